@@ -112,6 +112,13 @@ func TestAcceptance(t *testing.T) {
 			App:           "gomod_go_sum",
 			MustNotOutput: []string{"go.sum not found, generating"},
 		},
+		// Test that the appengine package can rely on /srv/ in the compile-time paths without the
+		// source being copied into the srv layer.
+		{
+			Name:       "gomod appengine package srv path",
+			App:        "gomod_appengine",
+			MustOutput: []string{"Linked the application source into"},
+		},
 		// Test that we can build an app with SDK dependencies
 		{
 			Name: "appengine_sdk dependencies",
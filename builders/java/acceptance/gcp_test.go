@@ -94,6 +94,22 @@ func TestAcceptanceJava(t *testing.T) {
 			MustUse:    []string{javaGradle, javaRuntime, entrypoint},
 			MustNotUse: []string{javaEntrypoint},
 		},
+		{
+			Name:               "Java maven reproducible",
+			App:                "hello_quarkus_maven",
+			Env:                []string{"GOOGLE_REPRODUCIBLE_BUILD=true"},
+			MustUse:            []string{javaMaven, javaRuntime, javaEntrypoint},
+			MustNotUse:         []string{entrypoint},
+			MustBeReproducible: true,
+		},
+		{
+			Name:               "Java gradle reproducible",
+			App:                "gradle_micronaut",
+			Env:                []string{"GOOGLE_REPRODUCIBLE_BUILD=true", "GOOGLE_ENTRYPOINT=java -jar build/libs/helloworld-0.1-all.jar"},
+			MustUse:            []string{javaGradle, javaRuntime, entrypoint},
+			MustNotUse:         []string{javaEntrypoint},
+			MustBeReproducible: true,
+		},
 		{
 			Name:       "Java mono repo",
 			App:        "gradle_mono_repo",
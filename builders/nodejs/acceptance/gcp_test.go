@@ -154,6 +154,13 @@ func TestFailures(t *testing.T) {
 			Env:                        []string{"GOOGLE_RUNTIME_VERSION=BAD_NEWS_BEARS"},
 			MustMatch:                  "invalid Node.js version specified",
 		},
+		{
+			Name:                       "npm audit fail level",
+			VersionInclusionConstraint: ">= 16.0.0",
+			App:                        "npm_audit_vulnerable",
+			Env:                        []string{"GOOGLE_NPM_AUDIT_FAIL_LEVEL=high"},
+			MustMatch:                  "npm audit found vulnerabilities with severity high or higher",
+		},
 	}
 
 	for _, tc := range acceptance.FilterFailureTests(t, testCases) {
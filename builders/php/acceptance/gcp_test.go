@@ -89,6 +89,24 @@ func TestAcceptance(t *testing.T) {
 			MustUse:                    []string{composer, composerGCPBuild, composerInstall, phpRuntime, phpWebConfig, utilsNginx},
 			MustNotUse:                 []string{functionFramework, cloudFunctions, entrypoint},
 		},
+		{
+			Name:                       "gcp-build script with dev dependencies",
+			App:                        "gcp_build_dev_dependencies",
+			VersionInclusionConstraint: ">= 7.3.0, < 8.2.0",
+			MustMatch:                  "PASS_NO_DEV_DEPENDENCIES",
+			MustUse:                    []string{composer, composerGCPBuild, composerInstall, phpRuntime, phpWebConfig, utilsNginx},
+			FilesMustNotExist:          []string{"/workspace/vendor/phpunit"},
+		},
+		{
+			Name:                       "dev dependencies opt-in",
+			App:                        "gcp_build_dev_dependencies",
+			VersionInclusionConstraint: ">= 7.3.0, < 8.2.0",
+			Path:                       "/?dev=true",
+			Env:                        []string{"GOOGLE_COMPOSER_DEV_DEPENDENCIES=true"},
+			MustMatch:                  "PASS_DEV_DEPENDENCIES",
+			MustUse:                    []string{composer, composerGCPBuild, composerInstall, phpRuntime, phpWebConfig, utilsNginx},
+			FilesMustExist:             []string{"/workspace/vendor/phpunit"},
+		},
 	}
 
 	for _, tc := range acceptance.FilterTests(t, imageCtx, testCases) {
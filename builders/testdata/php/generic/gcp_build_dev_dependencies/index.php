@@ -0,0 +1,36 @@
+<?php
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Reports whether the require-dev packages were installed in the image. The
+// gcp-build script uses phpunit, so generated.txt only exists if the dev
+// dependencies were available to it.
+
+if (!file_exists('generated.txt')) {
+  echo 'File generated by gcp-build not found.';
+  return;
+}
+
+$installed = json_decode(file_get_contents('vendor/composer/installed.json'), true);
+// Composer 2 wraps the list of packages, Composer 1 does not.
+$packages = isset($installed['packages']) ? $installed['packages'] : $installed;
+$names = array_column($packages, 'name');
+$hasDev = in_array('phpunit/phpunit', $names);
+
+$wantDev = isset($_GET['dev']) && $_GET['dev'] === 'true';
+if ($hasDev === $wantDev) {
+  echo $wantDev ? 'PASS_DEV_DEPENDENCIES' : 'PASS_NO_DEV_DEPENDENCIES';
+} else {
+  echo 'Unexpected packages in vendor/composer/installed.json: ' . implode(', ', $names);
+}
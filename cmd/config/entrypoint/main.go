@@ -46,6 +46,9 @@ func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
 	if os.Getenv(env.Entrypoint) != "" {
 		return gcp.OptInEnvSet(env.Entrypoint), nil
 	}
+	if os.Getenv(env.EntrypointJSON) != "" {
+		return gcp.OptInEnvSet(env.EntrypointJSON), nil
+	}
 	procExists, err := ctx.FileExists("Procfile")
 	if err != nil {
 		return nil, err
@@ -74,9 +77,18 @@ func buildFn(ctx *gcp.Context) error {
 		return appengine.Build(ctx, runtime, nil)
 	}
 
-	if entrypoint := os.Getenv(env.Entrypoint); entrypoint != "" {
-		ctx.AddProcess(gcp.WebProcess, []string{entrypoint}, gcp.AsDefaultProcess())
-		ctx.Logf("Using entrypoint from environment variable %s: %s", env.Entrypoint, entrypoint)
+	entrypoint, err := ctx.Entrypoint()
+	if err != nil {
+		return err
+	}
+	if entrypoint != nil {
+		if entrypoint.Exec != nil {
+			ctx.AddEntrypointProcess(entrypoint)
+		} else {
+			// The launcher runs the shell form in bash.
+			ctx.AddProcess(gcp.WebProcess, []string{entrypoint.Shell}, gcp.AsDefaultProcess())
+		}
+		ctx.Logf("Using entrypoint from environment variable %s: %s", entrypoint.Source, entrypoint)
 		return nil
 	}
 
@@ -92,13 +104,13 @@ func buildFn(ctx *gcp.Context) error {
 		return addProcfileProcesses(ctx, string(b))
 	}
 
-	entrypoint, err := appyaml.EntrypointIfExists(ctx.ApplicationRoot())
+	appYamlEntrypoint, err := appyaml.EntrypointIfExists(ctx.ApplicationRoot())
 	if err != nil {
 		return gcp.UserErrorf(fmt.Sprintf(
 			"app.yaml env var set but the specified app.yaml file doesn't exist."))
 	}
-	if entrypoint != "" {
-		ctx.AddProcess(gcp.WebProcess, []string{entrypoint}, gcp.AsDefaultProcess())
+	if appYamlEntrypoint != "" {
+		ctx.AddProcess(gcp.WebProcess, []string{appYamlEntrypoint}, gcp.AsDefaultProcess())
 		ctx.Logf("Using entrypoint from app.yaml.")
 		return nil
 	}
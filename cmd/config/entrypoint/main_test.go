@@ -35,6 +35,11 @@ func TestDetect(t *testing.T) {
 			env:  []string{"GOOGLE_ENTRYPOINT=my entrypoint"},
 			want: 0,
 		},
+		{
+			name: "with GOOGLE_ENTRYPOINT_JSON",
+			env:  []string{`GOOGLE_ENTRYPOINT_JSON=["my", "entrypoint"]`},
+			want: 0,
+		},
 		{
 			name: "with Procfile",
 			files: map[string]string{
@@ -77,6 +82,52 @@ func TestDetect(t *testing.T) {
 	}
 }
 
+func TestBuildEntrypointEnv(t *testing.T) {
+	testCases := []struct {
+		name string
+		env  map[string]string
+		want []libcnb.Process
+	}{
+		{
+			name: "GOOGLE_ENTRYPOINT",
+			env:  map[string]string{"GOOGLE_ENTRYPOINT": "gunicorn -b :$PORT main:app"},
+			want: []libcnb.Process{
+				{Type: "web", Command: "gunicorn -b :$PORT main:app", Default: true},
+			},
+		},
+		{
+			name: "GOOGLE_ENTRYPOINT_JSON",
+			env:  map[string]string{"GOOGLE_ENTRYPOINT_JSON": `["gunicorn", "-b", ":8080", "--log-config-json", "{\"version\": 1}", "main:app"]`},
+			want: []libcnb.Process{
+				{Type: "web", Command: "gunicorn", Arguments: []string{"-b", ":8080", "--log-config-json", `{"version": 1}`, "main:app"}, Direct: true, Default: true},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(t.TempDir()))
+			if err := buildFn(ctx); err != nil {
+				t.Fatalf("buildFn() got error: %v", err)
+			}
+			if got := ctx.Processes(); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("buildFn() processes = %#v, want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestBuildEntrypointEnvError(t *testing.T) {
+	t.Setenv("GOOGLE_ENTRYPOINT", "my entrypoint")
+	t.Setenv("GOOGLE_ENTRYPOINT_JSON", `["my", "entrypoint"]`)
+	ctx := gcp.NewContext(gcp.WithApplicationRoot(t.TempDir()))
+	if err := buildFn(ctx); err == nil {
+		t.Error("buildFn() got nil error, want error")
+	}
+}
+
 func TestProcfileProcesses(t *testing.T) {
 	testCases := []struct {
 		name    string
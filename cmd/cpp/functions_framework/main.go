@@ -24,8 +24,10 @@ import (
 	"text/template"
 	"time"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
 )
 
 const (
@@ -39,6 +41,17 @@ const (
 	vcpkgTripletName            = "x64-linux-nodebug"
 	installLayerName            = "cpp"
 	functionsFrameworkNamespace = "::google::cloud::functions"
+	cCompiler                   = "gcc-8"
+	cxxCompiler                 = "g++-8"
+
+	// vcpkgBinaryCacheKey keys the vcpkg binary cache on the toolchain only. Packages in the cache
+	// are addressed by their ABI hash, so changes to vcpkg.json only build the newly requested ports.
+	vcpkgBinaryCacheKey = "vcpkg_binary_cache_key"
+
+	ccacheLayerName  = "ccache"
+	ccacheVersion    = "4.10.2"
+	ccacheVersionKey = "version"
+	ccacheMaxSize    = "2G"
 )
 
 type signatureInfo struct {
@@ -50,6 +63,7 @@ type signatureInfo struct {
 
 var (
 	vcpkgURL             = fmt.Sprintf("%s/%s.tar.gz", vcpkgTarballPrefix, vcpkgVersion)
+	ccacheURL            = fmt.Sprintf("https://github.com/ccache/ccache/releases/download/v%[1]s/ccache-%[1]s-linux-x86_64.tar.xz", ccacheVersion)
 	mainTmpl             = template.Must(template.New("mainV0").Parse(mainTextTemplateV0))
 	declarativeSignature = signatureInfo{
 		ReturnType:   functionsFrameworkNamespace + "::Function",
@@ -71,6 +85,10 @@ var (
 	}
 )
 
+// ccacheSHA256 is the SHA256 checksum of the ccache release tarball of ccacheVersion. It must be
+// updated together with ccacheVersion, from the checksums published with the release.
+var ccacheSHA256 = ""
+
 type fnInfo struct {
 	Target    string
 	Namespace string
@@ -127,6 +145,9 @@ func buildFn(ctx *gcp.Context) error {
 	if err != nil {
 		return fmt.Errorf("creating %v layer: %w", vcpkgCacheLayerName, err)
 	}
+	if err := validateVcpkgBinaryCache(ctx, vcpkgCache); err != nil {
+		return err
+	}
 
 	mainLayer, err := ctx.Layer(mainLayerName)
 	if err != nil {
@@ -173,12 +194,17 @@ func buildFn(ctx *gcp.Context) error {
 		time.Sleep(time.Duration(i) * time.Second)
 	}
 
+	ccacheExePath, ccacheEnv, err := installCcache(ctx)
+	if err != nil {
+		return err
+	}
+
 	args := []string{
 		cmakeExePath,
 		"-GNinja",
 		"-DMAKE_BUILD_TYPE=Release",
-		"-DCMAKE_CXX_COMPILER=g++-8",
-		"-DCMAKE_C_COMPILER=gcc-8",
+		"-DCMAKE_CXX_COMPILER=" + cxxCompiler,
+		"-DCMAKE_C_COMPILER=" + cCompiler,
 		fmt.Sprintf("-DCMAKE_MAKE_PROGRAM=%s", ninjaExePath),
 		"-S", mainLayer.Path,
 		"-B", buildLayer.Path,
@@ -187,12 +213,19 @@ func buildFn(ctx *gcp.Context) error {
 		fmt.Sprintf("-DVCPKG_TARGET_TRIPLET=%s", vcpkgTripletName),
 		fmt.Sprintf("-DCMAKE_TOOLCHAIN_FILE=%s/scripts/buildsystems/vcpkg.cmake", vcpkgPath),
 	}
-	if _, err := ctx.Exec(args, gcp.WithUserAttribution, gcp.WithEnv(
+	if ccacheExePath != "" {
+		args = append(args,
+			fmt.Sprintf("-DCMAKE_CXX_COMPILER_LAUNCHER=%s", ccacheExePath),
+			fmt.Sprintf("-DCMAKE_C_COMPILER_LAUNCHER=%s", ccacheExePath))
+	}
+	configureEnv := append([]string{
 		fmt.Sprintf("VCPKG_DEFAULT_BINARY_CACHE=%s", vcpkgCache.Path),
-		fmt.Sprintf("VCPKG_DEFAULT_HOST_TRIPLET=%s", vcpkgTripletName))); err != nil {
+		fmt.Sprintf("VCPKG_DEFAULT_HOST_TRIPLET=%s", vcpkgTripletName),
+	}, ccacheEnv...)
+	if _, err := ctx.Exec(args, gcp.WithUserAttribution, gcp.WithEnv(configureEnv...)); err != nil {
 		return err
 	}
-	if _, err := ctx.Exec([]string{cmakeExePath, "--build", buildLayer.Path, "--target", "install"}, gcp.WithUserAttribution); err != nil {
+	if _, err := ctx.Exec([]string{cmakeExePath, "--build", buildLayer.Path, "--target", "install"}, gcp.WithUserAttribution, gcp.WithEnv(ccacheEnv...)); err != nil {
 		return err
 	}
 
@@ -200,6 +233,69 @@ func buildFn(ctx *gcp.Context) error {
 	return nil
 }
 
+// validateVcpkgBinaryCache clears the vcpkg binary cache if the toolchain used to build the
+// cached packages changed. The cache is deliberately not keyed on vcpkg.json.
+func validateVcpkgBinaryCache(ctx *gcp.Context, l *libcnb.Layer) error {
+	hash, cached, err := cache.HashAndCheck(ctx, l, vcpkgBinaryCacheKey, cache.WithStrings(vcpkgVersion, vcpkgTripletName, cCompiler, cxxCompiler))
+	if err != nil {
+		return err
+	}
+	if cached {
+		return nil
+	}
+	if err := ctx.ClearLayer(l); err != nil {
+		return fmt.Errorf("clearing layer %q: %w", l.Name, err)
+	}
+	cache.Add(ctx, l, vcpkgBinaryCacheKey, hash)
+	return nil
+}
+
+// installCcache installs ccache in a cached layer and returns its path along with the environment
+// needed to use it. The compiler cache persists across builds regardless of source changes and is
+// bounded by size instead. If ccache cannot be installed the build proceeds without it and an empty
+// path is returned.
+func installCcache(ctx *gcp.Context) (string, []string, error) {
+	l, err := ctx.Layer(ccacheLayerName, gcp.BuildLayer, gcp.CacheLayer)
+	if err != nil {
+		return "", nil, fmt.Errorf("creating %v layer: %w", ccacheLayerName, err)
+	}
+	binDir := filepath.Join(l.Path, "bin")
+	ccacheExePath := filepath.Join(binDir, "ccache")
+	exists, err := ctx.FileExists(ccacheExePath)
+	if err != nil {
+		return "", nil, err
+	}
+	if exists && ctx.GetMetadata(l, ccacheVersionKey) == ccacheVersion {
+		ctx.CacheHit(ccacheLayerName)
+	} else {
+		ctx.CacheMiss(ccacheLayerName)
+		// Only replace the executable, the compiler cache stays valid across ccache versions.
+		if err := ctx.RemoveAll(binDir); err != nil {
+			return "", nil, err
+		}
+		if err := ctx.MkdirAll(binDir, 0755); err != nil {
+			return "", nil, err
+		}
+		if ccacheSHA256 == "" {
+			ctx.Warnf("No checksum is pinned for ccache %s, building without a compiler cache.", ccacheVersion)
+			return "", nil, nil
+		}
+		ctx.Logf("Installing ccache %s", ccacheVersion)
+		// The tarball is only extracted once its checksum is verified.
+		tarball := filepath.Join(l.Path, "ccache.tar.xz")
+		command := fmt.Sprintf("curl --fail --show-error --silent --location --retry 3 --output %[1]s %[2]s && echo '%[3]s  %[1]s' | sha256sum --check --status && tar xJf %[1]s --directory %[4]s --strip-components=1 --wildcards '*/ccache'; status=$?; rm -f %[1]s; exit $status", tarball, ccacheURL, ccacheSHA256, binDir)
+		if _, err := ctx.Exec([]string{"bash", "-c", command}); err != nil {
+			ctx.Warnf("Installing ccache failed, building without a compiler cache: %v", err)
+			return "", nil, nil
+		}
+		ctx.SetMetadata(l, ccacheVersionKey, ccacheVersion)
+	}
+	return ccacheExePath, []string{
+		fmt.Sprintf("CCACHE_DIR=%s", filepath.Join(l.Path, "cache")),
+		fmt.Sprintf("CCACHE_MAXSIZE=%s", ccacheMaxSize),
+	}, nil
+}
+
 func warmupVcpkg(ctx *gcp.Context, vcpkgExePath string) error {
 	exec, err := ctx.Exec([]string{vcpkgExePath, "install", "--feature-flags=-manifests", "--only-downloads", "functions-framework-cpp"}, gcp.WithUserAttribution)
 	if err != nil {
@@ -22,6 +22,7 @@ import (
 	"testing"
 
 	buildpacktest "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 )
 
@@ -244,3 +245,68 @@ func TestPopulateMainLayer(t *testing.T) {
 		})
 	}
 }
+
+func TestBuild(t *testing.T) {
+	const testCcacheSHA256 = "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	testCases := []struct {
+		name              string
+		noCcacheSHA256    bool
+		mocks             []*mockprocess.Mock
+		wantCommands      []string
+		doNotWantCommands []string
+	}{
+		{
+			name: "with ccache",
+			wantCommands: []string{
+				"curl .*ccache-4.10.2-linux-x86_64.tar.xz && echo '" + testCcacheSHA256 + "  .*ccache.tar.xz' | sha256sum --check --status && tar xJf",
+				"cmake -GNinja .*-DCMAKE_CXX_COMPILER_LAUNCHER=.*ccache/bin/ccache",
+			},
+		},
+		{
+			name:              "no pinned ccache checksum",
+			noCcacheSHA256:    true,
+			wantCommands:      []string{"cmake -GNinja"},
+			doNotWantCommands: []string{"ccache-4.10.2-linux-x86_64.tar.xz", "CMAKE_CXX_COMPILER_LAUNCHER"},
+		},
+		{
+			name: "ccache install failure",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`ccache-4.10.2-linux-x86_64.tar.xz`, mockprocess.WithExitCode(1)),
+			},
+			wantCommands:      []string{"cmake -GNinja"},
+			doNotWantCommands: []string{"CMAKE_CXX_COMPILER_LAUNCHER"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			defer func(sha string) { ccacheSHA256 = sha }(ccacheSHA256)
+			ccacheSHA256 = testCcacheSHA256
+			if tc.noCcacheSHA256 {
+				ccacheSHA256 = ""
+			}
+			mocks := append(tc.mocks,
+				mockprocess.New(`vcpkg fetch --feature-flags=-manifests cmake$`, mockprocess.WithStdout("/usr/bin/cmake")),
+				mockprocess.New(`vcpkg fetch --feature-flags=-manifests ninja$`, mockprocess.WithStdout("/usr/bin/ninja")),
+			)
+			result, err := buildpacktest.RunBuild(t, buildFn,
+				buildpacktest.WithTestName(tc.name),
+				buildpacktest.WithFiles(map[string]string{"main.cc": ""}),
+				buildpacktest.WithEnvs("GOOGLE_FUNCTION_TARGET=HelloWorld"),
+				buildpacktest.WithExecMocks(mocks...),
+			)
+			if err != nil {
+				t.Fatalf("error running build: %v, logs: %s", err, result.Output)
+			}
+			for _, cmd := range tc.wantCommands {
+				if !result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to be executed, but it was not, build output: %s", cmd, result.Output)
+				}
+			}
+			for _, cmd := range tc.doNotWantCommands {
+				if result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q not to be executed, but it was, build output: %s", cmd, result.Output)
+				}
+			}
+		})
+	}
+}
@@ -17,8 +17,6 @@
 package main
 
 import (
-	"os"
-
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/appengine"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/appstart"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
@@ -41,10 +39,13 @@ func buildFn(ctx *gcp.Context) error {
 }
 
 func entrypoint(ctx *gcp.Context) (*appstart.Entrypoint, error) {
-	ep := os.Getenv(env.Entrypoint)
-	if ep == "" {
+	e, err := ctx.Entrypoint()
+	if err != nil {
+		return nil, err
+	}
+	if e == nil {
 		return nil, gcp.UserErrorf("expected entrypoint from app.yaml or root project file, found nothing")
 	}
-	ctx.Logf("Using the entrypoint: %q", ep)
-	return &appstart.Entrypoint{Type: appstart.EntrypointGenerated.String(), Command: ep}, nil
+	ctx.Logf("Using the entrypoint: %q", e)
+	return &appstart.Entrypoint{Type: appstart.EntrypointGenerated.String(), Command: e.String()}, nil
 }
@@ -18,6 +18,7 @@ import (
 	"testing"
 
 	buildpacktest "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 )
 
 func TestDetect(t *testing.T) {
@@ -42,3 +43,45 @@ func TestDetect(t *testing.T) {
 		})
 	}
 }
+
+func TestEntrypoint(t *testing.T) {
+	testCases := []struct {
+		name    string
+		envs    map[string]string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "GOOGLE_ENTRYPOINT",
+			envs: map[string]string{"GOOGLE_ENTRYPOINT": "cd bin && exec dotnet app.dll"},
+			want: "cd bin && exec dotnet app.dll",
+		},
+		{
+			name: "GOOGLE_ENTRYPOINT_JSON",
+			envs: map[string]string{"GOOGLE_ENTRYPOINT_JSON": `["dotnet", "bin/my app.dll"]`},
+			want: "dotnet 'bin/my app.dll'",
+		},
+		{
+			name:    "no entrypoint",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for k, v := range tc.envs {
+				t.Setenv(k, v)
+			}
+
+			got, err := entrypoint(gcp.NewContext())
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("entrypoint() got error: %v, want error: %v", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+			if got.Command != tc.want {
+				t.Errorf("entrypoint() command = %q, want %q", got.Command, tc.want)
+			}
+		})
+	}
+}
@@ -28,6 +28,7 @@ import (
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/dotnet"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/runtime"
 	"github.com/buildpacks/libcnb"
 )
 
@@ -35,8 +36,17 @@ const (
 	cacheTag          = "prod dependencies"
 	dependencyHashKey = "dependency_hash"
 	versionKey        = "version"
+	// publishHashKey is the key of the hash of the inputs of `dotnet publish` in the metadata of the
+	// publish layer, used to reuse its output when nothing changed.
+	publishHashKey = "publish_hash"
 )
 
+// resolveRuntimeVersion returns the runtime version the dotnet/runtime buildpack installs for a
+// version constraint. It can be overridden for testing.
+var resolveRuntimeVersion = func(ctx *gcp.Context, verConstraint string) (string, error) {
+	return runtime.ResolveVersion(ctx, runtime.AspNetCore, verConstraint, runtime.OSForStack(ctx))
+}
+
 func main() {
 	gcp.Main(detectFn, buildFn)
 }
@@ -67,7 +77,11 @@ func buildFn(ctx *gcp.Context) error {
 		return fmt.Errorf("creating layer: %w", err)
 	}
 
-	cached, err := checkCache(ctx, pkgLayer)
+	sdkVersion, err := sdkVersion(ctx)
+	if err != nil {
+		return err
+	}
+	cached, err := checkCache(ctx, pkgLayer, sdkVersion)
 	if err != nil {
 		return fmt.Errorf("checking cache: %w", err)
 	}
@@ -84,7 +98,7 @@ func buildFn(ctx *gcp.Context) error {
 		return err
 	}
 
-	binLayer, err := ctx.Layer(dotnet.PublishLayerName, gcp.BuildLayer, gcp.LaunchLayer)
+	binLayer, err := ctx.Layer(dotnet.PublishLayerName, gcp.BuildLayer, gcp.CacheLayer, gcp.LaunchLayer)
 	if err != nil {
 		return fmt.Errorf("creating layer: %w", err)
 	}
@@ -100,25 +114,13 @@ func buildFn(ctx *gcp.Context) error {
 		ctx.Warnf("A project file was uploaded, causing `dotnet publish` to be called, but the output bin folder already existed in application source.  Deleting %v.", outputDirectory)
 	}
 
-	cmd = []string{
-		"dotnet",
-		"publish",
-		"-nologo",
-		"--verbosity", "minimal",
-		"--configuration", "Release",
-		"--output", outputDirectory,
-		"--no-restore",
-		"--packages", pkgLayer.Path,
-		proj,
-	}
-
-	if args := os.Getenv(env.BuildArgs); args != "" {
-		// Use bash to excute the command to avoid havnig to parse the build arguments.
-		// strings.Fields may be unsafe here in case some arguments have a space.
-		cmd = []string{"/bin/bash", "-c", strings.Join(append(cmd, args), " ")}
+	publishHash, publishCached, err := checkPublishCache(ctx, binLayer, proj, sdkVersion)
+	if err != nil {
+		return fmt.Errorf("checking publish cache: %w", err)
 	}
-
-	if _, err := ctx.Exec(cmd, gcp.WithEnv("DOTNET_CLI_TELEMETRY_OPTOUT=true"), gcp.WithUserAttribution); err != nil {
+	if publishCached {
+		ctx.Logf("Reusing the output of `dotnet publish` from the previous build since the sources, project files and build arguments have not changed.")
+	} else if err := publish(ctx, binLayer, outputDirectory, pkgLayer.Path, proj, publishHash); err != nil {
 		return err
 	}
 
@@ -128,6 +130,12 @@ func buildFn(ctx *gcp.Context) error {
 		return gcp.InternalErrorf("getting runtime version: %v", err)
 	}
 	binLayer.BuildEnvironment.Default(dotnet.EnvRuntimeVersion, runtimeVersion)
+	// In dev mode the SDK is used to run the app and the runtime is not installed.
+	if !devmode.Enabled(ctx) {
+		if err := validateRuntimeVersion(ctx, outputDirectory, runtimeVersion); err != nil {
+			return err
+		}
+	}
 
 	// `dotnet publish` output originally went to ctx.ApplicationRoot()/bin/.  This was moved into a
 	// layer, but we create a symlink in the original location for backwards compatability.
@@ -136,22 +144,27 @@ func buildFn(ctx *gcp.Context) error {
 	}
 
 	// Infer the entrypoint in case an explicit override was not provided.
-	entrypoint := os.Getenv(env.Entrypoint)
-	if entrypoint != "" {
-		entrypoint = "exec " + entrypoint
+	entrypoint, err := ctx.Entrypoint()
+	if err != nil {
+		return err
+	}
+	var command []string
+	if entrypoint != nil {
+		command = entrypoint.Command()
 	} else {
 		ep, err := getEntrypoint(ctx, outputDirectory, proj)
 		if err != nil {
 			return fmt.Errorf("getting entrypoint: %w", err)
 		}
-		entrypoint = ep
-		binLayer.BuildEnvironment.Default(env.Entrypoint, entrypoint)
+		// The inferred entrypoint already execs dotnet after changing the working directory.
+		command = []string{"/bin/bash", "-c", ep}
+		binLayer.BuildEnvironment.Default(env.Entrypoint, ep)
 	}
 	binLayer.LaunchEnvironment.Default("DOTNET_RUNNING_IN_CONTAINER", "true")
 
 	// Configure the entrypoint for production.
 	if !devmode.Enabled(ctx) {
-		ctx.AddWebProcess([]string{"/bin/bash", "-c", entrypoint})
+		ctx.AddWebProcess(command)
 		return nil
 	}
 
@@ -160,6 +173,91 @@ func buildFn(ctx *gcp.Context) error {
 	return nil
 }
 
+// publish runs `dotnet publish` into the output directory of the publish layer, after clearing the
+// output of the previous build, and records the hash of its inputs so that the next build can reuse
+// the output.
+func publish(ctx *gcp.Context, binLayer *libcnb.Layer, outputDirectory, packagesDir, proj, hash string) error {
+	if err := ctx.ClearLayer(binLayer); err != nil {
+		return fmt.Errorf("clearing layer %q: %w", binLayer.Name, err)
+	}
+	ctx.Logf("Running `dotnet publish` since the output of the previous build cannot be reused.")
+	cmd := []string{
+		"dotnet",
+		"publish",
+		"-nologo",
+		"--verbosity", "minimal",
+		"--configuration", "Release",
+		"--output", outputDirectory,
+		"--no-restore",
+		"--packages", packagesDir,
+		proj,
+	}
+
+	if args := os.Getenv(env.BuildArgs); args != "" {
+		// Use bash to excute the command to avoid havnig to parse the build arguments.
+		// strings.Fields may be unsafe here in case some arguments have a space.
+		cmd = []string{"/bin/bash", "-c", strings.Join(append(cmd, args), " ")}
+	}
+
+	if _, err := ctx.Exec(cmd, gcp.WithEnv("DOTNET_CLI_TELEMETRY_OPTOUT=true"), gcp.WithUserAttribution); err != nil {
+		return err
+	}
+	// The hash is only recorded once publish succeeds, so that the output of a failed build is never
+	// reused.
+	cache.Add(ctx, binLayer, publishHashKey, hash)
+	return nil
+}
+
+// checkPublishCache returns the hash of the inputs of `dotnet publish`, and whether the publish layer
+// restored from the cache holds the output of a previous build with the same inputs. Any change to
+// the sources in the workspace, which include the project files, the projects they reference and
+// global.json, to the project being published, to the SDK version or to GOOGLE_BUILD_ARGS
+// invalidates the output. The uploaded bin directory is excluded since it is deleted before
+// publishing.
+func checkPublishCache(ctx *gcp.Context, binLayer *libcnb.Layer, proj, sdkVersion string) (string, bool, error) {
+	uploadedBin, err := filepath.Rel(ctx.WorkspaceRoot(), filepath.Join(ctx.ApplicationRoot(), dotnet.PublishOutputDirName))
+	if err != nil {
+		return "", false, err
+	}
+	return cache.HashAndCheck(ctx, binLayer, publishHashKey,
+		cache.WithNamedString("dotnet version", sdkVersion),
+		cache.WithNamedString("project", proj),
+		cache.WithNamedString(env.BuildArgs, os.Getenv(env.BuildArgs)),
+		cache.WithDirectory(ctx.WorkspaceRoot(), uploadedBin))
+}
+
+// validateRuntimeVersion fails the build if the ASP.NET Core runtime that the dotnet/runtime
+// buildpack installs for runtimeVersion does not satisfy a shared framework referenced by the
+// published runtimeconfig.json. Otherwise the app would exit at startup with a "framework not
+// found" error.
+func validateRuntimeVersion(ctx *gcp.Context, outputDirectory, runtimeVersion string) error {
+	refs, err := dotnet.GetFrameworkReferences(ctx, outputDirectory)
+	if err != nil {
+		return gcp.InternalErrorf("getting framework references: %v", err)
+	}
+	if len(refs) == 0 {
+		return nil
+	}
+	installed, err := resolveRuntimeVersion(ctx, runtimeVersion)
+	if err != nil {
+		return err
+	}
+	for _, ref := range refs {
+		ok, err := ref.AllowsVersion(installed)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return gcp.UserErrorf("the app requires %s %s with rollForward %q, but ASP.NET Core runtime %s would be installed for version %q and the app would fail to start; set %s to a compatible version or change the rollForward policy in the project file",
+				ref.Name, ref.Version, ref.RollForward, installed, runtimeVersion, dotnet.EnvRuntimeVersion)
+		}
+		if installed != ref.Version {
+			ctx.Logf("The app references %s %s, it will roll forward to %s (rollForward %q).", ref.Name, ref.Version, installed, ref.RollForward)
+		}
+	}
+	return nil
+}
+
 // getEntrypoint retrieves the appropriate entrypoint for this build.
 // * Check the output directory for a binary or a library with the same name as the project file (e.g. app.csproj --> app or app.dll).
 // * If not found, parse the project file for an AssemblyName field and check for the associated binary or library file in the output directory.
@@ -205,7 +303,16 @@ func getEntrypointCmd(ctx *gcp.Context, ep string) (string, error) {
 	return "", nil
 }
 
-func checkCache(ctx *gcp.Context, l *libcnb.Layer) (bool, error) {
+// sdkVersion returns the version of the .NET SDK.
+func sdkVersion(ctx *gcp.Context) (string, error) {
+	result, err := ctx.Exec([]string{"dotnet", "--version"})
+	if err != nil {
+		return "", err
+	}
+	return result.Stdout, nil
+}
+
+func checkCache(ctx *gcp.Context, l *libcnb.Layer, currentVersion string) (bool, error) {
 	// We cache all *.*proj files, as if we just cache just the main one, we would miss any changes
 	// to other libraries implemented as part of the app. As many apps are structured such that the
 	// main app only depends on the local binaries, that root project file would change very
@@ -223,14 +330,9 @@ func checkCache(ctx *gcp.Context, l *libcnb.Layer) (bool, error) {
 	if globalJSONExists {
 		projectFiles = append(projectFiles, globalJSON)
 	}
-	result, err := ctx.Exec([]string{"dotnet", "--version"})
-	if err != nil {
-		return false, err
-	}
-	currentVersion := result.Stdout
 
 	hash, cached, err := cache.HashAndCheck(ctx, l, dependencyHashKey,
-		cache.WithStrings(currentVersion),
+		cache.WithNamedString("dotnet version", currentVersion),
 		cache.WithFiles(projectFiles...))
 	if err != nil {
 		return false, err
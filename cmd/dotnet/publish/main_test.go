@@ -17,13 +17,18 @@ package main
 import (
 	"bytes"
 	"io/ioutil"
+	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"text/template"
 
 	buildpacktest "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
 )
 
 func TestGetAssemblyName(t *testing.T) {
@@ -338,3 +343,217 @@ func TestDeleteFolder(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateRuntimeVersion(t *testing.T) {
+	testCases := []struct {
+		name           string
+		runtimeOptions string
+		runtimeVersion string
+		// installed is the version resolved for runtimeVersion, which defaults to runtimeVersion.
+		installed string
+		wantLog   string
+		wantErr   bool
+	}{
+		{
+			name:           "exact match",
+			runtimeOptions: `"framework": {"name": "Microsoft.AspNetCore.App", "version": "8.0.0"}`,
+			runtimeVersion: "8.0.0",
+		},
+		{
+			name:           "roll forward to patch",
+			runtimeOptions: `"framework": {"name": "Microsoft.AspNetCore.App", "version": "8.0.0"}`,
+			runtimeVersion: "8.0.11",
+			wantLog:        `it will roll forward to 8.0.11 (rollForward "Minor")`,
+		},
+		{
+			name:           "older runtime",
+			runtimeOptions: `"framework": {"name": "Microsoft.AspNetCore.App", "version": "9.0.0-rc.2.24474.3"}`,
+			runtimeVersion: "8.0.11",
+			wantErr:        true,
+		},
+		{
+			name:           "roll forward disabled",
+			runtimeOptions: `"rollForward": "Disable", "framework": {"name": "Microsoft.AspNetCore.App", "version": "8.0.0"}`,
+			runtimeVersion: "8.0.11",
+			wantErr:        true,
+		},
+		{
+			name:           "major roll forward",
+			runtimeOptions: `"rollForward": "LatestMajor", "frameworks": [{"name": "Microsoft.NETCore.App", "version": "8.0.0"}, {"name": "Microsoft.AspNetCore.App", "version": "8.0.0"}]`,
+			runtimeVersion: "9.0.0",
+			wantLog:        `it will roll forward to 9.0.0 (rollForward "LatestMajor")`,
+		},
+		{
+			name:           "console app",
+			runtimeOptions: `"framework": {"name": "Microsoft.NETCore.App", "version": "8.0.0"}`,
+			runtimeVersion: "8.0.0",
+		},
+		{
+			name:           "console app on an older runtime",
+			runtimeOptions: `"framework": {"name": "Microsoft.NETCore.App", "version": "9.0.0"}`,
+			runtimeVersion: "8.0.11",
+			wantErr:        true,
+		},
+		{
+			name:           "runtimeconfig version resolves to a newer patch",
+			runtimeOptions: `"framework": {"name": "Microsoft.AspNetCore.App", "version": "8.0.0"}`,
+			runtimeVersion: "8.0",
+			installed:      "8.0.11",
+			wantLog:        `it will roll forward to 8.0.11 (rollForward "Minor")`,
+		},
+		{
+			name:           "resolved version differs and roll forward disabled",
+			runtimeOptions: `"rollForward": "Disable", "framework": {"name": "Microsoft.AspNetCore.App", "version": "8.0.0"}`,
+			runtimeVersion: "8.0.0",
+			installed:      "8.0.11",
+			wantErr:        true,
+		},
+		{
+			name:           "resolved version older than the preview reference",
+			runtimeOptions: `"framework": {"name": "Microsoft.AspNetCore.App", "version": "9.0.0-rc.2.24474.3"}`,
+			runtimeVersion: "9.0",
+			installed:      "8.0.11",
+			wantErr:        true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			rtCfg := `{"runtimeOptions": {` + tc.runtimeOptions + `}}`
+			if err := os.WriteFile(filepath.Join(dir, "app.runtimeconfig.json"), []byte(rtCfg), 0644); err != nil {
+				t.Fatalf("writing runtimeconfig.json: %v", err)
+			}
+			installed := tc.installed
+			if installed == "" {
+				installed = tc.runtimeVersion
+			}
+			orig := resolveRuntimeVersion
+			resolveRuntimeVersion = func(_ *gcp.Context, verConstraint string) (string, error) {
+				if verConstraint != tc.runtimeVersion {
+					t.Errorf("resolveRuntimeVersion() called with %q, want %q", verConstraint, tc.runtimeVersion)
+				}
+				return installed, nil
+			}
+			t.Cleanup(func() { resolveRuntimeVersion = orig })
+			var buf bytes.Buffer
+			ctx := gcp.NewContext(gcp.WithLogger(log.New(&buf, "", 0)))
+
+			err := validateRuntimeVersion(ctx, dir, tc.runtimeVersion)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("validateRuntimeVersion() got error: %v, want error: %v", err, tc.wantErr)
+			}
+			if !strings.Contains(buf.String(), tc.wantLog) {
+				t.Errorf("validateRuntimeVersion() logged %q, want output containing %q", buf.String(), tc.wantLog)
+			}
+		})
+	}
+}
+
+func TestCheckPublishCache(t *testing.T) {
+	files := map[string]string{
+		"app.csproj":         "<Project Sdk=\"Microsoft.NET.Sdk.Web\"></Project>",
+		"Program.cs":         "Console.WriteLine(\"hello\");",
+		"lib/lib.csproj":     "<Project Sdk=\"Microsoft.NET.Sdk\"></Project>",
+		"lib/Greeter.cs":     "class Greeter {}",
+		"global.json":        `{"sdk": {"version": "8.0.100"}}`,
+		"bin/uploaded.dll":   "stale",
+		"wwwroot/index.html": "<html></html>",
+	}
+	testCases := []struct {
+		name       string
+		files      map[string]string
+		buildArgs  string
+		sdkVersion string
+		proj       string
+		wantCached bool
+	}{
+		{
+			name:       "unchanged",
+			wantCached: true,
+		},
+		{
+			name:       "uploaded bin changed",
+			files:      map[string]string{"bin/uploaded.dll": "other"},
+			wantCached: true,
+		},
+		{
+			name:  "source changed",
+			files: map[string]string{"Program.cs": "Console.WriteLine(\"bye\");"},
+		},
+		{
+			name:  "referenced project source changed",
+			files: map[string]string{"lib/Greeter.cs": "class Greeter { }"},
+		},
+		{
+			name:  "source added",
+			files: map[string]string{"lib/Other.cs": "class Other {}"},
+		},
+		{
+			name:  "project file changed",
+			files: map[string]string{"app.csproj": "<Project Sdk=\"Microsoft.NET.Sdk.Web\"><PropertyGroup /></Project>"},
+		},
+		{
+			name:  "global.json changed",
+			files: map[string]string{"global.json": `{"sdk": {"version": "8.0.200"}}`},
+		},
+		{
+			name:      "build args changed",
+			buildArgs: "-p:Version=2.0.0",
+		},
+		{
+			name:       "sdk version changed",
+			sdkVersion: "8.0.200",
+		},
+		{
+			name: "project changed",
+			proj: "lib/lib.csproj",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles := func(files map[string]string) {
+				for name, content := range files {
+					path := filepath.Join(dir, name)
+					if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+						t.Fatal(err)
+					}
+					if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			writeFiles(files)
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(dir), gcp.WithBuildpackInfo(libcnb.BuildpackInfo{ID: "google.dotnet.publish", Version: "0.9.0"}))
+			l := &libcnb.Layer{Name: "publish", Metadata: map[string]any{}}
+			t.Setenv(env.BuildArgs, "")
+
+			hash, cached, err := checkPublishCache(ctx, l, "app.csproj", "8.0.100")
+			if err != nil {
+				t.Fatalf("checkPublishCache() got error: %v", err)
+			}
+			if cached {
+				t.Fatalf("checkPublishCache() got cached for the first build, want not cached")
+			}
+			cache.Add(ctx, l, publishHashKey, hash)
+
+			writeFiles(tc.files)
+			t.Setenv(env.BuildArgs, tc.buildArgs)
+			sdkVersion := "8.0.100"
+			if tc.sdkVersion != "" {
+				sdkVersion = tc.sdkVersion
+			}
+			proj := "app.csproj"
+			if tc.proj != "" {
+				proj = tc.proj
+			}
+			_, cached, err = checkPublishCache(ctx, l, proj, sdkVersion)
+			if err != nil {
+				t.Fatalf("checkPublishCache() got error: %v", err)
+			}
+			if cached != tc.wantCached {
+				t.Errorf("checkPublishCache() got cached: %v, want: %v", cached, tc.wantCached)
+			}
+		})
+	}
+}
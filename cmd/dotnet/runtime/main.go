@@ -81,6 +81,18 @@ func buildFn(ctx *gcp.Context) error {
 }
 
 func buildRuntimeLayer(ctx *gcp.Context, rtVersion string) error {
+	skip, err := runtime.SkipInstall()
+	if err != nil {
+		return err
+	}
+	if skip {
+		v, err := runtime.CheckPreinstalled(ctx, runtime.AspNetCore, rtVersion)
+		if err != nil {
+			return err
+		}
+		ctx.AddInstalledRuntimeVersion(v)
+		return nil
+	}
 	rtl, err := ctx.Layer(runtimeLayerName, gcp.CacheLayer, gcp.LaunchLayer)
 	if err != nil {
 		return fmt.Errorf("creating %v layer: %w", runtimeLayerName, err)
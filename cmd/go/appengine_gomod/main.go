@@ -87,7 +87,7 @@ func buildFn(ctx *gcp.Context) error {
 	l.BuildEnvironment.Override(env.Buildable, buildMainPath)
 
 	// HACK: For backwards compatibility on App Engine Go 1.11:
-	// Copy all files to a layer directory that ends with /srv because the appengine package relies on the name:
+	// Build from a layer directory that ends with /srv because the appengine package relies on the name:
 	// https://github.com/golang/appengine/blob/553959209a20f3be281c16dd5be5c740a893978f/delay/delay.go#L136
 	// We change the work directory instead of modifying env.Buildable, because the latter is not necessarily a filesystem path.
 	srvl, err := ctx.Layer("srv", gcp.BuildLayer)
@@ -95,7 +95,7 @@ func buildFn(ctx *gcp.Context) error {
 		return fmt.Errorf("creating srv layer: %w", err)
 	}
 	srvl.BuildEnvironment.Override(golang.BuildDirEnv, srvl.Path)
-	if _, err := ctx.Exec([]string{"cp", "--dereference", "-R", ".", srvl.Path}, gcp.WithUserTimingAttribution); err != nil {
+	if err := populateSrv(ctx, srvl); err != nil {
 		return err
 	}
 
@@ -0,0 +1,177 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+const (
+	// srvStrategyMetadataKey is the srv layer metadata key recording how the source was populated.
+	srvStrategyMetadataKey = "strategy"
+	// symlinkStrategy links each top-level entry of the application root into the srv layer.
+	symlinkStrategy = "symlink"
+	// copyStrategy copies the application source into the srv layer.
+	copyStrategy = "copy"
+)
+
+// embedRegexp matches a //go:embed directive.
+var embedRegexp = regexp.MustCompile(`(?m)^\s*//go:embed\s`)
+
+// skippedDirs are never copied into the srv layer because they are not needed to build Go code.
+var skippedDirs = map[string]bool{
+	".git":         true,
+	"node_modules": true,
+}
+
+// populateSrv makes the application source available in the srv layer and records the strategy
+// used in the layer metadata. The layer must be a directory for the lifecycle, so it cannot be a
+// single symlink to the application root; instead it is filled with a symlink for each top-level
+// entry. Go records the paths under the srv layer at compile time since it does not resolve
+// symlinks. The source is copied instead if the package in the application root embeds files,
+// since //go:embed rejects symlinks, or if the symlinks cannot be created.
+func populateSrv(ctx *gcp.Context, srvl *libcnb.Layer) error {
+	root := ctx.ApplicationRoot()
+	embeds, err := rootPackageEmbeds(ctx, root)
+	if err != nil {
+		return err
+	}
+	if embeds {
+		ctx.Logf("Copying the application source into %s because the package in the application root uses //go:embed", srvl.Path)
+	} else {
+		err := linkSrc(ctx, root, srvl.Path)
+		if err == nil {
+			ctx.SetMetadata(srvl, srvStrategyMetadataKey, symlinkStrategy)
+			ctx.Logf("Linked the application source into %s", srvl.Path)
+			return nil
+		}
+		ctx.Warnf("Linking the application source into %s failed, copying it instead: %v", srvl.Path, err)
+		if err := ctx.ClearLayer(srvl); err != nil {
+			return err
+		}
+	}
+	if err := copySrc(ctx, root, srvl.Path); err != nil {
+		return fmt.Errorf("copying the application source into %s: %w", srvl.Path, err)
+	}
+	ctx.SetMetadata(srvl, srvStrategyMetadataKey, copyStrategy)
+	return nil
+}
+
+// rootPackageEmbeds returns true if a Go file in root, other than a test, has a //go:embed
+// directive. Only the package in root is affected by the symlinks: in the other packages, the
+// embedded paths are under a linked directory but are not symlinks themselves.
+func rootPackageEmbeds(ctx *gcp.Context, root string) (bool, error) {
+	files, err := filepath.Glob(filepath.Join(root, "*.go"))
+	if err != nil {
+		return false, gcp.InternalErrorf("finding Go files in %s: %v", root, err)
+	}
+	for _, f := range files {
+		if strings.HasSuffix(f, "_test.go") {
+			continue
+		}
+		content, err := ctx.ReadFile(f)
+		if err != nil {
+			return false, err
+		}
+		if embedRegexp.Match(content) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// linkSrc creates a symlink in dest for each top-level entry of root. Writes through the links,
+// e.g. `go mod tidy` updating go.mod, change the application source as they do for the other Go
+// buildpacks.
+func linkSrc(ctx *gcp.Context, root, dest string) error {
+	entries, err := ctx.ReadDir(root)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := ctx.Symlink(filepath.Join(root, e.Name()), filepath.Join(dest, e.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copySrc copies the source under root to dest, except the skippedDirs. Any file may be embedded
+// with //go:embed, so files are not filtered by type. .gcloudignore is not applied: gcloud already
+// leaves the files it matches out of the uploaded source. Symlinks are copied as symlinks rather
+// than dereferenced.
+func copySrc(ctx *gcp.Context, root, dest string) error {
+	return filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == root {
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() && skippedDirs[d.Name()] {
+			return filepath.SkipDir
+		}
+		target := filepath.Join(dest, filepath.FromSlash(rel))
+		switch {
+		case d.IsDir():
+			return ctx.MkdirAll(target, 0755)
+		case d.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			return ctx.Symlink(link, target)
+		case d.Type().IsRegular():
+			return copyFile(p, target)
+		}
+		return nil
+	})
+}
+
+// copyFile copies the content and permissions of the regular file src to dst.
+func copyFile(src, dst string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,194 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+	"github.com/google/go-cmp/cmp"
+)
+
+var appFiles = map[string]string{
+	"go.mod":                    "module example.com/app\n",
+	"main.go":                   "package main\n",
+	"pkg/lib/lib.go":            "package lib\n",
+	"static/large.bin":          "assets",
+	"node_modules/dep/index.js": "",
+	".git/HEAD":                 "ref: refs/heads/main\n",
+	"tmp/scratch.go":            "package tmp\n",
+	"debug.log":                 "",
+	"pkg/lib/keep.log":          "",
+	".gitignore":                "*.log\n!keep.log\n",
+	".gcloudignore":             "#!include:.gitignore\n/static/\ntmp\n",
+}
+
+func TestPopulateSrv(t *testing.T) {
+	testCases := []struct {
+		name         string
+		files        map[string]string
+		existing     string
+		wantStrategy string
+		wantSymlink  bool
+		// wantRegular are the embedded paths, which must not be symlinks since //go:embed calls Lstat.
+		wantRegular []string
+	}{
+		{
+			name:         "symlinks",
+			wantStrategy: symlinkStrategy,
+			wantSymlink:  true,
+		},
+		{
+			name:         "copy when linking fails",
+			existing:     "main.go",
+			wantStrategy: copyStrategy,
+		},
+		{
+			name: "copy when the root package embeds files",
+			files: map[string]string{
+				"main.go":    "package main\n\nimport _ \"embed\"\n\n//go:embed index.html\nvar index string\n",
+				"index.html": "<html></html>",
+			},
+			wantStrategy: copyStrategy,
+			wantRegular:  []string{"index.html"},
+		},
+		{
+			name: "symlinks when a test of the root package embeds files",
+			files: map[string]string{
+				"main_test.go": "package main\n\nimport _ \"embed\"\n\n//go:embed index.html\nvar index string\n",
+				"index.html":   "<html></html>",
+			},
+			wantStrategy: symlinkStrategy,
+			wantSymlink:  true,
+		},
+		{
+			name: "symlinks when a subpackage embeds files",
+			files: map[string]string{
+				"pkg/lib/lib.go":     "package lib\n\nimport _ \"embed\"\n\n//go:embed index.html\nvar index string\n",
+				"pkg/lib/index.html": "<html></html>",
+			},
+			wantStrategy: symlinkStrategy,
+			wantSymlink:  true,
+			wantRegular:  []string{"pkg/lib/index.html"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeFiles(t, root, appFiles)
+			writeFiles(t, root, tc.files)
+			srv := filepath.Join(t.TempDir(), "srv")
+			if err := os.Mkdir(srv, 0755); err != nil {
+				t.Fatal(err)
+			}
+			if tc.existing != "" {
+				writeFiles(t, srv, map[string]string{tc.existing: "stale"})
+			}
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(root))
+			srvl := &libcnb.Layer{Path: srv, Metadata: map[string]interface{}{}}
+
+			if err := populateSrv(ctx, srvl); err != nil {
+				t.Fatalf("populateSrv() got error: %v", err)
+			}
+
+			if got := ctx.GetMetadata(srvl, srvStrategyMetadataKey); got != tc.wantStrategy {
+				t.Errorf("populateSrv() strategy = %q, want %q", got, tc.wantStrategy)
+			}
+			info, err := os.Lstat(filepath.Join(srv, "main.go"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if gotSymlink := info.Mode()&fs.ModeSymlink != 0; gotSymlink != tc.wantSymlink {
+				t.Errorf("populateSrv() main.go is a symlink = %t, want %t", gotSymlink, tc.wantSymlink)
+			}
+			content, err := os.ReadFile(filepath.Join(srv, "pkg", "lib", "lib.go"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := appFiles["pkg/lib/lib.go"]
+			if f, ok := tc.files["pkg/lib/lib.go"]; ok {
+				want = f
+			}
+			if got := string(content); got != want {
+				t.Errorf("populateSrv() pkg/lib/lib.go = %q, want %q", got, want)
+			}
+			for _, embedded := range tc.wantRegular {
+				info, err := os.Lstat(filepath.Join(srv, filepath.FromSlash(embedded)))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !info.Mode().IsRegular() {
+					t.Errorf("populateSrv() %s mode = %v, want a regular file", embedded, info.Mode())
+				}
+			}
+		})
+	}
+}
+
+func TestCopySrc(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, appFiles)
+	if err := os.Symlink("pkg/lib", filepath.Join(root, "lib")); err != nil {
+		t.Fatal(err)
+	}
+	dest := t.TempDir()
+	ctx := gcp.NewContext(gcp.WithApplicationRoot(root))
+
+	if err := copySrc(ctx, root, dest); err != nil {
+		t.Fatalf("copySrc() got error: %v", err)
+	}
+
+	var got []string
+	if err := filepath.WalkDir(dest, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dest, p)
+		got = append(got, filepath.ToSlash(rel))
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(got)
+	want := []string{".gcloudignore", ".gitignore", "debug.log", "go.mod", "lib", "main.go", "pkg/lib/keep.log", "pkg/lib/lib.go", "static/large.bin", "tmp/scratch.go"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("copySrc() files mismatch (-want +got):\n%s", diff)
+	}
+	link, err := os.Readlink(filepath.Join(dest, "lib"))
+	if err != nil {
+		t.Fatalf("copySrc() did not copy the symlink lib: %v", err)
+	}
+	if link != "pkg/lib" {
+		t.Errorf("copySrc() lib links to %q, want %q", link, "pkg/lib")
+	}
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -20,6 +20,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	goruntime "runtime"
+	"strconv"
 	"strings"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/devmode"
@@ -31,8 +33,24 @@ import (
 const (
 	noGoFileError         = "no Go files in"
 	cannotFindModuleError = "cannot find module"
+	// defaultGoCacheSizeMB is the default size limit of the Go build cache.
+	defaultGoCacheSizeMB = 2048
 )
 
+// buildModeOutputs are the build modes that GOOGLE_GO_BUILDMODE may select, and the name of the
+// file that go build writes in each mode.
+var buildModeOutputs = map[string]string{
+	"exe":      golang.OutBin,
+	"c-shared": "lib" + golang.OutBin + ".so",
+	"plugin":   golang.OutBin + ".so",
+}
+
+// supportedTargets are the GOOS/GOARCH combinations that GOOGLE_GOOS and GOOGLE_GOARCH may select.
+var supportedTargets = map[string]bool{
+	"linux/amd64": true,
+	"linux/arm64": true,
+}
+
 func main() {
 	gcp.Main(detectFn, buildFn)
 }
@@ -67,13 +85,50 @@ func buildFn(ctx *gcp.Context) error {
 	bl.LaunchEnvironment.Prepend("PATH", string(os.PathListSeparator), bl.Path)
 	outBin := filepath.Join(bl.Path, golang.OutBin)
 
-	buildable, err := goBuildable(ctx)
+	host := goruntime.GOOS + "/" + goruntime.GOARCH
+	buildMode, err := goBuildMode(host)
+	if err != nil {
+		return err
+	}
+
+	prebuilt, err := prebuiltBinary(ctx)
+	if err != nil {
+		return err
+	}
+	if prebuilt != "" {
+		ctx.Logf("Skipping go build because %s is set, using the prebuilt binary %s.", env.Prebuilt, prebuilt)
+		data, err := ctx.ReadFile(prebuilt)
+		if err != nil {
+			return err
+		}
+		if err := ctx.WriteFile(outBin, data, 0755); err != nil {
+			return err
+		}
+		ctx.AddWebProcess([]string{outBin})
+		return nil
+	}
+
+	goWork, err := golang.AppGoWork(ctx)
+	if err != nil {
+		return err
+	}
+	goEnv := append(golang.GoFlags(ctx), golang.GoWorkEnv(goWork)...)
+	buildable, err := goBuildable(ctx, goEnv, goWork)
 	if err != nil {
 		return fmt.Errorf("unable to find a valid buildable: %w", err)
 	}
 
+	targetEnv, err := crossCompileEnv(ctx, host)
+	if err != nil {
+		return err
+	}
+
 	// Build the application.
 	bld := []string{"go", "build"}
+	if buildMode != "" {
+		bld = append(bld, "-buildmode="+buildMode)
+		outBin = filepath.Join(bl.Path, buildModeOutputs[buildMode])
+	}
 	bld = append(bld, goBuildFlags()...)
 	bld = append(bld, "-o", outBin)
 	bld = append(bld, buildable)
@@ -82,10 +137,20 @@ func buildFn(ctx *gcp.Context) error {
 	if workdir == "" {
 		workdir = ctx.ApplicationRoot()
 	}
-	if _, err := ctx.Exec(bld, gcp.WithEnv("GOCACHE="+cl.Path), gcp.WithWorkDir(workdir), gcp.WithMessageProducer(printTipsAndKeepStderrTail(ctx)), gcp.WithUserAttribution); err != nil {
+	buildEnv := append([]string{"GOCACHE=" + cl.Path}, goEnv...)
+	buildEnv = append(buildEnv, targetEnv...)
+	if _, err := ctx.Exec(bld, gcp.WithEnv(buildEnv...), gcp.WithWorkDir(workdir), gcp.WithMessageProducer(printTipsAndKeepStderrTail(ctx)), gcp.WithUserAttribution); err != nil {
+		return err
+	}
+	if err := trimGoCache(ctx, cl.Path); err != nil {
 		return err
 	}
 
+	if buildMode != "" && buildMode != "exe" {
+		ctx.Warnf("No runnable entrypoint was produced: %s=%s builds %s instead of an executable, so no web process is configured. Set a custom entrypoint that loads it.", env.GoBuildMode, buildMode, outBin)
+		return nil
+	}
+
 	// Configure the entrypoint for production. Use the full path to save `skaffold debug`
 	// from fetching the remote container image (tens to hundreds of megabytes), which is slow.
 	if !devmode.Enabled(ctx) {
@@ -105,7 +170,67 @@ func buildFn(ctx *gcp.Context) error {
 	return nil
 }
 
-func goBuildable(ctx *gcp.Context) (string, error) {
+// prebuiltBinary returns the path of the executable named by GOOGLE_PREBUILT_BINARY if
+// GOOGLE_PREBUILT is true, or an empty string if the application should be built from source.
+func prebuiltBinary(ctx *gcp.Context) (string, error) {
+	prebuilt, err := env.IsPresentAndTrue(env.Prebuilt)
+	if err != nil || !prebuilt {
+		return "", err
+	}
+	bin := os.Getenv(env.PrebuiltBinary)
+	if bin == "" {
+		return "", gcp.UserErrorf("%s is set but %s does not specify the prebuilt binary to run", env.Prebuilt, env.PrebuiltBinary)
+	}
+	if !filepath.IsAbs(bin) {
+		bin = filepath.Join(ctx.ApplicationRoot(), bin)
+	}
+	info, err := os.Stat(bin)
+	if os.IsNotExist(err) {
+		return "", gcp.UserErrorf("%s=%q does not exist", env.PrebuiltBinary, os.Getenv(env.PrebuiltBinary))
+	}
+	if err != nil {
+		return "", gcp.InternalErrorf("stat %q: %v", bin, err)
+	}
+	if !info.Mode().IsRegular() {
+		return "", gcp.UserErrorf("%s=%q is not a regular file", env.PrebuiltBinary, os.Getenv(env.PrebuiltBinary))
+	}
+	if info.Mode().Perm()&0111 == 0 {
+		return "", gcp.UserErrorf("%s=%q is not executable, run `chmod +x` on it before deploying", env.PrebuiltBinary, os.Getenv(env.PrebuiltBinary))
+	}
+	return bin, nil
+}
+
+// goBuildMode returns the build mode set with GOOGLE_GO_BUILDMODE, or an empty string if it is not
+// set. The c-shared and plugin build modes require cgo, so they are rejected when cross-compiling
+// from host would disable it.
+func goBuildMode(host string) (string, error) {
+	mode := os.Getenv(env.GoBuildMode)
+	if mode == "" {
+		return "", nil
+	}
+	if _, ok := buildModeOutputs[mode]; !ok {
+		return "", gcp.UserErrorf("unsupported build mode %q set by %s, supported build modes are exe, c-shared and plugin", mode, env.GoBuildMode)
+	}
+	if mode == "exe" {
+		return mode, nil
+	}
+	if _, ok := os.LookupEnv("CGO_ENABLED"); ok {
+		return mode, nil
+	}
+	target, err := goTarget(host)
+	if err != nil {
+		return "", err
+	}
+	if target != "" && target != host {
+		return "", gcp.UserErrorf("%s=%s requires cgo, which is disabled to cross-compile for %s from %s: build on a %s builder, or set CGO_ENABLED=1 and provide a C cross-compiler with CC", env.GoBuildMode, mode, target, host, target)
+	}
+	return mode, nil
+}
+
+// goBuildable returns the package to build: the one set with GOOGLE_BUILDABLE, or the only main
+// package of the application. The main packages of all the modules of the Go workspace defined by
+// goWork, if any, are considered.
+func goBuildable(ctx *gcp.Context, goEnv []string, goWork string) (string, error) {
 	// The user tells us what to build.
 	if buildable, ok := os.LookupEnv(env.Buildable); ok {
 		return buildable, nil
@@ -114,7 +239,7 @@ func goBuildable(ctx *gcp.Context) (string, error) {
 	// We have to guess which package/file to build.
 	// `go build` will by default build the `.` package
 	// but we try to be smarter by searching for a valid buildable.
-	buildables, err := searchBuildables(ctx)
+	buildables, err := searchBuildables(ctx, goEnv, goWork)
 	if err != nil {
 		return "", err
 	}
@@ -129,8 +254,13 @@ func goBuildable(ctx *gcp.Context) (string, error) {
 
 // searchBuildables searches the source for all the files that contain
 // a `main()` entrypoint.
-func searchBuildables(ctx *gcp.Context) ([]string, error) {
-	result, err := ctx.Exec([]string{"go", "list", "-f", `{{if eq .Name "main"}}{{.Dir}}{{end}}`, "./..."}, gcp.WithUserAttribution)
+func searchBuildables(ctx *gcp.Context, goEnv []string, goWork string) ([]string, error) {
+	patterns, err := buildablePatterns(ctx, goWork)
+	if err != nil {
+		return nil, err
+	}
+	cmd := append([]string{"go", "list", "-f", `{{if eq .Name "main"}}{{.Dir}}{{end}}`}, patterns...)
+	result, err := ctx.Exec(cmd, gcp.WithEnv(goEnv...), gcp.WithUserAttribution)
 	if err != nil {
 		return nil, err
 	}
@@ -149,6 +279,106 @@ func searchBuildables(ctx *gcp.Context) ([]string, error) {
 	return buildables, nil
 }
 
+// buildablePatterns returns the package patterns that searchBuildables lists: the packages under
+// the application root, or those of every module of the Go workspace defined by goWork.
+func buildablePatterns(ctx *gcp.Context, goWork string) ([]string, error) {
+	if goWork == "" {
+		return []string{"./..."}, nil
+	}
+	modules, err := golang.GoWorkModules(ctx, goWork)
+	if err != nil {
+		return nil, err
+	}
+	var patterns []string
+	for _, dir := range modules {
+		rel, err := filepath.Rel(ctx.ApplicationRoot(), dir)
+		if err != nil {
+			return nil, gcp.InternalErrorf("finding relative path for %q: %v", dir, err)
+		}
+		patterns = append(patterns, "./"+filepath.ToSlash(filepath.Join(rel, "...")))
+	}
+	if len(patterns) == 0 {
+		return []string{"./..."}, nil
+	}
+	return patterns, nil
+}
+
+// trimGoCache clears the Go build cache at the given path if it is larger than the limit
+// configured by GOOGLE_GO_BUILD_CACHE_SIZE_MB, which keeps the layer from growing unboundedly.
+func trimGoCache(ctx *gcp.Context, path string) error {
+	limit := defaultGoCacheSizeMB
+	if v := os.Getenv(env.GoBuildCacheSizeMB); v != "" {
+		l, err := strconv.Atoi(v)
+		if err != nil || l <= 0 {
+			return gcp.UserErrorf("%s must be a positive number of megabytes, found %q", env.GoBuildCacheSizeMB, v)
+		}
+		limit = l
+	}
+	result, err := ctx.Exec([]string{"du", "-sm", path})
+	if err != nil {
+		return err
+	}
+	fields := strings.Fields(result.Stdout)
+	if len(fields) == 0 {
+		return gcp.InternalErrorf("parsing size of Go build cache from %q", result.Stdout)
+	}
+	size, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return gcp.InternalErrorf("parsing size of Go build cache from %q: %v", result.Stdout, err)
+	}
+	if size <= limit {
+		return nil
+	}
+	ctx.Logf("Go build cache size %d MB exceeds the %d MB limit set by %s, clearing it.", size, limit, env.GoBuildCacheSizeMB)
+	if _, err := ctx.Exec([]string{"go", "clean", "-cache", "-testcache"}, gcp.WithEnv("GOCACHE="+path)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// crossCompileEnv returns the environment that builds the binary for the target selected by
+// GOOGLE_GOOS and GOOGLE_GOARCH, which default to the host platform. Since cross-compiling with cgo
+// requires a C cross-compiler that the builder does not have, cgo is disabled when the target
+// differs from the host unless CGO_ENABLED is set.
+func crossCompileEnv(ctx *gcp.Context, host string) ([]string, error) {
+	target, err := goTarget(host)
+	if err != nil || target == "" {
+		return nil, err
+	}
+	goos, goarch, _ := strings.Cut(target, "/")
+	targetEnv := []string{"GOOS=" + goos, "GOARCH=" + goarch}
+	if target == host {
+		return targetEnv, nil
+	}
+	ctx.Logf("Cross-compiling for %s.", target)
+	if _, ok := os.LookupEnv("CGO_ENABLED"); !ok {
+		ctx.Warnf("Disabling cgo to cross-compile for %s from %s. Packages that require cgo will fail to build, set CGO_ENABLED=1 and provide a C cross-compiler with CC to use cgo.", target, host)
+		targetEnv = append(targetEnv, "CGO_ENABLED=0")
+	}
+	return targetEnv, nil
+}
+
+// goTarget returns the GOOS/GOARCH target selected by GOOGLE_GOOS and GOOGLE_GOARCH, each of which
+// defaults to the host platform, or an empty string if neither is set.
+func goTarget(host string) (string, error) {
+	goos, goarch := os.Getenv(env.GoOS), os.Getenv(env.GoArch)
+	if goos == "" && goarch == "" {
+		return "", nil
+	}
+	hostOS, hostArch, _ := strings.Cut(host, "/")
+	if goos == "" {
+		goos = hostOS
+	}
+	if goarch == "" {
+		goarch = hostArch
+	}
+	target := goos + "/" + goarch
+	if !supportedTargets[target] {
+		return "", gcp.UserErrorf("unsupported target %s selected by %s and %s, supported targets are linux/amd64 and linux/arm64", target, env.GoOS, env.GoArch)
+	}
+	return target, nil
+}
+
 func goBuildFlags() []string {
 	var flags []string
 	if v := os.Getenv(env.GoGCFlags); v != "" {
@@ -16,11 +16,14 @@ package main
 
 import (
 	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
 
 	"github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 )
 
 func TestDetect(t *testing.T) {
@@ -90,6 +93,435 @@ func TestGoBuildFlags(t *testing.T) {
 	}
 }
 
+func TestCrossCompileEnv(t *testing.T) {
+	oldEnv := os.Environ()
+	t.Cleanup(func() {
+		clearAndSetEnv(oldEnv)
+	})
+	testCases := []struct {
+		name    string
+		env     []string
+		host    string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "no target",
+			host: "linux/amd64",
+		},
+		{
+			name: "cross-compile to arm64",
+			env:  []string{"GOOGLE_GOOS=linux", "GOOGLE_GOARCH=arm64"},
+			host: "linux/amd64",
+			want: []string{"GOOS=linux", "GOARCH=arm64", "CGO_ENABLED=0"},
+		},
+		{
+			name: "cross-compile to amd64",
+			env:  []string{"GOOGLE_GOARCH=amd64"},
+			host: "linux/arm64",
+			want: []string{"GOOS=linux", "GOARCH=amd64", "CGO_ENABLED=0"},
+		},
+		{
+			name: "cross-compile with cgo enabled",
+			env:  []string{"GOOGLE_GOARCH=arm64", "CGO_ENABLED=1"},
+			host: "linux/amd64",
+			want: []string{"GOOS=linux", "GOARCH=arm64"},
+		},
+		{
+			name: "target is host",
+			env:  []string{"GOOGLE_GOOS=linux", "GOOGLE_GOARCH=amd64"},
+			host: "linux/amd64",
+			want: []string{"GOOS=linux", "GOARCH=amd64"},
+		},
+		{
+			name:    "unsupported architecture",
+			env:     []string{"GOOGLE_GOARCH=386"},
+			host:    "linux/amd64",
+			wantErr: true,
+		},
+		{
+			name:    "unsupported operating system",
+			env:     []string{"GOOGLE_GOOS=windows"},
+			host:    "linux/amd64",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			clearAndSetEnv(tc.env)
+			got, err := crossCompileEnv(gcp.NewContext(), tc.host)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("crossCompileEnv(%q) = %v, want error", tc.host, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("crossCompileEnv(%q) got error: %v", tc.host, err)
+			}
+			if !reflect.DeepEqual(tc.want, got) {
+				t.Errorf("crossCompileEnv(%q) = %v, want %v", tc.host, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestBuildTrimsGoCache(t *testing.T) {
+	testCases := []struct {
+		name              string
+		envs              []string
+		cacheSize         string
+		wantExitCode      int // 0 if unspecified
+		wantCommands      []string
+		doNotWantCommands []string
+	}{
+		{
+			name:              "cache under default limit",
+			cacheSize:         "1024\t/layers/gocache",
+			doNotWantCommands: []string{"go clean -cache -testcache"},
+		},
+		{
+			name:         "cache over default limit",
+			cacheSize:    "2049\t/layers/gocache",
+			wantCommands: []string{"go clean -cache -testcache"},
+		},
+		{
+			name:         "cache over configured limit",
+			envs:         []string{"GOOGLE_GO_BUILD_CACHE_SIZE_MB=100"},
+			cacheSize:    "101\t/layers/gocache",
+			wantCommands: []string{"go clean -cache -testcache"},
+		},
+		{
+			name:              "cache under configured limit",
+			envs:              []string{"GOOGLE_GO_BUILD_CACHE_SIZE_MB=4096"},
+			cacheSize:         "3000\t/layers/gocache",
+			doNotWantCommands: []string{"go clean -cache -testcache"},
+		},
+		{
+			name:         "invalid limit",
+			envs:         []string{"GOOGLE_GO_BUILD_CACHE_SIZE_MB=lots"},
+			cacheSize:    "1\t/layers/gocache",
+			wantExitCode: 1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			envs := append([]string{"GOOGLE_BUILDABLE=."}, tc.envs...)
+			result, err := buildpacktest.RunBuild(t, buildFn,
+				buildpacktest.WithTestName(tc.name),
+				buildpacktest.WithEnvs(envs...),
+				buildpacktest.WithExecMocks(mockprocess.New(`^du -sm`, mockprocess.WithStdout(tc.cacheSize))),
+			)
+			if err != nil && tc.wantExitCode == 0 {
+				t.Fatalf("error running build: %v, logs: %s", err, result.Output)
+			}
+			if result.ExitCode != tc.wantExitCode {
+				t.Errorf("build exit code mismatch, got: %d, want: %d", result.ExitCode, tc.wantExitCode)
+			}
+			for _, cmd := range tc.wantCommands {
+				if !result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to be executed, but it was not, build output: %s", cmd, result.Output)
+				}
+			}
+			for _, cmd := range tc.doNotWantCommands {
+				if result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q not to be executed, but it was, build output: %s", cmd, result.Output)
+				}
+			}
+		})
+	}
+}
+
+func TestBuildGoFlags(t *testing.T) {
+	testCases := []struct {
+		name              string
+		envs              []string
+		wantCommands      []string
+		doNotWantCommands []string
+	}{
+		{
+			name:              "not set",
+			envs:              []string{"GOOGLE_BUILDABLE=."},
+			doNotWantCommands: []string{"GOFLAGS="},
+		},
+		{
+			name:         "go build",
+			envs:         []string{"GOOGLE_BUILDABLE=.", "GOOGLE_GOFLAGS=-mod=mod -buildvcs=false"},
+			wantCommands: []string{`go build [^\n]*GOFLAGS=-mod=mod -buildvcs=false`},
+		},
+		{
+			name: "go list and go build",
+			envs: []string{"GOOGLE_GOFLAGS=-mod=mod"},
+			wantCommands: []string{
+				`go list [^\n]*GOFLAGS=-mod=mod`,
+				`go build [^\n]*GOFLAGS=-mod=mod`,
+			},
+		},
+		{
+			name:              "output flag ignored",
+			envs:              []string{"GOOGLE_BUILDABLE=.", "GOOGLE_GOFLAGS=-o=/tmp/main -buildvcs=false"},
+			wantCommands:      []string{`go build [^\n]*GOFLAGS=-buildvcs=false\)`},
+			doNotWantCommands: []string{"GOFLAGS=-o"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := buildpacktest.RunBuild(t, buildFn,
+				buildpacktest.WithTestName(tc.name),
+				buildpacktest.WithEnvs(tc.envs...),
+				buildpacktest.WithExecMocks(mockprocess.New(`^du -sm`, mockprocess.WithStdout("1\t/layers/gocache"))),
+			)
+			if err != nil {
+				t.Fatalf("error running build: %v, logs: %s", err, result.Output)
+			}
+			for _, cmd := range tc.wantCommands {
+				if !result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to be executed, but it was not, build output: %s", cmd, result.Output)
+				}
+			}
+			for _, cmd := range tc.doNotWantCommands {
+				if result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q not to be executed, but it was, build output: %s", cmd, result.Output)
+				}
+			}
+		})
+	}
+}
+
+func TestGoBuildMode(t *testing.T) {
+	oldEnv := os.Environ()
+	t.Cleanup(func() {
+		clearAndSetEnv(oldEnv)
+	})
+	testCases := []struct {
+		name    string
+		env     []string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "not set",
+		},
+		{
+			name: "c-shared",
+			env:  []string{"GOOGLE_GO_BUILDMODE=c-shared"},
+			want: "c-shared",
+		},
+		{
+			name: "c-shared for the host",
+			env:  []string{"GOOGLE_GO_BUILDMODE=c-shared", "GOOGLE_GOARCH=amd64"},
+			want: "c-shared",
+		},
+		{
+			name:    "c-shared cross-compiled",
+			env:     []string{"GOOGLE_GO_BUILDMODE=c-shared", "GOOGLE_GOARCH=arm64"},
+			wantErr: true,
+		},
+		{
+			name:    "plugin cross-compiled",
+			env:     []string{"GOOGLE_GO_BUILDMODE=plugin", "GOOGLE_GOOS=linux", "GOOGLE_GOARCH=arm64"},
+			wantErr: true,
+		},
+		{
+			name: "plugin cross-compiled with cgo enabled",
+			env:  []string{"GOOGLE_GO_BUILDMODE=plugin", "GOOGLE_GOARCH=arm64", "CGO_ENABLED=1"},
+			want: "plugin",
+		},
+		{
+			name: "exe cross-compiled",
+			env:  []string{"GOOGLE_GO_BUILDMODE=exe", "GOOGLE_GOARCH=arm64"},
+			want: "exe",
+		},
+		{
+			name:    "unsupported build mode",
+			env:     []string{"GOOGLE_GO_BUILDMODE=pie"},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			clearAndSetEnv(tc.env)
+			got, err := goBuildMode("linux/amd64")
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("goBuildMode() got error: %v, want error: %v", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("goBuildMode() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestBuildGoBuildMode(t *testing.T) {
+	testCases := []struct {
+		name              string
+		buildMode         string
+		wantExitCode      int // 0 if unspecified
+		wantCommands      []string
+		doNotWantCommands []string
+		wantOutput        string
+		doNotWantOutput   string
+	}{
+		{
+			name:              "build mode not set",
+			wantCommands:      []string{`go build -o \S*bin/main \.`},
+			doNotWantCommands: []string{"-buildmode"},
+			doNotWantOutput:   "No runnable entrypoint",
+		},
+		{
+			name:            "exe build mode",
+			buildMode:       "exe",
+			wantCommands:    []string{`go build -buildmode=exe -o \S*bin/main \.`},
+			doNotWantOutput: "No runnable entrypoint",
+		},
+		{
+			name:         "c-shared build mode",
+			buildMode:    "c-shared",
+			wantCommands: []string{`go build -buildmode=c-shared -o \S*bin/libmain.so \.`},
+			wantOutput:   "No runnable entrypoint was produced: GOOGLE_GO_BUILDMODE=c-shared",
+		},
+		{
+			name:         "plugin build mode",
+			buildMode:    "plugin",
+			wantCommands: []string{`go build -buildmode=plugin -o \S*bin/main.so \.`},
+			wantOutput:   "No runnable entrypoint was produced: GOOGLE_GO_BUILDMODE=plugin",
+		},
+		{
+			name:              "unsupported build mode",
+			buildMode:         "pie",
+			wantExitCode:      1,
+			doNotWantCommands: []string{"go build"},
+			wantOutput:        `unsupported build mode "pie"`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			envs := []string{"GOOGLE_BUILDABLE=."}
+			if tc.buildMode != "" {
+				envs = append(envs, "GOOGLE_GO_BUILDMODE="+tc.buildMode)
+			}
+			result, err := buildpacktest.RunBuild(t, buildFn,
+				buildpacktest.WithTestName(tc.name),
+				buildpacktest.WithEnvs(envs...),
+				buildpacktest.WithExecMocks(mockprocess.New(`^du -sm`, mockprocess.WithStdout("1\t/layers/gocache"))),
+			)
+			if err != nil && tc.wantExitCode == 0 {
+				t.Fatalf("error running build: %v, logs: %s", err, result.Output)
+			}
+			if result.ExitCode != tc.wantExitCode {
+				t.Errorf("build exit code mismatch, got: %d, want: %d", result.ExitCode, tc.wantExitCode)
+			}
+			for _, cmd := range tc.wantCommands {
+				if !result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to be executed, but it was not, build output: %s", cmd, result.Output)
+				}
+			}
+			for _, cmd := range tc.doNotWantCommands {
+				if result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q not to be executed, but it was, build output: %s", cmd, result.Output)
+				}
+			}
+			if tc.wantOutput != "" && !strings.Contains(result.Output, tc.wantOutput) {
+				t.Errorf("build output = %q, want output containing %q", result.Output, tc.wantOutput)
+			}
+			if tc.doNotWantOutput != "" && strings.Contains(result.Output, tc.doNotWantOutput) {
+				t.Errorf("build output = %q, want output not containing %q", result.Output, tc.doNotWantOutput)
+			}
+		})
+	}
+}
+
+func TestPrebuiltBinary(t *testing.T) {
+	testCases := []struct {
+		name    string
+		envs    map[string]string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "not prebuilt",
+			envs: map[string]string{"GOOGLE_PREBUILT_BINARY": "bin/server"},
+		},
+		{
+			name: "relative path",
+			envs: map[string]string{"GOOGLE_PREBUILT": "true", "GOOGLE_PREBUILT_BINARY": "bin/server"},
+			want: "bin/server",
+		},
+		{
+			name:    "binary not set",
+			envs:    map[string]string{"GOOGLE_PREBUILT": "true"},
+			wantErr: true,
+		},
+		{
+			name:    "binary missing",
+			envs:    map[string]string{"GOOGLE_PREBUILT": "true", "GOOGLE_PREBUILT_BINARY": "bin/missing"},
+			wantErr: true,
+		},
+		{
+			name:    "binary not executable",
+			envs:    map[string]string{"GOOGLE_PREBUILT": "true", "GOOGLE_PREBUILT_BINARY": "bin/data"},
+			wantErr: true,
+		},
+		{
+			name:    "binary is a directory",
+			envs:    map[string]string{"GOOGLE_PREBUILT": "true", "GOOGLE_PREBUILT_BINARY": "bin"},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			appRoot := t.TempDir()
+			if err := os.Mkdir(filepath.Join(appRoot, "bin"), 0755); err != nil {
+				t.Fatalf("creating dir: %v", err)
+			}
+			if err := os.WriteFile(filepath.Join(appRoot, "bin", "server"), []byte("binary"), 0755); err != nil {
+				t.Fatalf("writing file: %v", err)
+			}
+			if err := os.WriteFile(filepath.Join(appRoot, "bin", "data"), []byte("data"), 0644); err != nil {
+				t.Fatalf("writing file: %v", err)
+			}
+			for k, v := range tc.envs {
+				t.Setenv(k, v)
+			}
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(appRoot))
+
+			got, err := prebuiltBinary(ctx)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("prebuiltBinary() = %q, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("prebuiltBinary() got error: %v", err)
+			}
+			want := ""
+			if tc.want != "" {
+				want = filepath.Join(appRoot, tc.want)
+			}
+			if got != want {
+				t.Errorf("prebuiltBinary() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestBuildPrebuiltBinaryMissing(t *testing.T) {
+	result, err := buildpacktest.RunBuild(t, buildFn,
+		buildpacktest.WithTestName("prebuilt binary missing"),
+		buildpacktest.WithEnvs("GOOGLE_PREBUILT=true", "GOOGLE_PREBUILT_BINARY=bin/server"),
+	)
+	if err == nil {
+		t.Fatalf("build succeeded, want error, logs: %s", result.Output)
+	}
+	if result.ExitCode != 1 {
+		t.Errorf("build exit code mismatch, got: %d, want: 1", result.ExitCode)
+	}
+	if result.CommandExecuted("go build") {
+		t.Errorf("expected go build not to be executed, but it was, build output: %s", result.Output)
+	}
+}
+
 func clearAndSetEnv(env []string) {
 	os.Clearenv()
 	for _, p := range env {
@@ -19,29 +19,57 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
+	"go/ast"
 	"go/parser"
 	"go/token"
+	"io/ioutil"
 	"log"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 )
 
+const functionsPackage = "github.com/GoogleCloudPlatform/functions-framework-go/functions"
+
 var (
 	dir = flag.String("dir", "", "Directory containing *.go files from which to extract a package name.")
 )
 
+// registrationKinds maps the declarative registration functions of the functions package to
+// the signature kind of the function they register.
+var registrationKinds = map[string]string{
+	"HTTP":       "http",
+	"CloudEvent": "cloudevent",
+	"Typed":      "typed",
+}
+
 // parsedPackage represents a parsed package.
 type parsedPackage struct {
 	Name    string              `json:"name"`
 	Imports map[string]struct{} `json:"imports"`
+	// Functions lists the functions registered with the declarative functions API.
+	Functions []registeredFunction `json:"functions,omitempty"`
+	// ComputedNames is true if a function is registered with a name that is not a string literal.
+	ComputedNames bool `json:"computedNames,omitempty"`
+	// GenericFunctions lists the exported functions that have type parameters.
+	GenericFunctions []string `json:"genericFunctions,omitempty"`
+}
+
+// registeredFunction represents a function registered with the declarative functions API.
+type registeredFunction struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
 }
 
-// extract extracts the name of the package in the specified directory.
-// Expects that the specified directory contains one and only one Go package.
+// extract extracts the name of the package in the specified directory, the functions it registers
+// and its generic functions. Expects that the specified directory contains one and only one Go
+// package.
 func extract(source string) (*parsedPackage, error) {
 	fset := token.NewFileSet() // positions are relative to fset
 
-	// Parse all .go files in dir but stop after processing the package.
-	pkgs, err := parser.ParseDir(fset, source, nil, parser.ImportsOnly)
+	// Parse all .go files in dir fully: the registrations are calls in function bodies.
+	pkgs, err := parser.ParseDir(fset, source, nil, 0)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse source in %s: %v", source, err)
 	}
@@ -63,17 +91,111 @@ func extract(source string) (*parsedPackage, error) {
 		return nil, fmt.Errorf("unable to find Go package in %s", source)
 	}
 
-	packageImports := map[string]struct{}{}
-	for _, fi := range pkgs[packageName].Files {
+	pkg := &parsedPackage{
+		Name:    packageName,
+		Imports: map[string]struct{}{},
+	}
+	for path, fi := range pkgs[packageName].Files {
 		for _, im := range fi.Imports {
-			packageImports[strings.Trim(im.Path.Value, `"`)] = struct{}{}
+			pkg.Imports[strings.Trim(im.Path.Value, `"`)] = struct{}{}
+		}
+		if strings.HasSuffix(filepath.Base(path), "_test.go") {
+			continue
+		}
+		fns, computed := registrations(fi)
+		pkg.Functions = append(pkg.Functions, fns...)
+		pkg.ComputedNames = pkg.ComputedNames || computed
+		generic, err := genericFunctions(fset, fi, path)
+		if err != nil {
+			return nil, err
 		}
+		pkg.GenericFunctions = append(pkg.GenericFunctions, generic...)
 	}
+	sort.Slice(pkg.Functions, func(i, j int) bool {
+		return pkg.Functions[i].Name < pkg.Functions[j].Name
+	})
+	sort.Strings(pkg.GenericFunctions)
 
-	return &parsedPackage{
-		Name:    packageName,
-		Imports: packageImports,
-	}, nil
+	return pkg, nil
+}
+
+// registrations returns the functions registered in the file with the declarative functions API,
+// e.g. `functions.HTTP("Name", fn)`, and whether any of them is registered with a name that is
+// not a string literal.
+func registrations(fi *ast.File) ([]registeredFunction, bool) {
+	alias := ""
+	for _, im := range fi.Imports {
+		if strings.Trim(im.Path.Value, `"`) != functionsPackage {
+			continue
+		}
+		alias = "functions"
+		if im.Name != nil {
+			alias = im.Name.Name
+		}
+	}
+	// A blank or dot import cannot be referenced with a selector.
+	if alias == "" || alias == "_" || alias == "." {
+		return nil, false
+	}
+
+	var fns []registeredFunction
+	computed := false
+	ast.Inspect(fi, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || len(call.Args) == 0 {
+			return true
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		if id, ok := sel.X.(*ast.Ident); !ok || id.Name != alias {
+			return true
+		}
+		kind, ok := registrationKinds[sel.Sel.Name]
+		if !ok {
+			return true
+		}
+		lit, ok := call.Args[0].(*ast.BasicLit)
+		if !ok || lit.Kind != token.STRING {
+			computed = true
+			return true
+		}
+		name, err := strconv.Unquote(lit.Value)
+		if err != nil {
+			computed = true
+			return true
+		}
+		fns = append(fns, registeredFunction{Name: name, Kind: kind})
+		return true
+	})
+	return fns, computed
+}
+
+// genericFunctions returns the exported functions of the file that have type parameters, e.g.
+// `func Handle[T any](w http.ResponseWriter, r *http.Request)`. The type parameters are found in
+// the source between the name and the parameters of the function, rather than in the AST, so that
+// the script builds with versions of Go that predate generics.
+func genericFunctions(fset *token.FileSet, fi *ast.File, path string) ([]string, error) {
+	var src []byte
+	var fns []string
+	for _, decl := range fi.Decls {
+		fd, ok := decl.(*ast.FuncDecl)
+		if !ok || fd.Recv != nil || !fd.Name.IsExported() {
+			continue
+		}
+		if src == nil {
+			var err error
+			if src, err = ioutil.ReadFile(path); err != nil {
+				return nil, fmt.Errorf("reading %s: %v", path, err)
+			}
+		}
+		start, end := fset.Position(fd.Name.End()).Offset, fset.Position(fd.Type.Params.Opening).Offset
+		if start < end && end <= len(src) && strings.Contains(string(src[start:end]), "[") {
+			fns = append(fns, fd.Name.Name)
+		}
+	}
+	return fns, nil
 }
 
 func main() {
@@ -68,6 +68,7 @@ require (
 					"github.com/cloudevents/sdk-go/v2":                                struct{}{},
 					"log":                                                             struct{}{},
 				},
+				Functions: []registeredFunction{{Name: "HelloStorage", Kind: "cloudevent"}},
 			},
 		}, {
 			name: "one package with two files",
@@ -103,6 +104,119 @@ func init() {
 					"github.com/cloudevents/sdk-go/v2":                                struct{}{},
 					"log":                                                             struct{}{},
 				},
+				Functions: []registeredFunction{{Name: "HelloStorage", Kind: "cloudevent"}},
+			},
+		}, {
+			name: "multiple declarative functions with aliased import",
+			files: map[string]string{
+				"fn.go": `package myfunc
+
+import (
+	"net/http"
+
+	ff "github.com/GoogleCloudPlatform/functions-framework-go/functions"
+)
+
+func init() {
+	ff.HTTP("Hello", hello)
+	ff.Typed("Typed", typed)
+}
+
+func hello(w http.ResponseWriter, r *http.Request) {}
+
+func typed(in string) (string, error) { return in, nil }`,
+				"fn_test.go": `package myfunc
+
+import "github.com/GoogleCloudPlatform/functions-framework-go/functions"
+
+func init() {
+	functions.HTTP("TestOnly", hello)
+}`,
+			},
+			want: &parsedPackage{
+				Name: "myfunc",
+				Imports: map[string]struct{}{
+					"net/http": struct{}{},
+					"github.com/GoogleCloudPlatform/functions-framework-go/functions": struct{}{},
+				},
+				Functions: []registeredFunction{
+					{Name: "Hello", Kind: "http"},
+					{Name: "Typed", Kind: "typed"},
+				},
+			},
+		}, {
+			name: "computed function name",
+			files: map[string]string{
+				"fn.go": `package myfunc
+
+import (
+	"net/http"
+
+	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
+)
+
+const prefix = "Hello"
+
+func init() {
+	functions.HTTP(prefix+"World", hello)
+}
+
+func hello(w http.ResponseWriter, r *http.Request) {}`,
+			},
+			want: &parsedPackage{
+				Name: "myfunc",
+				Imports: map[string]struct{}{
+					"net/http": struct{}{},
+					"github.com/GoogleCloudPlatform/functions-framework-go/functions": struct{}{},
+				},
+				ComputedNames: true,
+			},
+		}, {
+			name: "generic functions",
+			files: map[string]string{
+				"fn.go": `package myfunc
+
+import "net/http"
+
+type Box[T any] struct{ v T }
+
+func Handle[T any](w http.ResponseWriter, r *http.Request) {}
+
+func HandleConstrained[T interface{ ~string }, U any](w http.ResponseWriter, r *http.Request) {}
+
+func Concrete(w http.ResponseWriter, r *http.Request) { _ = Box[int]{} }
+
+func WithGenericParam(w http.ResponseWriter, b Box[string]) {}
+
+func unexported[T any]() {}
+
+func (b Box[T]) Method(w http.ResponseWriter, r *http.Request) {}`,
+			},
+			want: &parsedPackage{
+				Name: "myfunc",
+				Imports: map[string]struct{}{
+					"net/http": struct{}{},
+				},
+				GenericFunctions: []string{"Handle", "HandleConstrained"},
+			},
+		}, {
+			name: "non-declarative function",
+			files: map[string]string{
+				"fn.go": `package myfunc
+
+import "net/http"
+
+func HTTP(name string, fn http.HandlerFunc) {}
+
+func init() {
+	HTTP("NotRegistered", nil)
+}`,
+			},
+			want: &parsedPackage{
+				Name: "myfunc",
+				Imports: map[string]struct{}{
+					"net/http": struct{}{},
+				},
 			},
 		},
 	}
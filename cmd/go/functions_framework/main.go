@@ -18,19 +18,30 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
 	"io/fs"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"text/template"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/cloudfunctions"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/fileutil"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/golang"
 	"github.com/Masterminds/semver"
+	"github.com/buildpacks/libcnb"
 )
 
 const (
@@ -41,14 +52,28 @@ const (
 	functionsFrameworkFunctionsPackage = functionsFrameworkModule + "/functions"
 	functionsFrameworkVersion          = "v1.8.1"
 	appModule                          = "functions.local/app"
-	fnSourceDir                        = "serverless_function_source_code"
+	defaultFnSourceDir                 = "serverless_function_source_code"
+	goSumCacheKey                      = "go-sum-sha"
+	// concurrencyLabel is the image label that records the maximum number of concurrent requests
+	// the function supports. AddLabel does not allow dots in keys, so the label is
+	// google.cloud-run-container-concurrency.
+	concurrencyLabel = "cloud-run-container-concurrency"
 )
 
 var (
-	googleDirs      = []string{fnSourceDir, ".googlebuild", ".googleconfig"}
 	tmplV0          = template.Must(template.New("mainV0").Parse(mainTextTemplateV0))
 	tmplV1_1        = template.Must(template.New("mainV1_1").Parse(mainTextTemplateV1_1))
 	tmplDeclarative = template.Must(template.New("main_declarative").Parse(mainTextTemplateDeclarative))
+	// declarativeRegistrationRegexp matches declarative function registrations with a string literal
+	// name, e.g. `functions.HTTP("HelloWorld", helloWorld)`.
+	declarativeRegistrationRegexp = regexp.MustCompile(`\bfunctions\.(?:HTTP|CloudEvent|Typed)\(\s*"([^"]+)"`)
+	// goproxySeparatorRegexp matches the separators of a GOPROXY list.
+	goproxySeparatorRegexp = regexp.MustCompile(`[,|]`)
+	// embedDirectiveRegexp matches //go:embed directives and captures their patterns.
+	embedDirectiveRegexp = regexp.MustCompile(`(?m)^\s*//go:embed\s+(.+)$`)
+	// concurrencySafeSyncTypes are the types of the sync package that are safe to declare as
+	// package-level variables of a function that handles concurrent requests.
+	concurrencySafeSyncTypes = map[string]bool{"Mutex": true, "RWMutex": true, "Once": true}
 )
 
 type fnInfo struct {
@@ -56,11 +81,29 @@ type fnInfo struct {
 	Target  string
 	Package string
 	Imports map[string]struct{}
+	// Subdir is the subdirectory of the source tree containing the function, if any.
+	Subdir string
+	// GoFlags holds the GOFLAGS environment entry for go commands run on the function source, if any.
+	GoFlags []string
+	// Goproxy is the GOPROXY set with GOOGLE_FUNCTION_GOPROXY, if any.
+	Goproxy string
+	// GoWork is the go.work file of the Go workspace that contains the function module, if any.
+	GoWork string
 }
 
 type parsedPackage struct {
-	Name    string              `json:"name"`
-	Imports map[string]struct{} `json:"imports"`
+	Name          string               `json:"name"`
+	Imports       map[string]struct{}  `json:"imports"`
+	Functions     []registeredFunction `json:"functions"`
+	ComputedNames bool                 `json:"computedNames"`
+	// GenericFunctions lists the exported functions that have type parameters.
+	GenericFunctions []string `json:"genericFunctions"`
+}
+
+// registeredFunction is a function registered with the declarative functions API.
+type registeredFunction struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
 }
 
 func main() {
@@ -74,23 +117,73 @@ func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
 	if _, ok := os.LookupEnv(env.FunctionTarget); ok {
 		return gcp.OptInEnvSet(env.FunctionTarget), nil
 	}
+	names, err := declarativeFunctionNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 1 {
+		return gcp.OptIn(fmt.Sprintf("%s not set, found declarative function %q", env.FunctionTarget, names[0])), nil
+	}
 	return gcp.OptOutEnvNotSet(env.FunctionTarget), nil
 }
 
+// declarativeFunctionNames returns the distinct names of the functions registered with the
+// declarative functions API in the Go files at the application root. The Go toolchain is not
+// available during detect, so this is a textual scan; the build step validates the result by
+// parsing the source.
+func declarativeFunctionNames(ctx *gcp.Context) ([]string, error) {
+	files, err := ctx.Glob(filepath.Join(ctx.ApplicationRoot(), "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	var names []string
+	for _, f := range files {
+		if strings.HasSuffix(f, "_test.go") {
+			continue
+		}
+		content, err := ctx.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.Contains(string(content), functionsFrameworkFunctionsPackage) {
+			continue
+		}
+		for _, m := range declarativeRegistrationRegexp.FindAllStringSubmatch(string(content), -1) {
+			if !seen[m[1]] {
+				seen[m[1]] = true
+				names = append(names, m[1])
+			}
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
 func buildFn(ctx *gcp.Context) error {
-	l, err := ctx.Layer(layerName, gcp.LaunchLayer)
+	goproxy, err := functionGoproxy(ctx)
 	if err != nil {
-		return fmt.Errorf("creating %v layer: %w", layerName, err)
+		return err
 	}
-	if err := ctx.SetFunctionsEnvVars(l); err != nil {
+	concurrencySafe, err := functionConcurrencySafe(ctx)
+	if err != nil {
 		return err
 	}
+
+	l, err := ctx.Layer(layerName, gcp.LaunchLayer)
+	if err != nil {
+		return fmt.Errorf("creating %v layer: %w", layerName, err)
+	}
 	ctx.AddWebProcess([]string{golang.OutBin})
 
-	fnTarget := os.Getenv(env.FunctionTarget)
+	subdir, err := cloudfunctions.SourceSubdir(ctx)
+	if err != nil {
+		return err
+	}
 
 	// Move the function source code into a subdirectory.
-	if err := ctx.RemoveAll(fnSourceDir); err != nil {
+	fnSourceDir, err := functionSourceDir(ctx)
+	if err != nil {
 		return err
 	}
 	if err := ctx.MkdirAll(fnSourceDir, 0755); err != nil {
@@ -102,22 +195,43 @@ func buildFn(ctx *gcp.Context) error {
 	}
 	if err := fileutil.MaybeMovePathContents(fnSourceDir, wd, func(path string, d fs.DirEntry) (bool, error) {
 		name := filepath.Base(path)
-		// Exclude serverless_function_source_code and .google* dir e.g. .googlebuild, .googleconfig
+		// Exclude the function source directory and .google* dir e.g. .googlebuild, .googleconfig
 		return name != fnSourceDir && !strings.HasPrefix(name, ".google"), nil
 	}); err != nil {
 		return gcp.InternalErrorf("unable to move source code to build directory: %v", err)
 	}
 
-	fnSource := filepath.Join(ctx.ApplicationRoot(), fnSourceDir)
+	warnBrokenEmbedPatterns(ctx, fnSourceDir)
+
+	fnSource := filepath.Join(ctx.ApplicationRoot(), fnSourceDir, subdir)
+	if subdir != "" {
+		ctx.Logf("Building function from %s=%q", env.FunctionSourceSubdir, subdir)
+	}
+	if concurrencySafe {
+		warnUnsafeGlobalVars(ctx, fnSource)
+	}
 	pkg, err := extractPackageNameInDir(ctx, fnSource)
 	if err != nil {
 		return gcp.UserErrorf("error extracting package name: %v", err)
 	}
+	fnTarget, err := resolveFunctionTarget(ctx, os.Getenv(env.FunctionTarget), pkg)
+	if err != nil {
+		return err
+	}
+	if err := checkStrictTyping(fnTarget, pkg); err != nil {
+		return err
+	}
+	if err := ctx.SetFunctionsEnvVars(l); err != nil {
+		return err
+	}
 	fn := fnInfo{
 		Source:  fnSource,
 		Target:  fnTarget,
 		Package: pkg.Name,
 		Imports: pkg.Imports,
+		Subdir:  subdir,
+		GoFlags: golang.GoFlags(ctx),
+		Goproxy: goproxy,
 	}
 
 	goMod := filepath.Join(fn.Source, "go.mod")
@@ -137,6 +251,16 @@ func buildFn(ctx *gcp.Context) error {
 		//     go: updates to go.sum needed, disabled by -mod=readonly
 		return gcp.UserErrorf("go.mod exists but is not writable")
 	}
+	fn.GoWork, err = golang.FindGoWork(ctx, fn.Source, filepath.Join(ctx.ApplicationRoot(), fnSourceDir))
+	if err != nil {
+		return err
+	}
+	if fn.GoWork != "" {
+		if err := useGoWork(ctx, fn); err != nil {
+			return err
+		}
+		return createMainGoMod(ctx, fn)
+	}
 	vendorExists, err := ctx.FileExists(fn.Source, "vendor")
 	if err != nil {
 		return err
@@ -148,13 +272,272 @@ func buildFn(ctx *gcp.Context) error {
 	return createMainGoMod(ctx, fn)
 }
 
-func createMainGoMod(ctx *gcp.Context, fn fnInfo) error {
-	l, err := ctx.Layer(gopathLayerName, gcp.BuildLayer)
+// useGoWork configures the go commands to build the function in the Go workspace defined by
+// fn.GoWork, so that the function module resolves the other modules of the workspace. The function
+// module is added to the workspace if needed, which also covers the generated main package since it
+// is created in the function module.
+func useGoWork(ctx *gcp.Context, fn fnInfo) error {
+	ctx.Logf("Building the function in the Go workspace defined by %s.", fn.GoWork)
+	if err := golang.CheckGoWorkNotVendored(ctx, fn.GoWork); err != nil {
+		return err
+	}
+	if err := ctx.Setenv("GOWORK", fn.GoWork); err != nil {
+		return err
+	}
+	if _, err := ctx.Exec([]string{"go", "work", "use", fn.Source}, gcp.WithWorkDir(filepath.Dir(fn.GoWork)), gcp.WithUserAttribution); err != nil {
+		return err
+	}
+	return nil
+}
+
+// tidyFunctionModule records the checksums of the dependencies of the function module. `go mod tidy`
+// ignores Go workspaces and fails to resolve their local modules, so the dependencies of a workspace
+// are downloaded instead, which records their checksums in go.work.sum.
+func tidyFunctionModule(ctx *gcp.Context, fn fnInfo) error {
+	cmd := []string{"go", "mod", "tidy"}
+	if fn.GoWork != "" {
+		cmd = []string{"go", "mod", "download"}
+	}
+	if _, err := execWithGoproxy(ctx, fn, cmd, gcp.WithEnv(fn.GoFlags...), gcp.WithWorkDir(fn.Source), gcp.WithUserAttribution); err != nil {
+		return fmt.Errorf("running %s: %w", strings.Join(cmd, " "), err)
+	}
+	return nil
+}
+
+// functionSourceDir returns the name of the directory of the application root that the function
+// source is moved into, GOOGLE_FUNCTION_STAGING_DIR or serverless_function_source_code by default.
+// It returns a user error if the directory already exists in the source, since moving the source
+// into it would mix the user's files with the function source.
+func functionSourceDir(ctx *gcp.Context) (string, error) {
+	name := defaultFnSourceDir
+	if dir := os.Getenv(env.FunctionStagingDir); dir != "" {
+		if dir == "." || dir == ".." || strings.ContainsAny(dir, `/\`) || strings.HasPrefix(dir, ".google") {
+			return "", gcp.UserErrorf("invalid %s %q: it must be the name of a directory, not a path", env.FunctionStagingDir, dir)
+		}
+		name = dir
+	}
+	exists, err := ctx.FileExists(ctx.ApplicationRoot(), name)
 	if err != nil {
-		return fmt.Errorf("creating %v layer: %w", gopathLayerName, err)
+		return "", err
 	}
-	l.BuildEnvironment.Override("GOPATH", l.Path)
-	if err := ctx.Setenv("GOPATH", l.Path); err != nil {
+	if exists {
+		return "", gcp.UserErrorf("the application source contains %q, which is the directory the function source is moved into while building. Rename it or set %s to the name of a directory that does not exist in the source", name, env.FunctionStagingDir)
+	}
+	return name, nil
+}
+
+// warnBrokenEmbedPatterns warns about //go:embed patterns that may no longer resolve after the
+// function source was moved to fnSourceDir.
+func warnBrokenEmbedPatterns(ctx *gcp.Context, fnSourceDir string) {
+	broken, err := brokenEmbedPatterns(os.DirFS(filepath.Join(ctx.ApplicationRoot(), fnSourceDir)))
+	if err != nil {
+		ctx.Warnf("Failed to check //go:embed directives: %v", err)
+		return
+	}
+	if len(broken) > 0 {
+		ctx.Warnf("The following //go:embed patterns may be broken because the function source was moved to %s, embedded files must be in the directory of the Go file or one of its subdirectories: %s", fnSourceDir, strings.Join(broken, ", "))
+	}
+}
+
+// brokenEmbedPatterns scans the .go files in fsys for //go:embed directives and returns the
+// patterns, prefixed by the file that declares them, that point outside of fsys or do not match any
+// file in it. Files in vendor directories are skipped.
+func brokenEmbedPatterns(fsys fs.FS) ([]string, error) {
+	var broken []string
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == "vendor" {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if path.Ext(p) != ".go" {
+			return nil
+		}
+		content, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		for _, m := range embedDirectiveRegexp.FindAllStringSubmatch(string(content), -1) {
+			for _, pattern := range strings.Fields(m[1]) {
+				pattern = strings.Trim(pattern, "\"`")
+				target := path.Join(path.Dir(p), strings.TrimPrefix(pattern, "all:"))
+				if !fs.ValidPath(target) {
+					broken = append(broken, fmt.Sprintf("%s: %s", p, pattern))
+					continue
+				}
+				matches, err := fs.Glob(fsys, target)
+				if err != nil || len(matches) == 0 {
+					broken = append(broken, fmt.Sprintf("%s: %s", p, pattern))
+				}
+			}
+		}
+		return nil
+	})
+	return broken, err
+}
+
+// functionConcurrencySafe returns false if GOOGLE_FUNCTION_CONCURRENCY_SAFE=false, in which case
+// it warns to deploy the function with a concurrency of 1 and records it in an image label.
+func functionConcurrencySafe(ctx *gcp.Context) (bool, error) {
+	v, ok := os.LookupEnv(env.FunctionConcurrencySafe)
+	if !ok {
+		return true, nil
+	}
+	safe, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, gcp.UserErrorf("invalid value %q for %s, must be true or false", v, env.FunctionConcurrencySafe)
+	}
+	if !safe {
+		ctx.Warnf("%s=false: the function does not support concurrent requests, deploy it with `--concurrency 1` so that each instance handles one request at a time. This increases the number of instances needed to serve the same traffic.", env.FunctionConcurrencySafe)
+		ctx.AddLabel(concurrencyLabel, "1")
+	}
+	return safe, nil
+}
+
+// warnUnsafeGlobalVars warns about package-level variables of the function that may be accessed
+// by concurrent requests.
+func warnUnsafeGlobalVars(ctx *gcp.Context, fnSource string) {
+	vars, err := unsafeGlobalVars(os.DirFS(fnSource))
+	if err != nil {
+		ctx.Warnf("Failed to check package-level variables: %v", err)
+		return
+	}
+	if len(vars) > 0 {
+		ctx.Warnf("The following package-level variables may be accessed by concurrent requests, guard them with a sync.Mutex or set %s=false if the function is not safe for concurrent use: %s", env.FunctionConcurrencySafe, strings.Join(vars, ", "))
+	}
+}
+
+// unsafeGlobalVars parses the non-test .go files at the root of fsys and returns the package-level
+// variables, prefixed by the file that declares them, that are not a sync.Mutex, sync.RWMutex,
+// sync.Once or channel. This is a heuristic, the variables may be read-only or guarded elsewhere.
+func unsafeGlobalVars(fsys fs.FS) ([]string, error) {
+	files, err := fs.Glob(fsys, "*.go")
+	if err != nil {
+		return nil, err
+	}
+	var vars []string
+	fset := token.NewFileSet()
+	for _, f := range files {
+		if strings.HasSuffix(f, "_test.go") {
+			continue
+		}
+		content, err := fs.ReadFile(fsys, f)
+		if err != nil {
+			return nil, err
+		}
+		file, err := parser.ParseFile(fset, f, content, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, err
+		}
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.VAR {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				vs := spec.(*ast.ValueSpec)
+				for i, name := range vs.Names {
+					if name.Name == "_" {
+						continue
+					}
+					typ := vs.Type
+					if typ == nil && i < len(vs.Values) {
+						typ = valueType(vs.Values[i])
+					}
+					if !concurrencySafeType(typ) {
+						vars = append(vars, fmt.Sprintf("%s: %s", f, name.Name))
+					}
+				}
+			}
+		}
+	}
+	return vars, nil
+}
+
+// valueType returns the type of composite literals, pointers to composite literals and channels
+// created with make, or nil for other values.
+func valueType(value ast.Expr) ast.Expr {
+	switch v := value.(type) {
+	case *ast.CompositeLit:
+		return v.Type
+	case *ast.UnaryExpr:
+		if v.Op == token.AND {
+			return valueType(v.X)
+		}
+	case *ast.CallExpr:
+		if fun, ok := v.Fun.(*ast.Ident); ok && fun.Name == "make" && len(v.Args) > 0 {
+			return v.Args[0]
+		}
+	}
+	return nil
+}
+
+// concurrencySafeType reports whether typ is a channel or one of concurrencySafeSyncTypes, or a
+// pointer to one.
+func concurrencySafeType(typ ast.Expr) bool {
+	switch t := typ.(type) {
+	case *ast.ChanType:
+		return true
+	case *ast.StarExpr:
+		return concurrencySafeType(t.X)
+	case *ast.SelectorExpr:
+		pkg, ok := t.X.(*ast.Ident)
+		return ok && pkg.Name == "sync" && concurrencySafeSyncTypes[t.Sel.Name]
+	}
+	return false
+}
+
+// resolveFunctionTarget validates the function target against the functions registered with the
+// declarative functions API. If the target is unset and exactly one function is registered, the
+// target defaults to that function.
+func resolveFunctionTarget(ctx *gcp.Context, target string, pkg *parsedPackage) (string, error) {
+	if _, ok := pkg.Imports[functionsFrameworkFunctionsPackage]; !ok {
+		return target, nil
+	}
+	var names []string
+	for _, f := range pkg.Functions {
+		names = append(names, fmt.Sprintf("%s (%s)", f.Name, f.Kind))
+	}
+	found := strings.Join(names, ", ")
+	if found == "" {
+		found = "none"
+	}
+
+	if target == "" {
+		if len(pkg.Functions) != 1 || pkg.ComputedNames {
+			return "", gcp.UserErrorf("%s is not set and the function to run could not be inferred, set it to one of the registered functions: %s", env.FunctionTarget, found)
+		}
+		target = pkg.Functions[0].Name
+		ctx.Logf("%s is not set, defaulting to the only registered function %q", env.FunctionTarget, target)
+		if err := ctx.Setenv(env.FunctionTarget, target); err != nil {
+			return "", err
+		}
+		return target, nil
+	}
+
+	// Functions may be registered by other packages, nothing to validate against.
+	if len(pkg.Functions) == 0 && !pkg.ComputedNames {
+		return target, nil
+	}
+	for _, f := range pkg.Functions {
+		if f.Name == target {
+			return target, nil
+		}
+	}
+	if pkg.ComputedNames {
+		ctx.Warnf("Unable to verify that function %q is registered: some functions are registered with names that are not string literals. Registered functions found: %s", target, found)
+		return target, nil
+	}
+	return "", gcp.UserErrorf("function %q is not registered with the functions framework, set %s to one of the registered functions: %s", target, env.FunctionTarget, found)
+}
+
+func createMainGoMod(ctx *gcp.Context, fn fnInfo) error {
+	l, err := gomodGopathLayer(ctx, fn)
+	if err != nil {
 		return err
 	}
 
@@ -162,22 +545,31 @@ func createMainGoMod(ctx *gcp.Context, fn fnInfo) error {
 	if err != nil {
 		return err
 	}
-	// If the function source does not include a go.sum, `go list` will fail under Go 1.16+.
 	if !goSumExists {
 		ctx.Logf(`go.sum not found, generating using "go mod tidy"`)
-		if _, err := golang.ExecWithGoproxyFallback(ctx, []string{"go", "mod", "tidy"}, gcp.WithWorkDir(fn.Source), gcp.WithUserAttribution); err != nil {
-			return fmt.Errorf("running go mod tiny: %w", err)
+		// `go list -mod=mod` is not allowed in a Go workspace, whose dependencies are downloaded first
+		// instead.
+		if fn.GoWork != "" {
+			if err := tidyFunctionModule(ctx, fn); err != nil {
+				return err
+			}
+			goSumExists = true
 		}
 	}
 
-	_, fnPackage, err := moduleAndPackageNames(ctx, fn)
+	_, fnPackage, err := moduleAndPackageNames(ctx, fn, true)
 	if err != nil {
 		return fmt.Errorf("extracting module and package names: %w", err)
 	}
 	fn.Package = fnPackage
 
 	// If the framework is not present in the function's go.mod, we require the current version.
-	version, err := frameworkSpecifiedVersion(ctx, fn.Source)
+	var version string
+	if goSumExists {
+		version, err = frameworkSpecifiedVersion(ctx, fn.Source)
+	} else {
+		version, err = frameworkVersionWithoutGoSum(ctx, fn)
+	}
 	if err != nil {
 		return fmt.Errorf("checking for functions framework dependency in go.mod: %w", err)
 	}
@@ -211,18 +603,65 @@ func createMainGoMod(ctx *gcp.Context, fn fnInfo) error {
 	// Generate a go.sum entry which is required starting with Go 1.16.
 	// We generate a go.mod file dynamically since the function may request a specific version of
 	// the framework, in which case we want to import that version. For that reason we cannot
-	// include a pre-generated go.sum file.
-	if _, err := golang.ExecWithGoproxyFallback(ctx, []string{"go", "mod", "tidy"}, gcp.WithUserAttribution, gcp.WithWorkDir(fn.Source)); err != nil {
-		return fmt.Errorf("running go mod tidy: %w", err)
+	// include a pre-generated go.sum file. This is the only `go mod tidy` of the build: it also
+	// generates the go.sum of functions that do not include one.
+	if err := tidyFunctionModule(ctx, fn); err != nil {
+		return err
 	}
 
 	// Make function's source the work directory when running go build
 	l.BuildEnvironment.Override(golang.BuildDirEnv, fn.Source)
+	if fn.GoWork != "" {
+		l.BuildEnvironment.Override("GOWORK", fn.GoWork)
+	}
 	// Specify what to build in the go build buildpack
 	l.BuildEnvironment.Override(env.Buildable, mainPackageDirectory)
 	return nil
 }
 
+// gomodGopathLayer creates the GOPATH layer for go.mod builds. The module cache, GOMODCACHE, is
+// in the layer and kept across builds, keyed on the function's go.mod and go.sum, so that
+// repeated builds of the function, or of another function from the same source tree, do not
+// download its dependencies again.
+func gomodGopathLayer(ctx *gcp.Context, fn fnInfo) (*libcnb.Layer, error) {
+	l, err := ctx.Layer(gopathLayerName, gcp.BuildLayer, gcp.CacheLayer)
+	if err != nil {
+		return nil, fmt.Errorf("creating %v layer: %w", gopathLayerName, err)
+	}
+	l.BuildEnvironment.Override("GOPATH", l.Path)
+	if err := ctx.Setenv("GOPATH", l.Path); err != nil {
+		return nil, err
+	}
+	hash, cached, err := modCacheHash(ctx, l, fn)
+	if err != nil {
+		return nil, err
+	}
+	if cached {
+		return l, nil
+	}
+	// The module cache is written without write access, so it must be cleared with the go tool.
+	if _, err := ctx.Exec([]string{"go", "clean", "-modcache"}, gcp.WithUserTimingAttribution); err != nil {
+		return nil, err
+	}
+	if hash == "" {
+		// Without a go.sum there is nothing to key the cache on.
+		l.Cache = false
+		return l, nil
+	}
+	cache.Add(ctx, l, goSumCacheKey, hash)
+	return l, nil
+}
+
+// modCacheHash returns the hash of the function's go.mod and go.sum and whether the module cache
+// of the layer was downloaded for the same files. The hash is empty if the function has no go.sum.
+func modCacheHash(ctx *gcp.Context, l *libcnb.Layer, fn fnInfo) (string, bool, error) {
+	hash, cached, err := cache.HashAndCheck(ctx, l, goSumCacheKey, cache.WithFiles(filepath.Join(fn.Source, "go.mod"), filepath.Join(fn.Source, "go.sum")))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", false, err
+	}
+	return hash, cached, nil
+}
+
 func createMainGoModVendored(ctx *gcp.Context, fn fnInfo) error {
 	l, err := ctx.Layer(gopathLayerName, gcp.BuildLayer)
 	if err != nil {
@@ -233,7 +672,7 @@ func createMainGoModVendored(ctx *gcp.Context, fn fnInfo) error {
 		return err
 	}
 
-	_, fnPackage, err := moduleAndPackageNames(ctx, fn)
+	_, fnPackage, err := moduleAndPackageNames(ctx, fn, false)
 	if err != nil {
 		return fmt.Errorf("extracting module and package names: %w", err)
 	}
@@ -274,16 +713,31 @@ func createMainGoModVendored(ctx *gcp.Context, fn fnInfo) error {
 	return createMainGoFile(ctx, fn, filepath.Join(appVendorDir, "main.go"), version)
 }
 
-// moduleAndPackageNames extracts the module name and package name of the function.
-func moduleAndPackageNames(ctx *gcp.Context, fn fnInfo) (string, string, error) {
-	result, err := ctx.Exec([]string{"go", "list", "-m"}, gcp.WithWorkDir(fn.Source), gcp.WithUserAttribution)
+// moduleAndPackageNames extracts the module name and package name of the function. If the module
+// path has no dot in its first path element and canAlias is true, the function's go.mod is edited
+// to also provide the module under a dotted alias, which is returned instead.
+func moduleAndPackageNames(ctx *gcp.Context, fn fnInfo, canAlias bool) (string, string, error) {
+	// In a Go workspace `go list -m` lists all the modules of the workspace, only the function module
+	// is wanted.
+	result, err := ctx.Exec([]string{"go", "list", "-m"}, gcp.WithEnv(fn.GoFlags...), gcp.WithEnv("GOWORK=off"), gcp.WithWorkDir(fn.Source), gcp.WithUserAttribution)
 	if err != nil {
 		return "", "", err
 	}
 	fnMod := result.Stdout
 	// golang.org/ref/mod requires that package names in a replace contains at least one dot.
 	if parts := strings.Split(fnMod, "/"); len(parts) > 0 && !strings.Contains(parts[0], ".") {
-		return "", "", gcp.UserErrorf("the module path in the function's go.mod must contain a dot in the first path element before a slash, e.g. example.com/module, found: %s", fnMod)
+		if !canAlias {
+			// Vendored builds must match vendor/modules.txt, so the go.mod cannot gain a replace.
+			return "", "", gcp.UserErrorf("the module path in the function's go.mod must contain a dot in the first path element before a slash, e.g. example.com/module, found: %s", fnMod)
+		}
+		alias, err := golang.ModulePathAlias(ctx, fnMod)
+		if err != nil {
+			return "", "", err
+		}
+		if _, err := ctx.Exec([]string{"go", "mod", "edit", "-require", alias + "@v0.0.0", "-replace", alias + "=./"}, gcp.WithWorkDir(fn.Source), gcp.WithUserAttribution); err != nil {
+			return "", "", err
+		}
+		fnMod = alias
 	}
 	// Add the module name to the the package name, such that go build will be able to find it,
 	// if a directory with the package name is not at the app root. Otherwise, assume the package is at the module root.
@@ -378,7 +832,7 @@ func createMainVendored(ctx *gcp.Context, fn fnInfo) error {
 			// Copy the contents of the vendor dir into GOPATH/src.
 			fmt.Sprintf("cp --archive vendor/. %s", gopathSrc),
 		}
-		if _, err := golang.ExecWithGoproxyFallback(ctx, []string{"/bin/bash", "-c", strings.Join(cmd, " && ")}, gcp.WithWorkDir(ffDepsDir), gcp.WithUserAttribution); err != nil {
+		if _, err := execWithGoproxy(ctx, fn, []string{"/bin/bash", "-c", strings.Join(cmd, " && ")}, gcp.WithWorkDir(ffDepsDir), gcp.WithUserAttribution); err != nil {
 			return fmt.Errorf("running command chain: %w", err)
 		}
 
@@ -428,6 +882,44 @@ func createMainGoFile(ctx *gcp.Context, fn fnInfo, main, version string) error {
 	return nil
 }
 
+// frameworkVersionWithoutGoSum returns the version of the framework specified in the go.mod of a
+// function without a go.sum, or an empty string if unspecified. `go list` cannot verify the go.mod
+// files of the dependencies without a go.sum, so it runs with -mod=mod to download them and record
+// their checksums. Unlike frameworkSpecifiedVersion, it fails if the go.mod files cannot be
+// downloaded, since the version required by the function would otherwise be replaced.
+func frameworkVersionWithoutGoSum(ctx *gcp.Context, fn fnInfo) (string, error) {
+	cmd := []string{"go", "list", "-m", "-f", "{{.Version}}", functionsFrameworkModule}
+	res, err := execWithGoproxy(ctx, fn, cmd, gcp.WithEnv(modModGoFlags(fn.GoFlags)), gcp.WithWorkDir(fn.Source), gcp.WithUserAttribution)
+	if err == nil {
+		v := strings.TrimSpace(res.Stdout)
+		ctx.Logf("Found framework version %s", v)
+		return v, nil
+	}
+	if res != nil && strings.Contains(res.Stderr, "not a known dependency") {
+		ctx.Logf("functions-framework not specified in go.mod, using default")
+		return "", nil
+	}
+	return "", err
+}
+
+// modModGoFlags returns the GOFLAGS environment entry that adds -mod=mod to the flags of the user,
+// from goFlags or the GOFLAGS environment variable, replacing any -mod flag they set.
+func modModGoFlags(goFlags []string) string {
+	userFlags := os.Getenv("GOFLAGS")
+	for _, e := range goFlags {
+		if v, ok := strings.CutPrefix(e, "GOFLAGS="); ok {
+			userFlags = v
+		}
+	}
+	var flags []string
+	for _, f := range strings.Fields(userFlags) {
+		if name := strings.SplitN(strings.TrimLeft(f, "-"), "=", 2)[0]; name != "mod" {
+			flags = append(flags, f)
+		}
+	}
+	return "GOFLAGS=" + strings.Join(append(flags, "-mod=mod"), " ")
+}
+
 // If a framework is specified, return the version. If unspecified, return an empty string.
 func frameworkSpecifiedVersion(ctx *gcp.Context, fnSource string) (string, error) {
 	res, err := ctx.Exec([]string{"go", "list", "-m", "-f", "{{.Version}}", functionsFrameworkModule}, gcp.WithWorkDir(fnSource), gcp.WithUserAttribution)
@@ -447,6 +939,66 @@ func frameworkSpecifiedVersion(ctx *gcp.Context, fnSource string) (string, error
 	return "", err
 }
 
+// functionGoproxy validates the GOPROXY set with GOOGLE_FUNCTION_GOPROXY and exports it to all go
+// commands run by the buildpack. It returns an empty string if the env var is not set.
+func functionGoproxy(ctx *gcp.Context) (string, error) {
+	goproxy := strings.TrimSpace(os.Getenv(env.FunctionGoproxy))
+	if goproxy == "" {
+		return "", nil
+	}
+	if err := validateGoproxy(goproxy); err != nil {
+		return "", err
+	}
+	ctx.Logf("Using GOPROXY=%s from %s", goproxy, env.FunctionGoproxy)
+	if err := ctx.Setenv("GOPROXY", goproxy); err != nil {
+		return "", err
+	}
+	return goproxy, nil
+}
+
+// validateGoproxy returns a user error if goproxy is not a list of proxy URLs, "direct" or "off"
+// separated by commas or pipes.
+func validateGoproxy(goproxy string) error {
+	for _, p := range goproxySeparatorRegexp.Split(goproxy, -1) {
+		if p == "direct" || p == "off" {
+			continue
+		}
+		u, err := url.Parse(p)
+		if err == nil && (u.Scheme == "https" || u.Scheme == "http") && u.Host != "" {
+			continue
+		}
+		if err == nil && u.Scheme == "file" && u.Path != "" {
+			continue
+		}
+		return gcp.UserErrorf("invalid %s %q: %q is not a proxy URL, \"direct\" or \"off\". Use a comma-separated list such as \"https://proxy.example.com,direct\"", env.FunctionGoproxy, goproxy, p)
+	}
+	return nil
+}
+
+// execWithGoproxy runs a go command that downloads modules. It uses the GOPROXY set with
+// GOOGLE_FUNCTION_GOPROXY if any, and falls back to the default proxy and direct downloads otherwise.
+func execWithGoproxy(ctx *gcp.Context, fn fnInfo, cmd []string, opts ...gcp.ExecOption) (*gcp.ExecResult, error) {
+	if fn.Goproxy == "" {
+		return golang.ExecWithGoproxyFallback(ctx, cmd, opts...)
+	}
+	return ctx.Exec(cmd, append(opts, gcp.WithEnv("GOPROXY="+fn.Goproxy))...)
+}
+
+// checkStrictTyping returns a user error if GOOGLE_FUNCTION_STRICT_TYPING is true and the function
+// target has type parameters.
+func checkStrictTyping(target string, pkg *parsedPackage) error {
+	strict, err := env.IsPresentAndTrue(env.FunctionStrictTyping)
+	if err != nil || !strict {
+		return err
+	}
+	for _, fn := range pkg.GenericFunctions {
+		if fn == target {
+			return gcp.UserErrorf("function target %s has type parameters, which are not supported with %s=true. Use one of the supported function signatures, see https://cloud.google.com/functions/docs/writing", target, env.FunctionStrictTyping)
+		}
+	}
+	return nil
+}
+
 // extractPackageNameInDir builds the script that does the extraction, and then runs it with the
 // specified source directory.
 // The parser is dependent on the language version being used, and it's highly likely that the buildpack binary
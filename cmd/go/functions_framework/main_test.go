@@ -16,10 +16,18 @@ package main
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"testing/fstest"
 
 	"github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
 	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
 )
 
 func TestDetect(t *testing.T) {
@@ -39,6 +47,33 @@ func TestDetect(t *testing.T) {
 			name: "without target",
 			want: 100,
 		},
+		{
+			name: "without target with one declarative function",
+			files: map[string]string{
+				"fn.go": `package myfunc
+
+import "github.com/GoogleCloudPlatform/functions-framework-go/functions"
+
+func init() {
+	functions.HTTP("HelloWorld", helloWorld)
+}`,
+			},
+			want: 0,
+		},
+		{
+			name: "without target with multiple declarative functions",
+			files: map[string]string{
+				"fn.go": `package myfunc
+
+import "github.com/GoogleCloudPlatform/functions-framework-go/functions"
+
+func init() {
+	functions.HTTP("HelloWorld", helloWorld)
+	functions.CloudEvent("HelloEvent", helloEvent)
+}`,
+			},
+			want: 100,
+		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -49,14 +84,18 @@ func TestDetect(t *testing.T) {
 
 func TestBuild(t *testing.T) {
 	testCases := []struct {
-		name         string
-		app          string
-		envs         []string
-		fnPkgName    string
-		opts         []buildpacktest.Option
-		mocks        []*mockprocess.Mock
-		wantExitCode int // 0 if unspecified
-		wantCommands []string
+		name              string
+		app               string
+		envs              []string
+		unsetTarget       bool
+		fnPkgName         string
+		getPackage        string // get_package output, defaults to the package name only
+		opts              []buildpacktest.Option
+		mocks             []*mockprocess.Mock
+		wantExitCode      int // 0 if unspecified
+		wantCommands      []string
+		doNotWantCommands []string
+		wantOutput        []string
 	}{
 		{
 			name:      "go mod function with framework",
@@ -67,6 +106,89 @@ func TestBuild(t *testing.T) {
 			},
 			wantCommands: []string{fmt.Sprintf("go mod tidy")},
 		},
+		{
+			name:      "go mod function with GOOGLE_GOFLAGS",
+			app:       "with_framework",
+			envs:      []string{"GOOGLE_GOFLAGS=-mod=mod"},
+			fnPkgName: "myfunc",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^go list -m$`, mockprocess.WithStdout("example.com/myfunc")),
+			},
+			wantCommands: []string{
+				`go list -m [^\n]*GOFLAGS=-mod=mod`,
+				`go mod tidy [^\n]*GOFLAGS=-mod=mod`,
+			},
+		},
+		{
+			name:      "go mod function with GOOGLE_FUNCTION_GOPROXY",
+			app:       "with_framework",
+			envs:      []string{"GOOGLE_FUNCTION_GOPROXY=https://proxy.example.com,direct"},
+			fnPkgName: "myfunc",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^go list -m$`, mockprocess.WithStdout("example.com/myfunc")),
+			},
+			wantCommands: []string{`go mod tidy [^\n]*GOPROXY=https://proxy.example.com,direct\)`},
+		},
+		{
+			name:      "go mod function with direct GOOGLE_FUNCTION_GOPROXY",
+			app:       "with_framework",
+			envs:      []string{"GOOGLE_FUNCTION_GOPROXY=direct"},
+			fnPkgName: "myfunc",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^go list -m$`, mockprocess.WithStdout("example.com/myfunc")),
+			},
+			wantCommands: []string{`go mod tidy [^\n]*GOPROXY=direct\)`},
+		},
+		{
+			name:         "go mod function with invalid GOOGLE_FUNCTION_GOPROXY",
+			app:          "with_framework",
+			envs:         []string{"GOOGLE_FUNCTION_GOPROXY=proxy.example.com"},
+			fnPkgName:    "myfunc",
+			wantExitCode: 1,
+		},
+		{
+			name:      "go mod function not safe for concurrent requests",
+			app:       "with_framework",
+			envs:      []string{"GOOGLE_FUNCTION_CONCURRENCY_SAFE=false"},
+			fnPkgName: "myfunc",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^go list -m$`, mockprocess.WithStdout("example.com/myfunc")),
+			},
+			wantCommands: []string{"go mod tidy"},
+			wantOutput: []string{
+				"GOOGLE_FUNCTION_CONCURRENCY_SAFE=false: the function does not support concurrent requests",
+				"Adding image label google.cloud-run-container-concurrency: 1",
+			},
+		},
+		{
+			name:         "go mod function with invalid GOOGLE_FUNCTION_CONCURRENCY_SAFE",
+			app:          "with_framework",
+			envs:         []string{"GOOGLE_FUNCTION_CONCURRENCY_SAFE=sometimes"},
+			fnPkgName:    "myfunc",
+			wantExitCode: 1,
+		},
+		{
+			name:      "go mod function with module path without dot",
+			app:       "with_framework",
+			fnPkgName: "myfunc",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^go list -m$`, mockprocess.WithStdout("myfunc")),
+			},
+			wantCommands: []string{
+				"go mod edit -require example.local/myfunc@v0.0.0 -replace example.local/myfunc=./",
+				"go mod tidy",
+			},
+		},
+		{
+			name:      "go mod function with module path without dot in strict mode",
+			app:       "with_framework",
+			envs:      []string{"GOOGLE_GO_STRICT_MODULE_PATH=true"},
+			fnPkgName: "myfunc",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^go list -m$`, mockprocess.WithStdout("myfunc")),
+			},
+			wantExitCode: 1,
+		},
 		{
 			name: "go mod function with framework without injection",
 			app:  "with_framework",
@@ -147,16 +269,209 @@ func TestBuild(t *testing.T) {
 			mocks:        []*mockprocess.Mock{},
 			wantExitCode: 1,
 		},
+		{
+			name: "function in source subdirectory",
+			envs: []string{"GOOGLE_FUNCTION_SOURCE_SUBDIR=functions/hello"},
+			opts: []buildpacktest.Option{
+				buildpacktest.WithFiles(map[string]string{
+					"functions/hello/go.mod": "module example.com/hello\n",
+					"functions/hello/go.sum": "",
+					"functions/hello/fn.go":  "package hello\n",
+					"functions/other/go.mod": "module example.com/other\n",
+				}),
+			},
+			fnPkgName: "hello",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^go list -m$`, mockprocess.WithStdout("example.com/hello")),
+			},
+			wantCommands: []string{
+				"get_package/main.go -dir .*/serverless_function_source_code/functions/hello",
+				"go clean -modcache",
+				"go mod tidy",
+			},
+		},
+		{
+			name: "custom staging directory",
+			envs: []string{"GOOGLE_FUNCTION_STAGING_DIR=fn_staging"},
+			opts: []buildpacktest.Option{
+				buildpacktest.WithFiles(map[string]string{
+					"go.mod": "module example.com/myfunc\n",
+					"go.sum": "",
+					"fn.go":  "package myfunc\n",
+					"serverless_function_source_code/data.txt": "",
+				}),
+			},
+			fnPkgName: "myfunc",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^go list -m$`, mockprocess.WithStdout("example.com/myfunc")),
+			},
+			wantCommands: []string{
+				"get_package/main.go -dir [^ ]*/fn_staging ",
+				"go mod tidy",
+			},
+		},
+		{
+			name: "default staging directory exists in source",
+			opts: []buildpacktest.Option{
+				buildpacktest.WithFiles(map[string]string{
+					"go.mod": "module example.com/myfunc\n",
+					"fn.go":  "package myfunc\n",
+					"serverless_function_source_code/data.txt": "",
+				}),
+			},
+			fnPkgName:    "myfunc",
+			wantExitCode: 1,
+			wantOutput:   []string{`the application source contains "serverless_function_source_code"`, "GOOGLE_FUNCTION_STAGING_DIR"},
+		},
+		{
+			name: "custom staging directory exists in source",
+			envs: []string{"GOOGLE_FUNCTION_STAGING_DIR=build"},
+			opts: []buildpacktest.Option{
+				buildpacktest.WithFiles(map[string]string{
+					"go.mod":         "module example.com/myfunc\n",
+					"fn.go":          "package myfunc\n",
+					"build/data.txt": "",
+				}),
+			},
+			fnPkgName:    "myfunc",
+			wantExitCode: 1,
+			wantOutput:   []string{`the application source contains "build"`},
+		},
+		{
+			name:         "staging directory is a path",
+			envs:         []string{"GOOGLE_FUNCTION_STAGING_DIR=tmp/staging"},
+			app:          "with_framework",
+			fnPkgName:    "myfunc",
+			wantExitCode: 1,
+			wantOutput:   []string{`invalid GOOGLE_FUNCTION_STAGING_DIR "tmp/staging"`},
+		},
+		{
+			name: "function in go workspace",
+			envs: []string{"GOOGLE_FUNCTION_SOURCE_SUBDIR=service"},
+			opts: []buildpacktest.Option{
+				buildpacktest.WithFiles(map[string]string{
+					"go.work":        "go 1.22\n\nuse (\n\t./service\n\t./shared // library\n)\n",
+					"service/go.mod": "module example.com/service\n",
+					"service/go.sum": "",
+					"service/fn.go":  "package service\n",
+					"shared/go.mod":  "module example.com/shared\n",
+				}),
+			},
+			fnPkgName: "service",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^go list -m$`, mockprocess.WithStdout("example.com/service")),
+			},
+			wantCommands: []string{
+				"go work use .*/serverless_function_source_code/service",
+				`go list -m [^\n]*GOWORK=off`,
+				"go mod download",
+			},
+			doNotWantCommands: []string{
+				"go mod tidy",
+			},
+		},
+		{
+			name: "vendored go workspace",
+			envs: []string{"GOOGLE_FUNCTION_SOURCE_SUBDIR=service"},
+			opts: []buildpacktest.Option{
+				buildpacktest.WithFiles(map[string]string{
+					"go.work":                  "go 1.22\n\nuse ./service\nuse ./shared\n",
+					"service/go.mod":           "module example.com/service\n",
+					"service/fn.go":            "package service\n",
+					"shared/go.mod":            "module example.com/shared\n",
+					"vendor/modules.txt":       "",
+					"service/vendor/README.md": "",
+				}),
+			},
+			fnPkgName:    "service",
+			wantExitCode: 1,
+			doNotWantCommands: []string{
+				"go work use",
+			},
+		},
+		{
+			name:         "missing source subdirectory",
+			app:          "with_framework",
+			envs:         []string{"GOOGLE_FUNCTION_SOURCE_SUBDIR=functions/missing"},
+			fnPkgName:    "myfunc",
+			wantExitCode: 1,
+		},
+		{
+			name:        "declarative function without target",
+			app:         "declarative_http",
+			unsetTarget: true,
+			getPackage:  fmt.Sprintf(`{"name":"myfunc","imports":{%q:{}},"functions":[{"name":"Func","kind":"http"}]}`, functionsFrameworkFunctionsPackage),
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^go list -m$`, mockprocess.WithStdout("example.com/myfunc")),
+				mockprocess.New(`^go list -m -f {{.Version}}.*`, mockprocess.WithStdout("v1.5.1")),
+			},
+			wantCommands: []string{"go mod tidy"},
+		},
+		{
+			name:         "multiple declarative functions without target",
+			app:          "declarative_http",
+			unsetTarget:  true,
+			getPackage:   fmt.Sprintf(`{"name":"myfunc","imports":{%q:{}},"functions":[{"name":"Func","kind":"http"},{"name":"Other","kind":"cloudevent"}]}`, functionsFrameworkFunctionsPackage),
+			wantExitCode: 1,
+		},
+		{
+			name:         "target not among declarative functions",
+			app:          "declarative_http",
+			getPackage:   fmt.Sprintf(`{"name":"myfunc","imports":{%q:{}},"functions":[{"name":"Other","kind":"http"}]}`, functionsFrameworkFunctionsPackage),
+			wantExitCode: 1,
+		},
+		{
+			name:         "generic target with GOOGLE_FUNCTION_STRICT_TYPING",
+			app:          "with_framework",
+			envs:         []string{"GOOGLE_FUNCTION_STRICT_TYPING=true"},
+			getPackage:   `{"name":"myfunc","genericFunctions":["Func"]}`,
+			wantExitCode: 1,
+			wantOutput:   []string{"function target Func has type parameters"},
+		},
+		{
+			name:       "generic target without GOOGLE_FUNCTION_STRICT_TYPING",
+			app:        "with_framework",
+			getPackage: `{"name":"myfunc","genericFunctions":["Func"]}`,
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^go list -m$`, mockprocess.WithStdout("example.com/myfunc")),
+			},
+			wantCommands: []string{"go mod tidy"},
+		},
+		{
+			name:       "other generic function with GOOGLE_FUNCTION_STRICT_TYPING",
+			app:        "with_framework",
+			envs:       []string{"GOOGLE_FUNCTION_STRICT_TYPING=true"},
+			getPackage: `{"name":"myfunc","genericFunctions":["Helper"]}`,
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^go list -m$`, mockprocess.WithStdout("example.com/myfunc")),
+			},
+			wantCommands: []string{"go mod tidy"},
+		},
+		{
+			name:       "target not among declarative functions with computed names",
+			app:        "declarative_http",
+			getPackage: fmt.Sprintf(`{"name":"myfunc","imports":{%q:{}},"functions":[{"name":"Other","kind":"http"}],"computedNames":true}`, functionsFrameworkFunctionsPackage),
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^go list -m$`, mockprocess.WithStdout("example.com/myfunc")),
+				mockprocess.New(`^go list -m -f {{.Version}}.*`, mockprocess.WithStdout("v1.5.1")),
+			},
+			wantCommands: []string{"go mod tidy"},
+		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			envs := []string{
-				"GOOGLE_FUNCTION_TARGET=Func",
+			var envs []string
+			if !tc.unsetTarget {
+				envs = append(envs, "GOOGLE_FUNCTION_TARGET=Func")
 			}
 			envs = append(envs, tc.envs...)
+			getPackage := tc.getPackage
+			if getPackage == "" {
+				getPackage = fmt.Sprintf(`{"name":"%s"}`, tc.fnPkgName)
+			}
 			mocks := []*mockprocess.Mock{
-				mockprocess.New("get_package", mockprocess.WithStdout(fmt.Sprintf(`{"name":"%s"}`, tc.fnPkgName))),
+				mockprocess.New("get_package", mockprocess.WithStdout(getPackage)),
 			}
 			mocks = append(mocks, tc.mocks...)
 
@@ -181,6 +496,348 @@ func TestBuild(t *testing.T) {
 					t.Errorf("expected command %q to be executed, but it was not, build output: %s", cmd, result.Output)
 				}
 			}
+			for _, cmd := range tc.doNotWantCommands {
+				if result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q not to be executed, but it was, build output: %s", cmd, result.Output)
+				}
+			}
+			for _, out := range tc.wantOutput {
+				if !strings.Contains(result.Output, out) {
+					t.Errorf("expected output to contain %q, build output: %s", out, result.Output)
+				}
+			}
+		})
+	}
+}
+
+func TestBuildTidiesOnce(t *testing.T) {
+	testCases := []struct {
+		name         string
+		files        map[string]string
+		wantCommands []string
+	}{
+		{
+			name: "without go.sum",
+			files: map[string]string{
+				"go.mod": "module example.com/myfunc\n",
+				"fn.go":  "package myfunc\n",
+			},
+			wantCommands: []string{`go list -m -f {{.Version}} [^\n]*GOFLAGS=-mod=mod`},
+		},
+		{
+			name: "with go.sum",
+			files: map[string]string{
+				"go.mod": "module example.com/myfunc\n",
+				"go.sum": "",
+				"fn.go":  "package myfunc\n",
+			},
+			wantCommands: []string{"go clean -modcache"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := buildpacktest.RunBuild(t, buildFn,
+				buildpacktest.WithTestName(tc.name),
+				buildpacktest.WithFiles(tc.files),
+				buildpacktest.WithEnvs("GOOGLE_FUNCTION_TARGET=Func"),
+				buildpacktest.WithExecMocks(
+					mockprocess.New("get_package", mockprocess.WithStdout(`{"name":"myfunc"}`)),
+					mockprocess.New(`^go list -m$`, mockprocess.WithStdout("example.com/myfunc")),
+				),
+			)
+			if err != nil {
+				t.Fatalf("error running build: %v, logs: %s", err, result.Output)
+			}
+			if got := strings.Count(result.Output, `Running "go mod tidy`); got != 1 {
+				t.Errorf("go mod tidy ran %d times, want 1, build output: %s", got, result.Output)
+			}
+			for _, cmd := range tc.wantCommands {
+				if !result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to be executed, but it was not, build output: %s", cmd, result.Output)
+				}
+			}
+		})
+	}
+}
+
+func TestModModGoFlags(t *testing.T) {
+	testCases := []struct {
+		name    string
+		goFlags []string
+		env     string
+		want    string
+	}{
+		{
+			name: "no flags",
+			want: "GOFLAGS=-mod=mod",
+		},
+		{
+			name:    "GOOGLE_GOFLAGS",
+			goFlags: []string{"GOFLAGS=-buildvcs=false -tags=prod"},
+			want:    "GOFLAGS=-buildvcs=false -tags=prod -mod=mod",
+		},
+		{
+			name:    "GOOGLE_GOFLAGS with mod flag",
+			goFlags: []string{"GOFLAGS=-mod=readonly -buildvcs=false"},
+			want:    "GOFLAGS=-buildvcs=false -mod=mod",
+		},
+		{
+			name: "GOFLAGS environment variable",
+			env:  "-tags=prod -mod=vendor",
+			want: "GOFLAGS=-tags=prod -mod=mod",
+		},
+		{
+			name:    "GOOGLE_GOFLAGS takes precedence",
+			goFlags: []string{"GOFLAGS=-buildvcs=false"},
+			env:     "-tags=prod",
+			want:    "GOFLAGS=-buildvcs=false -mod=mod",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("GOFLAGS", tc.env)
+			if got := modModGoFlags(tc.goFlags); got != tc.want {
+				t.Errorf("modModGoFlags(%v) = %q, want %q", tc.goFlags, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestModCacheHash(t *testing.T) {
+	goMod := "module example.com/myfunc\n\nrequire rsc.io/quote v1.5.2\n"
+	goSum := "rsc.io/quote v1.5.2 h1:abc=\n"
+	testCases := []struct {
+		name       string
+		files      map[string]string
+		wantCached bool
+	}{
+		{
+			name:       "unchanged",
+			wantCached: true,
+		},
+		{
+			name:       "source changed",
+			files:      map[string]string{"fn.go": "package myfunc\n\nfunc F() {}\n"},
+			wantCached: true,
+		},
+		{
+			name:  "go.sum changed",
+			files: map[string]string{"go.sum": goSum + "rsc.io/sampler v1.3.0 h1:def=\n"},
+		},
+		{
+			name:  "go.mod changed",
+			files: map[string]string{"go.mod": goMod + "require rsc.io/sampler v1.3.0\n"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles := func(files map[string]string) {
+				for name, content := range files {
+					if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			writeFiles(map[string]string{"go.mod": goMod, "go.sum": goSum, "fn.go": "package myfunc\n"})
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(dir), gcp.WithBuildpackInfo(libcnb.BuildpackInfo{ID: "google.go.functions-framework", Version: "0.9.4"}))
+			l := &libcnb.Layer{Name: gopathLayerName, Metadata: map[string]any{}}
+			fn := fnInfo{Source: dir}
+
+			hash, cached, err := modCacheHash(ctx, l, fn)
+			if err != nil {
+				t.Fatalf("modCacheHash() got error: %v", err)
+			}
+			if cached {
+				t.Fatalf("modCacheHash() got cached for the first build, want not cached")
+			}
+			cache.Add(ctx, l, goSumCacheKey, hash)
+
+			writeFiles(tc.files)
+			_, cached, err = modCacheHash(ctx, l, fn)
+			if err != nil {
+				t.Fatalf("modCacheHash() got error: %v", err)
+			}
+			if cached != tc.wantCached {
+				t.Errorf("modCacheHash() got cached: %v, want: %v", cached, tc.wantCached)
+			}
+		})
+	}
+}
+
+func TestValidateGoproxy(t *testing.T) {
+	testCases := []struct {
+		goproxy string
+		wantErr bool
+	}{
+		{goproxy: "direct"},
+		{goproxy: "off"},
+		{goproxy: "https://proxy.example.com"},
+		{goproxy: "https://proxy.example.com/go,direct"},
+		{goproxy: "http://10.0.0.1:3000|https://proxy.golang.org|direct"},
+		{goproxy: "file:///var/goproxy"},
+		{goproxy: "proxy.example.com", wantErr: true},
+		{goproxy: "https://", wantErr: true},
+		{goproxy: "ftp://proxy.example.com", wantErr: true},
+		{goproxy: "https://proxy.example.com,,direct", wantErr: true},
+		{goproxy: "Direct", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.goproxy, func(t *testing.T) {
+			err := validateGoproxy(tc.goproxy)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("validateGoproxy(%q) got error: %v, want error: %v", tc.goproxy, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestFunctionGoproxyKeepsChecksumDatabase(t *testing.T) {
+	t.Setenv("GOOGLE_FUNCTION_GOPROXY", "direct")
+	t.Setenv("GOPROXY", "")
+	t.Setenv("GOSUMDB", "")
+	os.Unsetenv("GOSUMDB")
+
+	got, err := functionGoproxy(gcp.NewContext())
+	if err != nil {
+		t.Fatalf("functionGoproxy() got error: %v", err)
+	}
+	if got != "direct" {
+		t.Errorf("functionGoproxy() = %q, want %q", got, "direct")
+	}
+	if sumdb, ok := os.LookupEnv("GOSUMDB"); ok {
+		t.Errorf("functionGoproxy() set GOSUMDB=%q, want it unset", sumdb)
+	}
+}
+
+func TestBrokenEmbedPatterns(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files fstest.MapFS
+		want  []string
+	}{
+		{
+			name: "no embed directives",
+			files: fstest.MapFS{
+				"main.go": {Data: []byte("package main\n")},
+			},
+		},
+		{
+			name: "embedded files in the same directory",
+			files: fstest.MapFS{
+				"main.go":                   {Data: []byte("package main\n\nimport _ \"embed\"\n\n//go:embed assets/* templates\nvar assets embed.FS\n")},
+				"assets/logo.png":           {},
+				"templates/a.tmpl":          {},
+				"pkg/web/server.go":         {Data: []byte("package web\n\n//go:embed \"static/index.html\" `all:public`\nvar static embed.FS\n")},
+				"pkg/web/static/index.html": {},
+				"pkg/web/public/.keep":      {},
+			},
+		},
+		{
+			name: "embedded files outside of the function source",
+			files: fstest.MapFS{
+				"main.go": {Data: []byte("package main\n\n//go:embed ../config.json\nvar config []byte\n")},
+			},
+			want: []string{"main.go: ../config.json"},
+		},
+		{
+			name: "embedded files missing after the move",
+			files: fstest.MapFS{
+				"main.go":         {Data: []byte("package main\n\n//go:embed assets/* .googleconfig/app.yaml\nvar assets embed.FS\n")},
+				"assets/logo.png": {},
+			},
+			want: []string{"main.go: .googleconfig/app.yaml"},
+		},
+		{
+			name: "vendor directory skipped",
+			files: fstest.MapFS{
+				"main.go":                     {Data: []byte("package main\n")},
+				"vendor/example.com/lib/l.go": {Data: []byte("package lib\n\n//go:embed missing.txt\nvar s string\n")},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := brokenEmbedPatterns(tc.files)
+			if err != nil {
+				t.Fatalf("brokenEmbedPatterns() got error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("brokenEmbedPatterns() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestUnsafeGlobalVars(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files fstest.MapFS
+		want  []string
+	}{
+		{
+			name: "no package-level variables",
+			files: fstest.MapFS{
+				"fn.go": {Data: []byte("package fn\n\nconst greeting = \"hello\"\n\nfunc F() {\n\tvar count int\n\t_ = count\n}\n")},
+			},
+		},
+		{
+			name: "synchronization variables",
+			files: fstest.MapFS{
+				"fn.go": {Data: []byte(`package fn
+
+import "sync"
+
+var (
+	mu      sync.Mutex
+	rw      = &sync.RWMutex{}
+	once    sync.Once
+	results = make(chan string, 10)
+	done    chan struct{}
+	_       = greet
+)
+
+func greet() {}
+`)},
+			},
+		},
+		{
+			name: "unsafe variables",
+			files: fstest.MapFS{
+				"fn.go": {Data: []byte(`package fn
+
+import "sync"
+
+var counter int
+
+var (
+	cache   = map[string]string{}
+	mu, wg  = sync.Mutex{}, sync.WaitGroup{}
+)
+`)},
+				"other.go": {Data: []byte("package fn\n\nvar client *http.Client\n")},
+			},
+			want: []string{"fn.go: counter", "fn.go: cache", "fn.go: wg", "other.go: client"},
+		},
+		{
+			name: "test files and subdirectories skipped",
+			files: fstest.MapFS{
+				"fn.go":               {Data: []byte("package fn\n")},
+				"fn_test.go":          {Data: []byte("package fn\n\nvar fixture = 1\n")},
+				"internal/lib/l.go":   {Data: []byte("package lib\n\nvar state = 1\n")},
+				"vendor/example/e.go": {Data: []byte("package example\n\nvar state = 1\n")},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := unsafeGlobalVars(tc.files)
+			if err != nil {
+				t.Fatalf("unsafeGlobalVars() got error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("unsafeGlobalVars() = %q, want %q", got, tc.want)
+			}
 		})
 	}
 }
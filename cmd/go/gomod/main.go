@@ -19,9 +19,11 @@ package main
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/golang"
+	"github.com/buildpacks/libcnb"
 )
 
 func main() {
@@ -29,11 +31,12 @@ func main() {
 }
 
 func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
-	goModExists, err := ctx.FileExists("go.mod")
+	// The application root may be a package of a module rooted higher up in the workspace.
+	goMod, err := ctx.FindInWorkspace("go.mod")
 	if err != nil {
 		return nil, err
 	}
-	if goModExists {
+	if goMod != "" {
 		return gcp.OptInFileFound("go.mod"), nil
 	}
 	return gcp.OptOutFileNotFound("go.mod"), nil
@@ -45,6 +48,14 @@ func buildFn(ctx *gcp.Context) error {
 		return fmt.Errorf("creating GOPATH layer: %w", err)
 	}
 
+	goWork, err := golang.AppGoWork(ctx)
+	if err != nil {
+		return err
+	}
+	if goWork != "" {
+		return downloadWorkspaceModules(ctx, l, goWork)
+	}
+
 	vendorExists, err := ctx.FileExists("vendor")
 	if err != nil {
 		return err
@@ -74,6 +85,7 @@ func buildFn(ctx *gcp.Context) error {
 		return gcp.UserErrorf("go.mod exists but is not writable")
 	}
 	env := []string{"GOPATH=" + l.Path, "GO111MODULE=on"}
+	env = append(env, golang.GoFlags(ctx)...)
 
 	// BuildDirEnv should only be set by App Engine buildpacks.
 	workdir := os.Getenv(golang.BuildDirEnv)
@@ -100,3 +112,21 @@ func buildFn(ctx *gcp.Context) error {
 
 	return nil
 }
+
+// downloadWorkspaceModules downloads the dependencies of all the modules of the Go workspace
+// defined by goWork, and points the go commands of the next buildpacks at it. go.sum files are not
+// generated because `go mod tidy` ignores the workspace and fails to resolve the local modules.
+func downloadWorkspaceModules(ctx *gcp.Context, l *libcnb.Layer, goWork string) error {
+	ctx.Logf("Using the Go workspace defined by %s.", goWork)
+	if err := golang.CheckGoWorkNotVendored(ctx, goWork); err != nil {
+		return err
+	}
+	l.BuildEnvironment.Override("GOWORK", goWork)
+	env := []string{"GOPATH=" + l.Path, "GO111MODULE=on"}
+	env = append(env, golang.GoWorkEnv(goWork)...)
+	env = append(env, golang.GoFlags(ctx)...)
+	if _, err := golang.ExecWithGoproxyFallback(ctx, []string{"go", "mod", "download"}, gcp.WithEnv(env...), gcp.WithWorkDir(filepath.Dir(goWork)), gcp.WithUserAttribution); err != nil {
+		return fmt.Errorf("running go mod download: %w", err)
+	}
+	return nil
+}
@@ -196,7 +196,12 @@ func moduleAndPackageNames(ctx *gcp.Context, fn fnInfo) (string, string, error)
 	if err != nil {
 		return "", "", err
 	}
-	fnMod := result.Stdout
+	// The function module is required through a replace in the generated go.mod, which needs a
+	// module path with a dot in the first path element.
+	fnMod, err := golang.ModulePathAlias(ctx, result.Stdout)
+	if err != nil {
+		return "", "", err
+	}
 	// Add the module name to the the package name, such that go build will be able to find it,
 	// if a directory with the package name is not at the app root. Otherwise, assume the package is at the module root.
 	fnPackage := fnMod
@@ -52,6 +52,14 @@ func buildFn(ctx *gcp.Context) error {
 	if err != nil {
 		return err
 	}
+	skip, err := runtime.SkipInstall()
+	if err != nil {
+		return err
+	}
+	if skip {
+		_, err := runtime.CheckPreinstalled(ctx, runtime.Go, version)
+		return err
+	}
 	grl, err := ctx.Layer(goLayer, gcp.BuildLayer, gcp.CacheLayer, gcp.LaunchLayerIfDevMode)
 	if err != nil {
 		return fmt.Errorf("creating layer: %w", err)
@@ -16,9 +16,12 @@ package main
 
 import (
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/golang"
 )
@@ -120,3 +123,57 @@ func TestRuntimeVersion(t *testing.T) {
 		})
 	}
 }
+
+func TestBuildSkipRuntimeInstall(t *testing.T) {
+	testCases := []struct {
+		name         string
+		mocks        []*mockprocess.Mock
+		wantExitCode int // 0 if unspecified
+		wantOutput   string
+	}{
+		{
+			name: "go on PATH with the requested version",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`command -v go`, mockprocess.WithStdout("/usr/local/go/bin/go")),
+				mockprocess.New(`^go version`, mockprocess.WithStdout("go version go1.21.5 linux/amd64")),
+			},
+			wantOutput: "Using Go v1.21.5 from the base image because GOOGLE_SKIP_RUNTIME_INSTALL is set.",
+		},
+		{
+			name: "go on PATH with another version",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`command -v go`, mockprocess.WithStdout("/usr/local/go/bin/go")),
+				mockprocess.New(`^go version`, mockprocess.WithStdout("go version go1.20.14 linux/amd64")),
+			},
+			wantExitCode: 1,
+			wantOutput:   `Go 1.20.14 on PATH does not satisfy the requested version "1.21.x"`,
+		},
+		{
+			name: "go not on PATH",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`command -v go`, mockprocess.WithStdout("")),
+			},
+			wantExitCode: 1,
+			wantOutput:   "go was not found on PATH",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := buildpacktest.RunBuild(t, buildFn,
+				buildpacktest.WithTestName(tc.name),
+				buildpacktest.WithFiles(map[string]string{"main.go": ""}),
+				buildpacktest.WithEnvs(env.SkipRuntimeInstall+"=true", "GOOGLE_GO_VERSION=1.21.x"),
+				buildpacktest.WithExecMocks(tc.mocks...),
+			)
+			if err != nil && tc.wantExitCode == 0 {
+				t.Fatalf("error running build: %v, logs: %s", err, result.Output)
+			}
+			if result.ExitCode != tc.wantExitCode {
+				t.Errorf("build exit code mismatch, got: %d, want: %d", result.ExitCode, tc.wantExitCode)
+			}
+			if !strings.Contains(result.Output, tc.wantOutput) {
+				t.Errorf("build output = %q, want output containing %q", result.Output, tc.wantOutput)
+			}
+		})
+	}
+}
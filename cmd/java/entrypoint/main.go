@@ -17,9 +17,10 @@ package main
 
 import (
 	"fmt"
-	"os"
+	"regexp"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/appengine"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/appstart"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/appyaml"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/devmode"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
@@ -27,6 +28,11 @@ import (
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/java"
 )
 
+const appYamlEnvLayer = "app_yaml_env"
+
+// procfileWebRe matches the web process of a Procfile.
+var procfileWebRe = regexp.MustCompile(`(?m)^web:\s*(.+?)\s*$`)
+
 func main() {
 	gcp.Main(detectFn, buildFn)
 }
@@ -36,10 +42,14 @@ func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
 }
 
 func buildFn(ctx *gcp.Context) error {
-	if entrypoint := getEntrypoint(ctx); env.IsFlex() && entrypoint != "" {
-		ctx.Setenv(env.Entrypoint, entrypoint)
-		appengine.Build(ctx, "java", nil)
-		return nil
+	if env.IsFlex() {
+		handled, err := buildFlex(ctx)
+		if err != nil {
+			return err
+		}
+		if handled {
+			return nil
+		}
 	}
 
 	executable, err := java.ExecutableJar(ctx)
@@ -67,10 +77,69 @@ func buildFn(ctx *gcp.Context) error {
 	return nil
 }
 
-func getEntrypoint(ctx *gcp.Context) string {
-	if entrypoint := os.Getenv(env.Entrypoint); entrypoint != "" {
-		return entrypoint
+// buildFlex applies the app.yaml configuration of a GAE Flexible app. The env_variables are
+// exported at launch and the entrypoint is chosen with the following precedence:
+//  1. GOOGLE_ENTRYPOINT or GOOGLE_ENTRYPOINT_JSON
+//  2. The web process in a Procfile
+//  3. The entrypoint in app.yaml
+//
+// The entrypoint is written to the App Engine start config run by /start. It returns false if none of them declares an entrypoint.
+func buildFlex(ctx *gcp.Context) (bool, error) {
+	cfg, err := appyaml.JavaConfiguration(ctx.ApplicationRoot())
+	if err != nil {
+		return false, err
+	}
+	if len(cfg.EnvVariables) > 0 {
+		l, err := ctx.Layer(appYamlEnvLayer, gcp.LaunchLayer)
+		if err != nil {
+			return false, fmt.Errorf("creating %v layer: %w", appYamlEnvLayer, err)
+		}
+		for name, value := range cfg.EnvVariables {
+			l.LaunchEnvironment.Default(name, value)
+		}
+		ctx.Logf("Exporting %d env_variables from app.yaml.", len(cfg.EnvVariables))
+	}
+
+	entrypoint, err := flexEntrypoint(ctx, cfg)
+	if err != nil {
+		return false, err
+	}
+	if entrypoint == nil {
+		return false, nil
+	}
+	if cfg.Entrypoint != "" && entrypoint.Source != "app.yaml" {
+		ctx.Logf("Using entrypoint from %s: %s (overrides the app.yaml entrypoint %q)", entrypoint.Source, entrypoint, cfg.Entrypoint)
+	} else {
+		ctx.Logf("Using entrypoint from %s: %s", entrypoint.Source, entrypoint)
+	}
+	// GOOGLE_ENTRYPOINT already takes precedence in appengine.Build, so the generator only supplies
+	// the Procfile or app.yaml entrypoint.
+	return true, appengine.Build(ctx, "java", func(*gcp.Context) (*appstart.Entrypoint, error) {
+		return &appstart.Entrypoint{Type: appstart.EntrypointUser.String(), Command: entrypoint.String()}, nil
+	})
+}
+
+// flexEntrypoint returns the entrypoint of a GAE Flexible app, or nil if none is declared.
+func flexEntrypoint(ctx *gcp.Context, cfg appyaml.JavaConfig) (*gcp.Entrypoint, error) {
+	entrypoint, err := ctx.Entrypoint()
+	if err != nil || entrypoint != nil {
+		return entrypoint, err
+	}
+	procExists, err := ctx.FileExists("Procfile")
+	if err != nil {
+		return nil, err
+	}
+	if procExists {
+		b, err := ctx.ReadFile("Procfile")
+		if err != nil {
+			return nil, err
+		}
+		if m := procfileWebRe.FindStringSubmatch(string(b)); m != nil {
+			return &gcp.Entrypoint{Source: "Procfile", Shell: m[1]}, nil
+		}
+	}
+	if cfg.Entrypoint == "" {
+		return nil, nil
 	}
-	entrypoint, _ := appyaml.EntrypointIfExists(ctx.ApplicationRoot())
-	return entrypoint
+	return &gcp.Entrypoint{Source: "app.yaml", Shell: cfg.Entrypoint}, nil
 }
@@ -15,6 +15,7 @@
 package main
 
 import (
+	"strings"
 	"testing"
 
 	buildpacktest "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
@@ -24,3 +25,89 @@ func TestDetect(t *testing.T) {
 	// The buildpack always opts in.
 	buildpacktest.TestDetect(t, detectFn, "no files", map[string]string{}, []string{}, 0)
 }
+
+func TestBuild(t *testing.T) {
+	testCases := []struct {
+		name         string
+		files        map[string]string
+		envs         []string
+		wantExitCode int // 0 if unspecified
+		wantOutput   []string
+	}{
+		{
+			name: "flex entrypoint from app.yaml",
+			files: map[string]string{
+				"app.yaml": "entrypoint: java -Xmx512m -jar app.jar\nenv_variables:\n  FOO: bar\n",
+			},
+			envs: []string{"GAE_APPLICATION_YAML_PATH=app.yaml"},
+			wantOutput: []string{
+				"Using entrypoint from app.yaml: java -Xmx512m -jar app.jar",
+				`Entrypoint:appstart.Entrypoint{Type:"User", Command:"java -Xmx512m -jar app.jar"`,
+				"Exporting 1 env_variables from app.yaml.",
+			},
+		},
+		{
+			name: "flex GOOGLE_ENTRYPOINT overrides app.yaml",
+			files: map[string]string{
+				"app.yaml": "entrypoint: java -jar app.jar\n",
+			},
+			envs: []string{"GAE_APPLICATION_YAML_PATH=app.yaml", "GOOGLE_ENTRYPOINT=java -jar other.jar"},
+			wantOutput: []string{
+				`Using entrypoint from GOOGLE_ENTRYPOINT: java -jar other.jar (overrides the app.yaml entrypoint "java -jar app.jar")`,
+				`Entrypoint:appstart.Entrypoint{Type:"User", Command:"java -jar other.jar"`,
+			},
+		},
+		{
+			name: "flex GOOGLE_ENTRYPOINT_JSON overrides app.yaml",
+			files: map[string]string{
+				"app.yaml": "entrypoint: java -jar app.jar\n",
+			},
+			envs: []string{"GAE_APPLICATION_YAML_PATH=app.yaml", `GOOGLE_ENTRYPOINT_JSON=["java", "-Dgreeting=hello world", "-jar", "other.jar"]`},
+			wantOutput: []string{
+				`Using entrypoint from GOOGLE_ENTRYPOINT_JSON: java '-Dgreeting=hello world' -jar other.jar (overrides the app.yaml entrypoint "java -jar app.jar")`,
+			},
+		},
+		{
+			name: "flex Procfile overrides app.yaml",
+			files: map[string]string{
+				"app.yaml": "entrypoint: java -jar app.jar\n",
+				"Procfile": "web: java -jar procfile.jar\n",
+			},
+			envs: []string{"GAE_APPLICATION_YAML_PATH=app.yaml"},
+			wantOutput: []string{
+				`Using entrypoint from Procfile: java -jar procfile.jar (overrides the app.yaml entrypoint "java -jar app.jar")`,
+				`Entrypoint:appstart.Entrypoint{Type:"User", Command:"java -jar procfile.jar"`,
+			},
+		},
+		{
+			name: "flex malformed app.yaml",
+			files: map[string]string{
+				"app.yaml": "entrypoint: java -jar app.jar\nenv_variables:\n  - FOO=bar\n",
+			},
+			envs:         []string{"GAE_APPLICATION_YAML_PATH=app.yaml"},
+			wantExitCode: 1,
+			wantOutput:   []string{"env_variables must be a mapping"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			envs := append([]string{"X_GOOGLE_TARGET_PLATFORM=flex"}, tc.envs...)
+			result, err := buildpacktest.RunBuild(t, buildFn,
+				buildpacktest.WithTestName(tc.name),
+				buildpacktest.WithFiles(tc.files),
+				buildpacktest.WithEnvs(envs...),
+			)
+			if err != nil && tc.wantExitCode == 0 {
+				t.Fatalf("error running build: %v, logs: %s", err, result.Output)
+			}
+			if result.ExitCode != tc.wantExitCode {
+				t.Errorf("build exit code mismatch, got: %d, want: %d", result.ExitCode, tc.wantExitCode)
+			}
+			for _, want := range tc.wantOutput {
+				if !strings.Contains(result.Output, want) {
+					t.Errorf("build output does not contain %q, build output: %s", want, result.Output)
+				}
+			}
+		})
+	}
+}
@@ -42,6 +42,9 @@ const (
 
 var (
 	frameworkVersionRegex = regexp.MustCompile("java-function-invoker-((\\d+\\.)*\\d+)")
+	// multipleTargetsRegex matches the usage of the --target option of an invoker that accepts it
+	// more than once.
+	multipleTargetsRegex = regexp.MustCompile(`(?i)--target\b[^\n]*\b(?:multiple|repeat(?:ed|able)?)\b`)
 )
 
 func main() {
@@ -56,7 +59,11 @@ func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
 }
 
 func buildFn(ctx *gcp.Context) error {
-	classpath, err := classpath(ctx)
+	sourceDir, err := functionSourceDir(ctx)
+	if err != nil {
+		return err
+	}
+	classpath, err := classpath(ctx, sourceDir)
 	if err != nil {
 		return err
 	}
@@ -65,7 +72,7 @@ func buildFn(ctx *gcp.Context) error {
 	if err != nil {
 		return fmt.Errorf("creating %v layer: %w", layerName, err)
 	}
-	ffPath, err := installFunctionsFramework(ctx, layer)
+	ffPath, err := installFunctionsFramework(ctx, layer, sourceDir)
 	layer.BuildEnvironment.Override(java.FFJarPathEnv, ffPath)
 	if err != nil {
 		return err
@@ -81,17 +88,76 @@ func buildFn(ctx *gcp.Context) error {
 	// Success here doesn't guarantee that the function will execute. It might not implement one of the
 	// required interfaces, for example. But it eliminates the commonest problem of specifying the wrong target.
 	// We use an ExecUser* method so that the time taken by the javap command is counted as user time.
-	target := os.Getenv(env.FunctionTarget)
-	if result, err := ctx.Exec([]string{"javap", "-classpath", classpath, target}, gcp.WithUserAttribution); err != nil {
-		// The javap error output will typically be "Error: class not found: foo.Bar".
-		return gcp.UserErrorf("build succeeded but did not produce the class %q specified as the function target: %s", target, result.Combined)
+	// All the targets are built from the same source, so a single classpath serves all of them.
+	targets, err := functionTargets(os.Getenv(env.FunctionTarget))
+	if err != nil {
+		return err
+	}
+	for _, target := range targets {
+		if result, err := ctx.Exec([]string{"javap", "-classpath", classpath, target}, gcp.WithUserAttribution); err != nil {
+			// The javap error output will typically be "Error: class not found: foo.Bar".
+			return gcp.UserErrorf("build succeeded but did not produce the class %q specified as the function target: %s", target, result.Combined)
+		}
+	}
+	if len(targets) > 1 {
+		supported, err := supportsMultipleTargets(ctx, ffPath)
+		if err != nil {
+			return err
+		}
+		if !supported {
+			return gcp.UserErrorf("%s specifies %d function targets but the functions framework %s serves a single target, use a version of java-function-invoker that accepts multiple --target options", env.FunctionTarget, len(targets), filepath.Base(ffPath))
+		}
 	}
 
 	launcherSource := filepath.Join(ctx.BuildpackRoot(), "launch.sh")
 	launcherTarget := filepath.Join(layer.Path, "launch.sh")
 	createLauncher(ctx, launcherSource, launcherTarget)
-	ctx.AddWebProcess([]string{launcherTarget, "java", "-jar", ffPath, "--classpath", classpath})
+	return addWebProcess(ctx, launcherTarget, ffPath, classpath, targets)
+}
 
+// functionTargets returns the function targets of a comma-separated GOOGLE_FUNCTION_TARGET, e.g.
+// `MyHttpFunc,MyCloudEventFunc`.
+func functionTargets(s string) ([]string, error) {
+	var targets []string
+	seen := map[string]bool{}
+	for _, target := range strings.Split(s, ",") {
+		target = strings.TrimSpace(target)
+		if target == "" {
+			return nil, gcp.UserErrorf("invalid %s %q: function targets must not be empty", env.FunctionTarget, s)
+		}
+		if !seen[target] {
+			seen[target] = true
+			targets = append(targets, target)
+		}
+	}
+	return targets, nil
+}
+
+// supportsMultipleTargets returns true if the usage of the functions framework invoker states that
+// the --target option can be repeated.
+func supportsMultipleTargets(ctx *gcp.Context, ffPath string) (bool, error) {
+	result, err := ctx.Exec([]string{"java", "-jar", ffPath, "--help"})
+	if err != nil {
+		return false, gcp.InternalErrorf("getting the usage of the functions framework: %v", err)
+	}
+	return multipleTargetsRegex.MatchString(result.Combined), nil
+}
+
+// addWebProcess registers the functions framework launch command with the extra arguments from
+// GOOGLE_FUNCTIONS_FRAMEWORK_ARGS. A single target is read by the invoker from FUNCTION_TARGET,
+// while multiple targets are passed with a --target option each.
+func addWebProcess(ctx *gcp.Context, launcher, ffPath, classpath string, targets []string) error {
+	args, err := cloudfunctions.FrameworkArgs()
+	if err != nil {
+		return err
+	}
+	cmd := []string{launcher, "java", "-jar", ffPath, "--classpath", classpath}
+	if len(targets) > 1 {
+		for _, target := range targets {
+			cmd = append(cmd, "--target", target)
+		}
+	}
+	ctx.AddWebProcess(append(cmd, args...))
 	return nil
 }
 
@@ -106,22 +172,78 @@ func createLauncher(ctx *gcp.Context, launcherSource, launcherTarget string) err
 	return nil
 }
 
+// functionSourceDir returns the Maven or Gradle module directory selected by GOOGLE_FUNCTION_SOURCE,
+// relative to the application root, or an empty string if the variable is unset and the function is
+// built from the application root.
+func functionSourceDir(ctx *gcp.Context) (string, error) {
+	source, ok := os.LookupEnv(env.FunctionSource)
+	if !ok || source == "" {
+		return "", nil
+	}
+	dir := filepath.Clean(source)
+	if !filepath.IsLocal(dir) {
+		return "", gcp.UserErrorf("%s=%q must be a directory within the application", env.FunctionSource, source)
+	}
+	if dir == "." {
+		return "", nil
+	}
+	for _, buildFile := range []string{"pom.xml", "build.gradle"} {
+		exists, err := ctx.FileExists(dir, buildFile)
+		if err != nil {
+			return "", err
+		}
+		if exists {
+			ctx.Logf("Building function from %s=%q", env.FunctionSource, source)
+			return dir, nil
+		}
+	}
+	return "", gcp.UserErrorf("%s=%q does not contain a pom.xml or build.gradle file", env.FunctionSource, source)
+}
+
+// mvnArgs returns the command line to run Maven with the given arguments on the pom.xml in dir, or
+// on the one in the application root if dir is empty.
+func mvnArgs(mvn, dir string, args ...string) []string {
+	cmd := []string{mvn}
+	if dir != "" {
+		cmd = append(cmd, "-f", filepath.Join(dir, "pom.xml"))
+	}
+	return append(cmd, args...)
+}
+
+// gradleArgs returns the command line to run Gradle with the given arguments on the project in dir,
+// or on the one in the application root if dir is empty.
+func gradleArgs(gradle, dir string, args ...string) []string {
+	cmd := []string{gradle}
+	if dir != "" {
+		cmd = append(cmd, "-p", dir)
+	}
+	return append(cmd, args...)
+}
+
 // classpath determines what the --classpath argument should be. This tells the Functions Framework where to find
-// the classes of the function, including dependencies.
-func classpath(ctx *gcp.Context) (string, error) {
-	pomExists, err := ctx.FileExists("pom.xml")
+// the classes of the function, including dependencies. sourceDir is the module directory selected by
+// GOOGLE_FUNCTION_SOURCE, or empty for the application root.
+func classpath(ctx *gcp.Context, sourceDir string) (string, error) {
+	pomExists, err := ctx.FileExists(sourceDir, "pom.xml")
 	if err != nil {
 		return "", err
 	}
 	if pomExists {
-		return mavenClasspath(ctx)
+		return mavenClasspath(ctx, sourceDir)
 	}
-	buildGradleExists, err := ctx.FileExists("build.gradle")
+	buildGradleExists, err := ctx.FileExists(sourceDir, "build.gradle")
 	if err != nil {
 		return "", err
 	}
 	if buildGradleExists {
-		return gradleClasspath(ctx)
+		return gradleClasspath(ctx, sourceDir)
+	}
+	buildSbtExists, err := ctx.FileExists("build.sbt")
+	if err != nil {
+		return "", err
+	}
+	if buildSbtExists {
+		return java.ScalaClasspath(ctx)
 	}
 	jars, err := ctx.Glob("*.jar")
 	if err != nil {
@@ -148,19 +270,19 @@ func classpath(ctx *gcp.Context) (string, error) {
 
 // mavenClasspath determines the --classpath when there is a pom.xml. This will consist of the jar file built
 // from the pom.xml itself, plus all jar files that are dependencies mentioned in the pom.xml.
-func mavenClasspath(ctx *gcp.Context) (string, error) {
+func mavenClasspath(ctx *gcp.Context, sourceDir string) (string, error) {
 	mvn, err := java.MvnCmd(ctx)
 	if err != nil {
 		return "", err
 	}
 
 	// Copy the dependencies of the function (`<dependencies>` in pom.xml) into target/dependency.
-	if _, err := ctx.Exec([]string{mvn, "--batch-mode", "dependency:copy-dependencies", "-Dmdep.prependGroupId", "-DincludeScope=runtime"}, gcp.WithUserAttribution); err != nil {
+	if _, err := ctx.Exec(mvnArgs(mvn, sourceDir, "--batch-mode", "dependency:copy-dependencies", "-Dmdep.prependGroupId", "-DincludeScope=runtime"), gcp.WithUserAttribution); err != nil {
 		return "", err
 	}
 
 	// Extract the final jar name from the user's pom.xml definitions.
-	execResult, err := ctx.Exec([]string{mvn, "help:evaluate", "-q", "-DforceStdout", "-Dexpression=project.build.finalName"}, gcp.WithUserAttribution)
+	execResult, err := ctx.Exec(mvnArgs(mvn, sourceDir, "help:evaluate", "-q", "-DforceStdout", "-Dexpression=project.build.finalName"), gcp.WithUserAttribution)
 	if err != nil {
 		return "", err
 	}
@@ -168,7 +290,7 @@ func mavenClasspath(ctx *gcp.Context) (string, error) {
 	if len(artifactName) == 0 {
 		return "", gcp.UserErrorf("invalid project.build.finalName configured in pom.xml")
 	}
-	jarName := fmt.Sprintf("target/%s.jar", artifactName)
+	jarName := filepath.Join(sourceDir, "target", artifactName+".jar")
 	jarExists, err := ctx.FileExists(jarName)
 	if err != nil {
 		return "", err
@@ -179,7 +301,7 @@ func mavenClasspath(ctx *gcp.Context) (string, error) {
 
 	// The Functions Framework understands "*" to mean every jar file in that directory.
 	// So this classpath consists of the just-built jar and all of the dependency jars.
-	return jarName + ":target/dependency/*", nil
+	return jarName + ":" + filepath.Join(sourceDir, "target", "dependency", "*"), nil
 }
 
 // gradleClasspath determines the --classpath when there is a build.gradle. This will consist of the jar file built
@@ -188,7 +310,7 @@ func mavenClasspath(ctx *gcp.Context) (string, error) {
 // the user's build.gradle to append tasks that do that. This is a bit ugly, but using --init-script didn't work
 // because apparently you can't define tasks there; and having the predefined script include the user's build.gradle
 // didn't work very well either, because you can't use a plugins {} clause in an included script.
-func gradleClasspath(ctx *gcp.Context) (string, error) {
+func gradleClasspath(ctx *gcp.Context, sourceDir string) (string, error) {
 	gradle, err := java.GradleCmd(ctx)
 	if err != nil {
 		return "", err
@@ -199,10 +321,11 @@ func gradleClasspath(ctx *gcp.Context) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	if err := os.Chmod("build.gradle", 0644); err != nil {
+	buildGradle := filepath.Join(sourceDir, "build.gradle")
+	if err := os.Chmod(buildGradle, 0644); err != nil {
 		return "", gcp.InternalErrorf("making build.gradle writable: %v", err)
 	}
-	f, err := os.OpenFile("build.gradle", os.O_APPEND|os.O_WRONLY, 0600)
+	f, err := os.OpenFile(buildGradle, os.O_APPEND|os.O_WRONLY, 0600)
 	if err != nil {
 		return "", gcp.InternalErrorf("opening build.gradle for appending: %v", err)
 	}
@@ -212,12 +335,12 @@ func gradleClasspath(ctx *gcp.Context) (string, error) {
 	}
 
 	// Copy the dependencies of the function (`dependencies {...}` in build.gradle) into build/_javaFunctionDependencies.
-	if _, err := ctx.Exec([]string{gradle, "--quiet", "_javaFunctionCopyAllDependencies"}, gcp.WithUserAttribution); err != nil {
+	if _, err := ctx.Exec(gradleArgs(gradle, sourceDir, "--quiet", "_javaFunctionCopyAllDependencies"), gcp.WithUserAttribution); err != nil {
 		return "", err
 	}
 
 	// Extract the name of the target jar.
-	execResult, err := ctx.Exec([]string{gradle, "--quiet", "_javaFunctionPrintJarTarget"}, gcp.WithUserAttribution)
+	execResult, err := ctx.Exec(gradleArgs(gradle, sourceDir, "--quiet", "_javaFunctionPrintJarTarget"), gcp.WithUserAttribution)
 	if err != nil {
 		return "", err
 	}
@@ -232,13 +355,13 @@ func gradleClasspath(ctx *gcp.Context) (string, error) {
 
 	// The Functions Framework understands "*" to mean every jar file in that directory.
 	// So this classpath consists of the just-built jar and all of the dependency jars.
-	return fmt.Sprintf("%s:build/_javaFunctionDependencies/*", jarName), nil
+	return fmt.Sprintf("%s:%s", jarName, filepath.Join(sourceDir, "build", "_javaFunctionDependencies", "*")), nil
 }
 
-func installFunctionsFramework(ctx *gcp.Context, layer *libcnb.Layer) (string, error) {
+func installFunctionsFramework(ctx *gcp.Context, layer *libcnb.Layer, sourceDir string) (string, error) {
 
 	jars := []string{}
-	pomExists, err := ctx.FileExists("pom.xml")
+	pomExists, err := ctx.FileExists(sourceDir, "pom.xml")
 	if err != nil {
 		return "", err
 	}
@@ -248,28 +371,30 @@ func installFunctionsFramework(ctx *gcp.Context, layer *libcnb.Layer) (string, e
 			return "", err
 		}
 		// If the invoker was listed as a dependency in the pom.xml, copy it into target/_javaInvokerDependency.
-		if _, err := ctx.Exec([]string{
+		// The output directory is relative to the module's pom.xml.
+		if _, err := ctx.Exec(mvnArgs(
 			mvn,
+			sourceDir,
 			"--batch-mode",
 			"dependency:copy-dependencies",
 			"-DoutputDirectory=target/_javaInvokerDependency",
 			"-DincludeGroupIds=com.google.cloud.functions",
 			"-DincludeArtifactIds=java-function-invoker",
-		}, gcp.WithUserAttribution); err != nil {
+		), gcp.WithUserAttribution); err != nil {
 			return "", err
 		}
-		jars, err = ctx.Glob("target/_javaInvokerDependency/java-function-invoker-*.jar")
+		jars, err = ctx.Glob(filepath.Join(sourceDir, "target/_javaInvokerDependency/java-function-invoker-*.jar"))
 		if err != nil {
 			return "", fmt.Errorf("finding java-function-invoker jar: %w", err)
 		}
 	} else {
-		buildGradleExists, err := ctx.FileExists("build.gradle")
+		buildGradleExists, err := ctx.FileExists(sourceDir, "build.gradle")
 		if err != nil {
 			return "", err
 		}
 		if buildGradleExists {
 			// If the invoker was listed as an implementation dependency it will have been copied to build/_javaFunctionDependencies.
-			jars, err = ctx.Glob("build/_javaFunctionDependencies/java-function-invoker-*.jar")
+			jars, err = ctx.Glob(filepath.Join(sourceDir, "build/_javaFunctionDependencies/java-function-invoker-*.jar"))
 			if err != nil {
 				return "", fmt.Errorf("finding java-function-invoker jar: %w", err)
 			}
@@ -15,9 +15,13 @@
 package main
 
 import (
+	"strings"
 	"testing"
 
 	buildpacktest "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/google/go-cmp/cmp"
 )
 
 func TestDetect(t *testing.T) {
@@ -44,3 +48,272 @@ func TestDetect(t *testing.T) {
 		})
 	}
 }
+
+func TestBuildScala(t *testing.T) {
+	assemblyPlugin := `addSbtPlugin("com.eed3si9n" % "sbt-assembly" % "2.3.0")`
+	testCases := []struct {
+		name         string
+		files        map[string]string
+		wantExitCode int // 0 if unspecified
+		wantCommands []string
+	}{
+		{
+			name: "assembled jar",
+			files: map[string]string{
+				"build.sbt":           "",
+				"project/plugins.sbt": assemblyPlugin,
+				"target/scala-3.3.4/fn-assembly-0.1.0.jar": "",
+				"target/scala-3.3.4/fn_3-0.1.0.jar":        "",
+			},
+			wantCommands: []string{
+				"sbt -batch assembly",
+				"javap -classpath target/scala-3.3.4/fn-assembly-0.1.0.jar HelloWorld",
+			},
+		},
+		{
+			name: "missing assembly plugin",
+			files: map[string]string{
+				"build.sbt": "",
+			},
+			wantExitCode: 1,
+		},
+		{
+			name: "no assembled jar",
+			files: map[string]string{
+				"build.sbt":           "",
+				"project/plugins.sbt": assemblyPlugin,
+			},
+			wantExitCode: 1,
+			wantCommands: []string{
+				"sbt -batch assembly",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := []buildpacktest.Option{
+				buildpacktest.WithTestName(tc.name),
+				buildpacktest.WithFiles(tc.files),
+				buildpacktest.WithEnvs("GOOGLE_FUNCTION_TARGET=HelloWorld"),
+				buildpacktest.WithExecMocks(
+					mockprocess.New(`command -v sbt`, mockprocess.WithStdout("/usr/bin/sbt")),
+				),
+			}
+			result, err := buildpacktest.RunBuild(t, buildFn, opts...)
+			if err != nil && tc.wantExitCode == 0 {
+				t.Fatalf("error running build: %v, result: %#v", err, result)
+			}
+			if result.ExitCode != tc.wantExitCode {
+				t.Errorf("build exit code mismatch, got: %d, want: %d", result.ExitCode, tc.wantExitCode)
+			}
+			for _, cmd := range tc.wantCommands {
+				if !result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to be executed, but it was not", cmd)
+				}
+			}
+		})
+	}
+}
+
+func TestBuildFunctionSource(t *testing.T) {
+	testCases := []struct {
+		name         string
+		files        map[string]string
+		env          []string
+		wantExitCode int // 0 if unspecified
+		wantCommands []string
+	}{
+		{
+			name: "maven root module by default",
+			files: map[string]string{
+				"pom.xml":            "",
+				"target/fn.jar":      "",
+				"hello/pom.xml":      "",
+				"hello/target/x.jar": "",
+			},
+			wantCommands: []string{
+				`"mvn --batch-mode dependency:copy-dependencies -Dmdep.prependGroupId`,
+				`javap -classpath target/fn.jar:target/dependency/\* HelloWorld`,
+			},
+		},
+		{
+			name: "maven sub-module",
+			files: map[string]string{
+				"pom.xml":                       "",
+				"functions/hello/pom.xml":       "",
+				"functions/hello/target/fn.jar": "",
+			},
+			env: []string{"GOOGLE_FUNCTION_SOURCE=functions/hello/"},
+			wantCommands: []string{
+				"mvn -f functions/hello/pom.xml --batch-mode dependency:copy-dependencies -Dmdep.prependGroupId",
+				"mvn -f functions/hello/pom.xml help:evaluate",
+				"mvn -f functions/hello/pom.xml --batch-mode dependency:copy-dependencies -DoutputDirectory=target/_javaInvokerDependency",
+				`javap -classpath functions/hello/target/fn.jar:functions/hello/target/dependency/\* HelloWorld`,
+			},
+		},
+		{
+			name: "sub-module output jar missing",
+			files: map[string]string{
+				"functions/hello/pom.xml": "",
+				"target/fn.jar":           "",
+			},
+			env:          []string{"GOOGLE_FUNCTION_SOURCE=functions/hello"},
+			wantExitCode: 1,
+		},
+		{
+			name: "directory without build file",
+			files: map[string]string{
+				"pom.xml":                 "",
+				"target/fn.jar":           "",
+				"functions/hello/Fn.java": "",
+			},
+			env:          []string{"GOOGLE_FUNCTION_SOURCE=functions/hello"},
+			wantExitCode: 1,
+		},
+		{
+			name: "directory outside application",
+			files: map[string]string{
+				"pom.xml":       "",
+				"target/fn.jar": "",
+			},
+			env:          []string{"GOOGLE_FUNCTION_SOURCE=../other"},
+			wantExitCode: 1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := []buildpacktest.Option{
+				buildpacktest.WithTestName(tc.name),
+				buildpacktest.WithFiles(tc.files),
+				buildpacktest.WithEnvs(append([]string{"GOOGLE_FUNCTION_TARGET=HelloWorld"}, tc.env...)...),
+				buildpacktest.WithExecMocks(
+					mockprocess.New(`help:evaluate`, mockprocess.WithStdout("fn")),
+				),
+			}
+			result, err := buildpacktest.RunBuild(t, buildFn, opts...)
+			if err != nil && tc.wantExitCode == 0 {
+				t.Fatalf("error running build: %v, result: %#v", err, result)
+			}
+			if result.ExitCode != tc.wantExitCode {
+				t.Errorf("build exit code mismatch, got: %d, want: %d", result.ExitCode, tc.wantExitCode)
+			}
+			for _, cmd := range tc.wantCommands {
+				if !result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to be executed, but it was not", cmd)
+				}
+			}
+		})
+	}
+}
+
+func TestBuildMultipleTargets(t *testing.T) {
+	files := map[string]string{
+		"pom.xml":       "",
+		"target/fn.jar": "",
+	}
+	testCases := []struct {
+		name         string
+		target       string
+		help         string
+		wantExitCode int // 0 if unspecified
+		wantCommands []string
+		wantOutput   string
+	}{
+		{
+			name:   "single target",
+			target: "HelloWorld",
+			wantCommands: []string{
+				`javap -classpath target/fn.jar:target/dependency/\* HelloWorld`,
+			},
+		},
+		{
+			name:   "multiple targets",
+			target: "com.example.MyHttpFunc, com.example.MyCloudEventFunc",
+			help:   "  --target <arg>   the name of a function to serve, can be repeated to serve multiple functions",
+			wantCommands: []string{
+				`javap -classpath target/fn.jar:target/dependency/\* com.example.MyHttpFunc`,
+				`javap -classpath target/fn.jar:target/dependency/\* com.example.MyCloudEventFunc`,
+				`java -jar .*functions-framework.jar --help`,
+			},
+		},
+		{
+			name:         "invoker with a single target",
+			target:       "MyHttpFunc,MyCloudEventFunc",
+			help:         "  --target <arg>   the name of the function to serve",
+			wantExitCode: 1,
+			wantOutput:   "serves a single target",
+		},
+		{
+			name:         "empty target",
+			target:       "MyHttpFunc,,MyCloudEventFunc",
+			wantExitCode: 1,
+			wantOutput:   "function targets must not be empty",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := []buildpacktest.Option{
+				buildpacktest.WithTestName(tc.name),
+				buildpacktest.WithFiles(files),
+				buildpacktest.WithEnvs("GOOGLE_FUNCTION_TARGET=" + tc.target),
+				buildpacktest.WithExecMocks(
+					mockprocess.New(`help:evaluate`, mockprocess.WithStdout("fn")),
+					mockprocess.New(`--help$`, mockprocess.WithStdout(tc.help)),
+				),
+			}
+			result, err := buildpacktest.RunBuild(t, buildFn, opts...)
+			if err != nil && tc.wantExitCode == 0 {
+				t.Fatalf("error running build: %v, result: %#v", err, result)
+			}
+			if result.ExitCode != tc.wantExitCode {
+				t.Errorf("build exit code mismatch, got: %d, want: %d", result.ExitCode, tc.wantExitCode)
+			}
+			for _, cmd := range tc.wantCommands {
+				if !result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to be executed, but it was not", cmd)
+				}
+			}
+			if !strings.Contains(result.Output, tc.wantOutput) {
+				t.Errorf("build output does not contain %q, got:\n%s", tc.wantOutput, result.Output)
+			}
+		})
+	}
+}
+
+func TestAddWebProcess(t *testing.T) {
+	testCases := []struct {
+		name    string
+		targets []string
+		want    []string
+	}{
+		{
+			name:    "single target",
+			targets: []string{"HelloWorld"},
+			want:    []string{"/layers/ff/launch.sh", "java", "-jar", "/layers/ff/ff.jar", "--classpath", "/workspace/target/classes", "--debug"},
+		},
+		{
+			name:    "multiple targets",
+			targets: []string{"MyHttpFunc", "MyCloudEventFunc"},
+			want:    []string{"/layers/ff/launch.sh", "java", "-jar", "/layers/ff/ff.jar", "--classpath", "/workspace/target/classes", "--target", "MyHttpFunc", "--target", "MyCloudEventFunc", "--debug"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("GOOGLE_FUNCTIONS_FRAMEWORK_ARGS", "--debug")
+			ctx := gcp.NewContext()
+
+			if err := addWebProcess(ctx, "/layers/ff/launch.sh", "/layers/ff/ff.jar", "/workspace/target/classes", tc.targets); err != nil {
+				t.Fatalf("addWebProcess() got error: %v", err)
+			}
+
+			processes := ctx.Processes()
+			if len(processes) != 1 {
+				t.Fatalf("addWebProcess() added %d processes, want 1", len(processes))
+			}
+			got := append([]string{processes[0].Command}, processes[0].Arguments...)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("addWebProcess() command mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
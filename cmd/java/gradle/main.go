@@ -17,10 +17,13 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"net/http"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/devmode"
@@ -28,6 +31,7 @@ import (
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/fileutil"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/java"
+	"github.com/buildpacks/libcnb"
 )
 
 const (
@@ -35,8 +39,24 @@ const (
 	gradleLayer     = "gradle"
 	cacheLayer      = "cache"
 	versionKey      = "version"
+	// gradlePropertiesHashKey is the cache layer metadata key of the gradle.properties hash.
+	gradlePropertiesHashKey = "gradle-properties-sha"
+	gradleProperties        = "gradle.properties"
+
+	// reproducibleInitScript configures the archive tasks of all projects, including the jar and
+	// bootJar tasks, to build archives that do not depend on file timestamps or file system order.
+	reproducibleInitScript = `allprojects {
+    tasks.withType(AbstractArchiveTask).configureEach {
+        preserveFileTimestamps = false
+        reproducibleFileOrder = true
+    }
+}
+`
 )
 
+// gradleDaemonRegexp matches a gradle.properties line that enables the Gradle daemon.
+var gradleDaemonRegexp = regexp.MustCompile(`(?m)^\s*org\.gradle\.daemon\s*[=:]\s*true\s*$`)
+
 func main() {
 	gcp.Main(detectFn, buildFn)
 }
@@ -61,11 +81,31 @@ func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
 }
 
 func buildFn(ctx *gcp.Context) error {
+	if prebuilt, err := java.UsePrebuiltJar(ctx, "Gradle"); err != nil || prebuilt {
+		return err
+	}
+
+	offline, err := java.Offline()
+	if err != nil {
+		return err
+	}
+	publish, err := env.IsPresentAndTrue(java.GradlePublishEnv)
+	if err != nil {
+		return err
+	}
+	if publish && offline {
+		return gcp.UserErrorf("%s cannot be used with %s since publishing requires network access", java.GradlePublishEnv, java.OfflineEnv)
+	}
+
 	gradleCachedRepo, err := ctx.Layer(cacheLayer, gcp.CacheLayer, gcp.LaunchLayerIfDevMode)
 	if err != nil {
 		return fmt.Errorf("creating %v layer: %w", cacheLayer, err)
 	}
 
+	// Check gradle.properties first since clearing the layer also clears its expiration.
+	if err := checkGradlePropertiesCache(ctx, gradleCachedRepo, offline); err != nil {
+		return err
+	}
 	if err := java.CheckCacheExpiration(ctx, gradleCachedRepo); err != nil {
 		return fmt.Errorf("validating the cache: %w", err)
 	}
@@ -80,32 +120,97 @@ func buildFn(ctx *gcp.Context) error {
 		return err
 	}
 
-	gradle, err := provisionOrDetectGradle(ctx)
+	gradle, err := provisionOrDetectGradle(ctx, offline)
 	if err != nil {
 		return err
 	}
 
-	command := []string{gradle, "clean", "assemble", "-x", "test", "--build-cache"}
+	runTests, err := java.RunTests()
+	if err != nil {
+		return err
+	}
+	tasks, err := java.EnvArgs(java.GradleTasks)
+	if err != nil {
+		return err
+	}
+	if len(tasks) == 0 {
+		tasks = []string{"assemble"}
+		if runTests {
+			// The assemble task does not depend on the tests.
+			tasks = append(tasks, "test")
+		}
+	}
+	command := append([]string{gradle, "clean"}, tasks...)
+	if !runTests {
+		command = append(command, "-x", "test")
+	}
+	command = append(command, "--build-cache")
 
 	if buildArgs := os.Getenv(env.BuildArgs); buildArgs != "" {
 		if strings.Contains(buildArgs, "project-cache-dir") {
 			ctx.Warnf("Detected project-cache-dir property set in GOOGLE_BUILD_ARGS. Dependency caching may not work properly.")
 		}
-		command = append(command, buildArgs)
+		args, err := java.EnvArgs(env.BuildArgs)
+		if err != nil {
+			return err
+		}
+		command = append(command, args...)
 	}
 
-	if gradleBuildArgs := os.Getenv(java.GradleBuildArgs); gradleBuildArgs != "" {
-		command = append([]string{gradle}, strings.Fields(gradleBuildArgs)...)
+	if os.Getenv(java.GradleBuildArgs) != "" {
+		args, err := java.EnvArgs(java.GradleBuildArgs)
+		if err != nil {
+			return err
+		}
+		command = append([]string{gradle}, args...)
 	}
 
 	if !ctx.Debug() && !devmode.Enabled(ctx) {
 		command = append(command, "--quiet")
 	}
 
+	if offline {
+		command = append(command, "--offline")
+	}
+
+	reproducible, err := env.IsReproducibleBuild()
+	if err != nil {
+		return err
+	}
+	if reproducible {
+		initScript, err := writeReproducibleInitScript(ctx)
+		if err != nil {
+			return err
+		}
+		command = append(command, "--init-script", initScript)
+	}
+
+	daemon, err := daemonEnabled(ctx)
+	if err != nil {
+		return err
+	}
+	if daemon {
+		ctx.Warnf("Ignoring org.gradle.daemon=true in %s: the Gradle daemon is disabled in the build container to prevent daemons from outliving the build.", gradleProperties)
+		command = append(command, "--no-daemon")
+	}
+
 	if _, err := ctx.Exec(command, gcp.WithUserAttribution); err != nil {
+		if offline {
+			return java.OfflineBuildError(err)
+		}
 		return err
 	}
 
+	if publish {
+		publishCommand := []string{gradle, "publish"}
+		if daemon {
+			publishCommand = append(publishCommand, "--no-daemon")
+		}
+		if err := java.PublishGradle(ctx, publishCommand, homeGradle); err != nil {
+			return err
+		}
+	}
+
 	// Store the build steps in a script to be run on each file change.
 	if devmode.Enabled(ctx) {
 		devmode.WriteBuildScript(ctx, gradleCachedRepo.Path, "~/.gradle", command)
@@ -114,26 +219,110 @@ func buildFn(ctx *gcp.Context) error {
 	return nil
 }
 
-func provisionOrDetectGradle(ctx *gcp.Context) (string, error) {
+// writeReproducibleInitScript writes the Gradle init script that makes archives reproducible to a
+// temporary directory and returns its path.
+func writeReproducibleInitScript(ctx *gcp.Context) (string, error) {
+	dir, err := ctx.TempDir("gradle")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, "reproducible.gradle")
+	if err := ctx.WriteFile(path, []byte(reproducibleInitScript), 0644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// gradlePropertiesHash returns the hash of the gradle.properties file, which declares the JVM args
+// and other properties of the Gradle build, or an empty string if the file does not exist.
+func gradlePropertiesHash(ctx *gcp.Context) (string, error) {
+	path := filepath.Join(ctx.ApplicationRoot(), gradleProperties)
+	exists, err := ctx.FileExists(path)
+	if err != nil || !exists {
+		return "", err
+	}
+	content, err := ctx.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// checkGradlePropertiesCache clears the cache layer if gradle.properties changed since the layer was
+// cached, and records the hash of the current file. Offline builds keep the cache and the previous
+// hash since they can only resolve dependencies from the cache.
+func checkGradlePropertiesCache(ctx *gcp.Context, l *libcnb.Layer, offline bool) error {
+	hash, err := gradlePropertiesHash(ctx)
+	if err != nil {
+		return err
+	}
+	prevHash := ctx.GetMetadata(l, gradlePropertiesHashKey)
+	if hash == prevHash {
+		return nil
+	}
+	// Layers cached before the hash was recorded are kept.
+	if _, recorded := l.Metadata[gradlePropertiesHashKey]; recorded {
+		if offline {
+			ctx.Logf("%s changed, keeping the Gradle cache because %s is set.", gradleProperties, java.OfflineEnv)
+			return nil
+		}
+		ctx.CacheMiss(l.Name)
+		ctx.Logf("%s changed, clearing the Gradle cache.", gradleProperties)
+		if err := ctx.ClearLayer(l); err != nil {
+			return fmt.Errorf("clearing layer %q: %w", l.Name, err)
+		}
+	}
+	ctx.SetMetadata(l, gradlePropertiesHashKey, hash)
+	return nil
+}
+
+// daemonEnabled returns true if gradle.properties enables the Gradle daemon.
+func daemonEnabled(ctx *gcp.Context) (bool, error) {
+	path := filepath.Join(ctx.ApplicationRoot(), gradleProperties)
+	exists, err := ctx.FileExists(path)
+	if err != nil || !exists {
+		return false, err
+	}
+	content, err := ctx.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	return gradleDaemonRegexp.Match(content), nil
+}
+
+func provisionOrDetectGradle(ctx *gcp.Context, offline bool) (string, error) {
+	pinned, err := java.PinnedToolVersion(java.GradleWrapperVersionEnv)
+	if err != nil {
+		return "", err
+	}
 	gradlewExists, err := ctx.FileExists("gradlew")
 	if err != nil {
 		return "", err
 	}
 	if gradlewExists {
+		if err := java.CheckGradleWrapperVersion(ctx, pinned); err != nil {
+			return "", err
+		}
 		// With CRLF endings, the "\r" gets seen as part of the shebang target, which doesn't exist.
 		if err := fileutil.EnsureUnixLineEndings("gradlew"); err != nil {
 			return "", fmt.Errorf("ensuring unix newline characters: %w", err)
 		}
 		return "./gradlew", nil
 	}
-	installed, err := gradleInstalled(ctx)
-	if err != nil {
-		return "", err
-	}
-	if installed {
-		return "gradle", nil
+	if pinned != "" {
+		// The version of an installed gradle is unknown, so the pinned version is always installed.
+		ctx.Logf("Using Gradle v%s set by %s.", pinned, java.GradleWrapperVersionEnv)
+	} else {
+		installed, err := gradleInstalled(ctx)
+		if err != nil {
+			return "", err
+		}
+		if installed {
+			return "gradle", nil
+		}
 	}
-	gradle, err := installGradle(ctx)
+	gradle, err := installGradle(ctx, offline, pinned)
 	if err != nil {
 		return "", fmt.Errorf("installing Gradle: %w", err)
 	}
@@ -148,18 +337,31 @@ func gradleInstalled(ctx *gcp.Context) (bool, error) {
 	return result.Stdout != "", nil
 }
 
-// installGradle installs Gradle and returns the path of the gradle binary
-func installGradle(ctx *gcp.Context) (string, error) {
+// installGradle installs Gradle and returns the path of the gradle binary. It installs the pinned
+// version if it is not empty, or the latest version otherwise. In offline mode, the cached
+// installation is used regardless of its version unless a version is pinned.
+func installGradle(ctx *gcp.Context, offline bool, pinned string) (string, error) {
 	gradlel, err := ctx.Layer(gradleLayer, gcp.CacheLayer, gcp.BuildLayer, gcp.LaunchLayerIfDevMode)
 	if err != nil {
 		return "", fmt.Errorf("creating %v layer: %w", gradleLayer, err)
 	}
 
 	metaVersion := ctx.GetMetadata(gradlel, versionKey)
+	if offline {
+		if metaVersion == "" || (pinned != "" && metaVersion != pinned) {
+			return "", gcp.UserErrorf("Gradle is not cached and cannot be downloaded because %s is set, add the Gradle wrapper (gradlew) to the project or run a build without %s first", java.OfflineEnv, java.OfflineEnv)
+		}
+		ctx.CacheHit(gradleLayer)
+		ctx.Logf("Using cached Gradle v%s in offline mode.", metaVersion)
+		return filepath.Join(gradlel.Path, "bin", "gradle"), nil
+	}
 	// Check the metadata in the cache layer to determine if we need to proceed.
-	gradleVersion, err := java.GetLatestGradleVersion()
-	if err != nil {
-		return "", fmt.Errorf("getting latest gradle version: %w", err)
+	gradleVersion := pinned
+	if gradleVersion == "" {
+		gradleVersion, err = java.GetLatestGradleVersion()
+		if err != nil {
+			return "", fmt.Errorf("getting latest gradle version: %w", err)
+		}
 	}
 	if gradleVersion == metaVersion {
 		ctx.CacheHit(gradleLayer)
@@ -179,6 +381,9 @@ func installGradle(ctx *gcp.Context) (string, error) {
 		return "", err
 	}
 	if code != http.StatusOK {
+		if pinned != "" {
+			return "", gcp.UserErrorf("Gradle version %s set by %s does not exist at %s (status %d)", gradleVersion, java.GradleWrapperVersionEnv, downloadURL, code)
+		}
 		return "", fmt.Errorf("Gradle version %s does not exist at %s (status %d)", gradleVersion, downloadURL, code)
 	}
 
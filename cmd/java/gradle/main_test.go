@@ -16,11 +16,17 @@ package main
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	buildpacktest "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
 	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/java"
+	"github.com/buildpacks/libcnb"
 )
 
 func TestDetect(t *testing.T) {
@@ -81,6 +87,156 @@ func TestBuildCommand(t *testing.T) {
 				"gradle clean assemble -x test --build-cache",
 			},
 		},
+		{
+			name: "daemon enabled in gradle.properties",
+			app:  "gradle_micronaut",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^bash -c command -v gradle || true`, mockprocess.WithStdout("Gradle 0.0.0")),
+			},
+			files: map[string]string{
+				"gradle.properties": "org.gradle.jvmargs=-Xmx2g -XX:+UseG1GC\norg.gradle.daemon = true\n",
+			},
+			wantCommands: []string{
+				"gradle clean assemble -x test --build-cache --no-daemon",
+			},
+		},
+		{
+			name: "daemon disabled in gradle.properties",
+			app:  "gradle_micronaut",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^bash -c command -v gradle || true`, mockprocess.WithStdout("Gradle 0.0.0")),
+			},
+			files: map[string]string{
+				"gradle.properties": "org.gradle.daemon=false\n",
+			},
+			doNotWantCommands: []string{
+				"--no-daemon",
+			},
+		},
+		{
+			name: "offline",
+			app:  "gradle_micronaut",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^bash -c command -v gradle || true`, mockprocess.WithStdout("Gradle 0.0.0")),
+			},
+			envs: []string{java.OfflineEnv + "=true"},
+			wantCommands: []string{
+				"gradle clean assemble -x test --build-cache --offline",
+			},
+		},
+		{
+			name: "offline with gradle build argument",
+			app:  "gradle_micronaut",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^bash -c command -v gradle || true`, mockprocess.WithStdout("Gradle 0.0.0")),
+			},
+			envs: []string{java.OfflineEnv + "=true", fmt.Sprintf("%s=clean assemble", java.GradleBuildArgs)},
+			wantCommands: []string{
+				"gradle clean assemble --offline",
+			},
+		},
+		{
+			name: "reproducible",
+			app:  "gradle_micronaut",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^bash -c command -v gradle || true`, mockprocess.WithStdout("Gradle 0.0.0")),
+			},
+			envs: []string{env.ReproducibleBuild + "=true"},
+			wantCommands: []string{
+				`gradle clean assemble -x test --build-cache --init-script \S+/reproducible.gradle`,
+			},
+		},
+		{
+			name: "not reproducible",
+			app:  "gradle_micronaut",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^bash -c command -v gradle || true`, mockprocess.WithStdout("Gradle 0.0.0")),
+			},
+			doNotWantCommands: []string{
+				"--init-script",
+			},
+		},
+		{
+			name: "run tests",
+			app:  "gradle_micronaut",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^bash -c command -v gradle || true`, mockprocess.WithStdout("Gradle 0.0.0")),
+			},
+			envs: []string{java.RunTestsEnv + "=true"},
+			wantCommands: []string{
+				"gradle clean assemble test --build-cache",
+			},
+			doNotWantCommands: []string{
+				"-x test",
+			},
+		},
+		{
+			name: "gradle tasks",
+			app:  "gradle_micronaut",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^bash -c command -v gradle || true`, mockprocess.WithStdout("Gradle 0.0.0")),
+			},
+			envs: []string{java.GradleTasks + "=:app:bootJar :worker:shadowJar"},
+			wantCommands: []string{
+				"gradle clean :app:bootJar :worker:shadowJar -x test --build-cache",
+			},
+		},
+		{
+			name: "run tests with gradle tasks",
+			app:  "gradle_micronaut",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^bash -c command -v gradle || true`, mockprocess.WithStdout("Gradle 0.0.0")),
+			},
+			envs: []string{java.RunTestsEnv + "=true", java.GradleTasks + "=build"},
+			wantCommands: []string{
+				"gradle clean build --build-cache",
+			},
+		},
+		{
+			name: "quoted build args",
+			app:  "gradle_micronaut",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^bash -c command -v gradle || true`, mockprocess.WithStdout("Gradle 0.0.0")),
+			},
+			envs: []string{`GOOGLE_BUILD_ARGS=-Pgreeting="hello world" --info`},
+			wantCommands: []string{
+				"gradle clean assemble -x test --build-cache -Pgreeting=hello world --info",
+			},
+		},
+		{
+			name: "publish",
+			app:  "gradle_micronaut",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^bash -c command -v gradle || true`, mockprocess.WithStdout("Gradle 0.0.0")),
+			},
+			// Layers are created relative to the application directory in tests, so HOME must be too
+			// for ~/.gradle to link to the cache layer.
+			envs: []string{java.GradlePublishEnv + "=true", "CLOUDSDK_AUTH_ACCESS_TOKEN=ya29.token", "HOME=."},
+			wantCommands: []string{
+				"gradle clean assemble -x test --build-cache",
+				`"gradle publish"`,
+			},
+		},
+		{
+			name: "not publish",
+			app:  "gradle_micronaut",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^bash -c command -v gradle || true`, mockprocess.WithStdout("Gradle 0.0.0")),
+			},
+			doNotWantCommands: []string{
+				"gradle publish",
+			},
+		},
+		{
+			name: "not offline",
+			app:  "gradle_micronaut",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^bash -c command -v gradle || true`, mockprocess.WithStdout("Gradle 0.0.0")),
+			},
+			doNotWantCommands: []string{
+				"--offline",
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -90,6 +246,7 @@ func TestBuildCommand(t *testing.T) {
 				buildpacktest.WithApp(tc.app),
 				buildpacktest.WithEnvs(tc.envs...),
 				buildpacktest.WithExecMocks(tc.mocks...),
+				buildpacktest.WithFiles(tc.files),
 			}
 
 			opts = append(opts, tc.opts...)
@@ -112,3 +269,220 @@ func TestBuildCommand(t *testing.T) {
 		})
 	}
 }
+
+func TestCheckGradlePropertiesCache(t *testing.T) {
+	testCases := []struct {
+		name        string
+		properties  string
+		prevEntries map[string]any
+		// recordCurrent records the hash of the current gradle.properties in prevEntries.
+		recordCurrent bool
+		offline       bool
+		wantCleared   bool
+		// wantHash is the recorded hash, defaults to the hash of the current gradle.properties.
+		wantHash string
+	}{
+		{
+			name:        "hash not recorded",
+			properties:  "org.gradle.jvmargs=-Xmx2g",
+			prevEntries: map[string]any{},
+		},
+		{
+			name:          "unchanged",
+			properties:    "org.gradle.jvmargs=-Xmx2g",
+			prevEntries:   map[string]any{},
+			recordCurrent: true,
+		},
+		{
+			name:        "changed",
+			properties:  "org.gradle.jvmargs=-Xmx4g",
+			prevEntries: map[string]any{gradlePropertiesHashKey: "old-hash"},
+			wantCleared: true,
+		},
+		{
+			name:        "changed offline",
+			properties:  "org.gradle.jvmargs=-Xmx4g",
+			prevEntries: map[string]any{gradlePropertiesHashKey: "old-hash"},
+			offline:     true,
+			wantHash:    "old-hash",
+		},
+		{
+			name:        "removed",
+			prevEntries: map[string]any{gradlePropertiesHashKey: "old-hash"},
+			wantCleared: true,
+		},
+		{
+			name:        "no gradle.properties",
+			prevEntries: map[string]any{gradlePropertiesHashKey: ""},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			root := t.TempDir()
+			if tc.properties != "" {
+				if err := os.WriteFile(filepath.Join(root, gradleProperties), []byte(tc.properties), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(root))
+			want, err := gradlePropertiesHash(ctx)
+			if err != nil {
+				t.Fatalf("gradlePropertiesHash() got error: %v", err)
+			}
+			if tc.recordCurrent {
+				tc.prevEntries[gradlePropertiesHashKey] = want
+			}
+			l := &libcnb.Layer{Name: cacheLayer, Path: t.TempDir(), Metadata: tc.prevEntries}
+			cached := filepath.Join(l.Path, "cached")
+			if err := os.WriteFile(cached, nil, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := checkGradlePropertiesCache(ctx, l, tc.offline); err != nil {
+				t.Fatalf("checkGradlePropertiesCache() got error: %v", err)
+			}
+			_, err = os.Stat(cached)
+			if gotCleared := os.IsNotExist(err); gotCleared != tc.wantCleared {
+				t.Errorf("checkGradlePropertiesCache() cleared layer = %t, want %t", gotCleared, tc.wantCleared)
+			}
+			if tc.wantHash != "" {
+				want = tc.wantHash
+			}
+			if got := ctx.GetMetadata(l, gradlePropertiesHashKey); got != want {
+				t.Errorf("checkGradlePropertiesCache() recorded hash %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestBuildPrebuilt(t *testing.T) {
+	testCases := []struct {
+		name         string
+		envs         []string
+		files        map[string]string
+		wantExitCode int // 0 if unspecified
+		wantCommands []string
+	}{
+		{
+			name:         "prebuilt jar missing",
+			envs:         []string{"GOOGLE_PREBUILT=true"},
+			files:        map[string]string{"build.gradle": ""},
+			wantExitCode: 1,
+		},
+		{
+			name:         "not prebuilt",
+			files:        map[string]string{"build.gradle": ""},
+			wantCommands: []string{"gradle clean assemble"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := buildpacktest.RunBuild(t, buildFn,
+				buildpacktest.WithTestName(tc.name),
+				buildpacktest.WithEnvs(tc.envs...),
+				buildpacktest.WithFiles(tc.files),
+				buildpacktest.WithExecMocks(mockprocess.New(`^bash -c command -v gradle || true`, mockprocess.WithStdout("/usr/bin/gradle"))),
+			)
+			if err != nil && tc.wantExitCode == 0 {
+				t.Fatalf("error running build: %v, logs: %s", err, result.Output)
+			}
+			if result.ExitCode != tc.wantExitCode {
+				t.Errorf("build exit code mismatch, got: %d, want: %d", result.ExitCode, tc.wantExitCode)
+			}
+			for _, cmd := range tc.wantCommands {
+				if !result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to be executed, but it was not, build output: %s", cmd, result.Output)
+				}
+			}
+		})
+	}
+}
+
+func TestBuildWrapperVersion(t *testing.T) {
+	wrapperFiles := map[string]string{
+		"gradlew": "#!/bin/sh\n",
+		"gradle/wrapper/gradle-wrapper.properties": "distributionUrl=https\\://services.gradle.org/distributions/gradle-8.5-bin.zip\n",
+	}
+	testCases := []struct {
+		name              string
+		envs              []string
+		files             map[string]string
+		wantExitCode      int // 0 if unspecified
+		wantCommands      []string
+		doNotWantCommands []string
+		wantOutput        string
+	}{
+		{
+			name:         "wrapper version matches",
+			envs:         []string{java.GradleWrapperVersionEnv + "=8.5"},
+			files:        wrapperFiles,
+			wantCommands: []string{"./gradlew clean"},
+			wantOutput:   "Verified that gradlew uses version 8.5",
+		},
+		{
+			name:              "wrapper version mismatch",
+			envs:              []string{java.GradleWrapperVersionEnv + "=8.4.1"},
+			files:             wrapperFiles,
+			wantExitCode:      1,
+			doNotWantCommands: []string{"./gradlew clean"},
+			wantOutput:        "gradlew uses version 8.5 according to gradle/wrapper/gradle-wrapper.properties, but GOOGLE_GRADLE_WRAPPER_VERSION=8.4.1",
+		},
+		{
+			name:         "wrapper version not found",
+			envs:         []string{java.GradleWrapperVersionEnv + "=8.5"},
+			files:        map[string]string{"gradlew": "#!/bin/sh\n"},
+			wantExitCode: 1,
+			wantOutput:   "could not be found in the distributionUrl of gradle/wrapper/gradle-wrapper.properties",
+		},
+		{
+			name:         "wrapper without pinned version",
+			files:        wrapperFiles,
+			wantCommands: []string{"./gradlew clean"},
+		},
+		{
+			name: "pinned version without wrapper",
+			envs: []string{java.GradleWrapperVersionEnv + "=8.4.1"},
+			// Seed the cached installation of the pinned version.
+			files:             map[string]string{"gradle.toml": "[metadata]\n  version = \"8.4.1\"\n"},
+			wantCommands:      []string{"gradle/bin/gradle clean"},
+			doNotWantCommands: []string{"command -v gradle"},
+			wantOutput:        "Using Gradle v8.4.1 set by GOOGLE_GRADLE_WRAPPER_VERSION.",
+		},
+		{
+			name:         "invalid pinned version",
+			envs:         []string{java.GradleWrapperVersionEnv + "=1.0; curl example.com"},
+			wantExitCode: 1,
+			wantOutput:   "invalid version",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := buildpacktest.RunBuild(t, buildFn,
+				buildpacktest.WithTestName(tc.name),
+				buildpacktest.WithApp("gradle_micronaut"),
+				buildpacktest.WithEnvs(tc.envs...),
+				buildpacktest.WithFiles(tc.files),
+				buildpacktest.WithExecMocks(mockprocess.New(`^bash -c command -v gradle || true`, mockprocess.WithStdout("Gradle 0.0.0"))),
+			)
+			if err != nil && tc.wantExitCode == 0 {
+				t.Fatalf("error running build: %v, logs: %s", err, result.Output)
+			}
+			if result.ExitCode != tc.wantExitCode {
+				t.Errorf("build exit code mismatch, got: %d, want: %d", result.ExitCode, tc.wantExitCode)
+			}
+			for _, cmd := range tc.wantCommands {
+				if !result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to be executed, but it was not, build output: %s", cmd, result.Output)
+				}
+			}
+			for _, cmd := range tc.doNotWantCommands {
+				if result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q not to be executed, but it was, build output: %s", cmd, result.Output)
+				}
+			}
+			if !strings.Contains(result.Output, tc.wantOutput) {
+				t.Errorf("build output = %q, want output containing %q", result.Output, tc.wantOutput)
+			}
+		})
+	}
+}
@@ -22,6 +22,8 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/devmode"
@@ -40,6 +42,16 @@ const (
 	versionKey   = "version"
 )
 
+var (
+	// mavenProfileRegexp matches a valid Maven profile ID.
+	mavenProfileRegexp = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+	// mavenPropertyRegexp matches a valid name of a property set by GOOGLE_MAVEN_PROPERTIES.
+	mavenPropertyRegexp = regexp.MustCompile(`^[a-zA-Z0-9._-]+$`)
+	// unsafePropertyValueRegexp matches the characters that are not allowed in the value of a
+	// property set by GOOGLE_MAVEN_PROPERTIES, since they are interpreted by shells.
+	unsafePropertyValueRegexp = regexp.MustCompile("[`$\\\\\"';&|<>(){}\\x00-\\x1f\\x7f]")
+)
+
 func main() {
 	gcp.Main(detectFn, buildFn)
 }
@@ -63,6 +75,15 @@ func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
 }
 
 func buildFn(ctx *gcp.Context) error {
+	if prebuilt, err := java.UsePrebuiltJar(ctx, "Maven"); err != nil || prebuilt {
+		return err
+	}
+
+	offline, err := java.Offline()
+	if err != nil {
+		return err
+	}
+
 	m2CachedRepo, err := ctx.Layer(m2Layer, gcp.CacheLayer, gcp.LaunchLayerIfDevMode)
 	if err != nil {
 		return fmt.Errorf("creating %v layer: %w", m2Layer, err)
@@ -87,12 +108,20 @@ func buildFn(ctx *gcp.Context) error {
 		return err
 	}
 
-	mvn, err := provisionOrDetectMaven(ctx)
+	mvn, err := provisionOrDetectMaven(ctx, offline)
 	if err != nil {
 		return err
 	}
 
-	command := []string{mvn, "clean", "package", "--batch-mode", "-DskipTests", "-Dhttp.keepAlive=false"}
+	runTests, err := java.RunTests()
+	if err != nil {
+		return err
+	}
+	command := []string{mvn, "clean", "package", "--batch-mode"}
+	if !runTests {
+		command = append(command, "-DskipTests")
+	}
+	command = append(command, "-Dhttp.keepAlive=false")
 
 	pomPath, err := pomFilePath(ctx)
 	if err != nil {
@@ -106,11 +135,45 @@ func buildFn(ctx *gcp.Context) error {
 		if strings.Contains(buildArgs, "maven.repo.local") {
 			ctx.Warnf("Detected maven.repo.local property set in GOOGLE_BUILD_ARGS. Maven caching may not work properly.")
 		}
-		command = append(command, strings.Fields(buildArgs)...)
+		args, err := java.EnvArgs(env.BuildArgs)
+		if err != nil {
+			return err
+		}
+		command = append(command, args...)
+	}
+
+	if os.Getenv(java.MavenBuildArgs) != "" {
+		args, err := java.EnvArgs(java.MavenBuildArgs)
+		if err != nil {
+			return err
+		}
+		command = append([]string{mvn}, args...)
+	}
+
+	args, err := activateProfiles(ctx, command[1:])
+	if err != nil {
+		return err
+	}
+	command = append([]string{mvn}, args...)
+
+	properties, err := mavenProperties(ctx)
+	if err != nil {
+		return err
+	}
+	command = append(command, properties...)
+
+	reproducible, err := env.IsReproducibleBuild()
+	if err != nil {
+		return err
+	}
+	if reproducible {
+		// Overrides the property if it is set in pom.xml so that the archiver plugins normalize the
+		// timestamps of the entries of the jars they build.
+		command = append(command, "-Dproject.build.outputTimestamp="+java.ReproducibleTimestamp)
 	}
 
-	if mvnBuildArgs := os.Getenv(java.MavenBuildArgs); mvnBuildArgs != "" {
-		command = append([]string{mvn}, strings.Fields(mvnBuildArgs)...)
+	if offline {
+		command = append(command, "--offline")
 	}
 
 	if !ctx.Debug() && !devmode.Enabled(ctx) {
@@ -118,6 +181,9 @@ func buildFn(ctx *gcp.Context) error {
 	}
 
 	if _, err := ctx.Exec(command, gcp.WithStdoutTail, gcp.WithUserAttribution); err != nil {
+		if offline {
+			return java.OfflineBuildError(err)
+		}
 		return err
 	}
 
@@ -129,26 +195,122 @@ func buildFn(ctx *gcp.Context) error {
 	return nil
 }
 
-func provisionOrDetectMaven(ctx *gcp.Context) (string, error) {
+// activateProfiles returns the Maven arguments with a -P flag that activates the profiles listed in
+// GOOGLE_MAVEN_PROFILES. Profiles already activated by a -P or --activate-profiles flag in args, e.g.
+// from GOOGLE_BUILD_ARGS, are merged into the single flag.
+func activateProfiles(ctx *gcp.Context, args []string) ([]string, error) {
+	raw := os.Getenv(java.MavenProfiles)
+	if strings.TrimSpace(raw) == "" {
+		return args, nil
+	}
+	var profiles []string
+	for _, part := range strings.Split(raw, ",") {
+		// Profiles may also be separated by spaces, e.g. "prod cloud-run".
+		names := strings.Fields(part)
+		if len(names) == 0 {
+			names = []string{""}
+		}
+		for _, p := range names {
+			if !mavenProfileRegexp.MatchString(p) {
+				return nil, gcp.UserErrorf("invalid Maven profile %q in %s=%q: profile IDs must match %s", p, java.MavenProfiles, raw, mavenProfileRegexp)
+			}
+			profiles = append(profiles, p)
+		}
+	}
+
+	var rest, existing []string
+	for i := 0; i < len(args); i++ {
+		switch a := args[i]; {
+		case (a == "-P" || a == "--activate-profiles") && i+1 < len(args):
+			existing = append(existing, strings.Split(args[i+1], ",")...)
+			i++
+		case strings.HasPrefix(a, "--activate-profiles="):
+			existing = append(existing, strings.Split(strings.TrimPrefix(a, "--activate-profiles="), ",")...)
+		case strings.HasPrefix(a, "-P") && len(a) > 2:
+			existing = append(existing, strings.Split(strings.TrimPrefix(a, "-P"), ",")...)
+		default:
+			rest = append(rest, a)
+		}
+	}
+	if len(existing) == 0 {
+		return append(rest, "-P"+strings.Join(profiles, ",")), nil
+	}
+
+	var merged []string
+	seen := map[string]bool{}
+	for _, p := range append(existing, profiles...) {
+		if p != "" && !seen[p] {
+			seen[p] = true
+			merged = append(merged, p)
+		}
+	}
+	ctx.Logf("Merging the Maven profiles of the build arguments with %s, activating profiles: %s", java.MavenProfiles, strings.Join(merged, ","))
+	return append(rest, "-P"+strings.Join(merged, ",")), nil
+}
+
+// mavenProperties returns a -D flag for each property of the JSON object in
+// GOOGLE_MAVEN_PROPERTIES, sorted by name.
+func mavenProperties(ctx *gcp.Context) ([]string, error) {
+	properties, err := env.StringMap(java.MavenProperties)
+	if err != nil {
+		return nil, gcp.UserErrorf("%w", err)
+	}
+	if len(properties) == 0 {
+		return nil, nil
+	}
+	names := make([]string, 0, len(properties))
+	for name, value := range properties {
+		if !mavenPropertyRegexp.MatchString(name) {
+			return nil, gcp.UserErrorf("invalid Maven property name %q in %s: property names must match %s", name, java.MavenProperties, mavenPropertyRegexp)
+		}
+		if c := unsafePropertyValueRegexp.FindString(value); c != "" {
+			return nil, gcp.UserErrorf("invalid value of Maven property %q in %s: %q is not allowed", name, java.MavenProperties, c)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var flags []string
+	for _, name := range names {
+		flags = append(flags, fmt.Sprintf("-D%s=%s", name, properties[name]))
+	}
+	ctx.Logf("Setting Maven properties from %s: %s", java.MavenProperties, strings.Join(names, ", "))
+	return flags, nil
+}
+
+func provisionOrDetectMaven(ctx *gcp.Context, offline bool) (string, error) {
+	pinned, err := java.PinnedToolVersion(java.MavenWrapperVersionEnv)
+	if err != nil {
+		return "", err
+	}
 	mvnwExists, err := ctx.FileExists("mvnw")
 	if err != nil {
 		return "", err
 	}
 	if mvnwExists {
+		if err := java.CheckMavenWrapperVersion(ctx, pinned); err != nil {
+			return "", err
+		}
 		// With CRLF endings, the "\r" gets seen as part of the shebang target, which doesn't exist.
 		if err := fileutil.EnsureUnixLineEndings("mvnw"); err != nil {
 			return "", fmt.Errorf("ensuring unix newline characters: %w", err)
 		}
 		return "./mvnw", nil
 	}
-	mvnInstalled, err := mvnInstalled(ctx)
-	if err != nil {
-		return "", err
-	}
-	if mvnInstalled {
-		return "mvn", nil
+	version := mavenVersion
+	if pinned != "" {
+		// The version of an installed mvn is unknown, so the pinned version is always installed.
+		ctx.Logf("Using Maven v%s set by %s.", pinned, java.MavenWrapperVersionEnv)
+		version = pinned
+	} else {
+		mvnInstalled, err := mvnInstalled(ctx)
+		if err != nil {
+			return "", err
+		}
+		if mvnInstalled {
+			return "mvn", nil
+		}
 	}
-	mvn, err := installMaven(ctx)
+	mvn, err := installMaven(ctx, offline, version)
 	if err != nil {
 		return "", fmt.Errorf("installing Maven: %w", err)
 	}
@@ -192,8 +354,9 @@ func mvnInstalled(ctx *gcp.Context) (bool, error) {
 	return result.Stdout != "", nil
 }
 
-// installMaven installs Maven and returns the path of the mvn binary
-func installMaven(ctx *gcp.Context) (string, error) {
+// installMaven installs the given version of Maven and returns the path of the mvn binary. In
+// offline mode, only a cached installation can be used.
+func installMaven(ctx *gcp.Context, offline bool, version string) (string, error) {
 	mvnl, err := ctx.Layer(mavenLayer, gcp.CacheLayer, gcp.BuildLayer, gcp.LaunchLayerIfDevMode)
 	if err != nil {
 		return "", fmt.Errorf("creating %v layer: %w", mavenLayer, err)
@@ -201,32 +364,38 @@ func installMaven(ctx *gcp.Context) (string, error) {
 
 	// Check the metadata in the cache layer to determine if we need to proceed.
 	metaVersion := ctx.GetMetadata(mvnl, versionKey)
-	if mavenVersion == metaVersion {
+	if version == metaVersion {
 		ctx.CacheHit(mavenLayer)
 		ctx.Logf("Maven cache hit, skipping installation.")
 		return filepath.Join(mvnl.Path, "bin", "mvn"), nil
 	}
 	ctx.CacheMiss(mavenLayer)
+	if offline {
+		return "", gcp.UserErrorf("Maven v%s is not cached and cannot be downloaded because %s is set, add the Maven wrapper (mvnw) to the project or run a build without %s first", version, java.OfflineEnv, java.OfflineEnv)
+	}
 	if err := ctx.ClearLayer(mvnl); err != nil {
 		return "", fmt.Errorf("clearing layer %q: %w", mvnl.Name, err)
 	}
 
 	// Download and install maven in layer.
-	ctx.Logf("Installing Maven v%s", mavenVersion)
-	archiveURL := fmt.Sprintf(mavenURL, mavenVersion)
+	ctx.Logf("Installing Maven v%s", version)
+	archiveURL := fmt.Sprintf(mavenURL, version)
 	code, err := ctx.HTTPStatus(archiveURL)
 	if err != nil {
 		return "", err
 	}
 	if code != http.StatusOK {
-		return "", gcp.InternalErrorf("Maven version %s does not exist at %s (status %d).", mavenVersion, archiveURL, code)
+		if version != mavenVersion {
+			return "", gcp.UserErrorf("Maven version %s set by %s does not exist at %s (status %d).", version, java.MavenWrapperVersionEnv, archiveURL, code)
+		}
+		return "", gcp.InternalErrorf("Maven version %s does not exist at %s (status %d).", version, archiveURL, code)
 	}
 	command := fmt.Sprintf("curl --fail --show-error --silent --location --retry 3 %s | tar xz --directory %s --strip-components=1", archiveURL, mvnl.Path)
 	if _, err := ctx.Exec([]string{"bash", "-c", command}); err != nil {
 		return "", err
 	}
 
-	ctx.SetMetadata(mvnl, versionKey, mavenVersion)
+	ctx.SetMetadata(mvnl, versionKey, version)
 	return filepath.Join(mvnl.Path, "bin", "mvn"), nil
 }
 
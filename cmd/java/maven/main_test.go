@@ -16,11 +16,15 @@ package main
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	buildpacktest "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
 	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/java"
+	"github.com/google/go-cmp/cmp"
 )
 
 func TestDetect(t *testing.T) {
@@ -90,6 +94,118 @@ func TestBuildCommand(t *testing.T) {
 				"mvn clean package --batch-mode -DskipTests -Dhttp.keepAlive=false",
 			},
 		},
+		{
+			name: "offline",
+			app:  "hello_quarkus_maven",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^bash -c command -v mvn || true`, mockprocess.WithStdout("Apache Maven")),
+			},
+			envs: []string{java.OfflineEnv + "=true"},
+			wantCommands: []string{
+				"mvn clean package --batch-mode -DskipTests -Dhttp.keepAlive=false -f=pom.xml --offline",
+			},
+		},
+		{
+			name: "offline with maven build argument",
+			app:  "hello_quarkus_maven",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^bash -c command -v mvn || true`, mockprocess.WithStdout("Apache Maven")),
+			},
+			envs: []string{java.OfflineEnv + "=true", fmt.Sprintf("%s=clean package", java.MavenBuildArgs)},
+			wantCommands: []string{
+				"mvn clean package --offline",
+			},
+		},
+		{
+			name: "reproducible",
+			app:  "hello_quarkus_maven",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^bash -c command -v mvn || true`, mockprocess.WithStdout("Apache Maven")),
+			},
+			envs: []string{env.ReproducibleBuild + "=true"},
+			wantCommands: []string{
+				"mvn clean package --batch-mode -DskipTests -Dhttp.keepAlive=false -f=pom.xml -Dproject.build.outputTimestamp=1980-01-01T00:00:02Z",
+			},
+		},
+		{
+			name: "maven profiles",
+			app:  "hello_quarkus_maven",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^bash -c command -v mvn || true`, mockprocess.WithStdout("Apache Maven")),
+			},
+			envs: []string{java.MavenProfiles + "=prod,cloud-run"},
+			wantCommands: []string{
+				"mvn clean package --batch-mode -DskipTests -Dhttp.keepAlive=false -f=pom.xml -Pprod,cloud-run",
+			},
+		},
+		{
+			name: "maven profiles merged with build args",
+			app:  "hello_quarkus_maven",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^bash -c command -v mvn || true`, mockprocess.WithStdout("Apache Maven")),
+			},
+			envs: []string{java.MavenProfiles + "=prod", "GOOGLE_BUILD_ARGS=-Plocal -Dfoo=bar"},
+			wantCommands: []string{
+				"mvn clean package --batch-mode -DskipTests -Dhttp.keepAlive=false -f=pom.xml -Dfoo=bar -Plocal,prod",
+			},
+		},
+		{
+			name: "run tests",
+			app:  "hello_quarkus_maven",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^bash -c command -v mvn || true`, mockprocess.WithStdout("Apache Maven")),
+			},
+			envs: []string{java.RunTestsEnv + "=true"},
+			wantCommands: []string{
+				"mvn clean package --batch-mode -Dhttp.keepAlive=false -f=pom.xml",
+			},
+			doNotWantCommands: []string{
+				"-DskipTests",
+			},
+		},
+		{
+			name: "quoted build args",
+			app:  "hello_quarkus_maven",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^bash -c command -v mvn || true`, mockprocess.WithStdout("Apache Maven")),
+			},
+			envs: []string{`GOOGLE_BUILD_ARGS=-Dgreeting="hello world" -Dfoo=bar`},
+			wantCommands: []string{
+				"mvn clean package --batch-mode -DskipTests -Dhttp.keepAlive=false -f=pom.xml -Dgreeting=hello world -Dfoo=bar",
+			},
+		},
+		{
+			name: "quoted maven build args",
+			app:  "hello_quarkus_maven",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^bash -c command -v mvn || true`, mockprocess.WithStdout("Apache Maven")),
+			},
+			envs: []string{java.MavenBuildArgs + `=clean verify '-Dname=my app'`},
+			wantCommands: []string{
+				"mvn clean verify -Dname=my app",
+			},
+		},
+		{
+			name: "run tests with maven profiles and build args",
+			app:  "hello_quarkus_maven",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^bash -c command -v mvn || true`, mockprocess.WithStdout("Apache Maven")),
+			},
+			envs: []string{java.RunTestsEnv + "=true", java.MavenProfiles + "=prod cloud-run", "GOOGLE_BUILD_ARGS=-Dfoo=bar"},
+			wantCommands: []string{
+				"mvn clean package --batch-mode -Dhttp.keepAlive=false -f=pom.xml -Dfoo=bar -Pprod,cloud-run",
+			},
+		},
+		{
+			name: "not offline",
+			app:  "hello_quarkus_maven",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^bash -c command -v mvn || true`, mockprocess.WithStdout("Apache Maven")),
+			},
+			doNotWantCommands: []string{
+				"--offline",
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -121,3 +237,281 @@ func TestBuildCommand(t *testing.T) {
 		})
 	}
 }
+
+func TestBuildPrebuilt(t *testing.T) {
+	testCases := []struct {
+		name         string
+		envs         []string
+		files        map[string]string
+		wantExitCode int // 0 if unspecified
+		wantCommands []string
+	}{
+		{
+			name:         "prebuilt jar missing",
+			envs:         []string{"GOOGLE_PREBUILT=true"},
+			files:        map[string]string{"pom.xml": ""},
+			wantExitCode: 1,
+		},
+		{
+			name:         "not prebuilt",
+			files:        map[string]string{"pom.xml": ""},
+			wantCommands: []string{"mvn clean package"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := buildpacktest.RunBuild(t, buildFn,
+				buildpacktest.WithTestName(tc.name),
+				buildpacktest.WithEnvs(tc.envs...),
+				buildpacktest.WithFiles(tc.files),
+				buildpacktest.WithExecMocks(mockprocess.New(`^bash -c command -v mvn || true`, mockprocess.WithStdout("Apache Maven"))),
+			)
+			if err != nil && tc.wantExitCode == 0 {
+				t.Fatalf("error running build: %v, logs: %s", err, result.Output)
+			}
+			if result.ExitCode != tc.wantExitCode {
+				t.Errorf("build exit code mismatch, got: %d, want: %d", result.ExitCode, tc.wantExitCode)
+			}
+			for _, cmd := range tc.wantCommands {
+				if !result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to be executed, but it was not, build output: %s", cmd, result.Output)
+				}
+			}
+		})
+	}
+}
+
+func TestActivateProfiles(t *testing.T) {
+	testCases := []struct {
+		name     string
+		profiles string
+		args     []string
+		want     []string
+		wantErr  bool
+	}{
+		{
+			name: "no profiles",
+			args: []string{"clean", "package", "-Plocal"},
+			want: []string{"clean", "package", "-Plocal"},
+		},
+		{
+			name:     "profiles",
+			profiles: "prod, cloud_run.v2",
+			args:     []string{"clean", "package"},
+			want:     []string{"clean", "package", "-Pprod,cloud_run.v2"},
+		},
+		{
+			name:     "merged with -P",
+			profiles: "prod,local",
+			args:     []string{"clean", "-Plocal,debug", "package"},
+			want:     []string{"clean", "package", "-Plocal,debug,prod"},
+		},
+		{
+			name:     "merged with separate -P value",
+			profiles: "prod",
+			args:     []string{"-P", "local", "package"},
+			want:     []string{"package", "-Plocal,prod"},
+		},
+		{
+			name:     "merged with --activate-profiles",
+			profiles: "prod",
+			args:     []string{"--activate-profiles=local", "package", "--activate-profiles", "debug"},
+			want:     []string{"package", "-Plocal,debug,prod"},
+		},
+		{
+			name:     "separated by spaces",
+			profiles: " prod  cloud-run,debug ",
+			args:     []string{"package"},
+			want:     []string{"package", "-Pprod,cloud-run,debug"},
+		},
+		{
+			name:     "whitespace only",
+			profiles: "  ",
+			args:     []string{"package"},
+			want:     []string{"package"},
+		},
+		{
+			name:     "invalid profile",
+			profiles: "prod,$(whoami)",
+			wantErr:  true,
+		},
+		{
+			name:     "empty profile",
+			profiles: "prod,,local",
+			wantErr:  true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.profiles != "" {
+				t.Setenv(java.MavenProfiles, tc.profiles)
+			}
+
+			got, err := activateProfiles(gcp.NewContext(), tc.args)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("activateProfiles(%q) got error: %v, want error: %v", tc.args, err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("activateProfiles(%q) mismatch (-want +got):\n%s", tc.args, diff)
+			}
+		})
+	}
+}
+
+func TestBuildWrapperVersion(t *testing.T) {
+	wrapperFiles := map[string]string{
+		"mvnw":                                  "#!/bin/sh\n",
+		".mvn/wrapper/maven-wrapper.properties": "distributionUrl=https://repo.maven.apache.org/maven2/org/apache/maven/apache-maven/3.9.6/apache-maven-3.9.6-bin.zip\n",
+	}
+	testCases := []struct {
+		name              string
+		envs              []string
+		files             map[string]string
+		wantExitCode      int // 0 if unspecified
+		wantCommands      []string
+		doNotWantCommands []string
+		wantOutput        string
+	}{
+		{
+			name:         "wrapper version matches",
+			envs:         []string{java.MavenWrapperVersionEnv + "=3.9.6"},
+			files:        wrapperFiles,
+			wantCommands: []string{"./mvnw clean"},
+			wantOutput:   "Verified that mvnw uses version 3.9.6",
+		},
+		{
+			name:              "wrapper version mismatch",
+			envs:              []string{java.MavenWrapperVersionEnv + "=3.8.8"},
+			files:             wrapperFiles,
+			wantExitCode:      1,
+			doNotWantCommands: []string{"./mvnw clean"},
+			wantOutput:        "mvnw uses version 3.9.6 according to .mvn/wrapper/maven-wrapper.properties, but GOOGLE_MAVEN_WRAPPER_VERSION=3.8.8",
+		},
+		{
+			name:         "wrapper version not found",
+			envs:         []string{java.MavenWrapperVersionEnv + "=3.9.6"},
+			files:        map[string]string{"mvnw": "#!/bin/sh\n"},
+			wantExitCode: 1,
+			wantOutput:   "could not be found in the distributionUrl of .mvn/wrapper/maven-wrapper.properties",
+		},
+		{
+			name:         "wrapper without pinned version",
+			files:        wrapperFiles,
+			wantCommands: []string{"./mvnw clean"},
+		},
+		{
+			name: "pinned version without wrapper",
+			envs: []string{java.MavenWrapperVersionEnv + "=3.8.8"},
+			// Seed the cached installation of the pinned version.
+			files:             map[string]string{"maven.toml": "[metadata]\n  version = \"3.8.8\"\n"},
+			wantCommands:      []string{"maven/bin/mvn clean"},
+			doNotWantCommands: []string{"command -v mvn"},
+			wantOutput:        "Using Maven v3.8.8 set by GOOGLE_MAVEN_WRAPPER_VERSION.",
+		},
+		{
+			name:         "invalid pinned version",
+			envs:         []string{java.MavenWrapperVersionEnv + "=1.0; curl example.com"},
+			wantExitCode: 1,
+			wantOutput:   "invalid version",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := buildpacktest.RunBuild(t, buildFn,
+				buildpacktest.WithTestName(tc.name),
+				buildpacktest.WithApp("hello_quarkus_maven"),
+				buildpacktest.WithEnvs(tc.envs...),
+				buildpacktest.WithFiles(tc.files),
+				buildpacktest.WithExecMocks(mockprocess.New(`^bash -c command -v mvn || true`, mockprocess.WithStdout("Apache Maven"))),
+			)
+			if err != nil && tc.wantExitCode == 0 {
+				t.Fatalf("error running build: %v, logs: %s", err, result.Output)
+			}
+			if result.ExitCode != tc.wantExitCode {
+				t.Errorf("build exit code mismatch, got: %d, want: %d", result.ExitCode, tc.wantExitCode)
+			}
+			for _, cmd := range tc.wantCommands {
+				if !result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to be executed, but it was not, build output: %s", cmd, result.Output)
+				}
+			}
+			for _, cmd := range tc.doNotWantCommands {
+				if result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q not to be executed, but it was, build output: %s", cmd, result.Output)
+				}
+			}
+			if !strings.Contains(result.Output, tc.wantOutput) {
+				t.Errorf("build output = %q, want output containing %q", result.Output, tc.wantOutput)
+			}
+		})
+	}
+}
+
+func TestBuildMavenProperties(t *testing.T) {
+	testCases := []struct {
+		name              string
+		envs              []string
+		wantExitCode      int // 0 if unspecified
+		wantCommands      []string
+		doNotWantCommands []string
+	}{
+		{
+			name: "maven properties",
+			envs: []string{java.MavenProperties + `={"env": "production", "db.url": "jdbc:postgresql://db:5432/app", "greeting": "hello world", "port": 8080}`},
+			wantCommands: []string{
+				"mvn clean package --batch-mode -DskipTests -Dhttp.keepAlive=false -f=pom.xml -Ddb.url=jdbc:postgresql://db:5432/app -Denv=production -Dgreeting=hello world -Dport=8080",
+			},
+		},
+		{
+			name: "maven properties with maven build args",
+			envs: []string{java.MavenBuildArgs + "=clean verify", java.MavenProperties + `={"env": "production"}`},
+			wantCommands: []string{
+				"mvn clean verify -Denv=production",
+			},
+		},
+		{
+			name:              "invalid maven property name",
+			envs:              []string{java.MavenProperties + `={"env=prod -Dfoo": "bar"}`},
+			wantExitCode:      1,
+			doNotWantCommands: []string{"mvn clean package"},
+		},
+		{
+			name:              "unsafe maven property value",
+			envs:              []string{java.MavenProperties + `={"env": "prod; rm -rf /"}`},
+			wantExitCode:      1,
+			doNotWantCommands: []string{"mvn clean package"},
+		},
+		{
+			name:              "maven properties not a json object",
+			envs:              []string{java.MavenProperties + "=env=production"},
+			wantExitCode:      1,
+			doNotWantCommands: []string{"mvn clean package"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := buildpacktest.RunBuild(t, buildFn,
+				buildpacktest.WithTestName(tc.name),
+				buildpacktest.WithApp("hello_quarkus_maven"),
+				buildpacktest.WithEnvs(tc.envs...),
+				buildpacktest.WithExecMocks(mockprocess.New(`^bash -c command -v mvn || true`, mockprocess.WithStdout("Apache Maven"))),
+			)
+			if err != nil && tc.wantExitCode == 0 {
+				t.Fatalf("error running build: %v, logs: %s", err, result.Output)
+			}
+			if result.ExitCode != tc.wantExitCode {
+				t.Errorf("build exit code mismatch, got: %d, want: %d", result.ExitCode, tc.wantExitCode)
+			}
+			for _, cmd := range tc.wantCommands {
+				if !result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to be executed, but it was not, build output: %s", cmd, result.Output)
+				}
+			}
+			for _, cmd := range tc.doNotWantCommands {
+				if result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q not to be executed, but it was, build output: %s", cmd, result.Output)
+				}
+			}
+		})
+	}
+}
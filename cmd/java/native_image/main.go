@@ -18,6 +18,7 @@ package main
 
 import (
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
@@ -30,11 +31,18 @@ import (
 
 const (
 	invokerMain = "com.google.cloud.functions.invoker.runner.Invoker"
+
+	linkModeStatic       = "static"
+	linkModeMostlyStatic = "mostly-static"
+	linkModeDynamic      = "dynamic"
 )
 
 var (
 	requiresGraalvm = []libcnb.BuildPlanRequire{{Name: "graalvm"}}
 	planRequires    = libcnb.BuildPlan{Requires: requiresGraalvm}
+
+	// nativeImageConfigFiles are the generated configuration files that mark a configuration directory.
+	nativeImageConfigFiles = []string{"reflect-config.json", "serialization-config.json"}
 )
 
 func main() {
@@ -109,9 +117,10 @@ func buildCommandLine(ctx *gcp.Context, buildArgs []string) ([]string, error) {
 
 	// Use a temporary image path because this command may generate extra files
 	// (*.o and *.build_artifacts.txt) alongside the binary in the temp dir.
-	userArgs := os.Getenv(env.NativeImageBuildArgs)
-	command := fmt.Sprintf("native-image --no-fallback --no-server -H:+StaticExecutableWithDynamicLibC %s %s %s",
-		userArgs, strings.Join(buildArgs, " "), tempImagePath)
+	command, err := nativeImageCommand(buildArgs, tempImagePath)
+	if err != nil {
+		return nil, err
+	}
 
 	if _, err := ctx.Exec([]string{"bash", "-c", command}, gcp.WithUserAttribution); err != nil {
 		return nil, err
@@ -133,6 +142,39 @@ func buildCommandLine(ctx *gcp.Context, buildArgs []string) ([]string, error) {
 	return []string{finalImage}, nil
 }
 
+// nativeImageCommand returns the native-image command line that builds buildArgs into imagePath,
+// including the linking flags selected by GOOGLE_NATIVE_IMAGE_LINK_MODE and the user arguments from
+// GOOGLE_JAVA_NATIVE_IMAGE_ARGS.
+func nativeImageCommand(buildArgs []string, imagePath string) (string, error) {
+	linkArgs, err := linkModeArgs(os.Getenv(env.NativeImageLinkMode))
+	if err != nil {
+		return "", err
+	}
+	command := append([]string{"native-image", "--no-fallback", "--no-server"}, linkArgs...)
+	if userArgs := os.Getenv(env.NativeImageBuildArgs); userArgs != "" {
+		command = append(command, userArgs)
+	}
+	command = append(command, buildArgs...)
+	command = append(command, imagePath)
+	return strings.Join(command, " "), nil
+}
+
+// linkModeArgs returns the native-image arguments for the given link mode. Mostly static images,
+// the default, only link glibc dynamically, which keeps them portable across sandboxed runtimes
+// such as gVisor while name resolution through NSS keeps working.
+func linkModeArgs(mode string) ([]string, error) {
+	switch mode {
+	case "", linkModeMostlyStatic:
+		return []string{"-H:+StaticExecutableWithDynamicLibC"}, nil
+	case linkModeStatic:
+		return []string{"--static"}, nil
+	case linkModeDynamic:
+		return nil, nil
+	default:
+		return nil, gcp.UserErrorf("invalid %s %q: must be one of %q, %q or %q", env.NativeImageLinkMode, mode, linkModeStatic, linkModeMostlyStatic, linkModeDynamic)
+	}
+}
+
 // buildMaven runs the Maven native-image build and returns the image entrypoint.
 func buildMaven(ctx *gcp.Context, buildProfile string) ([]string, error) {
 	mvn, err := java.MvnCmd(ctx)
@@ -307,7 +349,16 @@ func buildFunctionsFramework(ctx *gcp.Context, functionTarget string, project *j
 		return nil, err
 	}
 
-	entrypoint, err := buildCommandLine(ctx, []string{"-cp", classpath, invokerMain})
+	buildArgs := []string{"-cp", classpath, invokerMain}
+	configDir, err := findNativeImageConfigDir(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if configDir != "" {
+		ctx.Logf("Using the native-image configuration in %s.", configDir)
+		buildArgs = append([]string{"-H:ConfigurationFileDirectories=" + configDir}, buildArgs...)
+	}
+	entrypoint, err := buildCommandLine(ctx, buildArgs)
 	if err != nil {
 		return nil, err
 	}
@@ -315,6 +366,39 @@ func buildFunctionsFramework(ctx *gcp.Context, functionTarget string, project *j
 	return functionsFrameworkEntrypoint, nil
 }
 
+// findNativeImageConfigDir returns the directory of the native-image configuration generated by
+// Micronaut in the compiled classes, i.e. the first directory under
+// target/classes/META-INF/native-image that holds a reflect-config.json or
+// serialization-config.json file. It returns an empty string if there is none.
+func findNativeImageConfigDir(ctx *gcp.Context) (string, error) {
+	// Micronaut generates the configuration in a subdirectory per group and artifact.
+	root := filepath.Join(ctx.ApplicationRoot(), "target", "classes", "META-INF", "native-image")
+	rootExists, err := ctx.FileExists(root)
+	if err != nil || !rootExists {
+		return "", err
+	}
+	var configDir string
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		for _, name := range nativeImageConfigFiles {
+			if d.Name() == name {
+				configDir = filepath.Dir(path)
+				return fs.SkipAll
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", gcp.InternalErrorf("searching %s for native-image configuration: %w", root, err)
+	}
+	return configDir, nil
+}
+
 // createFunctionsClasspath generates the full classpath to be used with native-image command line for GCF workflow
 func createFunctionsClasspath(ctx *gcp.Context, project *java.MavenProject) (string, error) {
 	jarName := fmt.Sprintf("%s-%s.jar", project.ArtifactID, project.Version)
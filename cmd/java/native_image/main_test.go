@@ -18,12 +18,14 @@ import (
 	"archive/zip"
 	"bytes"
 	"io/ioutil"
+	"os"
 	"path"
 	"path/filepath"
 	"regexp"
 	"testing"
 
 	buildpacktest "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/java"
 	"github.com/buildpacks/libcnb"
@@ -199,3 +201,122 @@ func setupTestJar(t *testing.T, mfContent []byte) string {
 	}
 	return jarPath
 }
+
+func TestNativeImageCommand(t *testing.T) {
+	testCases := []struct {
+		name     string
+		linkMode string
+		userArgs string
+		want     string
+		wantErr  bool
+	}{
+		{
+			name: "default mode",
+			want: "native-image --no-fallback --no-server -H:+StaticExecutableWithDynamicLibC -jar app.jar /tmp/native-app",
+		},
+		{
+			name:     "mostly static",
+			linkMode: "mostly-static",
+			want:     "native-image --no-fallback --no-server -H:+StaticExecutableWithDynamicLibC -jar app.jar /tmp/native-app",
+		},
+		{
+			name:     "static",
+			linkMode: "static",
+			want:     "native-image --no-fallback --no-server --static -jar app.jar /tmp/native-app",
+		},
+		{
+			name:     "dynamic",
+			linkMode: "dynamic",
+			want:     "native-image --no-fallback --no-server -jar app.jar /tmp/native-app",
+		},
+		{
+			name:     "static with user args",
+			linkMode: "static",
+			userArgs: "--libc=musl --enable-http",
+			want:     "native-image --no-fallback --no-server --static --libc=musl --enable-http -jar app.jar /tmp/native-app",
+		},
+		{
+			name:     "invalid mode",
+			linkMode: "fully-static",
+			wantErr:  true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.linkMode != "" {
+				t.Setenv(env.NativeImageLinkMode, tc.linkMode)
+			}
+			if tc.userArgs != "" {
+				t.Setenv(env.NativeImageBuildArgs, tc.userArgs)
+			}
+
+			got, err := nativeImageCommand([]string{"-jar", "app.jar"}, "/tmp/native-app")
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("nativeImageCommand() = %q, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("nativeImageCommand() got error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("nativeImageCommand() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFindNativeImageConfigDir(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files []string
+		want  string
+	}{
+		{
+			name:  "micronaut reflect config",
+			files: []string{"target/classes/META-INF/native-image/com.example/app/reflect-config.json", "target/classes/META-INF/native-image/com.example/app/resource-config.json"},
+			want:  "target/classes/META-INF/native-image/com.example/app",
+		},
+		{
+			name:  "serialization config",
+			files: []string{"target/classes/META-INF/native-image/com.example/app/serialization-config.json"},
+			want:  "target/classes/META-INF/native-image/com.example/app",
+		},
+		{
+			name:  "other config only",
+			files: []string{"target/classes/META-INF/native-image/com.example/app/native-image.properties"},
+		},
+		{
+			name:  "no native-image directory",
+			files: []string{"target/classes/com/example/Function.class"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, f := range tc.files {
+				path := filepath.Join(dir, f)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatalf("creating directory: %v", err)
+				}
+				if err := os.WriteFile(path, []byte("[]"), 0644); err != nil {
+					t.Fatalf("writing %s: %v", f, err)
+				}
+			}
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(dir))
+
+			got, err := findNativeImageConfigDir(ctx)
+			if err != nil {
+				t.Fatalf("findNativeImageConfigDir() got error: %v", err)
+			}
+			want := ""
+			if tc.want != "" {
+				want = filepath.Join(dir, tc.want)
+			}
+			if got != want {
+				t.Errorf("findNativeImageConfigDir() = %q, want %q", got, want)
+			}
+		})
+	}
+}
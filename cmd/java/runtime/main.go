@@ -20,17 +20,29 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"regexp"
 	"strings"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/java"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/runtime"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/toolversions"
+	"github.com/buildpacks/libcnb"
 )
 
 const (
 	javaLayer = "java"
+	// javaBuildLayer holds the JDK used to build the application when it differs from the one in
+	// javaLayer, which is then only used at launch.
+	javaBuildLayer = "java-build"
+	// javaHomeLayer sets JAVA_HOME to the JDK of the base image when the installation is skipped.
+	javaHomeLayer = "java-home"
 )
 
+// javaHomeRegexp captures the java.home property printed by `java -XshowSettings:properties`.
+var javaHomeRegexp = regexp.MustCompile(`(?m)^\s*java\.home = (\S+)`)
+
 // Map with key as stackId and value as the default feature version for that stack.
 // We still need to support Java11 on ubuntu18 for OSS applications.
 var defaultFeatureVersion = map[string]string{
@@ -58,6 +70,7 @@ func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
 		"build.gradle.kts",
 		"settings.gradle.kts",
 		"settings.gradle",
+		"build.sbt",
 		"META-INF/MANIFEST.MF",
 	}
 	for _, f := range files {
@@ -88,23 +101,123 @@ func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
 }
 
 func buildFn(ctx *gcp.Context) error {
-	featureVersion := stackToVersion(ctx.StackID())
-	if v := os.Getenv(env.RuntimeVersion); v != "" {
-		featureVersion = v
-		ctx.Logf("Using requested runtime feature version: %s", featureVersion)
-	} else {
-		ctx.Logf("Using latest Java %s runtime version. You can specify a different version with %s: https://github.com/GoogleCloudPlatform/buildpacks#configuration", featureVersion, env.RuntimeVersion)
+	buildVersion, runtimeVersion, err := jdkVersions(ctx)
+	if err != nil {
+		return err
+	}
+	skip, err := runtime.SkipInstall()
+	if err != nil {
+		return err
+	}
+	if skip {
+		// The JDK of the base image is used both to build and to run the application.
+		for _, v := range []string{buildVersion, runtimeVersion} {
+			if _, err := runtime.CheckPreinstalled(ctx, runtime.OpenJDK, v); err != nil {
+				return err
+			}
+		}
+		return setPreinstalledJavaHome(ctx)
+	}
+	if buildVersion == runtimeVersion {
+		l, err := ctx.Layer(javaLayer, gcp.BuildLayer, gcp.CacheLayer, gcp.LaunchLayerUnlessSkipRuntimeLaunch)
+		if err != nil {
+			return fmt.Errorf("creating %v layer: %w", javaLayer, err)
+		}
+		return installJDK(ctx, runtimeVersion, l)
+	}
+
+	ctx.Logf("Building with Java %s and running on Java %s.", buildVersion, runtimeVersion)
+	bl, err := ctx.Layer(javaBuildLayer, gcp.BuildLayer, gcp.CacheLayer)
+	if err != nil {
+		return fmt.Errorf("creating %v layer: %w", javaBuildLayer, err)
+	}
+	if err := installJDK(ctx, buildVersion, bl); err != nil {
+		return err
 	}
-	l, err := ctx.Layer(javaLayer, gcp.BuildLayer, gcp.CacheLayer, gcp.LaunchLayerUnlessSkipRuntimeLaunch)
+	rl, err := ctx.Layer(javaLayer, gcp.CacheLayer, gcp.LaunchLayerUnlessSkipRuntimeLaunch)
 	if err != nil {
 		return fmt.Errorf("creating %v layer: %w", javaLayer, err)
 	}
+	if !rl.Launch {
+		ctx.Logf("Skipping the installation of Java %s because the runtime is not included in the image.", runtimeVersion)
+		return nil
+	}
+	return installJDK(ctx, runtimeVersion, rl)
+}
+
+// setPreinstalledJavaHome sets JAVA_HOME to the home of the JDK on PATH for the build and the
+// application, unless the base image already sets it.
+func setPreinstalledJavaHome(ctx *gcp.Context) error {
+	home, err := preinstalledJavaHome(ctx)
+	if err != nil || home == "" {
+		return err
+	}
+	l, err := ctx.Layer(javaHomeLayer, gcp.BuildLayer, gcp.LaunchLayer)
+	if err != nil {
+		return fmt.Errorf("creating %v layer: %w", javaHomeLayer, err)
+	}
+	l.SharedEnvironment.Override("JAVA_HOME", home)
+	ctx.Logf("Setting JAVA_HOME=%s.", home)
+	return nil
+}
+
+// preinstalledJavaHome returns the home of the JDK on PATH, or an empty string if the base image
+// sets JAVA_HOME.
+func preinstalledJavaHome(ctx *gcp.Context) (string, error) {
+	if home := os.Getenv("JAVA_HOME"); home != "" {
+		ctx.Logf("Using JAVA_HOME=%s from the base image.", home)
+		return "", nil
+	}
+	// The properties are printed to stderr.
+	result, err := ctx.Exec([]string{"java", "-XshowSettings:properties", "-version"})
+	if err != nil {
+		return "", err
+	}
+	m := javaHomeRegexp.FindStringSubmatch(result.Combined)
+	if m == nil {
+		return "", gcp.InternalErrorf("finding java.home in the output of java -XshowSettings:properties: %s", result.Combined)
+	}
+	return m[1], nil
+}
+
+// jdkVersions returns the feature versions of the JDK used to build the application and of the JDK
+// it runs on. The runtime version is selected by GOOGLE_JAVA_RUNTIME_VERSION, GOOGLE_RUNTIME_VERSION,
+// the .tool-versions file or the stack, and the build version defaults to it.
+func jdkVersions(ctx *gcp.Context) (string, string, error) {
+	runtimeVersion := stackToVersion(ctx.StackID())
+	if v := os.Getenv(java.RuntimeVersionEnv); v != "" {
+		runtimeVersion = v
+		ctx.Logf("Using requested runtime feature version from %s: %s", java.RuntimeVersionEnv, runtimeVersion)
+	} else if v := os.Getenv(env.RuntimeVersion); v != "" {
+		runtimeVersion = v
+		ctx.Logf("Using requested runtime feature version: %s", runtimeVersion)
+	} else {
+		v, err := java.ToolVersionsFeatureVersion(ctx)
+		if err != nil {
+			return "", "", err
+		}
+		if v != "" {
+			runtimeVersion = v
+			ctx.Logf("Using runtime feature version from %s: %s", toolversions.File, runtimeVersion)
+		} else {
+			ctx.Logf("Using latest Java %s runtime version. You can specify a different version with %s: https://github.com/GoogleCloudPlatform/buildpacks#configuration", runtimeVersion, env.RuntimeVersion)
+		}
+	}
+	buildVersion := runtimeVersion
+	if v := os.Getenv(java.BuildVersionEnv); v != "" {
+		buildVersion = v
+	}
+	return buildVersion, runtimeVersion, nil
+}
+
+// installJDK installs the JDK of the given feature version in the layer, unless it is cached.
+func installJDK(ctx *gcp.Context, featureVersion string, l *libcnb.Layer) error {
 	jdkRuntime := runtime.OpenJDK
 	// Java 21 should fetch Jdk from Canonical instead of Adoptium.
 	if strings.HasPrefix(featureVersion, "21") {
 		jdkRuntime = runtime.CanonicalJDK
 	}
-	_, err = runtime.InstallTarballIfNotCached(ctx, jdkRuntime, featureVersion, l)
+	_, err := runtime.InstallTarballIfNotCached(ctx, jdkRuntime, featureVersion, l)
 	return err
 }
 
@@ -15,10 +15,16 @@
 package main
 
 import (
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 
 	buildpacktest "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/java"
 )
 
 func TestDetect(t *testing.T) {
@@ -72,6 +78,13 @@ func TestDetect(t *testing.T) {
 			},
 			want: 0,
 		},
+		{
+			name: "build.sbt",
+			files: map[string]string{
+				"build.sbt": "",
+			},
+			want: 0,
+		},
 		{
 			name: "java files",
 			files: map[string]string{
@@ -236,6 +249,96 @@ func TestStackToVersion(t *testing.T) {
 	}
 }
 
+func TestJDKVersions(t *testing.T) {
+	testCases := []struct {
+		name             string
+		stack            string
+		envs             map[string]string
+		toolVersions     string
+		wantBuildVersion string
+		wantRunVersion   string
+	}{
+		{
+			name:             "stack default",
+			stack:            "google.22",
+			wantBuildVersion: "21",
+			wantRunVersion:   "21",
+		},
+		{
+			name:             "runtime version",
+			stack:            "google.22",
+			envs:             map[string]string{env.RuntimeVersion: "17"},
+			wantBuildVersion: "17",
+			wantRunVersion:   "17",
+		},
+		{
+			name:             "java runtime version takes precedence",
+			stack:            "google.22",
+			envs:             map[string]string{env.RuntimeVersion: "11", java.RuntimeVersionEnv: "17"},
+			wantBuildVersion: "17",
+			wantRunVersion:   "17",
+		},
+		{
+			name:             "build version only",
+			stack:            "google.18",
+			envs:             map[string]string{java.BuildVersionEnv: "17"},
+			wantBuildVersion: "17",
+			wantRunVersion:   "11",
+		},
+		{
+			name:             "build with 21 and run on 17",
+			stack:            "google.22",
+			envs:             map[string]string{java.BuildVersionEnv: "21", java.RuntimeVersionEnv: "17"},
+			wantBuildVersion: "21",
+			wantRunVersion:   "17",
+		},
+		{
+			name:             "same build and runtime versions",
+			stack:            "google.22",
+			envs:             map[string]string{java.BuildVersionEnv: "17", env.RuntimeVersion: "17"},
+			wantBuildVersion: "17",
+			wantRunVersion:   "17",
+		},
+		{
+			name:             ".tool-versions",
+			stack:            "google.22",
+			toolVersions:     "nodejs 20.11.0\njava temurin-17.0.10+7\n",
+			wantBuildVersion: "17",
+			wantRunVersion:   "17",
+		},
+		{
+			name:             "runtime version takes precedence over .tool-versions",
+			stack:            "google.22",
+			envs:             map[string]string{env.RuntimeVersion: "11"},
+			toolVersions:     "java temurin-17.0.10+7\n",
+			wantBuildVersion: "11",
+			wantRunVersion:   "11",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for k, v := range tc.envs {
+				t.Setenv(k, v)
+			}
+
+			dir := t.TempDir()
+			if tc.toolVersions != "" {
+				if err := os.WriteFile(filepath.Join(dir, ".tool-versions"), []byte(tc.toolVersions), 0644); err != nil {
+					t.Fatalf("writing .tool-versions: %v", err)
+				}
+			}
+
+			gotBuild, gotRun, err := jdkVersions(gcp.NewContext(gcp.WithStackID(tc.stack), gcp.WithApplicationRoot(dir)))
+			if err != nil {
+				t.Fatalf("jdkVersions() got error: %v", err)
+			}
+			if gotBuild != tc.wantBuildVersion || gotRun != tc.wantRunVersion {
+				t.Errorf("jdkVersions() = (%q, %q), want (%q, %q)", gotBuild, gotRun, tc.wantBuildVersion, tc.wantRunVersion)
+			}
+		})
+	}
+}
+
 func TestExtractRelease(t *testing.T) {
 	testCases := []struct {
 		name           string
@@ -384,3 +487,50 @@ func TestExtractReleaseFail(t *testing.T) {
 		})
 	}
 }
+
+func TestPreinstalledJavaHome(t *testing.T) {
+	testCases := []struct {
+		name     string
+		javaHome string
+		stderr   string
+		want     string
+		wantErr  bool
+	}{
+		{
+			name: "from java properties",
+			stderr: `Property settings:
+    file.encoding = UTF-8
+    java.home = /usr/lib/jvm/java-17-openjdk-amd64
+    java.version = 17.0.9
+`,
+			want: "/usr/lib/jvm/java-17-openjdk-amd64",
+		},
+		{
+			name:     "set by the base image",
+			javaHome: "/opt/java/openjdk",
+		},
+		{
+			name:    "java home not printed",
+			stderr:  "openjdk version \"17.0.9\" 2023-10-17\n",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("JAVA_HOME", tc.javaHome)
+			eCmd, err := mockprocess.NewExecCmd(mockprocess.New(`^java -XshowSettings:properties -version`, mockprocess.WithStderr(tc.stderr)))
+			if err != nil {
+				t.Fatalf("error creating mock exec command: %v", err)
+			}
+			ctx := gcp.NewContext(gcp.WithExecCmd(eCmd))
+
+			got, err := preinstalledJavaHome(ctx)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("preinstalledJavaHome() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("preinstalledJavaHome() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -94,6 +94,15 @@ func buildFn(ctx *gcp.Context) error {
 		return err
 	}
 
+	outputMode, err := nodejs.NextOutputMode(ctx, appDir)
+	if err != nil {
+		return err
+	}
+	if outputMode == nodejs.NextOutputExport {
+		ctx.Logf("*** Your Next.js config sets output: '%s', the app is a static export and does not need @apphosting/adapter-nextjs, skipping installation ***", nodejs.NextOutputExport)
+		return nil
+	}
+
 	// TODO(b/357644160) We we should consider adding a validation step to double check that the adapter version works for the framework version.
 	if version, exists := nodeDeps.PackageJSON.Dependencies["@apphosting/adapter-nextjs"]; exists {
 		ctx.Logf("*** You already have @apphosting/adapter-nextjs@%s listed as a dependency, skipping installation ***", version)
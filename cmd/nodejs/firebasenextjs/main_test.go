@@ -336,6 +336,52 @@ unsupported:
 			},
 			shouldInstallAdapter: true,
 		},
+		{
+			name: "static export",
+			files: map[string]string{
+				"package.json": `{
+					"dependencies": {
+						"next": "14.0.0"
+					}
+				}`,
+				"package-lock.json": `{
+					"packages": {
+						"node_modules/next": {
+							"version": "14.0.0"
+						}
+					}
+				}`,
+				"next.config.js": `/** @type {import('next').NextConfig} */
+const nextConfig = {
+  output: 'export',
+};
+module.exports = nextConfig;`,
+			},
+			shouldInstallAdapter: false,
+		},
+		{
+			name: "static export commented out",
+			files: map[string]string{
+				"package.json": `{
+					"dependencies": {
+						"next": "14.0.0"
+					}
+				}`,
+				"package-lock.json": `{
+					"packages": {
+						"node_modules/next": {
+							"version": "14.0.0"
+						}
+					}
+				}`,
+				"next.config.mjs": `const nextConfig = {
+  // output: 'export',
+  output: "standalone",
+};
+export default nextConfig;`,
+			},
+			shouldInstallAdapter: true,
+		},
 	}
 
 	for _, tc := range testCases {
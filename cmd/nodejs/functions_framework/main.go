@@ -21,7 +21,6 @@ import (
 	"os"
 	"path"
 	"path/filepath"
-	"strconv"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/ar"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
@@ -35,9 +34,9 @@ import (
 const (
 	layerName                 = "functions-framework"
 	functionsFrameworkPackage = "@google-cloud/functions-framework"
-
-	// nodeJSHeadroomMB is the amount of memory we'll set aside before computing the max memory size.
-	nodeJSHeadroomMB int = 64
+	// subdirDepsLayerName holds the dependencies of the package.json in the function source
+	// subdirectory.
+	subdirDepsLayerName = "function-dependencies"
 )
 
 var functionsFrameworkNodeModulePath = path.Join("node_modules", functionsFrameworkPackage)
@@ -66,7 +65,13 @@ func buildFn(ctx *gcp.Context) error {
 		return gcp.UserErrorf("%s is not currently supported for Node.js buildpacks", env.FunctionSource)
 	}
 
-	indexJSExists, err := ctx.FileExists("index.js")
+	subdir, err := cloudfunctions.SourceSubdir(ctx)
+	if err != nil {
+		return err
+	}
+	fnDir := filepath.Join(ctx.ApplicationRoot(), subdir)
+
+	indexJSExists, err := ctx.FileExists(fnDir, "index.js")
 	if err != nil {
 		return err
 	}
@@ -79,14 +84,51 @@ func buildFn(ctx *gcp.Context) error {
 
 	// Determine if the function has dependency on functions-framework.
 	hasFrameworkDependency := false
+	mainFromPJS := false
 	pjs, err := nodejs.ReadPackageJSONIfExists(ctx.ApplicationRoot())
 	if err != nil {
 		return fmt.Errorf("reading package.json: %w", err)
 	}
+	// fnPJS is the package.json of the function, which defines its build scripts.
+	fnPJS, fnPJSDir := pjs, ""
+	// subdirFramework is true if the package.json in the function source subdirectory declares the
+	// framework, which is then installed in the node_modules directory of the subdirectory.
+	subdirFramework := false
 	if pjs != nil {
 		_, hasFrameworkDependency = pjs.Dependencies[functionsFrameworkPackage]
-		if pjs.Main != "" {
+		if pjs.Main != "" && subdir == "" {
 			fnFile = pjs.Main
+			mainFromPJS = true
+		}
+	}
+	if subdir != "" {
+		ctx.Logf("Building function from %s=%q", env.FunctionSourceSubdir, subdir)
+		// The function in the subdirectory may declare its own package.json. Its dependencies
+		// resolve from the node_modules directories of the subdirectory and its parents.
+		subdirPJS, err := nodejs.ReadPackageJSONIfExists(fnDir)
+		if err != nil {
+			return fmt.Errorf("reading package.json: %w", err)
+		}
+		if subdirPJS != nil {
+			fnPJS, fnPJSDir = subdirPJS, subdir
+			if _, ok := subdirPJS.Dependencies[functionsFrameworkPackage]; ok {
+				hasFrameworkDependency, subdirFramework = true, true
+			}
+			if subdirPJS.Main != "" {
+				fnFile = subdirPJS.Main
+				mainFromPJS = true
+			}
+		}
+		fnFile = filepath.Join(subdir, fnFile)
+	}
+
+	isTypeScript, err := nodejs.IsTypeScript(ctx, fnDir)
+	if err != nil {
+		return err
+	}
+	if isTypeScript {
+		if err := buildTypeScript(ctx, fnFile, fnPJSDir, fnPJS); err != nil {
+			return err
 		}
 	}
 
@@ -115,6 +157,12 @@ func buildFn(ctx *gcp.Context) error {
 		return gcp.UserErrorf("This project is using pnpm but you have not included the Functions Framework in your dependencies. Please add it by running: 'pnpm add @google-cloud/functions-framework'.")
 	}
 
+	if fnPJSDir != "" && len(fnPJS.Dependencies) > 0 && !yarnPnP {
+		if err := installSubdirDependencies(ctx, fnDir); err != nil {
+			return fmt.Errorf("installing the dependencies of %s: %w", subdir, err)
+		}
+	}
+
 	// TODO(mattrobertson) remove this check once Nodejs has backported the fix to v16. More info here:
 	// https://github.com/GoogleCloudPlatform/functions-framework-nodejs/issues/407
 	if skip, err := nodejs.SkipSyntaxCheck(ctx, fnFile, pjs); err != nil {
@@ -132,6 +180,12 @@ func buildFn(ctx *gcp.Context) error {
 		}
 	}
 
+	if mainFromPJS {
+		if err := validateExport(ctx, fnFile, yarnPnP); err != nil {
+			return err
+		}
+	}
+
 	l, err := ctx.Layer(layerName, gcp.BuildLayer, gcp.CacheLayer, gcp.LaunchLayer)
 	if err != nil {
 		return fmt.Errorf("creating %v layer: %w", layerName, err)
@@ -160,8 +214,13 @@ func buildFn(ctx *gcp.Context) error {
 		if err := ctx.ClearLayer(l); err != nil {
 			return fmt.Errorf("clearing layer %q: %w", l.Name, err)
 		}
-		ff = filepath.Join("node_modules", ff)
-		addFrameworkVersionLabel(ctx, functionsFrameworkNodeModulePath, false)
+		if subdirFramework {
+			ff = filepath.Join(subdir, "node_modules", ff)
+			addFrameworkVersionLabel(ctx, filepath.Join(fnDir, functionsFrameworkNodeModulePath), false)
+		} else {
+			ff = filepath.Join("node_modules", ff)
+			addFrameworkVersionLabel(ctx, functionsFrameworkNodeModulePath, false)
+		}
 	} else {
 		ctx.Logf("Handling functions without dependency on functions-framework.")
 		if err := cloudfunctions.AssertFrameworkInjectionAllowed(); err != nil {
@@ -191,18 +250,125 @@ func buildFn(ctx *gcp.Context) error {
 		}
 	}
 
-	// Get and set the valid value for --max-old-space-size node_options.
-	// Keep the existing behaviour if the value is not provided or invalid
-	if size, err := getMaxOldSpaceSize(); err != nil {
+	if otel, err := env.IsPresentAndTrue(nodejs.OTelEnv); err != nil {
 		return err
-	} else if size > 0 {
-		l.LaunchEnvironment.Prepend("NODE_OPTIONS", " ", fmt.Sprintf("--max-old-space-size=%d", size))
+	} else if otel {
+		ol, err := ctx.Layer(nodejs.OTelLayer, gcp.CacheLayer, gcp.LaunchLayer)
+		if err != nil {
+			return fmt.Errorf("creating %v layer: %w", nodejs.OTelLayer, err)
+		}
+		if err := nodejs.InstallOTelBootstrap(ctx, ol, yarnPnP); err != nil {
+			return err
+		}
 	}
 
 	if err := ctx.SetFunctionsEnvVars(l); err != nil {
 		return err
 	}
-	ctx.AddWebProcess([]string{"/bin/bash", "-c", ff})
+	ffEnv, err := nodejs.FunctionsFrameworkEnv(ctx)
+	if err != nil {
+		return err
+	}
+	for name, value := range ffEnv {
+		l.LaunchEnvironment.Default(name, value)
+	}
+	if subdir != "" {
+		// The functions framework loads the function from the FUNCTION_SOURCE directory.
+		l.LaunchEnvironment.Default(env.FunctionSourceLaunch, fnDir)
+	}
+	return addWebProcess(ctx, ff)
+}
+
+// addWebProcess registers the functions framework launch command with the extra arguments from
+// GOOGLE_FUNCTIONS_FRAMEWORK_ARGS. The arguments are passed to bash as positional parameters so
+// that they are not interpreted by the shell.
+func addWebProcess(ctx *gcp.Context, ff string) error {
+	args, err := cloudfunctions.FrameworkArgs()
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		ctx.AddWebProcess([]string{"/bin/bash", "-c", ff})
+		return nil
+	}
+	ctx.AddWebProcess(append([]string{"/bin/bash", "-c", ff + ` "$@"`, "functions-framework"}, args...))
+	return nil
+}
+
+// buildTypeScript makes sure that a TypeScript function is compiled to the JavaScript file loaded
+// by the functions framework. The build script usually runs in the npm, yarn or pnpm buildpack, it
+// is run here if its output is missing, for example because Yarn only runs "gcp-build" scripts.
+// Compiled JavaScript that is already present, e.g. checked in, is used as is.
+// pjsDir is the directory of the package.json of the function, relative to the application root.
+func buildTypeScript(ctx *gcp.Context, fnFile, pjsDir string, pjs *nodejs.PackageJSON) error {
+	pjsFile := filepath.Join(pjsDir, "package.json")
+	if ext := filepath.Ext(fnFile); ext == ".ts" || ext == ".mts" || ext == ".cts" {
+		return gcp.UserErrorf(`"main" in %s is the TypeScript file %s: set it to the compiled JavaScript file, for example "dist/index.js"`, pjsFile, fnFile)
+	}
+	compiled, err := ctx.FileExists(fnFile)
+	if err != nil || compiled {
+		return err
+	}
+	script := ""
+	if pjs != nil {
+		script = nodejs.TypeScriptBuildScript(pjs)
+	}
+	if script == "" {
+		return gcp.UserErrorf(`found a TypeScript function (%s), but %s does not exist and %s does not define a "build" script to compile it: add a script such as "build": "tsc" and set "main" to the compiled JavaScript file, for example "dist/index.js"`, nodejs.TSConfig, fnFile, pjsFile)
+	}
+
+	pkgTool, err := packageTool(ctx)
+	if err != nil {
+		return err
+	}
+	ctx.Logf("Compiling the TypeScript function with the %q script.", script)
+	if _, err := ctx.Exec([]string{pkgTool, "run", script}, gcp.WithWorkDir(filepath.Join(ctx.ApplicationRoot(), pjsDir)), gcp.WithUserAttribution); err != nil {
+		return err
+	}
+	compiled, err = ctx.FileExists(fnFile)
+	if err != nil {
+		return err
+	}
+	if !compiled {
+		return gcp.UserErrorf(`the %q script did not create %s: set "main" in %s to the JavaScript file compiled from the function, for example "dist/index.js"`, script, fnFile, pjsFile)
+	}
+	return nil
+}
+
+// packageTool returns the package manager used by the application, based on its lockfile.
+func packageTool(ctx *gcp.Context) (string, error) {
+	for _, lock := range []struct{ file, tool string }{{nodejs.PNPMLock, "pnpm"}, {nodejs.YarnLock, "yarn"}} {
+		exists, err := ctx.FileExists(ctx.ApplicationRoot(), lock.file)
+		if err != nil {
+			return "", err
+		}
+		if exists {
+			return lock.tool, nil
+		}
+	}
+	return "npm", nil
+}
+
+// validateExport loads the function file with Node.js and checks that it exports the function
+// target. It is opt-in via GOOGLE_FUNCTION_VALIDATE_EXPORT because loading the module runs its
+// top-level code.
+func validateExport(ctx *gcp.Context, fnFile string, yarnPnP bool) error {
+	validate, err := env.IsPresentAndTrue(nodejs.EnvValidateExport)
+	if err != nil {
+		return err
+	}
+	target := os.Getenv(env.FunctionTarget)
+	if !validate || target == "" {
+		return nil
+	}
+	cmd := []string{"node", "-e", "require(require('path').resolve(process.env.FUNCTION_FILE)).hasOwnProperty(process.env.FUNCTION_TARGET) || process.exit(1)"}
+	if yarnPnP {
+		cmd = append([]string{"yarn"}, cmd...)
+	}
+	// The target is passed explicitly because it is usually set as GOOGLE_FUNCTION_TARGET.
+	if _, err := ctx.Exec(cmd, gcp.WithEnv("FUNCTION_FILE="+fnFile, "FUNCTION_TARGET="+target), gcp.WithWorkDir(ctx.ApplicationRoot()), gcp.WithUserAttribution); err != nil {
+		return gcp.UserErrorf("Function target %q is not exported by %s", target, fnFile)
+	}
 	return nil
 }
 
@@ -244,24 +410,56 @@ func installFunctionsFramework(ctx *gcp.Context, l *libcnb.Layer) error {
 	return nil
 }
 
-// getMaxOldSpaceSize returns the memory size specified by (GOOGLE_CONTAINER_MEMORY_HINT_MB - nodeJSHeadroomMB),
-// or 0 if env var is not specified.
-func getMaxOldSpaceSize() (int, error) {
-	memHintStr, exist := os.LookupEnv(env.ContainerMemoryHintMB)
-	if !exist {
-		return 0, nil
+// installSubdirDependencies installs the production dependencies declared in the package.json of
+// the function source subdirectory fnDir, since the npm, yarn and pnpm buildpacks only install the
+// dependencies of the application root. They are installed with npm in a layer keyed by the
+// package.json and package-lock.json of the subdirectory, which is linked from the node_modules
+// directory of the subdirectory. A vendored node_modules directory is used as is.
+func installSubdirDependencies(ctx *gcp.Context, fnDir string) error {
+	nm := filepath.Join(fnDir, "node_modules")
+	vendored, err := ctx.FileExists(nm)
+	if err != nil {
+		return err
 	}
-
-	memHint, err := strconv.Atoi(memHintStr)
+	if vendored {
+		ctx.Logf("Using the dependencies vendored in %s.", nm)
+		return nil
+	}
+	l, err := ctx.Layer(subdirDepsLayerName, gcp.BuildLayer, gcp.CacheLayer, gcp.LaunchLayer)
 	if err != nil {
-		return 0, fmt.Errorf("%s=%q must be an integer: %v", env.ContainerMemoryHintMB, memHintStr, err)
+		return fmt.Errorf("creating %v layer: %w", subdirDepsLayerName, err)
 	}
-
-	if memHint <= nodeJSHeadroomMB {
-		return 0, fmt.Errorf("%s=%q must be greater than %d", env.ContainerMemoryHintMB, memHintStr, nodeJSHeadroomMB)
+	files := []string{filepath.Join(fnDir, "package.json")}
+	installCmd := "install"
+	lockExists, err := ctx.FileExists(fnDir, nodejs.PackageLock)
+	if err != nil {
+		return err
 	}
-
-	return memHint - nodeJSHeadroomMB, nil
+	if lockExists {
+		files = append(files, filepath.Join(fnDir, nodejs.PackageLock))
+		if installCmd, err = nodejs.NPMInstallCommand(ctx); err != nil {
+			return err
+		}
+	}
+	cached, err := nodejs.CheckOrClearCache(ctx, l, cache.WithStrings(nodejs.EnvProduction), cache.WithFiles(files...))
+	if err != nil {
+		return fmt.Errorf("checking cache: %w", err)
+	}
+	if cached {
+		ctx.Logf("Reusing the cached dependencies of %s.", fnDir)
+	} else {
+		// NPM expects package.json and the lock file in the prefix directory.
+		if _, err := ctx.Exec(append([]string{"cp", "-t", l.Path}, files...), gcp.WithUserTimingAttribution); err != nil {
+			return err
+		}
+		if err := ar.GenerateNPMConfig(ctx); err != nil {
+			return fmt.Errorf("generating Artifact Registry credentials: %w", err)
+		}
+		if _, err := ctx.Exec([]string{"npm", installCmd, "--quiet", "--production", "--prefix", l.Path}, gcp.WithUserAttribution); err != nil {
+			return err
+		}
+	}
+	return ctx.Symlink(filepath.Join(l.Path, "node_modules"), nm)
 }
 
 // tryAddFrameworkVersionLabel attempts to identify the functions framework
@@ -272,7 +470,7 @@ func addFrameworkVersionLabel(ctx *gcp.Context, ffPackageJSON string, injected b
 	packageInfo, err := nodejs.ReadPackageJSONIfExists(ffPackageJSON)
 	if err != nil {
 		ctx.Logf("Could not detect installed functions framework version: %v", err)
-	} else {
+	} else if packageInfo != nil {
 		version = packageInfo.Version
 	}
 	cloudfunctions.AddFrameworkVersionLabel(ctx, &cloudfunctions.FrameworkVersionInfo{
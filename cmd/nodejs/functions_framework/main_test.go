@@ -16,11 +16,14 @@ package main
 
 import (
 	"os"
-	"strconv"
-	"strings"
+	"os/exec"
+	"path/filepath"
 	"testing"
 
 	buildpacktest "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/google/go-cmp/cmp"
 )
 
 func TestDetect(t *testing.T) {
@@ -50,6 +53,16 @@ func TestDetect(t *testing.T) {
 			name: "without target",
 			want: 100,
 		},
+		{
+			name: "typescript function",
+			files: map[string]string{
+				"package.json":  `{"main": "dist/index.js", "scripts": {"build": "tsc"}}`,
+				"tsconfig.json": "{}",
+				"src/index.ts":  "",
+			},
+			env:  []string{"GOOGLE_FUNCTION_TARGET=helloWorld"},
+			want: 0,
+		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -58,91 +71,307 @@ func TestDetect(t *testing.T) {
 	}
 }
 
-func TestGetMaxOldSpaceSize(t *testing.T) {
+func TestBuild(t *testing.T) {
 	testCases := []struct {
-		name    string
-		env     []string
-		want    int
-		wantErr bool
+		name              string
+		files             map[string]string
+		envs              []string
+		mocks             []*mockprocess.Mock
+		wantExitCode      int // 0 if unspecified
+		wantCommands      []string
+		doNotWantCommands []string
 	}{
 		{
-			name: "env val not set",
+			name: "function in source subdirectory",
+			files: map[string]string{
+				"package.json": `{"dependencies": {"@google-cloud/functions-framework": "^3.0.0"}}`,
+				"node_modules/@google-cloud/functions-framework/package.json": `{"version": "3.0.0"}`,
+				"index.js":                     "",
+				"functions/hello/package.json": `{"main": "fn.js"}`,
+				"functions/hello/fn.js":        "",
+			},
+			envs:         []string{"GOOGLE_FUNCTION_SOURCE_SUBDIR=functions/hello"},
+			wantCommands: []string{"node --check functions/hello/fn.js"},
 		},
 		{
-			name:    "env val set but no value",
-			env:     []string{"GOOGLE_CONTAINER_MEMORY_HINT_MB="},
-			wantErr: true,
+			name: "function in source subdirectory with its own dependencies",
+			files: map[string]string{
+				"package.json":                      "{}",
+				"functions/hello/package.json":      `{"main": "fn.js", "dependencies": {"@google-cloud/functions-framework": "^3.0.0"}}`,
+				"functions/hello/package-lock.json": "{}",
+				"functions/hello/fn.js":             "",
+			},
+			envs:  []string{"GOOGLE_FUNCTION_SOURCE_SUBDIR=functions/hello"},
+			mocks: []*mockprocess.Mock{mockprocess.New(`^npm --version$`, mockprocess.WithStdout("10.2.4"))},
+			wantCommands: []string{
+				"cp -t [^\n]*function-dependencies [^\n]*functions/hello/package.json [^\n]*functions/hello/package-lock.json",
+				"npm ci --quiet --production --prefix [^\n]*function-dependencies",
+				"node --check functions/hello/fn.js",
+			},
 		},
 		{
-			name:    "env val set but less than head room",
-			env:     []string{"GOOGLE_CONTAINER_MEMORY_HINT_MB=" + strconv.Itoa(nodeJSHeadroomMB-1)},
-			wantErr: true,
+			name: "function in source subdirectory with vendored dependencies",
+			files: map[string]string{
+				"package.json":                 "{}",
+				"functions/hello/package.json": `{"main": "fn.js", "dependencies": {"@google-cloud/functions-framework": "^3.0.0"}}`,
+				"functions/hello/node_modules/@google-cloud/functions-framework/package.json": `{"version": "3.0.0"}`,
+				"functions/hello/fn.js": "",
+			},
+			envs:              []string{"GOOGLE_FUNCTION_SOURCE_SUBDIR=functions/hello"},
+			wantCommands:      []string{"node --check functions/hello/fn.js"},
+			doNotWantCommands: []string{"--prefix"},
 		},
 		{
-			name:    "env val set but 0",
-			env:     []string{"GOOGLE_CONTAINER_MEMORY_HINT_MB=0"},
-			wantErr: true,
+			name: "function in source subdirectory without package.json",
+			files: map[string]string{
+				"package.json": `{"main": "main.js", "dependencies": {"@google-cloud/functions-framework": "^3.0.0"}}`,
+				"node_modules/@google-cloud/functions-framework/package.json": `{"version": "3.0.0"}`,
+				"functions/hello/index.js":                                    "",
+			},
+			envs:         []string{"GOOGLE_FUNCTION_SOURCE_SUBDIR=functions/hello"},
+			wantCommands: []string{"node --check functions/hello/index.js"},
 		},
 		{
-			name:    "env val set but negative",
-			env:     []string{"GOOGLE_CONTAINER_MEMORY_HINT_MB=-10"},
-			wantErr: true,
+			name: "missing function in source subdirectory",
+			files: map[string]string{
+				"package.json":                 `{"dependencies": {"@google-cloud/functions-framework": "^3.0.0"}}`,
+				"index.js":                     "",
+				"functions/hello/package.json": "{}",
+			},
+			envs:         []string{"GOOGLE_FUNCTION_SOURCE_SUBDIR=functions/hello"},
+			wantExitCode: 1,
 		},
 		{
-			name:    "env val set not integer",
-			env:     []string{"GOOGLE_CONTAINER_MEMORY_HINT_MB=1a2b"},
-			wantErr: true,
+			name: "validate export of main",
+			files: map[string]string{
+				"package.json": `{"main": "main.js", "dependencies": {"@google-cloud/functions-framework": "^3.0.0"}}`,
+				"node_modules/@google-cloud/functions-framework/package.json": `{"version": "3.0.0"}`,
+				"main.js": "exports.testFunction = () => {};",
+			},
+			envs:         []string{"GOOGLE_FUNCTION_VALIDATE_EXPORT=true"},
+			wantCommands: []string{"node --check main.js", `node -e require\(require\('path'\).resolve\(process.env.FUNCTION_FILE\)\)`},
 		},
 		{
-			name:    "env val set but equal to head room",
-			env:     []string{"GOOGLE_CONTAINER_MEMORY_HINT_MB=" + strconv.Itoa(nodeJSHeadroomMB)},
-			wantErr: true,
+			name: "validate export of main fails",
+			files: map[string]string{
+				"package.json": `{"main": "main.js", "dependencies": {"@google-cloud/functions-framework": "^3.0.0"}}`,
+				"node_modules/@google-cloud/functions-framework/package.json": `{"version": "3.0.0"}`,
+				"main.js": "exports.otherFunction = () => {};",
+			},
+			envs:         []string{"GOOGLE_FUNCTION_VALIDATE_EXPORT=true"},
+			mocks:        []*mockprocess.Mock{mockprocess.New(`^node -e`, mockprocess.WithExitCode(1))},
+			wantExitCode: 1,
+		},
+		{
+			name: "export of main not validated by default",
+			files: map[string]string{
+				"package.json": `{"main": "main.js", "dependencies": {"@google-cloud/functions-framework": "^3.0.0"}}`,
+				"node_modules/@google-cloud/functions-framework/package.json": `{"version": "3.0.0"}`,
+				"main.js": "",
+			},
+			doNotWantCommands: []string{"node -e"},
+		},
+		{
+			name: "export of index.js not validated",
+			files: map[string]string{
+				"package.json": `{"dependencies": {"@google-cloud/functions-framework": "^3.0.0"}}`,
+				"node_modules/@google-cloud/functions-framework/package.json": `{"version": "3.0.0"}`,
+				"index.js": "",
+			},
+			envs:              []string{"GOOGLE_FUNCTION_VALIDATE_EXPORT=true"},
+			doNotWantCommands: []string{"node -e"},
 		},
 		{
-			name: "env val set and greater than head room",
-			env:  []string{"GOOGLE_CONTAINER_MEMORY_HINT_MB=4096"},
-			want: 4096 - nodeJSHeadroomMB,
+			name: "otel bootstrap",
+			files: map[string]string{
+				"package.json": `{"dependencies": {"@google-cloud/functions-framework": "^3.0.0"}}`,
+				"node_modules/@google-cloud/functions-framework/package.json": `{"version": "3.0.0"}`,
+				"index.js": "",
+			},
+			envs:         []string{"GOOGLE_NODEJS_ENABLE_OTEL=true"},
+			wantCommands: []string{"npm install --prefix [^\n]*otel [^\n]*@opentelemetry/sdk-node"},
+		},
+		{
+			name: "otel bootstrap with yarn pnp",
+			files: map[string]string{
+				"package.json": `{"dependencies": {"@google-cloud/functions-framework": "^3.0.0"}}`,
+				"yarn.lock":    "__metadata:\n  version: 6\n",
+				"index.js":     "",
+			},
+			envs:              []string{"GOOGLE_NODEJS_ENABLE_OTEL=true"},
+			mocks:             []*mockprocess.Mock{mockprocess.New(`^yarn config get nodeLinker$`, mockprocess.WithStdout("pnp"))},
+			wantExitCode:      1,
+			doNotWantCommands: []string{"npm install --prefix"},
+		},
+		{
+			name: "compiled typescript function",
+			files: map[string]string{
+				"package.json": `{"main": "dist/index.js", "scripts": {"build": "tsc"}, "dependencies": {"@google-cloud/functions-framework": "^3.0.0"}}`,
+				"node_modules/@google-cloud/functions-framework/package.json": `{"version": "3.0.0"}`,
+				"tsconfig.json": "{}",
+				"src/index.ts":  "",
+				"dist/index.js": "",
+			},
+			wantCommands:      []string{"node --check dist/index.js"},
+			doNotWantCommands: []string{"npm run build"},
+		},
+		{
+			name: "typescript function compiled by build script",
+			files: map[string]string{
+				"package.json":  `{"main": "dist/index.js", "scripts": {"build": "tsc"}, "dependencies": {"@google-cloud/functions-framework": "^3.0.0"}}`,
+				"yarn.lock":     "",
+				"tsconfig.json": "{}",
+				"src/index.ts":  "",
+			},
+			// The build script is mocked, so the compiled file is still missing.
+			wantExitCode: 1,
+			wantCommands: []string{"yarn run build"},
+		},
+		{
+			name: "compiled typescript function without build script",
+			files: map[string]string{
+				"package.json": `{"main": "dist/index.js", "dependencies": {"@google-cloud/functions-framework": "^3.0.0"}}`,
+				"node_modules/@google-cloud/functions-framework/package.json": `{"version": "3.0.0"}`,
+				"tsconfig.json": "{}",
+				"src/index.ts":  "",
+				"dist/index.js": "",
+			},
+			wantCommands:      []string{"node --check dist/index.js"},
+			doNotWantCommands: []string{"run build"},
+		},
+		{
+			name: "typescript function without build script",
+			files: map[string]string{
+				"package.json":  `{"main": "dist/index.js", "dependencies": {"@google-cloud/functions-framework": "^3.0.0"}}`,
+				"tsconfig.json": "{}",
+				"index.ts":      "",
+			},
+			wantExitCode:      1,
+			doNotWantCommands: []string{"run build", "node --check"},
+		},
+		{
+			name: "typescript main",
+			files: map[string]string{
+				"package.json":  `{"main": "index.ts", "scripts": {"build": "tsc"}, "dependencies": {"@google-cloud/functions-framework": "^3.0.0"}}`,
+				"tsconfig.json": "{}",
+				"index.ts":      "",
+			},
+			wantExitCode:      1,
+			doNotWantCommands: []string{"run build", "node --check"},
 		},
 	}
+
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			for _, keyVal := range tc.env {
-				setEnv(t, keyVal)
+			envs := append([]string{"GOOGLE_FUNCTION_TARGET=testFunction"}, tc.envs...)
+			opts := []buildpacktest.Option{
+				buildpacktest.WithTestName(tc.name),
+				buildpacktest.WithFiles(tc.files),
+				buildpacktest.WithEnvs(envs...),
+				buildpacktest.WithExecMocks(append(tc.mocks, mockprocess.New(`^node -v$`, mockprocess.WithStdout("v18.17.0")))...),
+			}
+			result, err := buildpacktest.RunBuild(t, buildFn, opts...)
+			if err != nil && tc.wantExitCode == 0 {
+				t.Fatalf("error running build: %v, logs: %s", err, result.Output)
 			}
 
-			got, err := getMaxOldSpaceSize()
-			gotErr := err != nil
+			if result.ExitCode != tc.wantExitCode {
+				t.Errorf("build exit code mismatch, got: %d, want: %d", result.ExitCode, tc.wantExitCode)
+			}
 
-			if gotErr != tc.wantErr {
-				t.Errorf("getMaxOldSpaceSize() got err=%t, want err=%t. err: %v", gotErr, tc.wantErr, err)
+			for _, cmd := range tc.wantCommands {
+				if !result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to be executed, but it was not, build output: %s", cmd, result.Output)
+				}
 			}
-			if got != tc.want {
-				t.Errorf("getMaxOldSpaceSize()=%d, want=%d", got, tc.want)
+
+			for _, cmd := range tc.doNotWantCommands {
+				if result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q not to be executed, but it was, build output: %s", cmd, result.Output)
+				}
 			}
 		})
 	}
 }
 
-func setEnv(t *testing.T, keyVal string) {
-	t.Helper()
+// TestValidateExport runs the validation with Node.js rather than a mock to check the script.
+func TestValidateExport(t *testing.T) {
+	if _, err := exec.LookPath("node"); err != nil {
+		t.Skip("node is not installed")
+	}
+	testCases := []struct {
+		name     string
+		fnFile   string
+		absolute bool
+		wantErr  bool
+	}{
+		{
+			name:   "exported",
+			fnFile: "main.js",
+		},
+		{
+			name:   "exported from subdirectory",
+			fnFile: "functions/hello/fn.js",
+		},
+		{
+			name:     "exported with absolute path",
+			fnFile:   "main.js",
+			absolute: true,
+		},
+		{
+			name:    "not exported",
+			fnFile:  "other.js",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			files := map[string]string{
+				"main.js":               "exports.testFunction = () => {};",
+				"functions/hello/fn.js": "exports.testFunction = () => {};",
+				"other.js":              "exports.otherFunction = () => {};",
+			}
+			for name, content := range files {
+				path := filepath.Join(dir, name)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			t.Setenv("GOOGLE_FUNCTION_VALIDATE_EXPORT", "true")
+			t.Setenv("GOOGLE_FUNCTION_TARGET", "testFunction")
+			fnFile := tc.fnFile
+			if tc.absolute {
+				fnFile = filepath.Join(dir, fnFile)
+			}
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(dir))
 
-	kv := strings.SplitN(keyVal, "=", 2)
-	if len(kv) != 2 {
-		t.Fatal("Want env var in form KEY=VALUE")
+			err := validateExport(ctx, fnFile, false)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("validateExport(%q) got error: %v, want error: %t", fnFile, err, tc.wantErr)
+			}
+		})
 	}
+}
+
+func TestAddWebProcess(t *testing.T) {
+	t.Setenv("GOOGLE_FUNCTIONS_FRAMEWORK_ARGS", "--debug")
+	ctx := gcp.NewContext()
 
-	old, oldPresent := os.LookupEnv(kv[0])
-	if err := os.Setenv(kv[0], kv[1]); err != nil {
-		t.Fatal(err)
+	if err := addWebProcess(ctx, "node_modules/.bin/functions-framework"); err != nil {
+		t.Fatalf("addWebProcess() got error: %v", err)
 	}
 
-	t.Cleanup(func() {
-		if oldPresent {
-			if err := os.Setenv(kv[0], old); err != nil {
-				t.Fatal(err)
-			}
-		} else if err := os.Unsetenv(kv[0]); err != nil {
-			t.Fatal(err)
-		}
-	})
+	processes := ctx.Processes()
+	if len(processes) != 1 {
+		t.Fatalf("addWebProcess() added %d processes, want 1", len(processes))
+	}
+	got := append([]string{processes[0].Command}, processes[0].Arguments...)
+	want := []string{"/bin/bash", "-c", `node_modules/.bin/functions-framework "$@"`, "functions-framework", "--debug"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("addWebProcess() command mismatch (-want +got):\n%s", diff)
+	}
 }
@@ -26,9 +26,12 @@ import (
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildermetrics"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/devmode"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/firebase/faherror"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/lockcheck"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/nodejs"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/runtimelibs"
 )
 
 const (
@@ -51,6 +54,11 @@ func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
 }
 
 func buildFn(ctx *gcp.Context) error {
+	// Copy the shared libraries that native modules load at runtime.
+	if err := runtimelibs.Install(ctx); err != nil {
+		return err
+	}
+
 	ml, err := ctx.Layer("npm_modules", gcp.BuildLayer, gcp.CacheLayer)
 	if err != nil {
 		return fmt.Errorf("creating layer: %w", err)
@@ -81,11 +89,34 @@ func buildFn(ctx *gcp.Context) error {
 		}
 		return fmt.Errorf("%s Error: %w", vendorError, err)
 	}
+	if err := nodejs.ValidateEngines(ctx, pjs, "npm"); err != nil {
+		return err
+	}
+	ctx.ReportPackageManager("npm")
+	if err := nodejs.ValidateLockfileVersion(ctx); err != nil {
+		return err
+	}
 
-	lockfile, err := nodejs.EnsureLockfile(ctx)
+	shrinkwrap, err := env.IsPresentAndTrue(nodejs.NPMShrinkwrapEnv)
 	if err != nil {
 		return err
 	}
+	var lockfile string
+	if shrinkwrap {
+		sl, err := ctx.Layer("npm_shrinkwrap", gcp.CacheLayer)
+		if err != nil {
+			return fmt.Errorf("creating layer: %w", err)
+		}
+		lockfile, err = nodejs.EnsureShrinkwrap(ctx, sl)
+		if err != nil {
+			return err
+		}
+	} else {
+		lockfile, err = nodejs.EnsureLockfile(ctx)
+		if err != nil {
+			return err
+		}
+	}
 
 	buildCmds, isCustomBuild := nodejs.DetermineBuildCommands(pjs, "npm")
 	// Respect the user's NODE_ENV value if it's set
@@ -106,7 +137,16 @@ func buildFn(ctx *gcp.Context) error {
 			return err
 		}
 	} else {
-		cached, err := nodejs.CheckOrClearCache(ctx, ml, cache.WithStrings(buildNodeEnv), cache.WithFiles("package.json", lockfile))
+		verifyLockfile, err := lockcheck.Verify()
+		if err != nil {
+			return err
+		}
+		cacheOpts := []cache.Option{cache.WithNamedString(nodejs.EnvNodeEnv, buildNodeEnv), cache.WithFiles("package.json", lockfile)}
+		if verifyLockfile {
+			// Cached dependencies are reused without "npm ci", so they must have been verified too.
+			cacheOpts = append(cacheOpts, cache.WithNamedString(env.VerifyLockfile, "true"))
+		}
+		cached, err := nodejs.CheckOrClearCache(ctx, ml, cacheOpts...)
 		if err != nil {
 			return fmt.Errorf("checking cache: %w", err)
 		}
@@ -127,8 +167,19 @@ func buildFn(ctx *gcp.Context) error {
 			if err != nil {
 				return err
 			}
+			if verifyLockfile {
+				installCmd = "ci"
+			}
 
-			if _, err := ctx.Exec([]string{"npm", installCmd, "--quiet", "--no-fund", "--no-audit"}, gcp.WithEnv("NODE_ENV="+buildNodeEnv), gcp.WithUserAttribution); err != nil {
+			result, err := ctx.Exec([]string{"npm", installCmd, "--quiet", "--no-fund", "--no-audit"}, gcp.WithEnv("NODE_ENV="+buildNodeEnv), gcp.WithUserAttribution)
+			if err != nil && installCmd == "ci" && result != nil && nodejs.IsLockfileOutOfSync(result.Combined) {
+				if verifyLockfile {
+					err = lockcheck.DriftError(lockcheck.NPM, lockfile, err)
+				} else {
+					err = installOutOfSync(ctx, lockfile, buildNodeEnv, err)
+				}
+			}
+			if err != nil {
 				return err
 			}
 			// Ensure node_modules exists even if no dependencies were installed.
@@ -141,6 +192,10 @@ func buildFn(ctx *gcp.Context) error {
 		}
 	}
 
+	if err := nodejs.NPMAudit(ctx); err != nil {
+		return err
+	}
+
 	if len(buildCmds) > 0 {
 		// If there are multiple build scripts to run, run them one-by-one so the logs are
 		// easier to understand.
@@ -177,6 +232,10 @@ NOTE: Running the default build script can be skipped by passing the empty envir
 		}
 	}
 
+	if err := installOTel(ctx); err != nil {
+		return err
+	}
+
 	el, err := ctx.Layer("env", gcp.BuildLayer, gcp.LaunchLayer)
 	if err != nil {
 		return fmt.Errorf("creating layer: %w", err)
@@ -189,6 +248,12 @@ NOTE: Running the default build script can be skipped by passing the empty envir
 	if err != nil {
 		return fmt.Errorf("detecting start command: %w", err)
 	}
+	cmd, err = nodejs.InspectCommand(ctx, cmd)
+	if err != nil {
+		return err
+	}
+
+	ctx.AddReleaseProcess(nodejs.ReleaseCommand(pjs, "npm"))
 
 	if !devmode.Enabled(ctx) {
 		ctx.AddWebProcess(cmd)
@@ -227,13 +292,36 @@ func shouldPrune(ctx *gcp.Context, pjs *nodejs.PackageJSON) (bool, error) {
 	return canPrune, err
 }
 
+// installOutOfSync handles an "npm ci" that failed because package.json and the lockfile are out of
+// sync. It runs "npm install" instead if GOOGLE_NPM_INSTALL_FALLBACK is set, and otherwise returns
+// a user error that explains how to fix the lockfile.
+func installOutOfSync(ctx *gcp.Context, lockfile, buildNodeEnv string, ciErr error) error {
+	fallback, err := env.IsPresentAndTrue(nodejs.NPMInstallFallbackEnv)
+	if err != nil {
+		return err
+	}
+	if !fallback {
+		return gcp.UserErrorf("package.json and %s are out of sync. Regenerate %s by running \"npm install\" with the npm version used by the build and commit it, or set %s=true to fall back to \"npm install\": %w", lockfile, lockfile, nodejs.NPMInstallFallbackEnv, ciErr)
+	}
+	ctx.Warnf("package.json and %s are out of sync, falling back to \"npm install\" because %s is set. The installed dependencies may differ from the lockfile.", lockfile, nodejs.NPMInstallFallbackEnv)
+	_, err = ctx.Exec([]string{"npm", "install", "--quiet", "--no-fund", "--no-audit"}, gcp.WithEnv("NODE_ENV="+buildNodeEnv), gcp.WithUserAttribution)
+	return err
+}
+
 func upgradeNPM(ctx *gcp.Context, pjs *nodejs.PackageJSON) error {
 	npmVersion, err := nodejs.RequestedNPMVersion(pjs)
 	if err != nil {
 		return err
 	}
 	if npmVersion == "" {
-		// if an NPM version was not requested, use whatever was bundled with Node.js.
+		// If an NPM version was not requested, use whatever was bundled with Node.js unless it cannot
+		// install from the lockfile.
+		npmVersion, err = nodejs.NPMVersionForLockfile(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	if npmVersion == "" {
 		return nil
 	}
 	npmLayer, err := ctx.Layer("npm", gcp.BuildLayer, gcp.LaunchLayer, gcp.CacheLayer)
@@ -258,3 +346,20 @@ func upgradeNPM(ctx *gcp.Context, pjs *nodejs.PackageJSON) error {
 	}
 	return nil
 }
+
+// installOTel installs the OpenTelemetry bootstrap if GOOGLE_NODEJS_ENABLE_OTEL is set.
+func installOTel(ctx *gcp.Context) error {
+	enabled, err := env.IsPresentAndTrue(nodejs.OTelEnv)
+	if err != nil || !enabled {
+		return err
+	}
+	// The functions framework buildpack sets NODE_OPTIONS for functions.
+	if _, ok := os.LookupEnv(env.FunctionTarget); ok {
+		return nil
+	}
+	l, err := ctx.Layer(nodejs.OTelLayer, gcp.CacheLayer, gcp.LaunchLayer)
+	if err != nil {
+		return fmt.Errorf("creating %v layer: %w", nodejs.OTelLayer, err)
+	}
+	return nodejs.InstallOTelBootstrap(ctx, l, false)
+}
@@ -16,6 +16,7 @@ package main
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	bpt "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
@@ -53,6 +54,9 @@ func TestDetect(t *testing.T) {
 	}
 }
 
+// lodashAuditReport is the output of `npm audit --json` for lodash@4.17.15.
+const lodashAuditReport = `{"auditReportVersion":2,"vulnerabilities":{"lodash":{"name":"lodash","severity":"high","isDirect":true,"via":[{"source":1096996,"name":"lodash","dependency":"lodash","title":"Prototype Pollution in lodash","url":"https://github.com/advisories/GHSA-p6mc-m468-83gw","severity":"high","range":">=3.7.0 <4.17.19"}],"effects":[],"range":"<=4.17.20","nodes":["node_modules/lodash"],"fixAvailable":true}}}`
+
 func TestBuild(t *testing.T) {
 	testCases := []struct {
 		name              string
@@ -63,6 +67,7 @@ func TestBuild(t *testing.T) {
 		wantExitCode      int // 0 if unspecified
 		wantCommands      []string
 		doNotWantCommands []string
+		wantOutput        string
 		files             map[string]string
 	}{
 		{
@@ -155,6 +160,214 @@ func TestBuild(t *testing.T) {
 				"node_modules/index.js": "",
 			},
 		},
+		{
+			name: "shrinkwrap not generated with existing lockfile",
+			envs: []string{nodejs.NPMShrinkwrapEnv + "=true"},
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^npm --version$`, mockprocess.WithStdout("0.0.0")),
+			},
+			files: map[string]string{
+				"package.json":      "{}",
+				"package-lock.json": "{}",
+			},
+			wantCommands: []string{
+				"npm install",
+			},
+			doNotWantCommands: []string{
+				"npm shrinkwrap",
+				"npm install --package-lock-only",
+			},
+		},
+		{
+			name: "engines.node not satisfied",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^node -v$`, mockprocess.WithStdout("v18.17.0")),
+				mockprocess.New(`^npm --version$`, mockprocess.WithStdout("0.0.0")),
+			},
+			files: map[string]string{
+				"package.json":      `{"engines": {"node": ">=20"}}`,
+				"package-lock.json": "{}",
+			},
+			wantExitCode: 1,
+			doNotWantCommands: []string{
+				"npm ci",
+			},
+		},
+		{
+			name: "engines.node not satisfied with warn only",
+			envs: []string{nodejs.EnginesWarnOnlyEnv + "=true"},
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^node -v$`, mockprocess.WithStdout("v18.17.0")),
+				mockprocess.New(`^npm --version$`, mockprocess.WithStdout("0.0.0")),
+			},
+			files: map[string]string{
+				"package.json":      `{"engines": {"node": ">=20"}}`,
+				"package-lock.json": "{}",
+			},
+			wantCommands: []string{
+				"npm install",
+			},
+		},
+		{
+			name: "otel bootstrap",
+			app:  "package_lock",
+			envs: []string{nodejs.OTelEnv + "=true"},
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^npm --version$`, mockprocess.WithStdout("0.0.0")),
+			},
+			wantCommands: []string{
+				"npm install --prefix [^\n]*otel [^\n]*@opentelemetry/auto-instrumentations-node",
+			},
+		},
+		{
+			name: "otel bootstrap left to functions framework",
+			app:  "package_lock",
+			envs: []string{nodejs.OTelEnv + "=true", "GOOGLE_FUNCTION_TARGET=testFunction"},
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^npm --version$`, mockprocess.WithStdout("0.0.0")),
+			},
+			doNotWantCommands: []string{
+				"npm install --prefix",
+			},
+		},
+		{
+			name: "lockfile out of sync",
+			files: map[string]string{
+				"package.json":      `{"dependencies": {"express": "^4.0.0"}}`,
+				"package-lock.json": `{"lockfileVersion": 3}`,
+			},
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^npm --version$`, mockprocess.WithStdout("10.2.0")),
+				mockprocess.New(`^npm ci`, mockprocess.WithStderr("npm ERR! `npm ci` can only install packages when your package.json and package-lock.json or npm-shrinkwrap.json are in sync."), mockprocess.WithExitCode(1)),
+			},
+			wantExitCode: 1,
+			doNotWantCommands: []string{
+				"npm install --quiet",
+			},
+		},
+		{
+			name: "lockfile out of sync with install fallback",
+			files: map[string]string{
+				"package.json":      `{"dependencies": {"express": "^4.0.0"}}`,
+				"package-lock.json": `{"lockfileVersion": 3}`,
+			},
+			envs: []string{"GOOGLE_NPM_INSTALL_FALLBACK=true"},
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^npm --version$`, mockprocess.WithStdout("10.2.0")),
+				mockprocess.New(`^npm ci`, mockprocess.WithStderr("npm ERR! `npm ci` can only install packages when your package.json and package-lock.json or npm-shrinkwrap.json are in sync."), mockprocess.WithExitCode(1)),
+			},
+			wantCommands: []string{
+				"npm install --quiet --no-fund --no-audit",
+			},
+		},
+		{
+			name: "verify lockfile uses npm ci",
+			app:  "package_lock",
+			envs: []string{"GOOGLE_VERIFY_LOCKFILE=true"},
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^npm --version$`, mockprocess.WithStdout("0.0.0")),
+			},
+			wantCommands: []string{
+				"npm ci --quiet --no-fund --no-audit",
+			},
+			doNotWantCommands: []string{
+				"npm install --quiet",
+			},
+		},
+		{
+			name: "verify lockfile out of sync ignores install fallback",
+			files: map[string]string{
+				"package.json":      `{"dependencies": {"express": "^4.0.0"}}`,
+				"package-lock.json": `{"lockfileVersion": 3}`,
+			},
+			envs: []string{"GOOGLE_VERIFY_LOCKFILE=true", "GOOGLE_NPM_INSTALL_FALLBACK=true"},
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^npm --version$`, mockprocess.WithStdout("10.2.0")),
+				mockprocess.New(`^npm ci`, mockprocess.WithStderr("npm ERR! `npm ci` can only install packages when your package.json and package-lock.json or npm-shrinkwrap.json are in sync."), mockprocess.WithExitCode(1)),
+			},
+			wantExitCode: 1,
+			doNotWantCommands: []string{
+				"npm install --quiet",
+			},
+		},
+		{
+			name: "lockfile version unsupported by requested npm",
+			files: map[string]string{
+				"package.json":      `{"engines": {"npm": "6.14.4"}}`,
+				"package-lock.json": `{"lockfileVersion": 3}`,
+			},
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^npm --version$`, mockprocess.WithStdout("6.14.4")),
+			},
+			wantExitCode: 1,
+			doNotWantCommands: []string{
+				"npm ci",
+			},
+		},
+		{
+			name: "migrate script registers release process",
+			files: map[string]string{
+				"package.json":      `{"scripts": {"start": "node server.js", "migrate": "prisma migrate deploy"}}`,
+				"package-lock.json": "{}",
+			},
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^npm --version$`, mockprocess.WithStdout("10.2.0")),
+			},
+			wantOutput: `Adding release process: "npm run migrate"`,
+		},
+		{
+			name: "release command",
+			files: map[string]string{
+				"package.json":      `{"scripts": {"start": "node server.js"}}`,
+				"package-lock.json": "{}",
+			},
+			envs: []string{"GOOGLE_RELEASE_COMMAND=npx sequelize-cli db:migrate"},
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^npm --version$`, mockprocess.WithStdout("10.2.0")),
+			},
+			wantOutput: `Adding release process from GOOGLE_RELEASE_COMMAND: "npx sequelize-cli db:migrate"`,
+		},
+		{
+			name: "npm audit fails on high vulnerabilities",
+			app:  "package_lock",
+			envs: []string{"GOOGLE_NPM_AUDIT_FAIL_LEVEL=high"},
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^npm --version$`, mockprocess.WithStdout("10.2.0")),
+				mockprocess.New(`^npm audit --json$`, mockprocess.WithStdout(lodashAuditReport), mockprocess.WithExitCode(1)),
+			},
+			wantExitCode: 1,
+			wantOutput:   "lodash (high): GHSA-p6mc-m468-83gw",
+		},
+		{
+			name: "npm audit below critical",
+			app:  "package_lock",
+			envs: []string{"GOOGLE_NPM_AUDIT_FAIL_LEVEL=critical"},
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^npm --version$`, mockprocess.WithStdout("10.2.0")),
+				mockprocess.New(`^npm audit --json$`, mockprocess.WithStdout(lodashAuditReport), mockprocess.WithExitCode(1)),
+			},
+			wantCommands: []string{"npm audit --json"},
+			wantOutput:   "npm audit found no vulnerabilities with severity critical or higher.",
+		},
+		{
+			name: "npm audit with invalid level",
+			app:  "package_lock",
+			envs: []string{"GOOGLE_NPM_AUDIT_FAIL_LEVEL=moderate"},
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^npm --version$`, mockprocess.WithStdout("10.2.0")),
+			},
+			wantExitCode:      1,
+			wantOutput:        `invalid value "moderate" for GOOGLE_NPM_AUDIT_FAIL_LEVEL`,
+			doNotWantCommands: []string{"npm audit"},
+		},
+		{
+			name: "npm audit not run by default",
+			app:  "package_lock",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^npm --version$`, mockprocess.WithStdout("10.2.0")),
+			},
+			doNotWantCommands: []string{"npm audit"},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -187,6 +400,10 @@ func TestBuild(t *testing.T) {
 					t.Errorf("expected command %q not to be executed, but it was, build output: %s", cmd, result.Output)
 				}
 			}
+
+			if !strings.Contains(result.Output, tc.wantOutput) {
+				t.Errorf("build output = %q, want output containing %q", result.Output, tc.wantOutput)
+			}
 		})
 	}
 }
@@ -25,7 +25,9 @@ import (
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/firebase/faherror"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/lockcheck"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/nodejs"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/runtimelibs"
 )
 
 const (
@@ -46,11 +48,12 @@ func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
 		return gcp.OptOutFileNotFound("package.json"), nil
 	}
 
-	pnpmLockExists, err := ctx.FileExists(nodejs.PNPMLock)
+	// In a monorepo the pnpm-lock.yaml may be shared by all workspaces at the workspace root.
+	pnpmLock, err := ctx.FindInWorkspace(nodejs.PNPMLock)
 	if err != nil {
 		return nil, err
 	}
-	if !pnpmLockExists {
+	if pnpmLock == "" {
 		return gcp.OptOutFileNotFound(nodejs.PNPMLock), nil
 	}
 
@@ -58,6 +61,11 @@ func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
 }
 
 func buildFn(ctx *gcp.Context) error {
+	// Copy the shared libraries that native modules load at runtime.
+	if err := runtimelibs.Install(ctx); err != nil {
+		return err
+	}
+
 	pjs, err := nodejs.ReadPackageJSONIfExists(ctx.ApplicationRoot())
 	if err != nil {
 		return err
@@ -65,6 +73,10 @@ func buildFn(ctx *gcp.Context) error {
 	if err := installPNPM(ctx, pjs); err != nil {
 		return gcp.InternalErrorf("installing pnpm: %w", err)
 	}
+	if err := nodejs.ValidateEngines(ctx, pjs, "pnpm"); err != nil {
+		return err
+	}
+	ctx.ReportPackageManager("pnpm")
 
 	if err := pnpmInstallModules(ctx, pjs); err != nil {
 		return err
@@ -78,7 +90,12 @@ func buildFn(ctx *gcp.Context) error {
 	el.SharedEnvironment.Default("NODE_ENV", nodejs.NodeEnv())
 
 	// Configure the entrypoint for production.
-	ctx.AddWebProcess([]string{"pnpm", "run", "start"})
+	cmd, err := nodejs.InspectCommand(ctx, []string{"pnpm", "run", "start"})
+	if err != nil {
+		return err
+	}
+	ctx.AddWebProcess(cmd)
+	ctx.AddReleaseProcess(nodejs.ReleaseCommand(pjs, "pnpm"))
 	return nil
 }
 
@@ -95,8 +112,18 @@ func pnpmInstallModules(ctx *gcp.Context, pjs *nodejs.PackageJSON) error {
 			buildNodeEnv = nodejs.EnvProduction
 		}
 	}
+	verifyLockfile, err := lockcheck.Verify()
+	if err != nil {
+		return err
+	}
 	cmd := []string{"pnpm", "install"}
-	if _, err := ctx.Exec(cmd, gcp.WithUserAttribution, gcp.WithEnv("CI=true"), gcp.WithEnv("NODE_ENV="+buildNodeEnv)); err != nil {
+	if verifyLockfile {
+		cmd = append(cmd, lockcheck.InstallFlags(lockcheck.PNPM)...)
+	}
+	if result, err := ctx.Exec(cmd, gcp.WithUserAttribution, gcp.WithEnv("CI=true"), gcp.WithEnv("NODE_ENV="+buildNodeEnv)); err != nil {
+		if verifyLockfile && result != nil && lockcheck.IsDrift(lockcheck.PNPM, result.Combined) {
+			return lockcheck.DriftError(lockcheck.PNPM, "", err)
+		}
 		return gcp.UserErrorf("installing pnpm dependencies: %w", err)
 	}
 	if len(buildCmds) > 0 {
@@ -19,6 +19,7 @@ package main
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
@@ -29,6 +30,7 @@ import (
 
 const (
 	nodeLayer           = "node"
+	nodeOptionsLayer    = "node_options"
 	runtimeVersionLabel = "runtime_version"
 )
 
@@ -86,10 +88,40 @@ func buildFn(ctx *gcp.Context) error {
 		ctx.AddLabel(runtimeVersionLabel, string(runtime.Nodejs)+majorVersion)
 	}
 
-	nrl, err := ctx.Layer(nodeLayer, gcp.BuildLayer, gcp.CacheLayer, gcp.LaunchLayerUnlessSkipRuntimeLaunch)
+	skip, err := runtime.SkipInstall()
 	if err != nil {
-		return fmt.Errorf("creating %v layer: %w", nodeLayer, err)
+		return err
+	}
+	var installedVersion string
+	if skip {
+		installedVersion, err = runtime.CheckPreinstalled(ctx, runtime.Nodejs, version)
+		if err != nil {
+			return err
+		}
+	} else {
+		installedVersion, err = runtime.ResolveVersion(ctx, runtime.Nodejs, version, runtime.OSForStack(ctx))
+		if err != nil {
+			return fmt.Errorf("resolving version %s: %w", version, err)
+		}
+		nrl, err := ctx.Layer(nodeLayer, gcp.BuildLayer, gcp.CacheLayer, gcp.LaunchLayerUnlessSkipRuntimeLaunch)
+		if err != nil {
+			return fmt.Errorf("creating %v layer: %w", nodeLayer, err)
+		}
+		if _, err = runtime.InstallTarballIfNotCached(ctx, runtime.Nodejs, installedVersion, nrl); err != nil {
+			return err
+		}
+	}
+
+	opts, err := nodejs.NodeOptions(ctx, installedVersion)
+	if err != nil {
+		return err
+	}
+	if len(opts) > 0 {
+		l, err := ctx.Layer(nodeOptionsLayer, gcp.LaunchLayer)
+		if err != nil {
+			return fmt.Errorf("creating %v layer: %w", nodeOptionsLayer, err)
+		}
+		l.LaunchEnvironment.Prepend("NODE_OPTIONS", " ", strings.Join(opts, " "))
 	}
-	_, err = runtime.InstallTarballIfNotCached(ctx, runtime.Nodejs, version, nrl)
-	return err
+	return nil
 }
@@ -26,14 +26,23 @@ import (
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/devmode"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/fileutil"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/firebase/faherror"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/lockcheck"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/nodejs"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/runtimelibs"
+	"github.com/buildpacks/libcnb"
 )
 
 const (
-	cacheTag  = "prod dependencies"
-	yarnLayer = "yarn_engine"
+	cacheTag          = "prod dependencies"
+	yarnLayer         = "yarn_engine"
+	yarnPluginsLayer  = "yarn_plugins"
+	workspaceToolsPkg = "workspace-tools"
+	// workspaceToolsPlugin is the path of the workspace-tools plugin imported by Yarn, relative to
+	// the application root.
+	workspaceToolsPlugin = ".yarn/plugins/@yarnpkg/plugin-workspace-tools.cjs"
 )
 
 func main() {
@@ -49,11 +58,12 @@ func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
 		return gcp.OptOutFileNotFound("package.json"), nil
 	}
 
-	yarnLockExists, err := ctx.FileExists(nodejs.YarnLock)
+	// In a monorepo the yarn.lock may be shared by all workspaces at the workspace root.
+	yarnLock, err := ctx.FindInWorkspace(nodejs.YarnLock)
 	if err != nil {
 		return nil, err
 	}
-	if !yarnLockExists {
+	if yarnLock == "" {
 		return gcp.OptOutFileNotFound("yarn.lock"), nil
 	}
 
@@ -61,6 +71,11 @@ func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
 }
 
 func buildFn(ctx *gcp.Context) error {
+	// Copy the shared libraries that native modules load at runtime.
+	if err := runtimelibs.Install(ctx); err != nil {
+		return err
+	}
+
 	pjs, err := nodejs.ReadPackageJSONIfExists(ctx.ApplicationRoot())
 	if err != nil {
 		return err
@@ -68,28 +83,56 @@ func buildFn(ctx *gcp.Context) error {
 	if err := installYarn(ctx, pjs); err != nil {
 		return fmt.Errorf("installing Yarn: %w", err)
 	}
+	if err := nodejs.ValidateEngines(ctx, pjs, "yarn"); err != nil {
+		return err
+	}
+	ctx.ReportPackageManager("yarn")
 
-	if yarn2, err := nodejs.IsYarn2(ctx.ApplicationRoot()); err != nil {
+	yarnLock, err := ctx.FindInWorkspace(nodejs.YarnLock)
+	if err != nil {
+		return err
+	}
+	yarn2, err := nodejs.IsYarn2(filepath.Dir(yarnLock))
+	if err != nil {
+		return err
+	}
+	el, err := ctx.Layer("env", gcp.BuildLayer, gcp.LaunchLayer)
+	if err != nil {
+		return fmt.Errorf("creating layer: %w", err)
+	}
+	excluded, restoreWorkspaces, err := nodejs.ExcludeYarnWorkspaces(ctx, pjs, yarnLock)
+	if err != nil {
 		return err
-	} else if yarn2 {
-		if err := yarn2InstallModules(ctx, pjs); err != nil {
+	}
+	skipWorkspaces := len(excluded) > 0
+	if yarn2 {
+		if err := setYarn2BuildEnv(ctx, el); err != nil {
+			return err
+		}
+		if err := yarn2InstallModules(ctx, pjs, skipWorkspaces); err != nil {
 			return err
 		}
 	} else {
-		if err := yarn1InstallModules(ctx, pjs); err != nil {
+		if err := yarn1InstallModules(ctx, pjs, yarnLock, skipWorkspaces); err != nil {
 			return err
 		}
 	}
-
-	el, err := ctx.Layer("env", gcp.BuildLayer, gcp.LaunchLayer)
-	if err != nil {
-		return fmt.Errorf("creating layer: %w", err)
+	if err := restoreWorkspaces(); err != nil {
+		return err
+	}
+	if err := installOTel(ctx, yarn2); err != nil {
+		return err
 	}
+
 	el.SharedEnvironment.Prepend("PATH", string(os.PathListSeparator), filepath.Join(ctx.ApplicationRoot(), "node_modules", ".bin"))
 	el.SharedEnvironment.Default("NODE_ENV", nodejs.NodeEnv())
 
 	// Configure the entrypoint for production.
-	cmd := []string{"yarn", "run", "start"}
+	cmd, err := nodejs.InspectCommand(ctx, []string{"yarn", "run", "start"})
+	if err != nil {
+		return err
+	}
+	ctx.AddReleaseProcess(nodejs.ReleaseCommand(pjs, "yarn"))
 
 	if !devmode.Enabled(ctx) {
 		ctx.AddWebProcess(cmd)
@@ -107,11 +150,15 @@ func buildFn(ctx *gcp.Context) error {
 	return nil
 }
 
-func yarn1InstallModules(ctx *gcp.Context, pjs *nodejs.PackageJSON) error {
+func yarn1InstallModules(ctx *gcp.Context, pjs *nodejs.PackageJSON, yarnLock string, skipWorkspaces bool) error {
 	freezeLockfile, err := nodejs.UseFrozenLockfile(ctx)
 	if err != nil {
 		return err
 	}
+	verifyLockfile, err := lockcheck.Verify()
+	if err != nil {
+		return err
+	}
 
 	ml, err := ctx.Layer("yarn_modules", gcp.BuildLayer, gcp.CacheLayer, gcp.LaunchLayer)
 	if err != nil {
@@ -122,7 +169,7 @@ func yarn1InstallModules(ctx *gcp.Context, pjs *nodejs.PackageJSON) error {
 		return fmt.Errorf("generating Artifact Registry credentials: %w", err)
 	}
 
-	_, err = nodejs.CheckOrClearCache(ctx, ml, cache.WithFiles("package.json", nodejs.YarnLock))
+	_, err = nodejs.CheckOrClearCache(ctx, ml, cache.WithFiles("package.json", yarnLock))
 	if err != nil {
 		return fmt.Errorf("checking cache: %w", err)
 	}
@@ -161,10 +208,17 @@ func yarn1InstallModules(ctx *gcp.Context, pjs *nodejs.PackageJSON) error {
 	// Always run yarn install to execute customer's lifecycle hooks.
 	cmd := []string{"yarn", "install", "--non-interactive", "--prefer-offline", locationFlag}
 
-	// HACK: For backwards compatibility on App Engine Node.js 10 and older, skip using `--frozen-lockfile`.
-	if freezeLockfile {
+	// HACK: For backwards compatibility on App Engine Node.js 10 and older, skip using `--frozen-lockfile`
+	// unless the lockfile must be verified.
+	if verifyLockfile {
+		cmd = append(cmd, lockcheck.InstallFlags(lockcheck.Yarn)...)
+	} else if freezeLockfile {
 		cmd = append(cmd, "--frozen-lockfile")
 	}
+	if skipWorkspaces {
+		// The optional dependencies of the skipped workspaces may still be listed in the lockfile.
+		cmd = append(cmd, "--ignore-optional")
+	}
 	gcpBuild := nodejs.HasGCPBuild(pjs)
 	appHostingBuildScriptPresent := nodejs.HasApphostingBuild(pjs)
 	appHostingBuildEnv, appHostingBuildEnvPresent := os.LookupEnv(nodejs.AppHostingBuildEnv)
@@ -177,7 +231,10 @@ func yarn1InstallModules(ctx *gcp.Context, pjs *nodejs.PackageJSON) error {
 
 	// Add the layer's node_modules/.bin to the path so it is available in postinstall scripts.
 	nodeBin := filepath.Join(layerModules, ".bin")
-	if _, err := ctx.Exec(cmd, gcp.WithUserAttribution, gcp.WithEnv(fmt.Sprintf("PATH=%s:%s", os.Getenv("PATH"), nodeBin))); err != nil {
+	if result, err := ctx.Exec(cmd, gcp.WithUserAttribution, gcp.WithEnv(fmt.Sprintf("PATH=%s:%s", os.Getenv("PATH"), nodeBin))); err != nil {
+		if verifyLockfile && result != nil && lockcheck.IsDrift(lockcheck.Yarn, result.Combined) {
+			return lockcheck.DriftError(lockcheck.Yarn, "", err)
+		}
 		return err
 	}
 
@@ -207,12 +264,23 @@ func yarn1InstallModules(ctx *gcp.Context, pjs *nodejs.PackageJSON) error {
 				// rely on the node_modules folder at this point.
 				return nil
 			}
-			// For Yarn1, setting `--production=true` causes all `devDependencies` to be deleted.
 			ctx.Logf("Pruning devDependencies")
+			pruned, err := nodejs.PruneYarn1DevDependencies(ctx, pjs, yarnLock, layerModules)
+			if err != nil {
+				return err
+			}
+			if pruned {
+				return nil
+			}
+			// For Yarn1, setting `--production=true` causes all `devDependencies` to be deleted.
+			ctx.Logf("Pruning devDependencies with yarn install")
 			cmd := []string{"yarn", "install", "--ignore-scripts", "--prefer-offline", "--production=true", locationFlag}
-			if freezeLockfile {
+			if freezeLockfile || verifyLockfile {
 				cmd = append(cmd, "--frozen-lockfile")
 			}
+			if skipWorkspaces {
+				cmd = append(cmd, "--ignore-optional")
+			}
 			if _, err := ctx.Exec(cmd, gcp.WithUserAttribution); err != nil {
 				return err
 			}
@@ -222,23 +290,49 @@ func yarn1InstallModules(ctx *gcp.Context, pjs *nodejs.PackageJSON) error {
 	return nil
 }
 
-func yarn2InstallModules(ctx *gcp.Context, pjs *nodejs.PackageJSON) error {
+// setYarn2BuildEnv configures Yarn 2+ to keep its package cache and hardlinks within the project,
+// for this buildpack and the ones that run after it. By default Yarn may share a global cache
+// (~/.yarn/berry/cache) and hardlink packages across projects, which lets one build poison the
+// packages used by another in shared build environments.
+func setYarn2BuildEnv(ctx *gcp.Context, l *libcnb.Layer) error {
+	for _, kv := range [][2]string{
+		{"YARN_ENABLE_GLOBAL_CACHE", "false"},
+		{"YARN_NM_MODE", "hardlinks-local"},
+	} {
+		l.BuildEnvironment.Override(kv[0], kv[1])
+		if err := ctx.Setenv(kv[0], kv[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func yarn2InstallModules(ctx *gcp.Context, pjs *nodejs.PackageJSON, skipWorkspaces bool) error {
 	if err := ar.GenerateYarnConfig(ctx); err != nil {
 		return fmt.Errorf("generating Artifact Registry credentials: %w", err)
 	}
 
 	cmd := []string{"yarn", "install", "--immutable"}
-	yarnCacheExists, err := ctx.FileExists(ctx.ApplicationRoot(), ".yarn", "cache")
-	if err != nil {
-		return err
-	}
-	// In Plug'n'Play mode (https://yarnpkg.com/features/pnp) all dependencies must be included in
-	// the Yarn cache. The --immutable-cache option will abort the install with an error if anything
-	// is missing or out of date.
-	if yarnCacheExists {
-		cmd = append(cmd, "--immutable-cache")
+	var opts []gcp.ExecOption
+	if skipWorkspaces {
+		// Excluding workspaces removes their entries from the lockfile and the cache, which an
+		// immutable install rejects. The lockfile is restored after the build.
+		ctx.Logf("Installing without --immutable because %s is set.", nodejs.YarnWorkspacesNoInstallEnv)
+		cmd = []string{"yarn", "install"}
+		opts = append(opts, gcp.WithEnv("YARN_ENABLE_IMMUTABLE_INSTALLS=false"))
+	} else {
+		yarnCacheExists, err := ctx.FileExists(ctx.ApplicationRoot(), ".yarn", "cache")
+		if err != nil {
+			return err
+		}
+		// In Plug'n'Play mode (https://yarnpkg.com/features/pnp) all dependencies must be included in
+		// the Yarn cache. The --immutable-cache option will abort the install with an error if
+		// anything is missing or out of date.
+		if yarnCacheExists {
+			cmd = append(cmd, "--immutable-cache")
+		}
 	}
-	if _, err := ctx.Exec(cmd, gcp.WithUserAttribution); err != nil {
+	if _, err := ctx.Exec(cmd, append(opts, gcp.WithUserAttribution)...); err != nil {
 		return err
 	}
 
@@ -253,6 +347,13 @@ func yarn2InstallModules(ctx *gcp.Context, pjs *nodejs.PackageJSON) error {
 		}
 	}
 
+	return yarn2PruneDevDependencies(ctx, pjs)
+}
+
+// yarn2PruneDevDependencies removes the devDependencies of a production build with the Yarn
+// workspace-tools plugin. If the project does not include the plugin, it is imported when
+// GOOGLE_YARN_AUTO_IMPORT_PLUGINS is true, otherwise the devDependencies are kept.
+func yarn2PruneDevDependencies(ctx *gcp.Context, pjs *nodejs.PackageJSON) error {
 	// If there are no devDependencies, there is nothing to prune. We are done.
 	if !nodejs.HasDevDependencies(pjs) {
 		return nil
@@ -268,8 +369,18 @@ func yarn2InstallModules(ctx *gcp.Context, pjs *nodejs.PackageJSON) error {
 		return err
 	}
 	if !hasWorkPlugin {
-		ctx.Warnf("Keeping devDependencies because the Yarn workspace-tools plugin is not installed. You can add it to your project by running 'yarn plugin import workspace-tools'")
-		return nil
+		autoImport, err := env.IsPresentAndTrue(nodejs.YarnAutoImportPluginsEnv)
+		if err != nil {
+			return err
+		}
+		if !autoImport {
+			ctx.Warnf("Keeping devDependencies because the Yarn workspace-tools plugin is not installed. You can add it to your project by running 'yarn plugin import workspace-tools', or set %s=true to import it during the build.", nodejs.YarnAutoImportPluginsEnv)
+			return nil
+		}
+		if err := importWorkspaceToolsPlugin(ctx); err != nil {
+			ctx.Warnf("Keeping devDependencies because the Yarn workspace-tools plugin could not be imported: %v", err)
+			return nil
+		}
 	}
 	// For Yarn2, dependency pruning is via the workspaces plugin.
 	ctx.Logf("Pruning devDependencies")
@@ -279,6 +390,36 @@ func yarn2InstallModules(ctx *gcp.Context, pjs *nodejs.PackageJSON) error {
 	return nil
 }
 
+// importWorkspaceToolsPlugin imports the Yarn workspace-tools plugin into the project. The plugin is
+// kept in a cache layer so that it is only downloaded by the first build.
+func importWorkspaceToolsPlugin(ctx *gcp.Context) error {
+	l, err := ctx.Layer(yarnPluginsLayer, gcp.CacheLayer)
+	if err != nil {
+		return fmt.Errorf("creating %v layer: %w", yarnPluginsLayer, err)
+	}
+	cached := filepath.Join(l.Path, filepath.Base(workspaceToolsPlugin))
+	cacheHit, err := ctx.FileExists(cached)
+	if err != nil {
+		return err
+	}
+	source := workspaceToolsPkg
+	if cacheHit {
+		source = cached
+	}
+	ctx.Logf("Importing the Yarn workspace-tools plugin to prune devDependencies because %s is set.", nodejs.YarnAutoImportPluginsEnv)
+	if _, err := ctx.Exec([]string{"yarn", "plugin", "import", source}, gcp.WithUserAttribution); err != nil {
+		return err
+	}
+	if cacheHit {
+		return nil
+	}
+	if err := fileutil.CopyFile(cached, filepath.Join(ctx.ApplicationRoot(), workspaceToolsPlugin)); err != nil {
+		// The plugin was imported, so failing to cache it only affects the next build.
+		ctx.Warnf("Failed to cache the Yarn workspace-tools plugin: %v", err)
+	}
+	return nil
+}
+
 func installYarn(ctx *gcp.Context, pjs *nodejs.PackageJSON) error {
 	yrl, err := ctx.Layer(yarnLayer, gcp.BuildLayer, gcp.CacheLayer, gcp.LaunchLayer)
 	if err != nil {
@@ -286,3 +427,26 @@ func installYarn(ctx *gcp.Context, pjs *nodejs.PackageJSON) error {
 	}
 	return nodejs.InstallYarnLayer(ctx, yrl, pjs)
 }
+
+// installOTel installs the OpenTelemetry bootstrap if GOOGLE_NODEJS_ENABLE_OTEL is set.
+func installOTel(ctx *gcp.Context, yarn2 bool) error {
+	enabled, err := env.IsPresentAndTrue(nodejs.OTelEnv)
+	if err != nil || !enabled {
+		return err
+	}
+	// The functions framework buildpack sets NODE_OPTIONS for functions.
+	if _, ok := os.LookupEnv(env.FunctionTarget); ok {
+		return nil
+	}
+	yarnPnP := false
+	if yarn2 {
+		if yarnPnP, err = nodejs.IsYarnPnP(ctx); err != nil {
+			return err
+		}
+	}
+	l, err := ctx.Layer(nodejs.OTelLayer, gcp.CacheLayer, gcp.LaunchLayer)
+	if err != nil {
+		return fmt.Errorf("creating %v layer: %w", nodejs.OTelLayer, err)
+	}
+	return nodejs.InstallOTelBootstrap(ctx, l, yarnPnP)
+}
@@ -15,9 +15,15 @@
 package main
 
 import (
+	"strings"
 	"testing"
 
 	buildpacktest "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/nodejs"
+	"github.com/buildpacks/libcnb"
+	"github.com/google/go-cmp/cmp"
 )
 
 func TestDetect(t *testing.T) {
@@ -65,3 +71,103 @@ func TestDetect(t *testing.T) {
 		})
 	}
 }
+
+func TestSetYarn2BuildEnv(t *testing.T) {
+	// Restore the process environment modified by setYarn2BuildEnv after the test.
+	t.Setenv("YARN_ENABLE_GLOBAL_CACHE", "")
+	t.Setenv("YARN_NM_MODE", "")
+	l := &libcnb.Layer{BuildEnvironment: libcnb.Environment{}}
+
+	if err := setYarn2BuildEnv(gcp.NewContext(), l); err != nil {
+		t.Fatalf("setYarn2BuildEnv() got error: %v", err)
+	}
+
+	want := libcnb.Environment{
+		"YARN_ENABLE_GLOBAL_CACHE.override": "false",
+		"YARN_NM_MODE.override":             "hardlinks-local",
+	}
+	if diff := cmp.Diff(want, l.BuildEnvironment); diff != "" {
+		t.Errorf("setYarn2BuildEnv() build environment mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestBuild(t *testing.T) {
+	pjs := &nodejs.PackageJSON{DevDependencies: map[string]string{"typescript": "^5.4.0"}}
+	pluginMissing := mockprocess.New(`^yarn plugin runtime`, mockprocess.WithStdout("- @yarnpkg/plugin-typescript"))
+	testCases := []struct {
+		name              string
+		envs              []string
+		files             map[string]string
+		mocks             []*mockprocess.Mock
+		wantCommands      []string
+		doNotWantCommands []string
+		wantOutput        string
+	}{
+		{
+			name: "plugin installed",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^yarn plugin runtime`, mockprocess.WithStdout("- @yarnpkg/plugin-workspace-tools")),
+			},
+			wantCommands:      []string{"yarn workspaces focus --all --production"},
+			doNotWantCommands: []string{"yarn plugin import"},
+		},
+		{
+			name:              "plugin missing",
+			mocks:             []*mockprocess.Mock{pluginMissing},
+			doNotWantCommands: []string{"yarn plugin import", "yarn workspaces focus"},
+			wantOutput:        "set GOOGLE_YARN_AUTO_IMPORT_PLUGINS=true to import it during the build",
+		},
+		{
+			name:         "plugin missing with auto import",
+			envs:         []string{"GOOGLE_YARN_AUTO_IMPORT_PLUGINS=true"},
+			files:        map[string]string{workspaceToolsPlugin: "module.exports = {};"},
+			mocks:        []*mockprocess.Mock{pluginMissing},
+			wantCommands: []string{"yarn plugin import workspace-tools", "yarn workspaces focus --all --production"},
+		},
+		{
+			name: "plugin import fails",
+			envs: []string{"GOOGLE_YARN_AUTO_IMPORT_PLUGINS=true"},
+			mocks: []*mockprocess.Mock{
+				pluginMissing,
+				mockprocess.New(`^yarn plugin import`, mockprocess.WithExitCode(1)),
+			},
+			doNotWantCommands: []string{"yarn workspaces focus"},
+			wantOutput:        "Keeping devDependencies because the Yarn workspace-tools plugin could not be imported",
+		},
+		{
+			name:              "development build",
+			mocks:             []*mockprocess.Mock{pluginMissing},
+			envs:              []string{"NODE_ENV=development", "GOOGLE_YARN_AUTO_IMPORT_PLUGINS=true"},
+			doNotWantCommands: []string{"yarn plugin", "yarn workspaces focus"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := buildpacktest.RunBuild(t, func(ctx *gcp.Context) error {
+				return yarn2PruneDevDependencies(ctx, pjs)
+			},
+				buildpacktest.WithTestName(tc.name),
+				buildpacktest.WithEnvs(tc.envs...),
+				buildpacktest.WithFiles(tc.files),
+				buildpacktest.WithExecMocks(tc.mocks...),
+			)
+			if err != nil {
+				t.Fatalf("error running build: %v, logs: %s", err, result.Output)
+			}
+
+			for _, cmd := range tc.wantCommands {
+				if !result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to be executed, but it was not, build output: %s", cmd, result.Output)
+				}
+			}
+			for _, cmd := range tc.doNotWantCommands {
+				if result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q not to be executed, but it was, build output: %s", cmd, result.Output)
+				}
+			}
+			if !strings.Contains(result.Output, tc.wantOutput) {
+				t.Errorf("build output = %q, want output containing %q", result.Output, tc.wantOutput)
+			}
+		})
+	}
+}
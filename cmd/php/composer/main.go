@@ -43,8 +43,14 @@ func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
 }
 
 func buildFn(ctx *gcp.Context) error {
-	_, err := php.ComposerInstall(ctx, cacheTag)
+	devDependencies, err := php.ComposerDevDependencies()
 	if err != nil {
+		return err
+	}
+	if devDependencies {
+		ctx.Warnf("Installing the require-dev packages of composer.json in the application image because %s is set.", php.ComposerDevDependenciesEnv)
+	}
+	if _, err := php.ComposerInstall(ctx, cacheTag, devDependencies); err != nil {
 		return fmt.Errorf("composer install: %w", err)
 	}
 
@@ -18,6 +18,7 @@ import (
 	"testing"
 
 	buildpacktest "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
 )
 
 func TestDetect(t *testing.T) {
@@ -48,3 +49,55 @@ func TestDetect(t *testing.T) {
 		})
 	}
 }
+
+func TestBuild(t *testing.T) {
+	testCases := []struct {
+		name              string
+		envs              []string
+		wantExitCode      int // 0 if unspecified
+		wantCommands      []string
+		doNotWantCommands []string
+	}{
+		{
+			name:         "without dev dependencies",
+			wantCommands: []string{"composer install --no-dev --no-progress --no-interaction --optimize-autoloader"},
+		},
+		{
+			name:              "with dev dependencies",
+			envs:              []string{"GOOGLE_COMPOSER_DEV_DEPENDENCIES=true"},
+			wantCommands:      []string{"composer install --no-progress --no-interaction --optimize-autoloader"},
+			doNotWantCommands: []string{"--no-dev"},
+		},
+		{
+			name:         "invalid dev dependencies",
+			envs:         []string{"GOOGLE_COMPOSER_DEV_DEPENDENCIES=maybe"},
+			wantExitCode: 1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := buildpacktest.RunBuild(t, buildFn,
+				buildpacktest.WithTestName(tc.name),
+				buildpacktest.WithFiles(map[string]string{"composer.json": `{"require-dev": {"phpunit/phpunit": "^9.6"}}`}),
+				buildpacktest.WithEnvs(tc.envs...),
+				buildpacktest.WithExecMocks(mockprocess.New(`^php -r`, mockprocess.WithStdout("8.3.0"))),
+			)
+			if err != nil && tc.wantExitCode == 0 {
+				t.Fatalf("error running build: %v, logs: %s", err, result.Output)
+			}
+			if result.ExitCode != tc.wantExitCode {
+				t.Errorf("build exit code mismatch, got: %d, want: %d", result.ExitCode, tc.wantExitCode)
+			}
+			for _, cmd := range tc.wantCommands {
+				if !result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to be executed, but it was not, build output: %s", cmd, result.Output)
+				}
+			}
+			for _, cmd := range tc.doNotWantCommands {
+				if result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q not to be executed, but it was, build output: %s", cmd, result.Output)
+				}
+			}
+		})
+	}
+}
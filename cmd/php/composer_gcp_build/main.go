@@ -52,15 +52,14 @@ func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
 }
 
 func buildFn(ctx *gcp.Context) error {
-	_, err := php.ComposerInstall(ctx, cacheTag)
-	if err != nil {
+	// The gcp-build script may need the require-dev packages, e.g. to compile assets.
+	if _, err := php.ComposerInstall(ctx, cacheTag, true); err != nil {
 		return fmt.Errorf("composer install: %w", err)
 	}
 
-	if _, err := ctx.Exec([]string{"composer", "run-script", "--timeout=600", "--no-dev", "gcp-build"}, gcp.WithUserAttribution); err != nil {
-		return err
-	}
-	if err := ctx.RemoveAll(php.Vendor); err != nil {
+	// google.php.composer runs after this buildpack and reinstalls vendor/ without the require-dev
+	// packages unless GOOGLE_COMPOSER_DEV_DEPENDENCIES is set.
+	if _, err := ctx.Exec([]string{"composer", "run-script", "--timeout=600", "gcp-build"}, gcp.WithUserAttribution); err != nil {
 		return err
 	}
 	return nil
@@ -156,7 +156,7 @@ func handleNoComposerJSON(ctx *gcp.Context) error {
 			return err
 		}
 
-		if _, err := php.ComposerInstall(ctx, cacheTag); err != nil {
+		if _, err := php.ComposerInstall(ctx, cacheTag, false); err != nil {
 			return fmt.Errorf("composer install: %w", err)
 		}
 
@@ -60,6 +60,15 @@ func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
 }
 
 func buildFn(ctx *gcp.Context) error {
+	// Validate the configured error_reporting level before installing the runtime.
+	errorIni, err := php.ErrorReportingIni()
+	if err != nil {
+		return err
+	}
+	opcacheIni, err := php.OpcacheIni(ctx)
+	if err != nil {
+		return err
+	}
 	version, err := php.ExtractVersion(ctx)
 	if version == "" {
 		version = "8.3.x"
@@ -76,15 +85,26 @@ func buildFn(ctx *gcp.Context) error {
 	// Selecting PHPMin runtime only for Google-22 Builder.
 	phpInstallableRuntime := php.GetInstallableRuntime(ctx)
 
-	_, err = runtime.InstallTarballIfNotCached(ctx, phpInstallableRuntime, version, phpl)
+	skip, err := runtime.SkipInstall()
 	if err != nil {
 		return err
 	}
+	if skip {
+		if _, err := runtime.CheckPreinstalled(ctx, runtime.PHP, version); err != nil {
+			return err
+		}
+	} else {
+		if _, err := runtime.InstallTarballIfNotCached(ctx, phpInstallableRuntime, version, phpl); err != nil {
+			return err
+		}
+		setPeclConfig(phpl)
+		setPHPFpmConfig(phpl)
+	}
 
-	setPeclConfig(phpl)
-	setPHPFpmConfig(phpl)
-
-	return addPHPIni(ctx, phpl)
+	if err := addPHPIni(ctx, phpl, errorIni); err != nil {
+		return err
+	}
+	return addOpcacheIni(ctx, phpl, opcacheIni)
 }
 
 func setPeclConfig(phpl *libcnb.Layer) {
@@ -96,7 +116,7 @@ func setPHPFpmConfig(phpl *libcnb.Layer) {
 	phpl.LaunchEnvironment.Append("PATH", string(os.PathListSeparator), filepath.Join(phpl.Path, "sbin"))
 }
 
-func addPHPIni(ctx *gcp.Context, phpl *libcnb.Layer) error {
+func addPHPIni(ctx *gcp.Context, phpl *libcnb.Layer, errorIni string) error {
 	destDir := filepath.Join(phpl.Path, "etc")
 	destPath := filepath.Join(destDir, phpIniName)
 
@@ -104,7 +124,7 @@ func addPHPIni(ctx *gcp.Context, phpl *libcnb.Layer) error {
 		return fmt.Errorf("creating etc folder: %w", err)
 	}
 
-	if err := ctx.WriteFile(destPath, []byte(php.PHPIni), os.FileMode(0755)); err != nil {
+	if err := ctx.WriteFile(destPath, []byte(php.PHPIni+errorIni), os.FileMode(0755)); err != nil {
 		return err
 	}
 
@@ -112,3 +132,22 @@ func addPHPIni(ctx *gcp.Context, phpl *libcnb.Layer) error {
 	phpl.LaunchEnvironment.Default("PHPRC", destDir)
 	return nil
 }
+
+// addOpcacheIni writes the OPcache settings to a directory of the layer that PHP scans for
+// additional ini files at launch.
+func addOpcacheIni(ctx *gcp.Context, phpl *libcnb.Layer, opcacheIni string) error {
+	if opcacheIni == "" {
+		return nil
+	}
+	destDir := filepath.Join(phpl.Path, "etc", "conf.d")
+	if err := ctx.MkdirAll(destDir, 0755); err != nil {
+		return fmt.Errorf("creating conf.d folder: %w", err)
+	}
+	if err := ctx.WriteFile(filepath.Join(destDir, php.OpcacheIniName), []byte(opcacheIni), 0644); err != nil {
+		return err
+	}
+	ctx.Logf("Enabled OPcache with %s.", php.OpcacheEnv)
+	// The leading separator keeps the scan directory compiled into PHP.
+	phpl.LaunchEnvironment.Default("PHP_INI_SCAN_DIR", string(os.PathListSeparator)+destDir)
+	return nil
+}
@@ -15,9 +15,13 @@
 package main
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	buildpacktest "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
 )
 
 func TestDetect(t *testing.T) {
@@ -77,3 +81,45 @@ func TestDetect(t *testing.T) {
 		})
 	}
 }
+
+func TestAddOpcacheIni(t *testing.T) {
+	testCases := []struct {
+		name       string
+		opcacheIni string
+		wantIni    bool
+	}{
+		{
+			name:       "enabled",
+			opcacheIni: "opcache.enable=1\n",
+			wantIni:    true,
+		},
+		{
+			name: "disabled",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			l := &libcnb.Layer{Path: t.TempDir(), LaunchEnvironment: libcnb.Environment{}}
+
+			if err := addOpcacheIni(gcp.NewContext(), l, tc.opcacheIni); err != nil {
+				t.Fatalf("addOpcacheIni() got error: %v", err)
+			}
+
+			confDir := filepath.Join(l.Path, "etc", "conf.d")
+			content, err := os.ReadFile(filepath.Join(confDir, "opcache.ini"))
+			if gotIni := err == nil; gotIni != tc.wantIni {
+				t.Fatalf("reading opcache.ini got error: %v, want ini: %t", err, tc.wantIni)
+			}
+			if tc.wantIni && string(content) != tc.opcacheIni {
+				t.Errorf("opcache.ini = %q, want %q", content, tc.opcacheIni)
+			}
+			wantScanDir := ""
+			if tc.wantIni {
+				wantScanDir = ":" + confDir
+			}
+			if got := l.LaunchEnvironment["PHP_INI_SCAN_DIR.default"]; got != wantScanDir {
+				t.Errorf("PHP_INI_SCAN_DIR = %q, want %q", got, wantScanDir)
+			}
+		})
+	}
+}
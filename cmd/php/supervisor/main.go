@@ -10,9 +10,11 @@ import (
 	"text/template"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/appyaml"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/flex"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/nginx"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/php"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/webconfig"
 )
 
@@ -68,6 +70,11 @@ func buildFn(ctx *gcp.Context) error {
 	}
 
 	overrides := webconfig.OverriddenProperties(ctx, runtimeConfig)
+	stubStatus, err := env.IsPresentAndTrue(php.StubStatusEnv)
+	if err != nil {
+		return err
+	}
+	overrides.NginxStubStatus = stubStatus
 	webconfig.SetEnvVariables(l, overrides)
 
 	fpmConfFile, err := writeFpmConfig(l.Path, overrides)
@@ -169,6 +176,7 @@ func nginxConfig(layer string, overrides webconfig.OverrideProperties) nginx.Con
 		FrontControllerScript: frontController,
 		Root:                  filepath.Join(defaultRoot, overrides.DocumentRoot),
 		AppListenAddress:      defaultAddress,
+		StubStatus:            overrides.NginxStubStatus,
 	}
 
 	if overrides.NginxServerConfInclude {
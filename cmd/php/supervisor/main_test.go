@@ -58,6 +58,17 @@ func TestNginxConfig(t *testing.T) {
 				NginxConfInclude:      "/workspace/include.conf",
 			},
 		},
+		{
+			name:      "stub status enabled",
+			overrides: webconfig.OverrideProperties{NginxStubStatus: true},
+			want: nginx.Config{
+				Port:                  8080,
+				FrontControllerScript: "index.php",
+				Root:                  "/workspace",
+				AppListenAddress:      defaultAddress,
+				StubStatus:            true,
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -23,6 +23,7 @@ import (
 	"path/filepath"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/appyaml"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/devmode"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/nginx"
@@ -87,29 +88,68 @@ func buildFn(ctx *gcp.Context) error {
 		overrides.NginxConfOverrideFileName = filepath.Join(defaultRoot, customNginxConf)
 	}
 
+	if devmode.Enabled(ctx) {
+		devmode.AddSyncMetadata(ctx, devmode.PHPSyncRules)
+	}
+
+	server, explicit, err := php.Server(ctx)
+	if err != nil {
+		return err
+	}
+	if server != php.ServerFPM {
+		if err := php.CheckServerRequirements(ctx, server); err != nil {
+			if explicit {
+				return err
+			}
+			ctx.Warnf("composer.json requires laravel/octane but the application cannot run with Laravel Octane, using php-fpm and nginx instead: %v", err)
+			server = php.ServerFPM
+		}
+	}
+
+	procExists, err := ctx.FileExists("Procfile")
+	if err != nil {
+		return err
+	}
+	_, entrypointExists := os.LookupEnv(env.Entrypoint)
+	entrypointExists = entrypointExists || gcp.HasEntrypoint()
+
+	if server != php.ServerFPM {
+		// The server replaces php-fpm and nginx, so their configuration is not generated.
+		if !procExists && !entrypointExists {
+			return addServerProcess(ctx, server, overrides)
+		}
+		return nil
+	}
+
 	nginxServesStaticFiles, err := env.IsPresentAndTrue(php.NginxServesStaticFiles)
 	if err != nil {
 		return err
 	}
 	overrides.NginxServesStaticFiles = nginxServesStaticFiles
 
-	fpmConfFile, err := writeFpmConfig(ctx, l.Path, overrides)
+	securityHeaders, err := env.IsPresentAndTrue(php.SecurityHeadersEnv)
 	if err != nil {
 		return err
 	}
-	defer fpmConfFile.Close()
+	overrides.NginxSecurityHeaders = securityHeaders
 
-	nginxServerConfFile, err := writeNginxServerConfig(l.Path, overrides)
+	stubStatus, err := env.IsPresentAndTrue(php.StubStatusEnv)
 	if err != nil {
 		return err
 	}
-	defer nginxServerConfFile.Close()
+	overrides.NginxStubStatus = stubStatus
 
-	procExists, err := ctx.FileExists("Procfile")
+	fpmConfFile, err := writeFpmConfig(ctx, l.Path, overrides)
 	if err != nil {
 		return err
 	}
-	_, entrypointExists := os.LookupEnv(env.Entrypoint)
+	defer fpmConfFile.Close()
+
+	nginxServerConfFile, err := writeNginxServerConfig(l.Path, overrides)
+	if err != nil {
+		return err
+	}
+	defer nginxServerConfFile.Close()
 
 	if !procExists && !entrypointExists {
 		cmd := []string{
@@ -128,12 +168,55 @@ func buildFn(ctx *gcp.Context) error {
 		}
 		cmd = append(cmd, addArgs...)
 
-		ctx.AddProcess(gcp.WebProcess, cmd, gcp.AsDefaultProcess())
+		return addWebProcess(ctx, cmd)
 	}
 
 	return nil
 }
 
+// addWebProcess sets cmd as the default web process. In dev mode, cmd is run by a file watcher that
+// restarts the server when a PHP file or template changes.
+func addWebProcess(ctx *gcp.Context, cmd []string) error {
+	if !devmode.Enabled(ctx) {
+		ctx.AddProcess(gcp.WebProcess, cmd, gcp.AsDefaultProcess())
+		return nil
+	}
+	if err := devmode.AddFileWatcherProcess(ctx, devmode.Config{
+		RunCmd: cmd,
+		Ext:    devmode.PHPWatchedExtensions,
+	}); err != nil {
+		return fmt.Errorf("adding devmode file watcher: %w", err)
+	}
+	return nil
+}
+
+// addServerProcess registers a web process that runs the given long-running PHP server bound to
+// PORT instead of php-fpm behind nginx.
+func addServerProcess(ctx *gcp.Context, server string, overrides webconfig.OverrideProperties) error {
+	if server == php.ServerOctane && overrides.DocumentRoot != "" {
+		ctx.Warnf("Ignoring the document root %q: Laravel Octane serves the public directory of the Laravel application.", overrides.DocumentRoot)
+	}
+	ctx.Logf("Serving the application with %s instead of php-fpm and nginx.", server)
+	return addWebProcess(ctx, []string{"/bin/bash", "-c", serverCommand(server, overrides)})
+}
+
+// serverCommand returns the shell command that runs the given server bound to all interfaces on
+// PORT, or 8080 if PORT is not set.
+func serverCommand(server string, overrides webconfig.OverrideProperties) string {
+	if server == php.ServerFrankenPHP {
+		return fmt.Sprintf("frankenphp php-server --listen 0.0.0.0:${PORT:-%d} --root %s", defaultNginxPort, documentRoot(overrides))
+	}
+	return fmt.Sprintf("php artisan octane:start --server=swoole --host=0.0.0.0 --port=${PORT:-%d}", defaultNginxPort)
+}
+
+// documentRoot returns the directory that the server serves files from.
+func documentRoot(overrides webconfig.OverrideProperties) string {
+	if overrides.DocumentRoot != "" {
+		return filepath.Join(defaultRoot, overrides.DocumentRoot)
+	}
+	return defaultRoot
+}
+
 func getInstalledPhpVersion(ctx *gcp.Context) (string, error) {
 	version, err := php.ExtractVersion(ctx)
 	if err != nil {
@@ -243,17 +326,14 @@ func nginxConfig(layer string, overrides webconfig.OverrideProperties) nginx.Con
 		frontController = overrides.FrontController
 	}
 
-	root := defaultRoot
-	if overrides.DocumentRoot != "" {
-		root = filepath.Join(defaultRoot, overrides.DocumentRoot)
-	}
-
 	nginx := nginx.Config{
 		Port:                  defaultNginxPort,
 		FrontControllerScript: frontController,
-		Root:                  root,
+		Root:                  documentRoot(overrides),
 		AppListenAddress:      "unix:" + filepath.Join(layer, appSocket),
 		ServesStaticFiles:     overrides.NginxServesStaticFiles,
+		SecurityHeaders:       overrides.NginxSecurityHeaders,
+		StubStatus:            overrides.NginxStubStatus,
 	}
 
 	if env.IsFlex() {
@@ -22,8 +22,10 @@ import (
 	"testing"
 
 	bpt "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/php"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/webconfig"
 	"github.com/google/go-cmp/cmp"
 )
@@ -192,3 +194,114 @@ func TestAddNginxConfCmdArgs(t *testing.T) {
 	}
 
 }
+
+func TestBuildServer(t *testing.T) {
+	octaneComposerJSON := `{"require": {"laravel/framework": "^11.0", "laravel/octane": "^2.3"}}`
+	testCases := []struct {
+		name         string
+		files        map[string]string
+		envs         []string
+		mocks        []*mockprocess.Mock
+		wantOutput   string
+		wantExitCode int
+	}{
+		{
+			name:       "octane detected",
+			files:      map[string]string{"artisan": "", "composer.json": octaneComposerJSON},
+			mocks:      []*mockprocess.Mock{mockprocess.New(`^php -m$`, mockprocess.WithStdout("[PHP Modules]\nCore\nswoole\n"))},
+			wantOutput: "Serving the application with octane instead of php-fpm and nginx.",
+		},
+		{
+			name:         "octane without swoole",
+			files:        map[string]string{"artisan": "", "composer.json": octaneComposerJSON},
+			envs:         []string{php.ServerEnv + "=octane"},
+			mocks:        []*mockprocess.Mock{mockprocess.New(`^php -m$`, mockprocess.WithStdout("[PHP Modules]\nCore\n"))},
+			wantOutput:   "Laravel Octane requires the swoole or openswoole PHP extension",
+			wantExitCode: 1,
+		},
+		{
+			name:         "octane without artisan",
+			files:        map[string]string{"composer.json": octaneComposerJSON},
+			envs:         []string{php.ServerEnv + "=octane"},
+			wantOutput:   "requires a Laravel application with an artisan script",
+			wantExitCode: 1,
+		},
+		{
+			name:       "frankenphp",
+			files:      map[string]string{"index.php": ""},
+			envs:       []string{php.ServerEnv + "=frankenphp"},
+			mocks:      []*mockprocess.Mock{mockprocess.New(`command -v frankenphp`, mockprocess.WithStdout("/usr/local/bin/frankenphp"))},
+			wantOutput: "Serving the application with frankenphp instead of php-fpm and nginx.",
+		},
+		{
+			name:  "frankenphp in dev mode",
+			files: map[string]string{"index.php": ""},
+			envs:  []string{php.ServerEnv + "=frankenphp", env.DevMode + "=true"},
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`command -v frankenphp`, mockprocess.WithStdout("/usr/local/bin/frankenphp")),
+				mockprocess.New(`watchexec`),
+			},
+			wantOutput: "Installing watchexec",
+		},
+		{
+			name:         "frankenphp not installed",
+			files:        map[string]string{"index.php": ""},
+			envs:         []string{php.ServerEnv + "=frankenphp"},
+			mocks:        []*mockprocess.Mock{mockprocess.New(`command -v frankenphp`, mockprocess.WithStdout(""))},
+			wantOutput:   "requires the frankenphp binary",
+			wantExitCode: 1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := bpt.RunBuild(t, buildFn,
+				bpt.WithTestName(tc.name),
+				bpt.WithFiles(tc.files),
+				bpt.WithEnvs(tc.envs...),
+				bpt.WithExecMocks(tc.mocks...),
+			)
+			if err != nil && tc.wantExitCode == 0 {
+				t.Fatalf("error running build: %v, result: %#v", err, result)
+			}
+			if result.ExitCode != tc.wantExitCode {
+				t.Errorf("build exit code mismatch, got: %d, want: %d", result.ExitCode, tc.wantExitCode)
+			}
+			if !strings.Contains(result.Output, tc.wantOutput) {
+				t.Errorf("build output does not contain %q, got:\n%s", tc.wantOutput, result.Output)
+			}
+		})
+	}
+}
+
+func TestServerCommand(t *testing.T) {
+	testCases := []struct {
+		name      string
+		server    string
+		overrides webconfig.OverrideProperties
+		want      string
+	}{
+		{
+			name:   "octane",
+			server: php.ServerOctane,
+			want:   "php artisan octane:start --server=swoole --host=0.0.0.0 --port=${PORT:-8080}",
+		},
+		{
+			name:   "frankenphp",
+			server: php.ServerFrankenPHP,
+			want:   "frankenphp php-server --listen 0.0.0.0:${PORT:-8080} --root /workspace",
+		},
+		{
+			name:      "frankenphp with document root",
+			server:    php.ServerFrankenPHP,
+			overrides: webconfig.OverrideProperties{DocumentRoot: "public"},
+			want:      "frankenphp php-server --listen 0.0.0.0:${PORT:-8080} --root /workspace/public",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := serverCommand(tc.server, tc.overrides); got != tc.want {
+				t.Errorf("serverCommand(%q, %+v) = %q, want %q", tc.server, tc.overrides, got, tc.want)
+			}
+		})
+	}
+}
@@ -18,10 +18,13 @@ package main
 
 import (
 	"fmt"
+	"path/filepath"
 	"regexp"
+	"strings"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/appengine"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/appstart"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/appyaml"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/Masterminds/semver"
@@ -30,6 +33,9 @@ import (
 var (
 	versionRegexp = regexp.MustCompile(`(?m)^Version:\s+(.*)$`)
 	minVersion    = semver.MustParse("19.0.0")
+	// appSpecRegexp matches a WSGI or ASGI application reference such as "main:app" or
+	// "mysite.wsgi:create_app()", capturing the module name.
+	appSpecRegexp = regexp.MustCompile(`^([A-Za-z_][\w.]*):[A-Za-z_][\w.]*(\(.*\))?$`)
 )
 
 func main() {
@@ -47,7 +53,108 @@ func buildFn(ctx *gcp.Context) error {
 	if err := validateAppEngineAPIs(ctx); err != nil {
 		return err
 	}
-	return appengine.Build(ctx, "python", entrypoint)
+	eg, err := entrypointGenerator(ctx)
+	if err != nil {
+		return err
+	}
+	return appengine.Build(ctx, "python", eg)
+}
+
+// entrypointGenerator returns the generator of the entrypoint that appengine.Build writes to the
+// start config. The entrypoint is chosen with the following precedence:
+//  1. GOOGLE_ENTRYPOINT or GOOGLE_ENTRYPOINT_JSON, which appengine.Build uses before the generator
+//  2. The entrypoint in app.yaml
+//  3. The default entrypoint
+//
+// A Procfile is never used on App Engine.
+func entrypointGenerator(ctx *gcp.Context) (appstart.EntrypointGenerator, error) {
+	procExists, err := ctx.FileExists("Procfile")
+	if err != nil {
+		return nil, err
+	}
+	if procExists {
+		ctx.Logf("Ignoring Procfile: App Engine uses %s or the entrypoint in app.yaml instead.", env.Entrypoint)
+	}
+	e, err := ctx.Entrypoint()
+	if err != nil {
+		return nil, err
+	}
+	if e != nil {
+		ctx.Logf("Using entrypoint from %s: %s (takes precedence over the app.yaml entrypoint)", e.Source, e)
+		return nil, nil
+	}
+	ep, err := appyaml.DeclaredEntrypoint(ctx.ApplicationRoot())
+	if err != nil {
+		return nil, err
+	}
+	if ep == "" {
+		ctx.Logf("No entrypoint in %s or app.yaml, using the default entrypoint.", env.Entrypoint)
+		return entrypoint, nil
+	}
+	if err := validateEntrypointModules(ctx, ep); err != nil {
+		return nil, err
+	}
+	ctx.Logf("Using entrypoint from app.yaml: %s", ep)
+	return func(*gcp.Context) (*appstart.Entrypoint, error) {
+		return &appstart.Entrypoint{Type: appstart.EntrypointUser.String(), Command: ep}, nil
+	}, nil
+}
+
+// validateEntrypointModules returns a user error if the app.yaml entrypoint cannot be parsed or if
+// it references a Python file or application module that does not exist in the application source.
+// Only the last application reference, e.g. "main:app", is checked because earlier ones may be
+// option values such as a gunicorn worker class.
+func validateEntrypointModules(ctx *gcp.Context, ep string) error {
+	args, err := env.SplitArgs(ep)
+	if err != nil {
+		return gcp.UserErrorf("parsing entrypoint %q in app.yaml: %v", ep, err)
+	}
+	if len(args) == 0 {
+		return gcp.UserErrorf("entrypoint in app.yaml must not be empty")
+	}
+
+	dir := ctx.ApplicationRoot()
+	var appModule string
+	var pyFiles []string
+	for i, arg := range args {
+		switch {
+		case arg == "--chdir" && i+1 < len(args):
+			dir = filepath.Join(ctx.ApplicationRoot(), args[i+1])
+		case strings.HasPrefix(arg, "--chdir="):
+			dir = filepath.Join(ctx.ApplicationRoot(), strings.TrimPrefix(arg, "--chdir="))
+		case strings.HasPrefix(arg, "-") || strings.Contains(arg, "$"):
+		case strings.HasSuffix(arg, ".py"):
+			pyFiles = append(pyFiles, arg)
+		default:
+			if m := appSpecRegexp.FindStringSubmatch(arg); m != nil {
+				appModule = m[1]
+			}
+		}
+	}
+
+	for _, f := range pyFiles {
+		exists, err := ctx.FileExists(dir, f)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return gcp.UserErrorf("entrypoint %q in app.yaml references %s, which does not exist in the application source", ep, f)
+		}
+	}
+	if appModule == "" {
+		return nil
+	}
+	modPath := filepath.Join(strings.Split(appModule, ".")...)
+	for _, candidate := range []string{modPath + ".py", filepath.Join(modPath, "__init__.py")} {
+		exists, err := ctx.FileExists(dir, candidate)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return nil
+		}
+	}
+	return gcp.UserErrorf("entrypoint %q in app.yaml references module %q, but neither %s.py nor %s/__init__.py exists in the application source", ep, appModule, modPath, modPath)
 }
 
 func validateAppEngineAPIs(ctx *gcp.Context) error {
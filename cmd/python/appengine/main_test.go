@@ -15,7 +15,11 @@
 package main
 
 import (
+	"strings"
 	"testing"
+
+	buildpacktest "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
 )
 
 func TestExtractVersion(t *testing.T) {
@@ -51,3 +55,138 @@ func TestExtractVersion(t *testing.T) {
 		})
 	}
 }
+
+func TestBuild(t *testing.T) {
+	testCases := []struct {
+		name         string
+		files        map[string]string
+		envs         []string
+		wantExitCode int // 0 if unspecified
+		wantOutput   []string
+	}{
+		{
+			name: "entrypoint from app.yaml",
+			files: map[string]string{
+				"app.yaml": "entrypoint: gunicorn -b :$PORT --workers 4 --threads 8 main:app\n",
+				"main.py":  "",
+			},
+			envs: []string{"GAE_APPLICATION_YAML_PATH=app.yaml"},
+			wantOutput: []string{
+				"Using entrypoint from app.yaml: gunicorn -b :$PORT --workers 4 --threads 8 main:app",
+				`Entrypoint:appstart.Entrypoint{Type:"User", Command:"gunicorn -b :$PORT --workers 4 --threads 8 main:app"`,
+			},
+		},
+		{
+			name: "entrypoint from app.yaml with package module",
+			files: map[string]string{
+				"app.yaml":               "entrypoint: gunicorn -k uvicorn.workers:UvicornWorker --chdir src mysite.wsgi:application\n",
+				"src/mysite/wsgi.py":     "",
+				"src/mysite/__init__.py": "",
+			},
+			envs:       []string{"GAE_APPLICATION_YAML_PATH=app.yaml"},
+			wantOutput: []string{"Using entrypoint from app.yaml"},
+		},
+		{
+			name: "GOOGLE_ENTRYPOINT takes precedence over app.yaml",
+			files: map[string]string{
+				"app.yaml": "entrypoint: gunicorn -b :$PORT main:app\n",
+			},
+			envs:       []string{"GAE_APPLICATION_YAML_PATH=app.yaml", "GOOGLE_ENTRYPOINT=gunicorn -b :$PORT other:app"},
+			wantOutput: []string{"Using entrypoint from GOOGLE_ENTRYPOINT: gunicorn -b :$PORT other:app (takes precedence over the app.yaml entrypoint)"},
+		},
+		{
+			name: "GOOGLE_ENTRYPOINT_JSON takes precedence over app.yaml",
+			files: map[string]string{
+				"app.yaml": "entrypoint: gunicorn -b :$PORT main:app\n",
+			},
+			envs: []string{"GAE_APPLICATION_YAML_PATH=app.yaml", `GOOGLE_ENTRYPOINT_JSON=["gunicorn", "-b", ":8080", "other:app"]`},
+			wantOutput: []string{
+				"Using entrypoint from GOOGLE_ENTRYPOINT_JSON: gunicorn -b :8080 other:app (takes precedence over the app.yaml entrypoint)",
+				`Entrypoint:appstart.Entrypoint{Type:"User", Command:"gunicorn -b :8080 other:app"`,
+			},
+		},
+		{
+			name: "Procfile is ignored",
+			files: map[string]string{
+				"app.yaml": "entrypoint: gunicorn -b :$PORT main:app\n",
+				"main.py":  "",
+				"Procfile": "web: gunicorn -b :$PORT other:app\n",
+			},
+			envs: []string{"GAE_APPLICATION_YAML_PATH=app.yaml"},
+			wantOutput: []string{
+				"Ignoring Procfile",
+				"Using entrypoint from app.yaml: gunicorn -b :$PORT main:app",
+			},
+		},
+		{
+			name: "default entrypoint",
+			files: map[string]string{
+				"main.py": "",
+			},
+			wantOutput: []string{
+				"No entrypoint in GOOGLE_ENTRYPOINT or app.yaml, using the default entrypoint.",
+				`Entrypoint:appstart.Entrypoint{Type:"Default", Command:"/serve"`,
+			},
+		},
+		{
+			name: "missing module",
+			files: map[string]string{
+				"app.yaml": "entrypoint: gunicorn -b :$PORT main:app\n",
+			},
+			envs:         []string{"GAE_APPLICATION_YAML_PATH=app.yaml"},
+			wantExitCode: 1,
+			wantOutput:   []string{`references module "main"`},
+		},
+		{
+			name: "missing python file",
+			files: map[string]string{
+				"app.yaml": "entrypoint: python3 server.py\n",
+			},
+			envs:         []string{"GAE_APPLICATION_YAML_PATH=app.yaml"},
+			wantExitCode: 1,
+			wantOutput:   []string{"references server.py"},
+		},
+		{
+			name: "empty entrypoint",
+			files: map[string]string{
+				"app.yaml": "entrypoint: ''\n",
+			},
+			envs:         []string{"GAE_APPLICATION_YAML_PATH=app.yaml"},
+			wantExitCode: 1,
+			wantOutput:   []string{"entrypoint must not be empty"},
+		},
+		{
+			name: "unparseable entrypoint",
+			files: map[string]string{
+				"app.yaml": "entrypoint: gunicorn -b :$PORT 'main:app\n",
+			},
+			envs:         []string{"GAE_APPLICATION_YAML_PATH=app.yaml"},
+			wantExitCode: 1,
+			wantOutput:   []string{"in app.yaml: unterminated ' quote"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := buildpacktest.RunBuild(t, buildFn,
+				buildpacktest.WithTestName(tc.name),
+				buildpacktest.WithFiles(tc.files),
+				buildpacktest.WithEnvs(tc.envs...),
+				buildpacktest.WithExecMocks(
+					mockprocess.New(`pip show appengine-python-standard`, mockprocess.WithExitCode(1)),
+					mockprocess.New(`pip show gunicorn`, mockprocess.WithStdout("Version: 22.0.0\n")),
+				),
+			)
+			if err != nil && tc.wantExitCode == 0 {
+				t.Fatalf("error running build: %v, logs: %s", err, result.Output)
+			}
+			if result.ExitCode != tc.wantExitCode {
+				t.Errorf("build exit code mismatch, got: %d, want: %d", result.ExitCode, tc.wantExitCode)
+			}
+			for _, want := range tc.wantOutput {
+				if !strings.Contains(result.Output, want) {
+					t.Errorf("build output does not contain %q, build output: %s", want, result.Output)
+				}
+			}
+		})
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"strings"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/cloudfunctions"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
@@ -30,6 +31,18 @@ import (
 
 const (
 	layerName = "functions-framework"
+	// packageShim is the name of the file generated to load a function source inside a package.
+	packageShim = "function_source.py"
+	// packageShimTemplate imports the function module by its dotted name so that relative imports
+	// within its package work, and exposes the module's members to the functions framework, which
+	// loads FUNCTION_SOURCE by path as a top-level module.
+	packageShimTemplate = `# Generated by the python functions_framework buildpack to load %[1]s as a package module.
+import importlib
+import sys
+
+sys.path.insert(0, %[2]q)
+globals().update({k: v for k, v in vars(importlib.import_module(%[1]q)).items() if not k.startswith("__")})
+`
 )
 
 var (
@@ -49,34 +62,52 @@ func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
 }
 
 func buildFn(ctx *gcp.Context) error {
-	if err := validateSource(ctx); err != nil {
+	subdir, err := cloudfunctions.SourceSubdir(ctx)
+	if err != nil {
+		return err
+	}
+	fnSource, err := validateSource(ctx, subdir)
+	if err != nil {
 		return err
 	}
 
 	// Check for syntax errors to prevent failures that would only manifest at run time.
-	if _, err := ctx.Exec([]string{"python3", "-m", "compileall", "-f", "-q", "."}, gcp.WithStdoutTail, gcp.WithUserAttribution); err != nil {
+	compileDir := "."
+	if subdir != "" {
+		compileDir = subdir
+	}
+	if _, err := ctx.Exec([]string{"python3", "-m", "compileall", "-f", "-q", compileDir}, gcp.WithStdoutTail, gcp.WithUserAttribution); err != nil {
 		return err
 	}
 
 	// Determine if the function has dependency on functions-framework.
-	hasFrameworkDependency := false
-	requirementsExists, err := ctx.FileExists("requirements.txt")
+	hasFrameworkDependency, err := requirementsContainFF(ctx, "requirements.txt")
 	if err != nil {
 		return err
 	}
-	if requirementsExists {
-		content, err := ctx.ReadFile("requirements.txt")
-		if err != nil {
-			return err
-		}
-		hasFrameworkDependency = containsFF(string(content))
-	}
 
 	// Install functions-framework if necessary.
 	l, err := ctx.Layer(layerName, gcp.LaunchLayer, gcp.BuildLayer)
 	if err != nil {
 		return fmt.Errorf("creating %v layer: %w", layerName, err)
 	}
+	if subdir != "" {
+		ctx.Logf("Building function from %s=%q", env.FunctionSourceSubdir, subdir)
+		subdirRequirements := filepath.Join(subdir, "requirements.txt")
+		subdirHasFF, err := requirementsContainFF(ctx, subdirRequirements)
+		if err != nil {
+			return err
+		}
+		hasFrameworkDependency = hasFrameworkDependency || subdirHasFF
+		subdirRequirementsExists, err := ctx.FileExists(subdirRequirements)
+		if err != nil {
+			return err
+		}
+		if subdirRequirementsExists {
+			// The pip buildpack installs the requirements and keys its cache on their contents.
+			l.BuildEnvironment.Append(python.RequirementsFilesEnv, string(os.PathListSeparator), filepath.Join(ctx.ApplicationRoot(), subdirRequirements))
+		}
+	}
 	if hasFrameworkDependency {
 		ctx.Logf("Handling functions with dependency on functions-framework.")
 		if err := ctx.ClearLayer(l); err != nil {
@@ -95,36 +126,123 @@ func buildFn(ctx *gcp.Context) error {
 		ctx.Logf("Adding functions-framework requirements.txt to the list of requirements files to install.")
 		r := filepath.Join(ctx.BuildpackRoot(), "converter", "requirements.txt")
 		l.BuildEnvironment.Append(python.RequirementsFilesEnv, string(os.PathListSeparator), r)
+		// The framework version label is added by the pip buildpack once the framework is installed.
+		l.BuildEnvironment.Override(python.FrameworkInjectedEnv, "true")
 	}
 
 	if err := ctx.SetFunctionsEnvVars(l); err != nil {
 		return err
 	}
-	ctx.AddWebProcess([]string{"functions-framework"})
+	if subdir != "" {
+		l.LaunchEnvironment.Default(env.FunctionSourceLaunch, fnSource)
+	}
+	module, root, err := functionModule(ctx, fnSource)
+	if err != nil {
+		return err
+	}
+	if module != "" {
+		ctx.Logf("Loading the function from module %s of the package in %s.", module, filepath.Join(ctx.ApplicationRoot(), root))
+		shim := filepath.Join(l.Path, packageShim)
+		if err := ctx.WriteFile(shim, []byte(fmt.Sprintf(packageShimTemplate, module, filepath.Join(ctx.ApplicationRoot(), root))), 0644); err != nil {
+			return err
+		}
+		l.LaunchEnvironment.Override(env.FunctionSourceLaunch, shim)
+	}
+	return addWebProcess(ctx)
+}
+
+// functionModule returns the dotted module name of the function source file and the directory,
+// relative to the application root, from which it must be imported if the file is inside a regular
+// package, i.e. a directory with an __init__.py file. It returns an empty module name if the file
+// is not inside a package.
+func functionModule(ctx *gcp.Context, fnSource string) (string, string, error) {
+	rel := fnSource
+	if filepath.IsAbs(rel) {
+		var err error
+		if rel, err = filepath.Rel(ctx.ApplicationRoot(), fnSource); err != nil || !filepath.IsLocal(rel) {
+			return "", "", nil
+		}
+	}
+	rel = filepath.Clean(rel)
+	if filepath.Ext(rel) != ".py" {
+		return "", "", nil
+	}
+	root := filepath.Dir(rel)
+	for root != "." {
+		initExists, err := ctx.FileExists(ctx.ApplicationRoot(), root, "__init__.py")
+		if err != nil {
+			return "", "", err
+		}
+		if !initExists {
+			break
+		}
+		root = filepath.Dir(root)
+	}
+	if root == filepath.Dir(rel) {
+		return "", "", nil
+	}
+	modulePath, err := filepath.Rel(root, strings.TrimSuffix(rel, ".py"))
+	if err != nil {
+		return "", "", gcp.InternalErrorf("computing the module of %s: %v", fnSource, err)
+	}
+	return strings.ReplaceAll(modulePath, string(filepath.Separator), "."), root, nil
+}
+
+// addWebProcess registers the functions framework launch command with the extra arguments from
+// GOOGLE_FUNCTIONS_FRAMEWORK_ARGS.
+func addWebProcess(ctx *gcp.Context) error {
+	args, err := cloudfunctions.FrameworkArgs()
+	if err != nil {
+		return err
+	}
+	ctx.AddWebProcess(append([]string{"functions-framework"}, args...))
 	return nil
 }
 
-func validateSource(ctx *gcp.Context) error {
+// validateSource returns the path of the function source file relative to the application root.
+// GOOGLE_FUNCTION_SOURCE and the default main.py are resolved relative to the source subdirectory.
+func validateSource(ctx *gcp.Context, subdir string) (string, error) {
 	// Fail if the default|custom source file doesn't exist, otherwise the app will fail at runtime but still build here.
 	fnSource, ok := os.LookupEnv(env.FunctionSource)
 	if !ok {
-		mainPYExists, err := ctx.FileExists("main.py")
+		mainPY := filepath.Join(subdir, "main.py")
+		mainPYExists, err := ctx.FileExists(mainPY)
 		if err != nil {
-			return err
+			return "", err
 		}
 		if !mainPYExists {
-			return gcp.UserErrorf("missing main.py and %s not specified. Either create the function in main.py or specify %s to point to the file that contains the function", env.FunctionSource, env.FunctionSource)
-		}
-	} else {
-		fnSourceExists, err := ctx.FileExists(fnSource)
-		if err != nil {
-			return err
-		}
-		if !fnSourceExists {
-			return gcp.UserErrorf("%s specified file %q but it does not exist", env.FunctionSource, fnSource)
+			return "", gcp.UserErrorf("missing %s and %s not specified. Either create the function in main.py or specify %s to point to the file that contains the function", mainPY, env.FunctionSource, env.FunctionSource)
 		}
+		return mainPY, nil
 	}
-	return nil
+	if !filepath.IsAbs(fnSource) {
+		fnSource = filepath.Join(subdir, fnSource)
+	}
+	fnSourceExists, err := ctx.FileExists(fnSource)
+	if err != nil {
+		return "", err
+	}
+	if !fnSourceExists {
+		return "", gcp.UserErrorf("%s specified file %q but it does not exist", env.FunctionSource, fnSource)
+	}
+	return fnSource, nil
+}
+
+// requirementsContainFF returns true if the given requirements file exists and declares a
+// dependency on functions-framework.
+func requirementsContainFF(ctx *gcp.Context, requirements string) (bool, error) {
+	requirementsExists, err := ctx.FileExists(requirements)
+	if err != nil {
+		return false, err
+	}
+	if !requirementsExists {
+		return false, nil
+	}
+	content, err := ctx.ReadFile(requirements)
+	if err != nil {
+		return false, err
+	}
+	return containsFF(string(content)), nil
 }
 
 func containsFF(s string) bool {
@@ -15,10 +15,15 @@
 package main
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
 	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/google/go-cmp/cmp"
 )
 
 func TestContainsFF(t *testing.T) {
@@ -102,6 +107,40 @@ func TestBuild(t *testing.T) {
 			},
 			wantExitCode: 1,
 		},
+		{
+			name: "function in source subdirectory",
+			envs: []string{"GOOGLE_FUNCTION_SOURCE_SUBDIR=functions/hello"},
+			opts: []buildpacktest.Option{
+				buildpacktest.WithFiles(map[string]string{
+					"functions/hello/main.py":          "",
+					"functions/hello/requirements.txt": "functions-framework==3.0.0\n",
+				}),
+			},
+			wantCommands: []string{"python3 -m compileall -f -q functions/hello"},
+		},
+		{
+			name: "custom source file in source subdirectory",
+			envs: []string{
+				"GOOGLE_FUNCTION_SOURCE_SUBDIR=functions/hello",
+				"GOOGLE_FUNCTION_SOURCE=func.py",
+			},
+			opts: []buildpacktest.Option{
+				buildpacktest.WithFiles(map[string]string{
+					"functions/hello/func.py": "",
+				}),
+			},
+		},
+		{
+			name: "missing main.py in source subdirectory",
+			envs: []string{"GOOGLE_FUNCTION_SOURCE_SUBDIR=functions/hello"},
+			opts: []buildpacktest.Option{
+				buildpacktest.WithFiles(map[string]string{
+					"main.py":                 "",
+					"functions/hello/func.py": "",
+				}),
+			},
+			wantExitCode: 1,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -164,3 +203,87 @@ func TestDetect(t *testing.T) {
 		})
 	}
 }
+
+func TestAddWebProcess(t *testing.T) {
+	t.Setenv("GOOGLE_FUNCTIONS_FRAMEWORK_ARGS", "--debug")
+	ctx := gcp.NewContext()
+
+	if err := addWebProcess(ctx); err != nil {
+		t.Fatalf("addWebProcess() got error: %v", err)
+	}
+
+	processes := ctx.Processes()
+	if len(processes) != 1 {
+		t.Fatalf("addWebProcess() added %d processes, want 1", len(processes))
+	}
+	got := append([]string{processes[0].Command}, processes[0].Arguments...)
+	want := []string{"functions-framework", "--debug"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("addWebProcess() command mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestFunctionModule(t *testing.T) {
+	testCases := []struct {
+		name       string
+		files      []string
+		source     string
+		wantModule string
+		wantRoot   string
+	}{
+		{
+			name:   "top-level file",
+			files:  []string{"main.py"},
+			source: "main.py",
+		},
+		{
+			name:   "file in directory without package",
+			files:  []string{"src/handler.py"},
+			source: "src/handler.py",
+		},
+		{
+			name:       "file in package",
+			files:      []string{"mypackage/__init__.py", "mypackage/handler.py"},
+			source:     "mypackage/handler.py",
+			wantModule: "mypackage.handler",
+			wantRoot:   ".",
+		},
+		{
+			name:       "deeply nested module",
+			files:      []string{"src/app/__init__.py", "src/app/api/__init__.py", "src/app/api/v1/__init__.py", "src/app/api/v1/handler.py"},
+			source:     "src/app/api/v1/handler.py",
+			wantModule: "app.api.v1.handler",
+			wantRoot:   "src",
+		},
+		{
+			name:       "absolute path",
+			files:      []string{"mypackage/__init__.py", "mypackage/handler.py"},
+			source:     "{root}/mypackage/handler.py",
+			wantModule: "mypackage.handler",
+			wantRoot:   ".",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			root := t.TempDir()
+			for _, f := range tc.files {
+				path := filepath.Join(root, f)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatalf("creating directory: %v", err)
+				}
+				if err := os.WriteFile(path, nil, 0644); err != nil {
+					t.Fatalf("writing %s: %v", f, err)
+				}
+			}
+			source := strings.ReplaceAll(tc.source, "{root}", root)
+
+			module, moduleRoot, err := functionModule(gcp.NewContext(gcp.WithApplicationRoot(root)), source)
+			if err != nil {
+				t.Fatalf("functionModule(%q) got error: %v", source, err)
+			}
+			if module != tc.wantModule || moduleRoot != tc.wantRoot {
+				t.Errorf("functionModule(%q) = %q, %q, want %q, %q", source, module, moduleRoot, tc.wantModule, tc.wantRoot)
+			}
+		})
+	}
+}
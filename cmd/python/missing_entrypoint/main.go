@@ -21,8 +21,10 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/devmode"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/python"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/runtime"
 )
 
@@ -34,6 +36,18 @@ func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
 	if result := runtime.CheckOverride("python"); result != nil {
 		return result, nil
 	}
+	// An explicit entrypoint is set by the config/entrypoint buildpack, bypassing the default gunicorn
+	// command and its config.
+	if gcp.HasEntrypoint() {
+		return gcp.OptOut("custom entrypoint present"), nil
+	}
+	procfileExists, err := ctx.FileExists("Procfile")
+	if err != nil {
+		return nil, err
+	}
+	if procfileExists {
+		return gcp.OptOut("Procfile found"), nil
+	}
 
 	atLeastOne, err := ctx.HasAtLeastOne("*.py")
 	if err != nil {
@@ -46,17 +60,92 @@ func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
 }
 
 func buildFn(ctx *gcp.Context) error {
+	app, err := djangoApplication(ctx)
+	if err != nil {
+		return err
+	}
+	if app != "" {
+		cmd, err := python.GunicornCommand(ctx, app)
+		if err != nil {
+			return err
+		}
+		ctx.Logf("Setting default entrypoint for Django: %q", strings.Join(cmd, " "))
+		return addWebProcess(ctx, cmd)
+	}
+
 	hasMain, err := ctx.HasAtLeastOne("main.py")
 	if err != nil {
 		return fmt.Errorf("finding main.py files: %w", err)
 	}
 	if !hasMain {
-		return fmt.Errorf("for Python, provide a main.py file or set an entrypoint with %q env var or by creating a %q file", env.Entrypoint, "Procfile")
+		app, err := flaskApplication(ctx)
+		if err != nil {
+			return err
+		}
+		if app == "" {
+			return fmt.Errorf("for Python, provide a main.py file or set an entrypoint with %q env var or by creating a %q file", env.Entrypoint, "Procfile")
+		}
+		cmd, err := python.GunicornCommand(ctx, app)
+		if err != nil {
+			return err
+		}
+		ctx.Logf("Setting default entrypoint for Flask: %q", strings.Join(cmd, " "))
+		return addWebProcess(ctx, cmd)
 	}
 
-	cmd := []string{"gunicorn", "-b", ":8080", "main:app"}
+	cmd, err := python.GunicornCommand(ctx, "main:app")
+	if err != nil {
+		return err
+	}
 	ctx.Logf("Setting default entrypoint: %q", strings.Join(cmd, " "))
-	ctx.AddProcess(gcp.WebProcess, cmd, gcp.AsDefaultProcess())
+	return addWebProcess(ctx, cmd)
+}
 
+// addWebProcess sets cmd as the default web process. In dev mode, cmd is run by a file watcher that
+// restarts gunicorn when a Python file changes.
+func addWebProcess(ctx *gcp.Context, cmd []string) error {
+	if !devmode.Enabled(ctx) {
+		ctx.AddProcess(gcp.WebProcess, cmd, gcp.AsDefaultProcess())
+		return nil
+	}
+	if err := devmode.AddFileWatcherProcess(ctx, devmode.Config{
+		RunCmd: cmd,
+		Ext:    devmode.PythonWatchedExtensions,
+	}); err != nil {
+		return fmt.Errorf("adding devmode file watcher: %w", err)
+	}
 	return nil
 }
+
+// djangoApplication returns the WSGI application of a Django project in the form expected by
+// gunicorn, or an empty string if the application is not a Django project or the WSGI application
+// cannot be found.
+func djangoApplication(ctx *gcp.Context) (string, error) {
+	isDjango, err := python.IsDjango(ctx)
+	if err != nil || !isDjango {
+		return "", err
+	}
+	settings, err := python.DjangoSettingsModule(ctx)
+	if err != nil {
+		return "", err
+	}
+	app, err := python.DjangoWSGIApplication(ctx, settings)
+	if err != nil {
+		return "", err
+	}
+	if app == "" {
+		ctx.Warnf("Found a Django project but could not find its WSGI application from WSGI_APPLICATION in the settings module %q.", settings)
+	}
+	return app, nil
+}
+
+// flaskApplication returns the Flask application of wsgi.py or app.py in the form expected by
+// gunicorn, or an empty string if the application does not depend on Flask or the application
+// cannot be found.
+func flaskApplication(ctx *gcp.Context) (string, error) {
+	isFlask, err := python.IsFlask(ctx)
+	if err != nil || !isFlask {
+		return "", err
+	}
+	return python.FlaskApplication(ctx)
+}
@@ -0,0 +1,166 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	buildpacktest "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
+)
+
+func TestBuild(t *testing.T) {
+	testCases := []struct {
+		name         string
+		files        map[string]string
+		envs         []string
+		mocks        []*mockprocess.Mock
+		wantOutput   string
+		wantExitCode int
+	}{
+		{
+			name:       "main.py",
+			files:      map[string]string{"main.py": "", "requirements.txt": "flask"},
+			wantOutput: `Setting default entrypoint: "gunicorn -b :8080 -c gunicorn-config/gunicorn.conf.py main:app"`,
+		},
+		{
+			name:       "main.py in dev mode",
+			files:      map[string]string{"main.py": ""},
+			envs:       []string{"GOOGLE_DEVMODE=true"},
+			mocks:      []*mockprocess.Mock{mockprocess.New(`watchexec`)},
+			wantOutput: "Installing watchexec",
+		},
+		{
+			name:       "gunicorn config file",
+			files:      map[string]string{"main.py": "", "gunicorn.conf.py": "workers = 4\n"},
+			wantOutput: `Setting default entrypoint: "gunicorn -b :8080 -c gunicorn.conf.py main:app"`,
+		},
+		{
+			name:       "gunicorn timeouts",
+			files:      map[string]string{"main.py": "", "gunicorn.conf.py": "workers = 4\n"},
+			envs:       []string{"GOOGLE_GUNICORN_TIMEOUT=300", "GOOGLE_GUNICORN_GRACEFUL_TIMEOUT=20"},
+			wantOutput: `Setting default entrypoint: "gunicorn -b :8080 -c gunicorn.conf.py --timeout 300 --graceful-timeout 20 main:app"`,
+		},
+		{
+			name:         "invalid gunicorn timeout",
+			files:        map[string]string{"main.py": ""},
+			envs:         []string{"GOOGLE_GUNICORN_TIMEOUT=30s"},
+			wantOutput:   `invalid GOOGLE_GUNICORN_TIMEOUT "30s"`,
+			wantExitCode: 1,
+		},
+		{
+			name:       "generated gunicorn config logged in debug mode",
+			files:      map[string]string{"main.py": ""},
+			envs:       []string{"GOOGLE_DEBUG=true"},
+			wantOutput: "timeout = 0",
+		},
+		{
+			name: "flask app",
+			files: map[string]string{
+				"app.py":           "from flask import Flask\n\napp = Flask(__name__)\n",
+				"requirements.txt": "Flask==3.0.2",
+			},
+			wantOutput: `Setting default entrypoint for Flask: "gunicorn -b :8080 -c gunicorn-config/gunicorn.conf.py app:app"`,
+		},
+		{
+			name: "flask app factory",
+			files: map[string]string{
+				"app.py":           "from flask import Flask\n\ndef create_app():\n    return Flask(__name__)\n",
+				"requirements.txt": "Flask==3.0.2",
+			},
+			wantOutput: `Setting default entrypoint for Flask: "gunicorn -b :8080 -c gunicorn-config/gunicorn.conf.py app:create_app()"`,
+		},
+		{
+			name: "flask app without flask dependency",
+			files: map[string]string{
+				"app.py":           "app = object()\n",
+				"requirements.txt": "requests",
+			},
+			wantOutput:   "provide a main.py file",
+			wantExitCode: 1,
+		},
+		{
+			name: "flask without app",
+			files: map[string]string{
+				"server.py":        "from flask import Flask\n\napp = Flask(__name__)\n",
+				"requirements.txt": "flask",
+			},
+			wantOutput:   "provide a main.py file",
+			wantExitCode: 1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := buildpacktest.RunBuild(t, buildFn,
+				buildpacktest.WithTestName(tc.name),
+				buildpacktest.WithFiles(tc.files),
+				buildpacktest.WithEnvs(tc.envs...),
+				buildpacktest.WithExecMocks(tc.mocks...),
+			)
+			if err != nil && tc.wantExitCode == 0 {
+				t.Fatalf("error running build: %v, logs: %s", err, result.Output)
+			}
+			if result.ExitCode != tc.wantExitCode {
+				t.Errorf("build exit code mismatch, got: %d, want: %d", result.ExitCode, tc.wantExitCode)
+			}
+			if !strings.Contains(result.Output, tc.wantOutput) {
+				t.Errorf("build output = %q, want output containing %q", result.Output, tc.wantOutput)
+			}
+		})
+	}
+}
+
+func TestDetect(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		env   []string
+		want  int
+	}{
+		{
+			name:  "python files",
+			files: map[string]string{"main.py": ""},
+			want:  0,
+		},
+		{
+			name:  "no python files",
+			files: map[string]string{"index.js": ""},
+			want:  100,
+		},
+		{
+			name:  "entrypoint env var",
+			files: map[string]string{"main.py": ""},
+			env:   []string{"GOOGLE_ENTRYPOINT=gunicorn -b :8080 server:app"},
+			want:  100,
+		},
+		{
+			name:  "entrypoint JSON env var",
+			files: map[string]string{"main.py": ""},
+			env:   []string{`GOOGLE_ENTRYPOINT_JSON=["gunicorn", "-b", ":8080", "server:app"]`},
+			want:  100,
+		},
+		{
+			name:  "Procfile",
+			files: map[string]string{"main.py": "", "Procfile": "web: gunicorn -b :8080 server:app"},
+			want:  100,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			buildpacktest.TestDetect(t, detectFn, tc.name, tc.files, tc.env, tc.want)
+		})
+	}
+}
@@ -22,13 +22,18 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cloudfunctions"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/devmode"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/python"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/runtimelibs"
 	"github.com/buildpacks/libcnb"
 )
 
 const (
-	layerName = "pip"
+	layerName       = "pip"
+	djangoLayerName = "django-static"
 )
 
 // metadata represents metadata stored for a dependencies layer.
@@ -58,6 +63,12 @@ func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
 }
 
 func buildFn(ctx *gcp.Context) error {
+	// Copy the shared libraries that native modules load at runtime.
+	if err := runtimelibs.Install(ctx); err != nil {
+		return err
+	}
+	ctx.ReportPackageManager("pip")
+
 	// Remove leading and trailing : because otherwise SplitList will add empty strings.
 	reqs := filepath.SplitList(strings.Trim(os.Getenv(python.RequirementsFilesEnv), string(os.PathListSeparator)))
 	ctx.Debugf("Found requirements.txt files provided by other buildpacks: %s", reqs)
@@ -79,7 +90,33 @@ func buildFn(ctx *gcp.Context) error {
 	if err := python.InstallRequirements(ctx, l, reqs...); err != nil {
 		return fmt.Errorf("installing dependencies: %w", err)
 	}
+	if devmode.Enabled(ctx) {
+		// Python files are synced to the container without a rebuild; requirements changes still
+		// rebuild the image.
+		devmode.AddSyncMetadata(ctx, devmode.PythonSyncRules)
+	}
+	if _, ok := os.LookupEnv(env.FunctionTarget); ok {
+		addFrameworkVersionLabel(ctx)
+	}
+
+	if err := checkDependencies(ctx); err != nil {
+		return err
+	}
 
+	isDjango, err := python.IsDjango(ctx)
+	if err != nil {
+		return err
+	}
+	if isDjango {
+		ctx.AddReleaseProcess(python.DjangoMigrateCommand)
+		return buildDjango(ctx, l)
+	}
+	ctx.AddReleaseProcess(nil)
+	return nil
+}
+
+// checkDependencies fails the build if the installed dependencies are incompatible with each other.
+func checkDependencies(ctx *gcp.Context) error {
 	ctx.Logf("Checking for incompatible dependencies.")
 	result, err := ctx.Exec([]string{"python3", "-m", "pip", "check"}, gcp.WithUserAttribution)
 	if result == nil {
@@ -98,5 +135,58 @@ func buildFn(ctx *gcp.Context) error {
 		return nil
 	}
 	return gcp.UserErrorf("found incompatible dependencies: %q", result.Stdout)
+}
 
+// buildDjango collects the static files of a Django project into a layer and reports the issues
+// found by the Django deployment checks. It also sets DJANGO_SETTINGS_MODULE at run time to the
+// default from manage.py, so that the WSGI server loads the same settings as the build.
+func buildDjango(ctx *gcp.Context, pipLayer *libcnb.Layer) error {
+	l, err := ctx.Layer(djangoLayerName, gcp.LaunchLayer)
+	if err != nil {
+		return fmt.Errorf("creating %v layer: %w", djangoLayerName, err)
+	}
+	execEnv := python.DependencyEnv(pipLayer)
+	settings, err := python.DjangoSettingsModule(ctx)
+	if err != nil {
+		return err
+	}
+	if settings != "" && os.Getenv(python.DjangoSettingsModuleEnv) == "" {
+		ctx.Logf("Using Django settings module %s from manage.py.", settings)
+		l.LaunchEnvironment.Default(python.DjangoSettingsModuleEnv, settings)
+		execEnv = append(execEnv, python.DjangoSettingsModuleEnv+"="+settings)
+	}
+	if err := python.CollectDjangoStatic(ctx, l, execEnv); err != nil {
+		return err
+	}
+	python.CheckDjangoDeploy(ctx, execEnv)
+	return nil
+}
+
+// addFrameworkVersionLabel identifies the installed functions framework version from the output of
+// `pip show` and adds it to the generated image. It runs in the pip buildpack because the functions
+// framework buildpack only adds the framework to the requirements to install.
+func addFrameworkVersionLabel(ctx *gcp.Context) {
+	version := "unknown"
+	result, err := ctx.Exec([]string{"python3", "-m", "pip", "show", "functions-framework"})
+	if err != nil {
+		ctx.Logf("Could not detect installed functions framework version: %v", err)
+	} else if v := pipShowVersion(result.Stdout); v != "" {
+		version = v
+	}
+	cloudfunctions.AddFrameworkVersionLabel(ctx, &cloudfunctions.FrameworkVersionInfo{
+		Runtime:  "python",
+		Version:  version,
+		Injected: os.Getenv(python.FrameworkInjectedEnv) == "true",
+	})
+}
+
+// pipShowVersion returns the value of the "Version:" line in the output of `pip show`, or an empty
+// string if there is none.
+func pipShowVersion(output string) string {
+	for _, line := range strings.Split(output, "\n") {
+		if v, ok := strings.CutPrefix(line, "Version:"); ok {
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
 }
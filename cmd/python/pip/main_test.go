@@ -15,9 +15,16 @@
 package main
 
 import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	buildpacktest "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 )
 
 func TestDetect(t *testing.T) {
@@ -49,3 +56,154 @@ func TestDetect(t *testing.T) {
 		})
 	}
 }
+
+func TestPipShowVersion(t *testing.T) {
+	testCases := []struct {
+		name   string
+		output string
+		want   string
+	}{
+		{
+			name:   "installed",
+			output: "Name: functions-framework\nVersion: 3.5.0\nSummary: An open source FaaS (Function as a service) framework for writing portable Python functions -- brought to you by the Google Cloud Functions team.\nLocation: /layers/google.python.pip/pip/lib/python3.12/site-packages\n",
+			want:   "3.5.0",
+		},
+		{
+			name:   "trailing whitespace",
+			output: "Name: functions-framework\r\nVersion: 3.5.0 \r\n",
+			want:   "3.5.0",
+		},
+		{
+			name:   "no version line",
+			output: "WARNING: Package(s) not found: functions-framework\n",
+		},
+		{
+			name: "empty output",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := pipShowVersion(tc.output); got != tc.want {
+				t.Errorf("pipShowVersion(%q) = %q, want %q", tc.output, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestBuild(t *testing.T) {
+	const secret = "s3cret-t0ken"
+	testCases := []struct {
+		name         string
+		envs         []string
+		files        map[string]string
+		wantExitCode int // 0 if unspecified
+		wantCommands []string
+		wantOutput   string
+	}{
+		{
+			name:         "pypi",
+			wantCommands: []string{`python3 -m pip install --requirement requirements.txt .* --user`},
+		},
+		{
+			name: "index url with credentials",
+			envs: []string{
+				"GOOGLE_PIP_INDEX_URL=https://user:" + secret + "@pypi.example.com/simple",
+				"GOOGLE_PIP_EXTRA_INDEX_URL=https://extra.example.com/simple",
+			},
+			wantCommands: []string{`python3 -m pip install --requirement requirements.txt .* --index-url https://pypi.example.com/simple --extra-index-url https://extra.example.com/simple --user`},
+		},
+		{
+			name: "index url with binding credentials",
+			envs: []string{
+				"GOOGLE_PIP_INDEX_URL=https://pypi.example.com/simple",
+				"SERVICE_BINDING_ROOT=bindings",
+			},
+			files: map[string]string{
+				"bindings/pypi/type":     "pip-index",
+				"bindings/pypi/username": "user",
+				"bindings/pypi/password": secret,
+			},
+			wantCommands: []string{`python3 -m pip install --requirement requirements.txt .* --index-url https://pypi.example.com/simple --user`},
+		},
+		{
+			name: "django release process",
+			files: map[string]string{
+				"requirements.txt": "Django==5.0\n",
+				"manage.py":        "os.environ.setdefault('DJANGO_SETTINGS_MODULE', 'mysite.settings')\n",
+			},
+			wantOutput: `Adding release process: "python3 manage.py migrate --noinput"`,
+		},
+		{
+			name:       "release command",
+			envs:       []string{"GOOGLE_RELEASE_COMMAND=python3 migrate.py"},
+			wantOutput: `Adding release process from GOOGLE_RELEASE_COMMAND: "python3 migrate.py"`,
+		},
+		{
+			name:         "invalid index url",
+			envs:         []string{"GOOGLE_PIP_INDEX_URL=pypi.example.com/simple"},
+			wantExitCode: 1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			files := map[string]string{
+				"main.py":          "",
+				"requirements.txt": "flask\n",
+				// An existing .netrc skips the lookup of Artifact Registry credentials.
+				".netrc": "machine us-python.pkg.dev login oauth2accesstoken password ar-token\n",
+			}
+			for name, content := range tc.files {
+				files[name] = content
+			}
+			opts := []buildpacktest.Option{
+				buildpacktest.WithTestName(tc.name),
+				buildpacktest.WithFiles(files),
+				buildpacktest.WithEnvs(append([]string{"HOME=."}, tc.envs...)...),
+				buildpacktest.WithExecMocks(mockprocess.New(`^python3 --version$`, mockprocess.WithStdout("Python 3.12.1"))),
+			}
+			result, err := buildpacktest.RunBuild(t, buildWithoutCredentialsFn(secret), opts...)
+			if err != nil && tc.wantExitCode == 0 {
+				t.Fatalf("error running build: %v, logs: %s", err, result.Output)
+			}
+
+			if result.ExitCode != tc.wantExitCode {
+				t.Errorf("build exit code mismatch, got: %d, want: %d, logs: %s", result.ExitCode, tc.wantExitCode, result.Output)
+			}
+			for _, cmd := range tc.wantCommands {
+				if !result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to be executed, but it was not, build output: %s", cmd, result.Output)
+				}
+			}
+			if !strings.Contains(result.Output, tc.wantOutput) {
+				t.Errorf("build output = %q, want output containing %q", result.Output, tc.wantOutput)
+			}
+			if strings.Contains(result.Output, secret) {
+				t.Errorf("build output contains the index credentials: %s", result.Output)
+			}
+		})
+	}
+}
+
+// buildWithoutCredentialsFn returns a build function that runs buildFn and then fails if the given
+// secret was written to a layer.
+func buildWithoutCredentialsFn(secret string) gcp.BuildFn {
+	return func(ctx *gcp.Context) error {
+		if err := buildFn(ctx); err != nil {
+			return err
+		}
+		// The layers are created in the working directory in tests.
+		return filepath.WalkDir(".", func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || strings.HasPrefix(path, "bindings") {
+				return err
+			}
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			if strings.Contains(string(content), secret) {
+				return fmt.Errorf("found the index credentials in %s", path)
+			}
+			return nil
+		})
+	}
+}
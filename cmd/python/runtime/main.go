@@ -27,6 +27,7 @@ import (
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/python"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/runtime"
+	"github.com/buildpacks/libcnb"
 )
 
 const (
@@ -65,6 +66,37 @@ func buildFn(ctx *gcp.Context) error {
 	if err != nil {
 		return fmt.Errorf("creating %v layer: %w", pythonLayer, err)
 	}
+	impl, err := python.Implementation()
+	if err != nil {
+		return err
+	}
+	skip, err := runtime.SkipInstall()
+	if err != nil {
+		return err
+	}
+	switch {
+	case skip && impl == python.PyPy:
+		err = checkPreinstalledPyPy(ctx, layer)
+	case skip:
+		err = checkPreinstalledPython(ctx)
+	case impl == python.PyPy:
+		err = installPyPy(ctx, layer)
+	default:
+		err = installCPython(ctx, layer)
+	}
+	if err != nil {
+		return err
+	}
+	// Set the PYTHONHOME for flex apps because of uwsgi
+	if env.IsFlex() {
+		layer.LaunchEnvironment.Default("PYTHONHOME", layer.Path)
+	}
+	// Force stdout/stderr streams to be unbuffered so that log messages appear immediately in the logs.
+	layer.LaunchEnvironment.Default("PYTHONUNBUFFERED", "TRUE")
+	return nil
+}
+
+func installCPython(ctx *gcp.Context, layer *libcnb.Layer) error {
 	ver, err := python.RuntimeVersion(ctx, ctx.ApplicationRoot())
 	if err != nil {
 		return fmt.Errorf("determining runtime version: %w", err)
@@ -95,12 +127,65 @@ func buildFn(ctx *gcp.Context) error {
 			path,
 		})
 	}
-	// Set the PYTHONHOME for flex apps because of uwsgi
-	if env.IsFlex() {
-		layer.LaunchEnvironment.Default("PYTHONHOME", layer.Path)
+	return nil
+}
+
+// checkPreinstalledPython verifies that the python3 of the base image is of the requested version.
+func checkPreinstalledPython(ctx *gcp.Context) error {
+	ver, err := python.RuntimeVersion(ctx, ctx.ApplicationRoot())
+	if err != nil {
+		return fmt.Errorf("determining runtime version: %w", err)
+	}
+	_, err = runtime.CheckPreinstalled(ctx, runtime.Python, ver)
+	return err
+}
+
+// checkPreinstalledPyPy verifies that the pypy3 of the base image implements the Python version of
+// the requested PyPy release, and links python3 and python to it for the later buildpacks and the
+// application.
+func checkPreinstalledPyPy(ctx *gcp.Context, layer *libcnb.Layer) error {
+	pythonVersion, _, _ := strings.Cut(python.PyPyVersion(ctx), "-")
+	if _, err := runtime.CheckPreinstalled(ctx, runtime.PyPy, pythonVersion+".x"); err != nil {
+		return err
+	}
+	result, err := ctx.Exec([]string{"bash", "-c", "command -v pypy3"})
+	if err != nil {
+		return err
+	}
+	bin := filepath.Join(layer.Path, "bin")
+	if err := ctx.MkdirAll(bin, 0755); err != nil {
+		return err
+	}
+	for _, name := range []string{"python3", "python"} {
+		if err := ctx.Symlink(strings.TrimSpace(result.Stdout), filepath.Join(bin, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// installPyPy installs PyPy, with python3 and python links to pypy3 for the later buildpacks and
+// the application, and bootstraps pip since PyPy does not include it.
+func installPyPy(ctx *gcp.Context, layer *libcnb.Layer) error {
+	ctx.Warnf("PyPy support is experimental, packages with C extensions may fail to build or be slower than with CPython. Unset %s to use CPython.", python.ImplementationEnv)
+	if _, err := runtime.InstallTarballIfNotCached(ctx, runtime.PyPy, python.PyPyVersion(ctx), layer); err != nil {
+		return err
+	}
+	bin := filepath.Join(layer.Path, "bin")
+	for _, name := range []string{"python3", "python"} {
+		exists, err := ctx.FileExists(bin, name)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			if err := ctx.Symlink("pypy3", filepath.Join(bin, name)); err != nil {
+				return err
+			}
+		}
+	}
+	if _, err := ctx.Exec([]string{filepath.Join(bin, "pypy3"), "-m", "ensurepip", "--default-pip"}, gcp.WithUserAttribution); err != nil {
+		return fmt.Errorf("bootstrapping pip for PyPy: %w", err)
 	}
-	// Force stdout/stderr streams to be unbuffered so that log messages appear immediately in the logs.
-	layer.LaunchEnvironment.Default("PYTHONUNBUFFERED", "TRUE")
 	return nil
 }
 
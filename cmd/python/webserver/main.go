@@ -22,7 +22,6 @@ import (
 	"path/filepath"
 	"regexp"
 
-	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/python"
 )
@@ -41,7 +40,7 @@ func main() {
 }
 
 func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
-	if os.Getenv(env.Entrypoint) != "" {
+	if gcp.HasEntrypoint() {
 		return gcp.OptOut("custom entrypoint present"), nil
 	}
 	requirementsExists, err := ctx.FileExists("requirements.txt")
@@ -62,6 +61,10 @@ func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
 }
 
 func buildFn(ctx *gcp.Context) error {
+	// Fail before installing anything if the timeouts of the default gunicorn command are invalid.
+	if _, err := python.GunicornTimeoutArgs(); err != nil {
+		return err
+	}
 	l, err := ctx.Layer(layerName, gcp.BuildLayer)
 	if err != nil {
 		return fmt.Errorf("creating %v layer: %w", layerName, err)
@@ -42,6 +42,14 @@ func TestDetect(t *testing.T) {
 			env:  []string{"GOOGLE_ENTRYPOINT=gunicorn main:app"},
 			want: 100,
 		},
+		{
+			name: "has google entrypoint JSON",
+			files: map[string]string{
+				"main.py": "",
+			},
+			env:  []string{`GOOGLE_ENTRYPOINT_JSON=["gunicorn", "main:app"]`},
+			want: 100,
+		},
 		{
 			name: "has requirements",
 			files: map[string]string{
@@ -18,11 +18,15 @@ package main
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
+	"strconv"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildererror"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/lockcheck"
 	"github.com/buildpacks/libcnb"
 )
 
@@ -30,6 +34,18 @@ const (
 	layerName         = "gems"
 	dependencyHashKey = "dependency_hash"
 	rubyVersionKey    = "ruby_version"
+
+	// envBundleMirror is the URL of a rubygems.org mirror that bundler installs gems from.
+	envBundleMirror = "GOOGLE_BUNDLE_MIRROR"
+	rubygemsSource  = "https://rubygems.org"
+
+	// mirrorFallbackTimeout is the number of seconds bundler waits for the mirror to respond before
+	// it installs gems from rubygems.org instead.
+	mirrorFallbackTimeout = "3"
+
+	defaultBundleRetry   = 5
+	maxBundleRetry       = 10
+	defaultBundleTimeout = 120
 )
 
 func main() {
@@ -100,6 +116,11 @@ func buildFn(ctx *gcp.Context) error {
 	// This layer directory contains the files installed by bundler into the application .bundle directory
 	bundleOutput := filepath.Join(deps.Path, ".bundle")
 
+	installCmd, installEnv, err := bundleInstallCommand()
+	if err != nil {
+		return err
+	}
+
 	cached, err := checkCache(ctx, deps, cache.WithFiles(lockFile))
 	if err != nil {
 		return fmt.Errorf("checking cache: %w", err)
@@ -115,6 +136,19 @@ func buildFn(ctx *gcp.Context) error {
 	if _, err := ctx.Exec([]string{"bundle", "config", "--local", "path", localGemsDir}, gcp.WithUserAttribution); err != nil {
 		return err
 	}
+	if err := configureMirror(ctx); err != nil {
+		return err
+	}
+	// The lockfile must be verified before the platforms are added since that may update it.
+	verifyLockfile, err := lockcheck.Verify()
+	if err != nil {
+		return err
+	}
+	if verifyLockfile {
+		if err := lockcheck.Check(ctx, lockcheck.Bundler, lockFile); err != nil {
+			return err
+		}
+	}
 
 	// This line will override user provided BUNDLED WITH in the Gemfile.lock
 	// It'll use the currently activated bundler version instead
@@ -148,8 +182,10 @@ func buildFn(ctx *gcp.Context) error {
 		if _, err := ctx.Exec([]string{"bundle", "config", "--local", "path", localGemsDir}, gcp.WithUserAttribution); err != nil {
 			return err
 		}
-		if _, err := ctx.Exec([]string{"bundle", "install"},
-			gcp.WithEnv("NOKOGIRI_USE_SYSTEM_LIBRARIES=1", "MALLOC_ARENA_MAX=2", "LANG=C.utf8"), gcp.WithUserAttribution); err != nil {
+		if err := configureMirror(ctx); err != nil {
+			return err
+		}
+		if _, err := ctx.Exec(installCmd, gcp.WithEnv(installEnv...), gcp.WithUserAttribution); err != nil {
 			return err
 		}
 
@@ -183,6 +219,55 @@ func buildFn(ctx *gcp.Context) error {
 	return nil
 }
 
+// bundleInstallCommand returns the `bundle install` command and its env vars, with the number of
+// retries of GOOGLE_BUNDLE_RETRY and the timeout of GOOGLE_BUNDLE_TIMEOUT. Bundler has no timeout
+// option for install, so the timeout is set with the BUNDLE_TIMEOUT config env var.
+func bundleInstallCommand() ([]string, []string, error) {
+	retry, err := positiveIntEnv(env.BundleRetry, defaultBundleRetry)
+	if err != nil {
+		return nil, nil, err
+	}
+	if retry > maxBundleRetry {
+		return nil, nil, gcp.UserErrorf("invalid %s %d: must be at most %d", env.BundleRetry, retry, maxBundleRetry)
+	}
+	timeout, err := positiveIntEnv(env.BundleTimeout, defaultBundleTimeout)
+	if err != nil {
+		return nil, nil, err
+	}
+	cmd := []string{"bundle", "install", "--retry", strconv.Itoa(retry)}
+	installEnv := []string{"NOKOGIRI_USE_SYSTEM_LIBRARIES=1", "MALLOC_ARENA_MAX=2", "LANG=C.utf8", "BUNDLE_TIMEOUT=" + strconv.Itoa(timeout)}
+	return cmd, installEnv, nil
+}
+
+// positiveIntEnv returns the value of the given env var, which must be a positive integer, or def
+// if it is not set.
+func positiveIntEnv(name string, def int) (int, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		return 0, gcp.UserErrorf("invalid %s %q: must be a positive integer", name, v)
+	}
+	return n, nil
+}
+
+// configureMirror points bundler at the rubygems.org mirror configured by GOOGLE_BUNDLE_MIRROR, and
+// falls back to rubygems.org if the mirror does not respond.
+func configureMirror(ctx *gcp.Context) error {
+	mirror := os.Getenv(envBundleMirror)
+	if mirror == "" {
+		return nil
+	}
+	ctx.Logf("Installing gems from %s through mirror %s", rubygemsSource, mirror)
+	if _, err := ctx.Exec([]string{"bundle", "config", "--local", "mirror." + rubygemsSource, mirror}, gcp.WithUserAttribution); err != nil {
+		return err
+	}
+	_, err := ctx.Exec([]string{"bundle", "config", "--local", "mirror." + rubygemsSource + ".fallback_timeout", mirrorFallbackTimeout}, gcp.WithUserAttribution)
+	return err
+}
+
 // checkCache checks whether cached dependencies exist and match.
 func checkCache(ctx *gcp.Context, l *libcnb.Layer, opts ...cache.Option) (bool, error) {
 	result, err := ctx.Exec([]string{"ruby", "-v"})
@@ -190,7 +275,7 @@ func checkCache(ctx *gcp.Context, l *libcnb.Layer, opts ...cache.Option) (bool,
 		return false, err
 	}
 	currentRubyVersion := result.Stdout
-	opts = append(opts, cache.WithStrings(currentRubyVersion))
+	opts = append(opts, cache.WithNamedString("ruby version", currentRubyVersion))
 
 	hash, cached, err := cache.HashAndCheck(ctx, l, dependencyHashKey, opts...)
 	if err != nil {
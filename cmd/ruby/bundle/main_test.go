@@ -18,6 +18,9 @@ import (
 	"testing"
 
 	buildpacktest "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/google/go-cmp/cmp"
 )
 
 func TestDetect(t *testing.T) {
@@ -60,3 +63,173 @@ func TestDetect(t *testing.T) {
 		})
 	}
 }
+
+func TestBuild(t *testing.T) {
+	mirrorCommand := "bundle config --local mirror.https://rubygems.org https://gems.example.com"
+	testCases := []struct {
+		name            string
+		envs            []string
+		mocks           []*mockprocess.Mock
+		wantErr         bool
+		wantCommands    []string
+		skippedCommands []string
+	}{
+		{
+			name: "no mirror",
+			wantCommands: []string{
+				"bundle install --retry 5",
+			},
+			skippedCommands: []string{
+				"bundle config --local mirror",
+			},
+		},
+		{
+			name: "with mirror",
+			envs: []string{"GOOGLE_BUNDLE_MIRROR=https://gems.example.com"},
+			wantCommands: []string{
+				mirrorCommand,
+				"bundle config --local mirror.https://rubygems.org.fallback_timeout 3",
+				"bundle install",
+			},
+		},
+		{
+			name: "verify lockfile",
+			envs: []string{"GOOGLE_VERIFY_LOCKFILE=true"},
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^bundle check`, mockprocess.WithStdout("Bundler can't satisfy your Gemfile's dependencies.\nInstall missing gems with `bundle install`."), mockprocess.WithExitCode(1)),
+			},
+			wantCommands: []string{
+				"bundle check.*BUNDLE_FROZEN=true",
+				"bundle install",
+			},
+		},
+		{
+			name: "verify lockfile out of sync",
+			envs: []string{"GOOGLE_VERIFY_LOCKFILE=true"},
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^bundle check`, mockprocess.WithStderr("You are trying to install in frozen mode after changing your Gemfile."), mockprocess.WithExitCode(16)),
+			},
+			wantErr: true,
+			skippedCommands: []string{
+				"bundle lock",
+				"bundle install",
+			},
+		},
+		{
+			name: "bundle retry",
+			envs: []string{"GOOGLE_BUNDLE_RETRY=8"},
+			wantCommands: []string{
+				"bundle install --retry 8",
+			},
+		},
+		{
+			name:    "invalid bundle timeout",
+			envs:    []string{"GOOGLE_BUNDLE_TIMEOUT=0"},
+			wantErr: true,
+			skippedCommands: []string{
+				"bundle install",
+			},
+		},
+		{
+			name: "lockfile not verified by default",
+			skippedCommands: []string{
+				"bundle check",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := []buildpacktest.Option{
+				buildpacktest.WithTestName(tc.name),
+				buildpacktest.WithFiles(map[string]string{
+					"Gemfile":      "",
+					"Gemfile.lock": "",
+				}),
+				buildpacktest.WithEnvs(tc.envs...),
+				buildpacktest.WithExecMocks(append(tc.mocks, mockprocess.New(`^ruby -v`, mockprocess.WithStdout("ruby 3.2.0")))...),
+			}
+			result, err := buildpacktest.RunBuild(t, buildFn, opts...)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("RunBuild() got error %v, want error %t, result: %#v", err, tc.wantErr, result)
+			}
+
+			for _, cmd := range tc.wantCommands {
+				if !result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to be executed, but it was not", cmd)
+				}
+			}
+			for _, cmd := range tc.skippedCommands {
+				if result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to not be executed, but it was", cmd)
+				}
+			}
+		})
+	}
+}
+
+func TestBundleInstallCommand(t *testing.T) {
+	defaultEnv := []string{"NOKOGIRI_USE_SYSTEM_LIBRARIES=1", "MALLOC_ARENA_MAX=2", "LANG=C.utf8"}
+	testCases := []struct {
+		name    string
+		retry   string
+		timeout string
+		wantCmd []string
+		wantEnv []string
+		wantErr bool
+	}{
+		{
+			name:    "defaults",
+			wantCmd: []string{"bundle", "install", "--retry", "5"},
+			wantEnv: append(defaultEnv, "BUNDLE_TIMEOUT=120"),
+		},
+		{
+			name:    "retry and timeout",
+			retry:   "10",
+			timeout: "300",
+			wantCmd: []string{"bundle", "install", "--retry", "10"},
+			wantEnv: append(defaultEnv, "BUNDLE_TIMEOUT=300"),
+		},
+		{
+			name:    "retry above maximum",
+			retry:   "11",
+			wantErr: true,
+		},
+		{
+			name:    "zero retry",
+			retry:   "0",
+			wantErr: true,
+		},
+		{
+			name:    "invalid retry",
+			retry:   "many",
+			wantErr: true,
+		},
+		{
+			name:    "negative timeout",
+			timeout: "-1",
+			wantErr: true,
+		},
+		{
+			name:    "invalid timeout",
+			timeout: "2m",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(env.BundleRetry, tc.retry)
+			t.Setenv(env.BundleTimeout, tc.timeout)
+
+			gotCmd, gotEnv, err := bundleInstallCommand()
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("bundleInstallCommand() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.wantCmd, gotCmd); diff != "" {
+				t.Errorf("bundleInstallCommand() command mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantEnv, gotEnv); diff != "" {
+				t.Errorf("bundleInstallCommand() env mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
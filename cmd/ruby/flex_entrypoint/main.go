@@ -45,6 +45,9 @@ func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
 	if os.Getenv(env.Entrypoint) != "" {
 		return gcp.OptInEnvSet(env.Entrypoint), nil
 	}
+	if os.Getenv(env.EntrypointJSON) != "" {
+		return gcp.OptInEnvSet(env.EntrypointJSON), nil
+	}
 	entrypoint, err := appyaml.EntrypointIfExists(ctx.ApplicationRoot())
 	if err != nil {
 		return nil, fmt.Errorf("Error finding entrypoint in app.yaml if set. %w", err)
@@ -57,7 +60,10 @@ func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
 }
 
 func buildFn(ctx *gcp.Context) error {
-	entrypoint := getEntrypoint(ctx)
+	entrypoint, err := getEntrypoint(ctx)
+	if err != nil {
+		return err
+	}
 	l, err := ctx.Layer(flexEntrypoint, gcp.LaunchLayer)
 	if err != nil {
 		return fmt.Errorf("creating layer: %w", err)
@@ -68,28 +74,32 @@ func buildFn(ctx *gcp.Context) error {
 	l.LaunchEnvironment.Default("APP_ENV", production)
 
 	ctx.Logf("Using entrypoint %s", entrypoint)
-	ctx.AddProcess(gcp.WebProcess, []string{entrypoint}, gcp.AsDefaultProcess())
+	if entrypoint.Exec != nil {
+		ctx.AddEntrypointProcess(entrypoint)
+	} else {
+		ctx.AddProcess(gcp.WebProcess, []string{entrypoint.Shell}, gcp.AsDefaultProcess())
+	}
 	return nil
 }
 
-func getEntrypoint(ctx *gcp.Context) string {
-	if entrypoint := os.Getenv(env.Entrypoint); entrypoint != "" {
-		return entrypoint
+func getEntrypoint(ctx *gcp.Context) (*gcp.Entrypoint, error) {
+	entrypoint, err := ctx.Entrypoint()
+	if err != nil || entrypoint != nil {
+		return entrypoint, err
 	}
 
-	entrypoint, err := appyaml.EntrypointIfExists(ctx.ApplicationRoot())
+	ep, err := appyaml.EntrypointIfExists(ctx.ApplicationRoot())
 	if err != nil {
 		ctx.Logf("app.yaml env var set but the specified app.yaml file doesn't exist.")
-		return ""
+		return &gcp.Entrypoint{Source: "app.yaml"}, nil
 	}
 
-	if entrypoint != "" {
-		return entrypoint
+	if ep != "" {
+		return &gcp.Entrypoint{Source: "app.yaml", Shell: ep}, nil
 	}
-	ep, err := ruby.InferEntrypoint(ctx, ctx.ApplicationRoot())
+	ep, err = ruby.InferEntrypoint(ctx, ctx.ApplicationRoot())
 	if err != nil {
 		ctx.Logf(err.Error())
-		return ""
 	}
-	return ep
+	return &gcp.Entrypoint{Source: "inferred", Shell: ep}, nil
 }
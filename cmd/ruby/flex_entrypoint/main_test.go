@@ -39,6 +39,12 @@ func TestDetect(t *testing.T) {
 			env:   []string{"X_GOOGLE_TARGET_PLATFORM=flex", "GOOGLE_ENTRYPOINT=configured"},
 			want:  0,
 		},
+		{
+			name:  "entrypoint configured from JSON env var",
+			files: map[string]string{},
+			env:   []string{"X_GOOGLE_TARGET_PLATFORM=flex", `GOOGLE_ENTRYPOINT_JSON=["configured"]`},
+			want:  0,
+		},
 		{
 			name: "entrypoint from app.yaml",
 			files: map[string]string{
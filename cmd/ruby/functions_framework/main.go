@@ -89,8 +89,17 @@ func buildFn(ctx *gcp.Context) error {
 		Injected: false,
 	})
 
-	ctx.AddWebProcess([]string{"bundle", "exec", "functions-framework-ruby"})
+	return addWebProcess(ctx)
+}
 
+// addWebProcess registers the functions framework launch command with the extra arguments from
+// GOOGLE_FUNCTIONS_FRAMEWORK_ARGS.
+func addWebProcess(ctx *gcp.Context) error {
+	args, err := cloudfunctions.FrameworkArgs()
+	if err != nil {
+		return err
+	}
+	ctx.AddWebProcess(append([]string{"bundle", "exec", "functions-framework-ruby"}, args...))
 	return nil
 }
 
@@ -18,6 +18,8 @@ import (
 	"testing"
 
 	buildpacktest "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/google/go-cmp/cmp"
 )
 
 func TestDetect(t *testing.T) {
@@ -44,3 +46,22 @@ func TestDetect(t *testing.T) {
 		})
 	}
 }
+
+func TestAddWebProcess(t *testing.T) {
+	t.Setenv("GOOGLE_FUNCTIONS_FRAMEWORK_ARGS", "--debug")
+	ctx := gcp.NewContext()
+
+	if err := addWebProcess(ctx); err != nil {
+		t.Fatalf("addWebProcess() got error: %v", err)
+	}
+
+	processes := ctx.Processes()
+	if len(processes) != 1 {
+		t.Fatalf("addWebProcess() added %d processes, want 1", len(processes))
+	}
+	got := append([]string{processes[0].Command}, processes[0].Arguments...)
+	want := []string{"bundle", "exec", "functions-framework-ruby", "--debug"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("addWebProcess() command mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -13,19 +13,30 @@
 // limitations under the License.
 
 // Implements ruby/rails buildpack.
-// The rails buildpack precompiles assets using Rails.
+// The rails buildpack precompiles assets using Rails and optionally runs database migrations
+// at container start or registers them as the release process.
 package main
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/nodejs"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/ruby"
 )
 
 const (
-	yarnLayer = "yarn"
+	yarnLayer    = "yarn"
+	migrateLayer = "migrate"
+	// migrateProcess is the process type that runs the database migrations.
+	migrateProcess = "migrate"
+	// migrationsDir is the directory of the database migrations of Rails apps.
+	migrationsDir = "db/migrate"
+	// envDBMigrate enables running `rake db:migrate` before the web process starts.
+	envDBMigrate = "GOOGLE_RAILS_DB_MIGRATE"
 )
 
 func main() {
@@ -44,13 +55,112 @@ func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
 	if err != nil {
 		return nil, err
 	}
-	if !needsPrecompile {
-		return gcp.OptOut("Rails assets do not need precompilation"), nil
+	if needsPrecompile {
+		return gcp.OptIn("found Rails assets to precompile"), nil
+	}
+	migrate, err := env.IsPresentAndTrue(envDBMigrate)
+	if err != nil {
+		return nil, err
+	}
+	if migrate {
+		return gcp.OptInEnvSet(envDBMigrate), nil
+	}
+	if os.Getenv(env.ReleaseCommand) != "" {
+		return gcp.OptInEnvSet(env.ReleaseCommand), nil
 	}
-	return gcp.OptIn("found Rails assets to precompile"), nil
+	migrationsExist, err := ctx.FileExists(migrationsDir)
+	if err != nil {
+		return nil, err
+	}
+	if migrationsExist {
+		return gcp.OptInFileFound(migrationsDir), nil
+	}
+	return gcp.OptOut("Rails assets do not need precompilation"), nil
 }
 
 func buildFn(ctx *gcp.Context) error {
+	migrate, err := env.IsPresentAndTrue(envDBMigrate)
+	if err != nil {
+		return err
+	}
+	if migrate {
+		if err := addMigrateProcess(ctx); err != nil {
+			return err
+		}
+	}
+	if err := addReleaseProcess(ctx); err != nil {
+		return err
+	}
+
+	needsPrecompile, err := ruby.NeedsRailsAssetPrecompile(ctx)
+	if err != nil {
+		return err
+	}
+	if !needsPrecompile {
+		return nil
+	}
+	return precompileAssets(ctx)
+}
+
+// addMigrateProcess writes a script that runs the database migrations, registers it as the
+// migrate process, and runs it through exec.d before the web process starts.
+func addMigrateProcess(ctx *gcp.Context) error {
+	ctx.Logf("Configuring database migrations to run before the web process starts")
+	l, err := ctx.Layer(migrateLayer, gcp.LaunchLayer)
+	if err != nil {
+		return fmt.Errorf("creating %v layer: %w", migrateLayer, err)
+	}
+	entrypoint := filepath.Join(l.Path, "entrypoint.sh")
+	if err := ctx.WriteFile(entrypoint, []byte(migrateScript(ctx.ApplicationRoot())), 0755); err != nil {
+		return err
+	}
+	// exec.d executables scoped to the web process run at container start, before the web server.
+	execD := l.Exec.ProcessFilePath(gcp.WebProcess, migrateLayer)
+	if err := ctx.MkdirAll(filepath.Dir(execD), 0755); err != nil {
+		return err
+	}
+	if err := ctx.WriteFile(execD, []byte(execDScript(entrypoint)), 0755); err != nil {
+		return err
+	}
+	ctx.AddProcess(migrateProcess, []string{entrypoint}, gcp.AsDirectProcess())
+	return nil
+}
+
+// addReleaseProcess registers the release process that runs the database migrations if the app has
+// any, or the command set with GOOGLE_RELEASE_COMMAND.
+func addReleaseProcess(ctx *gcp.Context) error {
+	migrationsExist, err := ctx.FileExists(migrationsDir)
+	if err != nil {
+		return err
+	}
+	var cmd []string
+	if migrationsExist {
+		cmd = []string{"bundle", "exec", "rails", "db:migrate"}
+	}
+	ctx.AddReleaseProcess(cmd)
+	return nil
+}
+
+// migrateScript returns a shell script that runs the database migrations of the Rails app
+// located in appDir.
+func migrateScript(appDir string) string {
+	return fmt.Sprintf(`#!/bin/sh
+set -e
+cd %s
+echo "Running database migrations" >&2
+exec bundle exec rake db:migrate
+`, appDir)
+}
+
+// execDScript returns an exec.d executable that runs the given script. exec.d executables may
+// only write environment variables to file descriptor 3, so the script output is sent to stderr.
+func execDScript(script string) string {
+	return fmt.Sprintf(`#!/bin/sh
+exec %s 1>&2
+`, script)
+}
+
+func precompileAssets(ctx *gcp.Context) error {
 	ctx.Logf("Running Rails asset precompilation")
 
 	// Install Yarn as it is needed for asset precompilation.
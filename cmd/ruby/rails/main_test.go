@@ -15,6 +15,7 @@
 package main
 
 import (
+	"strings"
 	"testing"
 
 	buildpacktest "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
@@ -24,6 +25,7 @@ func TestDetect(t *testing.T) {
 	testCases := []struct {
 		name  string
 		files map[string]string
+		env   []string
 		want  int
 	}{
 		{
@@ -73,10 +75,101 @@ func TestDetect(t *testing.T) {
 			files: map[string]string{},
 			want:  100,
 		},
+		{
+			name: "no asset precompile with db migrate",
+			files: map[string]string{
+				"bin/rails": "",
+			},
+			env:  []string{"GOOGLE_RAILS_DB_MIGRATE=true"},
+			want: 0,
+		},
+		{
+			name: "migrations",
+			files: map[string]string{
+				"bin/rails":                          "",
+				"db/migrate/20240101000000_users.rb": "",
+			},
+			want: 0,
+		},
+		{
+			name: "release command",
+			files: map[string]string{
+				"bin/rails": "",
+			},
+			env:  []string{"GOOGLE_RELEASE_COMMAND=bin/rails db:prepare"},
+			want: 0,
+		},
+		{
+			name:  "db migrate without bin/rails",
+			files: map[string]string{},
+			env:   []string{"GOOGLE_RAILS_DB_MIGRATE=true"},
+			want:  100,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			buildpacktest.TestDetect(t, detectFn, tc.name, tc.files, tc.env, tc.want)
+		})
+	}
+}
+
+func TestMigrateScript(t *testing.T) {
+	got := migrateScript("/workspace")
+
+	for _, want := range []string{"#!/bin/sh\n", "cd /workspace\n", "exec bundle exec rake db:migrate\n"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("migrateScript() = %q, want it to contain %q", got, want)
+		}
+	}
+}
+
+func TestExecDScript(t *testing.T) {
+	got := execDScript("/layers/google.ruby.rails/migrate/entrypoint.sh")
+
+	want := "#!/bin/sh\nexec /layers/google.ruby.rails/migrate/entrypoint.sh 1>&2\n"
+	if got != want {
+		t.Errorf("execDScript() = %q, want %q", got, want)
+	}
+}
+
+func TestBuild(t *testing.T) {
+	testCases := []struct {
+		name       string
+		files      map[string]string
+		envs       []string
+		wantOutput string
+	}{
+		{
+			name: "migrations",
+			files: map[string]string{
+				"bin/rails":                          "",
+				"db/migrate/20240101000000_users.rb": "",
+			},
+			wantOutput: `Adding release process: "bundle exec rails db:migrate"`,
+		},
+		{
+			name: "release command",
+			files: map[string]string{
+				"bin/rails":                          "",
+				"db/migrate/20240101000000_users.rb": "",
+			},
+			envs:       []string{"GOOGLE_RELEASE_COMMAND=bin/rails db:prepare"},
+			wantOutput: `Adding release process from GOOGLE_RELEASE_COMMAND: "bin/rails db:prepare"`,
+		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			buildpacktest.TestDetect(t, detectFn, tc.name, tc.files, []string{}, tc.want)
+			result, err := buildpacktest.RunBuild(t, buildFn,
+				buildpacktest.WithTestName(tc.name),
+				buildpacktest.WithFiles(tc.files),
+				buildpacktest.WithEnvs(tc.envs...),
+			)
+			if err != nil {
+				t.Fatalf("error running build: %v, logs: %s", err, result.Output)
+			}
+			if !strings.Contains(result.Output, tc.wantOutput) {
+				t.Errorf("build output = %q, want output containing %q", result.Output, tc.wantOutput)
+			}
 		})
 	}
 }
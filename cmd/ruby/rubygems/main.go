@@ -17,16 +17,22 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/fetch"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/fileutil"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/ruby"
+	"github.com/Masterminds/semver"
 	"github.com/buildpacks/libcnb"
 )
 
@@ -35,12 +41,28 @@ var (
 	rubygemsURL     = "https://rubygems.org/rubygems/rubygems-3.3.15.tgz"
 	bundler1Version = "1.17.3"
 	bundler2Version = "2.3.15"
+
+	// bundlerGemURL and bundlerInfoURL are the locations of a bundler gem and of its metadata, which
+	// includes the SHA-256 checksum of the gem.
+	bundlerGemURL  = "https://rubygems.org/gems/bundler-%s.gem"
+	bundlerInfoURL = "https://rubygems.org/api/v1/gems/bundler/versions/%s.json"
 )
 
 const (
 	layerName         = "rubygems"
 	dependencyHashKey = "dependency_hash"
 	rubyVersionKey    = "ruby_version"
+	bundlerVersionKey = "bundler_version"
+	rubygemsURLKey    = "rubygems_url"
+	rubygemsMirrorKey = "rubygems_mirror"
+
+	// envRubygemsMirror is the base URL of a rubygems.org mirror to download RubyGems from, for
+	// example https://artifactory.example.com/rubygems-remote.
+	envRubygemsMirror = "GOOGLE_RUBYGEMS_MIRROR"
+
+	// pinnedBundlerConstraint is the range of versions allowed in GOOGLE_BUNDLER_VERSION. Bundler 3
+	// is not released yet.
+	pinnedBundlerConstraint = ">= 2.0.0, < 3.0.0"
 )
 
 func main() {
@@ -71,24 +93,60 @@ func buildFn(ctx *gcp.Context) error {
 		return fmt.Errorf("creating layer: %w", err)
 	}
 
-	if err = installRubygems(ctx, layer); err != nil {
+	bundledWith, err := lockedBundlerVersion(ctx)
+	if err != nil {
 		return err
 	}
-
-	// Install bundler1 for older Ruby runtimes if required. Ruby 3.2+ does not support it.
-	supportsBundler1, err := ruby.SupportsBundler1(ctx)
+	pinned, err := pinnedBundlerVersion(os.Getenv(env.BundlerVersion))
 	if err != nil {
 		return err
 	}
-	if supportsBundler1 {
-		usingBundler1, err := isUsingBundler1(ctx)
+	rubyVersion := os.Getenv(ruby.RubyVersionKey)
+	// Since Ruby 2.5.x has issues with the default RubyGems (3.3.15) and Bunder 2 versions,
+	// use an older version to maintain functionality.
+	if ruby.IsRuby25(ctx) {
+		rubygemsURL = "https://rubygems.org/rubygems/rubygems-3.2.26.tgz"
+		bundler2Version = "2.2.26"
+	}
+	wantBundler := bundledWith
+	if wantBundler == "" {
+		wantBundler = bundler2Version
+	}
+	if pinned != "" {
+		if bundledWith != "" && bundledWith != pinned {
+			ctx.Logf("Using bundler %s from %s instead of %s from the lockfile BUNDLED WITH.", pinned, env.BundlerVersion, bundledWith)
+		}
+		wantBundler = pinned
+	}
+
+	cacheKey := layerCacheKey(wantBundler, rubyVersion)
+	if isCached(ctx, layer, cacheKey) {
+		ctx.CacheHit(layerName)
+		ctx.Logf("RubyGems and bundler %s cache hit, skipping installation.", wantBundler)
+	} else {
+		ctx.CacheMiss(layerName)
+		if err := ctx.ClearLayer(layer); err != nil {
+			return fmt.Errorf("clearing layer %q: %w", layer.Name, err)
+		}
+		if err = installRubygems(ctx, layer); err != nil {
+			return err
+		}
+		var installed string
+		if pinned != "" {
+			installed, err = pinned, installPinnedBundler(ctx, layer, pinned)
+		} else {
+			installed, err = installBundler(ctx, layer, bundledWith)
+		}
 		if err != nil {
 			return err
 		}
-		if usingBundler1 {
-			if err = installBundler1(ctx, layer); err != nil {
-				return err
-			}
+		if err := copyBundlerExe(layer, installed); err != nil {
+			return err
+		}
+		// The key records the requested bundler version rather than the installed one, so that a
+		// fallback from a version that cannot be installed is reused by the next build.
+		for k, v := range cacheKey {
+			ctx.SetMetadata(layer, k, v)
 		}
 	}
 
@@ -104,48 +162,187 @@ func buildFn(ctx *gcp.Context) error {
 	return nil
 }
 
-func isUsingBundler1(ctx *gcp.Context) (bool, error) {
-	lockFile := ""
-	exists, err := ctx.FileExists("Gemfile.lock")
-	if err != nil {
-		return false, err
+// layerCacheKey returns the layer metadata identifying the installation of RubyGems from the
+// rubygemsURL tarball, possibly through the GOOGLE_RUBYGEMS_MIRROR mirror, and of the requested
+// bundler version for the Ruby version.
+func layerCacheKey(wantBundler, rubyVersion string) map[string]string {
+	return map[string]string{
+		bundlerVersionKey: wantBundler,
+		rubyVersionKey:    rubyVersion,
+		rubygemsURLKey:    rubygemsURL,
+		rubygemsMirrorKey: os.Getenv(envRubygemsMirror),
 	}
-	if exists {
-		lockFile = filepath.Join(ctx.ApplicationRoot(), "Gemfile.lock")
-	} else {
+}
 
-		exists, err = ctx.FileExists("gems.locked")
+// isCached returns true if the layer metadata matches every entry of the cache key. The key is only
+// recorded once the installation succeeds, since clearing the layer clears its metadata.
+func isCached(ctx *gcp.Context, layer *libcnb.Layer, cacheKey map[string]string) bool {
+	for k, v := range cacheKey {
+		if ctx.GetMetadata(layer, k) != v {
+			return false
+		}
+	}
+	return true
+}
+
+// lockedBundlerVersion returns the bundler version from BUNDLED WITH in Gemfile.lock or
+// gems.locked, or an empty string if there is no lockfile or it does not specify one.
+func lockedBundlerVersion(ctx *gcp.Context) (string, error) {
+	for _, name := range []string{"Gemfile.lock", "gems.locked"} {
+		exists, err := ctx.FileExists(name)
 		if err != nil {
-			return false, err
+			return "", err
 		}
 		if exists {
-			lockFile = filepath.Join(ctx.ApplicationRoot(), "gems.locked")
-		} else {
-			return false, nil
+			return ruby.ParseBundlerVersion(filepath.Join(ctx.ApplicationRoot(), name))
 		}
 	}
+	return "", nil
+}
 
-	version, err := ruby.ParseBundlerVersion(lockFile)
+// installBundler installs the exact bundler version from the lockfile BUNDLED WITH inside the
+// rubygems layer and returns the installed version. It falls back to the bundler that comes with
+// RubyGems if the lockfile does not specify a version or that version can't be installed. Bundler 1
+// lockfiles fall back to {bundler1Version} instead, and keep the RubyGems default on Ruby 3.2+
+// which does not support Bundler 1.
+func installBundler(ctx *gcp.Context, layer *libcnb.Layer, bundledWith string) (string, error) {
+	if bundledWith == "" || bundledWith == bundler2Version {
+		return bundler2Version, nil
+	}
+	if !strings.HasPrefix(bundledWith, "1.") {
+		if err := installBundlerGem(ctx, layer, bundledWith); err != nil {
+			ctx.Warnf("Failed to install bundler %s from the lockfile BUNDLED WITH, using bundler %s instead: %v", bundledWith, bundler2Version, err)
+			return bundler2Version, nil
+		}
+		return bundledWith, nil
+	}
+
+	// Ruby 3.2+ does not support bundler 1.
+	supportsBundler1, err := ruby.SupportsBundler1(ctx)
 	if err != nil {
-		return false, err
+		return "", err
+	}
+	if !supportsBundler1 {
+		ctx.Warnf("Bundler %s from the lockfile BUNDLED WITH does not support Ruby %s, using bundler %s instead.", bundledWith, os.Getenv(ruby.RubyVersionKey), bundler2Version)
+		return bundler2Version, nil
 	}
+	err = installBundlerGem(ctx, layer, bundledWith)
+	if err == nil {
+		return bundledWith, nil
+	}
+	if bundledWith == bundler1Version {
+		return "", err
+	}
+	ctx.Warnf("Failed to install bundler %s from the lockfile BUNDLED WITH, using bundler %s instead: %v", bundledWith, bundler1Version, err)
+	if err := installBundlerGem(ctx, layer, bundler1Version); err != nil {
+		return "", err
+	}
+	return bundler1Version, nil
+}
 
-	return strings.HasPrefix(version, "1."), nil
+// pinnedBundlerVersion validates the bundler version set in GOOGLE_BUNDLER_VERSION and returns it
+// in canonical form, or an empty string if the variable is not set.
+func pinnedBundlerVersion(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", nil
+	}
+	v, err := semver.NewVersion(raw)
+	if err != nil {
+		return "", gcp.UserErrorf("invalid %s %q: %v", env.BundlerVersion, raw, err)
+	}
+	c, err := semver.NewConstraint(pinnedBundlerConstraint)
+	if err != nil {
+		return "", gcp.InternalErrorf("parsing constraint %q: %v", pinnedBundlerConstraint, err)
+	}
+	if !c.Check(v) {
+		return "", gcp.UserErrorf("invalid %s %q: the version must satisfy %q", env.BundlerVersion, raw, pinnedBundlerConstraint)
+	}
+	return v.String(), nil
 }
 
-// installBundler1 installs bundler {bundler1Version} inside the rubygems layer
-func installBundler1(ctx *gcp.Context, layer *libcnb.Layer) error {
-	ctx.Logf("Installing bundler %s since the Gemfile.lock BUNDLED WITH uses 1.x.x", bundler1Version)
-	_, err := ctx.Exec([]string{"gem", "install", fmt.Sprintf("bundler:%s", bundler1Version), "--no-document"},
+// installPinnedBundler downloads the given bundler version from rubygems.org, verifies the SHA-256
+// checksum of the gem against the one published by rubygems.org, and installs it inside the
+// rubygems layer in place of the bundler that comes with RubyGems.
+func installPinnedBundler(ctx *gcp.Context, layer *libcnb.Layer, version string) error {
+	var info struct {
+		SHA string `json:"sha"`
+	}
+	infoURL := fmt.Sprintf(bundlerInfoURL, version)
+	if err := fetch.JSON(infoURL, &info); err != nil {
+		return gcp.UserErrorf("fetching the checksum of bundler %s set in %s from %s: %w", version, env.BundlerVersion, infoURL, err)
+	}
+	if info.SHA == "" {
+		return gcp.InternalErrorf("no checksum for bundler %s in %s", version, infoURL)
+	}
+
+	tempDir, err := os.MkdirTemp("", "bundler")
+	if err != nil {
+		return gcp.InternalErrorf("creating a temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+	gem := filepath.Join(tempDir, fmt.Sprintf("bundler-%s.gem", version))
+	gemURL := fmt.Sprintf(bundlerGemURL, version)
+	ctx.Logf("Downloading bundler %s from %s", version, gemURL)
+	if err := fetch.File(gemURL, gem); err != nil {
+		return gcp.InternalErrorf("downloading %s: %v", gemURL, err)
+	}
+	got, err := fileSHA256(gem)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(got, info.SHA) {
+		return gcp.InternalErrorf("SHA-256 checksum of %s is %s, want %s", gemURL, got, info.SHA)
+	}
+
+	ctx.Logf("Installing bundler %s", version)
+	if err := execGemInstall(ctx, layer, version, []string{"--local", gem}); err != nil {
+		return err
+	}
+	return removeDefaultBundler(layer)
+}
+
+// fileSHA256 returns the hex-encoded SHA-256 checksum of the file at path.
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", gcp.InternalErrorf("opening %s: %v", path, err)
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", gcp.InternalErrorf("reading %s: %v", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// installBundlerGem installs the given bundler version inside the rubygems layer in place of the
+// bundler that comes with RubyGems.
+func installBundlerGem(ctx *gcp.Context, layer *libcnb.Layer, version string) error {
+	ctx.Logf("Installing bundler %s", version)
+	if err := execGemInstall(ctx, layer, version, []string{fmt.Sprintf("bundler:%s", version)}); err != nil {
+		return err
+	}
+	return removeDefaultBundler(layer)
+}
+
+// execGemInstall runs gem install with the given arguments inside the rubygems layer.
+func execGemInstall(ctx *gcp.Context, layer *libcnb.Layer, version string, args []string) error {
+	cmd := append(append([]string{"gem", "install"}, args...), "--no-document")
+	_, err := ctx.Exec(cmd,
 		gcp.WithEnv(fmt.Sprintf("GEM_PATH=%s", layer.Path),
 			fmt.Sprintf("GEM_HOME=%s", layer.Path)),
 		gcp.WithUserAttribution,
 	)
 	if err != nil {
-		return fmt.Errorf("installing bundler %s, err: %v", bundler1Version, err)
+		return fmt.Errorf("installing bundler %s, err: %v", version, err)
 	}
+	return nil
+}
 
-	// bundler 1.17.3 won't work if we don't remove the newer bundler that comes with rubygems
+// removeDefaultBundler removes the bundler that comes with RubyGems from the rubygems layer.
+func removeDefaultBundler(layer *libcnb.Layer) error {
+	// The installed bundler won't be loaded if we don't remove the bundler that comes with rubygems
 	if err := os.RemoveAll(filepath.Join(layer.Path, "lib", "bundler")); err != nil &&
 		!errors.Is(err, os.ErrNotExist) {
 		return err
@@ -167,15 +364,8 @@ func installRubygems(ctx *gcp.Context, layer *libcnb.Layer) error {
 	}
 	defer os.RemoveAll(tempDir)
 
-	// Since Ruby 2.5.x has issues with the default RubyGems (3.3.15) and Bunder 2 versions,
-	// use an older version to maintain functionality.
-	if ruby.IsRuby25(ctx) {
-		rubygemsURL = "https://rubygems.org/rubygems/rubygems-3.2.26.tgz"
-		bundler2Version = "2.2.26"
-	}
-
-	if err = fetch.Tarball(rubygemsURL, tempDir, 1); err != nil {
-		return fmt.Errorf("fetching rubygems tarball from %s, err: %q", rubygemsURL, err)
+	if err = fetchRubygems(ctx, tempDir); err != nil {
+		return err
 	}
 
 	// this allows us to ship rubygems and bundler separately from the ruby runtime
@@ -185,18 +375,63 @@ func installRubygems(ctx *gcp.Context, layer *libcnb.Layer) error {
 	); err != nil {
 		return err
 	}
+	return nil
+}
 
-	// this is used to run bundler/setup
-	// https://github.com/rubygems/rubygems/blob/v3.3.15/bundler/lib/bundler/shared_helpers.rb#L277
+// copyBundlerExe copies the executables of the installed bundler version to the exe directory of
+// the layer. This is used to run bundler/setup
+// https://github.com/rubygems/rubygems/blob/v3.3.15/bundler/lib/bundler/shared_helpers.rb#L277
+func copyBundlerExe(layer *libcnb.Layer, version string) error {
 	destExe := filepath.Join(layer.Path, "exe")
 	os.MkdirAll(destExe, 0755)
-	if err = fileutil.MaybeCopyPathContents(
+	if err := fileutil.MaybeCopyPathContents(
 		destExe,
-		filepath.Join(layer.Path, "gems", fmt.Sprintf("bundler-%s", bundler2Version), "exe"),
+		filepath.Join(layer.Path, "gems", fmt.Sprintf("bundler-%s", version), "exe"),
 		fileutil.AllPaths,
 	); err != nil && !errors.Is(err, os.ErrNotExist) {
 		return err
 	}
+	return nil
+}
 
+// fetchRubygems downloads the RubyGems tarball into dir, going through the mirror configured by
+// GOOGLE_RUBYGEMS_MIRROR if set. Falls back to the upstream URL if the mirror download fails.
+func fetchRubygems(ctx *gcp.Context, dir string) error {
+	if mirror := os.Getenv(envRubygemsMirror); mirror != "" {
+		mirrored, err := mirrorURL(rubygemsURL, mirror)
+		if err != nil {
+			return gcp.UserErrorf("invalid %s: %v", envRubygemsMirror, err)
+		}
+		ctx.Logf("Fetching rubygems from mirror %s", mirrored)
+		err = fetch.Tarball(mirrored, dir, 1)
+		if err == nil {
+			return nil
+		}
+		ctx.Warnf("Failed to fetch rubygems from mirror %s, falling back to %s: %v", mirrored, rubygemsURL, err)
+	}
+	if err := fetch.Tarball(rubygemsURL, dir, 1); err != nil {
+		return fmt.Errorf("fetching rubygems tarball from %s, err: %q", rubygemsURL, err)
+	}
 	return nil
 }
+
+// mirrorURL rewrites the scheme and host of rawURL to those of mirror. Any path in mirror is
+// prepended to the path of rawURL.
+func mirrorURL(rawURL, mirror string) (string, error) {
+	m, err := url.Parse(mirror)
+	if err != nil {
+		return "", err
+	}
+	if m.Scheme == "" || m.Host == "" {
+		return "", fmt.Errorf("mirror %q must be an absolute URL, for example https://rubygems.example.com", mirror)
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	u.Scheme = m.Scheme
+	u.Host = m.Host
+	u.User = m.User
+	u.Path = strings.TrimSuffix(m.Path, "/") + u.Path
+	return u.String(), nil
+}
@@ -22,8 +22,10 @@ import (
 	buildpacktest "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
 	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
 	"github.com/GoogleCloudPlatform/buildpacks/internal/testserver"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/ruby"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/testdata"
+	"github.com/buildpacks/libcnb"
 )
 
 func TestDetect(t *testing.T) {
@@ -69,8 +71,10 @@ func TestDetect(t *testing.T) {
 
 func TestBuild(t *testing.T) {
 	var (
-		installCommand         = fmt.Sprintf("ruby setup.rb -E --no-document --destdir %s --prefix /", layerName)
-		bundler1InstallCommand = fmt.Sprintf("gem install bundler:%s --no-document", bundler1Version)
+		installCommand                 = fmt.Sprintf("ruby setup.rb -E --no-document --destdir %s --prefix /", layerName)
+		bundler1InstallCommand         = "gem install bundler:1.17.0 --no-document"
+		bundler1FallbackInstallCommand = fmt.Sprintf("gem install bundler:%s --no-document", bundler1Version)
+		bundler2InstallCommand         = "gem install bundler:2.1.14 --no-document"
 	)
 
 	testCases := []struct {
@@ -83,6 +87,8 @@ func TestBuild(t *testing.T) {
 		httpStatusInstaller int
 		app                 string
 		rubyVersion         string
+		mirror              bool
+		httpStatusMirror    int
 	}{
 		{
 			name: "bundler 1 in Gemfile.lock",
@@ -94,6 +100,23 @@ func TestBuild(t *testing.T) {
 				installCommand,
 				bundler1InstallCommand,
 			},
+			skippedCommands: []string{
+				bundler1FallbackInstallCommand,
+			},
+			tarFile: "testdata/dummy-rubygems.tar.gz",
+			app:     "testdata/bundler1",
+		},
+		{
+			name: "bundler 1 falls back when exact version fails",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New("^ruby"),
+				mockprocess.New("^gem install bundler:1.17.0", mockprocess.WithExitCode(1)),
+			},
+			wantCommands: []string{
+				installCommand,
+				bundler1InstallCommand,
+				bundler1FallbackInstallCommand,
+			},
 			tarFile: "testdata/dummy-rubygems.tar.gz",
 			app:     "testdata/bundler1",
 		},
@@ -105,6 +128,20 @@ func TestBuild(t *testing.T) {
 			},
 			wantCommands: []string{
 				installCommand,
+				bundler2InstallCommand,
+			},
+			tarFile: "testdata/dummy-rubygems.tar.gz",
+			app:     "testdata/bundler2",
+		},
+		{
+			name: "bundler 2 falls back to default when exact version fails",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New("^ruby"),
+				mockprocess.New("^gem", mockprocess.WithExitCode(1)),
+			},
+			wantCommands: []string{
+				installCommand,
+				bundler2InstallCommand,
 			},
 			tarFile: "testdata/dummy-rubygems.tar.gz",
 			app:     "testdata/bundler2",
@@ -121,6 +158,7 @@ func TestBuild(t *testing.T) {
 			},
 			skippedCommands: []string{
 				bundler1InstallCommand,
+				bundler1FallbackInstallCommand,
 			},
 			tarFile:     "testdata/dummy-rubygems.tar.gz",
 			app:         "testdata/bundler1",
@@ -131,6 +169,34 @@ func TestBuild(t *testing.T) {
 			httpStatusInstaller: http.StatusNotFound,
 			wantExitCode:        1,
 		},
+		{
+			name:                "downloads from mirror",
+			mirror:              true,
+			httpStatusInstaller: http.StatusNotFound,
+			mocks: []*mockprocess.Mock{
+				mockprocess.New("^ruby"),
+				mockprocess.New("^cp"),
+			},
+			wantCommands: []string{
+				installCommand,
+			},
+			tarFile: "testdata/dummy-rubygems.tar.gz",
+			app:     "testdata/bundler2",
+		},
+		{
+			name:             "falls back to upstream when mirror fails",
+			mirror:           true,
+			httpStatusMirror: http.StatusNotFound,
+			mocks: []*mockprocess.Mock{
+				mockprocess.New("^ruby"),
+				mockprocess.New("^cp"),
+			},
+			wantCommands: []string{
+				installCommand,
+			},
+			tarFile: "testdata/dummy-rubygems.tar.gz",
+			app:     "testdata/bundler2",
+		},
 		{
 			name: "handles rubygems install failure",
 			mocks: []*mockprocess.Mock{
@@ -152,6 +218,7 @@ func TestBuild(t *testing.T) {
 			wantCommands: []string{
 				installCommand,
 				bundler1InstallCommand,
+				bundler1FallbackInstallCommand,
 			},
 			tarFile:      "testdata/dummy-rubygems.tar.gz",
 			app:          "testdata/bundler1",
@@ -168,6 +235,15 @@ func TestBuild(t *testing.T) {
 				testserver.WithMockURL(&rubygemsURL),
 			)
 
+			if tc.mirror {
+				mirror := testserver.New(
+					t,
+					testserver.WithStatus(tc.httpStatusMirror),
+					testserver.WithFile(testdata.MustGetPath(tc.tarFile)),
+				)
+				t.Setenv(envRubygemsMirror, mirror.URL)
+			}
+
 			opts := []buildpacktest.Option{
 				buildpacktest.WithTestName(tc.name),
 				buildpacktest.WithExecMocks(tc.mocks...),
@@ -206,3 +282,173 @@ func TestBuild(t *testing.T) {
 		})
 	}
 }
+
+func TestIsCached(t *testing.T) {
+	previous := map[string]interface{}{
+		bundlerVersionKey: "2.4.0",
+		rubyVersionKey:    "3.2.0",
+		rubygemsURLKey:    rubygemsURL,
+		rubygemsMirrorKey: "",
+	}
+	testCases := []struct {
+		name        string
+		wantBundler string
+		rubyVersion string
+		rubygemsURL string
+		mirror      string
+		want        bool
+	}{
+		{
+			name:        "same inputs",
+			wantBundler: "2.4.0",
+			rubyVersion: "3.2.0",
+			rubygemsURL: rubygemsURL,
+			want:        true,
+		},
+		{
+			name:        "other bundler version",
+			wantBundler: "2.5.0",
+			rubyVersion: "3.2.0",
+			rubygemsURL: rubygemsURL,
+		},
+		{
+			name:        "other RubyGems tarball",
+			wantBundler: "2.4.0",
+			rubyVersion: "3.2.0",
+			rubygemsURL: "https://rubygems.org/rubygems/rubygems-3.2.26.tgz",
+		},
+		{
+			name:        "mirror set",
+			wantBundler: "2.4.0",
+			rubyVersion: "3.2.0",
+			rubygemsURL: rubygemsURL,
+			mirror:      "https://rubygems.example.com",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			origURL := rubygemsURL
+			defer func() { rubygemsURL = origURL }()
+			rubygemsURL = tc.rubygemsURL
+			t.Setenv(envRubygemsMirror, tc.mirror)
+			layer := &libcnb.Layer{Metadata: map[string]interface{}{}}
+			for k, v := range previous {
+				layer.Metadata[k] = v
+			}
+
+			if got := isCached(gcp.NewContext(), layer, layerCacheKey(tc.wantBundler, tc.rubyVersion)); got != tc.want {
+				t.Errorf("isCached() = %t, want %t", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMirrorURL(t *testing.T) {
+	testCases := []struct {
+		name    string
+		url     string
+		mirror  string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "host only",
+			url:    "https://rubygems.org/rubygems/rubygems-3.3.15.tgz",
+			mirror: "https://gems.example.com",
+			want:   "https://gems.example.com/rubygems/rubygems-3.3.15.tgz",
+		},
+		{
+			name:   "with path prefix",
+			url:    "https://rubygems.org/rubygems/rubygems-3.3.15.tgz",
+			mirror: "http://artifactory.example.com:8080/api/gems/rubygems-remote/",
+			want:   "http://artifactory.example.com:8080/api/gems/rubygems-remote/rubygems/rubygems-3.3.15.tgz",
+		},
+		{
+			name:    "relative mirror",
+			url:     "https://rubygems.org/rubygems/rubygems-3.3.15.tgz",
+			mirror:  "gems.example.com",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := mirrorURL(tc.url, tc.mirror)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("mirrorURL(%q, %q) got error: %v, want error: %v", tc.url, tc.mirror, err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("mirrorURL(%q, %q) = %q, want %q", tc.url, tc.mirror, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPinnedBundlerVersion(t *testing.T) {
+	testCases := []struct {
+		name    string
+		version string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "not set",
+		},
+		{
+			name:    "bundler 2",
+			version: "2.4.22",
+			want:    "2.4.22",
+		},
+		{
+			name:    "lowest bundler 2",
+			version: "2.0.0",
+			want:    "2.0.0",
+		},
+		{
+			name:    "surrounding whitespace",
+			version: " 2.5.6 ",
+			want:    "2.5.6",
+		},
+		{
+			name:    "v prefix",
+			version: "v2.3.26",
+			want:    "2.3.26",
+		},
+		{
+			name:    "bundler 1",
+			version: "1.17.3",
+			wantErr: true,
+		},
+		{
+			name:    "bundler 3",
+			version: "3.0.0",
+			wantErr: true,
+		},
+		{
+			name:    "prerelease",
+			version: "2.5.0-pre1",
+			wantErr: true,
+		},
+		{
+			name:    "not a version",
+			version: "latest",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := pinnedBundlerVersion(tc.version)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("pinnedBundlerVersion(%q) = %q, want error", tc.version, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("pinnedBundlerVersion(%q) got error: %v", tc.version, err)
+			}
+			if got != tc.want {
+				t.Errorf("pinnedBundlerVersion(%q) = %q, want %q", tc.version, got, tc.want)
+			}
+		})
+	}
+}
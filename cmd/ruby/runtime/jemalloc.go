@@ -0,0 +1,188 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/fetch"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+const (
+	jemallocLayer = "jemalloc"
+	// jemallocVersion is the upstream release built when the stack does not provide jemalloc.
+	jemallocVersion = "5.3.0"
+	// jemallocSHA256 is the checksum of the jemallocVersion source release.
+	jemallocSHA256 = "2db82d1e7119df3e71b7640219b6dfe84789bc0537983c3b7ac4f7189aecfeaa"
+	// jemallocURL is the source release of a jemalloc version.
+	jemallocURL = "https://github.com/jemalloc/jemalloc/releases/download/%[1]s/jemalloc-%[1]s.tar.bz2"
+	// jemallocLibName is the name of the jemalloc shared library.
+	jemallocLibName = "libjemalloc.so.2"
+	// jemallocSourceKey is the jemalloc layer metadata key recording where the library came from.
+	jemallocSourceKey = "source"
+	// jemallocLoadedScript exits with an error unless jemalloc is loaded in the Ruby process.
+	jemallocLoadedScript = "exit(File.read('/proc/self/maps').include?('libjemalloc') ? 0 : 1)"
+)
+
+// stackJemallocLibs are the paths of the library installed by the libjemalloc2 package of the
+// stack. The run image may not have the package, so the library is copied into a launch layer.
+var stackJemallocLibs = []string{
+	"/usr/lib/x86_64-linux-gnu/" + jemallocLibName,
+	"/usr/lib/" + jemallocLibName,
+}
+
+// useJemalloc returns true if GOOGLE_RUBY_USE_JEMALLOC is set to true, unless jemalloc is already
+// preloaded by the stack.
+func useJemalloc(ctx *gcp.Context) (bool, error) {
+	use, err := env.IsPresentAndTrue(env.RubyUseJemalloc)
+	if err != nil || !use {
+		return false, err
+	}
+	preload := os.Getenv("LD_PRELOAD")
+	for _, lib := range strings.FieldsFunc(preload, func(r rune) bool { return r == ':' || r == ' ' }) {
+		if !strings.Contains(filepath.Base(lib), "jemalloc") {
+			continue
+		}
+		exists, err := ctx.FileExists(lib)
+		if err != nil {
+			return false, err
+		}
+		if exists {
+			ctx.Logf("jemalloc is already preloaded by the stack (LD_PRELOAD=%s), skipping its installation.", preload)
+			return false, nil
+		}
+		ctx.Warnf("LD_PRELOAD=%s preloads %s, which does not exist, installing jemalloc.", preload, lib)
+	}
+	return true, nil
+}
+
+// installJemalloc installs jemalloc in a launch layer and preloads it in the application if
+// GOOGLE_RUBY_USE_JEMALLOC is set. It fails if Ruby does not load the library, which would only
+// make the application silently slower.
+func installJemalloc(ctx *gcp.Context, rl *libcnb.Layer, preinstalledRuby bool) error {
+	use, err := useJemalloc(ctx)
+	if err != nil || !use {
+		return err
+	}
+	jl, err := ctx.Layer(jemallocLayer, gcp.CacheLayer, gcp.LaunchLayer)
+	if err != nil {
+		return fmt.Errorf("creating %v layer: %w", jemallocLayer, err)
+	}
+	lib, err := installJemallocLib(ctx, jl)
+	if err != nil {
+		return err
+	}
+
+	rubyBin := "ruby"
+	if !preinstalledRuby {
+		rubyBin = filepath.Join(rl.Path, "bin", "ruby")
+	}
+	if _, err := ctx.Exec([]string{rubyBin, "-e", jemallocLoadedScript}, gcp.WithEnv("LD_PRELOAD="+lib)); err != nil {
+		return gcp.UserErrorf("jemalloc %s could not be preloaded in Ruby, unset %s to use the default memory allocator: %w", lib, env.RubyUseJemalloc, err)
+	}
+	ctx.Logf("Preloading jemalloc from %s in the application.", ctx.GetMetadata(jl, jemallocSourceKey))
+	jl.LaunchEnvironment.Prepend("LD_PRELOAD", ":", lib)
+	jl.LaunchEnvironment.Default("MALLOC_ARENA_MAX", "2")
+	return nil
+}
+
+// installJemallocLib puts the jemalloc shared library in the layer and returns its path. It copies
+// the library of the stack if there is one, and otherwise builds the pinned upstream release,
+// whose checksum is verified before it is extracted. The layer is reused while its source matches.
+func installJemallocLib(ctx *gcp.Context, jl *libcnb.Layer) (string, error) {
+	lib := filepath.Join(jl.Path, "lib", jemallocLibName)
+	source, err := stackJemallocLib(ctx)
+	if err != nil {
+		return "", err
+	}
+	if source == "" {
+		source = fmt.Sprintf(jemallocURL, jemallocVersion)
+	}
+	cached, err := ctx.FileExists(lib)
+	if err != nil {
+		return "", err
+	}
+	if cached && ctx.GetMetadata(jl, jemallocSourceKey) == source {
+		ctx.CacheHit(jemallocLayer)
+		return lib, nil
+	}
+	ctx.CacheMiss(jemallocLayer)
+	if err := ctx.ClearLayer(jl); err != nil {
+		return "", fmt.Errorf("clearing layer %q: %w", jl.Name, err)
+	}
+	if strings.HasPrefix(source, "/") {
+		if err := copyJemallocLib(ctx, source, lib); err != nil {
+			return "", err
+		}
+	} else if err := buildJemalloc(ctx, source, jl.Path); err != nil {
+		return "", err
+	}
+	ctx.SetMetadata(jl, jemallocSourceKey, source)
+	return lib, nil
+}
+
+// stackJemallocLib returns the path of the jemalloc library of the stack, or "" if the stack does
+// not have one.
+func stackJemallocLib(ctx *gcp.Context) (string, error) {
+	for _, lib := range stackJemallocLibs {
+		exists, err := ctx.FileExists(lib)
+		if err != nil {
+			return "", err
+		}
+		if exists {
+			return lib, nil
+		}
+	}
+	return "", nil
+}
+
+// copyJemallocLib copies the library of the stack to dest, following the symlink of the package.
+func copyJemallocLib(ctx *gcp.Context, src, dest string) error {
+	content, err := ctx.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	if err := ctx.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	return ctx.WriteFile(dest, content, 0755)
+}
+
+// buildJemalloc builds the shared library of the jemalloc source release at url into prefix.
+func buildJemalloc(ctx *gcp.Context, url, prefix string) error {
+	src, err := ctx.TempDir(jemallocLayer)
+	if err != nil {
+		return err
+	}
+	ctx.Logf("Building jemalloc %s from %s.", jemallocVersion, url)
+	if err := fetch.TarballWithSHA256(url, src, 1, jemallocSHA256); err != nil {
+		return err
+	}
+	for _, cmd := range [][]string{
+		{"./configure", "--prefix=" + prefix},
+		{"make", "install_lib_shared"},
+	} {
+		if _, err := ctx.Exec(cmd, gcp.WithWorkDir(src)); err != nil {
+			return gcp.InternalErrorf("building jemalloc %s: %w", jemallocVersion, err)
+		}
+	}
+	return nil
+}
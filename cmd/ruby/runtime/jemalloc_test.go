@@ -0,0 +1,157 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+func TestUseJemalloc(t *testing.T) {
+	stackLib := filepath.Join(t.TempDir(), jemallocLibName)
+	if err := os.WriteFile(stackLib, []byte("jemalloc"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		name      string
+		value     string
+		ldPreload string
+		want      bool
+		wantErr   bool
+	}{
+		{
+			name: "not set",
+		},
+		{
+			name:  "enabled",
+			value: "true",
+			want:  true,
+		},
+		{
+			name:  "disabled",
+			value: "false",
+		},
+		{
+			name:      "already preloaded by the stack",
+			value:     "true",
+			ldPreload: "/usr/lib/libfoo.so:" + stackLib,
+		},
+		{
+			name:      "preloaded library does not exist",
+			value:     "true",
+			ldPreload: "/does/not/exist/libjemalloc.so.2",
+			want:      true,
+		},
+		{
+			name:      "other library preloaded",
+			value:     "true",
+			ldPreload: "/usr/lib/libfoo.so",
+			want:      true,
+		},
+		{
+			name:    "invalid value",
+			value:   "yes please",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.value != "" {
+				t.Setenv(env.RubyUseJemalloc, tc.value)
+			}
+			t.Setenv("LD_PRELOAD", tc.ldPreload)
+
+			got, err := useJemalloc(gcp.NewContext())
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("useJemalloc() got error: %v, want error: %v", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("useJemalloc() = %t, want %t", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestInstallJemallocLibFromStack(t *testing.T) {
+	stackLib := filepath.Join(t.TempDir(), jemallocLibName)
+	if err := os.WriteFile(stackLib, []byte("jemalloc"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	orig := stackJemallocLibs
+	stackJemallocLibs = []string{"/does/not/exist/" + jemallocLibName, stackLib}
+	t.Cleanup(func() { stackJemallocLibs = orig })
+	testCases := []struct {
+		name     string
+		metadata map[string]interface{}
+		existing string
+		want     string
+	}{
+		{
+			name:     "copies the library of the stack",
+			metadata: map[string]interface{}{},
+			want:     "jemalloc",
+		},
+		{
+			name:     "reuses the cached library",
+			metadata: map[string]interface{}{jemallocSourceKey: stackLib},
+			existing: "cached",
+			want:     "cached",
+		},
+		{
+			name:     "replaces a library from another source",
+			metadata: map[string]interface{}{jemallocSourceKey: "https://example.com/jemalloc.tar.bz2"},
+			existing: "built",
+			want:     "jemalloc",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			jl := &libcnb.Layer{Name: jemallocLayer, Path: t.TempDir(), Metadata: tc.metadata}
+			if tc.existing != "" {
+				if err := os.MkdirAll(filepath.Join(jl.Path, "lib"), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filepath.Join(jl.Path, "lib", jemallocLibName), []byte(tc.existing), 0755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			ctx := gcp.NewContext()
+
+			lib, err := installJemallocLib(ctx, jl)
+			if err != nil {
+				t.Fatalf("installJemallocLib() got error: %v", err)
+			}
+
+			if want := filepath.Join(jl.Path, "lib", jemallocLibName); lib != want {
+				t.Errorf("installJemallocLib() = %q, want %q", lib, want)
+			}
+			content, err := os.ReadFile(lib)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(content); got != tc.want {
+				t.Errorf("installJemallocLib() library content = %q, want %q", got, tc.want)
+			}
+			if got := ctx.GetMetadata(jl, jemallocSourceKey); got != stackLib {
+				t.Errorf("installJemallocLib() source metadata = %q, want %q", got, stackLib)
+			}
+		})
+	}
+}
@@ -26,6 +26,7 @@ import (
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/nodejs"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/ruby"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/runtime"
+	"github.com/buildpacks/libcnb"
 )
 
 var osNodeVersionMap = map[string]string{
@@ -89,24 +90,22 @@ func buildFn(ctx *gcp.Context) error {
 		rl.BuildEnvironment.Override(nodejs.EnvNodeVersion, railsNodeVersion)
 	}
 
-	_, err = runtime.InstallTarballIfNotCached(ctx, runtime.Ruby, version, rl)
+	skip, err := runtime.SkipInstall()
 	if err != nil {
 		return err
 	}
-
-	versionInstalled, _ := runtime.ResolveVersion(ctx, runtime.Ruby, version, runtime.OSForStack(ctx))
-	// Store the installed Ruby version for subsequent buildpacks (like RubyGems) that depend on it.
-	rl.BuildEnvironment.Override(ruby.RubyVersionKey, versionInstalled)
-
-	ctx.Exec([]string{"ldd", filepath.Join(rl.Path, "lib/ruby/3.1.0/x86_64-linux/psych.so")})
-
-	// For GAE and GCF, install RubyGems and Bundler in the same layer to maintain compatibility
-	// with existing builder images.
-	if env.IsGAE() || env.IsGCF() {
-		err = runtime.PinGemAndBundlerVersion(ctx, version, rl)
+	if skip {
+		versionInstalled, err := runtime.CheckPreinstalled(ctx, runtime.Ruby, version)
 		if err != nil {
-			return fmt.Errorf("updating rubygems and bundler: %w", err)
+			return err
 		}
+		rl.BuildEnvironment.Override(ruby.RubyVersionKey, versionInstalled)
+	} else if err := installRuby(ctx, version, rl); err != nil {
+		return err
+	}
+
+	if err := installJemalloc(ctx, rl, skip); err != nil {
+		return err
 	}
 
 	// Ruby sometimes writes to local directories tmp/ and log/, so we link these to writable areas.
@@ -128,3 +127,25 @@ func buildFn(ctx *gcp.Context) error {
 
 	return nil
 }
+
+// installRuby installs Ruby in the layer, with the RubyGems and Bundler versions of GAE and GCF.
+func installRuby(ctx *gcp.Context, version string, rl *libcnb.Layer) error {
+	if _, err := runtime.InstallTarballIfNotCached(ctx, runtime.Ruby, version, rl); err != nil {
+		return err
+	}
+
+	versionInstalled, _ := runtime.ResolveVersion(ctx, runtime.Ruby, version, runtime.OSForStack(ctx))
+	// Store the installed Ruby version for subsequent buildpacks (like RubyGems) that depend on it.
+	rl.BuildEnvironment.Override(ruby.RubyVersionKey, versionInstalled)
+
+	ctx.Exec([]string{"ldd", filepath.Join(rl.Path, "lib/ruby/3.1.0/x86_64-linux/psych.so")})
+
+	// For GAE and GCF, install RubyGems and Bundler in the same layer to maintain compatibility
+	// with existing builder images.
+	if env.IsGAE() || env.IsGCF() {
+		if err := runtime.PinGemAndBundlerVersion(ctx, version, rl); err != nil {
+			return fmt.Errorf("updating rubygems and bundler: %w", err)
+		}
+	}
+	return nil
+}
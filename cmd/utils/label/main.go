@@ -13,8 +13,9 @@
 // limitations under the License.
 
 // Implements utils/label-image buildpack.
-// The label-image buildpack adds any environment variables with the "GOOGLE_LABEL_" prefix as
-// labels in the final application image.
+// The label-image buildpack adds any environment variables with the "GOOGLE_LABEL_" prefix, and the
+// labels configured by GOOGLE_LABELS and GOOGLE_LABELS_JSON, as labels in the final application image. As the last buildpack of every group, it also scans the
+// application source for committed secrets when GOOGLE_SCAN_FOR_SECRETS is true.
 package main
 
 import (
@@ -23,6 +24,7 @@ import (
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/secretscan"
 )
 
 func main() {
@@ -47,5 +49,9 @@ func buildFn(ctx *gcp.Context) error {
 		}
 		ctx.AddLabel(key, value)
 	}
+	if err := gcp.AddUserLabels(ctx); err != nil {
+		return err
+	}
+	secretscan.Report(ctx)
 	return nil
 }
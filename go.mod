@@ -13,6 +13,7 @@ require (
 	github.com/googleapis/gax-go/v2 v2.12.0
 	github.com/hashicorp/go-retryablehttp v0.6.7
 	github.com/rs/xid v0.0.0-20170604230408-02dd45c33376
+	golang.org/x/mod v0.10.0
 	golang.org/x/oauth2 v0.8.0
 	golang.org/x/sys v0.8.0
 	google.golang.org/genproto v0.0.0-20230913181813-007df8e322eb
@@ -150,6 +150,9 @@ type Test struct {
 	MustMatch string
 	// EnableCacheTest enables a second run of the test with the buildpacks cache enabled.
 	EnableCacheTest bool
+	// MustBeReproducible specifies that a second build of the application without cache must produce
+	// the same image layers.
+	MustBeReproducible bool
 	// MustUse specifies the IDs of the buildpacks that must be used during the build.
 	MustUse []string
 	// MustNotUse specifies the IDs of the buildpacks that must not be used during the build.
@@ -262,6 +265,10 @@ func TestApp(t *testing.T, imageCtx ImageContext, cfg Test) {
 	} else {
 		testApp(t, src, image, builderName, runName, env, false, checks, cfg)
 	}
+
+	if cfg.MustBeReproducible {
+		verifyReproducible(t, src, image, builderName, runName, env, cfg)
+	}
 }
 
 func testAppWithCache(t *testing.T, src, image, builderName, runName string, env map[string]string, checks *StructureTest, cfg Test) {
@@ -1140,6 +1147,34 @@ func verifyLabelValues(t *testing.T, image string, labels map[string]string) {
 	t.Logf("Finished verifying label values (in %s)", time.Since(start))
 }
 
+// verifyReproducible builds the application again without cache and verifies that the image has the
+// same layers as the given image.
+func verifyReproducible(t *testing.T, src, image, builderName, runName string, env map[string]string, cfg Test) {
+	t.Helper()
+
+	start := time.Now()
+	rebuild := image + "-rebuild"
+	defer func() {
+		cleanUpVolumes(t, rebuild)
+		cleanUpImage(t, rebuild)
+	}()
+	buildApp(t, src, rebuild, builderName, runName, env, false, cfg)
+
+	want, err := runOutput("docker", "inspect", "--format={{json .RootFS.Layers}}", image)
+	if err != nil {
+		t.Fatalf("Error reading layers of %s: %v", image, err)
+	}
+	got, err := runOutput("docker", "inspect", "--format={{json .RootFS.Layers}}", rebuild)
+	if err != nil {
+		t.Fatalf("Error reading layers of %s: %v", rebuild, err)
+	}
+	if got != want {
+		t.Errorf("Layers differ between two builds of the same source\ngot: %v\nwant %v", got, want)
+	}
+
+	t.Logf("Finished verifying reproducibility (in %s)", time.Since(start))
+}
+
 // verifyBuildMetadata verifies the image was built with correct buildpacks.
 func verifyBuildMetadata(t *testing.T, image string, mustUse, mustNotUse []string) {
 	t.Helper()
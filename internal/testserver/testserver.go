@@ -25,6 +25,7 @@ type config struct {
 	httpStatus   int
 	responseFile string
 	responseJSON string
+	headers      map[string]string
 	mockURL      *string
 }
 
@@ -45,6 +46,16 @@ func WithJSON(json string) Option {
 	}
 }
 
+// WithHeader sets a header the server sends in the response.
+func WithHeader(key, value string) Option {
+	return func(c *config) {
+		if c.headers == nil {
+			c.headers = map[string]string{}
+		}
+		c.headers[key] = value
+	}
+}
+
 // WithFile sets the path of a file the server should send as a response.
 func WithFile(path string) Option {
 	return func(c *config) {
@@ -68,6 +79,9 @@ func New(t *testing.T, opts ...Option) *httptest.Server {
 	}
 
 	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for k, v := range options.headers {
+			w.Header().Set(k, v)
+		}
 		if options.httpStatus != 0 {
 			w.WriteHeader(options.httpStatus)
 		}
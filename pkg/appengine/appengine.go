@@ -37,10 +37,15 @@ const (
 )
 
 func getEntrypoint(ctx *gcp.Context, eg appstart.EntrypointGenerator) (*appstart.Entrypoint, error) {
-	if val := os.Getenv(env.Entrypoint); val != "" {
+	e, err := ctx.Entrypoint()
+	if err != nil {
+		return nil, err
+	}
+	if e != nil {
+		// The start command runs the entrypoint in a shell, so the exec form is quoted.
 		return &appstart.Entrypoint{
 			Type:    appstart.EntrypointUser.String(),
-			Command: val,
+			Command: e.String(),
 		}, nil
 	}
 	if eg != nil {
@@ -27,6 +27,7 @@ func TestConfig(t *testing.T) {
 	testCases := []struct {
 		name          string
 		entrypointEnv string
+		jsonEnv       string
 		runtimeEnv    string
 		mainEnv       string
 		want          appstart.Config
@@ -43,6 +44,18 @@ func TestConfig(t *testing.T) {
 				MainExecutable: "",
 			},
 		},
+		{
+			name:    "entrypoint from JSON env",
+			jsonEnv: `["gunicorn", "--log-config-json", "{\"version\": 1}", "main:app"]`,
+			want: appstart.Config{
+				Runtime: "runtime",
+				Entrypoint: appstart.Entrypoint{
+					Type:    appstart.EntrypointUser.String(),
+					Command: `gunicorn --log-config-json '{"version": 1}' main:app`,
+				},
+				MainExecutable: "",
+			},
+		},
 		{
 			name:       "runtime from env",
 			runtimeEnv: "custom runtime",
@@ -80,6 +93,7 @@ func TestConfig(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			setEnv(t, "GOOGLE_ENTRYPOINT", tc.entrypointEnv)
+			setEnv(t, "GOOGLE_ENTRYPOINT_JSON", tc.jsonEnv)
 			setEnv(t, "GOOGLE_RUNTIME", tc.runtimeEnv)
 			setEnv(t, "GAE_YAML_MAIN", tc.mainEnv)
 
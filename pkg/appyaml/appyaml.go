@@ -16,7 +16,9 @@
 package appyaml
 
 import (
+	"fmt"
 	"os"
+	"strings"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
@@ -94,6 +96,36 @@ func EntrypointIfExists(root string) (string, error) {
 	return a.Entrypoint, nil
 }
 
+// DeclaredEntrypoint returns the entrypoint from GAE app.yaml, or an empty string if app.yaml or its
+// entrypoint field does not exist. Unlike EntrypointIfExists, a missing entrypoint is not an error,
+// but an entrypoint that is empty or not a string is.
+func DeclaredEntrypoint(root string) (string, error) {
+	exist, path, err := appYamlExists(root)
+	if err != nil || !exist {
+		return "", err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", gcp.InternalErrorf("reading app yaml file %v: %v", path, err)
+	}
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(content, &raw); err != nil {
+		return "", gcp.UserErrorf("parsing app yaml file %v: %v", path, err)
+	}
+	v, ok := raw["entrypoint"]
+	if !ok {
+		return "", nil
+	}
+	entrypoint, ok := v.(string)
+	if v != nil && !ok {
+		return "", gcp.UserErrorf("invalid app yaml file %v: entrypoint must be a string, found %v", path, v)
+	}
+	if strings.TrimSpace(entrypoint) == "" {
+		return "", gcp.UserErrorf("invalid app yaml file %v: entrypoint must not be empty", path)
+	}
+	return entrypoint, nil
+}
+
 // PhpConfiguration returns the PHP configuration in runtime_config
 // for GAE Flexible
 func PhpConfiguration(root string) (RuntimeConfig, error) {
@@ -107,3 +139,57 @@ func PhpConfiguration(root string) (RuntimeConfig, error) {
 
 	return a.RuntimeConfig, nil
 }
+
+// JavaConfig is the Java configuration in app.yaml for GAE Flexible.
+type JavaConfig struct {
+	Entrypoint   string
+	EnvVariables map[string]string
+}
+
+// JavaConfiguration returns the entrypoint and env_variables in app.yaml for GAE Flexible.
+// Malformed values are reported as user errors naming the YAML path of the problem.
+func JavaConfiguration(root string) (JavaConfig, error) {
+	exist, path, err := appYamlExists(root)
+	if err != nil || !exist {
+		return JavaConfig{}, err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return JavaConfig{}, gcp.InternalErrorf("reading app yaml file %v: %v", path, err)
+	}
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(content, &raw); err != nil {
+		return JavaConfig{}, gcp.UserErrorf("parsing app yaml file %v: %v", path, err)
+	}
+
+	var c JavaConfig
+	if v, ok := raw["entrypoint"]; ok && v != nil {
+		entrypoint, ok := v.(string)
+		if !ok {
+			return JavaConfig{}, gcp.UserErrorf("invalid app yaml file %v: entrypoint must be a string, found %v", path, v)
+		}
+		c.Entrypoint = entrypoint
+	}
+	if v, ok := raw["env_variables"]; ok && v != nil {
+		vars, ok := v.(map[interface{}]interface{})
+		if !ok {
+			return JavaConfig{}, gcp.UserErrorf("invalid app yaml file %v: env_variables must be a mapping of names to values, found %v", path, v)
+		}
+		c.EnvVariables = make(map[string]string, len(vars))
+		for k, val := range vars {
+			name, ok := k.(string)
+			if !ok {
+				return JavaConfig{}, gcp.UserErrorf("invalid app yaml file %v: env_variables.%v must have a string name", path, k)
+			}
+			switch val.(type) {
+			case string, bool, int, float64:
+				c.EnvVariables[name] = fmt.Sprint(val)
+			case nil:
+				c.EnvVariables[name] = ""
+			default:
+				return JavaConfig{}, gcp.UserErrorf("invalid app yaml file %v: env_variables.%s must be a scalar value, found %v", path, name, val)
+			}
+		}
+	}
+	return c, nil
+}
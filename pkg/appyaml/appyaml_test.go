@@ -19,6 +19,8 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/google/go-cmp/cmp"
 )
 
 func TestGetEntrypointIfExists(t *testing.T) {
@@ -89,6 +91,70 @@ func TestGetEntrypointIfExists(t *testing.T) {
 	}
 }
 
+func TestDeclaredEntrypoint(t *testing.T) {
+	testCases := []struct {
+		name    string
+		env     []string
+		path    string
+		content []byte
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "no env var",
+		},
+		{
+			name:    "valid entrypoint",
+			env:     []string{"GAE_APPLICATION_YAML_PATH=app.yaml"},
+			path:    "app.yaml",
+			content: []byte("entrypoint: gunicorn -b :$PORT main:app"),
+			want:    "gunicorn -b :$PORT main:app",
+		},
+		{
+			name:    "missing entrypoint",
+			env:     []string{"GAE_APPLICATION_YAML_PATH=app.yaml"},
+			path:    "app.yaml",
+			content: []byte("runtime: python312"),
+		},
+		{
+			name:    "empty entrypoint",
+			env:     []string{"GAE_APPLICATION_YAML_PATH=app.yaml"},
+			path:    "app.yaml",
+			content: []byte("entrypoint: ''"),
+			wantErr: true,
+		},
+		{
+			name:    "null entrypoint",
+			env:     []string{"GAE_APPLICATION_YAML_PATH=app.yaml"},
+			path:    "app.yaml",
+			content: []byte("entrypoint:"),
+			wantErr: true,
+		},
+		{
+			name:    "entrypoint not a string",
+			env:     []string{"GAE_APPLICATION_YAML_PATH=app.yaml"},
+			path:    "app.yaml",
+			content: []byte("entrypoint:\n  - gunicorn"),
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tempRoot := t.TempDir()
+			writeFile(tc.path, tempRoot, tc.content, tc.env, t)
+
+			got, err := DeclaredEntrypoint(tempRoot)
+
+			if err != nil != tc.wantErr {
+				t.Fatalf("got err=%t, want err=%t: %v", err != nil, tc.wantErr, err)
+			}
+			if got != tc.want {
+				t.Errorf("DeclaredEntrypoint returns %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
 func TestPhpConfiguration(t *testing.T) {
 	testCases := []struct {
 		name    string
@@ -132,6 +198,95 @@ runtime_config:
 	}
 }
 
+func TestJavaConfiguration(t *testing.T) {
+	testCases := []struct {
+		name        string
+		env         []string
+		path        string
+		content     []byte
+		want        JavaConfig
+		wantErr     bool
+		wantErrPath string
+	}{
+		{
+			name: "no env var",
+			want: JavaConfig{},
+		},
+		{
+			name: "entrypoint and env_variables",
+			env:  []string{"GAE_APPLICATION_YAML_PATH=app.yaml"},
+			path: "app.yaml",
+			content: []byte(`
+entrypoint: java -Xmx512m -jar app.jar
+env_variables:
+  FOO: bar
+  PORT_OFFSET: 1
+  DEBUG: true
+`),
+			want: JavaConfig{
+				Entrypoint:   "java -Xmx512m -jar app.jar",
+				EnvVariables: map[string]string{"FOO": "bar", "PORT_OFFSET": "1", "DEBUG": "true"},
+			},
+		},
+		{
+			name:    "missing entrypoint",
+			env:     []string{"GAE_APPLICATION_YAML_PATH=app.yaml"},
+			path:    "app.yaml",
+			content: []byte("runtime: java"),
+			want:    JavaConfig{},
+		},
+		{
+			name:    "invalid yaml",
+			env:     []string{"GAE_APPLICATION_YAML_PATH=app.yaml"},
+			path:    "app.yaml",
+			content: []byte("entrypoint: [unterminated"),
+			wantErr: true,
+		},
+		{
+			name:        "entrypoint not a string",
+			env:         []string{"GAE_APPLICATION_YAML_PATH=app.yaml"},
+			path:        "app.yaml",
+			content:     []byte("entrypoint:\n  cmd: java"),
+			wantErr:     true,
+			wantErrPath: "entrypoint",
+		},
+		{
+			name:        "env_variables not a mapping",
+			env:         []string{"GAE_APPLICATION_YAML_PATH=app.yaml"},
+			path:        "app.yaml",
+			content:     []byte("env_variables:\n  - FOO=bar"),
+			wantErr:     true,
+			wantErrPath: "env_variables",
+		},
+		{
+			name:        "env_variables value not a scalar",
+			env:         []string{"GAE_APPLICATION_YAML_PATH=app.yaml"},
+			path:        "app.yaml",
+			content:     []byte("env_variables:\n  FOO:\n    - bar"),
+			wantErr:     true,
+			wantErrPath: "env_variables.FOO",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tempRoot := t.TempDir()
+			writeFile(tc.path, tempRoot, tc.content, tc.env, t)
+
+			got, err := JavaConfiguration(tempRoot)
+
+			if err != nil != tc.wantErr {
+				t.Fatalf("got err=%t, want err=%t: %v", err != nil, tc.wantErr, err)
+			}
+			if err != nil && !strings.Contains(err.Error(), tc.wantErrPath) {
+				t.Errorf("JavaConfiguration returned error %q, want it to contain %q", err, tc.wantErrPath)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("JavaConfiguration returned unexpected config (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
 func writeFile(path, root string, content []byte, envs []string, t *testing.T) {
 	if path != "" {
 		fp := filepath.Join(root, path)
@@ -18,21 +18,76 @@ package cache
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"io"
+	"io/fs"
 	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/buildpacks/libcnb"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 )
 
-// Option is a function that returns strings to be hashed when computing a cache key.
-type Option func() ([]string, error)
+const (
+	// inputsKeySuffix is appended to a cache key to store the manifest of its inputs in layer metadata.
+	inputsKeySuffix = "-inputs"
+	// maxStringInputLen is the longest string input stored verbatim in the manifest; longer ones are hashed.
+	maxStringInputLen = 128
+	// maxExplainedChanges is the number of changed inputs logged on a cache miss unless GOOGLE_CACHE_DIFF is set.
+	maxExplainedChanges = 5
+)
+
+// pendingInputs holds the manifests computed by HashAndCheck until Add stores them with the hash.
+// Callers commonly clear the layer, and with it its metadata, between the two calls.
+var pendingInputs = map[string]pendingManifest{}
+
+type pendingManifest struct {
+	hash     string
+	manifest string
+}
+
+// input is a single value hashed when computing a cache key.
+type input struct {
+	// name identifies the input when explaining a cache miss, e.g. a file path.
+	name string
+	// value is the hashed value.
+	value string
+	// file is true if value holds the contents of the file at name.
+	file bool
+}
+
+// manifestEntry records an input in layer metadata. Digest is the value of a string input, or the
+// sha256 hash of a file or long string input.
+type manifestEntry struct {
+	Name   string `json:"name"`
+	File   bool   `json:"file,omitempty"`
+	Digest string `json:"digest"`
+}
+
+// Option is a function that returns the inputs to be hashed when computing a cache key.
+type Option func() ([]input, error)
 
 // WithStrings returns a cache option for string values.
 func WithStrings(strings ...string) Option {
-	return func() ([]string, error) {
-		return strings, nil
+	return func() ([]input, error) {
+		var inputs []input
+		for _, s := range strings {
+			inputs = append(inputs, input{value: s})
+		}
+		return inputs, nil
+	}
+}
+
+// WithNamedString returns a cache option for a string value that is referred to by name when
+// explaining a cache miss, e.g. "NODE_ENV". It hashes the same as WithStrings(value).
+func WithNamedString(name, value string) Option {
+	return func() ([]input, error) {
+		return []input{{name: name, value: value}}, nil
 	}
 }
 
@@ -40,53 +95,146 @@ func WithStrings(strings ...string) Option {
 // detect if a file did not exist by checking returned error values against
 // os.IsNotFound(...).
 func WithFiles(files ...string) Option {
-	return func() ([]string, error) {
-		var strings []string
+	return func() ([]input, error) {
+		var inputs []input
 		for _, f := range files {
 			b, err := ioutil.ReadFile(f)
 			if err != nil {
 				return nil, err
 			}
-			strings = append(strings, string(b))
+			inputs = append(inputs, input{name: f, value: string(b), file: true})
 		}
-		return strings, nil
+		return inputs, nil
+	}
+}
+
+// WithDirectory returns a cache option that hashes the paths, modes and contents of the files in
+// dir and its subdirectories, except those in the skipped subdirectories, e.g. build outputs. The
+// contents of symlinks are not followed, their targets are hashed instead. The files are not read
+// into memory, so that it can be used for whole source trees.
+func WithDirectory(dir string, skip ...string) Option {
+	return func() ([]input, error) {
+		skipped := map[string]bool{}
+		for _, s := range skip {
+			skipped[filepath.Clean(s)] = true
+		}
+		h := sha256.New()
+		// WalkDir visits the files in lexical order, so the hash is deterministic.
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			if skipped[rel] {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if d.IsDir() {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(h, "%s\x00%v\x00", rel, info.Mode())
+			if d.Type()&fs.ModeSymlink != 0 {
+				target, err := os.Readlink(path)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(h, "%s\x00", target)
+				return nil
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			if _, err := io.Copy(h, f); err != nil {
+				return err
+			}
+			h.Write([]byte{0})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return []input{{name: dir, value: "sha256:" + hex.EncodeToString(h.Sum(nil))}}, nil
 	}
 }
 
 // hash creates a sha256 hash from the given cache options.
 func hash(ctx *gcp.Context, opts ...Option) (string, error) {
+	hash, _, err := hashInputs(ctx, opts...)
+	return hash, err
+}
+
+// hashInputs creates a sha256 hash from the given cache options and returns it along with the
+// manifest of the hashed inputs.
+func hashInputs(ctx *gcp.Context, opts ...Option) (string, []manifestEntry, error) {
 	h := sha256.New()
 
 	h.Write([]byte(ctx.BuildpackID()))
 	h.Write([]byte(ctx.BuildpackVersion()))
+	manifest := []manifestEntry{{Name: "buildpack", Digest: ctx.BuildpackID() + "@" + ctx.BuildpackVersion()}}
 
+	unnamed := 0
 	for _, opt := range opts {
-		strings, err := opt()
+		inputs, err := opt()
 		if err != nil {
-			return "", err
+			return "", nil, err
 		}
-		for _, s := range strings {
-			h.Write([]byte(s))
+		for _, in := range inputs {
+			h.Write([]byte(in.value))
+
+			e := manifestEntry{Name: in.name, File: in.file, Digest: in.value}
+			if e.Name == "" {
+				unnamed++
+				e.Name = fmt.Sprintf("value %d", unnamed)
+			}
+			if in.file || len(in.value) > maxStringInputLen {
+				sum := sha256.Sum256([]byte(in.value))
+				e.Digest = "sha256:" + hex.EncodeToString(sum[:])
+			}
+			manifest = append(manifest, e)
 		}
 	}
 
 	hash := hex.EncodeToString(h.Sum(nil))
-	return hash, nil
+	return hash, manifest, nil
 }
 
-// Add adds the key-value to the cache for the given layer for future builds.
+// Add adds the key-value to the cache for the given layer for future builds. If value is the hash
+// computed by HashAndCheck for the same layer and key, the manifest of its inputs is stored too.
 func Add(ctx *gcp.Context, l *libcnb.Layer, key string, value string) {
 	ctx.SetMetadata(l, key, value)
+	if p, ok := pendingInputs[pendingKey(l, key)]; ok && p.hash == value {
+		ctx.SetMetadata(l, key+inputsKeySuffix, p.manifest)
+	}
 }
 
 // HashAndCheck computes a hash value according to the cache options provided and checks if there is
 // a cache hit or miss by looking at the provided layer; returns the computed hash and if there
-// was a cache.
+// was a cache. On a cache miss, the inputs that changed since the hash was added to the layer are
+// logged.
 func HashAndCheck(ctx *gcp.Context, l *libcnb.Layer, key string, opts ...Option) (string, bool, error) {
-	currHash, err := hash(ctx, opts...)
+	currHash, manifest, err := hashInputs(ctx, opts...)
 	if err != nil {
 		return "", false, fmt.Errorf("computing dependency hash: %w", err)
 	}
+	encoded, err := json.Marshal(manifest)
+	if err != nil {
+		return "", false, gcp.InternalErrorf("encoding cache inputs: %v", err)
+	}
+	pendingInputs[pendingKey(l, key)] = pendingManifest{hash: currHash, manifest: string(encoded)}
 
 	prevHash := ctx.GetMetadata(l, key)
 	ctx.Debugf("Current dependency hash: %q", currHash)
@@ -101,6 +249,122 @@ func HashAndCheck(ctx *gcp.Context, l *libcnb.Layer, key string, opts ...Option)
 		ctx.CacheHit(l.Name)
 	} else {
 		ctx.CacheMiss(l.Name)
+		if prevHash != "" {
+			explainMiss(ctx, ctx.GetMetadata(l, key+inputsKeySuffix), manifest)
+		}
 	}
 	return currHash, cached, nil
 }
+
+func pendingKey(l *libcnb.Layer, key string) string {
+	return l.Name + "/" + key
+}
+
+// explainMiss logs the inputs that differ between the previously stored manifest and the current
+// one. Only the first few changes are logged unless GOOGLE_CACHE_DIFF is set, in which case every
+// input is logged with its previous and current digest.
+func explainMiss(ctx *gcp.Context, prevEncoded string, curr []manifestEntry) {
+	if prevEncoded == "" {
+		ctx.Logf("Cache inputs from the previous build were not recorded, unable to explain the cache miss.")
+		return
+	}
+	var prev []manifestEntry
+	if err := json.Unmarshal([]byte(prevEncoded), &prev); err != nil {
+		ctx.Warnf("Ignoring invalid cache inputs from the previous build: %v", err)
+		return
+	}
+	full, err := env.IsPresentAndTrue(env.CacheDiff)
+	if err != nil {
+		ctx.Warnf("Ignoring %s: %v", env.CacheDiff, err)
+	}
+	if full {
+		ctx.Logf("Cache inputs (= unchanged, ~ changed, - removed, + added):")
+		for _, line := range diffManifests(prev, curr) {
+			ctx.Logf("  %s", line)
+		}
+		return
+	}
+	changes := explainChanges(prev, curr)
+	if len(changes) == 0 {
+		ctx.Logf("Cache inputs are unchanged, the cache key was computed differently by the previous build.")
+		return
+	}
+	for i, c := range changes {
+		if i == maxExplainedChanges {
+			ctx.Logf("  ... and %d more, set %s=true to log all cache inputs.", len(changes)-i, env.CacheDiff)
+			break
+		}
+		ctx.Logf("  %s", c)
+	}
+}
+
+// explainChanges returns a human readable description of each input that was changed, removed or
+// added between prev and curr.
+func explainChanges(prev, curr []manifestEntry) []string {
+	prevByName := manifestByName(prev)
+	currByName := manifestByName(curr)
+	var changes []string
+	for _, c := range curr {
+		p, ok := prevByName[c.Name]
+		switch {
+		case !ok && c.File:
+			changes = append(changes, fmt.Sprintf("%s was added", c.Name))
+		case !ok:
+			changes = append(changes, fmt.Sprintf("%s was added with value %s", c.Name, quote(c.Digest)))
+		case p.Digest == c.Digest:
+		case c.File:
+			changes = append(changes, fmt.Sprintf("%s hash changed", c.Name))
+		default:
+			changes = append(changes, fmt.Sprintf("%s changed from %s to %s", c.Name, quote(p.Digest), quote(c.Digest)))
+		}
+	}
+	for _, p := range prev {
+		if _, ok := currByName[p.Name]; ok {
+			continue
+		}
+		if _, err := os.Stat(p.Name); p.File && os.IsNotExist(err) {
+			changes = append(changes, fmt.Sprintf("file %s no longer exists", p.Name))
+		} else {
+			changes = append(changes, fmt.Sprintf("%s was removed", p.Name))
+		}
+	}
+	return changes
+}
+
+// diffManifests returns a line for every input in prev or curr, marked with whether it was
+// unchanged, changed, removed or added.
+func diffManifests(prev, curr []manifestEntry) []string {
+	prevByName := manifestByName(prev)
+	currByName := manifestByName(curr)
+	var lines []string
+	for _, c := range curr {
+		p, ok := prevByName[c.Name]
+		switch {
+		case !ok:
+			lines = append(lines, fmt.Sprintf("+ %s: %s", c.Name, quote(c.Digest)))
+		case p.Digest == c.Digest:
+			lines = append(lines, fmt.Sprintf("= %s: %s", c.Name, quote(c.Digest)))
+		default:
+			lines = append(lines, fmt.Sprintf("~ %s: %s -> %s", c.Name, quote(p.Digest), quote(c.Digest)))
+		}
+	}
+	for _, p := range prev {
+		if _, ok := currByName[p.Name]; !ok {
+			lines = append(lines, fmt.Sprintf("- %s: %s", p.Name, quote(p.Digest)))
+		}
+	}
+	return lines
+}
+
+func manifestByName(manifest []manifestEntry) map[string]manifestEntry {
+	m := make(map[string]manifestEntry, len(manifest))
+	for _, e := range manifest {
+		m[e.Name] = e
+	}
+	return m
+}
+
+// quote quotes a digest for logging, trimming the trailing newline of command output.
+func quote(digest string) string {
+	return fmt.Sprintf("%q", strings.TrimSpace(digest))
+}
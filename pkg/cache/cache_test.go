@@ -15,10 +15,14 @@
 package cache
 
 import (
+	"bytes"
 	"io/ioutil"
+	"log"
 	"os"
 	"path/filepath"
+	"reflect"
 	"sort"
+	"strings"
 	"testing"
 
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
@@ -291,6 +295,262 @@ func TestHash_Uniqueness(t *testing.T) {
 	}
 }
 
+func TestWithDirectory(t *testing.T) {
+	base := map[string]string{
+		"Program.cs":     "class Program {}",
+		"app.csproj":     "<Project />",
+		"lib/Lib.cs":     "class Lib {}",
+		"obj/out.dll":    "output",
+		"bin/app.dll":    "output",
+		"wwwroot/a.html": "<html />",
+	}
+	testCases := []struct {
+		name     string
+		change   func(t *testing.T, dir string)
+		wantSame bool
+	}{
+		{
+			name:     "unchanged",
+			change:   func(t *testing.T, dir string) {},
+			wantSame: true,
+		},
+		{
+			name:     "change in skipped directory",
+			change:   func(t *testing.T, dir string) { writeFile(t, dir, "obj/out.dll", "new output") },
+			wantSame: true,
+		},
+		{
+			name:   "changed content",
+			change: func(t *testing.T, dir string) { writeFile(t, dir, "lib/Lib.cs", "class Lib { int x; }") },
+		},
+		{
+			name:   "added file",
+			change: func(t *testing.T, dir string) { writeFile(t, dir, "lib/Other.cs", "") },
+		},
+		{
+			name: "renamed file",
+			change: func(t *testing.T, dir string) {
+				if err := os.Rename(filepath.Join(dir, "lib/Lib.cs"), filepath.Join(dir, "lib/Lib2.cs")); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name: "removed file",
+			change: func(t *testing.T, dir string) {
+				if err := os.Remove(filepath.Join(dir, "wwwroot/a.html")); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name: "changed mode",
+			change: func(t *testing.T, dir string) {
+				if err := os.Chmod(filepath.Join(dir, "Program.cs"), 0755); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name: "added symlink",
+			change: func(t *testing.T, dir string) {
+				if err := os.Symlink("lib/Lib.cs", filepath.Join(dir, "link.cs")); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+	}
+	ctx := gcp.NewContext(gcp.WithBuildpackInfo(libcnb.BuildpackInfo{ID: "id", Version: "version"}))
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, contents := range base {
+				if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755); err != nil {
+					t.Fatal(err)
+				}
+				writeFile(t, dir, name, contents)
+			}
+			before := computeHash(t, ctx, WithDirectory(dir, "bin", "obj"))
+			tc.change(t, dir)
+			after := computeHash(t, ctx, WithDirectory(dir, "bin", "obj"))
+
+			if gotSame := before == after; gotSame != tc.wantSame {
+				t.Errorf("WithDirectory() hash unchanged = %v, want %v", gotSame, tc.wantSame)
+			}
+		})
+	}
+}
+
+func TestWithDirectoryError(t *testing.T) {
+	if _, err := hash(gcp.NewContext(), WithDirectory("/does/not/exist")); err == nil {
+		t.Fatalf("Hash(WithDirectory()) got err=nil, want err")
+	}
+}
+
+func TestWithNamedStringHashesLikeWithStrings(t *testing.T) {
+	ctx := gcp.NewContext(gcp.WithBuildpackInfo(libcnb.BuildpackInfo{ID: "id", Version: "version"}))
+	named := computeHash(t, ctx, WithNamedString("NODE_ENV", "production"), WithStrings("v20.1.0"))
+	unnamed := computeHash(t, ctx, WithStrings("production", "v20.1.0"))
+	if named != unnamed {
+		t.Errorf("Hash(WithNamedString()) = %q, want %q", named, unnamed)
+	}
+}
+
+func TestExplainChanges(t *testing.T) {
+	temp := t.TempDir()
+	existing := writeFile(t, temp, "package.json", "{}")
+	missing := filepath.Join(temp, "yarn.lock")
+
+	testCases := []struct {
+		name string
+		prev []manifestEntry
+		curr []manifestEntry
+		want []string
+	}{
+		{
+			name: "unchanged",
+			prev: []manifestEntry{{Name: "NODE_ENV", Digest: "production"}},
+			curr: []manifestEntry{{Name: "NODE_ENV", Digest: "production"}},
+		},
+		{
+			name: "changed string",
+			prev: []manifestEntry{{Name: "NODE_ENV", Digest: "production"}},
+			curr: []manifestEntry{{Name: "NODE_ENV", Digest: "development"}},
+			want: []string{`NODE_ENV changed from "production" to "development"`},
+		},
+		{
+			name: "changed file",
+			prev: []manifestEntry{{Name: existing, File: true, Digest: "sha256:aaa"}},
+			curr: []manifestEntry{{Name: existing, File: true, Digest: "sha256:bbb"}},
+			want: []string{existing + " hash changed"},
+		},
+		{
+			name: "added inputs",
+			prev: []manifestEntry{},
+			curr: []manifestEntry{
+				{Name: existing, File: true, Digest: "sha256:aaa"},
+				{Name: "ruby version", Digest: "ruby 3.3.0\n"},
+			},
+			want: []string{
+				existing + " was added",
+				`ruby version was added with value "ruby 3.3.0"`,
+			},
+		},
+		{
+			name: "removed inputs",
+			prev: []manifestEntry{
+				{Name: missing, File: true, Digest: "sha256:aaa"},
+				{Name: existing, File: true, Digest: "sha256:bbb"},
+				{Name: "value 1", Digest: "8.0.100"},
+			},
+			curr: []manifestEntry{},
+			want: []string{
+				"file " + missing + " no longer exists",
+				existing + " was removed",
+				"value 1 was removed",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := explainChanges(tc.prev, tc.curr)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("explainChanges() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestHashAndCheckExplainsMiss(t *testing.T) {
+	temp := t.TempDir()
+	pjs := writeFile(t, temp, "package.json", `{"name": "app"}`)
+	opts := []Option{WithNamedString("NODE_ENV", "production"), WithFiles(pjs)}
+
+	testCases := []struct {
+		name       string
+		cacheDiff  bool
+		change     func(t *testing.T) []Option
+		wantOutput []string
+	}{
+		{
+			name: "string changed",
+			change: func(t *testing.T) []Option {
+				return []Option{WithNamedString("NODE_ENV", "development"), WithFiles(pjs)}
+			},
+			wantOutput: []string{`NODE_ENV changed from "production" to "development"`},
+		},
+		{
+			name: "file changed",
+			change: func(t *testing.T) []Option {
+				writeFile(t, temp, "package.json", `{"name": "other"}`)
+				return opts
+			},
+			wantOutput: []string{pjs + " hash changed"},
+		},
+		{
+			name:      "full diff",
+			cacheDiff: true,
+			change: func(t *testing.T) []Option {
+				return []Option{WithNamedString("NODE_ENV", "development"), WithFiles(pjs)}
+			},
+			wantOutput: []string{
+				`= buildpack: "id@version"`,
+				`~ NODE_ENV: "production" -> "development"`,
+				"= " + pjs + `: "sha256:`,
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			writeFile(t, temp, "package.json", `{"name": "app"}`)
+			if tc.cacheDiff {
+				t.Setenv("GOOGLE_CACHE_DIFF", "true")
+			}
+			var buf bytes.Buffer
+			ctx := gcp.NewContext(gcp.WithBuildpackInfo(libcnb.BuildpackInfo{ID: "id", Version: "version"}), gcp.WithLogger(log.New(&buf, "", 0)))
+			l := &libcnb.Layer{Name: "deps", Metadata: map[string]any{}}
+
+			// The previous build stores the hash along with its inputs.
+			hash, _, err := HashAndCheck(ctx, l, "testKey", opts...)
+			if err != nil {
+				t.Fatalf("HashAndCheck() got err=%v, want err=nil", err)
+			}
+			l.Metadata = map[string]any{}
+			Add(ctx, l, "testKey", hash)
+			if ctx.GetMetadata(l, "testKey"+inputsKeySuffix) == "" {
+				t.Fatalf("Add() did not store the cache inputs, metadata: %v", l.Metadata)
+			}
+
+			buf.Reset()
+			_, cached, err := HashAndCheck(ctx, l, "testKey", tc.change(t)...)
+			if err != nil {
+				t.Fatalf("HashAndCheck() got err=%v, want err=nil", err)
+			}
+			if cached {
+				t.Fatalf("HashAndCheck() cache result = true, want false")
+			}
+			for _, want := range tc.wantOutput {
+				if !strings.Contains(buf.String(), want) {
+					t.Errorf("HashAndCheck() output does not contain %q, output: %s", want, buf.String())
+				}
+			}
+		})
+	}
+}
+
+func TestHashAndCheckMissWithoutRecordedInputs(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := gcp.NewContext(gcp.WithBuildpackInfo(libcnb.BuildpackInfo{ID: "id", Version: "version"}), gcp.WithLogger(log.New(&buf, "", 0)))
+	l := &libcnb.Layer{Name: "deps", Metadata: map[string]any{"testKey": "old-value"}}
+
+	if _, _, err := HashAndCheck(ctx, l, "testKey", WithStrings("my-string")); err != nil {
+		t.Fatalf("HashAndCheck() got err=%v, want err=nil", err)
+	}
+	if want := "were not recorded"; !strings.Contains(buf.String(), want) {
+		t.Errorf("HashAndCheck() output does not contain %q, output: %s", want, buf.String())
+	}
+}
+
 func writeFile(t *testing.T, tempDir, name, contents string) string {
 	t.Helper()
 	fullName := filepath.Join(tempDir, name)
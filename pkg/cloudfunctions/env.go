@@ -14,7 +14,14 @@
 
 package cloudfunctions
 
-import "github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
 
 // SkipFrameworkInjection is used to allow opting out of Functions Framework auto-injection
 // when it hasn't been explicitly declared as a dependency.
@@ -24,3 +31,43 @@ const SkipFrameworkInjection = "GOOGLE_SKIP_FRAMEWORK_INJECTION"
 func IsSkipFrameworkInjectionEnabled() (bool, error) {
 	return env.IsPresentAndTrue(SkipFrameworkInjection)
 }
+
+// SourceSubdir returns the function source subdirectory specified by GOOGLE_FUNCTION_SOURCE_SUBDIR,
+// relative to the application root, or an empty string if it is not set.
+func SourceSubdir(ctx *gcp.Context) (string, error) {
+	subdir := os.Getenv(env.FunctionSourceSubdir)
+	if subdir == "" {
+		return "", nil
+	}
+	clean := filepath.Clean(subdir)
+	if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", gcp.UserErrorf("%s=%q must be a relative path inside the application source", env.FunctionSourceSubdir, subdir)
+	}
+	if clean == "." {
+		return "", nil
+	}
+	fi, err := os.Stat(filepath.Join(ctx.ApplicationRoot(), clean))
+	if os.IsNotExist(err) {
+		return "", gcp.UserErrorf("%s=%q does not exist", env.FunctionSourceSubdir, subdir)
+	}
+	if err != nil {
+		return "", gcp.InternalErrorf("checking %s: %v", env.FunctionSourceSubdir, err)
+	}
+	if !fi.IsDir() {
+		return "", gcp.UserErrorf("%s=%q is not a directory", env.FunctionSourceSubdir, subdir)
+	}
+	return clean, nil
+}
+
+// FrameworkArgs returns the extra arguments of the functions framework launch command specified by
+// GOOGLE_FUNCTIONS_FRAMEWORK_ARGS, or nil if it is not set. Arguments are separated by whitespace.
+// Single and double quotes group words into one argument and a backslash outside of single quotes
+// escapes the next character, as in a POSIX shell. No other shell expansion is performed.
+func FrameworkArgs() ([]string, error) {
+	raw := os.Getenv(env.FunctionsFrameworkArgs)
+	args, err := env.SplitArgs(raw)
+	if err != nil {
+		return nil, gcp.UserErrorf("invalid %s %q: %v", env.FunctionsFrameworkArgs, raw, err)
+	}
+	return args, nil
+}
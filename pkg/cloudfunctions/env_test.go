@@ -0,0 +1,166 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudfunctions
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSourceSubdir(t *testing.T) {
+	testCases := []struct {
+		name    string
+		subdir  string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "not set",
+			want: "",
+		},
+		{
+			name:   "current directory",
+			subdir: ".",
+			want:   "",
+		},
+		{
+			name:   "subdirectory",
+			subdir: "functions/hello",
+			want:   filepath.Join("functions", "hello"),
+		},
+		{
+			name:   "subdirectory with trailing slash",
+			subdir: "./functions/hello/",
+			want:   filepath.Join("functions", "hello"),
+		},
+		{
+			name:    "missing subdirectory",
+			subdir:  "functions/missing",
+			wantErr: true,
+		},
+		{
+			name:    "file",
+			subdir:  "functions/hello/main.py",
+			wantErr: true,
+		},
+		{
+			name:    "outside application root",
+			subdir:  "../functions",
+			wantErr: true,
+		},
+		{
+			name:    "absolute path",
+			subdir:  "/functions",
+			wantErr: true,
+		},
+	}
+
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "functions", "hello"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "functions", "hello", "main.py"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	ctx := gcp.NewContext(gcp.WithApplicationRoot(root))
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			setEnv(t, "GOOGLE_FUNCTION_SOURCE_SUBDIR", tc.subdir)
+
+			got, err := SourceSubdir(ctx)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("SourceSubdir() got error: %v, want error: %v", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("SourceSubdir() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFrameworkArgs(t *testing.T) {
+	testCases := []struct {
+		name    string
+		args    string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "not set",
+		},
+		{
+			name: "whitespace only",
+			args: " \t ",
+		},
+		{
+			name: "flags",
+			args: "--source=src  --log-execution-id",
+			want: []string{"--source=src", "--log-execution-id"},
+		},
+		{
+			name: "double quotes",
+			args: `--source "my functions/src" --debug`,
+			want: []string{"--source", "my functions/src", "--debug"},
+		},
+		{
+			name: "single quotes",
+			args: `--message='say "hi" \o/'`,
+			want: []string{`--message=say "hi" \o/`},
+		},
+		{
+			name: "backslash escapes",
+			args: `my\ dir \"quoted\" "a \" b"`,
+			want: []string{"my dir", `"quoted"`, `a " b`},
+		},
+		{
+			name: "empty quoted argument",
+			args: `--prefix ""`,
+			want: []string{"--prefix", ""},
+		},
+		{
+			name:    "unterminated double quote",
+			args:    `--source "src`,
+			wantErr: true,
+		},
+		{
+			name:    "unterminated single quote",
+			args:    `--source 'src`,
+			wantErr: true,
+		},
+		{
+			name:    "trailing backslash",
+			args:    `--source src\`,
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			setEnv(t, "GOOGLE_FUNCTIONS_FRAMEWORK_ARGS", tc.args)
+
+			got, err := FrameworkArgs()
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("FrameworkArgs() got error: %v, want error: %v", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("FrameworkArgs() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -26,7 +26,9 @@ func (fvi *FrameworkVersionInfo) String() string {
 	return string(b)
 }
 
-// AddFrameworkVersionLabel sets the google.functions-framework-version label on the image.
+// AddFrameworkVersionLabel sets the google.functions-framework-version label on the image and adds
+// the framework to the build report.
 func AddFrameworkVersionLabel(ctx *gcp.Context, version *FrameworkVersionInfo) {
 	ctx.AddLabel(FrameworkVersionLabel, version.String())
+	ctx.ReportFramework("functions-framework", version.Version, version.Injected)
 }
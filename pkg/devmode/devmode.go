@@ -85,6 +85,17 @@ func AddFileWatcherProcess(ctx *gcp.Context, cfg Config) error {
 	return nil
 }
 
+// AddSyncMetadata adds the rules of the files that can be synced to the application container
+// without rebuilding the image to the bill of materials of the image, where tools such as
+// `skaffold dev` read them.
+func AddSyncMetadata(ctx *gcp.Context, syncRulesFn func(string) []SyncRule) {
+	ctx.AddBOMEntry(libcnb.BOMEntry{
+		Name:     "devmode",
+		Metadata: map[string]interface{}{"devmode.sync": syncRulesFn(ctx.ApplicationRoot())},
+		Launch:   true,
+	})
+}
+
 // writeBuildAndRunScript writes the contents of a file that builds code and then runs the resulting program
 func writeBuildAndRunScript(ctx *gcp.Context, sl *libcnb.Layer, cfg Config) error {
 	sl.Launch = true
@@ -93,12 +104,14 @@ func writeBuildAndRunScript(ctx *gcp.Context, sl *libcnb.Layer, cfg Config) erro
 		return err
 	}
 
+	// Quote the arguments with spaces so that the script runs the same command as the web process
+	// would, e.g. `/bin/bash -c "php artisan serve"`.
 	var cmd []string
 	if cfg.BuildCmd != nil {
-		cmd = append(cmd, strings.Join(cfg.BuildCmd, " "))
+		cmd = append(cmd, shellJoin(cfg.BuildCmd))
 	}
 	if cfg.RunCmd != nil {
-		cmd = append(cmd, strings.Join(cfg.RunCmd, " "))
+		cmd = append(cmd, shellJoin(cfg.RunCmd))
 	}
 
 	c := fmt.Sprintf("#!/bin/sh\n%s", strings.Join(cmd, " && "))
@@ -23,6 +23,7 @@ import (
 
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/buildpacks/libcnb"
+	"github.com/google/go-cmp/cmp"
 )
 
 func TestWriteAndRunScripts(t *testing.T) {
@@ -60,6 +61,16 @@ func TestWriteAndRunScripts(t *testing.T) {
 			wantBuildAndRun: "#!/bin/sh\nbuild-me.sh && run-me.sh",
 			wantWatchAndRun: fmt.Sprintf("#!/bin/sh\nwatchexec -r -e .cc %s", filepath.Join(testDirRoot, "withBuildAndRun", "bin", "build_and_run.sh")),
 		},
+		{
+			name: "argumentsWithSpaces",
+			config: Config{
+				RunCmd: []string{"/bin/bash", "-c", "php artisan serve --port=$PORT"},
+				Ext:    []string{"php", "twig"},
+			},
+			layerRoot:       filepath.Join(testDirRoot, "argumentsWithSpaces"),
+			wantBuildAndRun: "#!/bin/sh\n/bin/bash -c 'php artisan serve --port=$PORT'",
+			wantWatchAndRun: fmt.Sprintf("#!/bin/sh\nwatchexec -r -e php,twig %s", filepath.Join(testDirRoot, "argumentsWithSpaces", "bin", "build_and_run.sh")),
+		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -92,3 +103,69 @@ func TestWriteAndRunScripts(t *testing.T) {
 		})
 	}
 }
+
+func TestShellJoin(t *testing.T) {
+	testCases := []struct {
+		name    string
+		command []string
+		want    string
+	}{
+		{
+			name:    "no spaces",
+			command: []string{"mvn", "clean", "package", "-Denv=production"},
+			want:    "mvn clean package -Denv=production",
+		},
+		{
+			name:    "spaces",
+			command: []string{"mvn", "package", "-Dgreeting=hello world"},
+			want:    "mvn package '-Dgreeting=hello world'",
+		},
+		{
+			name:    "spaces and single quote",
+			command: []string{"mvn", "-Dgreeting=it's me"},
+			want:    `mvn '-Dgreeting=it'\''s me'`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := shellJoin(tc.command); got != tc.want {
+				t.Errorf("shellJoin(%q) = %q, want %q", tc.command, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestAddSyncMetadata(t *testing.T) {
+	testCases := []struct {
+		name        string
+		syncRulesFn func(string) []SyncRule
+		want        []SyncRule
+	}{
+		{
+			name:        "python",
+			syncRulesFn: PythonSyncRules,
+			want:        []SyncRule{{Src: "**/*.py", Dest: "/workspace"}},
+		},
+		{
+			name:        "php",
+			syncRulesFn: PHPSyncRules,
+			want:        []SyncRule{{Src: "**/*.php", Dest: "/workspace"}, {Src: "**/*.twig", Dest: "/workspace"}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := gcp.NewContext(gcp.WithApplicationRoot("/workspace"))
+
+			AddSyncMetadata(ctx, tc.syncRulesFn)
+
+			want := []libcnb.BOMEntry{{
+				Name:     "devmode",
+				Metadata: map[string]interface{}{"devmode.sync": tc.want},
+				Launch:   true,
+			}}
+			if diff := cmp.Diff(want, ctx.BOMEntries()); diff != "" {
+				t.Errorf("AddSyncMetadata() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"path/filepath"
 	"strings"
 	"text/template"
+	"unicode"
 
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 )
@@ -51,7 +52,7 @@ func WriteBuildScript(ctx *gcp.Context, layerSrc, dest string, command []string)
 	buildScriptTmpl.Execute(&script, map[string]string{
 		"src":          layerSrc,
 		"dest":         dest,
-		"buildCommand": strings.Join(command, " "),
+		"buildCommand": shellJoin(command),
 	})
 
 	bin := filepath.Join(layerSrc, "bin")
@@ -64,3 +65,16 @@ func WriteBuildScript(ctx *gcp.Context, layerSrc, dest string, command []string)
 	}
 	return nil
 }
+
+// shellJoin joins the arguments of a command into a shell command line, quoting the arguments that
+// contain whitespace, e.g. -Dgreeting=hello world.
+func shellJoin(command []string) string {
+	args := make([]string, len(command))
+	for i, a := range command {
+		if strings.IndexFunc(a, unicode.IsSpace) >= 0 {
+			a = "'" + strings.ReplaceAll(a, "'", `'\''`) + "'"
+		}
+		args[i] = a
+	}
+	return strings.Join(args, " ")
+}
@@ -0,0 +1,30 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package devmode
+
+var (
+	// PHPWatchedExtensions is the list of file extensions to be watched for changes in Dev Mode for PHP.
+	// A change to any of those files restarts php-fpm, which clears OPcache and the compiled templates.
+	PHPWatchedExtensions = []string{"php", "twig"}
+)
+
+// PHPSyncRules lists the files that are synced to dest in Dev Mode for PHP, without rebuilding the
+// image. PHP code is interpreted, so the synced files only require a restart.
+func PHPSyncRules(dest string) []SyncRule {
+	return []SyncRule{
+		{Src: "**/*.php", Dest: dest},
+		{Src: "**/*.twig", Dest: dest},
+	}
+}
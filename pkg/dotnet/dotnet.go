@@ -26,10 +26,13 @@ import (
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/toolversions"
+	"github.com/Masterminds/semver"
 )
 
 const (
 	aspDotnetCore = "Microsoft.AspNetCore.App"
+	netCoreApp    = "Microsoft.NETCore.App"
 	envSdkVersion = "GOOGLE_DOTNET_SDK_VERSION"
 	googleMin22   = "google.min.22"
 	// EnvRuntimeVersion is the environment variable key for storing the target dotnet runtime version.
@@ -38,6 +41,22 @@ const (
 	PublishLayerName = "publish"
 	// PublishOutputDirName is passed as the output directory for `dotnet publish`.
 	PublishOutputDirName = "bin"
+	// AllowAspireAppHostEnv is the environment variable key that allows building a .NET Aspire
+	// AppHost project, which is otherwise rejected because it orchestrates other services.
+	AllowAspireAppHostEnv = "GOOGLE_DOTNET_ALLOW_ASPIRE_APPHOST"
+
+	// aspireAppHostSdk and aspireHostingPackage identify .NET Aspire AppHost projects.
+	aspireAppHostSdk     = "Aspire.AppHost.Sdk"
+	aspireHostingPackage = "Aspire.Hosting"
+
+	// Roll-forward policies supported by the .NET host, see
+	// https://learn.microsoft.com/en-us/dotnet/core/versions/selection#framework-dependent-apps-roll-forward
+	rollForwardDisable     = "Disable"
+	rollForwardLatestPatch = "LatestPatch"
+	rollForwardMinor       = "Minor"
+	rollForwardLatestMinor = "LatestMinor"
+	rollForwardMajor       = "Major"
+	rollForwardLatestMajor = "LatestMajor"
 )
 
 // ProjectFiles finds all project files supported by dotnet.
@@ -134,10 +153,12 @@ type configProperties struct {
 }
 
 type runtimeOptions struct {
-	TFM              string           `json:"tfm"`
-	Framework        framework        `json:"framework"`
-	Frameworks       []framework      `json:"frameworks"`
-	ConfigProperties configProperties `json:"configProperties"`
+	TFM                        string           `json:"tfm"`
+	Framework                  framework        `json:"framework"`
+	Frameworks                 []framework      `json:"frameworks"`
+	ConfigProperties           configProperties `json:"configProperties"`
+	RollForward                string           `json:"rollForward"`
+	RollForwardOnNoCandidateFx *int             `json:"rollForwardOnNoCandidateFx"`
 }
 
 // ReadRuntimeConfigJSON reads a given runtimeconfig.json file and returns a struct
@@ -165,7 +186,8 @@ type globalJSON struct {
 //  1. Return value of env variable GOOGLE_DOTNET_SDK_VERSION if present.
 //  2. Return value of env variable GOOGLE_RUNTIME_VERSION if present.
 //  3. Return SDK.Version from the .NET global.json file if present.
-//  4. Return an empty string by default, which will cause us to use the latest version available
+//  4. Return the dotnet or dotnet-core version from the asdf .tool-versions file if present.
+//  5. Return an empty string by default, which will cause us to use the latest version available
 //     on dl.google.com (see runtime.InstallTarballIfNotCached for details).
 func GetSDKVersion(ctx *gcp.Context) (string, error) {
 	if version := os.Getenv(envSdkVersion); version != "" {
@@ -185,6 +207,16 @@ func GetSDKVersion(ctx *gcp.Context) (string, error) {
 		ctx.Logf("Using .NET Core SDK version from global.json: %s", gjs.Sdk.Version)
 		return gjs.Sdk.Version, nil
 	}
+	for _, tool := range []string{toolversions.Dotnet, toolversions.DotnetCore} {
+		version, err := toolversions.Version(ctx, tool)
+		if err != nil {
+			return "", err
+		}
+		if version != "" {
+			ctx.Logf("Using .NET Core SDK version from %s: %s", toolversions.File, version)
+			return version, nil
+		}
+	}
 	ctx.Logf("Using latest stable .NET Core SDK version")
 	return "", nil
 }
@@ -205,29 +237,120 @@ func getGlobalJSONOrNil(applicationRoot string) (*globalJSON, error) {
 }
 
 // FindProjectFile finds the csproj file using the 'GOOGLE_BUILDABLE' env var and falling back with a search of the current directory.
+// .NET Aspire AppHost projects are skipped when searching a directory and rejected otherwise, unless
+// GOOGLE_DOTNET_ALLOW_ASPIRE_APPHOST is set, because they cannot run as a single container.
 func FindProjectFile(ctx *gcp.Context) (string, error) {
+	allowAppHost, err := env.IsPresentAndTrue(AllowAspireAppHostEnv)
+	if err != nil {
+		return "", err
+	}
 	proj := os.Getenv(env.Buildable)
 	if proj == "" {
 		proj = "."
 	}
+	var projFiles []string
 	// Find the project file if proj is a directory.
 	if fi, err := os.Stat(proj); os.IsNotExist(err) {
 		return "", gcp.UserErrorf("%s does not exist", proj)
 	} else if err != nil {
 		return "", fmt.Errorf("stating %s: %v", proj, err)
 	} else if fi.IsDir() {
-		projFiles, err := ProjectFiles(ctx, proj)
+		projFiles, err = ProjectFiles(ctx, proj)
 		if err != nil {
 			return "", err
 		}
-		if len(projFiles) != 1 {
-			return "", gcp.UserErrorf("expected to find exactly one project file in directory %s, found %v", proj, projFiles)
+		candidates := projFiles
+		if len(projFiles) > 1 && !allowAppHost {
+			var appHosts []string
+			candidates, appHosts, err = withoutAspireAppHosts(ctx, projFiles)
+			if err != nil {
+				return "", err
+			}
+			if len(appHosts) > 0 && len(candidates) == 1 {
+				ctx.Logf("Skipping .NET Aspire AppHost projects %v, building %s.", appHosts, candidates[0])
+			}
+		}
+		if len(candidates) == 0 {
+			candidates = projFiles
+		}
+		if len(candidates) != 1 {
+			return "", gcp.UserErrorf("expected to find exactly one project file in directory %s, found %v. Set %s to the project to build.", proj, candidates, env.Buildable)
 		}
-		proj = projFiles[0]
+		proj = candidates[0]
+	}
+	if allowAppHost {
+		return proj, nil
+	}
+	appHost, err := IsAspireAppHost(ctx, proj)
+	if err != nil {
+		return "", err
+	}
+	if appHost {
+		return "", gcp.UserErrorf(".NET Aspire AppHost project %s is not supported: AppHost projects orchestrate other services and cannot be deployed as a single container. Set %s to the service project to deploy (project files found: %v), or set %s=true to build the AppHost anyway.", proj, env.Buildable, projFiles, AllowAspireAppHostEnv)
 	}
 	return proj, nil
 }
 
+// withoutAspireAppHosts splits the given project files into .NET Aspire AppHost projects and the
+// other projects.
+func withoutAspireAppHosts(ctx *gcp.Context, projFiles []string) ([]string, []string, error) {
+	var others, appHosts []string
+	for _, p := range projFiles {
+		appHost, err := IsAspireAppHost(ctx, p)
+		if err != nil {
+			return nil, nil, err
+		}
+		if appHost {
+			appHosts = append(appHosts, p)
+		} else {
+			others = append(others, p)
+		}
+	}
+	return others, appHosts, nil
+}
+
+// aspireProject contains the parts of a project file that identify a .NET Aspire AppHost.
+type aspireProject struct {
+	Sdk  string `xml:"Sdk,attr"`
+	Sdks []struct {
+		Name string `xml:"Name,attr"`
+	} `xml:"Sdk"`
+	IsAspireHost      []string           `xml:"PropertyGroup>IsAspireHost"`
+	PackageReferences []PackageReference `xml:"ItemGroup>PackageReference"`
+}
+
+// IsAspireAppHost returns true if proj is a .NET Aspire AppHost project, identified by the Aspire
+// AppHost SDK, the IsAspireHost property or a reference to an Aspire.Hosting package.
+func IsAspireAppHost(ctx *gcp.Context, proj string) (bool, error) {
+	data, err := ctx.ReadFile(proj)
+	if err != nil {
+		return false, err
+	}
+	var p aspireProject
+	if err := xml.Unmarshal(data, &p); err != nil {
+		return false, gcp.UserErrorf("unmarshalling %s: %v", proj, err)
+	}
+	if strings.HasPrefix(p.Sdk, aspireAppHostSdk) {
+		return true, nil
+	}
+	for _, sdk := range p.Sdks {
+		if sdk.Name == aspireAppHostSdk {
+			return true, nil
+		}
+	}
+	for _, v := range p.IsAspireHost {
+		if strings.EqualFold(strings.TrimSpace(v), "true") {
+			return true, nil
+		}
+	}
+	for _, ref := range p.PackageReferences {
+		if ref.Include == aspireHostingPackage || strings.HasPrefix(ref.Include, aspireHostingPackage+".") {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 // GetRuntimeVersion returns the value in GOOGLE_ASP_NET_CORE_VERSION, and if not set, returns
 // Microsoft.AspNetCore.App version in the runtimeconfig.json file found in dir.
 func GetRuntimeVersion(ctx *gcp.Context, dir string) (string, error) {
@@ -246,43 +369,125 @@ func GetRuntimeVersion(ctx *gcp.Context, dir string) (string, error) {
 }
 
 func getRuntimeVersionFromRtCfgDir(ctx *gcp.Context, dir string) (string, string, error) {
+	rtCfg, rtCfgFile, err := readRuntimeConfigInDir(ctx, dir)
+	if err != nil {
+		return "", rtCfgFile, err
+	}
+	version := aspNetCoreVersion(rtCfg)
+	if version == "" {
+		return "", rtCfgFile, fmt.Errorf("couldn't find runtime version for framework %s from "+
+			"runtimeconfig.json: %#v", aspDotnetCore, rtCfg)
+	}
+
+	return version, rtCfgFile, nil
+}
+
+// readRuntimeConfigInDir reads the only runtimeconfig.json file in dir and returns it along with its path.
+func readRuntimeConfigInDir(ctx *gcp.Context, dir string) (*RuntimeConfigJSON, string, error) {
 	rtCfgFiles, err := RuntimeConfigJSONFiles(dir)
 	if err != nil {
-		return "", "", gcp.InternalErrorf("finding runtimeconfig.json: %v", err)
+		return nil, "", gcp.InternalErrorf("finding runtimeconfig.json: %v", err)
 	}
 
 	if len(rtCfgFiles) > 1 {
-		return "", "", fmt.Errorf("more than one runtimeconfig.json file found: %v", rtCfgFiles)
+		return nil, "", fmt.Errorf("more than one runtimeconfig.json file found: %v", rtCfgFiles)
 	}
 
 	if len(rtCfgFiles) < 1 {
-		return "", "", fmt.Errorf("no runtimeconfig.json file was found")
+		return nil, "", fmt.Errorf("no runtimeconfig.json file was found")
 	}
 	ctx.Logf("Found runtimeconfig file %q", rtCfgFiles[0])
 
-	version := ""
 	rtCfg, err := ReadRuntimeConfigJSON(rtCfgFiles[0])
 	if err != nil {
-		return "", rtCfgFiles[0], fmt.Errorf("reading runtimeconfig.json: %w", err)
+		return nil, rtCfgFiles[0], fmt.Errorf("reading runtimeconfig.json: %w", err)
 	}
+	return rtCfg, rtCfgFiles[0], nil
+}
 
+// aspNetCoreVersion returns the Microsoft.AspNetCore.App version referenced by rtCfg, or an empty
+// string if there is none.
+func aspNetCoreVersion(rtCfg *RuntimeConfigJSON) string {
 	if rtCfg.RuntimeOptions.Framework.Name == aspDotnetCore {
-		version = rtCfg.RuntimeOptions.Framework.Version
-	} else {
-		for _, fw := range rtCfg.RuntimeOptions.Frameworks {
-			if fw.Name == aspDotnetCore {
-				version = fw.Version
-				break
-			}
+		return rtCfg.RuntimeOptions.Framework.Version
+	}
+	for _, fw := range rtCfg.RuntimeOptions.Frameworks {
+		if fw.Name == aspDotnetCore {
+			return fw.Version
 		}
 	}
+	return ""
+}
 
-	if version == "" {
-		return "", rtCfgFiles[0], fmt.Errorf("couldn't find runtime version for framework %s from "+
-			"runtimeconfig.json: %#v", aspDotnetCore, rtCfg)
+// FrameworkReference is a shared framework of the ASP.NET Core runtime referenced by a
+// runtimeconfig.json file.
+type FrameworkReference struct {
+	// File is the path of the runtimeconfig.json file.
+	File string
+	// Name is the name of the shared framework, e.g. Microsoft.AspNetCore.App.
+	Name string
+	// Version is the lowest framework version the app can run on.
+	Version string
+	// RollForward is the policy the .NET host uses to select a framework version at startup.
+	RollForward string
+}
+
+// GetFrameworkReferences returns the references of the runtimeconfig.json file found in dir to the
+// shared frameworks installed with the ASP.NET Core runtime, Microsoft.NETCore.App and
+// Microsoft.AspNetCore.App, with their roll-forward policy. Console and worker apps only reference
+// Microsoft.NETCore.App.
+func GetFrameworkReferences(ctx *gcp.Context, dir string) ([]*FrameworkReference, error) {
+	rtCfg, rtCfgFile, err := readRuntimeConfigInDir(ctx, dir)
+	if err != nil {
+		return nil, err
 	}
+	rollForward := rtCfg.RuntimeOptions.RollForward
+	if rollForward == "" && rtCfg.RuntimeOptions.RollForwardOnNoCandidateFx != nil {
+		// rollForwardOnNoCandidateFx is the legacy setting replaced by rollForward in .NET Core 3.0.
+		switch *rtCfg.RuntimeOptions.RollForwardOnNoCandidateFx {
+		case 0:
+			rollForward = rollForwardLatestPatch
+		case 2:
+			rollForward = rollForwardMajor
+		}
+	}
+	if rollForward == "" {
+		rollForward = rollForwardMinor
+	}
+	var refs []*FrameworkReference
+	for _, fw := range append([]framework{rtCfg.RuntimeOptions.Framework}, rtCfg.RuntimeOptions.Frameworks...) {
+		if fw.Name == netCoreApp || fw.Name == aspDotnetCore {
+			refs = append(refs, &FrameworkReference{File: rtCfgFile, Name: fw.Name, Version: fw.Version, RollForward: rollForward})
+		}
+	}
+	return refs, nil
+}
 
-	return version, rtCfgFiles[0], nil
+// AllowsVersion returns true if the .NET host would run the app on the given framework version
+// under the roll-forward policy of the reference.
+func (r *FrameworkReference) AllowsVersion(version string) (bool, error) {
+	want, err := semver.NewVersion(r.Version)
+	if err != nil {
+		return false, gcp.UserErrorf("parsing %s version %q in %s: %v", r.Name, r.Version, r.File, err)
+	}
+	got, err := semver.NewVersion(version)
+	if err != nil {
+		return false, gcp.InternalErrorf("parsing %s version %q: %v", r.Name, version, err)
+	}
+	if got.LessThan(want) {
+		return false, nil
+	}
+	switch {
+	case strings.EqualFold(r.RollForward, rollForwardDisable):
+		return got.Equal(want), nil
+	case strings.EqualFold(r.RollForward, rollForwardLatestPatch):
+		return got.Major() == want.Major() && got.Minor() == want.Minor(), nil
+	case strings.EqualFold(r.RollForward, rollForwardMinor), strings.EqualFold(r.RollForward, rollForwardLatestMinor):
+		return got.Major() == want.Major(), nil
+	case strings.EqualFold(r.RollForward, rollForwardMajor), strings.EqualFold(r.RollForward, rollForwardLatestMajor):
+		return true, nil
+	}
+	return false, gcp.UserErrorf("unsupported rollForward value %q in %s", r.RollForward, r.File)
 }
 
 // RequiresGlobalizationInvariant returns true if the system lacks the OS packages necessary to
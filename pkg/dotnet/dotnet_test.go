@@ -149,6 +149,7 @@ func TestGetSDKVersion(t *testing.T) {
 		SDKVersionEnvVar     string
 		RuntimeVersionEnvVar string
 		ApplicationRoot      string
+		ToolVersions         string
 		ExpectedResult       string
 	}{
 		{
@@ -188,11 +189,44 @@ func TestGetSDKVersion(t *testing.T) {
 			ApplicationRoot:      testdata.MustGetPath("testdata/"),
 			ExpectedResult:       "3.1.100",
 		},
+		{
+			Name:           "Should read dotnet from .tool-versions",
+			ToolVersions:   "nodejs 20.0.0\ndotnet 8.0.100\n",
+			ExpectedResult: "8.0.100",
+		},
+		{
+			Name:           "Should read dotnet-core from .tool-versions",
+			ToolVersions:   "dotnet-core 6.0.400\n",
+			ExpectedResult: "6.0.400",
+		},
+		{
+			Name:           "dotnet takes precedence over dotnet-core in .tool-versions",
+			ToolVersions:   "dotnet-core 6.0.400\ndotnet 8.0.100\n",
+			ExpectedResult: "8.0.100",
+		},
+		{
+			Name:           ".tool-versions without dotnet",
+			ToolVersions:   "nodejs 20.0.0\n",
+			ExpectedResult: "",
+		},
+		{
+			Name:                 "Env var should take precedence over .tool-versions",
+			RuntimeVersionEnvVar: "2.1.100",
+			ToolVersions:         "dotnet 8.0.100\n",
+			ExpectedResult:       "2.1.100",
+		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.Name, func(t *testing.T) {
-			ctx := gcp.NewContext(gcp.WithApplicationRoot(tc.ApplicationRoot))
+			appRoot := tc.ApplicationRoot
+			if tc.ToolVersions != "" {
+				appRoot = t.TempDir()
+				if err := os.WriteFile(filepath.Join(appRoot, ".tool-versions"), []byte(tc.ToolVersions), 0644); err != nil {
+					t.Fatalf("writing .tool-versions: %v", err)
+				}
+			}
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(appRoot))
 			if tc.SDKVersionEnvVar != "" {
 				t.Setenv(envSdkVersion, tc.SDKVersionEnvVar)
 			}
@@ -291,6 +325,96 @@ func TestGetRuntimeVersion(t *testing.T) {
 	}
 }
 
+func TestGetFrameworkReferences(t *testing.T) {
+	testCases := []struct {
+		name           string
+		runtimeOptions string
+		want           []*FrameworkReference
+	}{
+		{
+			name:           "default roll forward",
+			runtimeOptions: `"framework": {"name": "Microsoft.AspNetCore.App", "version": "8.0.0"}`,
+			want:           []*FrameworkReference{{Name: "Microsoft.AspNetCore.App", Version: "8.0.0", RollForward: "Minor"}},
+		},
+		{
+			name:           "roll forward",
+			runtimeOptions: `"rollForward": "LatestPatch", "frameworks": [{"name": "Microsoft.NETCore.App", "version": "8.0.0"}, {"name": "Microsoft.AspNetCore.App", "version": "8.0.1"}]`,
+			want: []*FrameworkReference{
+				{Name: "Microsoft.NETCore.App", Version: "8.0.0", RollForward: "LatestPatch"},
+				{Name: "Microsoft.AspNetCore.App", Version: "8.0.1", RollForward: "LatestPatch"},
+			},
+		},
+		{
+			name:           "legacy roll forward",
+			runtimeOptions: `"rollForwardOnNoCandidateFx": 2, "framework": {"name": "Microsoft.AspNetCore.App", "version": "3.1.0"}`,
+			want:           []*FrameworkReference{{Name: "Microsoft.AspNetCore.App", Version: "3.1.0", RollForward: "Major"}},
+		},
+		{
+			name:           "console app",
+			runtimeOptions: `"framework": {"name": "Microsoft.NETCore.App", "version": "8.0.0"}`,
+			want:           []*FrameworkReference{{Name: "Microsoft.NETCore.App", Version: "8.0.0", RollForward: "Minor"}},
+		},
+		{
+			name:           "framework not installed with the ASP.NET Core runtime",
+			runtimeOptions: `"framework": {"name": "Microsoft.WindowsDesktop.App", "version": "8.0.0"}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			rtCfgFile := filepath.Join(dir, "app.runtimeconfig.json")
+			if err := os.WriteFile(rtCfgFile, []byte(`{"runtimeOptions": {`+tc.runtimeOptions+`}}`), 0644); err != nil {
+				t.Fatalf("writing runtimeconfig.json: %v", err)
+			}
+
+			got, err := GetFrameworkReferences(gcp.NewContext(), dir)
+			if err != nil {
+				t.Fatalf("GetFrameworkReferences() got error: %v", err)
+			}
+			for _, ref := range tc.want {
+				ref.File = rtCfgFile
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("GetFrameworkReferences() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFrameworkReferenceAllowsVersion(t *testing.T) {
+	testCases := []struct {
+		rollForward string
+		version     string
+		candidate   string
+		want        bool
+		wantErr     bool
+	}{
+		{rollForward: "Disable", version: "8.0.1", candidate: "8.0.1", want: true},
+		{rollForward: "Disable", version: "8.0.1", candidate: "8.0.2"},
+		{rollForward: "LatestPatch", version: "8.0.1", candidate: "8.0.11", want: true},
+		{rollForward: "LatestPatch", version: "8.0.1", candidate: "8.1.0"},
+		{rollForward: "LatestPatch", version: "8.0.1", candidate: "8.0.0"},
+		{rollForward: "Minor", version: "8.0.1", candidate: "8.2.0", want: true},
+		{rollForward: "minor", version: "8.0.1", candidate: "8.2.0", want: true},
+		{rollForward: "LatestMinor", version: "8.0.1", candidate: "9.0.0"},
+		{rollForward: "Major", version: "8.0.1", candidate: "9.0.0", want: true},
+		{rollForward: "LatestMajor", version: "8.0.1", candidate: "7.0.20"},
+		{rollForward: "Minor", version: "9.0.0-preview.7.24405.7", candidate: "9.0.0", want: true},
+		{rollForward: "Minor", version: "9.0.1", candidate: "9.0.0-rc.2"},
+		{rollForward: "Sideways", version: "8.0.1", candidate: "8.0.1", wantErr: true},
+	}
+	for _, tc := range testCases {
+		ref := &FrameworkReference{Version: tc.version, RollForward: tc.rollForward}
+		got, err := ref.AllowsVersion(tc.candidate)
+		if gotErr := err != nil; gotErr != tc.wantErr {
+			t.Errorf("%+v.AllowsVersion(%q) got error: %v, want error: %v", ref, tc.candidate, err, tc.wantErr)
+		}
+		if got != tc.want {
+			t.Errorf("%+v.AllowsVersion(%q) = %v, want %v", ref, tc.candidate, got, tc.want)
+		}
+	}
+}
+
 func TestRequiresGlobalizationInvariant(t *testing.T) {
 	testCases := []struct {
 		Stack string
@@ -320,3 +444,125 @@ func TestRequiresGlobalizationInvariant(t *testing.T) {
 		})
 	}
 }
+
+func TestFindProjectFileAspire(t *testing.T) {
+	const (
+		appHost = `<Project Sdk="Microsoft.NET.Sdk">
+	<Sdk Name="Aspire.AppHost.Sdk" Version="9.0.0" />
+	<PropertyGroup>
+		<OutputType>Exe</OutputType>
+		<IsAspireHost>true</IsAspireHost>
+	</PropertyGroup>
+	<ItemGroup>
+		<PackageReference Include="Aspire.Hosting.AppHost" Version="9.0.0" />
+	</ItemGroup>
+</Project>`
+		legacyAppHost = `<Project Sdk="Microsoft.NET.Sdk">
+	<ItemGroup>
+		<PackageReference Include="Aspire.Hosting.AppHost" Version="8.2.0" />
+	</ItemGroup>
+</Project>`
+		service = `<Project Sdk="Microsoft.NET.Sdk.Web">
+	<ItemGroup>
+		<PackageReference Include="Aspire.Npgsql" Version="9.0.0" />
+	</ItemGroup>
+</Project>`
+	)
+	testCases := []struct {
+		name      string
+		files     map[string]string
+		buildable string
+		allow     bool
+		want      string
+		wantErr   []string
+	}{
+		{
+			name: "apphost and two services",
+			files: map[string]string{
+				"Shop.AppHost/Shop.AppHost.csproj": appHost,
+				"Shop.Api/Shop.Api.csproj":         service,
+				"Shop.Web/Shop.Web.csproj":         service,
+			},
+			wantErr: []string{"Shop.Api/Shop.Api.csproj", "Shop.Web/Shop.Web.csproj", env.Buildable},
+		},
+		{
+			name: "apphost and two services with buildable",
+			files: map[string]string{
+				"Shop.AppHost/Shop.AppHost.csproj": appHost,
+				"Shop.Api/Shop.Api.csproj":         service,
+				"Shop.Web/Shop.Web.csproj":         service,
+			},
+			buildable: "Shop.Api",
+			want:      "Shop.Api/Shop.Api.csproj",
+		},
+		{
+			name: "apphost skipped",
+			files: map[string]string{
+				"Shop.AppHost/Shop.AppHost.csproj": legacyAppHost,
+				"Shop.Api/Shop.Api.csproj":         service,
+			},
+			want: "Shop.Api/Shop.Api.csproj",
+		},
+		{
+			name: "apphost as buildable",
+			files: map[string]string{
+				"Shop.AppHost/Shop.AppHost.csproj": appHost,
+				"Shop.Api/Shop.Api.csproj":         service,
+				"Shop.Web/Shop.Web.csproj":         service,
+			},
+			buildable: "Shop.AppHost/Shop.AppHost.csproj",
+			wantErr:   []string{"AppHost project", AllowAspireAppHostEnv},
+		},
+		{
+			name: "only apphost",
+			files: map[string]string{
+				"Shop.AppHost/Shop.AppHost.csproj": `<Project Sdk="Aspire.AppHost.Sdk/9.2.0"></Project>`,
+			},
+			wantErr: []string{"AppHost project", AllowAspireAppHostEnv},
+		},
+		{
+			name: "apphost allowed",
+			files: map[string]string{
+				"Shop.AppHost/Shop.AppHost.csproj": appHost,
+			},
+			allow: true,
+			want:  "Shop.AppHost/Shop.AppHost.csproj",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for f, c := range tc.files {
+				if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(f)), 0755); err != nil {
+					t.Fatalf("creating directory: %v", err)
+				}
+				if err := os.WriteFile(filepath.Join(dir, f), []byte(c), 0644); err != nil {
+					t.Fatalf("writing %s: %v", f, err)
+				}
+			}
+			t.Setenv(env.Buildable, filepath.Join(dir, tc.buildable))
+			if tc.allow {
+				t.Setenv(AllowAspireAppHostEnv, "true")
+			}
+
+			got, err := FindProjectFile(gcp.NewContext())
+			if len(tc.wantErr) > 0 {
+				if err == nil {
+					t.Fatalf("FindProjectFile() = %q, want error containing %q", got, tc.wantErr)
+				}
+				for _, want := range tc.wantErr {
+					if !strings.Contains(err.Error(), want) {
+						t.Errorf("FindProjectFile() = %q, want error containing %q", err, want)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FindProjectFile() got error: %v", err)
+			}
+			if want := filepath.Join(dir, tc.want); got != want {
+				t.Errorf("FindProjectFile() = %q, want %q", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package env
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitArgs(t *testing.T) {
+	testCases := []struct {
+		name    string
+		args    string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name: "flags",
+			args: "-Dfoo=bar \t --info",
+			want: []string{"-Dfoo=bar", "--info"},
+		},
+		{
+			name: "quoted arguments with spaces",
+			args: `-Dgreeting="hello world" '-Dname=my app'`,
+			want: []string{"-Dgreeting=hello world", "-Dname=my app"},
+		},
+		{
+			name: "backslash escapes",
+			args: `my\ dir "a \" b"`,
+			want: []string{"my dir", `a " b`},
+		},
+		{
+			name:    "unterminated quote",
+			args:    `-Dgreeting="hello`,
+			wantErr: true,
+		},
+		{
+			name:    "trailing backslash",
+			args:    `--info \`,
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := SplitArgs(tc.args)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("SplitArgs(%q) got error: %v, want error: %t", tc.args, err, tc.wantErr)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("SplitArgs(%q) = %q, want %q", tc.args, got, tc.want)
+			}
+		})
+	}
+}
@@ -37,6 +37,37 @@ const (
 	// Example: `true`, `True`, `1` will enable development mode.
 	DebugMode = "GOOGLE_DEBUG"
 
+	// Labels is an env var used to attach custom labels to the application image.
+	// Example: `service-id=checkout,owner=payments,description="a, b"`.
+	Labels = "GOOGLE_LABELS"
+
+	// LabelsJSON is an env var used to attach custom labels to the application image as a JSON object.
+	// Example: `{"service-id": "checkout", "description": "a=b, c"}`.
+	LabelsJSON = "GOOGLE_LABELS_JSON"
+
+	// CacheDiff is an env var used to log every input of a layer cache key, not only the changed ones, on a cache miss.
+	CacheDiff = "GOOGLE_CACHE_DIFF"
+
+	// ApplicationRoot is an env var used to build a subdirectory of the workspace as the application.
+	// Example: `services/api` for a monorepo with one deployable service per directory.
+	// Lockfiles and workspace configuration are still looked up in parent directories up to the workspace root.
+	ApplicationRoot = "GOOGLE_APPLICATION_ROOT"
+
+	// BuildReport is an env var used to write a JSON summary of the language, runtime version, package manager
+	// and framework detected by the buildpacks to $BUILDER_OUTPUT/build-report.json at the end of the build.
+	BuildReport = "GOOGLE_BUILD_REPORT"
+
+	// BundlerVersion is an env var used to pin the version of Bundler installed by the Ruby buildpacks.
+	// Example: `2.4.22`. It must be a Bundler 2 version.
+	BundlerVersion = "GOOGLE_BUNDLER_VERSION"
+
+	// DefaultProcessType is an env var used to register the primary process under a type other than "web", e.g. "worker".
+	DefaultProcessType = "GOOGLE_DEFAULT_PROCESS_TYPE"
+
+	// ReleaseCommand is an env var used to set the command of the release process, which platforms
+	// supporting it run before routing traffic to a new release, e.g. `python3 manage.py migrate`.
+	ReleaseCommand = "GOOGLE_RELEASE_COMMAND"
+
 	// DevMode is an env var used to enable development mode in buildpacks.
 	// DevMode should be respected by all buildpacks that are not product-specific.
 	// Example: `true`, `True`, `1` will enable development mode.
@@ -47,6 +78,42 @@ const (
 	// Example: `gunicorn -p :8080 main:app` for Python.
 	Entrypoint = "GOOGLE_ENTRYPOINT"
 
+	// EntrypointJSON is an env var used to override the default entrypoint with a JSON array of the
+	// program and its arguments, which are passed as is without shell tokenization or expansion. It
+	// cannot be set together with Entrypoint.
+	// Example: `["gunicorn", "-p", ":8080", "--log-config-json", "{\"version\": 1}", "main:app"]`.
+	EntrypointJSON = "GOOGLE_ENTRYPOINT_JSON"
+
+	// Prebuilt is an env var used to package artifacts built outside of the buildpacks instead of
+	// compiling the source. Buildpacks for Go and Java support prebuilt artifacts.
+	Prebuilt = "GOOGLE_PREBUILT"
+
+	// PrebuiltBinary is an env var used to specify the path of a prebuilt Go executable, relative to
+	// the application root, when Prebuilt is set.
+	// Example: `bin/server`.
+	PrebuiltBinary = "GOOGLE_PREBUILT_BINARY"
+
+	// ReproducibleBuild is an env var used to make the application layers reproducible: build tools
+	// that support it normalize the timestamps of the archives they produce, and the buildpacks set
+	// the modification time of the files in the layers they create to a fixed epoch.
+	// Example: `true`, `True`, `1` will enable reproducible builds.
+	ReproducibleBuild = "GOOGLE_REPRODUCIBLE_BUILD"
+
+	// VerifyLockfile is an env var used to fail the build if the lockfile of the package manager is out
+	// of sync with the dependencies declared by the application, instead of resolving them again.
+	// Example: `true`, `True`, `1` will verify the lockfile.
+	VerifyLockfile = "GOOGLE_VERIFY_LOCKFILE"
+
+	// MaxImageLayerMB is an env var used to set the maximum total size, in MB, of the launch layers
+	// created by each buildpack. Larger layers are reported with a warning.
+	// Example: `500`.
+	MaxImageLayerMB = "GOOGLE_MAX_IMAGE_LAYER_MB"
+
+	// MaxImageLayerStrict is an env var used to fail the build instead of logging a warning when the
+	// launch layers of a buildpack exceed MaxImageLayerMB.
+	// Example: `true`, `True`, `1` will fail the build.
+	MaxImageLayerStrict = "GOOGLE_MAX_IMAGE_LAYER_STRICT"
+
 	// ClearSource is an env var used to clear source files from the final image.
 	// Buildpacks for Go and Java support clearing the source.
 	ClearSource = "GOOGLE_CLEAR_SOURCE"
@@ -79,6 +146,15 @@ const (
 	// FunctionTargetLaunch is a launch time version of FunctionTarget.
 	FunctionTargetLaunch = "FUNCTION_TARGET"
 
+	// FunctionGoproxy is an env var used to override GOPROXY when building Go functions, e.g. in
+	// environments where proxy.golang.org is unreachable. It accepts the same values as GOPROXY.
+	FunctionGoproxy = "GOOGLE_FUNCTION_GOPROXY"
+
+	// FunctionConcurrencySafe is an env var used to declare whether a Go function can handle concurrent
+	// requests. Setting it to false adds an image label recording a concurrency of 1; it does not
+	// change the concurrency of the deployed service, which must be set with `--concurrency 1`.
+	FunctionConcurrencySafe = "GOOGLE_FUNCTION_CONCURRENCY_SAFE"
+
 	// FunctionSource is an env var used to specify function source location.
 	// FunctionSource must be respected by all functions-framework buildpacks.
 	// Example: `./path/to/source` will build the function at the specfied path.
@@ -86,6 +162,22 @@ const (
 	// FunctionSourceLaunch is a launch time version of FunctionSource.
 	FunctionSourceLaunch = "FUNCTION_SOURCE"
 
+	// FunctionStrictTyping is an env var used to fail the build of a Go function whose target has
+	// type parameters, which the functions framework cannot invoke in all deployment environments.
+	// Defaults to false.
+	FunctionStrictTyping = "GOOGLE_FUNCTION_STRICT_TYPING"
+
+	// FunctionSourceSubdir is an env var used to specify the subdirectory of the application source
+	// that contains the function. It allows building several functions from one source tree.
+	// Example: `functions/hello` will build the function located in the functions/hello directory.
+	FunctionSourceSubdir = "GOOGLE_FUNCTION_SOURCE_SUBDIR"
+
+	// FunctionStagingDir is an env var used to specify the name of the directory of the application
+	// root that the Go functions framework buildpack moves the function source into. The directory
+	// must not already exist in the source.
+	// Defaults to `serverless_function_source_code`.
+	FunctionStagingDir = "GOOGLE_FUNCTION_STAGING_DIR"
+
 	// FunctionSignatureType is an env var used to specify function signature type.
 	// FunctionSignatureType must be respected by all functions-framework buildpacks.
 	// Example: `http` for HTTP-triggered functions or `event` for event-triggered functions.
@@ -93,12 +185,30 @@ const (
 	// FunctionSignatureTypeLaunch is a launch time version of FunctionSignatureType.
 	FunctionSignatureTypeLaunch = "FUNCTION_SIGNATURE_TYPE"
 
+	// FunctionsFrameworkArgs is an env var used to append arguments to the functions framework launch
+	// command. Arguments are separated by whitespace and may be quoted.
+	// Example: `--log-execution-id --debug` is appended to the command of the web process.
+	FunctionsFrameworkArgs = "GOOGLE_FUNCTIONS_FRAMEWORK_ARGS"
+
 	// GoGCFlags is an env var used to pass through compilation flags to the Go compiler.
 	// Example: `-N -l` is used during debugging to disable optimizations and inlining.
 	GoGCFlags = "GOOGLE_GOGCFLAGS"
 	// GoLDFlags is an env var used to pass through linker flags to the Go linker.
 	// Example: `-s -w` is sometimes used to strip and reduce binary size.
 	GoLDFlags = "GOOGLE_GOLDFLAGS"
+	// GoBuildCacheSizeMB is an env var used to limit the size in megabytes of the Go build cache.
+	// The cache is cleared after the build if it grows beyond the limit.
+	GoBuildCacheSizeMB = "GOOGLE_GO_BUILD_CACHE_SIZE_MB"
+	// GoOS is an env var used to cross-compile the Go binary for another operating system.
+	// Example: `linux`. Only linux/amd64 and linux/arm64 targets are supported.
+	GoOS = "GOOGLE_GOOS"
+	// GoArch is an env var used to cross-compile the Go binary for another architecture.
+	// Example: `arm64` builds a linux/arm64 binary on a linux/amd64 builder.
+	GoArch = "GOOGLE_GOARCH"
+	// GoBuildMode is an env var used to pass -buildmode to go build.
+	// Example: `c-shared` builds a shared library instead of an executable. Only exe, c-shared and
+	// plugin are supported.
+	GoBuildMode = "GOOGLE_GO_BUILDMODE"
 
 	// UseNativeImage is used to enable the GraalVM Java buildpack for native image compilation.
 	// Example: `true`, `True`, `1` will enable development mode.
@@ -108,6 +218,10 @@ const (
 	// Example: `--enable-http --enable-https -H:ReflectionConfigurationFiles=native-image-config/picocli-reflect.json`
 	NativeImageBuildArgs = "GOOGLE_JAVA_NATIVE_IMAGE_ARGS"
 
+	// NativeImageLinkMode selects how a GraalVM native image is linked: `static`, `mostly-static` or `dynamic`.
+	// Example: `dynamic` links against the system libraries. Defaults to `mostly-static`, which only links glibc dynamically.
+	NativeImageLinkMode = "GOOGLE_NATIVE_IMAGE_LINK_MODE"
+
 	// LabelPrefix is a prefix for values that will be added to the final
 	// built user container. The prefix is stripped and the remainder forms the
 	// label key. For example, "GOOGLE_LABEL_ABC=Some-Value" will result in a
@@ -143,6 +257,49 @@ const (
 	// FlexMinVersion is the lowest version that is allowed to build.
 	FlexMinVersion = "GOOGLE_FLEX_MIN_VERSION"
 
+	// DisallowEOLRuntime is an env var used to fail the build when the runtime version is past its end of life.
+	DisallowEOLRuntime = "GOOGLE_DISALLOW_EOL_RUNTIME"
+
+	// XGoogleRuntimeEOLManifest is the path of a JSON file baked into the builder image that overrides
+	// the end of life schedule of runtime versions, including their decommission dates.
+	XGoogleRuntimeEOLManifest = "X_GOOGLE_RUNTIME_EOL_MANIFEST"
+
+	// RuntimeLibs is an env var used to copy allowlisted shared libraries from the build image into the application image.
+	// Example: `libvips,libpq`.
+	RuntimeLibs = "GOOGLE_RUNTIME_LIBS"
+
+	// PHPErrorReporting is the PHP error_reporting level written to php.ini.
+	// Example: `E_ALL & ~E_NOTICE`.
+	PHPErrorReporting = "GOOGLE_PHP_ERROR_REPORTING"
+
+	// BundleRetry is the number of times Bundler retries failed gem downloads, between 1 and 10.
+	// Defaults to 5.
+	BundleRetry = "GOOGLE_BUNDLE_RETRY"
+
+	// BundleTimeout is the number of seconds Bundler waits for a response from a gem server.
+	// Defaults to 120.
+	BundleTimeout = "GOOGLE_BUNDLE_TIMEOUT"
+
+	// RubyUseJemalloc preloads the jemalloc memory allocator in Ruby applications to reduce memory
+	// fragmentation. Defaults to false.
+	RubyUseJemalloc = "GOOGLE_RUBY_USE_JEMALLOC"
+
+	// SkipRuntimeChecksum disables the SHA256 verification of downloaded runtime tarballs, for
+	// mirrors that do not publish checksums.
+	SkipRuntimeChecksum = "GOOGLE_SKIP_RUNTIME_CHECKSUM"
+
+	// SkipRuntimeInstall skips the installation of the language runtime by the runtime buildpacks,
+	// for base images that already include it. The runtime on PATH must satisfy the requested version.
+	SkipRuntimeInstall = "GOOGLE_SKIP_RUNTIME_INSTALL"
+
+	// ScanForSecrets scans the application source for committed secrets, such as private keys or
+	// .env files with credentials, and warns about the files that contain them. Defaults to false.
+	ScanForSecrets = "GOOGLE_SCAN_FOR_SECRETS"
+
+	// SkipBuildpacks is a comma-separated list of buildpack IDs that opt out of the build regardless
+	// of the application, e.g. `google.nodejs.runtime`.
+	SkipBuildpacks = "GOOGLE_SKIP_BUILDPACKS"
+
 	// RuntimeImageRegion is the region to fetch runtime images.
 	RuntimeImageRegion = "GOOGLE_RUNTIME_IMAGE_REGION"
 
@@ -189,6 +346,16 @@ func IsDevMode() (bool, error) {
 	return IsPresentAndTrue(DevMode)
 }
 
+// IsReproducibleBuild returns true if the application layers should be reproducible.
+func IsReproducibleBuild() (bool, error) {
+	return IsPresentAndTrue(ReproducibleBuild)
+}
+
+// IsVerifyLockfile returns true if the build must fail when the lockfile is out of sync.
+func IsVerifyLockfile() (bool, error) {
+	return IsPresentAndTrue(VerifyLockfile)
+}
+
 // IsUsingNativeImage returns true if the Java application should be built as a native image.
 func IsUsingNativeImage() (bool, error) {
 	return IsPresentAndTrue(UseNativeImage)
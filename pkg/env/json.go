@@ -0,0 +1,55 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package env
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// StringMap parses the value of the env var varName as a JSON object whose values are strings,
+// numbers or booleans, e.g. `{"env": "production", "port": 8080}`, and returns it with the values
+// converted to strings. It returns nil if the env var is not set.
+func StringMap(varName string) (map[string]string, error) {
+	raw := os.Getenv(varName)
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(raw), &obj); err != nil {
+		return nil, fmt.Errorf("parsing %s as a JSON object: %w", varName, err)
+	}
+	m := make(map[string]string, len(obj))
+	for k, v := range obj {
+		d := json.NewDecoder(bytes.NewReader(v))
+		d.UseNumber()
+		var scalar interface{}
+		if err := d.Decode(&scalar); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", varName, err)
+		}
+		switch s := scalar.(type) {
+		case string:
+			m[k] = s
+		case json.Number, bool:
+			m[k] = fmt.Sprint(s)
+		default:
+			return nil, fmt.Errorf("value of %q in %s must be a string, number or boolean, got %s", k, varName, v)
+		}
+	}
+	return m, nil
+}
@@ -0,0 +1,81 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package env
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStringMap(t *testing.T) {
+	testCases := []struct {
+		name    string
+		value   string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name: "unset",
+		},
+		{
+			name:  "strings",
+			value: `{"env": "production", "db.url": "jdbc:postgresql://localhost/db"}`,
+			want:  map[string]string{"env": "production", "db.url": "jdbc:postgresql://localhost/db"},
+		},
+		{
+			name:  "numbers and booleans",
+			value: `{"port": 8080, "ratio": 0.5, "debug": false}`,
+			want:  map[string]string{"port": "8080", "ratio": "0.5", "debug": "false"},
+		},
+		{
+			name:  "empty object",
+			value: `{}`,
+			want:  map[string]string{},
+		},
+		{
+			name:    "not an object",
+			value:   `["env", "production"]`,
+			wantErr: true,
+		},
+		{
+			name:    "nested object",
+			value:   `{"db": {"url": "jdbc:postgresql://localhost/db"}}`,
+			wantErr: true,
+		},
+		{
+			name:    "null value",
+			value:   `{"env": null}`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid json",
+			value:   `env=production`,
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("TEST_STRING_MAP", tc.value)
+
+			got, err := StringMap("TEST_STRING_MAP")
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("StringMap() got error: %v, want error: %v", err, tc.wantErr)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("StringMap() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -17,7 +17,11 @@ package fetch
 
 import (
 	"archive/tar"
+	"bufio"
+	"compress/bzip2"
 	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"io"
 	"io/ioutil"
@@ -31,8 +35,15 @@ import (
 	"github.com/hashicorp/go-retryablehttp"
 )
 
-// gcpUserAgent is required for the Ruby runtime, but used for others for simplicity.
-const gcpUserAgent = "GCPBuildpacks"
+const (
+	// gcpUserAgent is required for the Ruby runtime, but used for others for simplicity.
+	gcpUserAgent = "GCPBuildpacks"
+	// bzip2Magic is the header of bzip2 compressed data, e.g. the PyPy tarballs.
+	bzip2Magic = "BZh"
+	// sha256MetadataHeader is the header in which Cloud Storage serves the sha256 custom metadata of
+	// an object.
+	sha256MetadataHeader = "x-goog-meta-sha256"
+)
 
 // Tarball downloads a tarball from a URL and extracts it into the provided directory.
 func Tarball(url, dir string, stripComponents int) error {
@@ -44,6 +55,34 @@ func Tarball(url, dir string, stripComponents int) error {
 	return untar(dir, response.Body, stripComponents)
 }
 
+// TarballWithSHA256 downloads a tarball from a URL, verifies that its SHA256 checksum matches the
+// given hex-encoded checksum, and only then extracts it into the provided directory.
+func TarballWithSHA256(url, dir string, stripComponents int, wantSHA256 string) error {
+	f, err := ioutil.TempFile("", "tarball-*.tar.gz")
+	if err != nil {
+		return gcp.InternalErrorf("creating temporary file: %v", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	response, err := doGet(url)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(f, h), response.Body); err != nil {
+		return gcp.InternalErrorf("downloading %s: %v", url, err)
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(got, wantSHA256) {
+		return gcp.InternalErrorf("SHA256 checksum of %s is %s, want %s", url, got, wantSHA256)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return gcp.InternalErrorf("rewinding %s: %v", f.Name(), err)
+	}
+	return untar(dir, f, stripComponents)
+}
+
 // ARVersions downloads list of versions from artifact registry.
 var ARVersions = func(url, fallbackURL string, ctx *gcp.Context) ([]string, error) {
 	versions, err := crane.ListTags(url)
@@ -133,16 +172,39 @@ func GetURL(url string, f io.Writer) error {
 	return nil
 }
 
-// untar extracts a tarball from a reader and writes it to the given directory.
-func untar(dir string, r io.Reader, stripComponents int) error {
-	gzr, err := gzip.NewReader(r)
+// SHA256Metadata returns the hex-encoded SHA256 checksum stored in the sha256 custom metadata of
+// the Cloud Storage object served at url, without downloading the object.
+func SHA256Metadata(url string) (string, error) {
+	response, err := doRequest(http.MethodHead, url)
 	if err != nil {
-		return gcp.InternalErrorf("creating gzip reader: %v", err)
+		return "", err
+	}
+	response.Body.Close()
+	checksum := strings.ToLower(strings.TrimSpace(response.Header.Get(sha256MetadataHeader)))
+	if checksum == "" {
+		return "", gcp.InternalErrorf("%s does not have %s metadata", url, sha256MetadataHeader)
+	}
+	return checksum, nil
+}
+
+// untar extracts a gzip or bzip2 compressed tarball from a reader and writes it to the given
+// directory.
+func untar(dir string, r io.Reader, stripComponents int) error {
+	br := bufio.NewReader(r)
+	var zr io.Reader
+	if magic, err := br.Peek(len(bzip2Magic)); err == nil && string(magic) == bzip2Magic {
+		zr = bzip2.NewReader(br)
+	} else {
+		gzr, err := gzip.NewReader(br)
+		if err != nil {
+			return gcp.InternalErrorf("creating gzip reader: %v", err)
+		}
+		defer gzr.Close()
+		zr = gzr
 	}
-	defer gzr.Close()
 
 	madeDir := map[string]bool{}
-	tr := tar.NewReader(gzr)
+	tr := tar.NewReader(zr)
 
 	for {
 		header, err := tr.Next()
@@ -253,9 +315,14 @@ func isValidTarDestination(dest, rootDir string, tarType byte) bool {
 
 // doGet performs an HTTP GET request for a URL.
 func doGet(url string) (*http.Response, error) {
+	return doRequest(http.MethodGet, url)
+}
+
+// doRequest performs an HTTP request for a URL and fails unless the response status is 2xx.
+func doRequest(method, url string) (*http.Response, error) {
 	retryClient := retryablehttp.NewClient()
 	retryClient.RetryMax = 3
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequest(method, url, nil)
 	if err != nil {
 		return nil, gcp.UserErrorf("fetching %s: %v", url, err)
 	}
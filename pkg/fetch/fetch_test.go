@@ -20,6 +20,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/GoogleCloudPlatform/buildpacks/internal/testserver"
@@ -47,6 +48,12 @@ func TestTarball(t *testing.T) {
 			stripComponents: 1,
 			wantFile:        "foo.txt",
 		},
+		{
+			name:            "bzip2 tarball",
+			responseFile:    "testdata/test.tar.bz2",
+			stripComponents: 1,
+			wantFile:        "foo.txt",
+		},
 		{
 			name:       "not found",
 			httpStatus: http.StatusNotFound,
@@ -89,6 +96,47 @@ func TestTarball(t *testing.T) {
 	}
 }
 
+func TestTarballWithSHA256(t *testing.T) {
+	const testTarballSHA256 = "fd9c9c45077d43db68deeaf210401b427efe4634b7a176fa84e9414f3790fa29"
+	testCases := []struct {
+		name      string
+		checksum  string
+		wantError bool
+	}{
+		{
+			name:     "matching checksum",
+			checksum: testTarballSHA256,
+		},
+		{
+			name:     "checksum is case insensitive",
+			checksum: strings.ToUpper(testTarballSHA256),
+		},
+		{
+			name:      "mismatched checksum",
+			checksum:  strings.Repeat("0", 64),
+			wantError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := testserver.New(
+				t,
+				testserver.WithFile(testdata.MustGetPath("testdata/test.tar.gz")))
+
+			dir := t.TempDir()
+			err := TarballWithSHA256(server.URL, dir, 0, tc.checksum)
+			if tc.wantError == (err == nil) {
+				t.Fatalf("TarballWithSHA256(%q, %q, 0, %q) got error: %v, want error? %v", server.URL, dir, tc.checksum, err, tc.wantError)
+			}
+			fp := filepath.Join(dir, "lib/foo.txt")
+			if _, err := os.Stat(fp); tc.wantError != os.IsNotExist(err) {
+				t.Errorf("os.Stat(%q) got error: %v, want extracted? %v", fp, err, !tc.wantError)
+			}
+		})
+	}
+}
+
 func TestJSON(t *testing.T) {
 	testCases := []struct {
 		name       string
@@ -172,3 +220,48 @@ func TestGetURL(t *testing.T) {
 		})
 	}
 }
+
+func TestSHA256Metadata(t *testing.T) {
+	checksum := strings.Repeat("a", 64)
+	testCases := []struct {
+		name       string
+		httpStatus int
+		header     string
+		want       string
+		wantError  bool
+	}{
+		{
+			name:   "metadata set",
+			header: strings.ToUpper(checksum),
+			want:   checksum,
+		},
+		{
+			name:      "metadata missing",
+			wantError: true,
+		},
+		{
+			name:       "not found",
+			httpStatus: http.StatusNotFound,
+			header:     checksum,
+			wantError:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := []testserver.Option{testserver.WithStatus(tc.httpStatus)}
+			if tc.header != "" {
+				opts = append(opts, testserver.WithHeader("x-goog-meta-sha256", tc.header))
+			}
+			server := testserver.New(t, opts...)
+
+			got, err := SHA256Metadata(server.URL)
+			if tc.wantError == (err == nil) {
+				t.Fatalf("SHA256Metadata(%q) got error: %v, want error? %v", server.URL, err, tc.wantError)
+			}
+			if got != tc.want {
+				t.Errorf("SHA256Metadata(%q) = %q, want %q", server.URL, got, tc.want)
+			}
+		})
+	}
+}
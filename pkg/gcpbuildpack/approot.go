@@ -0,0 +1,121 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+)
+
+// WorkspaceRoot returns the root folder of the source uploaded for the build. It differs from
+// ApplicationRoot only when GOOGLE_APPLICATION_ROOT selects a subdirectory of the workspace.
+func (ctx *Context) WorkspaceRoot() string {
+	if ctx.workspaceRoot == "" {
+		return ctx.applicationRoot
+	}
+	return ctx.workspaceRoot
+}
+
+// FindInWorkspace looks for a file with one of the given names in the application root and then in
+// each parent directory up to and including the workspace root. Names are tried in order within each
+// directory. It returns the path of the first match, or an empty string if there is none. This
+// allows workspace-aware package managers to find a lockfile shared by several applications in a
+// monorepo.
+func (ctx *Context) FindInWorkspace(names ...string) (string, error) {
+	workspace := ctx.WorkspaceRoot()
+	dir := ctx.ApplicationRoot()
+	for {
+		for _, name := range names {
+			path := filepath.Join(dir, name)
+			exists, err := ctx.FileExists(path)
+			if err != nil {
+				return "", err
+			}
+			if exists {
+				return path, nil
+			}
+		}
+		if dir == workspace {
+			return "", nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir || !IsWithin(workspace, parent) {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// applyApplicationRoot moves the application root to the subdirectory of the workspace named by
+// GOOGLE_APPLICATION_ROOT, if set, and changes the working directory to it so that relative paths
+// used by buildpacks resolve against the new root.
+func (ctx *Context) applyApplicationRoot() error {
+	rel := os.Getenv(env.ApplicationRoot)
+	if rel == "" {
+		return nil
+	}
+	workspace := ctx.ApplicationRoot()
+	root, err := resolveApplicationRoot(workspace, rel)
+	if err != nil {
+		return err
+	}
+	if err := os.Chdir(root); err != nil {
+		return InternalErrorf("changing directory to %s: %v", root, err)
+	}
+	ctx.workspaceRoot = workspace
+	ctx.applicationRoot = root
+	return nil
+}
+
+// resolveApplicationRoot returns the absolute path of the directory rel within workspace. It
+// rejects paths that do not exist, are not directories, or escape the workspace, including through
+// symlinks.
+func resolveApplicationRoot(workspace, rel string) (string, error) {
+	if filepath.IsAbs(rel) || !filepath.IsLocal(rel) {
+		return "", UserErrorf("invalid %s %q: must be a relative path inside the workspace", env.ApplicationRoot, rel)
+	}
+	root := filepath.Join(workspace, rel)
+	info, err := os.Stat(root)
+	if os.IsNotExist(err) {
+		return "", UserErrorf("invalid %s %q: directory does not exist", env.ApplicationRoot, rel)
+	}
+	if err != nil {
+		return "", InternalErrorf("stat %q: %v", root, err)
+	}
+	if !info.IsDir() {
+		return "", UserErrorf("invalid %s %q: not a directory", env.ApplicationRoot, rel)
+	}
+	realWorkspace, err := filepath.EvalSymlinks(workspace)
+	if err != nil {
+		return "", InternalErrorf("resolving %q: %v", workspace, err)
+	}
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", InternalErrorf("resolving %q: %v", root, err)
+	}
+	if !IsWithin(realWorkspace, realRoot) {
+		return "", UserErrorf("invalid %s %q: resolves to %s, which is outside the workspace", env.ApplicationRoot, rel, realRoot)
+	}
+	return root, nil
+}
+
+// IsWithin returns true if path is dir or one of its descendants, comparing cleaned paths rather
+// than string prefixes, so that e.g. /workspace-other is not within /workspace.
+func IsWithin(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && filepath.IsLocal(rel)
+}
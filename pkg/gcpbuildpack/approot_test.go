@@ -0,0 +1,176 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveApplicationRoot(t *testing.T) {
+	workspace := t.TempDir()
+	outside := t.TempDir()
+	for _, dir := range []string{"services/api", "shared"} {
+		if err := os.MkdirAll(filepath.Join(workspace, dir), 0755); err != nil {
+			t.Fatalf("creating dir: %v", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(workspace, "README.md"), nil, 0644); err != nil {
+		t.Fatalf("writing file: %v", err)
+	}
+	if err := os.Symlink(outside, filepath.Join(workspace, "escape")); err != nil {
+		t.Fatalf("creating symlink: %v", err)
+	}
+	if err := os.Symlink(filepath.Join(workspace, "shared"), filepath.Join(workspace, "link")); err != nil {
+		t.Fatalf("creating symlink: %v", err)
+	}
+
+	testCases := []struct {
+		rel     string
+		want    string
+		wantErr bool
+	}{
+		{rel: "services/api", want: filepath.Join(workspace, "services/api")},
+		{rel: "./services/api/", want: filepath.Join(workspace, "services/api")},
+		{rel: ".", want: workspace},
+		{rel: "link", want: filepath.Join(workspace, "link")},
+		{rel: "services/../shared", want: filepath.Join(workspace, "shared")},
+		{rel: "../", wantErr: true},
+		{rel: "services/../../etc", wantErr: true},
+		{rel: "/etc", wantErr: true},
+		{rel: "escape", wantErr: true},
+		{rel: "missing", wantErr: true},
+		{rel: "README.md", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.rel, func(t *testing.T) {
+			got, err := resolveApplicationRoot(workspace, tc.rel)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("resolveApplicationRoot(%q) = %q, want error", tc.rel, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveApplicationRoot(%q) got error: %v", tc.rel, err)
+			}
+			if got != tc.want {
+				t.Errorf("resolveApplicationRoot(%q) = %q, want %q", tc.rel, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFindInWorkspace(t *testing.T) {
+	testCases := []struct {
+		name      string
+		files     []string
+		workspace string
+		names     []string
+		want      string
+	}{
+		{
+			name:  "in application root",
+			files: []string{"services/api/yarn.lock", "yarn.lock"},
+			names: []string{"yarn.lock"},
+			want:  "services/api/yarn.lock",
+		},
+		{
+			name:  "in workspace root",
+			files: []string{"yarn.lock"},
+			names: []string{"yarn.lock"},
+			want:  "yarn.lock",
+		},
+		{
+			name:  "in intermediate directory",
+			files: []string{"services/yarn.lock", "yarn.lock"},
+			names: []string{"yarn.lock"},
+			want:  "services/yarn.lock",
+		},
+		{
+			name:  "names in order",
+			files: []string{"package-lock.json", "npm-shrinkwrap.json"},
+			names: []string{"npm-shrinkwrap.json", "package-lock.json"},
+			want:  "npm-shrinkwrap.json",
+		},
+		{
+			name:  "nearest directory first",
+			files: []string{"services/api/package-lock.json", "npm-shrinkwrap.json"},
+			names: []string{"npm-shrinkwrap.json", "package-lock.json"},
+			want:  "services/api/package-lock.json",
+		},
+		{
+			name:  "not found",
+			names: []string{"yarn.lock"},
+		},
+		{
+			name:      "not above workspace root",
+			files:     []string{"yarn.lock"},
+			workspace: "services",
+			names:     []string{"yarn.lock"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			appRoot := filepath.Join(dir, "services/api")
+			if err := os.MkdirAll(appRoot, 0755); err != nil {
+				t.Fatalf("creating dir: %v", err)
+			}
+			for _, f := range tc.files {
+				if err := os.WriteFile(filepath.Join(dir, f), nil, 0644); err != nil {
+					t.Fatalf("writing file: %v", err)
+				}
+			}
+			ctx := NewContext(WithApplicationRoot(appRoot), WithWorkspaceRoot(filepath.Join(dir, tc.workspace)))
+
+			got, err := ctx.FindInWorkspace(tc.names...)
+			if err != nil {
+				t.Fatalf("FindInWorkspace(%q) got error: %v", tc.names, err)
+			}
+			want := ""
+			if tc.want != "" {
+				want = filepath.Join(dir, tc.want)
+			}
+			if got != want {
+				t.Errorf("FindInWorkspace(%q) = %q, want %q", tc.names, got, want)
+			}
+		})
+	}
+}
+
+func TestFindInWorkspaceWithoutWorkspaceRoot(t *testing.T) {
+	dir := t.TempDir()
+	appRoot := filepath.Join(dir, "app")
+	if err := os.Mkdir(appRoot, 0755); err != nil {
+		t.Fatalf("creating dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "yarn.lock"), nil, 0644); err != nil {
+		t.Fatalf("writing file: %v", err)
+	}
+	ctx := NewContext(WithApplicationRoot(appRoot))
+
+	if got := ctx.WorkspaceRoot(); got != appRoot {
+		t.Errorf("WorkspaceRoot() = %q, want %q", got, appRoot)
+	}
+	got, err := ctx.FindInWorkspace("yarn.lock")
+	if err != nil {
+		t.Fatalf("FindInWorkspace() got error: %v", err)
+	}
+	if got != "" {
+		t.Errorf("FindInWorkspace() = %q, want no match outside the application root", got)
+	}
+}
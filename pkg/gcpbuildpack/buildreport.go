@@ -0,0 +1,151 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+)
+
+// BuildReportFile is the name of the build report in the output directory provided by the platform
+// in BUILDER_OUTPUT. It is shared by all buildpacks in the group so that each one adds its facts to it.
+const BuildReportFile = "build-report.json"
+
+// BuildReport summarizes what the buildpacks detected and installed during the build. It is written
+// as JSON when GOOGLE_BUILD_REPORT is enabled.
+type BuildReport struct {
+	// Language is the language of the application, e.g. "nodejs" or "python".
+	Language string `json:"language,omitempty"`
+	// RuntimeVersion is the version of the language runtime installed in the image.
+	RuntimeVersion string `json:"runtimeVersion,omitempty"`
+	// PackageManager is the tool used to install the application dependencies, e.g. "npm" or "pip".
+	PackageManager string `json:"packageManager,omitempty"`
+	// Framework is the framework the application is built with, if any was detected.
+	Framework *FrameworkReport `json:"framework,omitempty"`
+	// Buildpacks lists the buildpacks that ran, in order.
+	Buildpacks []BuildpackReport `json:"buildpacks"`
+}
+
+// FrameworkReport describes the framework used by the application.
+type FrameworkReport struct {
+	// Name is the name of the framework, e.g. "functions-framework" or "nextjs".
+	Name string `json:"name"`
+	// Version is the version of the framework in the image.
+	Version string `json:"version,omitempty"`
+	// Injected is true if the framework was added by a buildpack rather than declared by the application.
+	Injected bool `json:"injected"`
+}
+
+// BuildpackReport identifies a buildpack that contributed to the build report.
+type BuildpackReport struct {
+	ID      string `json:"id"`
+	Version string `json:"version"`
+}
+
+// ReportRuntime records the language and runtime version installed by the buildpack.
+func (ctx *Context) ReportRuntime(language, version string) {
+	ctx.report.Language = language
+	ctx.report.RuntimeVersion = version
+}
+
+// ReportPackageManager records the tool used to install the application dependencies.
+func (ctx *Context) ReportPackageManager(name string) {
+	ctx.report.PackageManager = name
+}
+
+// ReportFramework records the framework used by the application and whether it was injected by the
+// buildpack.
+func (ctx *Context) ReportFramework(name, version string, injected bool) {
+	ctx.report.Framework = &FrameworkReport{Name: name, Version: version, Injected: injected}
+}
+
+// merge adds the facts reported by a single buildpack to the report. Facts reported by later
+// buildpacks take precedence.
+func (r *BuildReport) merge(facts BuildReport, bp BuildpackReport) {
+	if facts.Language != "" {
+		r.Language = facts.Language
+	}
+	if facts.RuntimeVersion != "" {
+		r.RuntimeVersion = facts.RuntimeVersion
+	}
+	if facts.PackageManager != "" {
+		r.PackageManager = facts.PackageManager
+	}
+	if facts.Framework != nil {
+		r.Framework = facts.Framework
+	}
+	r.Buildpacks = append(r.Buildpacks, bp)
+}
+
+// buildReportPath returns the path of the build report shared by all buildpacks, or an empty string
+// if the platform does not provide an output directory.
+func buildReportPath() string {
+	outputDir := os.Getenv(builderOutputEnv)
+	if outputDir == "" {
+		return ""
+	}
+	return filepath.Join(outputDir, BuildReportFile)
+}
+
+// saveBuildReport adds the facts reported by this buildpack to the build report if
+// GOOGLE_BUILD_REPORT is enabled. Each buildpack rewrites the report, so it is complete once the
+// last buildpack finishes. Failures are logged as warnings since the report must not fail the build.
+func (ctx *Context) saveBuildReport() {
+	enabled, err := env.IsPresentAndTrue(env.BuildReport)
+	if err != nil {
+		ctx.Warnf("Failed to parse %s, skipping build report: %v", env.BuildReport, err)
+		return
+	}
+	if !enabled {
+		return
+	}
+	fname := buildReportPath()
+	if fname == "" {
+		ctx.Warnf("%s is not set, skipping build report.", builderOutputEnv)
+		return
+	}
+
+	var report BuildReport
+	content, err := os.ReadFile(fname)
+	if err != nil && !os.IsNotExist(err) {
+		ctx.Warnf("Failed to read %s, skipping build report: %v", fname, err)
+		return
+	}
+	if err == nil {
+		if err := json.Unmarshal(content, &report); err != nil {
+			ctx.Warnf("Failed to unmarshal %s, skipping build report: %v", fname, err)
+			return
+		}
+	}
+	report.merge(ctx.report, BuildpackReport{ID: ctx.BuildpackID(), Version: ctx.BuildpackVersion()})
+
+	content, err = json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		ctx.Warnf("Failed to marshal the build report, skipping: %v", err)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(fname), 0755); err != nil {
+		ctx.Warnf("Failed to create dir %s, skipping build report: %v", filepath.Dir(fname), err)
+		return
+	}
+	if err := os.WriteFile(fname, append(content, '\n'), 0644); err != nil {
+		ctx.Warnf("Failed to write %s, skipping build report: %v", fname, err)
+		return
+	}
+	ctx.Debugf("Updated build report %s.", fname)
+}
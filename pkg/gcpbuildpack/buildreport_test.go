@@ -0,0 +1,73 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/testdata"
+	"github.com/buildpacks/libcnb"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSaveBuildReport(t *testing.T) {
+	t.Setenv(env.BuildReport, "true")
+	outputDir := t.TempDir()
+	t.Setenv(builderOutputEnv, outputDir)
+	newContext := func(id string) *Context {
+		return NewContext(WithBuildpackInfo(libcnb.BuildpackInfo{ID: id, Version: "1.2.3"}))
+	}
+
+	// Simulate the buildpacks of a Node.js function running in order.
+	runtime := newContext("google.nodejs.runtime")
+	runtime.ReportRuntime("nodejs", "20.11.1")
+	runtime.saveBuildReport()
+
+	npm := newContext("google.nodejs.npm")
+	npm.ReportPackageManager("npm")
+	npm.saveBuildReport()
+
+	ff := newContext("google.nodejs.functions-framework")
+	ff.ReportFramework("functions-framework", "3.4.0", true)
+	ff.saveBuildReport()
+
+	got, err := os.ReadFile(filepath.Join(outputDir, BuildReportFile))
+	if err != nil {
+		t.Fatalf("reading build report: %v", err)
+	}
+	want, err := os.ReadFile(testdata.MustGetPath("testdata/build_report.json"))
+	if err != nil {
+		t.Fatalf("reading golden file: %v", err)
+	}
+	if diff := cmp.Diff(string(want), string(got)); diff != "" {
+		t.Errorf("build report mismatch (-want, +got):\n%s", diff)
+	}
+}
+
+func TestSaveBuildReportDisabled(t *testing.T) {
+	outputDir := t.TempDir()
+	t.Setenv(builderOutputEnv, outputDir)
+	ctx := NewContext(WithBuildpackInfo(libcnb.BuildpackInfo{ID: "google.nodejs.runtime"}))
+	ctx.ReportRuntime("nodejs", "20.11.1")
+
+	ctx.saveBuildReport()
+
+	if _, err := os.Stat(filepath.Join(outputDir, BuildReportFile)); !os.IsNotExist(err) {
+		t.Errorf("build report exists with %s unset, want no report (stat error: %v)", env.BuildReport, err)
+	}
+}
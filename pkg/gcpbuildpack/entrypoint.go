@@ -0,0 +1,140 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"encoding/json"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+)
+
+// shellSafeRegexp matches the arguments that do not need to be quoted in a shell command.
+var shellSafeRegexp = regexp.MustCompile(`^[a-zA-Z0-9@%_+=:,./-]+$`)
+
+// Entrypoint is the command of the web process, in shell form or in exec form.
+//
+// The shell form, e.g. the value of GOOGLE_ENTRYPOINT, a Procfile or app.yaml, is a bash command.
+// It is passed to bash unchanged when the container starts, so it is tokenized with the quoting
+// rules of bash, and env vars such as $PORT are expanded at launch, never during the build.
+//
+// The exec form, the JSON array of GOOGLE_ENTRYPOINT_JSON, is the program and its arguments. The
+// program is executed directly with the arguments exactly as given: they are neither tokenized nor
+// expanded, so they may contain spaces, quotes, $ or JSON.
+type Entrypoint struct {
+	// Source is where the entrypoint is declared, e.g. GOOGLE_ENTRYPOINT or Procfile.
+	Source string
+	// Shell is the command of the shell form.
+	Shell string
+	// Exec is the program and arguments of the exec form.
+	Exec []string
+}
+
+// HasEntrypoint returns true if GOOGLE_ENTRYPOINT or GOOGLE_ENTRYPOINT_JSON is set.
+func HasEntrypoint() bool {
+	return os.Getenv(env.Entrypoint) != "" || os.Getenv(env.EntrypointJSON) != ""
+}
+
+// Entrypoint returns the entrypoint set with GOOGLE_ENTRYPOINT or GOOGLE_ENTRYPOINT_JSON, or nil if
+// neither is set.
+func (ctx *Context) Entrypoint() (*Entrypoint, error) {
+	shell, exec := os.Getenv(env.Entrypoint), os.Getenv(env.EntrypointJSON)
+	if shell != "" && exec != "" {
+		return nil, UserErrorf("%s and %s cannot both be set", env.Entrypoint, env.EntrypointJSON)
+	}
+	if exec != "" {
+		var args []string
+		if err := json.Unmarshal([]byte(exec), &args); err != nil {
+			return nil, UserErrorf("parsing %s: it must be a JSON array of strings, e.g. [\"gunicorn\", \"-b\", \":8080\", \"main:app\"]: %v", env.EntrypointJSON, err)
+		}
+		if len(args) == 0 || args[0] == "" {
+			return nil, UserErrorf("%s must start with the program to execute", env.EntrypointJSON)
+		}
+		return &Entrypoint{Source: env.EntrypointJSON, Exec: args}, nil
+	}
+	if shell == "" {
+		return nil, nil
+	}
+	var args []string
+	if strings.HasPrefix(strings.TrimSpace(shell), "[") && json.Unmarshal([]byte(shell), &args) == nil {
+		ctx.Warnf("%s is a JSON array, which bash runs as a command. Set %s instead to execute the program with these arguments.", env.Entrypoint, env.EntrypointJSON)
+	} else if hasUnterminatedQuote(shell) {
+		ctx.Warnf("%s appears to have an unterminated quote. Set %s to pass arguments that contain quotes without shell quoting.", env.Entrypoint, env.EntrypointJSON)
+	}
+	return &Entrypoint{Source: env.Entrypoint, Shell: shell}, nil
+}
+
+// Command returns the command of a direct process that runs the entrypoint. The shell form is
+// executed by bash with `exec` so that the program replaces the shell and receives signals.
+func (e *Entrypoint) Command() []string {
+	if e.Exec != nil {
+		return e.Exec
+	}
+	return []string{"/bin/bash", "-c", "exec " + e.Shell}
+}
+
+// String returns the entrypoint as a shell command, quoting the arguments of the exec form that
+// bash would otherwise tokenize or expand.
+func (e *Entrypoint) String() string {
+	if e.Exec == nil {
+		return e.Shell
+	}
+	quoted := make([]string, len(e.Exec))
+	for i, arg := range e.Exec {
+		if shellSafeRegexp.MatchString(arg) {
+			quoted[i] = arg
+		} else {
+			quoted[i] = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+		}
+	}
+	return strings.Join(quoted, " ")
+}
+
+// AddEntrypointProcess adds the entrypoint as the default web process.
+func (ctx *Context) AddEntrypointProcess(e *Entrypoint) {
+	ctx.AddWebProcess(e.Command())
+}
+
+// hasUnterminatedQuote returns true if a single or double quote of the shell command is not
+// closed, ignoring comments. It does not follow quotes nested in command substitutions.
+func hasUnterminatedQuote(cmd string) bool {
+	var quote rune
+	escaped := false
+	wordStart := true
+	for _, c := range cmd {
+		switch {
+		case escaped:
+			escaped = false
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			}
+		case c == '\\':
+			escaped = true
+		case quote == '"':
+			if c == '"' {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '#' && wordStart:
+			return false
+		}
+		wordStart = quote == 0 && (c == ' ' || c == '\t' || c == ';' || c == '&' || c == '|')
+	}
+	return quote != 0
+}
@@ -0,0 +1,220 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/buildpacks/libcnb"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestEntrypoint(t *testing.T) {
+	testCases := []struct {
+		name    string
+		shell   string
+		exec    string
+		want    *Entrypoint
+		wantErr bool
+	}{
+		{
+			name: "not set",
+		},
+		{
+			name:  "shell form",
+			shell: "gunicorn -b :$PORT main:app",
+			want:  &Entrypoint{Source: env.Entrypoint, Shell: "gunicorn -b :$PORT main:app"},
+		},
+		{
+			name:  "shell form with quotes",
+			shell: `python -c "print('hello')"`,
+			want:  &Entrypoint{Source: env.Entrypoint, Shell: `python -c "print('hello')"`},
+		},
+		{
+			name: "exec form",
+			exec: `["gunicorn", "-b", ":8080", "main:app"]`,
+			want: &Entrypoint{Source: env.EntrypointJSON, Exec: []string{"gunicorn", "-b", ":8080", "main:app"}},
+		},
+		{
+			name: "exec form is not expanded",
+			exec: `["sh", "-c", "echo $PORT", "it's"]`,
+			want: &Entrypoint{Source: env.EntrypointJSON, Exec: []string{"sh", "-c", "echo $PORT", "it's"}},
+		},
+		{
+			name:    "both set",
+			shell:   "node index.js",
+			exec:    `["node", "index.js"]`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid JSON",
+			exec:    `node index.js`,
+			wantErr: true,
+		},
+		{
+			name:    "not an array of strings",
+			exec:    `["node", 1]`,
+			wantErr: true,
+		},
+		{
+			name:    "empty array",
+			exec:    `[]`,
+			wantErr: true,
+		},
+		{
+			name:    "empty program",
+			exec:    `["", "index.js"]`,
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.shell != "" {
+				t.Setenv(env.Entrypoint, tc.shell)
+			}
+			if tc.exec != "" {
+				t.Setenv(env.EntrypointJSON, tc.exec)
+			}
+			ctx := NewContext()
+
+			got, err := ctx.Entrypoint()
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("Entrypoint() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("Entrypoint() mismatch (-want +got):\n%s", diff)
+			}
+			if gotHas, wantHas := HasEntrypoint(), tc.shell != "" || tc.exec != ""; gotHas != wantHas {
+				t.Errorf("HasEntrypoint() = %t, want %t", gotHas, wantHas)
+			}
+		})
+	}
+}
+
+// TestEntrypointCommandCompatibility verifies that the shell form results in the same command
+// that the language buildpacks used to build for the entrypoints of each runtime.
+func TestEntrypointCommandCompatibility(t *testing.T) {
+	testCases := []struct {
+		name  string
+		shell string
+	}{
+		{name: "python", shell: "gunicorn -b :$PORT main:app"},
+		{name: "python with options", shell: "gunicorn --workers 2 --threads 8 --timeout 0 -b :${PORT} main:app"},
+		{name: "nodejs", shell: "node index.js"},
+		{name: "nodejs npm", shell: "npm start"},
+		{name: "java", shell: "java -jar app.jar"},
+		{name: "java with options", shell: "java $JAVA_OPTS -Dserver.port=$PORT -jar target/app.jar"},
+		{name: "go", shell: "./main"},
+		{name: "ruby", shell: "bundle exec rackup -p $PORT"},
+		{name: "php", shell: "php -S 0.0.0.0:$PORT index.php"},
+		{name: "dotnet", shell: "dotnet app.dll"},
+		{name: "quotes", shell: `sh -c 'echo "$PORT" && exec ./server'`},
+		{name: "env assignment", shell: "FOO=bar ./server --flag=\"a b\""},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(env.Entrypoint, tc.shell)
+			ctx := NewContext()
+			e, err := ctx.Entrypoint()
+			if err != nil {
+				t.Fatalf("Entrypoint() got error: %v", err)
+			}
+
+			ctx.AddEntrypointProcess(e)
+
+			want := []libcnb.Process{{
+				Type:      WebProcess,
+				Command:   "/bin/bash",
+				Arguments: []string{"-c", "exec " + tc.shell},
+				Direct:    true,
+				Default:   true,
+			}}
+			if diff := cmp.Diff(want, ctx.Processes()); diff != "" {
+				t.Errorf("AddEntrypointProcess(%q) mismatch (-want +got):\n%s", tc.shell, diff)
+			}
+			if got := e.String(); got != tc.shell {
+				t.Errorf("String() = %q, want %q", got, tc.shell)
+			}
+		})
+	}
+}
+
+func TestEntrypointExecForm(t *testing.T) {
+	testCases := []struct {
+		name       string
+		exec       []string
+		wantString string
+	}{
+		{
+			name:       "simple",
+			exec:       []string{"node", "index.js"},
+			wantString: "node index.js",
+		},
+		{
+			name:       "spaces and JSON",
+			exec:       []string{"gunicorn", "--log-config-json", `{"version": 1}`, "main:app"},
+			wantString: `gunicorn --log-config-json '{"version": 1}' main:app`,
+		},
+		{
+			name:       "dollar and single quote",
+			exec:       []string{"echo", "$PORT", "it's"},
+			wantString: `echo '$PORT' 'it'\''s'`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := &Entrypoint{Source: env.EntrypointJSON, Exec: tc.exec}
+			ctx := NewContext()
+
+			ctx.AddEntrypointProcess(e)
+
+			want := []libcnb.Process{{
+				Type:      WebProcess,
+				Command:   tc.exec[0],
+				Arguments: tc.exec[1:],
+				Direct:    true,
+				Default:   true,
+			}}
+			if diff := cmp.Diff(want, ctx.Processes()); diff != "" {
+				t.Errorf("AddEntrypointProcess(%q) mismatch (-want +got):\n%s", tc.exec, diff)
+			}
+			if got := e.String(); got != tc.wantString {
+				t.Errorf("String() = %q, want %q", got, tc.wantString)
+			}
+		})
+	}
+}
+
+func TestHasUnterminatedQuote(t *testing.T) {
+	testCases := []struct {
+		cmd  string
+		want bool
+	}{
+		{cmd: "node index.js", want: false},
+		{cmd: `python -c "print('hi')"`, want: false},
+		{cmd: `echo 'it'\''s'`, want: false},
+		{cmd: `echo it\'s`, want: false},
+		{cmd: `./server # it's`, want: false},
+		{cmd: `python -c "print('hi')`, want: true},
+		{cmd: `echo it's`, want: true},
+		{cmd: `echo "a\"`, want: true},
+	}
+	for _, tc := range testCases {
+		if got := hasUnterminatedQuote(tc.cmd); got != tc.want {
+			t.Errorf("hasUnterminatedQuote(%q) = %t, want %t", tc.cmd, got, tc.want)
+		}
+	}
+}
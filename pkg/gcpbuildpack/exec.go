@@ -50,6 +50,7 @@ type execParams struct {
 	messageProducer    MessageProducer
 	logCommandOverride *bool
 	logOutputOverride  *bool
+	sensitiveOutput    bool
 }
 
 // ExecOption configures Exec functions.
@@ -103,6 +104,12 @@ func WithLogOutput(shouldLog bool) ExecOption {
 	}
 }
 
+// WithSensitiveOutput indicates that the command's output may contain secrets: it is never logged
+// and the error message only reports the exit code. This takes precedence over any other options.
+var WithSensitiveOutput = func(o *execParams) {
+	o.sensitiveOutput = true
+}
+
 // WithCombinedTail keeps the tail of the combined stdout/stderr for the error message.
 var WithCombinedTail = WithMessageProducer(KeepCombinedTail)
 
@@ -122,6 +129,8 @@ var WithStdoutTail = WithMessageProducer(KeepStdoutTail)
 var WithStdoutHead = WithMessageProducer(KeepStdoutHead)
 
 // Exec runs the given command (with args) under the default configuration, allowing the caller to handle the error.
+// The values of sensitive env vars, such as tokens and passwords, are redacted from the logged
+// command line and output and from the returned error, but not from the returned ExecResult.
 func (ctx *Context) Exec(cmd []string, opts ...ExecOption) (*ExecResult, error) {
 	params := execParams{cmd: cmd, messageProducer: KeepCombinedTail}
 	for _, o := range opts {
@@ -130,7 +139,8 @@ func (ctx *Context) Exec(cmd []string, opts ...ExecOption) (*ExecResult, error)
 
 	start := time.Now()
 
-	result, err := ctx.configuredExec(params)
+	r := newRedactor(params.env)
+	result, err := ctx.configuredExec(params, r)
 
 	if params.userTiming {
 		ctx.stats.user += time.Since(start)
@@ -142,7 +152,11 @@ func (ctx *Context) Exec(cmd []string, opts ...ExecOption) (*ExecResult, error)
 
 	message := err.Error()
 	if result != nil {
-		message = params.messageProducer(result)
+		if params.sensitiveOutput {
+			message = fmt.Sprintf("exit code %d, output not shown because it may contain secrets", result.ExitCode)
+		} else {
+			message = r.redact(params.messageProducer(result))
+		}
 	}
 
 	var be *buildererror.Error
@@ -156,7 +170,7 @@ func (ctx *Context) Exec(cmd []string, opts ...ExecOption) (*ExecResult, error)
 	return result, be
 }
 
-func (ctx *Context) configuredExec(params execParams) (*ExecResult, error) {
+func (ctx *Context) configuredExec(params execParams, r *redactor) (*ExecResult, error) {
 	if len(params.cmd) < 1 {
 		return nil, fmt.Errorf("no command provided")
 	}
@@ -172,9 +186,10 @@ func (ctx *Context) configuredExec(params execParams) (*ExecResult, error) {
 
 	readableCmd := strings.Join(params.cmd, " ")
 	if len(params.env) > 0 {
-		env := strings.Join(params.env, " ")
+		env := strings.Join(redactEnv(params.env), " ")
 		readableCmd = fmt.Sprintf("%s (%s)", readableCmd, env)
 	}
+	readableCmd = r.redact(readableCmd)
 
 	logCmd := defaultShouldLog
 	if params.logCommandOverride != nil {
@@ -195,7 +210,7 @@ func (ctx *Context) configuredExec(params execParams) (*ExecResult, error) {
 		if logCmd {
 			ctx.Logf("Done %q (%v)", truncated, time.Since(start))
 		}
-		ctx.Span(ctx.createSpanName(params.cmd), start, status)
+		ctx.Span(r.redact(ctx.createSpanName(params.cmd)), start, status)
 	}(time.Now())
 
 	exitCode := 0
@@ -213,8 +228,11 @@ func (ctx *Context) configuredExec(params execParams) (*ExecResult, error) {
 	if params.logOutputOverride != nil {
 		logOutput = *params.logOutputOverride
 	}
+	if params.sensitiveOutput {
+		logOutput = false
+	}
 	var outb, errb bytes.Buffer
-	combinedb := lockingBuffer{ctx: ctx, log: logOutput}
+	combinedb := lockingBuffer{ctx: ctx, log: logOutput, redactor: r}
 	ecmd.Stdout = io.MultiWriter(&outb, &combinedb)
 	ecmd.Stderr = io.MultiWriter(&errb, &combinedb)
 
@@ -259,13 +277,15 @@ type lockingBuffer struct {
 	// log tells the buffer to also log the output to stderr.
 	log bool
 	ctx *Context
+	// redactor removes secrets from the logged output. Values split across writes are not redacted.
+	redactor *redactor
 }
 
 func (lb *lockingBuffer) Write(p []byte) (int, error) {
 	lb.Lock()
 	defer lb.Unlock()
 	if lb.log {
-		lb.ctx.Logf(string(p))
+		lb.ctx.Logf(lb.redactor.redact(string(p)))
 	}
 	return lb.buf.Write(p)
 }
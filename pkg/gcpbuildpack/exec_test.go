@@ -673,3 +673,89 @@ func (e *fakeExiter) Exit(exitCode int, be *buildererror.Error) {
 	e.code = exitCode
 	e.err = be
 }
+
+func TestExecRedactsSecrets(t *testing.T) {
+	const secret = "s3cr3t-t0ken"
+	testCases := []struct {
+		name           string
+		cmd            []string
+		opts           []ExecOption
+		processEnv     string
+		wantErr        bool
+		wantErrMessage string
+		wantLogged     []string
+	}{
+		{
+			name: "success logs",
+			cmd:  []string{"bash", "-c", "echo token is $NPM_TOKEN"},
+			opts: []ExecOption{WithUserAttribution, WithEnv("NPM_TOKEN="+secret, "PORT=8080")},
+			wantLogged: []string{
+				`(NPM_TOKEN=[REDACTED] PORT=8080)`,
+				"token is [REDACTED]",
+			},
+		},
+		{
+			name:           "failure message",
+			cmd:            []string{"bash", "-c", "echo bad password $DB_PASSWORD; exit 1"},
+			opts:           []ExecOption{WithUserAttribution, WithEnv("DB_PASSWORD=" + secret)},
+			wantErr:        true,
+			wantErrMessage: "bad password [REDACTED]",
+			wantLogged:     []string{"bad password [REDACTED]"},
+		},
+		{
+			name:           "secret from process env in command line",
+			cmd:            []string{"bash", "-c", "echo --auth=" + secret + "; exit 2"},
+			opts:           []ExecOption{WithUserAttribution},
+			processEnv:     secret,
+			wantErr:        true,
+			wantErrMessage: "--auth=[REDACTED]",
+			wantLogged:     []string{`Running "bash -c echo --auth=[REDACTED]; exit 2"`},
+		},
+		{
+			name:           "index url",
+			cmd:            []string{"bash", "-c", "echo $PIP_INDEX_URL; exit 1"},
+			opts:           []ExecOption{WithEnv("PIP_INDEX_URL=https://user:" + secret + "@example.com/simple")},
+			wantErr:        true,
+			wantErrMessage: "[REDACTED]",
+		},
+		{
+			name:           "sensitive output",
+			cmd:            []string{"bash", "-c", "echo s3cr3t-$(echo t0ken); exit 3"},
+			opts:           []ExecOption{WithUserAttribution, WithSensitiveOutput},
+			wantErr:        true,
+			wantErrMessage: "exit code 3, output not shown because it may contain secrets",
+			wantLogged:     []string{"Running"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.processEnv != "" {
+				t.Setenv("GOOGLE_TEST_ACCESS_TOKEN", tc.processEnv)
+			}
+			buf := new(bytes.Buffer)
+			ctx := NewContext(WithLogger(log.New(buf, "", 0)))
+
+			_, err := ctx.execWithErrCastToBuildError(tc.cmd, tc.opts...)
+			if got := err != nil; got != tc.wantErr {
+				t.Fatalf("Exec(%q) got error %v, want error %t", tc.cmd, err, tc.wantErr)
+			}
+			if tc.wantErr {
+				if err.Message != tc.wantErrMessage {
+					t.Errorf("Exec(%q) error message = %q, want %q", tc.cmd, err.Message, tc.wantErrMessage)
+				}
+				if strings.Contains(err.Error(), secret) {
+					t.Errorf("Exec(%q) error %q contains the secret", tc.cmd, err.Error())
+				}
+			}
+			gotOut := buf.String()
+			if strings.Contains(gotOut, secret) {
+				t.Errorf("Exec(%q) logged the secret: %s", tc.cmd, gotOut)
+			}
+			for _, want := range tc.wantLogged {
+				if !strings.Contains(gotOut, want) {
+					t.Errorf("Exec(%q) missing expected logs, got logs: %s\nshould contain: %q", tc.cmd, gotOut, want)
+				}
+			}
+		})
+	}
+}
@@ -53,11 +53,16 @@ const (
 
 	// WebProcess is the name of the default web process.
 	WebProcess = "web"
+	// ReleaseProcess is the name of the process that platforms supporting it run before routing
+	// traffic to a new release of the application, e.g. to run database migrations.
+	ReleaseProcess = "release"
 )
 
 var (
 	defaultLogger  = log.New(os.Stderr, "", 0)
 	labelKeyRegexp = regexp.MustCompile(labelKeyRegexpStr)
+	// processTypeRegexp matches the process types allowed by the buildpacks spec.
+	processTypeRegexp = regexp.MustCompile(`\A[A-Za-z0-9_.-]+\z`)
 )
 
 // DetectFn is the callback signature for Detect()
@@ -75,6 +80,7 @@ type stats struct {
 type Context struct {
 	info                     libcnb.BuildpackInfo
 	applicationRoot          string
+	workspaceRoot            string
 	buildpackRoot            string
 	debug                    bool
 	logger                   *log.Logger
@@ -82,6 +88,7 @@ type Context struct {
 	stats                    stats
 	exiter                   Exiter
 	warnings                 []string
+	report                   BuildReport
 
 	// detect items
 	detectContext libcnb.DetectContext
@@ -103,6 +110,13 @@ func WithApplicationRoot(root string) ContextOption {
 	}
 }
 
+// WithWorkspaceRoot sets the workspace root in Context.
+func WithWorkspaceRoot(root string) ContextOption {
+	return func(ctx *Context) {
+		ctx.workspaceRoot = root
+	}
+}
+
 // WithBuildpackRoot sets the buildpack root in Context.
 func WithBuildpackRoot(root string) ContextOption {
 	return func(ctx *Context) {
@@ -248,7 +262,16 @@ func (gcpd gcpdetector) Detect(ldctx libcnb.DetectContext) (libcnb.DetectResult,
 		ctx.Span(fmt.Sprintf("Buildpack Detect %s", ctx.info.ID), now, status)
 	}(time.Now())
 
-	result, err := gcpd.detectFn(ctx)
+	if err := ctx.applyProjectTOML(false); err != nil {
+		return libcnb.DetectResult{}, err
+	}
+	if err := ctx.applyApplicationRoot(); err != nil {
+		return libcnb.DetectResult{}, err
+	}
+	result, err := ctx.skipDetect()
+	if err == nil && result == nil {
+		result, err = gcpd.detectFn(ctx)
+	}
 	if err != nil {
 		msg := fmt.Sprintf("Failed to run /bin/detect: %v", err)
 		var be *buildererror.Error
@@ -288,7 +311,26 @@ func (gcpb gcpbuilder) Build(lbctx libcnb.BuildContext) (libcnb.BuildResult, err
 		ctx.Span(fmt.Sprintf("Buildpack Build %s", ctx.BuildpackID()), now, status)
 	}(time.Now())
 
-	if err := gcpb.buildFn(ctx); err != nil {
+	err := ctx.applyProjectTOML(true)
+	if err == nil {
+		err = ctx.applyApplicationRoot()
+	}
+	if err == nil && ctx.ApplicationRoot() != ctx.WorkspaceRoot() {
+		ctx.Logf("Using application root %s within workspace %s.", ctx.ApplicationRoot(), ctx.WorkspaceRoot())
+	}
+	if err == nil {
+		err = validateDefaultProcessType()
+	}
+	if err == nil {
+		err = gcpb.buildFn(ctx)
+	}
+	if err == nil {
+		err = ctx.normalizeLayerTimes()
+	}
+	if err == nil {
+		err = ctx.checkLayerSizes()
+	}
+	if err != nil {
 		var be *buildererror.Error
 		if errors.As(err, &be) {
 			status = be.Status
@@ -299,6 +341,7 @@ func (gcpb gcpbuilder) Build(lbctx libcnb.BuildContext) (libcnb.BuildResult, err
 
 	status = buildererror.StatusOk
 	ctx.saveSuccessOutput(time.Since(start))
+	ctx.saveBuildReport()
 	return ctx.buildResult, nil
 }
 
@@ -399,7 +442,14 @@ func AsDefaultProcess() processOption {
 }
 
 // AddProcess adds the given command as named process, overwriting any previous process with the same name.
+// If GOOGLE_DEFAULT_PROCESS_TYPE is set, the web process is added under that type instead and marked
+// as the default process. The process runs from the application root if it differs from the workspace.
 func (ctx *Context) AddProcess(name string, cmd []string, opts ...processOption) {
+	if t := os.Getenv(env.DefaultProcessType); name == WebProcess && t != "" && t != WebProcess {
+		ctx.Logf("Adding the %s process as %q from %s.", WebProcess, t, env.DefaultProcessType)
+		name = t
+		opts = append(opts, AsDefaultProcess())
+	}
 	current := ctx.buildResult.Processes
 	ctx.buildResult.Processes = []libcnb.Process{}
 	for _, p := range current {
@@ -413,6 +463,11 @@ func (ctx *Context) AddProcess(name string, cmd []string, opts ...processOption)
 		Type:    name,
 		Command: cmd[0],
 	}
+	// Processes run from the workspace by default, so relative commands, e.g. `gunicorn main:app`,
+	// must run from the application root when GOOGLE_APPLICATION_ROOT selects a subdirectory.
+	if ctx.ApplicationRoot() != ctx.WorkspaceRoot() {
+		p.WorkingDirectory = ctx.ApplicationRoot()
+	}
 	if len(cmd) > 1 {
 		p.Arguments = cmd[1:]
 	}
@@ -422,6 +477,48 @@ func (ctx *Context) AddProcess(name string, cmd []string, opts ...processOption)
 	ctx.buildResult.Processes = append(ctx.buildResult.Processes, p)
 }
 
+// AddReleaseProcess adds the release process, which runs the command set with GOOGLE_RELEASE_COMMAND
+// or, if it is not set, the given command detected from the conventions of the application. No
+// process is added if neither is set. The release process is never the default process.
+func (ctx *Context) AddReleaseProcess(detected []string) {
+	if cmd := os.Getenv(env.ReleaseCommand); cmd != "" {
+		ctx.Logf("Adding %s process from %s: %q", ReleaseProcess, env.ReleaseCommand, cmd)
+		ctx.AddProcess(ReleaseProcess, []string{cmd})
+		return
+	}
+	if len(detected) == 0 {
+		return
+	}
+	ctx.Logf("Adding %s process: %q", ReleaseProcess, strings.Join(detected, " "))
+	ctx.AddProcess(ReleaseProcess, detected, AsDirectProcess())
+}
+
+// validateDefaultProcessType returns a user error if GOOGLE_DEFAULT_PROCESS_TYPE is not a valid process type.
+func validateDefaultProcessType() error {
+	t := os.Getenv(env.DefaultProcessType)
+	if t == "" || processTypeRegexp.MatchString(t) {
+		return nil
+	}
+	return UserErrorf("invalid %s %q: process types may only contain letters, digits, '.', '_' and '-'", env.DefaultProcessType, t)
+}
+
+// AddBOMEntry adds an entry to the bill of materials, which is written to the
+// io.buildpacks.build.metadata label of the image.
+func (ctx *Context) AddBOMEntry(entry libcnb.BOMEntry) {
+	if ctx.buildResult.BOM == nil {
+		ctx.buildResult.BOM = &libcnb.BOM{}
+	}
+	ctx.buildResult.BOM.Entries = append(ctx.buildResult.BOM.Entries, entry)
+}
+
+// BOMEntries returns the entries of the bill of materials added by buildpacks.
+func (ctx *Context) BOMEntries() []libcnb.BOMEntry {
+	if ctx.buildResult.BOM == nil {
+		return nil
+	}
+	return ctx.buildResult.BOM.Entries
+}
+
 // HTTPStatus returns the status code for a url.
 func (ctx *Context) HTTPStatus(url string) (int, error) {
 	res, err := http.Head(url)
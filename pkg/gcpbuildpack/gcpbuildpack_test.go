@@ -267,14 +267,27 @@ func TestAddWebProcess(t *testing.T) {
 	}
 }
 
+func TestAddWebProcessWithDefaultProcessType(t *testing.T) {
+	t.Setenv(env.DefaultProcessType, "worker")
+	ctx := NewContext()
+	ctx.AddWebProcess([]string{"/start"})
+	want := []libcnb.Process{proc("/start", "worker")}
+
+	if !reflect.DeepEqual(ctx.buildResult.Processes, want) {
+		t.Errorf("Processes not equal got %#v, want %#v", ctx.buildResult.Processes, want)
+	}
+}
+
 func TestAddProcess(t *testing.T) {
 	testCases := []struct {
-		desc    string
-		name    string
-		cmd     []string
-		opts    []processOption
-		initial []libcnb.Process
-		want    []libcnb.Process
+		desc               string
+		name               string
+		cmd                []string
+		opts               []processOption
+		defaultProcessType string
+		appRoot            string
+		initial            []libcnb.Process
+		want               []libcnb.Process
 	}{
 		{
 			desc: "no args, no processes",
@@ -356,11 +369,74 @@ func TestAddProcess(t *testing.T) {
 				libcnb.Process{Command: "/start", Arguments: []string{"arg1", "arg2"}, Type: "foo", Direct: true, Default: true},
 			},
 		},
+		{
+			desc:               "web with default process type",
+			name:               "web",
+			cmd:                []string{"/start", "arg1"},
+			defaultProcessType: "worker",
+			want: []libcnb.Process{
+				libcnb.Process{Command: "/start", Arguments: []string{"arg1"}, Type: "worker", Default: true},
+			},
+		},
+		{
+			desc:               "web with default process type overrides existing",
+			name:               "web",
+			cmd:                []string{"/OVERRIDE"},
+			defaultProcessType: "worker",
+			initial: []libcnb.Process{
+				libcnb.Process{Command: "/worker", Type: "worker"},
+				libcnb.Process{Command: "/cli", Type: "cli"},
+			},
+			want: []libcnb.Process{
+				libcnb.Process{Command: "/cli", Type: "cli"},
+				libcnb.Process{Command: "/OVERRIDE", Type: "worker", Default: true},
+			},
+		},
+		{
+			desc:               "web default process type",
+			name:               "web",
+			cmd:                []string{"/start"},
+			defaultProcessType: "web",
+			want: []libcnb.Process{
+				libcnb.Process{Command: "/start", Type: "web"},
+			},
+		},
+		{
+			desc:    "application root in workspace subdirectory",
+			name:    "web",
+			cmd:     []string{"gunicorn", "main:app"},
+			appRoot: "/workspace/services/api",
+			want: []libcnb.Process{
+				libcnb.Process{Command: "gunicorn", Arguments: []string{"main:app"}, Type: "web", WorkingDirectory: "/workspace/services/api"},
+			},
+		},
+		{
+			desc:    "application root is workspace",
+			name:    "web",
+			cmd:     []string{"gunicorn", "main:app"},
+			appRoot: "/workspace",
+			want: []libcnb.Process{
+				libcnb.Process{Command: "gunicorn", Arguments: []string{"main:app"}, Type: "web"},
+			},
+		},
+		{
+			desc:               "other process with default process type",
+			name:               "foo",
+			cmd:                []string{"/start"},
+			defaultProcessType: "worker",
+			want: []libcnb.Process{
+				libcnb.Process{Command: "/start", Type: "foo"},
+			},
+		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.desc, func(t *testing.T) {
+			t.Setenv(env.DefaultProcessType, tc.defaultProcessType)
 			ctx := NewContext()
+			if tc.appRoot != "" {
+				ctx = NewContext(WithApplicationRoot(tc.appRoot), WithWorkspaceRoot("/workspace"))
+			}
 			ctx.buildResult.Processes = tc.initial
 
 			ctx.AddProcess(tc.name, tc.cmd, tc.opts...)
@@ -372,6 +448,76 @@ func TestAddProcess(t *testing.T) {
 	}
 }
 
+func TestAddReleaseProcess(t *testing.T) {
+	testCases := []struct {
+		desc           string
+		detected       []string
+		releaseCommand string
+		want           []libcnb.Process
+	}{
+		{
+			desc: "nothing detected",
+		},
+		{
+			desc:     "detected command",
+			detected: []string{"bundle", "exec", "rails", "db:migrate"},
+			want: []libcnb.Process{
+				{Type: "release", Command: "bundle", Arguments: []string{"exec", "rails", "db:migrate"}, Direct: true},
+			},
+		},
+		{
+			desc:           "release command overrides detected command",
+			detected:       []string{"bundle", "exec", "rails", "db:migrate"},
+			releaseCommand: "bin/rails db:prepare && bin/rails data:migrate",
+			want: []libcnb.Process{
+				{Type: "release", Command: "bin/rails db:prepare && bin/rails data:migrate"},
+			},
+		},
+		{
+			desc:           "release command without detected command",
+			releaseCommand: "./migrate.sh",
+			want: []libcnb.Process{
+				{Type: "release", Command: "./migrate.sh"},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Setenv(env.ReleaseCommand, tc.releaseCommand)
+			// The release process is not affected by the default process type.
+			t.Setenv(env.DefaultProcessType, "worker")
+			ctx := NewContext()
+
+			ctx.AddReleaseProcess(tc.detected)
+
+			if !reflect.DeepEqual(ctx.buildResult.Processes, tc.want) {
+				t.Errorf("Processes not equal got %#v, want %#v", ctx.buildResult.Processes, tc.want)
+			}
+		})
+	}
+}
+
+func TestValidateDefaultProcessType(t *testing.T) {
+	testCases := []struct {
+		processType string
+		wantErr     bool
+	}{
+		{processType: ""},
+		{processType: "worker"},
+		{processType: "my-app_v1.2"},
+		{processType: "my worker", wantErr: true},
+		{processType: "worker/1", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.processType, func(t *testing.T) {
+			t.Setenv(env.DefaultProcessType, tc.processType)
+			if err := validateDefaultProcessType(); (err != nil) != tc.wantErr {
+				t.Errorf("validateDefaultProcessType() with %q got error: %v, want error: %v", tc.processType, err, tc.wantErr)
+			}
+		})
+	}
+}
+
 func TestAddLabel(t *testing.T) {
 	testCases := []struct {
 		name      string
@@ -0,0 +1,152 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+)
+
+// userLabelPrefix is prepended to user-provided label keys so they cannot collide with labels
+// added by buildpacks. Combined with AddLabel, a key "owner" becomes "google.user-owner".
+const userLabelPrefix = "user-"
+
+type userLabel struct {
+	key   string
+	value string
+}
+
+// AddUserLabels adds the labels configured by GOOGLE_LABELS and GOOGLE_LABELS_JSON to the image. It
+// is called by the label-image buildpack only, so that the labels are added once per build.
+func AddUserLabels(ctx *Context) error {
+	var labels []userLabel
+	if v := os.Getenv(env.Labels); v != "" {
+		l, err := parseLabels(v)
+		if err != nil {
+			return UserErrorf("parsing %s: %v", env.Labels, err)
+		}
+		labels = append(labels, l...)
+	}
+	if v := os.Getenv(env.LabelsJSON); v != "" {
+		l, err := parseLabelsJSON(v)
+		if err != nil {
+			return UserErrorf("parsing %s: %v", env.LabelsJSON, err)
+		}
+		labels = append(labels, l...)
+	}
+
+	seen := make(map[string]bool, len(labels))
+	for _, l := range labels {
+		if err := validateLabelKey(l.key); err != nil {
+			return UserErrorf("invalid label %q: %v", l.key, err)
+		}
+		if seen[l.key] {
+			return UserErrorf("label %q is set more than once in %s and %s", l.key, env.Labels, env.LabelsJSON)
+		}
+		seen[l.key] = true
+	}
+	for _, l := range labels {
+		ctx.AddLabel(userLabelPrefix+l.key, l.value)
+	}
+	return nil
+}
+
+// validateLabelKey returns an error if the key would be rejected by AddLabel.
+func validateLabelKey(key string) error {
+	if !labelKeyRegexp.MatchString(key) {
+		return fmt.Errorf("key must match %s", labelKeyRegexpStr)
+	}
+	if strings.Contains(key, "__") {
+		return fmt.Errorf("key must not contain consecutive underscores")
+	}
+	return nil
+}
+
+// parseLabels parses comma-separated key=value pairs. Values containing commas or equals signs can
+// be wrapped in double quotes, in which a literal double quote is written as \".
+func parseLabels(s string) ([]userLabel, error) {
+	var labels []userLabel
+	for i := 0; i < len(s); {
+		eq := strings.IndexByte(s[i:], '=')
+		if eq < 0 {
+			return nil, fmt.Errorf("entry %q is not in key=value format", s[i:])
+		}
+		key := strings.TrimSpace(s[i : i+eq])
+		i += eq + 1
+
+		var value string
+		if i < len(s) && s[i] == '"' {
+			var b strings.Builder
+			closed := false
+			for i++; i < len(s); i++ {
+				if s[i] == '\\' && i+1 < len(s) && s[i+1] == '"' {
+					b.WriteByte('"')
+					i++
+					continue
+				}
+				if s[i] == '"' {
+					closed = true
+					i++
+					break
+				}
+				b.WriteByte(s[i])
+			}
+			if !closed {
+				return nil, fmt.Errorf("entry %q has an unterminated quoted value", key)
+			}
+			if i < len(s) && s[i] != ',' {
+				return nil, fmt.Errorf("entry %q has unexpected characters after its quoted value", key)
+			}
+			value = b.String()
+		} else {
+			end := strings.IndexByte(s[i:], ',')
+			if end < 0 {
+				end = len(s) - i
+			}
+			value = s[i : i+end]
+			i += end
+			if strings.Contains(value, "=") {
+				return nil, fmt.Errorf("entry %q has an unquoted value containing '='", key)
+			}
+		}
+		if key == "" {
+			return nil, fmt.Errorf("entry with value %q has an empty key", value)
+		}
+		labels = append(labels, userLabel{key: key, value: value})
+		// Skip the separating comma.
+		i++
+	}
+	return labels, nil
+}
+
+// parseLabelsJSON parses a JSON object of string keys and values.
+func parseLabelsJSON(s string) ([]userLabel, error) {
+	var m map[string]string
+	if err := json.Unmarshal([]byte(s), &m); err != nil {
+		return nil, fmt.Errorf("expected a JSON object of string values: %v", err)
+	}
+	labels := make([]userLabel, 0, len(m))
+	for k, v := range m {
+		labels = append(labels, userLabel{key: k, value: v})
+	}
+	// Map iteration order is random, keep the applied labels stable.
+	sort.Slice(labels, func(i, j int) bool { return labels[i].key < labels[j].key })
+	return labels, nil
+}
@@ -0,0 +1,142 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/buildpacks/libcnb"
+)
+
+func TestAddUserLabels(t *testing.T) {
+	testCases := []struct {
+		name       string
+		labels     string
+		labelsJSON string
+		want       []libcnb.Label
+		wantErr    string
+	}{
+		{
+			name: "no labels",
+		},
+		{
+			name:   "simple",
+			labels: "owner=payments,service_id=checkout",
+			want: []libcnb.Label{
+				{Key: "google.user-owner", Value: "payments"},
+				{Key: "google.user-service-id", Value: "checkout"},
+			},
+		},
+		{
+			name:   "quoted values",
+			labels: `description="a, b=c",quote="say \"hi\"",empty=`,
+			want: []libcnb.Label{
+				{Key: "google.user-description", Value: "a, b=c"},
+				{Key: "google.user-quote", Value: `say "hi"`},
+				{Key: "google.user-empty", Value: ""},
+			},
+		},
+		{
+			name:       "json",
+			labelsJSON: `{"team": "x, y", "owner": "a=b"}`,
+			want: []libcnb.Label{
+				{Key: "google.user-owner", Value: "a=b"},
+				{Key: "google.user-team", Value: "x, y"},
+			},
+		},
+		{
+			name:       "both",
+			labels:     "owner=payments",
+			labelsJSON: `{"team": "checkout"}`,
+			want: []libcnb.Label{
+				{Key: "google.user-owner", Value: "payments"},
+				{Key: "google.user-team", Value: "checkout"},
+			},
+		},
+		{
+			name:    "missing equals",
+			labels:  "owner=payments,team",
+			wantErr: `entry "team" is not in key=value format`,
+		},
+		{
+			name:    "empty key",
+			labels:  "=payments",
+			wantErr: "empty key",
+		},
+		{
+			name:    "invalid key",
+			labels:  "owner=payments,1team=checkout",
+			wantErr: `invalid label "1team"`,
+		},
+		{
+			name:    "double underscore",
+			labels:  "my__team=checkout",
+			wantErr: `invalid label "my__team"`,
+		},
+		{
+			name:    "unterminated quote",
+			labels:  `owner="payments`,
+			wantErr: `entry "owner" has an unterminated quoted value`,
+		},
+		{
+			name:    "trailing characters after quote",
+			labels:  `owner="pay"ments`,
+			wantErr: `entry "owner" has unexpected characters`,
+		},
+		{
+			name:    "unquoted equals",
+			labels:  "owner=a=b",
+			wantErr: `entry "owner" has an unquoted value containing '='`,
+		},
+		{
+			name:       "duplicate",
+			labels:     "owner=payments",
+			labelsJSON: `{"owner": "checkout"}`,
+			wantErr:    `label "owner" is set more than once`,
+		},
+		{
+			name:       "invalid json",
+			labelsJSON: `{"owner": 1}`,
+			wantErr:    env.LabelsJSON,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(env.Labels, tc.labels)
+			t.Setenv(env.LabelsJSON, tc.labelsJSON)
+			ctx := NewContext()
+
+			err := AddUserLabels(ctx)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("AddUserLabels() got error %v, want error containing %q", err, tc.wantErr)
+				}
+				if len(ctx.buildResult.Labels) > 0 {
+					t.Errorf("AddUserLabels() added labels %v on error", ctx.buildResult.Labels)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("AddUserLabels() got error: %v", err)
+			}
+			if !reflect.DeepEqual(ctx.buildResult.Labels, tc.want) {
+				t.Errorf("AddUserLabels() labels got %#v, want %#v", ctx.buildResult.Labels, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+)
+
+const bytesPerMB = 1024 * 1024
+
+// checkLayerSizes measures the launch layers created by the buildpack and reports a warning, or an
+// error when env.MaxImageLayerStrict is set, if their total size is larger than env.MaxImageLayerMB.
+// Large layers usually come from a misconfigured build, such as development dependencies that were
+// not pruned or caches that were written to a launch layer.
+func (ctx *Context) checkLayerSizes() error {
+	limit := os.Getenv(env.MaxImageLayerMB)
+	if limit == "" {
+		return nil
+	}
+	limitMB, err := strconv.Atoi(limit)
+	if err != nil || limitMB <= 0 {
+		return UserErrorf("%s=%q must be a positive integer", env.MaxImageLayerMB, limit)
+	}
+	strict, err := env.IsPresentAndTrue(env.MaxImageLayerStrict)
+	if err != nil {
+		return UserErrorf("parsing %s: %w", env.MaxImageLayerStrict, err)
+	}
+
+	type layerSize struct {
+		name string
+		size int64
+	}
+	var sizes []layerSize
+	var total int64
+	for _, c := range ctx.buildResult.Layers {
+		lc, ok := c.(layerContributor)
+		if !ok || !lc.l.Launch {
+			continue
+		}
+		size, err := dirSize(lc.l.Path)
+		if err != nil {
+			return InternalErrorf("measuring the size of %s: %w", lc.l.Path, err)
+		}
+		sizes = append(sizes, layerSize{lc.l.Name, size})
+		total += size
+	}
+	if total <= int64(limitMB)*bytesPerMB {
+		return nil
+	}
+
+	sort.SliceStable(sizes, func(i, j int) bool { return sizes[i].size > sizes[j].size })
+	var details []string
+	for _, s := range sizes {
+		details = append(details, fmt.Sprintf("%s: %s", s.name, formatMB(s.size)))
+	}
+	msg := fmt.Sprintf("the launch layers of %s are %s, more than the %d MB allowed by %s (%s)", ctx.BuildpackID(), formatMB(total), limitMB, env.MaxImageLayerMB, strings.Join(details, ", "))
+	if strict {
+		return UserErrorf("%s: reduce the size of the application, for example by excluding development dependencies and generated files, or increase %s", msg, env.MaxImageLayerMB)
+	}
+	ctx.Warnf("%s. Set %s=true to fail the build instead.", msg, env.MaxImageLayerStrict)
+	return nil
+}
+
+// dirSize returns the total size of the regular files under dir. Symlinks are not followed.
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	return size, err
+}
+
+func formatMB(size int64) string {
+	return fmt.Sprintf("%.1f MB", float64(size)/bytesPerMB)
+}
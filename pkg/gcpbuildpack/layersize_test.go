@@ -0,0 +1,113 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/buildpacks/libcnb"
+)
+
+func TestCheckLayerSizes(t *testing.T) {
+	testCases := []struct {
+		name        string
+		maxMB       string
+		strict      string
+		wantWarning bool
+		wantErr     bool
+	}{
+		{
+			name: "not set",
+		},
+		{
+			name:  "under the limit",
+			maxMB: "3",
+		},
+		{
+			name:        "over the limit",
+			maxMB:       "1",
+			wantWarning: true,
+		},
+		{
+			name:    "over the limit strict",
+			maxMB:   "1",
+			strict:  "true",
+			wantErr: true,
+		},
+		{
+			name:   "under the limit strict",
+			maxMB:  "3",
+			strict: "true",
+		},
+		{
+			name:    "invalid limit",
+			maxMB:   "1GB",
+			wantErr: true,
+		},
+		{
+			name:    "invalid strict",
+			maxMB:   "1",
+			strict:  "maybe",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.maxMB != "" {
+				t.Setenv(env.MaxImageLayerMB, tc.maxMB)
+			}
+			if tc.strict != "" {
+				t.Setenv(env.MaxImageLayerStrict, tc.strict)
+			}
+			ctx := NewContext(WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: t.TempDir()}}))
+			// The launch layers add up to 2 MB, the 4 MB cache layer is not part of the image.
+			for _, l := range []struct {
+				name string
+				size int
+				opt  layerOption
+			}{
+				{"launch", bytesPerMB + bytesPerMB/2, LaunchLayer},
+				{"other-launch", bytesPerMB / 2, LaunchLayer},
+				{"cache", 4 * bytesPerMB, CacheLayer},
+			} {
+				layer, err := ctx.Layer(l.name, l.opt)
+				if err != nil {
+					t.Fatalf("creating layer %s: %v", l.name, err)
+				}
+				if err := os.MkdirAll(filepath.Join(layer.Path, "lib"), 0755); err != nil {
+					t.Fatalf("creating dir: %v", err)
+				}
+				if err := os.WriteFile(filepath.Join(layer.Path, "lib", "data"), make([]byte, l.size), 0644); err != nil {
+					t.Fatalf("writing file: %v", err)
+				}
+				// Symlinks are not followed, so the file is counted once.
+				if err := os.Symlink("lib/data", filepath.Join(layer.Path, "link")); err != nil {
+					t.Fatalf("creating symlink: %v", err)
+				}
+			}
+
+			err := ctx.checkLayerSizes()
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("checkLayerSizes() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if gotWarning := len(ctx.warnings) > 0; gotWarning != tc.wantWarning {
+				t.Errorf("checkLayerSizes() got warnings %q, want warning: %t", ctx.warnings, tc.wantWarning)
+			}
+		})
+	}
+}
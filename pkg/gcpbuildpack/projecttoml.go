@@ -0,0 +1,84 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/projecttoml"
+)
+
+const (
+	// projectTOMLExcludedEnv is set by a build layer of the first buildpack to record that the files
+	// excluded by project.toml were removed. The lifecycle exports it to the later buildpacks.
+	projectTOMLExcludedEnv = "X_GOOGLE_PROJECT_TOML_EXCLUDED"
+	projectTOMLLayer       = "project_toml"
+)
+
+// applyProjectTOML reads the project.toml file in the root of the source and sets the build
+// environment variables it declares. Variables that are already set keep their value, so the
+// environment provided by the platform takes precedence over the file. If removeExcluded is true,
+// the files that are not part of the build according to its include and exclude patterns are
+// removed. This runs for every buildpack, so the build environment of a layer ensures that the
+// files are only removed before the first buildpack builds, and not the outputs of earlier
+// buildpacks. It must run before the application root is changed since GOOGLE_APPLICATION_ROOT may
+// be set in the file.
+func (ctx *Context) applyProjectTOML(removeExcluded bool) error {
+	config, err := projecttoml.Read(ctx.ApplicationRoot())
+	if err != nil {
+		return UserErrorf("%w", err)
+	}
+	if config == nil {
+		return nil
+	}
+	for _, e := range config.Env {
+		if _, ok := os.LookupEnv(e.Name); ok {
+			ctx.Debugf("Ignoring %s from %s because it is already set.", e.Name, projecttoml.FileName)
+			continue
+		}
+		if err := ctx.Setenv(e.Name, e.Value); err != nil {
+			return err
+		}
+	}
+	if !removeExcluded {
+		return nil
+	}
+	if os.Getenv(projectTOMLExcludedEnv) != "" {
+		return nil
+	}
+	excluded, err := config.ExcludedFiles(ctx.ApplicationRoot())
+	if err != nil {
+		return InternalErrorf("finding files excluded by %s: %v", projecttoml.FileName, err)
+	}
+	if len(excluded) > 0 {
+		ctx.Logf("Removing %d files and directories excluded by %s.", len(excluded), projecttoml.FileName)
+	}
+	for _, rel := range excluded {
+		ctx.Debugf("Removing %s.", rel)
+		if err := ctx.RemoveAll(ctx.ApplicationRoot(), filepath.FromSlash(rel)); err != nil {
+			return err
+		}
+	}
+	if ctx.buildContext.Layers.Path == "" {
+		return nil
+	}
+	l, err := ctx.Layer(projectTOMLLayer, BuildLayer)
+	if err != nil {
+		return err
+	}
+	l.BuildEnvironment.Override(projectTOMLExcludedEnv, "true")
+	return nil
+}
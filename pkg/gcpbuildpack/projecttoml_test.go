@@ -0,0 +1,160 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/buildpacks/libcnb"
+)
+
+const testProjectTOML = `
+[build]
+exclude = ["*.md"]
+
+[[build.env]]
+name = "PROJECT_TOML_FROM_FILE"
+value = "file"
+
+[[build.env]]
+name = "PROJECT_TOML_FROM_ENV"
+value = "file"
+
+[[build.env]]
+name = "PROJECT_TOML_EMPTY_ENV"
+value = "file"
+`
+
+func TestApplyProjectTOML(t *testing.T) {
+	testCases := []struct {
+		name           string
+		removeExcluded bool
+		wantReadme     bool
+	}{
+		{
+			name:       "detect",
+			wantReadme: true,
+		},
+		{
+			name:           "build",
+			removeExcluded: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Register the variables so that they are restored at the end of the test.
+			t.Setenv("PROJECT_TOML_FROM_FILE", "")
+			os.Unsetenv("PROJECT_TOML_FROM_FILE")
+			t.Setenv("PROJECT_TOML_FROM_ENV", "env")
+			t.Setenv("PROJECT_TOML_EMPTY_ENV", "")
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "project.toml"), []byte(testProjectTOML), 0644); err != nil {
+				t.Fatalf("writing project.toml: %v", err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, "README.md"), nil, 0644); err != nil {
+				t.Fatalf("writing README.md: %v", err)
+			}
+			ctx := NewContext(WithApplicationRoot(dir))
+
+			if err := ctx.applyProjectTOML(tc.removeExcluded); err != nil {
+				t.Fatalf("applyProjectTOML(%t) got error: %v", tc.removeExcluded, err)
+			}
+
+			for name, want := range map[string]string{
+				"PROJECT_TOML_FROM_FILE": "file",
+				"PROJECT_TOML_FROM_ENV":  "env",
+				"PROJECT_TOML_EMPTY_ENV": "",
+			} {
+				if got := os.Getenv(name); got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+			gotReadme, err := ctx.FileExists(dir, "README.md")
+			if err != nil {
+				t.Fatalf("FileExists() got error: %v", err)
+			}
+			if gotReadme != tc.wantReadme {
+				t.Errorf("README.md exists = %t, want %t", gotReadme, tc.wantReadme)
+			}
+		})
+	}
+}
+
+func TestApplyProjectTOMLRemovesExcludedFilesOnce(t *testing.T) {
+	t.Setenv(projectTOMLExcludedEnv, "")
+	os.Unsetenv(projectTOMLExcludedEnv)
+	t.Setenv("PROJECT_TOML_FROM_FILE", "")
+	t.Setenv("PROJECT_TOML_FROM_ENV", "env")
+	t.Setenv("PROJECT_TOML_EMPTY_ENV", "")
+	dir := t.TempDir()
+	for name, content := range map[string]string{"project.toml": testProjectTOML, "README.md": ""} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("writing %s: %v", name, err)
+		}
+	}
+	layersRoot := t.TempDir()
+	newContext := func(id string) *Context {
+		return NewContext(
+			WithApplicationRoot(dir),
+			WithBuildpackInfo(libcnb.BuildpackInfo{ID: id}),
+			WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: filepath.Join(layersRoot, id)}}),
+		)
+	}
+
+	// Simulate two buildpacks building in order, the first of which writes an excluded file.
+	first := newContext("google.first")
+	if err := first.applyProjectTOML(true); err != nil {
+		t.Fatalf("applyProjectTOML() of the first buildpack got error: %v", err)
+	}
+	if exists, err := first.FileExists(dir, "README.md"); err != nil || exists {
+		t.Errorf("README.md exists after the first buildpack = %t (err %v), want false", exists, err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "OUTPUT.md"), nil, 0644); err != nil {
+		t.Fatalf("writing OUTPUT.md: %v", err)
+	}
+	// The lifecycle exports the build environment of the first buildpack's layers to the second.
+	var exported bool
+	for _, lc := range first.buildResult.Layers {
+		l := lc.(layerContributor).l
+		if v, ok := l.BuildEnvironment[projectTOMLExcludedEnv+".override"]; ok {
+			t.Setenv(projectTOMLExcludedEnv, v)
+			exported = true
+		}
+	}
+	if !exported {
+		t.Fatalf("the first buildpack did not set %s in the build environment of a layer", projectTOMLExcludedEnv)
+	}
+	second := newContext("google.second")
+	if err := second.applyProjectTOML(true); err != nil {
+		t.Fatalf("applyProjectTOML() of the second buildpack got error: %v", err)
+	}
+	if exists, err := second.FileExists(dir, "OUTPUT.md"); err != nil || !exists {
+		t.Errorf("OUTPUT.md exists after the second buildpack = %t (err %v), want true", exists, err)
+	}
+}
+
+func TestApplyProjectTOMLInvalid(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "project.toml"), []byte("[build\n"), 0644); err != nil {
+		t.Fatalf("writing project.toml: %v", err)
+	}
+	ctx := NewContext(WithApplicationRoot(dir))
+
+	if err := ctx.applyProjectTOML(true); err == nil {
+		t.Error("applyProjectTOML() got no error, want error")
+	}
+}
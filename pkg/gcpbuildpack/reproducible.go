@@ -0,0 +1,67 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"io/fs"
+	"path/filepath"
+	"time"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"golang.org/x/sys/unix"
+)
+
+// ReproducibleEpoch is the modification time of the files in launch layers when
+// env.ReproducibleBuild is set. It is the time used by the lifecycle for the entries of the image
+// layers, so files keep the same timestamp whether they are read during the build or at run time.
+var ReproducibleEpoch = time.Date(1980, time.January, 1, 0, 0, 1, 0, time.UTC)
+
+// normalizeLayerTimes sets the modification time of the files in the launch layers created by the
+// buildpack, such as generated sources, copied launchers and symlinks, to ReproducibleEpoch when
+// env.ReproducibleBuild is set. Build and cache layers are left untouched since tools use the
+// timestamps of the files they cache to detect changes.
+func (ctx *Context) normalizeLayerTimes() error {
+	reproducible, err := env.IsReproducibleBuild()
+	if err != nil {
+		return UserErrorf("parsing %s: %w", env.ReproducibleBuild, err)
+	}
+	if !reproducible {
+		return nil
+	}
+	for _, c := range ctx.buildResult.Layers {
+		lc, ok := c.(layerContributor)
+		if !ok || !lc.l.Launch {
+			continue
+		}
+		ctx.Debugf("Normalizing the modification times of the files in layer %s.", lc.l.Name)
+		if err := normalizeTimes(lc.l.Path); err != nil {
+			return InternalErrorf("normalizing the modification times in %s: %w", lc.l.Path, err)
+		}
+	}
+	return nil
+}
+
+// normalizeTimes sets the access and modification times of dir and every file under it, including
+// symlinks themselves rather than their targets, to ReproducibleEpoch.
+func normalizeTimes(dir string) error {
+	tv := unix.NsecToTimeval(ReproducibleEpoch.UnixNano())
+	times := []unix.Timeval{tv, tv}
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		return unix.Lutimes(path, times)
+	})
+}
@@ -0,0 +1,85 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/buildpacks/libcnb"
+)
+
+func TestNormalizeLayerTimes(t *testing.T) {
+	testCases := []struct {
+		name         string
+		reproducible string
+		want         map[string]bool
+	}{
+		{
+			name:         "reproducible",
+			reproducible: "true",
+			want:         map[string]bool{"launch": true, "launch/bin": true, "launch/bin/main": true, "launch/bin/link": true},
+		},
+		{
+			name: "not reproducible",
+			want: map[string]bool{},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.reproducible != "" {
+				t.Setenv(env.ReproducibleBuild, tc.reproducible)
+			}
+			layers := t.TempDir()
+			ctx := NewContext(WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: layers}}))
+			launch, err := ctx.Layer("launch", LaunchLayer)
+			if err != nil {
+				t.Fatalf("creating launch layer: %v", err)
+			}
+			cache, err := ctx.Layer("cache", CacheLayer)
+			if err != nil {
+				t.Fatalf("creating cache layer: %v", err)
+			}
+			for _, l := range []*libcnb.Layer{launch, cache} {
+				if err := os.MkdirAll(filepath.Join(l.Path, "bin"), 0755); err != nil {
+					t.Fatalf("creating dir: %v", err)
+				}
+				if err := os.WriteFile(filepath.Join(l.Path, "bin", "main"), []byte("main"), 0755); err != nil {
+					t.Fatalf("writing file: %v", err)
+				}
+				// The symlink is dangling to check that its target is not followed.
+				if err := os.Symlink("missing", filepath.Join(l.Path, "bin", "link")); err != nil {
+					t.Fatalf("creating symlink: %v", err)
+				}
+			}
+
+			if err := ctx.normalizeLayerTimes(); err != nil {
+				t.Fatalf("normalizeLayerTimes() got error: %v", err)
+			}
+
+			for _, f := range []string{"launch", "launch/bin", "launch/bin/main", "launch/bin/link", "cache", "cache/bin", "cache/bin/main", "cache/bin/link"} {
+				info, err := os.Lstat(filepath.Join(layers, f))
+				if err != nil {
+					t.Fatalf("stat %s: %v", f, err)
+				}
+				if got := info.ModTime().Equal(ReproducibleEpoch); got != tc.want[f] {
+					t.Errorf("%s modification time is %v, want normalized: %t", f, info.ModTime(), tc.want[f])
+				}
+			}
+		})
+	}
+}
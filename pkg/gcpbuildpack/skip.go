@@ -0,0 +1,129 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+)
+
+const (
+	// orderPathEnv is the env var set by the platform to the path of the builder's order.toml.
+	orderPathEnv = "CNB_ORDER_PATH"
+	// defaultOrderPath is where the builder's order.toml is when CNB_ORDER_PATH is not set.
+	defaultOrderPath = "/cnb/order.toml"
+)
+
+// builderOrder is the content of the order.toml of a builder.
+type builderOrder struct {
+	Order []struct {
+		Group []struct {
+			ID string `toml:"id"`
+		} `toml:"group"`
+	} `toml:"order"`
+}
+
+// BuilderOrder returns the buildpack IDs of each group of the builder, in the order in which the
+// groups and their buildpacks are detected. It returns nil if the builder's order.toml is not
+// found, e.g. when the buildpack is not run by a builder.
+func BuilderOrder() ([][]string, error) {
+	path := os.Getenv(orderPathEnv)
+	if path == "" {
+		path = defaultOrderPath
+	}
+	var order builderOrder
+	if _, err := toml.DecodeFile(path, &order); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, InternalErrorf("reading builder order %s: %v", path, err)
+	}
+	var groups [][]string
+	for _, o := range order.Order {
+		var group []string
+		for _, bp := range o.Group {
+			group = append(group, bp.ID)
+		}
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
+
+// skippedBuildpacks returns the buildpack IDs listed in GOOGLE_SKIP_BUILDPACKS.
+func skippedBuildpacks() []string {
+	var ids []string
+	for _, id := range strings.Split(os.Getenv(env.SkipBuildpacks), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// skipDetect returns an opt-out result if the buildpack is listed in GOOGLE_SKIP_BUILDPACKS, or nil
+// if it must run its detect function. It warns about the listed IDs that are not buildpacks of the
+// builder.
+func (ctx *Context) skipDetect() (DetectResult, error) {
+	skipped := skippedBuildpacks()
+	if len(skipped) == 0 {
+		return nil, nil
+	}
+	ctx.warnUnknownBuildpacks(skipped)
+	for _, id := range skipped {
+		if id == ctx.BuildpackID() {
+			ctx.Logf("***** SKIPPED: %s is listed in %s", id, env.SkipBuildpacks)
+			return OptOut(fmt.Sprintf("skipped by configuration, %s is listed in %s", id, env.SkipBuildpacks)), nil
+		}
+	}
+	return nil, nil
+}
+
+// warnUnknownBuildpacks warns about the given buildpack IDs that are not in the builder's order.
+// Since every buildpack runs it, only the first buildpack of the builder's order warns, which the
+// lifecycle always detects. The order is only used for the warning, so errors reading it are logged.
+func (ctx *Context) warnUnknownBuildpacks(ids []string) {
+	groups, err := BuilderOrder()
+	if err != nil {
+		ctx.Logf("Not validating %s: %v", env.SkipBuildpacks, err)
+		return
+	}
+	if len(groups) == 0 || len(groups[0]) == 0 {
+		ctx.Debugf("Builder order not found, not validating %s.", env.SkipBuildpacks)
+		return
+	}
+	if groups[0][0] != ctx.BuildpackID() {
+		return
+	}
+	known := map[string]bool{}
+	var valid []string
+	for _, group := range groups {
+		for _, id := range group {
+			if !known[id] {
+				known[id] = true
+				valid = append(valid, id)
+			}
+		}
+	}
+	for _, id := range ids {
+		if !known[id] {
+			ctx.Warnf("Unknown buildpack %q in %s, valid IDs are: %s", id, env.SkipBuildpacks, strings.Join(valid, ", "))
+		}
+	}
+}
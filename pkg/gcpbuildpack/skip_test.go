@@ -0,0 +1,196 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/buildpacks/libcnb"
+	"github.com/google/go-cmp/cmp"
+)
+
+const testOrder = `
+[[order]]
+  [[order.group]]
+    id = "google.python.runtime"
+  [[order.group]]
+    id = "google.python.pip"
+
+[[order]]
+  [[order.group]]
+    id = "google.nodejs.runtime"
+  [[order.group]]
+    id = "google.python.runtime"
+    optional = true
+`
+
+func writeOrder(t *testing.T, content string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "order.toml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(orderPathEnv, path)
+}
+
+func TestBuilderOrder(t *testing.T) {
+	writeOrder(t, testOrder)
+
+	got, err := BuilderOrder()
+	if err != nil {
+		t.Fatalf("BuilderOrder() got error: %v", err)
+	}
+	want := [][]string{
+		{"google.python.runtime", "google.python.pip"},
+		{"google.nodejs.runtime", "google.python.runtime"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("BuilderOrder() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestBuilderOrderNotFound(t *testing.T) {
+	t.Setenv(orderPathEnv, filepath.Join(t.TempDir(), "order.toml"))
+
+	got, err := BuilderOrder()
+	if err != nil {
+		t.Fatalf("BuilderOrder() got error: %v", err)
+	}
+	if got != nil {
+		t.Errorf("BuilderOrder() = %v, want nil", got)
+	}
+}
+
+func TestSkipDetect(t *testing.T) {
+	testCases := []struct {
+		name        string
+		buildpackID string
+		skip        string
+		order       string
+		wantSkipped bool
+		wantOutput  string
+		notOutput   string
+	}{
+		{
+			name: "not set",
+		},
+		{
+			name:        "listed",
+			skip:        "google.nodejs.runtime",
+			order:       testOrder,
+			wantSkipped: true,
+			wantOutput:  "SKIPPED: google.nodejs.runtime is listed in GOOGLE_SKIP_BUILDPACKS",
+			notOutput:   "Unknown buildpack",
+		},
+		{
+			name:        "listed with others",
+			skip:        "google.python.pip, google.nodejs.runtime",
+			order:       testOrder,
+			wantSkipped: true,
+		},
+		{
+			name:      "not listed",
+			skip:      "google.python.pip",
+			order:     testOrder,
+			notOutput: "SKIPPED",
+		},
+		{
+			name:        "unknown buildpack",
+			buildpackID: "google.python.runtime",
+			skip:        "google.python.runtime,google.nodejs.rails",
+			order:       testOrder,
+			wantSkipped: true,
+			wantOutput:  `Unknown buildpack "google.nodejs.rails" in GOOGLE_SKIP_BUILDPACKS, valid IDs are: google.python.runtime, google.python.pip, google.nodejs.runtime`,
+		},
+		{
+			name:        "unknown buildpack warned by the first buildpack only",
+			skip:        "google.nodejs.runtime,google.nodejs.rails",
+			order:       testOrder,
+			wantSkipped: true,
+			notOutput:   "Unknown buildpack",
+		},
+		{
+			name:        "malformed builder order",
+			skip:        "google.nodejs.runtime,google.nodejs.rails",
+			order:       "[[order",
+			wantSkipped: true,
+			wantOutput:  "Not validating GOOGLE_SKIP_BUILDPACKS",
+		},
+		{
+			name:        "without builder order",
+			skip:        "google.nodejs.rails,google.nodejs.runtime",
+			wantSkipped: true,
+			notOutput:   "Unknown buildpack",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(env.SkipBuildpacks, tc.skip)
+			if tc.order != "" {
+				writeOrder(t, tc.order)
+			} else {
+				t.Setenv(orderPathEnv, filepath.Join(t.TempDir(), "order.toml"))
+			}
+			id := tc.buildpackID
+			if id == "" {
+				id = "google.nodejs.runtime"
+			}
+			var buf bytes.Buffer
+			ctx := NewContext(WithLogger(log.New(&buf, "", 0)), WithBuildpackInfo(libcnb.BuildpackInfo{ID: id}))
+
+			result, err := ctx.skipDetect()
+			if err != nil {
+				t.Fatalf("skipDetect() got error: %v", err)
+			}
+			if gotSkipped := result != nil; gotSkipped != tc.wantSkipped {
+				t.Fatalf("skipDetect() skipped = %t, want %t", gotSkipped, tc.wantSkipped)
+			}
+			if result != nil && result.Result().Pass {
+				t.Errorf("skipDetect() opted in, want opt out")
+			}
+			if !strings.Contains(buf.String(), tc.wantOutput) {
+				t.Errorf("skipDetect() output = %q, want output containing %q", buf.String(), tc.wantOutput)
+			}
+			if tc.notOutput != "" && strings.Contains(buf.String(), tc.notOutput) {
+				t.Errorf("skipDetect() output = %q, want output not containing %q", buf.String(), tc.notOutput)
+			}
+		})
+	}
+}
+
+func TestDetectSkipped(t *testing.T) {
+	setUpDetectEnvironment(t)
+	t.Setenv(env.SkipBuildpacks, "my-id")
+
+	handler := &fakeExitHandler{}
+	called := false
+	detect(func(c *Context) (DetectResult, error) {
+		called = true
+		return OptIn("some reason"), nil
+	}, libcnb.WithExitHandler(handler))
+
+	if called {
+		t.Error("detect function was called for a skipped buildpack")
+	}
+	if !handler.failCalled {
+		t.Error("detect did not opt out for a skipped buildpack")
+	}
+}
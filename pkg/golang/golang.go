@@ -27,6 +27,7 @@ import (
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/fetch"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/toolversions"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/version"
 	"github.com/buildpacks/libcnb"
 	"github.com/Masterminds/semver"
@@ -42,6 +43,14 @@ const (
 	// The key used when a layers' cache is keyed off of the go mod
 	goModCacheKey = "go-mod-sha"
 	envGoVersion  = "GOOGLE_GO_VERSION"
+	// StrictModulePathEnv is an environment variable that makes the build fail, instead of aliasing
+	// the module, when a function's module path has no dot in its first path element.
+	StrictModulePathEnv = "GOOGLE_GO_STRICT_MODULE_PATH"
+	// GoFlagsEnv is an environment variable whose value is exported as GOFLAGS to the go commands
+	// run by the Go buildpacks, e.g. GOOGLE_GOFLAGS="-mod=mod -buildvcs=false".
+	GoFlagsEnv = "GOOGLE_GOFLAGS"
+	// syntheticModuleDomain is prepended to module paths without a dot in their first path element.
+	syntheticModuleDomain = "example.local"
 )
 
 var (
@@ -61,6 +70,25 @@ type goRelease struct {
 	Stable  bool   `json:"stable"`
 }
 
+// GoFlags returns the environment entries that export the flags in GOOGLE_GOFLAGS as GOFLAGS, or
+// nil if it is not set. The -o flag is dropped with a warning because the buildpacks set the output
+// path of the compiled binary explicitly.
+func GoFlags(ctx *gcp.Context) []string {
+	var flags []string
+	for _, f := range strings.Fields(os.Getenv(GoFlagsEnv)) {
+		name := strings.SplitN(strings.TrimLeft(f, "-"), "=", 2)[0]
+		if name == "o" {
+			ctx.Warnf("Ignoring %q in %s: the output path is set by the buildpack", f, GoFlagsEnv)
+			continue
+		}
+		flags = append(flags, f)
+	}
+	if len(flags) == 0 {
+		return nil
+	}
+	return []string{"GOFLAGS=" + strings.Join(flags, " ")}
+}
+
 // SupportsAppEngineApis is a Go buildpack specific function that returns true if App Engine API access is enabled
 func SupportsAppEngineApis(ctx *gcp.Context) (bool, error) {
 	if IsGo111Runtime() {
@@ -244,7 +272,23 @@ func NewGoWorkspaceLayer(ctx *gcp.Context) (*libcnb.Layer, error) {
 		return l, nil
 	}
 
-	hash, cached, err := cache.HashAndCheck(ctx, l, goModCacheKey, cache.WithFiles(goModPath(ctx)))
+	cacheFiles := []string{goModPath(ctx)}
+	// The dependencies of a Go workspace also depend on go.work and the modules it uses.
+	goWork, err := AppGoWork(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if goWork != "" {
+		modules, err := GoWorkModules(ctx, goWork)
+		if err != nil {
+			return nil, err
+		}
+		cacheFiles = append(cacheFiles, goWork)
+		for _, m := range modules {
+			cacheFiles = append(cacheFiles, filepath.Join(m, "go.mod"))
+		}
+	}
+	hash, cached, err := cache.HashAndCheck(ctx, l, goModCacheKey, cache.WithFiles(cacheFiles...))
 	if err != nil {
 		if os.IsNotExist(err) {
 			// when go.mod doesn't exist, clear any previously cached bits and return an empty layer
@@ -263,7 +307,12 @@ func NewGoWorkspaceLayer(ctx *gcp.Context) (*libcnb.Layer, error) {
 	return l, nil
 }
 
+// goModPath returns the path of the go.mod file of the application's module. The module root may
+// be a parent directory of the application root within the workspace.
 func goModPath(ctx *gcp.Context) string {
+	if path, err := ctx.FindInWorkspace("go.mod"); err == nil && path != "" {
+		return path
+	}
 	return filepath.Join(ctx.ApplicationRoot(), "go.mod")
 }
 
@@ -291,6 +340,27 @@ func ExecWithGoproxyFallback(ctx *gcp.Context, cmd []string, opts ...gcp.ExecOpt
 	return ctx.Exec(cmd, opts...)
 }
 
+// ModulePathAlias returns a module path that can be required through a replace directive. golang.org/ref/mod
+// requires such module paths to contain a dot in the first path element, so paths like "myapp" are
+// aliased to a synthetic "example.local/myapp". If GOOGLE_GO_STRICT_MODULE_PATH is true, such
+// module paths fail the build instead.
+func ModulePathAlias(ctx *gcp.Context, modulePath string) (string, error) {
+	first, _, _ := strings.Cut(modulePath, "/")
+	if strings.Contains(first, ".") {
+		return modulePath, nil
+	}
+	strict, err := env.IsPresentAndTrue(StrictModulePathEnv)
+	if err != nil {
+		return "", err
+	}
+	if strict {
+		return "", gcp.UserErrorf("the module path in the function's go.mod must contain a dot in the first path element before a slash, e.g. example.com/module, found: %s", modulePath)
+	}
+	alias := syntheticModuleDomain + "/" + modulePath
+	ctx.Warnf("The module path %q in go.mod does not contain a dot in the first path element, aliasing it as %q. Set %s=true to fail the build instead.", modulePath, alias, StrictModulePathEnv)
+	return alias, nil
+}
+
 // IsGo111Runtime returns true when the GOOGLE_RUNTIME is go111. This will be
 // true when using GCF or GAE with go 1.11.
 func IsGo111Runtime() bool {
@@ -309,6 +379,15 @@ func RuntimeVersion(ctx *gcp.Context) (string, error) {
 		return version, nil
 	}
 
+	version, err := toolversions.Version(ctx, toolversions.Golang)
+	if err != nil {
+		return "", err
+	}
+	if version != "" {
+		ctx.Logf("Using runtime version from %s: %s", toolversions.File, version)
+		return version, nil
+	}
+
 	ctx.Logf("Using latest stable Go version")
 	return "", nil
 }
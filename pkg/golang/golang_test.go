@@ -319,6 +319,71 @@ module dir
 	}
 }
 
+func TestRuntimeVersion(t *testing.T) {
+	testCases := []struct {
+		name           string
+		goVersion      string
+		runtimeVersion string
+		toolVersions   string
+		want           string
+	}{
+		{
+			name: "latest by default",
+			want: "",
+		},
+		{
+			name:      "from GOOGLE_GO_VERSION",
+			goVersion: "1.21.5",
+			want:      "1.21.5",
+		},
+		{
+			name:           "from GOOGLE_RUNTIME_VERSION",
+			runtimeVersion: "1.20.12",
+			want:           "1.20.12",
+		},
+		{
+			name:         "from .tool-versions",
+			toolVersions: "nodejs 20.11.0\ngolang 1.22.0 # pinned\npython 3.12.1\n",
+			want:         "1.22.0",
+		},
+		{
+			name:         ".tool-versions without golang",
+			toolVersions: "nodejs 20.11.0\n",
+			want:         "",
+		},
+		{
+			name:           "GOOGLE_RUNTIME_VERSION takes precedence over .tool-versions",
+			runtimeVersion: "1.20.12",
+			toolVersions:   "golang 1.22.0\n",
+			want:           "1.20.12",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tc.goVersion != "" {
+				t.Setenv(envGoVersion, tc.goVersion)
+			}
+			if tc.runtimeVersion != "" {
+				t.Setenv("GOOGLE_RUNTIME_VERSION", tc.runtimeVersion)
+			}
+			if tc.toolVersions != "" {
+				if err := os.WriteFile(filepath.Join(dir, ".tool-versions"), []byte(tc.toolVersions), 0644); err != nil {
+					t.Fatalf("writing .tool-versions: %v", err)
+				}
+			}
+
+			got, err := RuntimeVersion(gcp.NewContext(gcp.WithApplicationRoot(dir)))
+			if err != nil {
+				t.Fatalf("RuntimeVersion() got error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("RuntimeVersion() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
 func TestSupportsAutoVendor(t *testing.T) {
 	testCases := []struct {
 		goVersion string
@@ -610,6 +675,98 @@ func TestResolveGoVersion(t *testing.T) {
 	}
 }
 
+func TestModulePathAlias(t *testing.T) {
+	testCases := []struct {
+		name       string
+		modulePath string
+		strict     bool
+		want       string
+		wantErr    bool
+	}{
+		{
+			name:       "dotted module path",
+			modulePath: "example.com/myfunc",
+			want:       "example.com/myfunc",
+		},
+		{
+			name:       "dotted module path in strict mode",
+			modulePath: "example.com/myfunc",
+			strict:     true,
+			want:       "example.com/myfunc",
+		},
+		{
+			name:       "module path without dot",
+			modulePath: "myfunc",
+			want:       "example.local/myfunc",
+		},
+		{
+			name:       "nested module path without dot",
+			modulePath: "myorg/myfunc",
+			want:       "example.local/myorg/myfunc",
+		},
+		{
+			name:       "module path without dot in strict mode",
+			modulePath: "myfunc",
+			strict:     true,
+			wantErr:    true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.strict {
+				t.Setenv(StrictModulePathEnv, "true")
+			}
+			got, err := ModulePathAlias(gcp.NewContext(), tc.modulePath)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("ModulePathAlias(%q) got error: %v, want error: %v", tc.modulePath, err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("ModulePathAlias(%q) = %q, want %q", tc.modulePath, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestGoFlags(t *testing.T) {
+	testCases := []struct {
+		name    string
+		goFlags string
+		want    []string
+	}{
+		{
+			name: "not set",
+		},
+		{
+			name:    "single flag",
+			goFlags: "-mod=mod",
+			want:    []string{"GOFLAGS=-mod=mod"},
+		},
+		{
+			name:    "multiple flags",
+			goFlags: " -mod=mod   -buildvcs=false ",
+			want:    []string{"GOFLAGS=-mod=mod -buildvcs=false"},
+		},
+		{
+			name:    "output flag dropped",
+			goFlags: "-o=/tmp/bin -mod=mod --o=out",
+			want:    []string{"GOFLAGS=-mod=mod"},
+		},
+		{
+			name:    "only output flag",
+			goFlags: "-o",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(GoFlagsEnv, tc.goFlags)
+			got := GoFlags(gcp.NewContext())
+			if fmt.Sprint(got) != fmt.Sprint(tc.want) {
+				t.Errorf("GoFlags() with %s=%q = %v, want %v", GoFlagsEnv, tc.goFlags, got, tc.want)
+			}
+		})
+	}
+}
+
 // mockReadGoVersion mocks the readGoVersion
 func mockReadGoVersion(t *testing.T, goVer string) {
 	origReadGoVersion := readGoVersion
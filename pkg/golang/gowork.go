@@ -0,0 +1,116 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golang
+
+import (
+	"os"
+	"path/filepath"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"golang.org/x/mod/modfile"
+)
+
+const (
+	// GoWorkFile is the name of the file that defines a Go workspace.
+	GoWorkFile = "go.work"
+	// goWorkEnv is the environment variable that tells the go command which go.work file to use.
+	goWorkEnv = "GOWORK"
+)
+
+// FindGoWork returns the path of the go.work file in dir or in one of its parents up to and
+// including root, or an empty string if there is none.
+func FindGoWork(ctx *gcp.Context, dir, root string) (string, error) {
+	for {
+		exists, err := ctx.FileExists(dir, GoWorkFile)
+		if err != nil {
+			return "", err
+		}
+		if exists {
+			return filepath.Join(dir, GoWorkFile), nil
+		}
+		parent := filepath.Dir(dir)
+		if dir == root || parent == dir {
+			return "", nil
+		}
+		if rel, err := filepath.Rel(root, parent); err != nil || !filepath.IsLocal(rel) {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// AppGoWork returns the path of the go.work file that applies to the application: the one set with
+// GOWORK, for example by a previous buildpack, or the one found in the application root or one of
+// its parents within the workspace. It returns an empty string if the application is not part of a
+// Go workspace or GOWORK=off.
+func AppGoWork(ctx *gcp.Context) (string, error) {
+	if v, ok := os.LookupEnv(goWorkEnv); ok && v != "" {
+		if v == "off" {
+			return "", nil
+		}
+		return v, nil
+	}
+	return FindGoWork(ctx, ctx.ApplicationRoot(), ctx.WorkspaceRoot())
+}
+
+// GoWorkEnv returns the environment entries that point the go command at the given go.work file, or
+// nil if it is empty.
+func GoWorkEnv(goWork string) []string {
+	if goWork == "" {
+		return nil
+	}
+	return []string{goWorkEnv + "=" + goWork}
+}
+
+// GoWorkModules returns the absolute directories of the modules listed by the use directives of the
+// given go.work file.
+func GoWorkModules(ctx *gcp.Context, goWork string) ([]string, error) {
+	content, err := ctx.ReadFile(goWork)
+	if err != nil {
+		return nil, err
+	}
+	wf, err := modfile.ParseWork(goWork, content, nil)
+	if err != nil {
+		return nil, gcp.UserErrorf("parsing %s: %v", goWork, err)
+	}
+	var dirs []string
+	for _, use := range wf.Use {
+		dir := use.Path
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(filepath.Dir(goWork), dir)
+		}
+		dirs = append(dirs, filepath.Clean(dir))
+	}
+	return dirs, nil
+}
+
+// CheckGoWorkNotVendored returns a user error if the Go workspace defined by goWork or one of its
+// modules has a vendor directory, since the buildpacks do not support vendored workspaces.
+func CheckGoWorkNotVendored(ctx *gcp.Context, goWork string) error {
+	modules, err := GoWorkModules(ctx, goWork)
+	if err != nil {
+		return err
+	}
+	for _, dir := range append([]string{filepath.Dir(goWork)}, modules...) {
+		vendored, err := ctx.FileExists(dir, "vendor")
+		if err != nil {
+			return err
+		}
+		if vendored {
+			return gcp.UserErrorf("found a vendor directory in %s, but vendored dependencies are not supported for Go workspaces defined by %s: remove the vendor directory to download the dependencies during the build, or remove %s to build a single module", dir, goWork, GoWorkFile)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,208 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golang
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/google/go-cmp/cmp"
+)
+
+func writeGoWorkFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("creating directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("writing %s: %v", name, err)
+		}
+	}
+}
+
+func TestFindGoWork(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		dir   string
+		root  string
+		want  string
+	}{
+		{
+			name:  "in directory",
+			files: map[string]string{"service/go.work": ""},
+			dir:   "service",
+			want:  "service/go.work",
+		},
+		{
+			name:  "in parent",
+			files: map[string]string{"go.work": "", "service/go.mod": ""},
+			dir:   "service",
+			want:  "go.work",
+		},
+		{
+			name:  "above root",
+			files: map[string]string{"go.work": "", "service/api/go.mod": ""},
+			dir:   "service/api",
+			root:  "service",
+		},
+		{
+			name:  "none",
+			files: map[string]string{"service/go.mod": ""},
+			dir:   "service",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeGoWorkFiles(t, dir, tc.files)
+
+			got, err := FindGoWork(gcp.NewContext(), filepath.Join(dir, tc.dir), filepath.Join(dir, tc.root))
+			if err != nil {
+				t.Fatalf("FindGoWork() got error: %v", err)
+			}
+			want := ""
+			if tc.want != "" {
+				want = filepath.Join(dir, tc.want)
+			}
+			if got != want {
+				t.Errorf("FindGoWork() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestAppGoWork(t *testing.T) {
+	testCases := []struct {
+		name   string
+		goWork string
+		want   string
+	}{
+		{name: "found", want: "go.work"},
+		{name: "from GOWORK", goWork: "/workspace/other/go.work", want: "/workspace/other/go.work"},
+		{name: "off", goWork: "off"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeGoWorkFiles(t, dir, map[string]string{"go.work": "go 1.22\n"})
+			if tc.goWork != "" {
+				t.Setenv("GOWORK", tc.goWork)
+			}
+
+			got, err := AppGoWork(gcp.NewContext(gcp.WithApplicationRoot(dir)))
+			if err != nil {
+				t.Fatalf("AppGoWork() got error: %v", err)
+			}
+			want := tc.want
+			if want != "" && !filepath.IsAbs(want) {
+				want = filepath.Join(dir, want)
+			}
+			if got != want {
+				t.Errorf("AppGoWork() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestGoWorkModules(t *testing.T) {
+	testCases := []struct {
+		name    string
+		goWork  string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:   "single line",
+			goWork: "go 1.22\n\nuse ./service\nuse ./shared\n",
+			want:   []string{"service", "shared"},
+		},
+		{
+			name:   "block",
+			goWork: "go 1.22\n\nuse (\n\t./service // the app\n\t\"./shared lib\"\n\n\t.\n)\n\nreplace example.com/x => ./x\n",
+			want:   []string{"service", "shared lib", "."},
+		},
+		{
+			name:   "parent directory",
+			goWork: "go 1.22\nuse ../common\n",
+			want:   []string{"../common"},
+		},
+		{
+			name:   "no use",
+			goWork: "go 1.22\n",
+		},
+		{
+			name:    "invalid",
+			goWork:  "go 1.22\nuse (\n\t./service\n",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "repo")
+			writeGoWorkFiles(t, dir, map[string]string{"go.work": tc.goWork})
+
+			got, err := GoWorkModules(gcp.NewContext(), filepath.Join(dir, "go.work"))
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("GoWorkModules() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			var want []string
+			for _, w := range tc.want {
+				want = append(want, filepath.Join(dir, w))
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("GoWorkModules() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCheckGoWorkNotVendored(t *testing.T) {
+	testCases := []struct {
+		name    string
+		files   map[string]string
+		wantErr bool
+	}{
+		{
+			name:  "not vendored",
+			files: map[string]string{"go.work": "use ./service\n", "service/go.mod": ""},
+		},
+		{
+			name:    "workspace vendor",
+			files:   map[string]string{"go.work": "use ./service\n", "service/go.mod": "", "vendor/modules.txt": ""},
+			wantErr: true,
+		},
+		{
+			name:    "module vendor",
+			files:   map[string]string{"go.work": "use ./service\n", "service/go.mod": "", "service/vendor/modules.txt": ""},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeGoWorkFiles(t, dir, tc.files)
+
+			err := CheckGoWorkNotVendored(gcp.NewContext(), filepath.Join(dir, "go.work"))
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("CheckGoWorkNotVendored() got error %v, want error %t", err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -15,7 +15,24 @@
 package java
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/fetch"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// artifactRegistryRepository is the name of the Gradle repository that receives the Artifact
+	// Registry credentials. Gradle reads the credentials of a repository declared with
+	// credentials(PasswordCredentials) from the <name>Username and <name>Password properties.
+	artifactRegistryRepository = "artifactRegistry"
+	// artifactRegistryUsername is the username that authenticates to Artifact Registry with an
+	// access token.
+	artifactRegistryUsername = "oauth2accesstoken"
+	// accessTokenEnv is the env var that holds the access token of the build's credentials.
+	accessTokenEnv = "CLOUDSDK_AUTH_ACCESS_TOKEN"
 )
 
 var (
@@ -47,3 +64,54 @@ func GetLatestGradleVersion() (string, error) {
 	}
 	return result.Version, nil
 }
+
+// PublishGradle runs the given `gradle publish` command with the Artifact Registry credentials
+// written to the gradle.properties of gradleUserHome, and removes them once the command completes.
+func PublishGradle(ctx *gcp.Context, command []string, gradleUserHome string) error {
+	cleanup, err := injectArtifactRegistryCredentials(ctx, gradleUserHome)
+	if err != nil {
+		return err
+	}
+	ctx.Logf("Publishing the build artifacts because %s is set.", GradlePublishEnv)
+	_, execErr := ctx.Exec(command, gcp.WithUserAttribution)
+	// Remove the credentials even if the publish failed since gradleUserHome may be cached.
+	if err := cleanup(); err != nil {
+		return gcp.InternalErrorf("removing the Artifact Registry credentials: %w", err)
+	}
+	return execErr
+}
+
+// injectArtifactRegistryCredentials adds the access token of CLOUDSDK_AUTH_ACCESS_TOKEN to the
+// gradle.properties of gradleUserHome as the credentials of the artifactRegistry repository. It
+// returns a function that restores the previous gradle.properties.
+func injectArtifactRegistryCredentials(ctx *gcp.Context, gradleUserHome string) (func() error, error) {
+	token := os.Getenv(accessTokenEnv)
+	if token == "" {
+		return nil, gcp.UserErrorf("%s is set but %s is not, provide an access token that can publish to Artifact Registry", GradlePublishEnv, accessTokenEnv)
+	}
+	path := filepath.Join(gradleUserHome, "gradle.properties")
+	exists, err := ctx.FileExists(path)
+	if err != nil {
+		return nil, err
+	}
+	var original []byte
+	if exists {
+		if original, err = ctx.ReadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	content := append([]byte{}, original...)
+	if len(content) > 0 && content[len(content)-1] != '\n' {
+		content = append(content, '\n')
+	}
+	content = append(content, fmt.Sprintf("%[1]sUsername=%[2]s\n%[1]sPassword=%[3]s\n", artifactRegistryRepository, artifactRegistryUsername, token)...)
+	if err := ctx.WriteFile(path, content, 0600); err != nil {
+		return nil, err
+	}
+	return func() error {
+		if exists {
+			return ctx.WriteFile(path, original, 0600)
+		}
+		return ctx.RemoveAll(path)
+	}, nil
+}
@@ -16,9 +16,12 @@ package java
 
 import (
 	"net/http"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/GoogleCloudPlatform/buildpacks/internal/testserver"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 )
 
 func TestGetLatestGradleVersion(t *testing.T) {
@@ -79,3 +82,74 @@ func stubGradleVersionService(t *testing.T, responseData string, httpStatus int)
 		testserver.WithMockURL(&gradleVersionURL),
 	)
 }
+
+func TestInjectArtifactRegistryCredentials(t *testing.T) {
+	credentials := "artifactRegistryUsername=oauth2accesstoken\nartifactRegistryPassword=ya29.token\n"
+	testCases := []struct {
+		name       string
+		properties string
+		token      string
+		want       string
+		wantErr    bool
+	}{
+		{
+			name:  "no gradle.properties",
+			token: "ya29.token",
+			want:  credentials,
+		},
+		{
+			name:       "existing gradle.properties",
+			properties: "org.gradle.caching=true",
+			token:      "ya29.token",
+			want:       "org.gradle.caching=true\n" + credentials,
+		},
+		{
+			name:    "no access token",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(accessTokenEnv, tc.token)
+			dir := t.TempDir()
+			path := filepath.Join(dir, "gradle.properties")
+			if tc.properties != "" {
+				if err := os.WriteFile(path, []byte(tc.properties), 0644); err != nil {
+					t.Fatalf("writing gradle.properties: %v", err)
+				}
+			}
+
+			cleanup, err := injectArtifactRegistryCredentials(gcp.NewContext(), dir)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("injectArtifactRegistryCredentials() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("reading gradle.properties: %v", err)
+			}
+			if string(got) != tc.want {
+				t.Errorf("injectArtifactRegistryCredentials() wrote %q, want %q", got, tc.want)
+			}
+
+			if err := cleanup(); err != nil {
+				t.Fatalf("cleanup() got error: %v", err)
+			}
+			got, err = os.ReadFile(path)
+			if tc.properties == "" {
+				if !os.IsNotExist(err) {
+					t.Errorf("cleanup() left gradle.properties %q, want it removed", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("reading gradle.properties: %v", err)
+			}
+			if string(got) != tc.properties {
+				t.Errorf("cleanup() restored %q, want %q", got, tc.properties)
+			}
+		})
+	}
+}
@@ -27,6 +27,7 @@ import (
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/toolversions"
 	"github.com/buildpacks/libcnb"
 )
 
@@ -52,6 +53,47 @@ const (
 	// MavenBuildArgs is an env var used to append arguments to the mvn build command.
 	// Example: `clean package` for Maven apps run "mvn clean package" command.
 	MavenBuildArgs = "GOOGLE_MAVEN_BUILD_ARGS"
+
+	// MavenProfiles is an env var used to activate Maven build profiles, separated by commas or spaces.
+	// Example: `prod,cloud-run` for Maven apps adds "-Pprod,cloud-run" to the mvn build command.
+	MavenProfiles = "GOOGLE_MAVEN_PROFILES"
+
+	// MavenProperties is an env var used to set Maven properties with a JSON object.
+	// Example: `{"env": "production"}` for Maven apps adds "-Denv=production" to the mvn build command.
+	MavenProperties = "GOOGLE_MAVEN_PROPERTIES"
+
+	// GradleTasks is an env var used to replace the default `assemble` task of the gradle build command.
+	// Example: `:app:bootJar` for Gradle apps runs "gradle clean :app:bootJar -x test --build-cache".
+	GradleTasks = "GOOGLE_GRADLE_TASKS"
+
+	// GradlePublishEnv is an env var used to run `gradle publish` after the gradle build, with the
+	// Artifact Registry access token of CLOUDSDK_AUTH_ACCESS_TOKEN as the credentials of the
+	// "artifactRegistry" repository.
+	GradlePublishEnv = "GOOGLE_GRADLE_PUBLISH"
+
+	// RunTestsEnv is an env var used to run the tests of Maven and Gradle apps during the build, which
+	// fails if a test fails. Tests are skipped by default.
+	RunTestsEnv = "GOOGLE_JAVA_RUN_TESTS"
+
+	// BuildVersionEnv is an env var used to select the feature version of the JDK used to build the
+	// application when it differs from the version it runs on.
+	// Example: `21` builds with Java 21 an application that runs on the Java 17 selected by
+	// RuntimeVersionEnv, provided that it targets Java 17 bytecode.
+	BuildVersionEnv = "GOOGLE_JAVA_BUILD_VERSION"
+
+	// RuntimeVersionEnv is an env var used to select the feature version of the JDK the application
+	// runs on. It takes precedence over GOOGLE_RUNTIME_VERSION.
+	// Example: `17`.
+	RuntimeVersionEnv = "GOOGLE_JAVA_RUNTIME_VERSION"
+
+	// OfflineEnv is an env var that runs Maven and Gradle in offline mode so that dependencies are
+	// resolved only from the cached ~/.m2 and ~/.gradle layers, for reproducible builds.
+	OfflineEnv = "GOOGLE_JAVA_OFFLINE"
+
+	// ReproducibleTimestamp is the timestamp of the entries of the archives built by Maven and Gradle
+	// when env.ReproducibleBuild is set. It is the earliest timestamp accepted by the Maven archiver
+	// since zip files cannot represent times before 1980.
+	ReproducibleTimestamp = "1980-01-01T00:00:02Z"
 )
 
 var (
@@ -92,6 +134,31 @@ func ExecutableJar(ctx *gcp.Context) (string, error) {
 	return "", gcp.UserErrorf("did not find any jar files with a Main-Class manifest entry")
 }
 
+// UsePrebuiltJar returns true if GOOGLE_PREBUILT is true and the build with tool, e.g. Maven,
+// must be skipped in favor of the prebuilt executable jar.
+func UsePrebuiltJar(ctx *gcp.Context, tool string) (bool, error) {
+	jar, err := prebuiltJar(ctx)
+	if err != nil || jar == "" {
+		return false, err
+	}
+	ctx.Logf("Skipping the %s build because %s is set, using the prebuilt jar %s.", tool, env.Prebuilt, jar)
+	return true, nil
+}
+
+// prebuiltJar returns the executable jar to run instead of building the application if
+// GOOGLE_PREBUILT is true, or an empty string if the application should be built from source.
+func prebuiltJar(ctx *gcp.Context) (string, error) {
+	prebuilt, err := env.IsPresentAndTrue(env.Prebuilt)
+	if err != nil || !prebuilt {
+		return "", err
+	}
+	jar, err := ExecutableJar(ctx)
+	if err != nil {
+		return "", gcp.UserErrorf("%s is set but no prebuilt jar was found: %w", env.Prebuilt, err)
+	}
+	return jar, nil
+}
+
 func filterExecutables(ctx *gcp.Context, jars []string) []string {
 	var executables []string
 	for _, jar := range jars {
@@ -164,6 +231,7 @@ func findValueFromManifest(manifestContent []byte, key string) (string, error) {
 }
 
 // CheckCacheExpiration clears the m2 layer and sets a new expiry timestamp when the cache is past expiration.
+// Expired caches are kept in offline mode.
 func CheckCacheExpiration(ctx *gcp.Context, m2CachedRepo *libcnb.Layer) error {
 	t := time.Now()
 	expiry := ctx.GetMetadata(m2CachedRepo, expiryTimestampKey)
@@ -177,6 +245,15 @@ func CheckCacheExpiration(ctx *gcp.Context, m2CachedRepo *libcnb.Layer) error {
 	if t.After(time.Now()) {
 		return nil
 	}
+	offline, err := Offline()
+	if err != nil {
+		return err
+	}
+	if offline && expiry != "" {
+		// Offline builds can only resolve dependencies from the cache, so it must not be cleared.
+		ctx.Logf("Keeping the expired dependency cache because %s is set.", OfflineEnv)
+		return nil
+	}
 
 	ctx.Debugf("Cache expired on %v, clearing", t)
 	if err := ctx.ClearLayer(m2CachedRepo); err != nil {
@@ -186,6 +263,33 @@ func CheckCacheExpiration(ctx *gcp.Context, m2CachedRepo *libcnb.Layer) error {
 	return nil
 }
 
+// Offline returns true if Maven and Gradle must resolve dependencies from the cache only.
+func Offline() (bool, error) {
+	return env.IsPresentAndTrue(OfflineEnv)
+}
+
+// RunTests returns true if the Maven and Gradle builds must run the tests of the application.
+func RunTests() (bool, error) {
+	return env.IsPresentAndTrue(RunTestsEnv)
+}
+
+// EnvArgs returns the arguments set in the given env var, such as GOOGLE_BUILD_ARGS, split as by a
+// shell so that quoted arguments may contain spaces.
+func EnvArgs(name string) ([]string, error) {
+	raw := os.Getenv(name)
+	args, err := env.SplitArgs(raw)
+	if err != nil {
+		return nil, gcp.UserErrorf("invalid %s %q: %v", name, raw, err)
+	}
+	return args, nil
+}
+
+// OfflineBuildError annotates the error of a build that ran in offline mode: the most likely cause
+// is a dependency that is missing from the cache.
+func OfflineBuildError(err error) error {
+	return gcp.UserErrorf("the build failed in offline mode because %s is set, a required dependency may be missing from the cache. Run a build without %s to populate the cache: %w", OfflineEnv, OfflineEnv, err)
+}
+
 // MvnCmd returns the command that should be used to invoke maven for this build.
 func MvnCmd(ctx *gcp.Context) (string, error) {
 	exists, err := ctx.FileExists("mvnw")
@@ -211,3 +315,21 @@ func GradleCmd(ctx *gcp.Context) (string, error) {
 	}
 	return "gradle", nil
 }
+
+// toolVersionsFeatureRegexp captures the feature version of an asdf java version, e.g. 21 in
+// temurin-21.0.2+13.0.LTS, openjdk-21 or corretto-17.0.9.8.1.
+var toolVersionsFeatureRegexp = regexp.MustCompile(`(?:^|-)([0-9]+)(?:[.+_]|$)`)
+
+// ToolVersionsFeatureVersion returns the feature version of the JDK declared in the .tool-versions
+// file, or an empty string if it does not declare one.
+func ToolVersionsFeatureVersion(ctx *gcp.Context) (string, error) {
+	v, err := toolversions.Version(ctx, toolversions.Java)
+	if err != nil || v == "" {
+		return "", err
+	}
+	m := toolVersionsFeatureRegexp.FindStringSubmatch(v)
+	if m == nil {
+		return "", gcp.UserErrorf("unable to find the Java feature version in %q in %s", v, toolversions.File)
+	}
+	return m[1], nil
+}
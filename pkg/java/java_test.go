@@ -23,6 +23,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/buildpacks/libcnb"
 )
@@ -255,6 +256,100 @@ func TestCheckCacheNewDateHit(t *testing.T) {
 	}
 }
 
+func TestCheckCacheExpiredOffline(t *testing.T) {
+	t.Setenv(OfflineEnv, "true")
+	ctx := gcp.NewContext()
+	testFilePath, m2CachedRepo := setupTestLayer(t, ctx)
+	expiry := time.Now().Truncate(repoExpiration).Format(dateFormat)
+	ctx.SetMetadata(m2CachedRepo, "expiry_timestamp", expiry)
+
+	if err := CheckCacheExpiration(ctx, m2CachedRepo); err != nil {
+		t.Fatalf("CheckCacheExpiration() unexpected error = %q", err.Error())
+	}
+	if got := ctx.GetMetadata(m2CachedRepo, "expiry_timestamp"); got != expiry {
+		t.Errorf("CheckCacheExpiration() set new date %q in offline mode, want %q", got, expiry)
+	}
+	if _, err := os.Stat(testFilePath); err != nil {
+		t.Errorf("CheckCacheExpiration() cleared layer in offline mode")
+	}
+}
+
+func TestUsePrebuiltJar(t *testing.T) {
+	testCases := []struct {
+		name     string
+		prebuilt string
+		jar      bool
+		want     string
+		wantErr  bool
+	}{
+		{
+			name: "not prebuilt",
+			jar:  true,
+		},
+		{
+			name:     "prebuilt jar",
+			prebuilt: "true",
+			jar:      true,
+			want:     "target/app.jar",
+		},
+		{
+			name:     "prebuilt jar missing",
+			prebuilt: "true",
+			wantErr:  true,
+		},
+		{
+			name:     "invalid prebuilt value",
+			prebuilt: "yes please",
+			jar:      true,
+			wantErr:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.prebuilt != "" {
+				t.Setenv(env.Prebuilt, tc.prebuilt)
+			}
+			appRoot := t.TempDir()
+			if tc.jar {
+				if err := os.Mkdir(filepath.Join(appRoot, "target"), 0755); err != nil {
+					t.Fatalf("creating dir: %v", err)
+				}
+				jarPath := setupTestJar(t, []byte("Main-Class: com.example.Main\n"))
+				if err := os.Rename(jarPath, filepath.Join(appRoot, "target", "app.jar")); err != nil {
+					t.Fatalf("moving jar: %v", err)
+				}
+			}
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(appRoot))
+
+			got, err := UsePrebuiltJar(ctx, "Maven")
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("UsePrebuiltJar() = %t, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("UsePrebuiltJar() got error: %v", err)
+			}
+			if want := tc.want != ""; got != want {
+				t.Errorf("UsePrebuiltJar() = %t, want %t", got, want)
+			}
+			jar, err := prebuiltJar(ctx)
+			if err != nil {
+				t.Fatalf("prebuiltJar() got error: %v", err)
+			}
+			want := ""
+			if tc.want != "" {
+				want = filepath.Join(appRoot, tc.want)
+			}
+			if jar != want {
+				t.Errorf("prebuiltJar() = %q, want %q", jar, want)
+			}
+		})
+	}
+}
+
 func setupTestLayer(t *testing.T, ctx *gcp.Context) (string, *libcnb.Layer) {
 	t.Helper()
 	testLayerRoot := t.TempDir()
@@ -305,3 +400,58 @@ func setupTestJar(t *testing.T, mfContent []byte) string {
 	}
 	return jarPath
 }
+
+func TestToolVersionsFeatureVersion(t *testing.T) {
+	testCases := []struct {
+		name         string
+		toolVersions string
+		want         string
+		wantErr      bool
+	}{
+		{
+			name:         "temurin",
+			toolVersions: "nodejs 20.11.0\njava temurin-21.0.2+13.0.LTS\n",
+			want:         "21",
+		},
+		{
+			name:         "openjdk",
+			toolVersions: "java openjdk-17",
+			want:         "17",
+		},
+		{
+			name:         "corretto",
+			toolVersions: "java corretto-11.0.22.7.1",
+			want:         "11",
+		},
+		{
+			name:         "no java",
+			toolVersions: "ruby 3.3.0",
+		},
+		{
+			name: "no .tool-versions",
+		},
+		{
+			name:         "no feature version",
+			toolVersions: "java latest",
+			wantErr:      true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tc.toolVersions != "" {
+				if err := os.WriteFile(filepath.Join(dir, ".tool-versions"), []byte(tc.toolVersions), 0644); err != nil {
+					t.Fatalf("writing .tool-versions: %v", err)
+				}
+			}
+
+			got, err := ToolVersionsFeatureVersion(gcp.NewContext(gcp.WithApplicationRoot(dir)))
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("ToolVersionsFeatureVersion() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("ToolVersionsFeatureVersion() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
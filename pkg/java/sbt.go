@@ -0,0 +1,173 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/fetch"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	sbtVersion    = "1.10.7"
+	sbtLayer      = "sbt"
+	sbtVersionKey = "version"
+)
+
+var (
+	// assemblyPluginRegexp matches the sbt-assembly plugin declaration in project/*.sbt files.
+	assemblyPluginRegexp = regexp.MustCompile(`(?m)^\s*addSbtPlugin\(.*"sbt-assembly"`)
+	sbtURL               = "https://github.com/sbt/sbt/releases/download/v%[1]s/sbt-%[1]s.tgz"
+	// sbtChecksumURL is the SHA256 checksum published with each sbt release.
+	sbtChecksumURL = "https://github.com/sbt/sbt/releases/download/v%[1]s/sbt-%[1]s.tgz.sha256"
+)
+
+// ScalaClasspath determines the --classpath when there is a build.sbt. The function is built into a
+// self-contained fat jar with `sbt assembly`, which means that it can be the only thing given to
+// --classpath.
+func ScalaClasspath(ctx *gcp.Context) (string, error) {
+	buildSbtExists, err := ctx.FileExists("build.sbt")
+	if err != nil {
+		return "", err
+	}
+	if !buildSbtExists {
+		return "", gcp.UserErrorf("function has no build.sbt")
+	}
+	hasPlugin, err := hasAssemblyPlugin(ctx)
+	if err != nil {
+		return "", err
+	}
+	if !hasPlugin {
+		return "", gcp.UserErrorf(`function has a build.sbt but does not use the sbt-assembly plugin; add addSbtPlugin("com.eed3si9n" %% "sbt-assembly" %% "<version>") to project/plugins.sbt`)
+	}
+
+	sbt, err := sbtCmd(ctx)
+	if err != nil {
+		return "", err
+	}
+	if _, err := ctx.Exec([]string{sbt, "-batch", "assembly"}, gcp.WithUserAttribution); err != nil {
+		return "", err
+	}
+
+	jars, err := ctx.Glob(filepath.Join("target", "scala-*", "*.jar"))
+	if err != nil {
+		return "", fmt.Errorf("finding assembled jar files: %w", err)
+	}
+	if len(jars) > 1 {
+		// Other tasks may leave the thin jar built by `sbt package` next to the assembled one.
+		var assembled []string
+		for _, jar := range jars {
+			if strings.Contains(filepath.Base(jar), "-assembly") {
+				assembled = append(assembled, jar)
+			}
+		}
+		jars = assembled
+	}
+	if len(jars) == 0 {
+		return "", gcp.UserErrorf("sbt assembly did not produce a jar file in target/scala-*/")
+	}
+	if len(jars) > 1 {
+		return "", gcp.UserErrorf("sbt assembly produced more than one jar file: %s", strings.Join(jars, ", "))
+	}
+	return jars[0], nil
+}
+
+// hasAssemblyPlugin returns true if the sbt-assembly plugin is declared in the sbt project definition.
+func hasAssemblyPlugin(ctx *gcp.Context) (bool, error) {
+	files, err := ctx.Glob(filepath.Join(ctx.ApplicationRoot(), "project", "*.sbt"))
+	if err != nil {
+		return false, fmt.Errorf("finding sbt plugin files: %w", err)
+	}
+	for _, f := range files {
+		content, err := ctx.ReadFile(f)
+		if err != nil {
+			return false, err
+		}
+		if assemblyPluginRegexp.Match(content) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// sbtCmd returns the sbt command, installing sbt if it is not already available.
+func sbtCmd(ctx *gcp.Context) (string, error) {
+	result, err := ctx.Exec([]string{"bash", "-c", "command -v sbt || true"})
+	if err != nil {
+		return "", err
+	}
+	if result.Stdout != "" {
+		return "sbt", nil
+	}
+	sbt, err := installSbt(ctx)
+	if err != nil {
+		return "", fmt.Errorf("installing sbt: %w", err)
+	}
+	return sbt, nil
+}
+
+// installSbt installs sbt and returns the path of the sbt binary.
+func installSbt(ctx *gcp.Context) (string, error) {
+	sbtl, err := ctx.Layer(sbtLayer, gcp.CacheLayer, gcp.BuildLayer)
+	if err != nil {
+		return "", fmt.Errorf("creating %v layer: %w", sbtLayer, err)
+	}
+
+	// Check the metadata in the cache layer to determine if we need to proceed.
+	metaVersion := ctx.GetMetadata(sbtl, sbtVersionKey)
+	if sbtVersion == metaVersion {
+		ctx.CacheHit(sbtLayer)
+		ctx.Logf("sbt cache hit, skipping installation.")
+		return filepath.Join(sbtl.Path, "bin", "sbt"), nil
+	}
+	ctx.CacheMiss(sbtLayer)
+	if err := ctx.ClearLayer(sbtl); err != nil {
+		return "", fmt.Errorf("clearing layer %q: %w", sbtl.Name, err)
+	}
+
+	// Download and install sbt in layer.
+	ctx.Logf("Installing sbt v%s", sbtVersion)
+	checksum, err := sbtChecksum(sbtVersion)
+	if err != nil {
+		return "", err
+	}
+	archiveURL := fmt.Sprintf(sbtURL, sbtVersion)
+	if err := fetch.TarballWithSHA256(archiveURL, sbtl.Path, 1, checksum); err != nil {
+		return "", fmt.Errorf("fetching sbt v%s: %w", sbtVersion, err)
+	}
+
+	ctx.SetMetadata(sbtl, sbtVersionKey, sbtVersion)
+	return filepath.Join(sbtl.Path, "bin", "sbt"), nil
+}
+
+// sbtChecksum returns the SHA256 checksum published for the sbt release, in the format written by
+// sha256sum.
+func sbtChecksum(version string) (string, error) {
+	url := fmt.Sprintf(sbtChecksumURL, version)
+	var sums bytes.Buffer
+	if err := fetch.GetURL(url, &sums); err != nil {
+		return "", fmt.Errorf("fetching the checksum of sbt v%s: %w", version, err)
+	}
+	fields := strings.Fields(sums.String())
+	if len(fields) == 0 {
+		return "", gcp.InternalErrorf("the checksum of sbt v%s at %s is empty", version, url)
+	}
+	return fields[0], nil
+}
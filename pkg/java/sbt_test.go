@@ -0,0 +1,178 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+func TestHasAssemblyPlugin(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		want  bool
+	}{
+		{
+			name: "no project directory",
+		},
+		{
+			name: "plugins.sbt",
+			files: map[string]string{
+				"project/plugins.sbt": `addSbtPlugin("com.eed3si9n" % "sbt-assembly" % "2.3.0")`,
+			},
+			want: true,
+		},
+		{
+			name: "other sbt file",
+			files: map[string]string{
+				"project/plugins.sbt":  `addSbtPlugin("org.scalameta" % "sbt-scalafmt" % "2.5.2")`,
+				"project/assembly.sbt": `  addSbtPlugin("com.eed3si9n" % "sbt-assembly" % "2.3.0")`,
+			},
+			want: true,
+		},
+		{
+			name: "commented out",
+			files: map[string]string{
+				"project/plugins.sbt": `// addSbtPlugin("com.eed3si9n" % "sbt-assembly" % "2.3.0")`,
+			},
+		},
+		{
+			name: "other plugins",
+			files: map[string]string{
+				"project/plugins.sbt": `addSbtPlugin("org.scalameta" % "sbt-scalafmt" % "2.5.2")`,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tc.files {
+				path := filepath.Join(dir, name)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatalf("creating directory for %s: %v", name, err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+					t.Fatalf("writing %s: %v", name, err)
+				}
+			}
+
+			got, err := hasAssemblyPlugin(gcp.NewContext(gcp.WithApplicationRoot(dir)))
+			if err != nil {
+				t.Fatalf("hasAssemblyPlugin() got error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("hasAssemblyPlugin() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestInstallSbt(t *testing.T) {
+	tarball := sbtTarball(t)
+	sum := sha256.Sum256(tarball)
+	testCases := []struct {
+		name     string
+		checksum string
+		wantErr  bool
+	}{
+		{
+			name:     "matching checksum",
+			checksum: hex.EncodeToString(sum[:]) + "  sbt-" + sbtVersion + ".tgz\n",
+		},
+		{
+			name:     "mismatched checksum",
+			checksum: hex.EncodeToString(make([]byte, sha256.Size)) + "  sbt-" + sbtVersion + ".tgz\n",
+			wantErr:  true,
+		},
+		{
+			name:    "checksum not published",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/sbt.tgz":
+					w.Write(tarball)
+				case "/sbt.tgz.sha256":
+					if tc.checksum == "" {
+						w.WriteHeader(http.StatusNotFound)
+						return
+					}
+					w.Write([]byte(tc.checksum))
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer svr.Close()
+			origURL, origChecksumURL := sbtURL, sbtChecksumURL
+			defer func() { sbtURL, sbtChecksumURL = origURL, origChecksumURL }()
+			sbtURL = svr.URL + "/sbt.tgz?v=%s"
+			sbtChecksumURL = svr.URL + "/sbt.tgz.sha256?v=%s"
+			layers := t.TempDir()
+			ctx := gcp.NewContext(gcp.WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: layers}}))
+
+			got, err := installSbt(ctx)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("installSbt() got error: %v, want error: %v", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+			if want := filepath.Join(layers, sbtLayer, "bin", "sbt"); got != want {
+				t.Errorf("installSbt() = %q, want %q", got, want)
+			}
+			if _, err := os.Stat(got); err != nil {
+				t.Errorf("installSbt() did not extract bin/sbt: %v", err)
+			}
+		})
+	}
+}
+
+// sbtTarball returns a gzipped tarball laid out like an sbt release.
+func sbtTarball(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	content := []byte("#!/bin/sh\n")
+	if err := tw.WriteHeader(&tar.Header{Name: "sbt/bin/sbt", Mode: 0755, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
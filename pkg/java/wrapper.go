@@ -0,0 +1,118 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// MavenWrapperVersionEnv is an env var used to pin the Maven version for reproducible builds. If
+	// the project has a Maven wrapper (mvnw), the build fails unless the wrapper uses this version.
+	// Otherwise, this version of Maven is installed instead of the default one.
+	MavenWrapperVersionEnv = "GOOGLE_MAVEN_WRAPPER_VERSION"
+
+	// GradleWrapperVersionEnv is an env var used to pin the Gradle version for reproducible builds.
+	// If the project has a Gradle wrapper (gradlew), the build fails unless the wrapper uses this
+	// version. Otherwise, this version of Gradle is installed instead of the latest one.
+	GradleWrapperVersionEnv = "GOOGLE_GRADLE_WRAPPER_VERSION"
+
+	// MavenWrapperProperties is the file that declares the Maven distribution used by mvnw.
+	MavenWrapperProperties = ".mvn/wrapper/maven-wrapper.properties"
+
+	// GradleWrapperProperties is the file that declares the Gradle distribution used by gradlew.
+	GradleWrapperProperties = "gradle/wrapper/gradle-wrapper.properties"
+)
+
+var (
+	// toolVersionRegexp matches a Maven or Gradle version, e.g. 3.9.9 or 8.10-rc-1.
+	toolVersionRegexp = regexp.MustCompile(`^[0-9]+(\.[0-9]+)*(-[0-9A-Za-z.-]+)?$`)
+	// distributionURLRegexp matches the distributionUrl property of a wrapper properties file.
+	distributionURLRegexp = regexp.MustCompile(`(?m)^\s*distributionUrl\s*[=:]\s*(\S+)`)
+	// mavenDistributionRegexp matches the archive name of a Maven distribution and captures its
+	// version.
+	mavenDistributionRegexp = regexp.MustCompile(`apache-maven-([^/]+)-bin\.(?:zip|tar\.gz)$`)
+	// gradleDistributionRegexp matches the archive name of a Gradle distribution and captures its
+	// version.
+	gradleDistributionRegexp = regexp.MustCompile(`gradle-([^/]+)-(?:bin|all)\.zip$`)
+)
+
+// PinnedToolVersion returns the Maven or Gradle version pinned with the given env var, or an empty
+// string if it is not set.
+func PinnedToolVersion(envName string) (string, error) {
+	v := os.Getenv(envName)
+	if v == "" {
+		return "", nil
+	}
+	if !toolVersionRegexp.MatchString(v) {
+		return "", gcp.UserErrorf("invalid version %q set by %s, e.g. 3.9.9", v, envName)
+	}
+	return v, nil
+}
+
+// CheckMavenWrapperVersion returns an error if the Maven wrapper does not use the pinned version.
+func CheckMavenWrapperVersion(ctx *gcp.Context, pinned string) error {
+	return checkWrapperVersion(ctx, pinned, MavenWrapperVersionEnv, "mvnw", MavenWrapperProperties, mavenDistributionRegexp)
+}
+
+// CheckGradleWrapperVersion returns an error if the Gradle wrapper does not use the pinned version.
+func CheckGradleWrapperVersion(ctx *gcp.Context, pinned string) error {
+	return checkWrapperVersion(ctx, pinned, GradleWrapperVersionEnv, "gradlew", GradleWrapperProperties, gradleDistributionRegexp)
+}
+
+func checkWrapperVersion(ctx *gcp.Context, pinned, envName, wrapper, properties string, distributionRegexp *regexp.Regexp) error {
+	if pinned == "" {
+		return nil
+	}
+	declared, err := wrapperVersion(ctx, properties, distributionRegexp)
+	if err != nil {
+		return err
+	}
+	if declared == "" {
+		return gcp.UserErrorf("%s is set but the version used by %s could not be found in the distributionUrl of %s", envName, wrapper, properties)
+	}
+	if declared != pinned {
+		return gcp.UserErrorf("%s uses version %s according to %s, but %s=%s: update the wrapper or %s", wrapper, declared, properties, envName, pinned, envName)
+	}
+	ctx.Logf("Verified that %s uses version %s set by %s.", wrapper, pinned, envName)
+	return nil
+}
+
+// wrapperVersion returns the version of the distribution declared in the given wrapper properties
+// file, or an empty string if the file or the version is not found.
+func wrapperVersion(ctx *gcp.Context, properties string, distributionRegexp *regexp.Regexp) (string, error) {
+	path := filepath.Join(ctx.ApplicationRoot(), properties)
+	exists, err := ctx.FileExists(path)
+	if err != nil || !exists {
+		return "", err
+	}
+	content, err := ctx.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	url := distributionURLRegexp.FindSubmatch(content)
+	if url == nil {
+		return "", nil
+	}
+	version := distributionRegexp.FindSubmatch(url[1])
+	if version == nil {
+		return "", nil
+	}
+	return string(version[1]), nil
+}
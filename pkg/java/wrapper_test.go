@@ -0,0 +1,126 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+func TestPinnedToolVersion(t *testing.T) {
+	testCases := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{name: "unset"},
+		{name: "release", value: "3.9.9", want: "3.9.9"},
+		{name: "major and minor", value: "8.5", want: "8.5"},
+		{name: "release candidate", value: "8.10-rc-1", want: "8.10-rc-1"},
+		{name: "path", value: "../3.9.9", wantErr: true},
+		{name: "shell command", value: "3.9.9; rm -rf /", wantErr: true},
+		{name: "latest", value: "latest", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(MavenWrapperVersionEnv, tc.value)
+
+			got, err := PinnedToolVersion(MavenWrapperVersionEnv)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("PinnedToolVersion() got error: %v, want error: %v", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("PinnedToolVersion() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCheckWrapperVersion(t *testing.T) {
+	testCases := []struct {
+		name       string
+		properties map[string]string
+		check      func(*gcp.Context, string) error
+		pinned     string
+		wantErr    bool
+	}{
+		{
+			name:       "maven match",
+			properties: map[string]string{MavenWrapperProperties: "distributionUrl=https://repo.maven.apache.org/maven2/org/apache/maven/apache-maven/3.9.6/apache-maven-3.9.6-bin.zip\n"},
+			check:      CheckMavenWrapperVersion,
+			pinned:     "3.9.6",
+		},
+		{
+			name:       "maven mismatch",
+			properties: map[string]string{MavenWrapperProperties: "distributionUrl=https://repo.maven.apache.org/maven2/org/apache/maven/apache-maven/3.9.6/apache-maven-3.9.6-bin.zip\n"},
+			check:      CheckMavenWrapperVersion,
+			pinned:     "3.8.8",
+			wantErr:    true,
+		},
+		{
+			name:       "maven without distributionUrl",
+			properties: map[string]string{MavenWrapperProperties: "wrapperUrl=https://repo.maven.apache.org/maven2/org/apache/maven/wrapper/maven-wrapper/3.2.0/maven-wrapper-3.2.0.jar\n"},
+			check:      CheckMavenWrapperVersion,
+			pinned:     "3.9.6",
+			wantErr:    true,
+		},
+		{
+			name:    "maven without properties",
+			check:   CheckMavenWrapperVersion,
+			pinned:  "3.9.6",
+			wantErr: true,
+		},
+		{
+			name:  "maven not pinned",
+			check: CheckMavenWrapperVersion,
+		},
+		{
+			name:       "gradle match",
+			properties: map[string]string{GradleWrapperProperties: "distributionBase=GRADLE_USER_HOME\ndistributionUrl=https\\://services.gradle.org/distributions/gradle-8.5-all.zip\n"},
+			check:      CheckGradleWrapperVersion,
+			pinned:     "8.5",
+		},
+		{
+			name:       "gradle mismatch",
+			properties: map[string]string{GradleWrapperProperties: "distributionUrl=https\\://services.gradle.org/distributions/gradle-8.5-bin.zip\n"},
+			check:      CheckGradleWrapperVersion,
+			pinned:     "8.4.1",
+			wantErr:    true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tc.properties {
+				path := filepath.Join(dir, name)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := tc.check(gcp.NewContext(gcp.WithApplicationRoot(dir)), tc.pinned)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("checking wrapper version got error: %v, want error: %v", err, tc.wantErr)
+			}
+		})
+	}
+}
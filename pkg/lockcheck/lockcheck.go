@@ -0,0 +1,129 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package lockcheck implements GOOGLE_VERIFY_LOCKFILE: the policy that makes each package manager
+// fail the build when its lockfile is out of sync with the dependencies declared by the
+// application, instead of silently resolving them again.
+package lockcheck
+
+import (
+	"regexp"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+// Manager identifies a package manager.
+type Manager string
+
+const (
+	// NPM is npm, whose "npm ci" command always fails on drift.
+	NPM Manager = "npm"
+	// Yarn is Yarn classic (1.x). Yarn 2+ always installs with --immutable.
+	Yarn Manager = "yarn"
+	// PNPM is pnpm.
+	PNPM Manager = "pnpm"
+	// Bundler is Ruby's bundler.
+	Bundler Manager = "bundler"
+)
+
+// policy describes how a package manager verifies its lockfile.
+type policy struct {
+	// installFlags are added to the install command.
+	installFlags []string
+	// check is a command run before the install, with checkEnv.
+	check    []string
+	checkEnv []string
+	// driftRegexp matches the output of a command that failed because of drift.
+	driftRegexp *regexp.Regexp
+	manifest    string
+	lockfile    string
+	// regenerate is the command that updates the lockfile.
+	regenerate string
+}
+
+var policies = map[Manager]policy{
+	NPM: {
+		driftRegexp: regexp.MustCompile(`can only install packages when your package\.json and`),
+		manifest:    "package.json",
+		lockfile:    "package-lock.json",
+		regenerate:  "npm install",
+	},
+	Yarn: {
+		installFlags: []string{"--frozen-lockfile"},
+		driftRegexp:  regexp.MustCompile(`Your lockfile needs to be updated`),
+		manifest:     "package.json",
+		lockfile:     "yarn.lock",
+		regenerate:   "yarn install",
+	},
+	PNPM: {
+		installFlags: []string{"--frozen-lockfile"},
+		driftRegexp:  regexp.MustCompile(`ERR_PNPM_OUTDATED_LOCKFILE|lockfile needs updates`),
+		manifest:     "package.json",
+		lockfile:     "pnpm-lock.yaml",
+		regenerate:   "pnpm install",
+	},
+	Bundler: {
+		check: []string{"bundle", "check"},
+		// Frozen mode makes bundler report changes to the Gemfile instead of resolving them.
+		checkEnv: []string{"BUNDLE_FROZEN=true"},
+		// Missing gems are expected before the install, only changes to the Gemfile are drift.
+		driftRegexp: regexp.MustCompile(`(?:frozen|deployment) mode|lockfile can't be updated|Gemfile changed`),
+		manifest:    "Gemfile",
+		lockfile:    "Gemfile.lock",
+		regenerate:  "bundle install",
+	},
+}
+
+// Verify returns true if GOOGLE_VERIFY_LOCKFILE is set to true.
+func Verify() (bool, error) {
+	return env.IsVerifyLockfile()
+}
+
+// InstallFlags returns the flags that make the install command of the package manager fail on
+// drift.
+func InstallFlags(m Manager) []string {
+	return policies[m].installFlags
+}
+
+// Check runs the check command of the package manager, if it has one, and returns a DriftError if
+// it reports drift. Other failures, such as dependencies that are not installed yet, are left to
+// the install.
+func Check(ctx *gcp.Context, m Manager, lockfile string) error {
+	p := policies[m]
+	if p.check == nil {
+		return nil
+	}
+	result, err := ctx.Exec(p.check, gcp.WithEnv(p.checkEnv...), gcp.WithUserAttribution)
+	if err != nil && result != nil && IsDrift(m, result.Combined) {
+		return DriftError(m, lockfile, err)
+	}
+	return nil
+}
+
+// IsDrift returns true if the output of a failed command reports that the lockfile is out of sync.
+func IsDrift(m Manager, output string) bool {
+	p, ok := policies[m]
+	return ok && p.driftRegexp.MatchString(output)
+}
+
+// DriftError returns a user error explaining how to regenerate the lockfile of the package manager.
+// lockfile overrides the default name of the lockfile if not empty.
+func DriftError(m Manager, lockfile string, err error) error {
+	p := policies[m]
+	if lockfile == "" {
+		lockfile = p.lockfile
+	}
+	return gcp.UserErrorf("%s is out of sync with %s and %s is set. Regenerate it by running %q, commit the updated %s, and deploy again: %w", lockfile, p.manifest, env.VerifyLockfile, p.regenerate, lockfile, err)
+}
@@ -0,0 +1,132 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lockcheck
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestInstallFlags(t *testing.T) {
+	testCases := []struct {
+		manager Manager
+		want    []string
+	}{
+		{manager: NPM},
+		{manager: Yarn, want: []string{"--frozen-lockfile"}},
+		{manager: PNPM, want: []string{"--frozen-lockfile"}},
+		{manager: Bundler},
+	}
+	for _, tc := range testCases {
+		t.Run(string(tc.manager), func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, InstallFlags(tc.manager)); diff != "" {
+				t.Errorf("InstallFlags(%q) mismatch (-want +got):\n%s", tc.manager, diff)
+			}
+		})
+	}
+}
+
+func TestCheckCommand(t *testing.T) {
+	if diff := cmp.Diff([]string{"bundle", "check"}, policies[Bundler].check); diff != "" {
+		t.Errorf("bundler check command mismatch (-want +got):\n%s", diff)
+	}
+	for _, m := range []Manager{NPM, Yarn, PNPM} {
+		if got := policies[m].check; got != nil {
+			t.Errorf("%s check command = %q, want none", m, got)
+		}
+	}
+}
+
+func TestIsDrift(t *testing.T) {
+	testCases := []struct {
+		name    string
+		manager Manager
+		output  string
+		want    bool
+	}{
+		{
+			name:    "npm ci",
+			manager: NPM,
+			output:  "npm ERR! `npm ci` can only install packages when your package.json and package-lock.json or npm-shrinkwrap.json are in sync.",
+			want:    true,
+		},
+		{
+			name:    "yarn frozen lockfile",
+			manager: Yarn,
+			output:  "error Your lockfile needs to be updated, but yarn was run with `--frozen-lockfile`.",
+			want:    true,
+		},
+		{
+			name:    "pnpm frozen lockfile",
+			manager: PNPM,
+			output:  "ERR_PNPM_OUTDATED_LOCKFILE  Cannot install with \"frozen-lockfile\" because pnpm-lock.yaml is not up to date with package.json",
+			want:    true,
+		},
+		{
+			name:    "bundler frozen mode",
+			manager: Bundler,
+			output:  "You are trying to install in frozen mode after changing your Gemfile. Run `bundle install` elsewhere and add the updated Gemfile.lock to version control.",
+			want:    true,
+		},
+		{
+			name:    "bundler missing gems",
+			manager: Bundler,
+			output:  "Bundler can't satisfy your Gemfile's dependencies.\nInstall missing gems with `bundle install`.",
+		},
+		{
+			name:    "network error",
+			manager: NPM,
+			output:  "npm ERR! network request to https://registry.npmjs.org/express failed",
+		},
+		{
+			name:    "unknown manager",
+			manager: "pip",
+			output:  "Your lockfile needs to be updated",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := IsDrift(tc.manager, tc.output); got != tc.want {
+				t.Errorf("IsDrift(%q, %q) = %t, want %t", tc.manager, tc.output, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDriftError(t *testing.T) {
+	testCases := []struct {
+		manager  Manager
+		lockfile string
+		want     []string
+	}{
+		{manager: NPM, lockfile: "npm-shrinkwrap.json", want: []string{"npm-shrinkwrap.json is out of sync with package.json", `"npm install"`}},
+		{manager: Yarn, want: []string{"yarn.lock is out of sync with package.json", `"yarn install"`}},
+		{manager: PNPM, want: []string{"pnpm-lock.yaml is out of sync with package.json", `"pnpm install"`}},
+		{manager: Bundler, want: []string{"Gemfile.lock is out of sync with Gemfile", `"bundle install"`}},
+	}
+	for _, tc := range testCases {
+		t.Run(string(tc.manager), func(t *testing.T) {
+			err := DriftError(tc.manager, tc.lockfile, errors.New("exit code 1"))
+			for _, want := range append(tc.want, "GOOGLE_VERIFY_LOCKFILE", "exit code 1") {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("DriftError(%q, %q) = %q, want it to contain %q", tc.manager, tc.lockfile, err, want)
+				}
+			}
+		})
+	}
+}
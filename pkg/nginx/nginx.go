@@ -77,6 +77,11 @@ include = {{.ConfOverride}}
 // the pid1 program.
 var NginxTemplate = template.Must(template.New("nginx").Parse(`
 fastcgi_read_timeout 24h;
+{{- if .StubStatus}}
+
+# Logs the connection counters of nginx on each request to the status endpoint.
+log_format	stub_status	'$time_iso8601 active_connections=$connections_active reading=$connections_reading writing=$connections_writing waiting=$connections_waiting';
+{{- end}}
 
 # proxy_* are not set for PHP because fastcgi is used.
 
@@ -90,14 +95,30 @@ server {
 	server_name	"";
 	root	{{.Root}};
 
+	{{- if .SecurityHeaders}}
+
+	add_header	X-Content-Type-Options	"nosniff"	always;
+	add_header	X-Frame-Options	"SAMEORIGIN"	always;
+	add_header	Referrer-Policy	"strict-origin-when-cross-origin"	always;
+	add_header	Permissions-Policy	"camera=(), microphone=()"	always;
+	{{- end}}
+
 	{{if .ServesStaticFiles}}
 	location / {
 		try_files $uri /{{.FrontControllerScript}}$uri$is_args$args;
 	}
 	{{else}}
-	rewrite	^/(.*)$	/{{.FrontControllerScript}}$uri;
+	rewrite	^/{{if .StubStatus}}(?!_nginx/status$){{end}}(.*)$	/{{.FrontControllerScript}}$uri;
 	{{end}}
 
+	{{- if .StubStatus}}
+
+	location = /_nginx/status {
+		stub_status;
+		access_log	/dev/stdout	stub_status;
+	}
+	{{- end}}
+
 	location	~	^/{{.FrontControllerScript}}	{
 		error_log stderr;
 
@@ -168,6 +189,8 @@ type Config struct {
 	FrontControllerScript string
 	NginxConfInclude      string
 	ServesStaticFiles     bool
+	SecurityHeaders       bool
+	StubStatus            bool
 }
 
 const (
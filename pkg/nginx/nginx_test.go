@@ -0,0 +1,127 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nginx
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteNginxConfigToPath(t *testing.T) {
+	securityHeaders := []string{
+		`add_header	X-Content-Type-Options	"nosniff"	always;`,
+		`add_header	X-Frame-Options	"SAMEORIGIN"	always;`,
+		`add_header	Referrer-Policy	"strict-origin-when-cross-origin"	always;`,
+		`add_header	Permissions-Policy	"camera=(), microphone=()"	always;`,
+	}
+	testCases := []struct {
+		name        string
+		conf        Config
+		wantHeaders bool
+	}{
+		{
+			name: "default",
+			conf: Config{Port: 8080, Root: "/workspace", FrontControllerScript: "index.php"},
+		},
+		{
+			name:        "security headers",
+			conf:        Config{Port: 8080, Root: "/workspace", FrontControllerScript: "index.php", SecurityHeaders: true},
+			wantHeaders: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			f, err := WriteNginxConfigToPath(dir, tc.conf)
+			if err != nil {
+				t.Fatalf("WriteNginxConfigToPath(%v) got error: %v", tc.conf, err)
+			}
+			f.Close()
+			content, err := os.ReadFile(filepath.Join(dir, nginxServerConf))
+			if err != nil {
+				t.Fatalf("reading nginx config: %v", err)
+			}
+			got := string(content)
+
+			if !strings.Contains(got, "listen	8080 default_server;") {
+				t.Errorf("WriteNginxConfigToPath(%v) wrote %q, want the server to listen on port 8080", tc.conf, got)
+			}
+			for _, h := range securityHeaders {
+				if gotHeader := strings.Contains(got, h); gotHeader != tc.wantHeaders {
+					t.Errorf("WriteNginxConfigToPath(%v) wrote %q, contains %q = %t, want %t", tc.conf, got, h, gotHeader, tc.wantHeaders)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteNginxConfigStubStatus(t *testing.T) {
+	stubStatus := []string{
+		"log_format	stub_status	'$time_iso8601 active_connections=$connections_active reading=$connections_reading writing=$connections_writing waiting=$connections_waiting';",
+		"location = /_nginx/status {\n\t\tstub_status;\n\t\taccess_log	/dev/stdout	stub_status;\n\t}",
+	}
+	testCases := []struct {
+		name        string
+		conf        Config
+		wantStatus  bool
+		wantRewrite string
+	}{
+		{
+			name:        "default",
+			conf:        Config{Port: 8080, Root: "/workspace", FrontControllerScript: "index.php"},
+			wantRewrite: "rewrite	^/(.*)$	/index.php$uri;",
+		},
+		{
+			name:        "stub status",
+			conf:        Config{Port: 8080, Root: "/workspace", FrontControllerScript: "index.php", StubStatus: true},
+			wantStatus:  true,
+			wantRewrite: "rewrite	^/(?!_nginx/status$)(.*)$	/index.php$uri;",
+		},
+		{
+			name:       "stub status with static files",
+			conf:       Config{Port: 8080, Root: "/workspace", FrontControllerScript: "index.php", StubStatus: true, ServesStaticFiles: true},
+			wantStatus: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			f, err := WriteNginxConfigToPath(dir, tc.conf)
+			if err != nil {
+				t.Fatalf("WriteNginxConfigToPath(%v) got error: %v", tc.conf, err)
+			}
+			f.Close()
+			content, err := os.ReadFile(filepath.Join(dir, nginxServerConf))
+			if err != nil {
+				t.Fatalf("reading nginx config: %v", err)
+			}
+			got := string(content)
+
+			for _, s := range stubStatus {
+				if gotStatus := strings.Contains(got, s); gotStatus != tc.wantStatus {
+					t.Errorf("WriteNginxConfigToPath(%v) wrote %q, contains %q = %t, want %t", tc.conf, got, s, gotStatus, tc.wantStatus)
+				}
+			}
+			if tc.wantRewrite != "" && !strings.Contains(got, tc.wantRewrite) {
+				t.Errorf("WriteNginxConfigToPath(%v) wrote %q, want it to contain %q", tc.conf, got, tc.wantRewrite)
+			}
+			if tc.conf.ServesStaticFiles && strings.Contains(got, "rewrite") {
+				t.Errorf("WriteNginxConfigToPath(%v) wrote %q, want no rewrite when static files are served", tc.conf, got)
+			}
+		})
+	}
+}
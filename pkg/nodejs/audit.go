@@ -0,0 +1,157 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// NPMAuditFailLevelEnv is the env var that fails the build if `npm audit` reports vulnerabilities
+	// with this severity or higher. It must be high or critical.
+	NPMAuditFailLevelEnv = "GOOGLE_NPM_AUDIT_FAIL_LEVEL"
+)
+
+var (
+	// auditSeverities ranks the severities reported by `npm audit`.
+	auditSeverities = map[string]int{"info": 0, "low": 1, "moderate": 2, "high": 3, "critical": 4}
+	// auditFailLevels are the values allowed for GOOGLE_NPM_AUDIT_FAIL_LEVEL.
+	auditFailLevels = []string{"high", "critical"}
+)
+
+// auditReport is the output of `npm audit --json`. npm 7+ reports vulnerabilities by package, npm 6
+// and lower by advisory.
+type auditReport struct {
+	Vulnerabilities map[string]struct {
+		Name string `json:"name"`
+		// Via holds the advisories of the package, and the names of the vulnerable dependencies that
+		// make it vulnerable.
+		Via []json.RawMessage `json:"via"`
+	} `json:"vulnerabilities"`
+	Advisories map[string]struct {
+		ModuleName string   `json:"module_name"`
+		Severity   string   `json:"severity"`
+		CVEs       []string `json:"cves"`
+		URL        string   `json:"url"`
+	} `json:"advisories"`
+	Error *struct {
+		Code    string `json:"code"`
+		Summary string `json:"summary"`
+	} `json:"error"`
+}
+
+// auditAdvisory is an advisory in the via list of an npm 7+ audit report.
+type auditAdvisory struct {
+	Name     string `json:"name"`
+	Severity string `json:"severity"`
+	URL      string `json:"url"`
+}
+
+// NPMAudit runs `npm audit` if GOOGLE_NPM_AUDIT_FAIL_LEVEL is set, and returns a user error that
+// lists the vulnerable packages if it reports vulnerabilities with that severity or higher.
+func NPMAudit(ctx *gcp.Context) error {
+	level := os.Getenv(NPMAuditFailLevelEnv)
+	if level == "" {
+		return nil
+	}
+	valid := false
+	for _, l := range auditFailLevels {
+		valid = valid || level == l
+	}
+	if !valid {
+		return gcp.UserErrorf("invalid value %q for %s, must be one of: %s", level, NPMAuditFailLevelEnv, strings.Join(auditFailLevels, ", "))
+	}
+	ctx.Logf("Checking the dependencies for vulnerabilities with severity %s or higher.", level)
+	// npm audit exits with a non-zero code if it reports vulnerabilities of any severity.
+	result, err := ctx.Exec([]string{"npm", "audit", "--json"}, gcp.WithLogOutput(false), gcp.WithUserAttribution)
+	if result == nil {
+		return err
+	}
+	vulnerable, parseErr := parseAuditSeverities(result.Stdout, level)
+	if parseErr != nil {
+		if err != nil {
+			return err
+		}
+		return parseErr
+	}
+	if len(vulnerable) > 0 {
+		return gcp.UserErrorf("npm audit found vulnerabilities with severity %s or higher in the following packages, update them or change %s:\n  %s", level, NPMAuditFailLevelEnv, strings.Join(vulnerable, "\n  "))
+	}
+	ctx.Logf("npm audit found no vulnerabilities with severity %s or higher.", level)
+	return nil
+}
+
+// parseAuditSeverities parses the output of `npm audit --json` and returns the packages with
+// advisories of severity minSeverity or higher, each followed by its highest severity and the IDs
+// of the advisories: the CVE IDs if the report has them, or the advisory IDs from their URLs, e.g.
+// GHSA-p6mc-m468-83gw, since npm 7+ does not report CVE IDs.
+func parseAuditSeverities(report string, minSeverity string) ([]string, error) {
+	minRank, ok := auditSeverities[minSeverity]
+	if !ok {
+		return nil, fmt.Errorf("unknown npm audit severity %q", minSeverity)
+	}
+	var r auditReport
+	if err := json.Unmarshal([]byte(report), &r); err != nil {
+		return nil, gcp.InternalErrorf("parsing npm audit output: %w", err)
+	}
+	if r.Error != nil {
+		return nil, gcp.UserErrorf("npm audit failed: %s: %s", r.Error.Code, r.Error.Summary)
+	}
+
+	severities := map[string]string{}
+	ids := map[string][]string{}
+	add := func(pkg, severity string, advisoryIDs ...string) {
+		if auditSeverities[severity] < minRank {
+			return
+		}
+		if current, ok := severities[pkg]; !ok || auditSeverities[severity] > auditSeverities[current] {
+			severities[pkg] = severity
+		}
+		ids[pkg] = append(ids[pkg], advisoryIDs...)
+	}
+	for name, v := range r.Vulnerabilities {
+		for _, via := range v.Via {
+			var a auditAdvisory
+			// Names of vulnerable dependencies are strings, their advisories are listed under them.
+			if err := json.Unmarshal(via, &a); err != nil {
+				continue
+			}
+			add(name, a.Severity, path.Base(a.URL))
+		}
+	}
+	for id, a := range r.Advisories {
+		cves := a.CVEs
+		if len(cves) == 0 {
+			cves = []string{id}
+		}
+		add(a.ModuleName, a.Severity, cves...)
+	}
+
+	var vulnerable []string
+	for pkg, severity := range severities {
+		pkgIDs := ids[pkg]
+		sort.Strings(pkgIDs)
+		vulnerable = append(vulnerable, fmt.Sprintf("%s (%s): %s", pkg, severity, strings.Join(pkgIDs, ", ")))
+	}
+	sort.Strings(vulnerable)
+	return vulnerable, nil
+}
@@ -0,0 +1,111 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"os"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/testdata"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseAuditSeverities(t *testing.T) {
+	testCases := []struct {
+		name        string
+		report      string
+		fixture     string
+		minSeverity string
+		want        []string
+		wantErr     bool
+	}{
+		{
+			name:        "high",
+			fixture:     "testdata/npm-audit/lodash-4.17.15.json",
+			minSeverity: "high",
+			want: []string{
+				"lodash (high): GHSA-35jh-r3h4-6jhm, GHSA-p6mc-m468-83gw",
+				"minimist (critical): GHSA-xvch-5gv4-984h",
+			},
+		},
+		{
+			name:        "critical",
+			fixture:     "testdata/npm-audit/lodash-4.17.15.json",
+			minSeverity: "critical",
+			want:        []string{"minimist (critical): GHSA-xvch-5gv4-984h"},
+		},
+		{
+			name:        "moderate",
+			fixture:     "testdata/npm-audit/lodash-4.17.15.json",
+			minSeverity: "moderate",
+			want: []string{
+				"lodash (high): GHSA-29mw-wpgm-hmr9, GHSA-35jh-r3h4-6jhm, GHSA-p6mc-m468-83gw",
+				"minimist (critical): GHSA-xvch-5gv4-984h",
+			},
+		},
+		{
+			name:        "npm 6 report",
+			fixture:     "testdata/npm-audit/legacy.json",
+			minSeverity: "high",
+			want: []string{
+				"lodash (high): CVE-2021-23337",
+				"minimist (critical): 1179",
+			},
+		},
+		{
+			name:        "no vulnerabilities",
+			report:      `{"auditReportVersion":2,"vulnerabilities":{},"metadata":{}}`,
+			minSeverity: "high",
+		},
+		{
+			name:        "npm error",
+			report:      `{"error":{"code":"ENOLOCK","summary":"This command requires an existing lockfile."}}`,
+			minSeverity: "high",
+			wantErr:     true,
+		},
+		{
+			name:        "invalid json",
+			report:      "npm ERR! audit endpoint returned an error",
+			minSeverity: "high",
+			wantErr:     true,
+		},
+		{
+			name:        "unknown severity",
+			report:      `{}`,
+			minSeverity: "severe",
+			wantErr:     true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			report := tc.report
+			if tc.fixture != "" {
+				content, err := os.ReadFile(testdata.MustGetPath(tc.fixture))
+				if err != nil {
+					t.Fatal(err)
+				}
+				report = string(content)
+			}
+
+			got, err := parseAuditSeverities(report, tc.minSeverity)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("parseAuditSeverities() got error: %v, want error: %v", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("parseAuditSeverities() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/Masterminds/semver"
+)
+
+// EnginesWarnOnlyEnv is an env var that downgrades package.json "engines" and "packageManager"
+// violations from build errors to warnings.
+const EnginesWarnOnlyEnv = "GOOGLE_NODEJS_ENGINES_WARN_ONLY"
+
+// packageManagerVersion returns the version of the given package manager installed in the system.
+// It can be overridden for testing.
+var packageManagerVersion = func(ctx *gcp.Context, pkgTool string) (string, error) {
+	result, err := ctx.Exec([]string{pkgTool, "--version"})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(result.Stdout), nil
+}
+
+// ValidateEngines compares the installed Node.js and package manager versions against the
+// constraints declared in the "engines" and "packageManager" fields of package.json. pkgTool is the
+// package manager used to install dependencies, one of "npm", "yarn" or "pnpm". Violations fail the
+// build with an actionable error unless GOOGLE_NODEJS_ENGINES_WARN_ONLY is set, in which case they
+// are logged as warnings.
+func ValidateEngines(ctx *gcp.Context, pjs *PackageJSON, pkgTool string) error {
+	if pjs == nil {
+		return nil
+	}
+	var violations []string
+
+	if pjs.Engines.Node != "" {
+		installed, err := nodeVersion(ctx)
+		if err != nil {
+			return err
+		}
+		fix := fmt.Sprintf("set %s (or %s) to a matching version or update the \"engines.node\" field in package.json", EnvNodeVersion, env.RuntimeVersion)
+		if v, err := checkConstraint(ctx, "node", installed, pjs.Engines.Node, `"engines.node"`, fix); err != nil {
+			return err
+		} else if v != "" {
+			violations = append(violations, v)
+		}
+	}
+
+	var declared, field string
+	switch pkgTool {
+	case "npm":
+		declared, field = pjs.Engines.NPM, `"engines.npm"`
+	case "yarn":
+		declared, field = pjs.Engines.Yarn, `"engines.yarn"`
+	case "pnpm":
+		declared, field = pjs.Engines.PNPM, `"engines.pnpm"`
+	default:
+		return gcp.InternalErrorf("unsupported package manager %q", pkgTool)
+	}
+	var pmVersion string
+	if pjs.PackageManager != "" {
+		name, version, err := parsePackageManager(pjs.PackageManager)
+		if err != nil {
+			return err
+		}
+		// The packageManager field only applies to the package manager that is actually used.
+		if name == pkgTool {
+			pmVersion = version
+		}
+	}
+	if declared != "" || pmVersion != "" {
+		installed, err := packageManagerVersion(ctx, pkgTool)
+		if err != nil {
+			return err
+		}
+		if declared != "" {
+			fix := fmt.Sprintf("update the %s field in package.json to a range that includes %s %s", field, pkgTool, installed)
+			if v, err := checkConstraint(ctx, pkgTool, installed, declared, field, fix); err != nil {
+				return err
+			} else if v != "" {
+				violations = append(violations, v)
+			}
+		}
+		if pmVersion != "" {
+			// packageManager pins an exact version, optionally followed by a "+sha..." hash.
+			pinned := strings.SplitN(pmVersion, "+", 2)[0]
+			fix := fmt.Sprintf("update the \"packageManager\" field in package.json to %s@%s", pkgTool, installed)
+			if v, err := checkConstraint(ctx, pkgTool, installed, "="+pinned, `"packageManager"`, fix); err != nil {
+				return err
+			} else if v != "" {
+				violations = append(violations, v)
+			}
+		}
+	}
+
+	if len(violations) == 0 {
+		return nil
+	}
+	warnOnly, err := env.IsPresentAndTrue(EnginesWarnOnlyEnv)
+	if err != nil {
+		return err
+	}
+	if warnOnly {
+		for _, v := range violations {
+			ctx.Warnf("%s", v)
+		}
+		return nil
+	}
+	return gcp.UserErrorf("package.json engine constraints are not satisfied:\n  %s\nSet %s=true to continue the build with a warning instead.", strings.Join(violations, "\n  "), EnginesWarnOnlyEnv)
+}
+
+// checkConstraint returns a description of the violation if the installed version of tool does not
+// satisfy the declared constraint, or an empty string if it does. Constraints that cannot be parsed
+// as semver ranges are skipped with a warning since the package manager may still understand them.
+func checkConstraint(ctx *gcp.Context, tool, installed, declared, field, fix string) (string, error) {
+	c, err := semver.NewConstraint(declared)
+	if err != nil {
+		ctx.Warnf("Skipping validation of %s constraint %q: %v", field, declared, err)
+		return "", nil
+	}
+	v, err := semver.NewVersion(strings.TrimSpace(installed))
+	if err != nil {
+		return "", gcp.InternalErrorf("parsing installed %s version %q: %v", tool, installed, err)
+	}
+	if c.Check(v) {
+		return "", nil
+	}
+	return fmt.Sprintf("installed %s %s does not satisfy %s constraint %q: %s", tool, v, field, declared, fix), nil
+}
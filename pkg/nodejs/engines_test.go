@@ -0,0 +1,138 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"strings"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+func TestValidateEngines(t *testing.T) {
+	testCases := []struct {
+		name        string
+		pjs         *PackageJSON
+		pkgTool     string
+		nodeVersion string
+		pmVersion   string
+		warnOnly    bool
+		wantErr     []string
+	}{
+		{
+			name:    "nil package.json",
+			pkgTool: "npm",
+		},
+		{
+			name:        "no constraints",
+			pjs:         &PackageJSON{},
+			pkgTool:     "npm",
+			nodeVersion: "v20.1.0",
+			pmVersion:   "10.2.0",
+		},
+		{
+			name:        "all satisfied",
+			pjs:         &PackageJSON{Engines: packageEnginesJSON{Node: ">=18 <21", NPM: "^10.0.0"}},
+			pkgTool:     "npm",
+			nodeVersion: "v20.1.0",
+			pmVersion:   "10.2.0",
+		},
+		{
+			name:        "node not satisfied",
+			pjs:         &PackageJSON{Engines: packageEnginesJSON{Node: ">=22"}},
+			pkgTool:     "npm",
+			nodeVersion: "v20.1.0",
+			wantErr:     []string{"node 20.1.0", `">=22"`, EnvNodeVersion, `"engines.node"`, EnginesWarnOnlyEnv},
+		},
+		{
+			name:        "yarn not satisfied",
+			pjs:         &PackageJSON{Engines: packageEnginesJSON{Yarn: "^4.0.0"}},
+			pkgTool:     "yarn",
+			nodeVersion: "v20.1.0",
+			pmVersion:   "1.22.22",
+			wantErr:     []string{"yarn 1.22.22", `"engines.yarn"`, `"^4.0.0"`},
+		},
+		{
+			name:        "engines for other package manager ignored",
+			pjs:         &PackageJSON{Engines: packageEnginesJSON{Yarn: "^4.0.0"}},
+			pkgTool:     "pnpm",
+			nodeVersion: "v20.1.0",
+			pmVersion:   "9.0.0",
+		},
+		{
+			name:        "packageManager satisfied",
+			pjs:         &PackageJSON{PackageManager: "pnpm@9.0.0+sha512.abc"},
+			pkgTool:     "pnpm",
+			nodeVersion: "v20.1.0",
+			pmVersion:   "9.0.0",
+		},
+		{
+			name:        "packageManager not satisfied",
+			pjs:         &PackageJSON{PackageManager: "pnpm@9.0.0"},
+			pkgTool:     "pnpm",
+			nodeVersion: "v20.1.0",
+			pmVersion:   "8.15.1",
+			wantErr:     []string{"pnpm 8.15.1", `"packageManager"`, "pnpm@8.15.1"},
+		},
+		{
+			name:        "packageManager for other package manager ignored",
+			pjs:         &PackageJSON{PackageManager: "yarn@4.1.0"},
+			pkgTool:     "npm",
+			nodeVersion: "v20.1.0",
+			pmVersion:   "10.2.0",
+		},
+		{
+			name:        "unparsable constraint skipped",
+			pjs:         &PackageJSON{Engines: packageEnginesJSON{Node: "latest"}},
+			pkgTool:     "npm",
+			nodeVersion: "v20.1.0",
+		},
+		{
+			name:        "warn only",
+			pjs:         &PackageJSON{Engines: packageEnginesJSON{Node: ">=22", NPM: "^11"}},
+			pkgTool:     "npm",
+			nodeVersion: "v20.1.0",
+			pmVersion:   "10.2.0",
+			warnOnly:    true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			defer func(fn func(*gcp.Context) (string, error)) { nodeVersion = fn }(nodeVersion)
+			nodeVersion = func(*gcp.Context) (string, error) { return tc.nodeVersion, nil }
+			defer func(fn func(*gcp.Context, string) (string, error)) { packageManagerVersion = fn }(packageManagerVersion)
+			packageManagerVersion = func(*gcp.Context, string) (string, error) { return tc.pmVersion, nil }
+			if tc.warnOnly {
+				t.Setenv(EnginesWarnOnlyEnv, "true")
+			}
+
+			err := ValidateEngines(gcp.NewContext(), tc.pjs, tc.pkgTool)
+			if len(tc.wantErr) == 0 {
+				if err != nil {
+					t.Fatalf("ValidateEngines() got error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("ValidateEngines() got nil error, want error containing %q", tc.wantErr)
+			}
+			for _, want := range tc.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("ValidateEngines() = %q, want error containing %q", err, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// EnvValidateExport enables loading the package.json "main" file at build time to check that it
+	// exports the function target.
+	EnvValidateExport = "GOOGLE_FUNCTION_VALIDATE_EXPORT"
+	// EnvFunctionTimeout sets the number of seconds after which the functions framework aborts a
+	// request.
+	EnvFunctionTimeout = "GOOGLE_FUNCTION_TIMEOUT_SECONDS"
+	// EnvFunctionLogExecutionID makes the functions framework add the execution ID of the request to
+	// the logs written by the function.
+	EnvFunctionLogExecutionID = "GOOGLE_FUNCTION_LOG_EXECUTION_ID"
+	// EnvFunctionIgnoredRoutes sets the route pattern, e.g. "/favicon.ico", to which the functions
+	// framework responds with a 404 instead of invoking the function.
+	EnvFunctionIgnoredRoutes = "GOOGLE_FUNCTION_IGNORED_ROUTES"
+	// EnvFunctionPropagateFrameworkErrors makes the functions framework propagate the errors it
+	// raises, for example while parsing a request, to the function's error handlers.
+	EnvFunctionPropagateFrameworkErrors = "GOOGLE_FUNCTION_PROPAGATE_FRAMEWORK_ERRORS"
+
+	functionEnvPrefix = "GOOGLE_FUNCTION_"
+)
+
+// functionsFrameworkOption is a GOOGLE_FUNCTION_* build env var translated into a launch env var of
+// the functions framework.
+type functionsFrameworkOption struct {
+	// launch is the env var read by the functions framework.
+	launch string
+	// parse validates the value of the build env var and returns the value of the launch env var.
+	parse func(name, value string) (string, error)
+}
+
+var (
+	// functionsFrameworkOptions are the functions framework options that can be set at build time,
+	// keyed by build env var.
+	functionsFrameworkOptions = map[string]functionsFrameworkOption{
+		EnvFunctionTimeout:                  {launch: "CLOUD_RUN_TIMEOUT_SECONDS", parse: parseTimeoutSeconds},
+		EnvFunctionLogExecutionID:           {launch: "LOG_EXECUTION_ID", parse: parseBool},
+		EnvFunctionIgnoredRoutes:            {launch: "IGNORED_ROUTES", parse: parseRoute},
+		EnvFunctionPropagateFrameworkErrors: {launch: "PROPAGATE_FRAMEWORK_ERRORS", parse: parseBool},
+	}
+
+	// knownFunctionEnvs are the other GOOGLE_FUNCTION_* env vars used by the Node.js buildpacks.
+	knownFunctionEnvs = map[string]bool{
+		env.FunctionTarget:        true,
+		env.FunctionSource:        true,
+		env.FunctionSourceSubdir:  true,
+		env.FunctionSignatureType: true,
+		EnvValidateExport:         true,
+	}
+)
+
+// FunctionsFrameworkEnv returns the launch env vars of the functions framework translated from the
+// GOOGLE_FUNCTION_* build env vars, after checking that their values are valid. It warns about the
+// GOOGLE_FUNCTION_* env vars that are not supported so that typos are not silently ignored.
+func FunctionsFrameworkEnv(ctx *gcp.Context) (map[string]string, error) {
+	launchEnv := map[string]string{}
+	var unknown []string
+	for _, e := range os.Environ() {
+		name, value, _ := strings.Cut(e, "=")
+		if !strings.HasPrefix(name, functionEnvPrefix) || knownFunctionEnvs[name] {
+			continue
+		}
+		opt, ok := functionsFrameworkOptions[name]
+		if !ok {
+			unknown = append(unknown, name)
+			continue
+		}
+		v, err := opt.parse(name, value)
+		if err != nil {
+			return nil, err
+		}
+		launchEnv[opt.launch] = v
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		ctx.Warnf("Ignoring unsupported env vars %s. The functions framework options that can be set at build time are %s.", strings.Join(unknown, ", "), strings.Join(supportedFunctionEnvs(), ", "))
+	}
+	return launchEnv, nil
+}
+
+// supportedFunctionEnvs returns the sorted names of the functions framework options that can be set
+// at build time.
+func supportedFunctionEnvs() []string {
+	var names []string
+	for name := range functionsFrameworkOptions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func parseTimeoutSeconds(name, value string) (string, error) {
+	seconds, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || seconds <= 0 {
+		return "", gcp.UserErrorf("invalid %s %q: must be a positive number of seconds", name, value)
+	}
+	return strconv.Itoa(seconds), nil
+}
+
+func parseBool(name, value string) (string, error) {
+	b, err := strconv.ParseBool(strings.TrimSpace(value))
+	if err != nil {
+		return "", gcp.UserErrorf("invalid %s %q: must be true or false", name, value)
+	}
+	return strconv.FormatBool(b), nil
+}
+
+func parseRoute(name, value string) (string, error) {
+	route := strings.TrimSpace(value)
+	if !strings.HasPrefix(route, "/") {
+		return "", gcp.UserErrorf("invalid %s %q: must be a route starting with /", name, value)
+	}
+	return route, nil
+}
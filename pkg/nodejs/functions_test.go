@@ -0,0 +1,108 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFunctionsFrameworkEnv(t *testing.T) {
+	testCases := []struct {
+		name    string
+		envs    map[string]string
+		want    map[string]string
+		wantLog string
+		wantErr bool
+	}{
+		{
+			name: "no options",
+			envs: map[string]string{"GOOGLE_FUNCTION_TARGET": "helloWorld", "GOOGLE_FUNCTION_SIGNATURE_TYPE": "http"},
+			want: map[string]string{},
+		},
+		{
+			name: "all options",
+			envs: map[string]string{
+				"GOOGLE_FUNCTION_TIMEOUT_SECONDS":            "300",
+				"GOOGLE_FUNCTION_LOG_EXECUTION_ID":           "TRUE",
+				"GOOGLE_FUNCTION_IGNORED_ROUTES":             "/favicon.ico",
+				"GOOGLE_FUNCTION_PROPAGATE_FRAMEWORK_ERRORS": "0",
+			},
+			want: map[string]string{
+				"CLOUD_RUN_TIMEOUT_SECONDS":  "300",
+				"LOG_EXECUTION_ID":           "true",
+				"IGNORED_ROUTES":             "/favicon.ico",
+				"PROPAGATE_FRAMEWORK_ERRORS": "false",
+			},
+		},
+		{
+			name:    "unknown option",
+			envs:    map[string]string{"GOOGLE_FUNCTION_TIMEOUT": "300", "GOOGLE_FUNCTION_VALIDATE_EXPORT": "true"},
+			want:    map[string]string{},
+			wantLog: "Ignoring unsupported env vars GOOGLE_FUNCTION_TIMEOUT.",
+		},
+		{
+			name:    "invalid timeout",
+			envs:    map[string]string{"GOOGLE_FUNCTION_TIMEOUT_SECONDS": "5m"},
+			wantErr: true,
+		},
+		{
+			name:    "negative timeout",
+			envs:    map[string]string{"GOOGLE_FUNCTION_TIMEOUT_SECONDS": "-1"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid boolean",
+			envs:    map[string]string{"GOOGLE_FUNCTION_LOG_EXECUTION_ID": "yes"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid route",
+			envs:    map[string]string{"GOOGLE_FUNCTION_IGNORED_ROUTES": "favicon.ico"},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for k, v := range tc.envs {
+				t.Setenv(k, v)
+			}
+			var buf bytes.Buffer
+			ctx := gcp.NewContext(gcp.WithLogger(log.New(&buf, "", 0)))
+
+			got, err := FunctionsFrameworkEnv(ctx)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("FunctionsFrameworkEnv() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("FunctionsFrameworkEnv() mismatch (-want +got):\n%s", diff)
+			}
+			if tc.wantLog == "" && buf.Len() != 0 {
+				t.Errorf("FunctionsFrameworkEnv() logged %q, want no output", buf.String())
+			}
+			if !strings.Contains(buf.String(), tc.wantLog) {
+				t.Errorf("FunctionsFrameworkEnv() logged %q, want output containing %q", buf.String(), tc.wantLog)
+			}
+		})
+	}
+}
@@ -16,6 +16,8 @@ package nodejs
 
 import (
 	"fmt"
+	"path/filepath"
+	"regexp"
 
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/buildpacks/libcnb"
@@ -26,6 +28,17 @@ var (
 	nextJsVersionKey = "version"
 	// PinnedNextjsAdapterVersion is the version of the nextjs adapter that will be used.
 	PinnedNextjsAdapterVersion = "14.0.9"
+	// nextConfigFiles are the names of the Next.js config file, in the order Next.js looks for them.
+	nextConfigFiles = []string{"next.config.js", "next.config.mjs", "next.config.ts"}
+	// nextOutputRegexp matches the output option of a Next.js config, e.g. `output: 'export'`.
+	nextOutputRegexp = regexp.MustCompile("\\boutput\\s*:\\s*['\"`]([a-z]+)['\"`]")
+	// jsCommentRegexp matches JavaScript block comments and comments that take up a whole line.
+	jsCommentRegexp = regexp.MustCompile(`(?s:/\*.*?\*/)|(?m:^[ \t]*//.*$)`)
+)
+
+const (
+	// NextOutputExport is the Next.js output mode of a static export, which does not need a server.
+	NextOutputExport = "export"
 )
 
 // InstallNextJsBuildAdaptor installs the nextjs build adaptor in the given layer if it is not already cached.
@@ -77,6 +90,37 @@ func downloadNextJsAdaptor(ctx *gcp.Context, dirPath string, version string) err
 	return nil
 }
 
+// NextOutputMode returns the output mode set in the Next.js config file in appDir, e.g. "export" or
+// "standalone", or an empty string if there is no config file or it does not set the output option.
+func NextOutputMode(ctx *gcp.Context, appDir string) (string, error) {
+	for _, name := range nextConfigFiles {
+		configFile := filepath.Join(appDir, name)
+		exists, err := ctx.FileExists(configFile)
+		if err != nil {
+			return "", err
+		}
+		if exists {
+			return detectNextOutputMode(ctx, configFile)
+		}
+	}
+	return "", nil
+}
+
+// detectNextOutputMode returns the value of the output option in the given Next.js config file.
+// The config is a JavaScript module, so it is matched with a regexp rather than parsed; commented
+// out options are ignored.
+func detectNextOutputMode(ctx *gcp.Context, configFile string) (string, error) {
+	content, err := ctx.ReadFile(configFile)
+	if err != nil {
+		return "", err
+	}
+	m := nextOutputRegexp.FindSubmatch(jsCommentRegexp.ReplaceAll(content, nil))
+	if m == nil {
+		return "", nil
+	}
+	return string(m[1]), nil
+}
+
 // OverrideNextjsBuildScript overrides the build script to be the Nextjs build script
 func OverrideNextjsBuildScript(njsl *libcnb.Layer) {
 	njsl.BuildEnvironment.Override(AppHostingBuildEnv, fmt.Sprintf("npm exec --prefix %s apphosting-adapter-nextjs-build", njsl.Path))
@@ -14,6 +14,8 @@
 package nodejs
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
@@ -110,3 +112,80 @@ func getContextOpts(t *testing.T, mocks []*mockprocess.Mock) []gcp.ContextOption
 	}
 	return opts
 }
+
+func TestNextOutputMode(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{
+			name: "no config",
+		},
+		{
+			name:  "no output",
+			files: map[string]string{"next.config.js": `module.exports = {reactStrictMode: true};`},
+		},
+		{
+			name:  "export",
+			files: map[string]string{"next.config.js": `module.exports = { output: 'export' };`},
+			want:  "export",
+		},
+		{
+			name:  "standalone with double quotes",
+			files: map[string]string{"next.config.mjs": "const nextConfig = {\n  output: \"standalone\",\n};\nexport default nextConfig;"},
+			want:  "standalone",
+		},
+		{
+			name:  "typescript config",
+			files: map[string]string{"next.config.ts": "import type { NextConfig } from \"next\";\nconst nextConfig: NextConfig = {\n  output: `export`,\n};\nexport default nextConfig;"},
+			want:  "export",
+		},
+		{
+			name: "line comment",
+			files: map[string]string{"next.config.js": `module.exports = {
+  // output: 'export',
+};`},
+		},
+		{
+			name: "block comment",
+			files: map[string]string{"next.config.js": `module.exports = {
+  /*
+  output: 'export',
+  */
+  output: 'standalone',
+};`},
+			want: "standalone",
+		},
+		{
+			name: "js config takes precedence",
+			files: map[string]string{
+				"next.config.js":  `module.exports = { output: 'standalone' };`,
+				"next.config.mjs": `export default { output: 'export' };`,
+			},
+			want: "standalone",
+		},
+		{
+			name:  "other output key",
+			files: map[string]string{"next.config.js": `module.exports = { distOutput: 'export' };`},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for f, c := range tc.files {
+				if err := os.WriteFile(filepath.Join(dir, f), []byte(c), 0644); err != nil {
+					t.Fatalf("writing %s: %v", f, err)
+				}
+			}
+
+			got, err := NextOutputMode(gcp.NewContext(), dir)
+			if err != nil {
+				t.Fatalf("NextOutputMode() got error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("NextOutputMode() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
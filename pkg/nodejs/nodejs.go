@@ -27,6 +27,7 @@ import (
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/toolversions"
 	"github.com/buildpacks/libcnb"
 	"github.com/Masterminds/semver"
 	"gopkg.in/yaml.v2"
@@ -41,6 +42,12 @@ const (
 	EnvProduction = "production"
 	// EnvNodeVersion can be used to specify the version of Node.js is used for an app.
 	EnvNodeVersion = "GOOGLE_NODEJS_VERSION"
+	// EnvHeapDumpOnOOM enables writing heap snapshots when the app runs out of memory or receives SIGUSR2.
+	EnvHeapDumpOnOOM = "GOOGLE_NODE_HEAP_DUMP_ON_OOM"
+	// EnvInspect enables the V8 inspector at startup so that a remote debugger can attach.
+	EnvInspect = "GOOGLE_NODE_INSPECT"
+	// InspectFlag is the node flag that enables the V8 inspector on all interfaces.
+	InspectFlag = "--inspect=0.0.0.0:9229"
 
 	nodeVersionKey    = "node_version"
 	dependencyHashKey = "dependency_hash"
@@ -73,6 +80,9 @@ const (
 	ScriptGCPBuild = "gcp-build"
 	// ScriptApphostingBuild is the name of "apphosting-build" scripts.
 	ScriptApphostingBuild = "apphosting:build"
+	// ScriptMigrate is the name of the scripts that run database migrations, e.g. with Prisma or
+	// Sequelize.
+	ScriptMigrate = "migrate"
 )
 
 // PackageJSON represents the contents of a package.json file.
@@ -85,7 +95,11 @@ type PackageJSON struct {
 	Scripts         map[string]string  `json:"scripts"`
 	Dependencies    map[string]string  `json:"dependencies"`
 	DevDependencies map[string]string  `json:"devDependencies"`
-	PackageManager  string             `json:"packageManager"`
+	// OptionalDependencies are installed like dependencies, but a failure to install them does not
+	// fail the install.
+	OptionalDependencies map[string]string `json:"optionalDependencies"`
+	PackageManager       string            `json:"packageManager"`
+	Workspaces           json.RawMessage   `json:"workspaces"`
 }
 
 // NpmLockfile represents the contents of a lock file generated with npm.
@@ -147,7 +161,8 @@ func ReadPackageJSONIfExists(dir string) (*PackageJSON, error) {
 }
 
 // ReadNodeDependencies looks for a package.json and lockfile in either appDir or rootDir. The
-// lockfile must either be in the same directory as package.json or be in the application root.
+// lockfile must either be in the same directory as package.json, be in the application root, or be
+// in a parent directory of the application root within the workspace.
 // TODO (b/354012293): In the future we should read the data into structs for easier manipulation.
 func ReadNodeDependencies(ctx *gcp.Context, appDir string) (*NodeDependencies, error) {
 	rootDir := ctx.ApplicationRoot()
@@ -184,6 +199,14 @@ func ReadNodeDependencies(ctx *gcp.Context, appDir string) (*NodeDependencies, e
 		return &NodeDependencies{pjs, path}, nil
 	}
 
+	// Monorepos may share a single lockfile at the workspace root.
+	for dir := rootDir; dir != ctx.WorkspaceRoot() && gcp.IsWithin(ctx.WorkspaceRoot(), dir); {
+		dir = filepath.Dir(dir)
+		if path := findValidLockfileInDir(dir); path != "" {
+			return &NodeDependencies{pjs, path}, nil
+		}
+	}
+
 	return &NodeDependencies{pjs, ""}, nil
 }
 
@@ -221,6 +244,15 @@ func HasScript(p *PackageJSON, name string) bool {
 	return ok
 }
 
+// ReleaseCommand returns the command that runs the "migrate" script with the given package manager,
+// or nil if the package.json file does not define one.
+func ReleaseCommand(p *PackageJSON, packageManager string) []string {
+	if !HasScript(p, ScriptMigrate) {
+		return nil
+	}
+	return []string{packageManager, "run", ScriptMigrate}
+}
+
 // HasDevDependencies returns true if the given directory contains a package.json file that lists
 // more one or more devDependencies.
 func HasDevDependencies(p *PackageJSON) bool {
@@ -228,7 +260,7 @@ func HasDevDependencies(p *PackageJSON) bool {
 }
 
 // RequestedNodejsVersion returns any customer provided Node.js version constraint by inspecting the
-// environment and the package.json.
+// environment, the package.json and the .tool-versions file.
 func RequestedNodejsVersion(ctx *gcp.Context, pjs *PackageJSON) (string, error) {
 	if version := os.Getenv(EnvNodeVersion); version != "" {
 		ctx.Logf("Using runtime version from %s: %s", EnvNodeVersion, version)
@@ -238,10 +270,18 @@ func RequestedNodejsVersion(ctx *gcp.Context, pjs *PackageJSON) (string, error)
 		ctx.Logf("Using runtime version from %s: %s", env.RuntimeVersion, version)
 		return version, nil
 	}
-	if pjs == nil || pjs.Engines.Node == "" {
-		return defaultVersionConstraint, nil
+	if pjs != nil && pjs.Engines.Node != "" {
+		return pjs.Engines.Node, nil
 	}
-	return pjs.Engines.Node, nil
+	version, err := toolversions.Version(ctx, toolversions.Nodejs)
+	if err != nil {
+		return "", err
+	}
+	if version != "" {
+		ctx.Logf("Using runtime version from %s: %s", toolversions.File, version)
+		return version, nil
+	}
+	return defaultVersionConstraint, nil
 }
 
 // nodeVersion returns the installed version of Node.js.
@@ -277,6 +317,39 @@ func NodeEnv() string {
 	return nodeEnv
 }
 
+// HeapDumpNodeOptions returns the NODE_OPTIONS flags enabled by GOOGLE_NODE_HEAP_DUMP_ON_OOM. Node.js
+// writes a heap snapshot to the working directory when it receives SIGUSR2 and automatically when
+// the heap is close to its limit, up to 3 times.
+func HeapDumpNodeOptions() ([]string, error) {
+	enabled, err := env.IsPresentAndTrue(EnvHeapDumpOnOOM)
+	if err != nil {
+		return nil, err
+	}
+	if !enabled {
+		return nil, nil
+	}
+	return []string{"--heapsnapshot-signal=SIGUSR2", "--heapsnapshot-near-heap-limit=3"}, nil
+}
+
+// InspectCommand returns the start command with the V8 inspector enabled if GOOGLE_NODE_INSPECT is
+// true. The flag is only added to commands that run node directly: in NODE_OPTIONS it would also be
+// read by the package manager, which would take the inspector port before the application.
+func InspectCommand(ctx *gcp.Context, cmd []string) ([]string, error) {
+	enabled, err := env.IsPresentAndTrue(EnvInspect)
+	if err != nil {
+		return nil, err
+	}
+	if !enabled {
+		return cmd, nil
+	}
+	if len(cmd) == 0 || cmd[0] != "node" {
+		ctx.Warnf("Ignoring %s because the start command %q does not run node directly, add %s to the node command of the start script to enable the V8 inspector.", EnvInspect, strings.Join(cmd, " "), InspectFlag)
+		return cmd, nil
+	}
+	ctx.Warnf("The V8 inspector is enabled on port 9229 of all interfaces, forward the port to attach a debugger. Do not use %s in production, anyone who can reach the port can run code in the application.", EnvInspect)
+	return append([]string{cmd[0], InspectFlag}, cmd[1:]...), nil
+}
+
 // CheckOrClearCache checks whether cached dependencies exist and match. If they do not match, the
 // layer is cleared and the layer metadata is updated with the new cache key.
 func CheckOrClearCache(ctx *gcp.Context, l *libcnb.Layer, opts ...cache.Option) (bool, error) {
@@ -284,7 +357,7 @@ func CheckOrClearCache(ctx *gcp.Context, l *libcnb.Layer, opts ...cache.Option)
 	if err != nil {
 		return false, err
 	}
-	opts = append(opts, cache.WithStrings(currentNodeVersion))
+	opts = append(opts, cache.WithNamedString("node version", currentNodeVersion))
 	hash, cached, err := cache.HashAndCheck(ctx, l, dependencyHashKey, opts...)
 	if err != nil {
 		return false, err
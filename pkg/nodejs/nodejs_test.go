@@ -17,6 +17,7 @@ package nodejs
 import (
 	"encoding/json"
 	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 
@@ -173,6 +174,100 @@ func TestHasScript(t *testing.T) {
 	}
 }
 
+func TestReleaseCommand(t *testing.T) {
+	testCases := []struct {
+		name           string
+		packageJSON    *PackageJSON
+		packageManager string
+		want           []string
+	}{
+		{
+			name:           "nil package.json",
+			packageManager: "npm",
+		},
+		{
+			name:           "no migrate script",
+			packageJSON:    &PackageJSON{Scripts: map[string]string{"start": "node index.js"}},
+			packageManager: "npm",
+		},
+		{
+			name:           "npm",
+			packageJSON:    &PackageJSON{Scripts: map[string]string{"migrate": "prisma migrate deploy"}},
+			packageManager: "npm",
+			want:           []string{"npm", "run", "migrate"},
+		},
+		{
+			name:           "yarn",
+			packageJSON:    &PackageJSON{Scripts: map[string]string{"migrate": "sequelize-cli db:migrate"}},
+			packageManager: "yarn",
+			want:           []string{"yarn", "run", "migrate"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := ReleaseCommand(tc.packageJSON, tc.packageManager)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("ReleaseCommand(%v, %q) = %v, want %v", tc.packageJSON, tc.packageManager, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestInspectCommand(t *testing.T) {
+	testCases := []struct {
+		name    string
+		inspect string
+		cmd     []string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "not set",
+			cmd:  []string{"node", "index.js"},
+			want: []string{"node", "index.js"},
+		},
+		{
+			name:    "disabled",
+			inspect: "false",
+			cmd:     []string{"node", "index.js"},
+			want:    []string{"node", "index.js"},
+		},
+		{
+			name:    "node command",
+			inspect: "true",
+			cmd:     []string{"node", "build/index.js"},
+			want:    []string{"node", InspectFlag, "build/index.js"},
+		},
+		{
+			name:    "package manager command",
+			inspect: "true",
+			cmd:     []string{"npm", "run", "start"},
+			want:    []string{"npm", "run", "start"},
+		},
+		{
+			name:    "invalid",
+			inspect: "yes please",
+			cmd:     []string{"node", "index.js"},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.inspect != "" {
+				t.Setenv(EnvInspect, tc.inspect)
+			}
+
+			got, err := InspectCommand(gcp.NewContext(), tc.cmd)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("InspectCommand(%q) got error: %v, want error: %t", tc.cmd, err, tc.wantErr)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("InspectCommand(%q) = %q, want %q", tc.cmd, got, tc.want)
+			}
+		})
+	}
+}
+
 func TestHasDevDependencies(t *testing.T) {
 	testCases := []struct {
 		name        string
@@ -219,12 +314,13 @@ func TestHasDevDependencies(t *testing.T) {
 
 func TestRequestedNodejsVersion(t *testing.T) {
 	testCases := []struct {
-		name        string
-		nodeEnv     string
-		runtimeEnv  string
-		packageJSON string
-		want        string
-		wantErr     bool
+		name         string
+		nodeEnv      string
+		runtimeEnv   string
+		packageJSON  string
+		toolVersions string
+		want         string
+		wantErr      bool
 	}{
 		{
 			name: "default is empty",
@@ -262,12 +358,33 @@ func TestRequestedNodejsVersion(t *testing.T) {
 			runtimeEnv:  "3.3.3",
 			want:        "3.3.3",
 		},
+		{
+			name:         ".tool-versions",
+			toolVersions: "ruby 3.3.0\nnodejs 20.11.0 18.19.0\npython 3.12.1\n",
+			want:         "20.11.0",
+		},
+		{
+			name:         ".tool-versions without nodejs",
+			toolVersions: "ruby 3.3.0\n",
+			want:         defaultVersionConstraint,
+		},
+		{
+			name:         "engines.nodejs and .tool-versions set",
+			packageJSON:  `{"engines": {"node": "2.2.2"}}`,
+			toolVersions: "nodejs 20.11.0\n",
+			want:         "2.2.2",
+		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 
 			dir := t.TempDir()
+			if tc.toolVersions != "" {
+				if err := os.WriteFile(filepath.Join(dir, ".tool-versions"), []byte(tc.toolVersions), 0644); err != nil {
+					t.Fatalf("writing .tool-versions: %v", err)
+				}
+			}
 			var pjs *PackageJSON
 			if tc.packageJSON != "" {
 				if err := json.Unmarshal([]byte(tc.packageJSON), &pjs); err != nil {
@@ -281,7 +398,7 @@ func TestRequestedNodejsVersion(t *testing.T) {
 				t.Setenv("GOOGLE_RUNTIME_VERSION", tc.runtimeEnv)
 			}
 
-			ctx := gcp.NewContext()
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(dir))
 			got, err := RequestedNodejsVersion(ctx, pjs)
 			if tc.wantErr == (err == nil) {
 				t.Errorf("RequestedNodejsVersion(ctx, %q) got error: %v, want err? %t", dir, err, tc.wantErr)
@@ -358,6 +475,7 @@ func TestReadNodeDependencies(t *testing.T) {
 	testCases := []struct {
 		name          string
 		rootDir       string
+		workspaceRoot string
 		appDir        string
 		expectedError bool
 		want          *NodeDependencies
@@ -384,10 +502,20 @@ func TestReadNodeDependencies(t *testing.T) {
 				LockfilePath: testdata.MustGetPath("testdata/test-read-node-deps-nested/package-lock.json"),
 			},
 		},
+		{
+			name:          "lockfile in workspace root above application root",
+			rootDir:       testdata.MustGetPath("testdata/test-read-node-deps-nested/package-a"),
+			workspaceRoot: testdata.MustGetPath("testdata/test-read-node-deps-nested"),
+			appDir:        testdata.MustGetPath("testdata/test-read-node-deps-nested/package-a"),
+			want: &NodeDependencies{
+				PackageJSON:  want.PackageJSON,
+				LockfilePath: testdata.MustGetPath("testdata/test-read-node-deps-nested/package-lock.json"),
+			},
+		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			ctx := gcp.NewContext(gcp.WithApplicationRoot(tc.rootDir))
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(tc.rootDir), gcp.WithWorkspaceRoot(tc.workspaceRoot))
 			got, err := ReadNodeDependencies(ctx, tc.appDir)
 			if err != nil && !tc.expectedError {
 				t.Fatalf("ReadNodeDependencies returned an unexpected error: %v", err)
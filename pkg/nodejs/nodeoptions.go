@@ -0,0 +1,189 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/Masterminds/semver"
+)
+
+const (
+	// EnvV8Flags is a space-separated list of V8 flags added to NODE_OPTIONS at run time, for example
+	// "--max-semi-space-size=64 --expose-gc".
+	EnvV8Flags = "GOOGLE_NODEJS_V8_FLAGS"
+
+	// nodeJSHeadroomMB is the amount of memory we'll set aside before computing the max memory size.
+	nodeJSHeadroomMB int = 64
+
+	maxOldSpaceSizeFlag = "--max-old-space-size"
+	exposeGCFlag        = "--expose-gc"
+
+	// exposeGCVersions are the Node.js versions that allow --expose-gc in NODE_OPTIONS. Older versions
+	// refuse to start when NODE_OPTIONS contains it.
+	exposeGCVersions = ">=20.18.0, <21.0.0 || >=22.3.0"
+)
+
+// allowedV8Flags are the flags accepted in GOOGLE_NODEJS_V8_FLAGS, all of which Node.js allows in
+// NODE_OPTIONS. The value is true for the flags that take a numeric value, e.g. --stack-trace-limit=50.
+var allowedV8Flags = map[string]bool{
+	"--abort-on-uncaught-exception":     false,
+	exposeGCFlag:                        false,
+	"--huge-max-old-generation-size":    false,
+	"--interpreted-frames-native-stack": false,
+	"--jitless":                         false,
+	maxOldSpaceSizeFlag:                 true,
+	"--max-semi-space-size":             true,
+	"--perf-basic-prof":                 false,
+	"--perf-basic-prof-only-functions":  false,
+	"--perf-prof":                       false,
+	"--perf-prof-unwinding-info":        false,
+	"--stack-trace-limit":               true,
+}
+
+// NodeOptions returns the flags to add to NODE_OPTIONS at run time: the --max-old-space-size
+// computed from GOOGLE_CONTAINER_MEMORY_HINT_MB, the heap snapshot flags enabled by
+// GOOGLE_NODE_HEAP_DUMP_ON_OOM and the flags from GOOGLE_NODEJS_V8_FLAGS. A --max-old-space-size
+// set in GOOGLE_NODEJS_V8_FLAGS takes precedence over the computed one. nodeVersion is the
+// installed version of Node.js, which must allow every flag in NODE_OPTIONS.
+func NodeOptions(ctx *gcp.Context, nodeVersion string) ([]string, error) {
+	v8Flags, err := V8Flags()
+	if err != nil {
+		return nil, err
+	}
+	if hasV8Flag(v8Flags, exposeGCFlag) {
+		if err := checkExposeGC(nodeVersion); err != nil {
+			return nil, err
+		}
+	}
+	var opts []string
+	// Keep the existing behaviour if the memory hint is not provided.
+	size, err := MaxOldSpaceSize()
+	if err != nil {
+		return nil, err
+	}
+	if size > 0 {
+		if userSize := v8FlagValue(v8Flags, maxOldSpaceSizeFlag); userSize != "" {
+			ctx.Logf("Using %s=%s from %s instead of %d computed from %s.", maxOldSpaceSizeFlag, userSize, EnvV8Flags, size, env.ContainerMemoryHintMB)
+		} else {
+			opts = append(opts, fmt.Sprintf("%s=%d", maxOldSpaceSizeFlag, size))
+		}
+	}
+	heapDumpOpts, err := HeapDumpNodeOptions()
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, heapDumpOpts...)
+	return append(opts, v8Flags...), nil
+}
+
+// MaxOldSpaceSize returns the memory size specified by (GOOGLE_CONTAINER_MEMORY_HINT_MB - nodeJSHeadroomMB),
+// or 0 if env var is not specified.
+func MaxOldSpaceSize() (int, error) {
+	memHintStr, exist := os.LookupEnv(env.ContainerMemoryHintMB)
+	if !exist {
+		return 0, nil
+	}
+
+	memHint, err := strconv.Atoi(memHintStr)
+	if err != nil {
+		return 0, fmt.Errorf("%s=%q must be an integer: %v", env.ContainerMemoryHintMB, memHintStr, err)
+	}
+
+	if memHint <= nodeJSHeadroomMB {
+		return 0, fmt.Errorf("%s=%q must be greater than %d", env.ContainerMemoryHintMB, memHintStr, nodeJSHeadroomMB)
+	}
+
+	return memHint - nodeJSHeadroomMB, nil
+}
+
+// V8Flags returns the flags listed in GOOGLE_NODEJS_V8_FLAGS after checking that they are allowed.
+func V8Flags() ([]string, error) {
+	flags := strings.Fields(os.Getenv(EnvV8Flags))
+	for _, flag := range flags {
+		name, value, hasValue := strings.Cut(flag, "=")
+		takesValue, ok := allowedV8Flags[normalizeV8Flag(name)]
+		if !ok {
+			return nil, gcp.UserErrorf("%s contains %q, which is not supported: use one of %s", EnvV8Flags, name, strings.Join(supportedV8Flags(), ", "))
+		}
+		if !takesValue && hasValue {
+			return nil, gcp.UserErrorf("%s flag %s does not take a value, got %q", EnvV8Flags, name, flag)
+		}
+		if takesValue {
+			if n, err := strconv.Atoi(value); err != nil || n <= 0 {
+				return nil, gcp.UserErrorf("%s flag %s must be set to a positive integer, e.g. %s=64, got %q", EnvV8Flags, name, name, flag)
+			}
+		}
+	}
+	return flags, nil
+}
+
+// checkExposeGC returns an error if the given version of Node.js does not allow --expose-gc in
+// NODE_OPTIONS.
+func checkExposeGC(nodeVersion string) error {
+	v, err := semver.NewVersion(nodeVersion)
+	if err != nil {
+		return gcp.InternalErrorf("parsing Node.js version %q: %v", nodeVersion, err)
+	}
+	c, err := semver.NewConstraint(exposeGCVersions)
+	if err != nil {
+		return gcp.InternalErrorf("parsing constraint %q: %v", exposeGCVersions, err)
+	}
+	if !c.Check(v) {
+		return gcp.UserErrorf("%s contains %s, which Node.js v%s does not allow in NODE_OPTIONS: use Node.js 20.18.0, 22.3.0 or later, or remove the flag", EnvV8Flags, exposeGCFlag, v)
+	}
+	return nil
+}
+
+// hasV8Flag returns true if flags contains the given flag without a value.
+func hasV8Flag(flags []string, name string) bool {
+	for _, flag := range flags {
+		if normalizeV8Flag(flag) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// v8FlagValue returns the value of the given flag in flags, or an empty string if it is not set.
+func v8FlagValue(flags []string, name string) string {
+	for _, flag := range flags {
+		if n, v, ok := strings.Cut(flag, "="); ok && normalizeV8Flag(n) == name {
+			return v
+		}
+	}
+	return ""
+}
+
+// normalizeV8Flag returns the flag name with underscores replaced by dashes, since V8 accepts both,
+// e.g. --max_old_space_size.
+func normalizeV8Flag(name string) string {
+	return strings.ReplaceAll(name, "_", "-")
+}
+
+func supportedV8Flags() []string {
+	var flags []string
+	for flag := range allowedV8Flags {
+		flags = append(flags, flag)
+	}
+	sort.Strings(flags)
+	return flags
+}
@@ -0,0 +1,217 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+func TestMaxOldSpaceSize(t *testing.T) {
+	testCases := []struct {
+		name    string
+		env     []string
+		want    int
+		wantErr bool
+	}{
+		{
+			name: "env val not set",
+		},
+		{
+			name:    "env val set but no value",
+			env:     []string{"GOOGLE_CONTAINER_MEMORY_HINT_MB="},
+			wantErr: true,
+		},
+		{
+			name:    "env val set but less than head room",
+			env:     []string{"GOOGLE_CONTAINER_MEMORY_HINT_MB=10"},
+			wantErr: true,
+		},
+		{
+			name:    "env val set but 0",
+			env:     []string{"GOOGLE_CONTAINER_MEMORY_HINT_MB=0"},
+			wantErr: true,
+		},
+		{
+			name:    "env val set but negative",
+			env:     []string{"GOOGLE_CONTAINER_MEMORY_HINT_MB=-10"},
+			wantErr: true,
+		},
+		{
+			name:    "env val set not integer",
+			env:     []string{"GOOGLE_CONTAINER_MEMORY_HINT_MB=1a2b"},
+			wantErr: true,
+		},
+		{
+			name:    "env val set but equal to head room",
+			env:     []string{"GOOGLE_CONTAINER_MEMORY_HINT_MB=" + strconv.Itoa(nodeJSHeadroomMB)},
+			wantErr: true,
+		},
+		{
+			name: "env val set and greater than head room",
+			env:  []string{"GOOGLE_CONTAINER_MEMORY_HINT_MB=4096"},
+			want: 4096 - nodeJSHeadroomMB,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			setEnvs(t, tc.env)
+
+			got, err := MaxOldSpaceSize()
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("MaxOldSpaceSize() got err=%t, want err=%t. err: %v", gotErr, tc.wantErr, err)
+			}
+			if got != tc.want {
+				t.Errorf("MaxOldSpaceSize()=%d, want=%d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestNodeOptions(t *testing.T) {
+	testCases := []struct {
+		name        string
+		env         []string
+		nodeVersion string
+		want        string
+		wantErr     bool
+	}{
+		{
+			name: "no options",
+		},
+		{
+			name: "max old space size only",
+			env:  []string{"GOOGLE_CONTAINER_MEMORY_HINT_MB=512"},
+			want: "--max-old-space-size=448",
+		},
+		{
+			name: "heap dump only",
+			env:  []string{"GOOGLE_NODE_HEAP_DUMP_ON_OOM=true"},
+			want: "--heapsnapshot-signal=SIGUSR2 --heapsnapshot-near-heap-limit=3",
+		},
+		{
+			name: "heap dump disabled",
+			env:  []string{"GOOGLE_NODE_HEAP_DUMP_ON_OOM=false"},
+		},
+		{
+			name: "max old space size and heap dump",
+			env:  []string{"GOOGLE_CONTAINER_MEMORY_HINT_MB=512", "GOOGLE_NODE_HEAP_DUMP_ON_OOM=true"},
+			want: "--max-old-space-size=448 --heapsnapshot-signal=SIGUSR2 --heapsnapshot-near-heap-limit=3",
+		},
+		{
+			name: "v8 flags",
+			env:  []string{"GOOGLE_NODEJS_V8_FLAGS= --max-semi-space-size=64   --expose-gc "},
+			want: "--max-semi-space-size=64 --expose-gc",
+		},
+		{
+			name: "v8 flags with memory hint",
+			env:  []string{"GOOGLE_CONTAINER_MEMORY_HINT_MB=512", "GOOGLE_NODEJS_V8_FLAGS=--expose-gc"},
+			want: "--max-old-space-size=448 --expose-gc",
+		},
+		{
+			name: "user max old space size takes precedence",
+			env:  []string{"GOOGLE_CONTAINER_MEMORY_HINT_MB=512", "GOOGLE_NODEJS_V8_FLAGS=--max_old_space_size=300"},
+			want: "--max_old_space_size=300",
+		},
+		{
+			name:        "expose gc on node 20.18",
+			env:         []string{"GOOGLE_NODEJS_V8_FLAGS=--expose-gc"},
+			nodeVersion: "20.18.0",
+			want:        "--expose-gc",
+		},
+		{
+			name:        "expose gc on node 20.17",
+			env:         []string{"GOOGLE_NODEJS_V8_FLAGS=--expose-gc"},
+			nodeVersion: "20.17.0",
+			wantErr:     true,
+		},
+		{
+			name:        "expose gc on node 21",
+			env:         []string{"GOOGLE_NODEJS_V8_FLAGS=--expose_gc"},
+			nodeVersion: "v21.7.3",
+			wantErr:     true,
+		},
+		{
+			name:        "expose gc on node 18",
+			env:         []string{"GOOGLE_NODEJS_V8_FLAGS=--expose-gc"},
+			nodeVersion: "18.20.4",
+			wantErr:     true,
+		},
+		{
+			name:        "other v8 flags on node 18",
+			env:         []string{"GOOGLE_NODEJS_V8_FLAGS=--max-semi-space-size=64"},
+			nodeVersion: "18.20.4",
+			want:        "--max-semi-space-size=64",
+		},
+		{
+			name:    "unsupported v8 flag",
+			env:     []string{"GOOGLE_NODEJS_V8_FLAGS=--expose-gc --allow-natives-syntax"},
+			wantErr: true,
+		},
+		{
+			name:    "v8 flag without value",
+			env:     []string{"GOOGLE_NODEJS_V8_FLAGS=--max-semi-space-size"},
+			wantErr: true,
+		},
+		{
+			name:    "v8 flag with invalid value",
+			env:     []string{"GOOGLE_NODEJS_V8_FLAGS=--max-semi-space-size=lots"},
+			wantErr: true,
+		},
+		{
+			name:    "v8 flag with unexpected value",
+			env:     []string{"GOOGLE_NODEJS_V8_FLAGS=--expose-gc=true"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid heap dump value",
+			env:     []string{"GOOGLE_NODE_HEAP_DUMP_ON_OOM=sometimes"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid memory hint",
+			env:     []string{"GOOGLE_CONTAINER_MEMORY_HINT_MB=1a2b", "GOOGLE_NODE_HEAP_DUMP_ON_OOM=true"},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			setEnvs(t, tc.env)
+
+			nodeVersion := tc.nodeVersion
+			if nodeVersion == "" {
+				nodeVersion = "22.3.0"
+			}
+			opts, err := NodeOptions(gcp.NewContext(), nodeVersion)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("NodeOptions() got err=%t, want err=%t. err: %v", gotErr, tc.wantErr, err)
+			}
+			if got := strings.Join(opts, " "); got != tc.want {
+				t.Errorf("NodeOptions()=%q, want=%q", got, tc.want)
+			}
+		})
+	}
+}
+
+func setEnvs(t *testing.T, envs []string) {
+	t.Helper()
+	for _, e := range envs {
+		k, v, _ := strings.Cut(e, "=")
+		t.Setenv(k, v)
+	}
+}
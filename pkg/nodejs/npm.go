@@ -15,14 +15,19 @@
 package nodejs
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildermetrics"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/fileutil"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/Masterminds/semver"
+	"github.com/buildpacks/libcnb"
 )
 
 const (
@@ -37,8 +42,19 @@ const (
 	nodejsNPMBuildEnv = "GOOGLE_EXPERIMENTAL_NODEJS_NPM_BUILD_ENABLED"
 	// VendorNpmDeps for vendoring npm dependencies
 	VendorNpmDeps = "GOOGLE_VENDOR_NPM_DEPENDENCIES"
+	// NPMShrinkwrapEnv is the env var that generates an npm-shrinkwrap.json instead of a
+	// package-lock.json when the application has no lockfile.
+	NPMShrinkwrapEnv = "GOOGLE_NPM_SHRINKWRAP"
+	// shrinkwrapHashKey is the layer metadata key of the package.json the shrinkwrap was generated from.
+	shrinkwrapHashKey = "package_json_hash"
 	// AppHostingBuildEnv is the env var that contains the build command to run for Firebase backends.
 	AppHostingBuildEnv = "APPHOSTING_BUILD"
+	// NPMInstallFallbackEnv is the env var that falls back from "npm ci" to "npm install" when
+	// package.json and the lockfile are out of sync.
+	NPMInstallFallbackEnv = "GOOGLE_NPM_INSTALL_FALLBACK"
+	// lockfileOutOfSync is part of the error message of "npm ci" when package.json and the lockfile
+	// are out of sync.
+	lockfileOutOfSync = "can only install packages when your package.json and"
 )
 
 var (
@@ -46,8 +62,30 @@ var (
 	minPruneVersion = semver.MustParse("5.7.0")
 	// minNpmCIVersion is the first npm version that suports the ci command.
 	minNpmCIVersion = semver.MustParse("6.14.0")
+	// lockfileRequirements maps the lockfileVersion of an npm lockfile to the npm versions that can
+	// install from it. Version 1 is understood by all npm versions that generate lockfiles and version
+	// 2 is backwards compatible with npm 6, so only version 3 has requirements.
+	lockfileRequirements = map[int]lockfileRequirement{
+		3: {minNPM: semver.MustParse("7.0.0"), compatible: "^8.0.0"},
+	}
 )
 
+// lockfileRequirement describes the npm versions that can install from a lockfileVersion.
+type lockfileRequirement struct {
+	// minNPM is the first npm version that can install from the lockfile.
+	minNPM *semver.Version
+	// compatible is the npm version constraint installed when the bundled npm is too old.
+	compatible string
+}
+
+// lockfileMismatch describes an npm lockfile that the installed npm cannot install from.
+type lockfileMismatch struct {
+	lockfile        string
+	lockfileVersion int
+	npmVersion      *semver.Version
+	lockfileRequirement
+}
+
 // RequestedNPMVersion returns any customer provided NPM version constraint configured in the
 // "engines" section of the package.json file in the given application dir.
 func RequestedNPMVersion(pjs *PackageJSON) (string, error) {
@@ -62,27 +100,162 @@ func RequestedNPMVersion(pjs *PackageJSON) (string, error) {
 }
 
 // EnsureLockfile returns the name of the lockfile, generating a package-lock.json if necessary.
+// A lockfile shared by npm workspaces may be found in a parent directory up to the workspace root,
+// in which case its path is returned.
 func EnsureLockfile(ctx *gcp.Context) (string, error) {
-	npmShrinkwrapExists, err := ctx.FileExists(NPMShrinkwrap)
-	if err != nil {
+	lockfile, err := findLockfile(ctx)
+	if err != nil || lockfile != "" {
+		return lockfile, err
+	}
+	ctx.Logf("Generating %s.", PackageLock)
+	ctx.Warnf("*** Improve build performance by generating and committing %s.", PackageLock)
+	if _, err := ctx.Exec([]string{"npm", "install", "--package-lock-only", "--quiet"}, gcp.WithUserAttribution); err != nil {
 		return "", err
 	}
-	// npm prefers npm-shrinkwrap.json, see https://docs.npmjs.com/cli/shrinkwrap.
-	if npmShrinkwrapExists {
-		return NPMShrinkwrap, nil
+	return PackageLock, nil
+}
+
+// EnsureShrinkwrap is like EnsureLockfile but generates an npm-shrinkwrap.json instead of a
+// package-lock.json if the application has no lockfile. The generated file is kept in the given
+// cache layer and reused by later builds until package.json changes, so that dependency versions
+// stay the same between builds.
+func EnsureShrinkwrap(ctx *gcp.Context, l *libcnb.Layer) (string, error) {
+	lockfile, err := findLockfile(ctx)
+	if err != nil || lockfile != "" {
+		return lockfile, err
 	}
-	pkgLockExists, err := ctx.FileExists(PackageLock)
+	shrinkwrap := filepath.Join(ctx.ApplicationRoot(), NPMShrinkwrap)
+	saved := filepath.Join(l.Path, NPMShrinkwrap)
+	hash, cached, err := cache.HashAndCheck(ctx, l, shrinkwrapHashKey, cache.WithFiles(filepath.Join(ctx.ApplicationRoot(), "package.json")))
 	if err != nil {
 		return "", err
 	}
-	if !pkgLockExists {
-		ctx.Logf("Generating %s.", PackageLock)
-		ctx.Warnf("*** Improve build performance by generating and committing %s.", PackageLock)
-		if _, err := ctx.Exec([]string{"npm", "install", "--package-lock-only", "--quiet"}, gcp.WithUserAttribution); err != nil {
+	savedExists, err := ctx.FileExists(saved)
+	if err != nil {
+		return "", err
+	}
+	if cached && savedExists {
+		ctx.Logf("Using %s generated by a previous build.", NPMShrinkwrap)
+		if err := fileutil.CopyFile(shrinkwrap, saved); err != nil {
+			return "", gcp.InternalErrorf("copying %s to %s: %v", saved, shrinkwrap, err)
+		}
+	} else {
+		if err := ctx.ClearLayer(l); err != nil {
+			return "", fmt.Errorf("clearing layer %q: %w", l.Name, err)
+		}
+		ctx.Logf("Generating %s.", NPMShrinkwrap)
+		if _, err := ctx.Exec([]string{"npm", "install", "--package-lock-only", "--quiet"}, gcp.WithWorkDir(ctx.ApplicationRoot()), gcp.WithUserAttribution); err != nil {
+			return "", err
+		}
+		// npm shrinkwrap renames the package-lock.json to npm-shrinkwrap.json.
+		if _, err := ctx.Exec([]string{"npm", "shrinkwrap", "--quiet"}, gcp.WithWorkDir(ctx.ApplicationRoot()), gcp.WithUserAttribution); err != nil {
 			return "", err
 		}
+		if err := fileutil.CopyFile(saved, shrinkwrap); err != nil {
+			return "", gcp.InternalErrorf("copying %s to %s: %v", shrinkwrap, saved, err)
+		}
+		cache.Add(ctx, l, shrinkwrapHashKey, hash)
 	}
-	return PackageLock, nil
+	ctx.Warnf("*** Commit the generated %s to your repository to make builds reproducible.", NPMShrinkwrap)
+	return NPMShrinkwrap, nil
+}
+
+// findLockfile returns the name of the npm lockfile in the application root, or the path of a
+// lockfile shared by npm workspaces up to the workspace root. It returns an empty string if there
+// is no lockfile.
+func findLockfile(ctx *gcp.Context) (string, error) {
+	// npm prefers npm-shrinkwrap.json, see https://docs.npmjs.com/cli/shrinkwrap.
+	lockfile, err := ctx.FindInWorkspace(NPMShrinkwrap, PackageLock)
+	if err != nil || lockfile == "" {
+		return "", err
+	}
+	if filepath.Dir(lockfile) == ctx.ApplicationRoot() {
+		return filepath.Base(lockfile), nil
+	}
+	ctx.Logf("Using workspace lockfile %s.", lockfile)
+	return lockfile, nil
+}
+
+// NPMLockfileVersion returns the path of the npm lockfile and its lockfileVersion, or an empty path
+// if the application has no lockfile. Lockfiles without a lockfileVersion were generated by npm 5 or
+// 6 and are version 1.
+func NPMLockfileVersion(ctx *gcp.Context) (string, int, error) {
+	lockfile, err := ctx.FindInWorkspace(NPMShrinkwrap, PackageLock)
+	if err != nil || lockfile == "" {
+		return "", 0, err
+	}
+	data, err := ctx.ReadFile(lockfile)
+	if err != nil {
+		return "", 0, err
+	}
+	var lock struct {
+		LockfileVersion int `json:"lockfileVersion"`
+	}
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return "", 0, gcp.UserErrorf("parsing %s: %v", lockfile, err)
+	}
+	if lock.LockfileVersion == 0 {
+		return lockfile, 1, nil
+	}
+	return lockfile, lock.LockfileVersion, nil
+}
+
+// checkLockfileVersion returns a description of the mismatch if the installed npm cannot install
+// from the application's lockfile, or nil if it can.
+func checkLockfileVersion(ctx *gcp.Context) (*lockfileMismatch, error) {
+	lockfile, lockfileVersion, err := NPMLockfileVersion(ctx)
+	if err != nil || lockfile == "" {
+		return nil, err
+	}
+	req, ok := lockfileRequirements[lockfileVersion]
+	if !ok {
+		return nil, nil
+	}
+	npmVer, err := npmVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	installed, err := semver.NewVersion(npmVer)
+	if err != nil {
+		return nil, gcp.InternalErrorf("parsing npm version: %v", err)
+	}
+	if !installed.LessThan(req.minNPM) {
+		return nil, nil
+	}
+	return &lockfileMismatch{lockfile: lockfile, lockfileVersion: lockfileVersion, npmVersion: installed, lockfileRequirement: req}, nil
+}
+
+// NPMVersionForLockfile returns an npm version that can install from the application's lockfile if
+// the installed npm cannot, or an empty string otherwise. It is used to select the npm version when
+// package.json does not request one.
+func NPMVersionForLockfile(ctx *gcp.Context) (string, error) {
+	m, err := checkLockfileVersion(ctx)
+	if err != nil || m == nil {
+		return "", err
+	}
+	version, err := resolvePackageVersion("npm", m.compatible)
+	if err != nil {
+		return "", gcp.InternalErrorf("fetching npm metadata: %v", err)
+	}
+	ctx.Logf("%s has lockfileVersion %d, which npm %s cannot install from, using npm %s instead.", filepath.Base(m.lockfile), m.lockfileVersion, m.npmVersion, version)
+	return version, nil
+}
+
+// ValidateLockfileVersion returns a user error with the possible fixes if the installed npm cannot
+// install from the application's lockfile.
+func ValidateLockfileVersion(ctx *gcp.Context) error {
+	m, err := checkLockfileVersion(ctx)
+	if err != nil || m == nil {
+		return err
+	}
+	name := filepath.Base(m.lockfile)
+	return gcp.UserErrorf("%s has lockfileVersion %d, which requires npm %s or later, but the build uses npm %s. Either request a compatible npm version in package.json, e.g. \"engines\": {\"npm\": %q}, or regenerate %s with npm %s by running \"npm install --package-lock-only\".", name, m.lockfileVersion, m.minNPM, m.npmVersion, m.compatible, name, m.npmVersion)
+}
+
+// IsLockfileOutOfSync returns true if the output of a failed "npm ci" reports that package.json and
+// the lockfile are out of sync.
+func IsLockfileOutOfSync(output string) bool {
+	return strings.Contains(output, lockfileOutOfSync)
 }
 
 // NPMInstallCommand returns the correct install command based on the version of Node.js. By default
@@ -16,11 +16,17 @@ package nodejs
 
 import (
 	"encoding/json"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"google3/security/safeopen/safeopen"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
 	"github.com/google/go-cmp/cmp"
 )
 
@@ -120,6 +126,116 @@ func TestNPMInstallCommand(t *testing.T) {
 	}
 }
 
+func TestEnsureShrinkwrap(t *testing.T) {
+	const pkgJSON = `{"dependencies": {"express": "^4.0.0"}}`
+	testCases := []struct {
+		name string
+		// files are written to the application root before the build.
+		files map[string]string
+		// previous is the package.json of a previous build, if any.
+		previous     string
+		want         string
+		wantCommands []string
+	}{
+		{
+			name:  "generates shrinkwrap",
+			files: map[string]string{"package.json": pkgJSON},
+			want:  NPMShrinkwrap,
+			wantCommands: []string{
+				"npm install --package-lock-only --quiet",
+				"npm shrinkwrap --quiet",
+			},
+		},
+		{
+			name:     "reuses shrinkwrap from previous build",
+			files:    map[string]string{"package.json": pkgJSON},
+			previous: pkgJSON,
+			want:     NPMShrinkwrap,
+		},
+		{
+			name:     "regenerates shrinkwrap when package.json changed",
+			files:    map[string]string{"package.json": pkgJSON},
+			previous: `{"dependencies": {"express": "^3.0.0"}}`,
+			want:     NPMShrinkwrap,
+			wantCommands: []string{
+				"npm install --package-lock-only --quiet",
+				"npm shrinkwrap --quiet",
+			},
+		},
+		{
+			name:  "existing package-lock.json",
+			files: map[string]string{"package.json": pkgJSON, PackageLock: "{}"},
+			want:  PackageLock,
+		},
+		{
+			name:  "existing npm-shrinkwrap.json",
+			files: map[string]string{"package.json": pkgJSON, NPMShrinkwrap: "{}"},
+			want:  NPMShrinkwrap,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			layer := &libcnb.Layer{Name: "npm_shrinkwrap", Path: t.TempDir(), Metadata: map[string]interface{}{}}
+			if tc.previous != "" {
+				home := t.TempDir()
+				writeFiles(t, home, map[string]string{"package.json": tc.previous})
+				if _, err := EnsureShrinkwrap(newShrinkwrapContext(t, home, nil), layer); err != nil {
+					t.Fatalf("EnsureShrinkwrap() for previous build got error: %v", err)
+				}
+			}
+			home := t.TempDir()
+			writeFiles(t, home, tc.files)
+			var commands []string
+			ctx := newShrinkwrapContext(t, home, &commands)
+
+			got, err := EnsureShrinkwrap(ctx, layer)
+			if err != nil {
+				t.Fatalf("EnsureShrinkwrap() got error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("EnsureShrinkwrap() = %q, want %q", got, tc.want)
+			}
+			if diff := cmp.Diff(tc.wantCommands, commands); diff != "" {
+				t.Errorf("EnsureShrinkwrap() commands mismatch (-want +got):\n%s", diff)
+			}
+			if _, ok := tc.files[tc.want]; ok {
+				return
+			}
+			for _, f := range []string{filepath.Join(home, NPMShrinkwrap), filepath.Join(layer.Path, NPMShrinkwrap)} {
+				if _, err := os.Stat(f); err != nil {
+					t.Errorf("EnsureShrinkwrap() did not write %s: %v", f, err)
+				}
+			}
+		})
+	}
+}
+
+// newShrinkwrapContext returns a context for an application in home whose `npm shrinkwrap` command
+// writes an npm-shrinkwrap.json file. Commands are recorded in commands if it is not nil.
+func newShrinkwrapContext(t *testing.T, home string, commands *[]string) *gcpbuildpack.Context {
+	t.Helper()
+	execCmd := func(name string, args ...string) *exec.Cmd {
+		cmd := strings.Join(append([]string{name}, args...), " ")
+		if commands != nil {
+			*commands = append(*commands, cmd)
+		}
+		if strings.HasPrefix(cmd, "npm shrinkwrap") {
+			return exec.Command("sh", "-c", "echo {} > "+NPMShrinkwrap)
+		}
+		return exec.Command("true")
+	}
+	return gcpbuildpack.NewContext(gcpbuildpack.WithApplicationRoot(home), gcpbuildpack.WithExecCmd(execCmd))
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("writing %s: %v", name, err)
+		}
+	}
+}
+
 func TestSupportsNPMPrune(t *testing.T) {
 	testCases := []struct {
 		version string
@@ -442,3 +558,175 @@ func TestDefaultStartCommand(t *testing.T) {
 		})
 	}
 }
+
+func TestNPMLockfileVersion(t *testing.T) {
+	testCases := []struct {
+		name         string
+		files        map[string]string
+		wantLockfile string
+		wantVersion  int
+		wantErr      bool
+	}{
+		{
+			name: "no lockfile",
+		},
+		{
+			name:         "package-lock.json",
+			files:        map[string]string{PackageLock: `{"lockfileVersion": 3}`},
+			wantLockfile: PackageLock,
+			wantVersion:  3,
+		},
+		{
+			name:         "npm-shrinkwrap.json preferred",
+			files:        map[string]string{PackageLock: `{"lockfileVersion": 3}`, NPMShrinkwrap: `{"lockfileVersion": 2}`},
+			wantLockfile: NPMShrinkwrap,
+			wantVersion:  2,
+		},
+		{
+			name:         "no lockfileVersion",
+			files:        map[string]string{PackageLock: `{"dependencies": {}}`},
+			wantLockfile: PackageLock,
+			wantVersion:  1,
+		},
+		{
+			name:    "invalid lockfile",
+			files:   map[string]string{PackageLock: `{"lockfileVersion": `},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for f, c := range tc.files {
+				if err := os.WriteFile(filepath.Join(dir, f), []byte(c), 0644); err != nil {
+					t.Fatalf("writing %s: %v", f, err)
+				}
+			}
+			ctx := gcpbuildpack.NewContext(gcpbuildpack.WithApplicationRoot(dir))
+
+			lockfile, version, err := NPMLockfileVersion(ctx)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("NPMLockfileVersion() got error: %v, want error: %v", err, tc.wantErr)
+			}
+			wantLockfile := ""
+			if tc.wantLockfile != "" {
+				wantLockfile = filepath.Join(dir, tc.wantLockfile)
+			}
+			if lockfile != wantLockfile || version != tc.wantVersion {
+				t.Errorf("NPMLockfileVersion() = %q, %d, want %q, %d", lockfile, version, wantLockfile, tc.wantVersion)
+			}
+		})
+	}
+}
+
+func TestValidateLockfileVersion(t *testing.T) {
+	testCases := []struct {
+		name       string
+		lockfile   string
+		npmVersion string
+		wantErr    []string
+	}{
+		{
+			name:       "no lockfile",
+			npmVersion: "6.14.4",
+		},
+		{
+			name:       "version 1 with npm 10",
+			lockfile:   `{"lockfileVersion": 1}`,
+			npmVersion: "10.2.0",
+		},
+		{
+			name:       "version 2 with npm 6",
+			lockfile:   `{"lockfileVersion": 2}`,
+			npmVersion: "6.14.4",
+		},
+		{
+			name:       "version 3 with npm 7",
+			lockfile:   `{"lockfileVersion": 3}`,
+			npmVersion: "7.0.0",
+		},
+		{
+			name:       "version 3 with npm 6",
+			lockfile:   `{"lockfileVersion": 3}`,
+			npmVersion: "6.14.4",
+			wantErr:    []string{"lockfileVersion 3", "npm 6.14.4", `"engines"`, "npm install --package-lock-only"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			defer func(fn func(*gcpbuildpack.Context) (string, error)) { npmVersion = fn }(npmVersion)
+			npmVersion = func(*gcpbuildpack.Context) (string, error) { return tc.npmVersion, nil }
+			dir := t.TempDir()
+			if tc.lockfile != "" {
+				if err := os.WriteFile(filepath.Join(dir, PackageLock), []byte(tc.lockfile), 0644); err != nil {
+					t.Fatalf("writing %s: %v", PackageLock, err)
+				}
+			}
+			ctx := gcpbuildpack.NewContext(gcpbuildpack.WithApplicationRoot(dir))
+
+			err := ValidateLockfileVersion(ctx)
+			if len(tc.wantErr) == 0 {
+				if err != nil {
+					t.Fatalf("ValidateLockfileVersion() got error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("ValidateLockfileVersion() got nil error, want error containing %q", tc.wantErr)
+			}
+			for _, want := range tc.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("ValidateLockfileVersion() = %q, want error containing %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestNPMVersionForLockfile(t *testing.T) {
+	testCases := []struct {
+		name       string
+		lockfile   string
+		npmVersion string
+		want       string
+	}{
+		{
+			name:       "compatible",
+			lockfile:   `{"lockfileVersion": 3}`,
+			npmVersion: "8.19.4",
+		},
+		{
+			name:       "incompatible",
+			lockfile:   `{"lockfileVersion": 3}`,
+			npmVersion: "6.14.4",
+			want:       "8.19.4",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			stubNPMRegistry(t, `{
+				"name": "npm",
+				"versions": {
+					"6.14.18": {"name": "npm", "version": "6.14.18"},
+					"8.19.4": {"name": "npm", "version": "8.19.4"},
+					"10.2.0": {"name": "npm", "version": "10.2.0"}
+				}
+			}`, http.StatusOK)
+			defer func(fn func(*gcpbuildpack.Context) (string, error)) { npmVersion = fn }(npmVersion)
+			npmVersion = func(*gcpbuildpack.Context) (string, error) { return tc.npmVersion, nil }
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, PackageLock), []byte(tc.lockfile), 0644); err != nil {
+				t.Fatalf("writing %s: %v", PackageLock, err)
+			}
+			ctx := gcpbuildpack.NewContext(gcpbuildpack.WithApplicationRoot(dir))
+
+			got, err := NPMVersionForLockfile(ctx)
+			if err != nil {
+				t.Fatalf("NPMVersionForLockfile() got error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("NPMVersionForLockfile() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+const (
+	// OTelEnv is an env var that enables OpenTelemetry auto-instrumentation and the Cloud Profiler
+	// agent without changes to the application code.
+	OTelEnv = "GOOGLE_NODEJS_ENABLE_OTEL"
+	// OTelLayer is the name of the layer that holds the instrumentation bootstrap.
+	OTelLayer = "otel"
+	// OTelBootstrap is the name of the script loaded with --require before the application.
+	OTelBootstrap = "otel-bootstrap.js"
+
+	// otelBootstrapVersion must be incremented whenever otelBootstrapJS changes so that cached
+	// layers are rebuilt.
+	otelBootstrapVersion = "1"
+
+	otelBootstrapJS = `'use strict';
+// Generated by the nodejs buildpacks because GOOGLE_NODEJS_ENABLE_OTEL is set. Dependencies are
+// resolved from the node_modules directory next to this file, never from the application.
+const {NodeSDK} = require('@opentelemetry/sdk-node');
+const {getNodeAutoInstrumentations} = require('@opentelemetry/auto-instrumentations-node');
+
+const sdk = new NodeSDK({instrumentations: [getNodeAutoInstrumentations()]});
+sdk.start();
+process.once('SIGTERM', () => {
+  sdk.shutdown().catch((err) => console.error('Error shutting down OpenTelemetry', err));
+});
+
+require('@google-cloud/profiler')
+  .start()
+  .catch((err) => console.error('Error starting the Cloud Profiler agent', err));
+`
+)
+
+// otelPackages are the pinned packages required by otelBootstrapJS.
+var otelPackages = []string{
+	"@google-cloud/profiler@6.0.3",
+	"@opentelemetry/auto-instrumentations-node@0.56.1",
+	"@opentelemetry/sdk-node@0.57.2",
+}
+
+// InstallOTelBootstrap installs the OpenTelemetry and Cloud Profiler bootstrap in the given layer,
+// if it is not already cached, and loads it before the application by adding --require to
+// NODE_OPTIONS in the launch environment. Any NODE_OPTIONS set by the user are preserved. The
+// bootstrap is installed outside of the application so that its dependencies never conflict with
+// the user's. Yarn Plug'n'Play projects are rejected because the PnP runtime does not allow
+// modules outside of the dependency tree to be resolved.
+func InstallOTelBootstrap(ctx *gcp.Context, l *libcnb.Layer, yarnPnP bool) error {
+	if yarnPnP {
+		return gcp.UserErrorf("%s is not supported for projects using Yarn Plug'n'Play: the instrumentation bootstrap is installed outside of the project and cannot be resolved by the PnP runtime. Set nodeLinker to \"node-modules\" in .yarnrc.yml or unset %s.", OTelEnv, OTelEnv)
+	}
+	version := otelVersion()
+	bootstrap := filepath.Join(l.Path, OTelBootstrap)
+	if ctx.GetMetadata(l, versionKey) == version {
+		ctx.CacheHit(l.Name)
+		ctx.Logf("OpenTelemetry bootstrap cache hit, skipping installation.")
+	} else {
+		ctx.CacheMiss(l.Name)
+		if err := ctx.ClearLayer(l); err != nil {
+			return fmt.Errorf("clearing layer %q: %w", l.Name, err)
+		}
+		ctx.Logf("Installing the OpenTelemetry bootstrap.")
+		cmd := append([]string{"npm", "install", "--prefix", l.Path, "--no-save", "--no-package-lock", "--no-fund", "--no-audit", "--quiet"}, otelPackages...)
+		if _, err := ctx.Exec(cmd, gcp.WithUserAttribution); err != nil {
+			return err
+		}
+		if err := ctx.WriteFile(bootstrap, []byte(otelBootstrapJS), 0644); err != nil {
+			return err
+		}
+		ctx.SetMetadata(l, versionKey, version)
+	}
+	l.LaunchEnvironment.Prepend("NODE_OPTIONS", " ", "--require "+bootstrap)
+	return nil
+}
+
+// otelVersion returns the cache key of the bootstrap: its version and those of its dependencies.
+func otelVersion() string {
+	return otelBootstrapVersion + " " + strings.Join(otelPackages, " ")
+}
@@ -0,0 +1,106 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+func TestInstallOTelBootstrap(t *testing.T) {
+	testCases := []struct {
+		name        string
+		cached      string
+		yarnPnP     bool
+		wantInstall bool
+		wantErr     bool
+	}{
+		{
+			name:        "fresh install",
+			wantInstall: true,
+		},
+		{
+			name:   "cache hit",
+			cached: otelVersion(),
+		},
+		{
+			name:        "cached version outdated",
+			cached:      "0 @opentelemetry/sdk-node@0.0.1",
+			wantInstall: true,
+		},
+		{
+			name:    "yarn pnp",
+			yarnPnP: true,
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			l := &libcnb.Layer{
+				Name:              OTelLayer,
+				Path:              t.TempDir(),
+				Metadata:          map[string]interface{}{},
+				LaunchEnvironment: libcnb.Environment{},
+			}
+			if tc.cached != "" {
+				l.Metadata[versionKey] = tc.cached
+			}
+			var commands []string
+			execCmd := func(name string, args ...string) *exec.Cmd {
+				commands = append(commands, strings.Join(append([]string{name}, args...), " "))
+				return exec.Command("true")
+			}
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(t.TempDir()), gcp.WithExecCmd(execCmd))
+
+			err := InstallOTelBootstrap(ctx, l, tc.yarnPnP)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("InstallOTelBootstrap() got nil error, want error")
+				}
+				if len(commands) > 0 {
+					t.Errorf("InstallOTelBootstrap() ran %q, want no commands", commands)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("InstallOTelBootstrap() got error: %v", err)
+			}
+			if gotInstall := len(commands) > 0; gotInstall != tc.wantInstall {
+				t.Errorf("InstallOTelBootstrap() ran %q, want install: %t", commands, tc.wantInstall)
+			}
+			if got := ctx.GetMetadata(l, versionKey); got != otelVersion() {
+				t.Errorf("InstallOTelBootstrap() set %s metadata to %q, want %q", versionKey, got, otelVersion())
+			}
+			bootstrap := filepath.Join(l.Path, OTelBootstrap)
+			if tc.wantInstall {
+				if _, err := os.Stat(bootstrap); err != nil {
+					t.Errorf("InstallOTelBootstrap() did not write %s: %v", bootstrap, err)
+				}
+			}
+			if got, want := l.LaunchEnvironment["NODE_OPTIONS.prepend"], "--require "+bootstrap; got != want {
+				t.Errorf("InstallOTelBootstrap() NODE_OPTIONS.prepend = %q, want %q", got, want)
+			}
+			if got := l.LaunchEnvironment["NODE_OPTIONS.delim"]; got != " " {
+				t.Errorf("InstallOTelBootstrap() NODE_OPTIONS.delim = %q, want %q", got, " ")
+			}
+		})
+	}
+}
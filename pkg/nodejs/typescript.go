@@ -0,0 +1,68 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+// TSConfig is the name of the TypeScript compiler configuration file.
+const TSConfig = "tsconfig.json"
+
+// IsTypeScript returns true if dir contains a TypeScript project: a tsconfig.json file and at least
+// one .ts source file outside of node_modules. Declaration files (.d.ts) are not sources.
+func IsTypeScript(ctx *gcp.Context, dir string) (bool, error) {
+	tsconfigExists, err := ctx.FileExists(dir, TSConfig)
+	if err != nil || !tsconfigExists {
+		return false, err
+	}
+	found := false
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && (d.Name() == "node_modules" || strings.HasPrefix(d.Name(), ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasSuffix(path, ".ts") && !strings.HasSuffix(path, ".d.ts") {
+			found = true
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	if err != nil {
+		return false, gcp.InternalErrorf("searching %s for TypeScript files: %w", dir, err)
+	}
+	return found, nil
+}
+
+// TypeScriptBuildScript returns the package.json script that compiles a TypeScript project, with
+// the precedence used by DetermineBuildCommands: "gcp-build", then "build". It returns an empty
+// string if neither is defined with a command.
+func TypeScriptBuildScript(pjs *PackageJSON) string {
+	for _, script := range []string{ScriptGCPBuild, ScriptBuild} {
+		if HasScript(pjs, script) && strings.TrimSpace(pjs.Scripts[script]) != "" {
+			return script
+		}
+	}
+	return ""
+}
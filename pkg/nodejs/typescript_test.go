@@ -0,0 +1,106 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+func TestIsTypeScript(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files []string
+		want  bool
+	}{
+		{
+			name:  "tsconfig and source",
+			files: []string{"tsconfig.json", "src/index.ts"},
+			want:  true,
+		},
+		{
+			name:  "no tsconfig",
+			files: []string{"index.ts"},
+		},
+		{
+			name:  "only declaration files",
+			files: []string{"tsconfig.json", "index.js", "types/index.d.ts"},
+		},
+		{
+			name:  "only dependency sources",
+			files: []string{"tsconfig.json", "index.js", "node_modules/dep/index.ts"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, f := range tc.files {
+				path := filepath.Join(dir, f)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatalf("creating directory: %v", err)
+				}
+				if err := os.WriteFile(path, nil, 0644); err != nil {
+					t.Fatalf("writing %s: %v", f, err)
+				}
+			}
+
+			got, err := IsTypeScript(gcp.NewContext(), dir)
+			if err != nil {
+				t.Fatalf("IsTypeScript() got error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("IsTypeScript() = %t, want %t", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestTypeScriptBuildScript(t *testing.T) {
+	testCases := []struct {
+		name    string
+		scripts map[string]string
+		want    string
+	}{
+		{
+			name:    "build",
+			scripts: map[string]string{"build": "tsc", "start": "functions-framework"},
+			want:    "build",
+		},
+		{
+			name:    "gcp-build takes precedence",
+			scripts: map[string]string{"build": "tsc", "gcp-build": "npm run compile"},
+			want:    "gcp-build",
+		},
+		{
+			name:    "empty gcp-build",
+			scripts: map[string]string{"build": "tsc", "gcp-build": ""},
+			want:    "build",
+		},
+		{
+			name:    "no build script",
+			scripts: map[string]string{"start": "functions-framework"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := TypeScriptBuildScript(&PackageJSON{Scripts: tc.scripts}); got != tc.want {
+				t.Errorf("TypeScriptBuildScript() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
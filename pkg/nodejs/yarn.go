@@ -38,6 +38,10 @@ var (
 const (
 	// YarnLock is the name of the yarn lock file.
 	YarnLock = "yarn.lock"
+	// YarnAutoImportPluginsEnv is an env var that allows the buildpack to import the Yarn plugins it
+	// needs, such as workspace-tools to prune devDependencies, when the project does not include them.
+	// It is opt-in because importing a plugin may download it from the network.
+	YarnAutoImportPluginsEnv = "GOOGLE_YARN_AUTO_IMPORT_PLUGINS"
 )
 
 type yarn2Lock struct {
@@ -79,6 +83,16 @@ func HasYarnWorkspacePlugin(ctx *gcp.Context) (bool, error) {
 	return strings.Contains(res.Stdout, "plugin-workspace-tools"), nil
 }
 
+// IsYarnPnP returns true if Yarn 2+ is configured to use Plug'n'Play module resolution instead of
+// creating a "node_modules" directory.
+func IsYarnPnP(ctx *gcp.Context) (bool, error) {
+	result, err := ctx.Exec([]string{"yarn", "config", "get", "nodeLinker"}, gcp.WithUserAttribution)
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(result.Stdout) == "pnp", nil
+}
+
 // detectYarnVersion determines the version of Yarn that should be installed in a Node.js project
 // by examining the "engines.yarn" and "packageManager" constraints specified in package.json and comparing it against all
 // published versions in the NPM registry, if both exist "engines.yarn" will take precedence.
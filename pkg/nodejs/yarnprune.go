@@ -0,0 +1,417 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// yarnIntegrity is the file in which Yarn 1 records the state of node_modules to skip installs
+	// that would not change it.
+	yarnIntegrity = ".yarn-integrity"
+
+	// verifyModulesScript requires each package given as argument and prints the ones that fail
+	// because they or their dependencies are missing, or because of a broken native build, as a JSON
+	// object of error messages. Other errors, such as for packages without an entry point or ES
+	// modules, are not caused by pruning and are ignored. It exits explicitly in case a package keeps the event loop alive.
+	verifyModulesScript = `
+const fs = require('fs');
+const path = require('path');
+const failures = {};
+for (const name of process.argv.slice(1)) {
+  try {
+    require(name);
+  } catch (e) {
+    const message = String((e && e.message) || e).split('\n')[0];
+    const missing = /^Cannot find module '([^']+)'/.exec(message);
+    if (e.code === 'MODULE_NOT_FOUND' && missing) {
+      // A package without an entry point cannot be required, but it must still be installed.
+      if (missing[1] !== name || !fs.existsSync(path.join('node_modules', name, 'package.json'))) {
+        failures[name] = message;
+      }
+    } else if (e.code === 'ERR_DLOPEN_FAILED' || /bindings file|NODE_MODULE_VERSION|invalid ELF header/.test(message)) {
+      failures[name] = message;
+    }
+  }
+}
+console.log(JSON.stringify(failures));
+process.exit(0);
+`
+)
+
+// yarn1LockEntry is a package resolved in a Yarn 1 lockfile.
+type yarn1LockEntry struct {
+	version string
+	// dependencies maps the names of the dependencies and optional dependencies of the package to
+	// their version ranges.
+	dependencies map[string]string
+}
+
+// yarn1Package is a package installed in node_modules, identified by the name it is installed
+// under, which differs from the name of the package for aliases, and its version.
+type yarn1Package struct {
+	name    string
+	version string
+}
+
+// PruneYarn1DevDependencies removes the packages that only devDependencies depend on from the
+// node_modules directory installed by Yarn 1, without reinstalling the production dependencies
+// from the network. The production dependencies are then required to verify the result, and the
+// native ones that fail to load are rebuilt. It returns false if the packages must be pruned with
+// `yarn install --production` instead: for Yarn workspaces, if the lockfile cannot be read or is
+// out of date, or if dependencies still fail to load. yarnLock is the path of the lockfile, which
+// may be in a parent directory of the application root.
+func PruneYarn1DevDependencies(ctx *gcp.Context, pjs *PackageJSON, yarnLock, nodeModules string) (bool, error) {
+	if pjs == nil {
+		return false, nil
+	}
+	if len(pjs.Workspaces) > 0 {
+		ctx.Debugf("Not pruning node_modules directly because the project uses Yarn workspaces.")
+		return false, nil
+	}
+	content, err := os.ReadFile(yarnLock)
+	if err != nil {
+		ctx.Warnf("Failed to read %s: %v", yarnLock, err)
+		return false, nil
+	}
+	entries, err := parseYarn1Lock(string(content))
+	if err != nil {
+		ctx.Warnf("Failed to parse %s: %v", yarnLock, err)
+		return false, nil
+	}
+	devOnly, err := yarn1DevOnlyPackages(entries, pjs)
+	if err != nil {
+		ctx.Debugf("Not pruning node_modules directly: %v", err)
+		return false, nil
+	}
+	removed, err := removePackages(ctx, nodeModules, devOnly)
+	if err != nil {
+		return false, err
+	}
+	// Yarn 1 would otherwise consider node_modules up to date, and not reinstall the
+	// devDependencies when the layer is reused by the next build.
+	if err := ctx.RemoveAll(filepath.Join(nodeModules, yarnIntegrity)); err != nil {
+		return false, err
+	}
+	ctx.Logf("Removed %d packages only used by devDependencies from node_modules.", removed)
+
+	if len(pjs.Dependencies) == 0 {
+		return true, nil
+	}
+	failures, err := verifyModules(ctx, pjs)
+	if err != nil {
+		return false, err
+	}
+	if len(failures) == 0 {
+		return true, nil
+	}
+	var native []string
+	for name := range failures {
+		isNative, err := ctx.FileExists(nodeModules, name, "binding.gyp")
+		if err != nil {
+			return false, err
+		}
+		if isNative {
+			native = append(native, name)
+		}
+	}
+	if len(native) > 0 {
+		sort.Strings(native)
+		ctx.Logf("Rebuilding native dependencies that failed to load after pruning: %s", strings.Join(native, ", "))
+		if _, err := ctx.Exec(append([]string{"npm", "rebuild"}, native...), gcp.WithUserAttribution); err != nil {
+			return false, err
+		}
+		if failures, err = verifyModules(ctx, pjs); err != nil {
+			return false, err
+		}
+	}
+	if len(failures) > 0 {
+		for _, name := range sortedKeys(failures) {
+			ctx.Warnf("Dependency %s failed to load after pruning node_modules: %s", name, failures[name])
+		}
+		return false, nil
+	}
+	return true, nil
+}
+
+// verifyModules requires each production dependency of the application and returns the error
+// messages of the ones that fail to load because of pruning.
+func verifyModules(ctx *gcp.Context, pjs *PackageJSON) (map[string]string, error) {
+	cmd := append([]string{"node", "-e", verifyModulesScript}, sortedKeys(pjs.Dependencies)...)
+	result, err := ctx.Exec(cmd, gcp.WithUserAttribution, gcp.WithLogOutput(false))
+	if err != nil {
+		return nil, err
+	}
+	// Packages may print when they are loaded, the result is the last line.
+	lines := strings.Split(strings.TrimSpace(result.Stdout), "\n")
+	failures := map[string]string{}
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &failures); err != nil {
+		return nil, gcp.InternalErrorf("parsing the dependencies that failed to load: %v", err)
+	}
+	return failures, nil
+}
+
+// yarn1DevOnlyPackages returns the packages that are depended on by the devDependencies of the
+// application but not by its dependencies or optionalDependencies.
+func yarn1DevOnlyPackages(entries map[string]*yarn1LockEntry, pjs *PackageJSON) (map[yarn1Package]bool, error) {
+	prod := map[yarn1Package]bool{}
+	for _, deps := range []map[string]string{pjs.Dependencies, pjs.OptionalDependencies} {
+		if err := addYarn1Packages(entries, deps, prod); err != nil {
+			return nil, err
+		}
+	}
+	dev := map[yarn1Package]bool{}
+	if err := addYarn1Packages(entries, pjs.DevDependencies, dev); err != nil {
+		return nil, err
+	}
+	for p := range dev {
+		if prod[p] {
+			delete(dev, p)
+		}
+	}
+	return dev, nil
+}
+
+// addYarn1Packages adds the given dependencies and the packages they depend on to pkgs. Yarn 1
+// locks optional dependencies even if they do not support the platform, so a missing one means
+// that the lockfile is out of date.
+func addYarn1Packages(entries map[string]*yarn1LockEntry, deps map[string]string, pkgs map[yarn1Package]bool) error {
+	for name, rng := range deps {
+		entry, ok := entries[name+"@"+rng]
+		if !ok {
+			return fmt.Errorf("%s@%s is not in %s", name, rng, YarnLock)
+		}
+		p := yarn1Package{name: name, version: entry.version}
+		if pkgs[p] {
+			continue
+		}
+		pkgs[p] = true
+		if err := addYarn1Packages(entries, entry.dependencies, pkgs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removePackages removes the given packages from the node_modules directory and from the
+// node_modules directories nested in the packages it keeps, along with the executables they link
+// in .bin directories. Symlinked packages, such as the ones added by `yarn link`, are kept. It
+// returns the number of packages removed.
+func removePackages(ctx *gcp.Context, nodeModules string, pkgs map[yarn1Package]bool) (int, error) {
+	var dirs []string
+	entries, err := os.ReadDir(nodeModules)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, gcp.InternalErrorf("reading %s: %v", nodeModules, err)
+	}
+	for _, e := range entries {
+		if !e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		if !strings.HasPrefix(e.Name(), "@") {
+			dirs = append(dirs, e.Name())
+			continue
+		}
+		scoped, err := os.ReadDir(filepath.Join(nodeModules, e.Name()))
+		if err != nil {
+			return 0, gcp.InternalErrorf("reading %s: %v", e.Name(), err)
+		}
+		for _, s := range scoped {
+			if s.IsDir() {
+				dirs = append(dirs, e.Name()+"/"+s.Name())
+			}
+		}
+	}
+
+	removed := 0
+	for _, name := range dirs {
+		dir := filepath.Join(nodeModules, name)
+		version, err := installedVersion(dir)
+		if err != nil {
+			return 0, err
+		}
+		if pkgs[yarn1Package{name: name, version: version}] {
+			if err := ctx.RemoveAll(dir); err != nil {
+				return 0, err
+			}
+			removed++
+			continue
+		}
+		n, err := removePackages(ctx, filepath.Join(dir, "node_modules"), pkgs)
+		if err != nil {
+			return 0, err
+		}
+		removed += n
+	}
+	for _, e := range entries {
+		if !e.IsDir() || !strings.HasPrefix(e.Name(), "@") {
+			continue
+		}
+		scope := filepath.Join(nodeModules, e.Name())
+		if scoped, err := os.ReadDir(scope); err == nil && len(scoped) == 0 {
+			if err := ctx.RemoveAll(scope); err != nil {
+				return 0, err
+			}
+		}
+	}
+	if err := removeBrokenLinks(ctx, filepath.Join(nodeModules, ".bin")); err != nil {
+		return 0, err
+	}
+	return removed, nil
+}
+
+// installedVersion returns the version in the package.json of the package installed in dir, or an
+// empty string if it cannot be read.
+func installedVersion(dir string) (string, error) {
+	content, err := os.ReadFile(filepath.Join(dir, "package.json"))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", gcp.InternalErrorf("reading %s: %v", dir, err)
+	}
+	var pjs struct {
+		Version string `json:"version"`
+	}
+	if err := json.Unmarshal(content, &pjs); err != nil {
+		return "", nil
+	}
+	return pjs.Version, nil
+}
+
+// removeBrokenLinks removes the symlinks in dir whose target no longer exists.
+func removeBrokenLinks(ctx *gcp.Context, dir string) error {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return gcp.InternalErrorf("reading %s: %v", dir, err)
+	}
+	for _, e := range entries {
+		path := filepath.Join(dir, e.Name())
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			if err := ctx.RemoveAll(path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// parseYarn1Lock parses a Yarn 1 lockfile and returns its entries by the "name@range" patterns
+// that resolve to them.
+func parseYarn1Lock(content string) (map[string]*yarn1LockEntry, error) {
+	entries := map[string]*yarn1LockEntry{}
+	var entry *yarn1LockEntry
+	inDependencies := false
+	for i, line := range strings.Split(content, "\n") {
+		line = strings.TrimRight(line, "\r")
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		switch {
+		case indent == 0:
+			if !strings.HasSuffix(trimmed, ":") {
+				return nil, fmt.Errorf("line %d: expected a list of package patterns ending with a colon", i+1)
+			}
+			entry = &yarn1LockEntry{dependencies: map[string]string{}}
+			for _, pattern := range strings.Split(strings.TrimSuffix(trimmed, ":"), ",") {
+				p, err := unquoteYarn1(strings.TrimSpace(pattern))
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %v", i+1, err)
+				}
+				entries[p] = entry
+			}
+		case entry == nil:
+			return nil, fmt.Errorf("line %d: unexpected indentation", i+1)
+		case indent <= 2:
+			inDependencies = trimmed == "dependencies:" || trimmed == "optionalDependencies:"
+			key, value, err := splitYarn1Field(trimmed)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", i+1, err)
+			}
+			if key == "version" {
+				entry.version = value
+			}
+		case inDependencies:
+			name, rng, err := splitYarn1Field(trimmed)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", i+1, err)
+			}
+			entry.dependencies[name] = rng
+		}
+	}
+	return entries, nil
+}
+
+// splitYarn1Field splits a field of a Yarn 1 lockfile into its key and value, which may be quoted.
+// Keys of sections, such as "dependencies:", have an empty value.
+func splitYarn1Field(field string) (string, string, error) {
+	if strings.HasSuffix(field, ":") {
+		return strings.TrimSuffix(field, ":"), "", nil
+	}
+	var key string
+	if strings.HasPrefix(field, `"`) {
+		quoted, err := strconv.QuotedPrefix(field)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid quoted string in %q", field)
+		}
+		key, _ = strconv.Unquote(quoted)
+		field = field[len(quoted):]
+	} else {
+		i := strings.Index(field, " ")
+		if i < 0 {
+			return "", "", fmt.Errorf("missing value in %q", field)
+		}
+		key, field = field[:i], field[i:]
+	}
+	value, err := unquoteYarn1(strings.TrimSpace(field))
+	return key, value, err
+}
+
+// unquoteYarn1 removes the quotes around a string of a Yarn 1 lockfile, if any.
+func unquoteYarn1(s string) (string, error) {
+	if !strings.HasPrefix(s, `"`) {
+		return s, nil
+	}
+	u, err := strconv.Unquote(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid quoted string %s", s)
+	}
+	return u, nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,345 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/google/go-cmp/cmp"
+)
+
+// yarn1Lockfile locks express as a dependency and jest as a devDependency. Both depend on
+// debug@^4.3.1, which must be kept, and jest also depends on a version of ms that is nested under
+// jest in node_modules since express hoists another one.
+const yarn1Lockfile = `# THIS IS AN AUTOGENERATED FILE. DO NOT EDIT THIS FILE DIRECTLY.
+# yarn lockfile v1
+
+
+"@jest/core@^29.0.0":
+  version "29.7.0"
+  resolved "https://registry.yarnpkg.com/@jest/core/-/core-29.7.0.tgz#b6cccc239f30ff36609658c5a5e2291757ce448f"
+  dependencies:
+    ms "^1.0.0"
+
+debug@^4.3.1, "debug@^4.3.4":
+  version "4.3.4"
+  resolved "https://registry.yarnpkg.com/debug/-/debug-4.3.4.tgz#1319f6579357f2338d3337d2cdd4914bb5dcc865"
+  dependencies:
+    ms "2.1.2"
+
+express@^4.18.2:
+  version "4.18.2"
+  resolved "https://registry.yarnpkg.com/express/-/express-4.18.2.tgz#3fabe08296e930c796c19e3c516979386ba9fd59"
+  dependencies:
+    debug "^4.3.1"
+  optionalDependencies:
+    fsevents "~2.3.2"
+
+fsevents@~2.3.2:
+  version "2.3.3"
+  resolved "https://registry.yarnpkg.com/fsevents/-/fsevents-2.3.3.tgz#cac6407785d03675a2a5e1a5305c697b347d90d6"
+
+jest@^29.7.0:
+  version "29.7.0"
+  resolved "https://registry.yarnpkg.com/jest/-/jest-29.7.0.tgz#994676fc24177f088f1c5e3737f5697204ff2613"
+  dependencies:
+    "@jest/core" "^29.0.0"
+    debug "^4.3.4"
+
+ms@2.1.2:
+  version "2.1.2"
+  resolved "https://registry.yarnpkg.com/ms/-/ms-2.1.2.tgz#d09d1f357b443f493382a8eb3ccd183872ae6009"
+
+ms@^1.0.0:
+  version "1.0.0"
+  resolved "https://registry.yarnpkg.com/ms/-/ms-1.0.0.tgz#59adcd22edc543f7b5381862d31387b1f4bc9473"
+`
+
+func yarn1TestPackageJSON() *PackageJSON {
+	return &PackageJSON{
+		Dependencies:    map[string]string{"express": "^4.18.2"},
+		DevDependencies: map[string]string{"jest": "^29.7.0"},
+	}
+}
+
+func TestParseYarn1Lock(t *testing.T) {
+	entries, err := parseYarn1Lock(yarn1Lockfile)
+	if err != nil {
+		t.Fatalf("parseYarn1Lock() got error: %v", err)
+	}
+	want := map[string]yarn1LockEntry{
+		"@jest/core@^29.0.0": {version: "29.7.0", dependencies: map[string]string{"ms": "^1.0.0"}},
+		"debug@^4.3.1":       {version: "4.3.4", dependencies: map[string]string{"ms": "2.1.2"}},
+		"debug@^4.3.4":       {version: "4.3.4", dependencies: map[string]string{"ms": "2.1.2"}},
+		"express@^4.18.2":    {version: "4.18.2", dependencies: map[string]string{"debug": "^4.3.1", "fsevents": "~2.3.2"}},
+		"fsevents@~2.3.2":    {version: "2.3.3", dependencies: map[string]string{}},
+		"jest@^29.7.0":       {version: "29.7.0", dependencies: map[string]string{"@jest/core": "^29.0.0", "debug": "^4.3.4"}},
+		"ms@2.1.2":           {version: "2.1.2", dependencies: map[string]string{}},
+		"ms@^1.0.0":          {version: "1.0.0", dependencies: map[string]string{}},
+	}
+	got := map[string]yarn1LockEntry{}
+	for k, v := range entries {
+		got[k] = *v
+	}
+	if diff := cmp.Diff(want, got, cmp.AllowUnexported(yarn1LockEntry{})); diff != "" {
+		t.Errorf("parseYarn1Lock() mismatch (-want +got):\n%s", diff)
+	}
+	if entries["debug@^4.3.1"] != entries["debug@^4.3.4"] {
+		t.Errorf("parseYarn1Lock() returned different entries for patterns of the same package")
+	}
+}
+
+func TestParseYarn1LockErrors(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+	}{
+		{
+			name:    "field before entry",
+			content: "  version \"1.0.0\"\n",
+		},
+		{
+			name:    "entry without colon",
+			content: "express@^4.18.2\n  version \"4.18.2\"\n",
+		},
+		{
+			name:    "unterminated quote",
+			content: "\"express@^4.18.2:\n  version \"4.18.2\"\n",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := parseYarn1Lock(tc.content); err == nil {
+				t.Errorf("parseYarn1Lock(%q) got nil error, want error", tc.content)
+			}
+		})
+	}
+}
+
+func TestYarn1DevOnlyPackages(t *testing.T) {
+	entries, err := parseYarn1Lock(yarn1Lockfile)
+	if err != nil {
+		t.Fatalf("parseYarn1Lock() got error: %v", err)
+	}
+	testCases := []struct {
+		name    string
+		pjs     *PackageJSON
+		want    map[yarn1Package]bool
+		wantErr bool
+	}{
+		{
+			name: "dev only",
+			pjs:  yarn1TestPackageJSON(),
+			want: map[yarn1Package]bool{
+				{name: "jest", version: "29.7.0"}:       true,
+				{name: "@jest/core", version: "29.7.0"}: true,
+				{name: "ms", version: "1.0.0"}:          true,
+			},
+		},
+		{
+			name: "also a dependency",
+			pjs: &PackageJSON{
+				Dependencies:    map[string]string{"express": "^4.18.2", "jest": "^29.7.0"},
+				DevDependencies: map[string]string{"jest": "^29.7.0"},
+			},
+			want: map[yarn1Package]bool{},
+		},
+		{
+			name: "optional dependency",
+			pjs: &PackageJSON{
+				OptionalDependencies: map[string]string{"jest": "^29.7.0"},
+				DevDependencies:      map[string]string{"jest": "^29.7.0"},
+			},
+			want: map[yarn1Package]bool{},
+		},
+		{
+			name: "out of date lockfile",
+			pjs: &PackageJSON{
+				Dependencies:    map[string]string{"express": "^5.0.0"},
+				DevDependencies: map[string]string{"jest": "^29.7.0"},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := yarn1DevOnlyPackages(entries, tc.pjs)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("yarn1DevOnlyPackages() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(yarn1Package{})); diff != "" {
+				t.Errorf("yarn1DevOnlyPackages() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestPruneYarn1DevDependencies(t *testing.T) {
+	testCases := []struct {
+		name string
+		pjs  *PackageJSON
+		// yarnLock is the path of the lockfile relative to the workspace root if it is not yarn.lock.
+		yarnLock string
+		// failures are the dependencies reported by the verification script.
+		failures    map[string]string
+		wantPruned  bool
+		wantRebuild bool
+		// wantKept and wantRemoved are paths relative to node_modules.
+		wantKept    []string
+		wantRemoved []string
+	}{
+		{
+			name:        "dev dependencies pruned",
+			pjs:         yarn1TestPackageJSON(),
+			wantPruned:  true,
+			wantKept:    []string{"express", "debug", "ms", ".bin/express"},
+			wantRemoved: []string{"jest", "@jest/core", "@jest", ".bin/jest", ".yarn-integrity"},
+		},
+		{
+			name: "workspaces",
+			pjs: &PackageJSON{
+				Dependencies:    map[string]string{"express": "^4.18.2"},
+				DevDependencies: map[string]string{"jest": "^29.7.0"},
+				Workspaces:      json.RawMessage(`["packages/*"]`),
+			},
+			wantKept: []string{"express", "jest", "@jest/core", ".yarn-integrity"},
+		},
+		{
+			name: "out of date lockfile",
+			pjs: &PackageJSON{
+				Dependencies:    map[string]string{"express": "^5.0.0"},
+				DevDependencies: map[string]string{"jest": "^29.7.0"},
+			},
+			wantKept: []string{"express", "jest", "@jest/core", ".yarn-integrity"},
+		},
+		{
+			name:     "missing lockfile",
+			pjs:      yarn1TestPackageJSON(),
+			yarnLock: "missing/" + YarnLock,
+			wantKept: []string{"express", "jest", "@jest/core", ".yarn-integrity"},
+		},
+		{
+			name:        "native dependency rebuilt",
+			pjs:         yarn1TestPackageJSON(),
+			failures:    map[string]string{"express": "Could not locate the bindings file."},
+			wantRebuild: true,
+			wantRemoved: []string{"jest"},
+		},
+		{
+			name:        "dependency fails to load",
+			pjs:         yarn1TestPackageJSON(),
+			failures:    map[string]string{"debug": "Cannot find module 'ms'"},
+			wantRemoved: []string{"jest"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			nodeModules := filepath.Join(dir, "node_modules")
+			writeTree(t, dir, map[string]string{
+				YarnLock:                                               yarn1Lockfile,
+				"node_modules/.yarn-integrity":                         "{}",
+				"node_modules/express/package.json":                    `{"name": "express", "version": "4.18.2"}`,
+				"node_modules/express/index.js":                        "",
+				"node_modules/debug/package.json":                      `{"name": "debug", "version": "4.3.4"}`,
+				"node_modules/ms/package.json":                         `{"name": "ms", "version": "2.1.2"}`,
+				"node_modules/jest/package.json":                       `{"name": "jest", "version": "29.7.0"}`,
+				"node_modules/jest/bin/jest.js":                        "",
+				"node_modules/@jest/core/package.json":                 `{"name": "@jest/core", "version": "29.7.0"}`,
+				"node_modules/@jest/core/node_modules/ms/package.json": `{"name": "ms", "version": "1.0.0"}`,
+			})
+			if tc.wantRebuild {
+				writeTree(t, dir, map[string]string{"node_modules/express/binding.gyp": "{}"})
+			}
+			for name, target := range map[string]string{"express": "../express/index.js", "jest": "../jest/bin/jest.js"} {
+				if err := os.MkdirAll(filepath.Join(nodeModules, ".bin"), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Symlink(target, filepath.Join(nodeModules, ".bin", name)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			failures, err := json.Marshal(tc.failures)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var commands []string
+			execCmd := func(name string, args ...string) *exec.Cmd {
+				commands = append(commands, name+" "+args[0])
+				if name == "node" {
+					return exec.Command("echo", "loading...\n"+string(failures))
+				}
+				return exec.Command("true")
+			}
+			// The application is in a subdirectory of the workspace, like in a monorepo, and the lockfile
+			// is found in the workspace root.
+			appRoot := filepath.Join(dir, "app")
+			if err := os.Mkdir(appRoot, 0755); err != nil {
+				t.Fatal(err)
+			}
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(appRoot), gcp.WithWorkspaceRoot(dir), gcp.WithExecCmd(execCmd))
+
+			yarnLock := filepath.Join(dir, YarnLock)
+			if tc.yarnLock != "" {
+				yarnLock = filepath.Join(dir, tc.yarnLock)
+			}
+
+			got, err := PruneYarn1DevDependencies(ctx, tc.pjs, yarnLock, nodeModules)
+			if err != nil {
+				t.Fatalf("PruneYarn1DevDependencies() got error: %v", err)
+			}
+			if got != tc.wantPruned {
+				t.Errorf("PruneYarn1DevDependencies() = %t, want %t", got, tc.wantPruned)
+			}
+			rebuilt := false
+			for _, c := range commands {
+				rebuilt = rebuilt || strings.HasPrefix(c, "npm rebuild")
+			}
+			if rebuilt != tc.wantRebuild {
+				t.Errorf("PruneYarn1DevDependencies() ran %q, want npm rebuild: %t", commands, tc.wantRebuild)
+			}
+			for _, p := range tc.wantKept {
+				if _, err := os.Stat(filepath.Join(nodeModules, p)); err != nil {
+					t.Errorf("PruneYarn1DevDependencies() removed %s: %v", p, err)
+				}
+			}
+			for _, p := range tc.wantRemoved {
+				if _, err := os.Lstat(filepath.Join(nodeModules, p)); !os.IsNotExist(err) {
+					t.Errorf("PruneYarn1DevDependencies() kept %s, want removed", p)
+				}
+			}
+		})
+	}
+}
+
+// writeTree writes the given files to dir, creating their parent directories.
+func writeTree(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+// YarnWorkspacesNoInstallEnv is an env var with a comma-separated list of the Yarn workspaces that
+// are not installed, e.g. the other apps of a monorepo in which only one app is deployed. Each
+// workspace is identified by its package name or by its directory relative to the application root.
+const YarnWorkspacesNoInstallEnv = "GOOGLE_YARN_WORKSPACES_NOINSTALL"
+
+// YarnWorkspace is a workspace declared in the workspaces field of package.json.
+type YarnWorkspace struct {
+	// Name is the package name of the workspace.
+	Name string
+	// Dir is the directory of the workspace relative to the application root.
+	Dir string
+}
+
+// YarnWorkspaces returns the workspaces matched by the workspaces field of package.json, sorted by
+// directory. Both the array form and the object form with a packages field are supported.
+func YarnWorkspaces(ctx *gcp.Context, pjs *PackageJSON) ([]YarnWorkspace, error) {
+	patterns, err := workspacePatterns(pjs)
+	if err != nil {
+		return nil, err
+	}
+	root := ctx.ApplicationRoot()
+	seen := map[string]bool{}
+	var workspaces []YarnWorkspace
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(filepath.Join(root, filepath.FromSlash(pattern)))
+		if err != nil {
+			return nil, gcp.UserErrorf("invalid workspace pattern %q in package.json: %v", pattern, err)
+		}
+		for _, dir := range matches {
+			rel, err := filepath.Rel(root, dir)
+			if err != nil {
+				return nil, err
+			}
+			rel = filepath.ToSlash(rel)
+			if seen[rel] {
+				continue
+			}
+			wpjs, err := ReadPackageJSONIfExists(dir)
+			if err != nil {
+				return nil, err
+			}
+			if wpjs == nil {
+				continue
+			}
+			seen[rel] = true
+			workspaces = append(workspaces, YarnWorkspace{Name: wpjs.Name, Dir: rel})
+		}
+	}
+	sort.Slice(workspaces, func(i, j int) bool { return workspaces[i].Dir < workspaces[j].Dir })
+	return workspaces, nil
+}
+
+// ExcludeYarnWorkspaces removes the workspaces listed in GOOGLE_YARN_WORKSPACES_NOINSTALL from the
+// workspaces field of package.json so that Yarn does not install them. Neither .yarnrc nor
+// .yarnrc.yml can disable a workspace, so package.json is rewritten for the duration of the
+// install. The returned function restores package.json and the lockfile, which Yarn may update
+// while the workspaces are excluded. It returns the excluded workspaces, which are empty if the env
+// var is not set.
+func ExcludeYarnWorkspaces(ctx *gcp.Context, pjs *PackageJSON, yarnLock string) ([]YarnWorkspace, func() error, error) {
+	noop := func() error { return nil }
+	requested := splitWorkspaceNames(os.Getenv(YarnWorkspacesNoInstallEnv))
+	if len(requested) == 0 {
+		return nil, noop, nil
+	}
+	if pjs == nil || len(pjs.Workspaces) == 0 {
+		return nil, nil, gcp.UserErrorf("%s is set but package.json does not declare workspaces", YarnWorkspacesNoInstallEnv)
+	}
+	workspaces, err := YarnWorkspaces(ctx, pjs)
+	if err != nil {
+		return nil, nil, err
+	}
+	excluded := map[string]bool{}
+	var skipped []YarnWorkspace
+	for _, name := range requested {
+		w, ok := findWorkspace(workspaces, name)
+		if !ok {
+			return nil, nil, gcp.UserErrorf("%s: %q is not one of the workspaces of package.json: %s", YarnWorkspacesNoInstallEnv, name, workspaceNames(workspaces))
+		}
+		if !excluded[w.Dir] {
+			excluded[w.Dir] = true
+			skipped = append(skipped, w)
+		}
+	}
+	var kept []string
+	for _, w := range workspaces {
+		if !excluded[w.Dir] {
+			kept = append(kept, w.Dir)
+		}
+	}
+	ctx.Warnf("Skipping the installation of the workspaces %s because %s is set.", workspaceNames(skipped), YarnWorkspacesNoInstallEnv)
+
+	pjsPath := filepath.Join(ctx.ApplicationRoot(), "package.json")
+	restore, err := backupFiles(ctx, pjsPath, yarnLock)
+	if err != nil {
+		return nil, nil, err
+	}
+	content, err := withWorkspaces(ctx, pjs.Workspaces, pjsPath, kept)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := ctx.WriteFile(pjsPath, content, 0644); err != nil {
+		return nil, nil, err
+	}
+	return skipped, restore, nil
+}
+
+// workspacePatterns returns the patterns of the workspaces field of package.json.
+func workspacePatterns(pjs *PackageJSON) ([]string, error) {
+	if pjs == nil || len(pjs.Workspaces) == 0 {
+		return nil, nil
+	}
+	var patterns []string
+	if err := json.Unmarshal(pjs.Workspaces, &patterns); err == nil {
+		return patterns, nil
+	}
+	var obj struct {
+		Packages []string `json:"packages"`
+	}
+	if err := json.Unmarshal(pjs.Workspaces, &obj); err != nil {
+		return nil, gcp.UserErrorf("parsing the workspaces of package.json: %v", err)
+	}
+	return obj.Packages, nil
+}
+
+// withWorkspaces returns the content of package.json with the workspaces replaced by the given
+// directories. The other fields of a workspaces object, e.g. nohoist, are preserved.
+func withWorkspaces(ctx *gcp.Context, workspaces json.RawMessage, pjsPath string, dirs []string) ([]byte, error) {
+	raw, err := ctx.ReadFile(pjsPath)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, gcp.UserErrorf("parsing %s: %v", pjsPath, err)
+	}
+	if dirs == nil {
+		dirs = []string{}
+	}
+	packages, err := json.Marshal(dirs)
+	if err != nil {
+		return nil, err
+	}
+	var obj map[string]json.RawMessage
+	if json.Unmarshal(workspaces, &obj) == nil {
+		obj["packages"] = packages
+		if packages, err = json.Marshal(obj); err != nil {
+			return nil, err
+		}
+	}
+	fields["workspaces"] = packages
+	return json.MarshalIndent(fields, "", "  ")
+}
+
+// backupFiles reads the given files and returns a function that writes them back.
+func backupFiles(ctx *gcp.Context, paths ...string) (func() error, error) {
+	contents := map[string][]byte{}
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		b, err := ctx.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		contents[path] = b
+	}
+	return func() error {
+		for path, b := range contents {
+			if err := ctx.WriteFile(path, b, 0644); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, nil
+}
+
+// findWorkspace returns the workspace with the given package name or directory.
+func findWorkspace(workspaces []YarnWorkspace, name string) (YarnWorkspace, bool) {
+	dir := filepath.ToSlash(filepath.Clean(name))
+	for _, w := range workspaces {
+		if (w.Name != "" && w.Name == name) || w.Dir == dir {
+			return w, true
+		}
+	}
+	return YarnWorkspace{}, false
+}
+
+// workspaceNames returns the names of the workspaces, or their directory if they have no name.
+func workspaceNames(workspaces []YarnWorkspace) string {
+	var names []string
+	for _, w := range workspaces {
+		if w.Name != "" {
+			names = append(names, w.Name)
+		} else {
+			names = append(names, w.Dir)
+		}
+	}
+	return fmt.Sprintf("[%s]", strings.Join(names, ", "))
+}
+
+// splitWorkspaceNames splits a comma-separated list, ignoring empty entries.
+func splitWorkspaceNames(s string) []string {
+	var names []string
+	for _, name := range strings.Split(s, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,206 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/google/go-cmp/cmp"
+)
+
+var monorepoWorkspaces = map[string]string{
+	"apps/web/package.json":      `{"name": "web"}`,
+	"apps/admin/package.json":    `{"name": "admin"}`,
+	"packages/ui/package.json":   `{"name": "@acme/ui"}`,
+	"packages/docs/package.json": `{}`,
+	"packages/empty/README.md":   ``,
+	"yarn.lock":                  "# yarn lockfile v1\n",
+}
+
+func TestYarnWorkspaces(t *testing.T) {
+	testCases := []struct {
+		name       string
+		workspaces string
+		want       []YarnWorkspace
+	}{
+		{
+			name:       "array",
+			workspaces: `["apps/*", "packages/ui"]`,
+			want: []YarnWorkspace{
+				{Name: "admin", Dir: "apps/admin"},
+				{Name: "web", Dir: "apps/web"},
+				{Name: "@acme/ui", Dir: "packages/ui"},
+			},
+		},
+		{
+			name:       "object",
+			workspaces: `{"packages": ["packages/*"], "nohoist": ["**/react"]}`,
+			want: []YarnWorkspace{
+				{Dir: "packages/docs"},
+				{Name: "@acme/ui", Dir: "packages/ui"},
+			},
+		},
+		{
+			name:       "duplicate patterns",
+			workspaces: `["apps/web", "apps/*"]`,
+			want: []YarnWorkspace{
+				{Name: "admin", Dir: "apps/admin"},
+				{Name: "web", Dir: "apps/web"},
+			},
+		},
+		{
+			name: "no workspaces",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTree(t, dir, monorepoWorkspaces)
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(dir))
+			pjs := &PackageJSON{Workspaces: json.RawMessage(tc.workspaces)}
+
+			got, err := YarnWorkspaces(ctx, pjs)
+			if err != nil {
+				t.Fatalf("YarnWorkspaces() got error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("YarnWorkspaces() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestExcludeYarnWorkspaces(t *testing.T) {
+	testCases := []struct {
+		name           string
+		packageJSON    string
+		noInstall      string
+		wantExcluded   []YarnWorkspace
+		wantWorkspaces string
+		wantErr        bool
+	}{
+		{
+			name:        "not set",
+			packageJSON: `{"workspaces": ["apps/*"]}`,
+		},
+		{
+			name:           "by name",
+			packageJSON:    `{"name": "root", "workspaces": ["apps/*", "packages/*"]}`,
+			noInstall:      "admin, @acme/ui",
+			wantExcluded:   []YarnWorkspace{{Name: "admin", Dir: "apps/admin"}, {Name: "@acme/ui", Dir: "packages/ui"}},
+			wantWorkspaces: `["apps/web","packages/docs"]`,
+		},
+		{
+			name:           "by directory",
+			packageJSON:    `{"workspaces": ["apps/*"]}`,
+			noInstall:      "./apps/admin,apps/admin",
+			wantExcluded:   []YarnWorkspace{{Name: "admin", Dir: "apps/admin"}},
+			wantWorkspaces: `["apps/web"]`,
+		},
+		{
+			name:           "object form keeps nohoist",
+			packageJSON:    `{"workspaces": {"packages": ["apps/*"], "nohoist": ["**/react"]}}`,
+			noInstall:      "web",
+			wantExcluded:   []YarnWorkspace{{Name: "web", Dir: "apps/web"}},
+			wantWorkspaces: `{"nohoist":["**/react"],"packages":["apps/admin"]}`,
+		},
+		{
+			name:        "unknown workspace",
+			packageJSON: `{"workspaces": ["apps/*"]}`,
+			noInstall:   "@acme/ui",
+			wantErr:     true,
+		},
+		{
+			name:        "no workspaces",
+			packageJSON: `{"name": "app"}`,
+			noInstall:   "web",
+			wantErr:     true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTree(t, dir, monorepoWorkspaces)
+			writeTree(t, dir, map[string]string{"package.json": tc.packageJSON})
+			if tc.noInstall != "" {
+				t.Setenv(YarnWorkspacesNoInstallEnv, tc.noInstall)
+			}
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(dir))
+			pjs, err := ReadPackageJSONIfExists(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			yarnLock := filepath.Join(dir, "yarn.lock")
+
+			excluded, restore, err := ExcludeYarnWorkspaces(ctx, pjs, yarnLock)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("ExcludeYarnWorkspaces() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+			if diff := cmp.Diff(tc.wantExcluded, excluded); diff != "" {
+				t.Errorf("ExcludeYarnWorkspaces() mismatch (-want +got):\n%s", diff)
+			}
+			got, err := ReadPackageJSONIfExists(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantWorkspaces := tc.wantWorkspaces
+			if wantWorkspaces == "" {
+				wantWorkspaces = string(pjs.Workspaces)
+			}
+			if diff := cmp.Diff(wantWorkspaces, string(compactJSON(t, got.Workspaces))); diff != "" {
+				t.Errorf("package.json workspaces mismatch (-want +got):\n%s", diff)
+			}
+
+			if err := os.WriteFile(yarnLock, []byte("updated"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := restore(); err != nil {
+				t.Fatalf("restore() got error: %v", err)
+			}
+			if tc.noInstall == "" {
+				return
+			}
+			for name, want := range map[string]string{"package.json": tc.packageJSON, "yarn.lock": monorepoWorkspaces["yarn.lock"]} {
+				b, err := os.ReadFile(filepath.Join(dir, name))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(b) != want {
+					t.Errorf("restore() %s = %q, want %q", name, b, want)
+				}
+			}
+		})
+	}
+}
+
+func compactJSON(t *testing.T, raw json.RawMessage) []byte {
+	t.Helper()
+	var v any
+	if err := json.Unmarshal(raw, &v); err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
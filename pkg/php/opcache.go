@@ -0,0 +1,88 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package php
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/devmode"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// OpcacheEnv is an environment variable to enable OPcache with settings for production, where
+	// the application files do not change after the build.
+	OpcacheEnv = "GOOGLE_PHP_OPCACHE"
+
+	// OpcachePreloadEnv is an environment variable to set the script, relative to the application
+	// root, that OPcache preloads when PHP starts. It requires OpcacheEnv.
+	// Example: `config/preload.php`.
+	OpcachePreloadEnv = "GOOGLE_PHP_OPCACHE_PRELOAD"
+
+	// OpcacheIniName is the name of the ini file with the OPcache settings.
+	OpcacheIniName = "opcache.ini"
+
+	// opcacheIni loads the OPcache extension, which the php.ini of the buildpack does not load, and
+	// holds the settings shared by production and dev mode.
+	opcacheIni = `zend_extension=opcache
+opcache.enable=1
+opcache.memory_consumption=128
+opcache.max_accelerated_files=10000
+`
+	// opcacheProdIni turns off timestamp validation since the application files are immutable in
+	// the image.
+	opcacheProdIni = "opcache.validate_timestamps=0\n"
+	// opcacheDevIni revalidates the scripts on every request so that edits synced in dev mode are
+	// picked up.
+	opcacheDevIni = "opcache.validate_timestamps=1\nopcache.revalidate_freq=0\n"
+)
+
+// OpcacheIni returns the content of the opcache.ini file configured by GOOGLE_PHP_OPCACHE and
+// GOOGLE_PHP_OPCACHE_PRELOAD, or an empty string if OPcache is not enabled.
+func OpcacheIni(ctx *gcp.Context) (string, error) {
+	enabled, err := env.IsPresentAndTrue(OpcacheEnv)
+	if err != nil {
+		return "", err
+	}
+	preload := os.Getenv(OpcachePreloadEnv)
+	if !enabled {
+		if preload != "" {
+			ctx.Warnf("Ignoring %s because OPcache is not enabled, set %s=true to enable it.", OpcachePreloadEnv, OpcacheEnv)
+		}
+		return "", nil
+	}
+	ini := opcacheIni + opcacheProdIni
+	if devmode.Enabled(ctx) {
+		ini = opcacheIni + opcacheDevIni
+	}
+	if preload != "" {
+		path := preload
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(ctx.ApplicationRoot(), path)
+		}
+		exists, err := ctx.FileExists(path)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return "", gcp.UserErrorf("the preload script %q set by %s does not exist", preload, OpcachePreloadEnv)
+		}
+		ini += fmt.Sprintf("opcache.preload=%s\n", path)
+	}
+	return ini, nil
+}
@@ -0,0 +1,130 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package php
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+func TestOpcacheIni(t *testing.T) {
+	testCases := []struct {
+		name    string
+		opcache string
+		devMode string
+		preload string
+		files   []string
+		// want is the expected ini, where APP_ROOT is replaced with the application root.
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "not set",
+		},
+		{
+			name:    "disabled",
+			opcache: "false",
+		},
+		{
+			name:    "disabled with preload",
+			opcache: "false",
+			preload: "preload.php",
+			files:   []string{"preload.php"},
+		},
+		{
+			name:    "enabled",
+			opcache: "true",
+			want: `zend_extension=opcache
+opcache.enable=1
+opcache.memory_consumption=128
+opcache.max_accelerated_files=10000
+opcache.validate_timestamps=0
+`,
+		},
+		{
+			name:    "enabled in dev mode",
+			opcache: "true",
+			devMode: "true",
+			want: `zend_extension=opcache
+opcache.enable=1
+opcache.memory_consumption=128
+opcache.max_accelerated_files=10000
+opcache.validate_timestamps=1
+opcache.revalidate_freq=0
+`,
+		},
+		{
+			name:    "enabled with preload",
+			opcache: "true",
+			preload: "config/preload.php",
+			files:   []string{"config/preload.php"},
+			want: `zend_extension=opcache
+opcache.enable=1
+opcache.memory_consumption=128
+opcache.max_accelerated_files=10000
+opcache.validate_timestamps=0
+opcache.preload=APP_ROOT/config/preload.php
+`,
+		},
+		{
+			name:    "missing preload script",
+			opcache: "true",
+			preload: "preload.php",
+			wantErr: true,
+		},
+		{
+			name:    "invalid value",
+			opcache: "yes please",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.opcache != "" {
+				t.Setenv(OpcacheEnv, tc.opcache)
+			}
+			if tc.devMode != "" {
+				t.Setenv(env.DevMode, tc.devMode)
+			}
+			if tc.preload != "" {
+				t.Setenv(OpcachePreloadEnv, tc.preload)
+			}
+			dir := t.TempDir()
+			for _, f := range tc.files {
+				path := filepath.Join(dir, f)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte("<?php\n"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := OpcacheIni(gcp.NewContext(gcp.WithApplicationRoot(dir)))
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("OpcacheIni() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			want := strings.ReplaceAll(tc.want, "APP_ROOT", dir)
+			if got != want {
+				t.Errorf("OpcacheIni() = %q, want %q", got, want)
+			}
+		})
+	}
+}
@@ -21,6 +21,8 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/appengine"
@@ -28,6 +30,8 @@ import (
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/runtime"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/toolversions"
+	"github.com/Masterminds/semver"
 	"github.com/buildpacks/libcnb"
 )
 
@@ -68,8 +72,7 @@ max_execution_time = 0
 ; Error handling and logging, based on php.ini-production. ;
 ;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;
 
-error_reporting = E_ALL & ~E_DEPRECATED & ~E_STRICT
-display_errors = Off
+; error_reporting and display_errors are appended by ErrorReportingIni.
 display_startup_errors = Off
 log_errors = On
 log_errors_max_len = 0
@@ -93,10 +96,47 @@ post_max_size = 32M
 
 	// NginxServesStaticFiles is an environment variable to configure Nginx to serve static files.
 	NginxServesStaticFiles = "NGINX_SERVES_STATIC_FILES"
+
+	// SecurityHeadersEnv is an environment variable to configure Nginx to add HTTP security headers,
+	// such as X-Content-Type-Options and X-Frame-Options, to its responses.
+	SecurityHeadersEnv = "GOOGLE_PHP_SECURITY_HEADERS"
+
+	// StubStatusEnv is an environment variable to expose the Nginx stub_status page on
+	// /_nginx/status, e.g. for readiness probes.
+	StubStatusEnv = "GOOGLE_PHP_STUB_STATUS"
+
+	// ComposerNoScriptsEnv is an environment variable to skip the scripts defined in composer.json
+	// when running `composer install`.
+	ComposerNoScriptsEnv = "GOOGLE_COMPOSER_NO_SCRIPTS"
+
+	// ComposerDevDependenciesEnv is an environment variable to install the require-dev packages of
+	// composer.json in the application image. They are not installed by default.
+	ComposerDevDependenciesEnv = "GOOGLE_COMPOSER_DEV_DEPENDENCIES"
 )
 
 type composerScriptsJSON struct {
-	GCPBuild string `json:"gcp-build"`
+	GCPBuild       string         `json:"gcp-build"`
+	PostInstallCmd composerScript `json:"post-install-cmd"`
+	PostUpdateCmd  composerScript `json:"post-update-cmd"`
+}
+
+// composerScript is a composer.json script, which may be declared as a single command or a list
+// of commands.
+type composerScript []string
+
+// UnmarshalJSON implements json.Unmarshaler. Values of any other type are ignored since they are
+// only used for reporting.
+func (s *composerScript) UnmarshalJSON(data []byte) error {
+	var cmd string
+	if err := json.Unmarshal(data, &cmd); err == nil {
+		*s = composerScript{cmd}
+		return nil
+	}
+	var cmds []string
+	if err := json.Unmarshal(data, &cmds); err == nil {
+		*s = cmds
+	}
+	return nil
 }
 
 // ComposerJSON represents the contents of a composer.json file.
@@ -105,6 +145,82 @@ type ComposerJSON struct {
 	Scripts composerScriptsJSON `json:"scripts"`
 }
 
+// errorLevels are the predefined constants that may be used in the error_reporting setting.
+var errorLevels = map[string]bool{
+	"E_ERROR":             true,
+	"E_WARNING":           true,
+	"E_PARSE":             true,
+	"E_NOTICE":            true,
+	"E_CORE_ERROR":        true,
+	"E_CORE_WARNING":      true,
+	"E_COMPILE_ERROR":     true,
+	"E_COMPILE_WARNING":   true,
+	"E_USER_ERROR":        true,
+	"E_USER_WARNING":      true,
+	"E_USER_NOTICE":       true,
+	"E_STRICT":            true,
+	"E_RECOVERABLE_ERROR": true,
+	"E_DEPRECATED":        true,
+	"E_USER_DEPRECATED":   true,
+	"E_ALL":               true,
+}
+
+// ErrorReportingIni returns the php.ini settings for the error_reporting level configured by
+// GOOGLE_PHP_ERROR_REPORTING, or E_ALL if it is not set. Errors are never displayed to end users.
+func ErrorReportingIni() (string, error) {
+	level := strings.TrimSpace(os.Getenv(env.PHPErrorReporting))
+	if level == "" {
+		level = "E_ALL"
+	} else if err := validateErrorReporting(level); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("error_reporting = %s\ndisplay_errors = Off\n", level), nil
+}
+
+// validateErrorReporting checks that level is a bitmask expression of error level constants, such as
+// `E_ALL & ~E_NOTICE`. Bare integers are rejected because their meaning changes between PHP
+// versions, and logical operators are rejected because they are a common mistake for bitwise ones.
+func validateErrorReporting(level string) error {
+	fix := fmt.Sprintf("set %s to a bitmask of error level constants, for example \"E_ALL & ~E_NOTICE\"", env.PHPErrorReporting)
+	if strings.Contains(level, "&&") || strings.Contains(level, "||") {
+		return gcp.UserErrorf("invalid %s %q: logical operators are not supported, use the bitwise operators & and | instead", env.PHPErrorReporting, level)
+	}
+	depth := 0
+	for i := 0; i < len(level); {
+		c := level[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '&' || c == '|' || c == '~' || c == '^':
+			i++
+		case c == '(':
+			depth++
+			i++
+		case c == ')':
+			depth--
+			if depth < 0 {
+				return gcp.UserErrorf("invalid %s %q: unbalanced parentheses", env.PHPErrorReporting, level)
+			}
+			i++
+		case c >= '0' && c <= '9':
+			return gcp.UserErrorf("invalid %s %q: integer error levels are not supported, %s", env.PHPErrorReporting, level, fix)
+		case c == '_' || (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z'):
+			j := i
+			for j < len(level) && (level[j] == '_' || (level[j] >= 'A' && level[j] <= 'Z') || (level[j] >= 'a' && level[j] <= 'z') || (level[j] >= '0' && level[j] <= '9')) {
+				j++
+			}
+			if name := level[i:j]; !errorLevels[name] {
+				return gcp.UserErrorf("invalid %s %q: unknown error level %q, %s", env.PHPErrorReporting, level, name, fix)
+			}
+			i = j
+		default:
+			return gcp.UserErrorf("invalid %s %q: unexpected character %q, %s", env.PHPErrorReporting, level, c, fix)
+		}
+	}
+	if depth != 0 {
+		return gcp.UserErrorf("invalid %s %q: unbalanced parentheses", env.PHPErrorReporting, level)
+	}
+	return nil
+}
+
 // SupportsAppEngineApis is a function that returns true if App Engine API access is enabled
 func SupportsAppEngineApis(ctx *gcp.Context) (bool, error) {
 	if os.Getenv(env.Runtime) == "php55" {
@@ -138,6 +254,75 @@ func version(ctx *gcp.Context) (string, error) {
 	return result.Stdout, nil
 }
 
+var (
+	// installedVersionRegexp matches the major, minor and patch numbers of a PHP version, without
+	// suffixes such as "RC1" or "-dev".
+	installedVersionRegexp = regexp.MustCompile(`^\d+\.\d+\.\d+`)
+	// constraintOrRegexp matches the separator of alternative Composer constraints, "||" or "|".
+	constraintOrRegexp = regexp.MustCompile(`\|\|?`)
+	// constraintStabilityRegexp matches a Composer stability flag, for example "@dev".
+	constraintStabilityRegexp = regexp.MustCompile(`@\w+`)
+	// constraintOperatorRegexp matches a comparison operator separated from its version by a space.
+	constraintOperatorRegexp = regexp.MustCompile(`^(>=|<=|!=|>|<|=|~|\^)$`)
+	// constraintTildeMinorRegexp matches a tilde constraint with exactly two segments, for example
+	// "~8.0", which Composer reads as ">=8.0,<9.0".
+	constraintTildeMinorRegexp = regexp.MustCompile(`^~(\d+)\.(\d+)$`)
+)
+
+// validatePHPVersionConstraint returns a user error if the installed PHP version does not satisfy
+// the php constraint in the require section of composer.json. Constraints and versions that cannot
+// be parsed are not validated, Composer reports invalid constraints itself.
+func validatePHPVersionConstraint(composerJSON *ComposerJSON, installedVersion string) error {
+	constraint := strings.TrimSpace(composerJSON.Require[composerVersionKey])
+	if constraint == "" {
+		return nil
+	}
+	c, err := semver.NewConstraint(semverConstraint(constraint))
+	if err != nil {
+		return nil
+	}
+	v, err := semver.NewVersion(installedVersionRegexp.FindString(strings.TrimSpace(installedVersion)))
+	if err != nil {
+		return nil
+	}
+	if !c.Check(v) {
+		return gcp.UserErrorf("the installed PHP version %s does not satisfy the constraint %q required by composer.json, set %s to a version that satisfies it or update the %q requirement in composer.json", installedVersion, constraint, env.RuntimeVersion, composerVersionKey)
+	}
+	return nil
+}
+
+// semverConstraint converts a Composer version constraint to the syntax of the semver package:
+// alternatives may be separated by a single pipe, conditions may be separated by spaces instead of
+// commas, operators may be followed by a space, and versions may have a stability flag. Tilde
+// constraints with two segments allow the next minor versions in Composer, but only the next
+// patch versions in the semver package, so they are rewritten as explicit ranges.
+func semverConstraint(constraint string) string {
+	var alternatives []string
+	for _, alt := range constraintOrRegexp.Split(constraintStabilityRegexp.ReplaceAllString(constraint, ""), -1) {
+		tokens := strings.Fields(strings.ReplaceAll(alt, ",", " "))
+		var conditions []string
+		for i := 0; i < len(tokens); i++ {
+			cond := tokens[i]
+			switch {
+			case constraintOperatorRegexp.MatchString(cond) && i+1 < len(tokens):
+				cond += tokens[i+1]
+				i++
+			case i+2 < len(tokens) && tokens[i+1] == "-":
+				// Hyphenated ranges, for example "7.4 - 8.1".
+				cond += " - " + tokens[i+2]
+				i += 2
+			}
+			if m := constraintTildeMinorRegexp.FindStringSubmatch(cond); m != nil {
+				major, _ := strconv.Atoi(m[1])
+				cond = fmt.Sprintf(">=%s.%s, <%d.0.0", m[1], m[2], major+1)
+			}
+			conditions = append(conditions, cond)
+		}
+		alternatives = append(alternatives, strings.Join(conditions, ", "))
+	}
+	return strings.Join(alternatives, " || ")
+}
+
 // composerInstall runs `composer install` with the given flags.
 func composerInstall(ctx *gcp.Context, flags []string) error {
 	cmd := append([]string{"composer", "install"}, flags...)
@@ -147,20 +332,62 @@ func composerInstall(ctx *gcp.Context, flags []string) error {
 	return nil
 }
 
-// ComposerInstall runs `composer install`, using the cache iff a lock file is present.
-// It creates a layer, so it returns the layer so that the caller may further modify it
-// if they desire.
-func ComposerInstall(ctx *gcp.Context, cacheTag string) (*libcnb.Layer, error) {
-	var flags []string
+// ComposerDevDependencies returns true if GOOGLE_COMPOSER_DEV_DEPENDENCIES opts in to installing
+// the require-dev packages in the application image.
+func ComposerDevDependencies() (bool, error) {
+	return env.IsPresentAndTrue(ComposerDevDependenciesEnv)
+}
+
+// composerFlags returns the flags of `composer install`: those set with GOOGLE_COMPOSER_ARGS, or
+// the defaults. --no-dev is added to them unless devDependencies is true, in which case it is
+// removed.
+func composerFlags(devDependencies bool) []string {
+	flags := []string{"--no-progress", "--no-interaction", "--optimize-autoloader"}
 	if composerArgs := os.Getenv(ComposerArgsEnv); composerArgs != "" {
 		flags = strings.Split(composerArgs, " ")
-	} else {
+	}
+	var result []string
+	if !devDependencies {
 		// We don't install dev dependencies (i.e. we pass --no-dev to composer) because doing so has caused
 		// problems for customers in the past. For more information see these links:
 		//   https://github.com/GoogleCloudPlatform/php-docs-samples/issues/736
 		//   https://github.com/GoogleCloudPlatform/runtimes-common/pull/763
 		//   https://github.com/GoogleCloudPlatform/runtimes-common/commit/6c4970f609d80f9436ac58ae272cfcc6bcd57143
-		flags = []string{"--no-dev", "--no-progress", "--no-interaction", "--optimize-autoloader"}
+		result = append(result, "--no-dev")
+	}
+	for _, f := range flags {
+		if f != "--no-dev" {
+			result = append(result, f)
+		}
+	}
+	return result
+}
+
+// ComposerInstall runs `composer install`, using the cache iff a lock file is present.
+// It creates a layer, so it returns the layer so that the caller may further modify it
+// if they desire. The require-dev packages are only installed if devDependencies is true.
+func ComposerInstall(ctx *gcp.Context, cacheTag string, devDependencies bool) (*libcnb.Layer, error) {
+	flags := composerFlags(devDependencies)
+
+	cjs, err := ReadComposerJSON(ctx.ApplicationRoot())
+	if err != nil {
+		return nil, err
+	}
+	currentPHPVersion, err := version(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := validatePHPVersionConstraint(cjs, currentPHPVersion); err != nil {
+		return nil, err
+	}
+
+	noScripts, err := env.IsPresentAndTrue(ComposerNoScriptsEnv)
+	if err != nil {
+		return nil, err
+	}
+	if noScripts {
+		flags = append(flags, "--no-scripts")
+		ctx.Warnf("%s", skippedScriptsWarning(cjs))
 	}
 
 	if err := ctx.RemoveAll(Vendor); err != nil {
@@ -187,11 +414,11 @@ func ComposerInstall(ctx *gcp.Context, cacheTag string) (*libcnb.Layer, error) {
 		return l, nil
 	}
 
-	currentPHPVersion, err := version(ctx)
-	if err != nil {
-		return nil, err
+	cacheOpts := []cache.Option{cache.WithFiles(composerJSON, composerLock), cache.WithStrings(currentPHPVersion)}
+	if devDependencies {
+		cacheOpts = append(cacheOpts, cache.WithNamedString(ComposerDevDependenciesEnv, "true"))
 	}
-	hash, cached, err := cache.HashAndCheck(ctx, l, dependencyHashKey, cache.WithFiles(composerJSON, composerLock), cache.WithStrings(currentPHPVersion))
+	hash, cached, err := cache.HashAndCheck(ctx, l, dependencyHashKey, cacheOpts...)
 	if err != nil {
 		return nil, err
 	}
@@ -226,6 +453,27 @@ func ComposerInstall(ctx *gcp.Context, cacheTag string) (*libcnb.Layer, error) {
 	return l, nil
 }
 
+// skippedScriptsWarning returns a message listing the composer.json lifecycle scripts that are
+// skipped because of GOOGLE_COMPOSER_NO_SCRIPTS.
+func skippedScriptsWarning(cjs *ComposerJSON) string {
+	var skipped []string
+	for _, s := range []struct {
+		event string
+		cmds  composerScript
+	}{
+		{"post-install-cmd", cjs.Scripts.PostInstallCmd},
+		{"post-update-cmd", cjs.Scripts.PostUpdateCmd},
+	} {
+		for _, cmd := range s.cmds {
+			skipped = append(skipped, fmt.Sprintf("%s: %s", s.event, cmd))
+		}
+	}
+	if len(skipped) == 0 {
+		return fmt.Sprintf("%s is set, composer scripts will not be run.", ComposerNoScriptsEnv)
+	}
+	return fmt.Sprintf("%s is set, skipping composer scripts:\n  %s", ComposerNoScriptsEnv, strings.Join(skipped, "\n  "))
+}
+
 // ComposerRequire runs `composer require` with the given packages. It expects packages to
 // be specified as `composer require` would expect them on the command line, for example
 // "myorg/mypackage:^0.7". It does no caching.
@@ -242,7 +490,7 @@ func GetInstallableRuntime(ctx *gcp.Context) runtime.InstallableRuntime {
 	return runtime.PHP
 }
 
-// ExtractVersion extracts the php version from the environment, composer.json.
+// ExtractVersion extracts the php version from the environment, composer.json or .tool-versions.
 func ExtractVersion(ctx *gcp.Context) (string, error) {
 	// get the runtime version from env.RuntimeVersion
 	if v := os.Getenv(env.RuntimeVersion); v != "" {
@@ -267,6 +515,16 @@ func ExtractVersion(ctx *gcp.Context) (string, error) {
 		}
 	}
 
+	// get the runtime version from the .tool-versions file
+	v, err := toolversions.Version(ctx, toolversions.PHP)
+	if err != nil {
+		return "", err
+	}
+	if v != "" {
+		ctx.Logf("Using php version from %s: %s", toolversions.File, v)
+		return v, nil
+	}
+
 	return "", nil
 }
 
@@ -67,6 +67,61 @@ func TestReadComposerJSON(t *testing.T) {
 	}
 }
 
+func TestSkippedScriptsWarning(t *testing.T) {
+	testCases := []struct {
+		name         string
+		composerJSON string
+		want         string
+	}{
+		{
+			name:         "no scripts",
+			composerJSON: `{"require": {"php": "8.3"}}`,
+			want:         "GOOGLE_COMPOSER_NO_SCRIPTS is set, composer scripts will not be run.",
+		},
+		{
+			name:         "string script",
+			composerJSON: `{"scripts": {"post-install-cmd": "php artisan migrate"}}`,
+			want:         "GOOGLE_COMPOSER_NO_SCRIPTS is set, skipping composer scripts:\n  post-install-cmd: php artisan migrate",
+		},
+		{
+			name: "list scripts",
+			composerJSON: `{
+				"scripts": {
+					"gcp-build": "npm run build",
+					"post-update-cmd": ["@php artisan package:discover"],
+					"post-install-cmd": ["@php artisan migrate", "@php artisan cache:clear"]
+				}
+			}`,
+			want: "GOOGLE_COMPOSER_NO_SCRIPTS is set, skipping composer scripts:\n" +
+				"  post-install-cmd: @php artisan migrate\n" +
+				"  post-install-cmd: @php artisan cache:clear\n" +
+				"  post-update-cmd: @php artisan package:discover",
+		},
+		{
+			name:         "unsupported script type ignored",
+			composerJSON: `{"scripts": {"post-install-cmd": {"not": "a script"}}}`,
+			want:         "GOOGLE_COMPOSER_NO_SCRIPTS is set, composer scripts will not be run.",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := t.TempDir()
+			if err := ioutil.WriteFile(filepath.Join(d, composerJSON), []byte(tc.composerJSON), 0644); err != nil {
+				t.Fatalf("Failed to write composer.json: %v", err)
+			}
+			cjs, err := ReadComposerJSON(d)
+			if err != nil {
+				t.Fatalf("ReadComposerJSON got error: %v", err)
+			}
+
+			if got := skippedScriptsWarning(cjs); got != tc.want {
+				t.Errorf("skippedScriptsWarning() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
 func TestExtractVersion(t *testing.T) {
 
 	testCases := []struct {
@@ -74,6 +129,7 @@ func TestExtractVersion(t *testing.T) {
 		runtimeEnv   string
 		want         string
 		composerJSON string
+		toolVersions string
 		wantErr      bool
 	}{
 		{
@@ -152,6 +208,23 @@ func TestExtractVersion(t *testing.T) {
 `),
 			want: ">= 7.1.3, < 7.4.4",
 		},
+		{
+			name:         "from .tool-versions",
+			toolVersions: "nodejs 20.11.0\nphp 8.2.15\nruby 3.3.0\n",
+			want:         "8.2.15",
+		},
+		{
+			name: "both composer.json and .tool-versions",
+			composerJSON: strings.TrimSpace(`
+{
+  "require": {
+    "php": "7.4.1"
+  }
+}
+`),
+			toolVersions: "php 8.2.15\n",
+			want:         "7.4.1",
+		},
 	}
 
 	for _, tc := range testCases {
@@ -172,6 +245,12 @@ func TestExtractVersion(t *testing.T) {
 				}
 			}
 
+			if len(tc.toolVersions) > 0 {
+				if err := ioutil.WriteFile(filepath.Join(path, ".tool-versions"), []byte(tc.toolVersions), 0644); err != nil {
+					t.Fatalf("Failed to write .tool-versions: %v", err)
+				}
+			}
+
 			ctx := gcp.NewContext(gcp.WithApplicationRoot(path))
 			got, err := ExtractVersion(ctx)
 			gotErr := err != nil
@@ -187,3 +266,161 @@ func TestExtractVersion(t *testing.T) {
 	}
 
 }
+
+func TestValidateErrorReporting(t *testing.T) {
+	testCases := []struct {
+		level   string
+		wantErr bool
+	}{
+		{level: "E_ALL"},
+		{level: "E_ALL & ~E_NOTICE"},
+		{level: "E_ALL & ~(E_DEPRECATED | E_STRICT)"},
+		{level: "E_ERROR|E_WARNING|E_PARSE"},
+		{level: "E_ALL ^ E_USER_DEPRECATED"},
+		{level: "32767", wantErr: true},
+		{level: "E_ALL & ~8", wantErr: true},
+		{level: "E_ALL && ~E_NOTICE", wantErr: true},
+		{level: "E_ALL || E_NOTICE", wantErr: true},
+		{level: "E_NOTICES", wantErr: true},
+		{level: "e_all", wantErr: true},
+		{level: `"E_ALL"`, wantErr: true},
+		{level: "(E_ALL & ~E_NOTICE", wantErr: true},
+		{level: "E_ALL) & (~E_NOTICE", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.level, func(t *testing.T) {
+			err := validateErrorReporting(tc.level)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("validateErrorReporting(%q) got error: %v, want error? %t", tc.level, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestErrorReportingIni(t *testing.T) {
+	testCases := []struct {
+		name    string
+		level   string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "default",
+			want: "error_reporting = E_ALL\ndisplay_errors = Off\n",
+		},
+		{
+			name:  "configured level",
+			level: "E_ALL & ~E_NOTICE",
+			want:  "error_reporting = E_ALL & ~E_NOTICE\ndisplay_errors = Off\n",
+		},
+		{
+			name:    "invalid level",
+			level:   "32767",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.level != "" {
+				t.Setenv(env.PHPErrorReporting, tc.level)
+			}
+
+			got, err := ErrorReportingIni()
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("ErrorReportingIni() got error: %v, want error? %t", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("ErrorReportingIni() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestValidatePHPVersionConstraint(t *testing.T) {
+	testCases := []struct {
+		name       string
+		constraint string
+		installed  string
+		wantErr    bool
+	}{
+		{name: "caret satisfied", constraint: "^8.1", installed: "8.3.4"},
+		{name: "caret not satisfied", constraint: "^8.1", installed: "7.4.33", wantErr: true},
+		{name: "caret below minor", constraint: "^8.2", installed: "8.1.27", wantErr: true},
+		{name: "tilde not satisfied", constraint: "~8.1.0", installed: "8.2.17", wantErr: true},
+		{name: "two segment tilde allows next minor", constraint: "~8.0", installed: "8.2.10"},
+		{name: "two segment tilde not satisfied", constraint: "~8.0", installed: "9.0.0", wantErr: true},
+		{name: "two segment tilde below minimum", constraint: "~8.1", installed: "8.0.30", wantErr: true},
+		{name: "three segment tilde satisfied", constraint: "~8.0.1", installed: "8.0.30"},
+		{name: "three segment tilde not satisfied", constraint: "~8.0.1", installed: "8.1.0", wantErr: true},
+		{name: "wildcard satisfied", constraint: "8.2.*", installed: "8.2.17"},
+		{name: "comma separated range", constraint: ">= 7.1.3, < 7.4.4", installed: "7.4.4", wantErr: true},
+		{name: "space separated range", constraint: ">=8.0 <8.3", installed: "8.2.17"},
+		{name: "space separated range not satisfied", constraint: ">=8.0 <8.3", installed: "8.3.4", wantErr: true},
+		{name: "single pipe alternatives", constraint: "^7.4 | ^8.0", installed: "8.3.4"},
+		{name: "double pipe alternatives", constraint: "^7.4 || ^8.2", installed: "8.1.27", wantErr: true},
+		{name: "hyphen range", constraint: "8.0 - 8.2", installed: "8.1.27"},
+		{name: "stability flag", constraint: "^8.1@dev", installed: "8.3.4"},
+		{name: "installed version suffix", constraint: "^8.4", installed: "8.4.0RC1"},
+		{name: "no constraint", installed: "8.3.4"},
+		{name: "invalid constraint", constraint: "not a version", installed: "8.3.4"},
+		{name: "invalid installed version", constraint: "^8.1", installed: "unknown"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cjs := &ComposerJSON{Require: map[string]string{}}
+			if tc.constraint != "" {
+				cjs.Require[composerVersionKey] = tc.constraint
+			}
+
+			err := validatePHPVersionConstraint(cjs, tc.installed)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("validatePHPVersionConstraint(%q, %q) got error: %v, want error: %t", tc.constraint, tc.installed, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestComposerFlags(t *testing.T) {
+	testCases := []struct {
+		name            string
+		composerArgs    string
+		devDependencies bool
+		want            []string
+	}{
+		{
+			name: "default",
+			want: []string{"--no-dev", "--no-progress", "--no-interaction", "--optimize-autoloader"},
+		},
+		{
+			name:            "default with dev dependencies",
+			devDependencies: true,
+			want:            []string{"--no-progress", "--no-interaction", "--optimize-autoloader"},
+		},
+		{
+			name:         "custom args without --no-dev",
+			composerArgs: "--prefer-dist --no-interaction",
+			want:         []string{"--no-dev", "--prefer-dist", "--no-interaction"},
+		},
+		{
+			name:         "custom args with --no-dev",
+			composerArgs: "--no-interaction --no-dev",
+			want:         []string{"--no-dev", "--no-interaction"},
+		},
+		{
+			name:            "custom args with dev dependencies",
+			composerArgs:    "--no-dev --prefer-dist",
+			devDependencies: true,
+			want:            []string{"--prefer-dist"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(ComposerArgsEnv, tc.composerArgs)
+
+			got := composerFlags(tc.devDependencies)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("composerFlags(%v) = %v, want %v", tc.devDependencies, got, tc.want)
+			}
+		})
+	}
+}
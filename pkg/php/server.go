@@ -0,0 +1,108 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package php
+
+import (
+	"os"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// ServerEnv is an environment variable that selects the server that runs the application:
+	// `fpm` runs php-fpm behind nginx, `octane` runs Laravel Octane with Swoole and `frankenphp`
+	// runs the FrankenPHP server. Applications that require laravel/octane use Octane by default
+	// when the Swoole extension is available, all others use php-fpm.
+	ServerEnv = "GOOGLE_PHP_SERVER"
+
+	// ServerFPM runs php-fpm behind nginx.
+	ServerFPM = "fpm"
+	// ServerOctane runs the Laravel Octane server with Swoole.
+	ServerOctane = "octane"
+	// ServerFrankenPHP runs the FrankenPHP server.
+	ServerFrankenPHP = "frankenphp"
+
+	// octanePackage is the Composer package of Laravel Octane.
+	octanePackage = "laravel/octane"
+)
+
+// Server returns the server selected by GOOGLE_PHP_SERVER, if set. Otherwise it returns
+// ServerOctane if composer.json requires laravel/octane and ServerFPM if not. explicit is true if
+// the server was selected by GOOGLE_PHP_SERVER.
+func Server(ctx *gcp.Context) (server string, explicit bool, err error) {
+	if s := os.Getenv(ServerEnv); s != "" {
+		switch s := strings.ToLower(s); s {
+		case ServerFPM, ServerOctane, ServerFrankenPHP:
+			return s, true, nil
+		}
+		return "", false, gcp.UserErrorf("invalid %s %q, must be one of %q, %q or %q", ServerEnv, s, ServerFPM, ServerOctane, ServerFrankenPHP)
+	}
+	exists, err := ctx.FileExists(ctx.ApplicationRoot(), composerJSON)
+	if err != nil || !exists {
+		return ServerFPM, false, err
+	}
+	cjs, err := ReadComposerJSON(ctx.ApplicationRoot())
+	if err != nil {
+		return "", false, err
+	}
+	if _, ok := cjs.Require[octanePackage]; ok {
+		return ServerOctane, false, nil
+	}
+	return ServerFPM, false, nil
+}
+
+// CheckServerRequirements returns a user error if the application or the runtime lack what the
+// given server needs: the artisan script and the swoole or openswoole extension for Octane, and
+// the frankenphp binary for FrankenPHP.
+func CheckServerRequirements(ctx *gcp.Context, server string) error {
+	switch server {
+	case ServerOctane:
+		artisanExists, err := ctx.FileExists(ctx.ApplicationRoot(), "artisan")
+		if err != nil {
+			return err
+		}
+		if !artisanExists {
+			return gcp.UserErrorf("%s=%s requires a Laravel application with an artisan script in the application root", ServerEnv, ServerOctane)
+		}
+		result, err := ctx.Exec([]string{"php", "-m"})
+		if err != nil {
+			return err
+		}
+		if !hasSwooleExtension(result.Stdout) {
+			return gcp.UserErrorf("Laravel Octane requires the swoole or openswoole PHP extension, which is not available in the PHP runtime. Enable the extension, or set %s=%s to use php-fpm and nginx", ServerEnv, ServerFPM)
+		}
+	case ServerFrankenPHP:
+		result, err := ctx.Exec([]string{"bash", "-c", "command -v frankenphp || true"})
+		if err != nil {
+			return err
+		}
+		if strings.TrimSpace(result.Stdout) == "" {
+			return gcp.UserErrorf("%s=%s requires the frankenphp binary, which is not available in the PHP runtime. Set %s=%s to use php-fpm and nginx", ServerEnv, ServerFrankenPHP, ServerEnv, ServerFPM)
+		}
+	}
+	return nil
+}
+
+// hasSwooleExtension returns true if the output of `php -m` lists the swoole or openswoole module.
+func hasSwooleExtension(modules string) bool {
+	for _, m := range strings.Split(modules, "\n") {
+		switch strings.ToLower(strings.TrimSpace(m)) {
+		case "swoole", "openswoole":
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package php
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+func TestServer(t *testing.T) {
+	testCases := []struct {
+		name         string
+		composerJSON string
+		env          string
+		want         string
+		wantExplicit bool
+		wantErr      bool
+	}{
+		{
+			name: "no composer.json",
+			want: ServerFPM,
+		},
+		{
+			name:         "laravel without octane",
+			composerJSON: `{"require": {"php": "^8.2", "laravel/framework": "^11.0"}}`,
+			want:         ServerFPM,
+		},
+		{
+			name:         "laravel octane",
+			composerJSON: `{"require": {"php": "^8.2", "laravel/framework": "^11.0", "laravel/octane": "^2.3"}}`,
+			want:         ServerOctane,
+		},
+		{
+			name:         "env overrides octane detection",
+			composerJSON: `{"require": {"laravel/octane": "^2.3"}}`,
+			env:          "fpm",
+			want:         ServerFPM,
+			wantExplicit: true,
+		},
+		{
+			name:         "frankenphp",
+			env:          "FrankenPHP",
+			want:         ServerFrankenPHP,
+			wantExplicit: true,
+		},
+		{
+			name:    "invalid server",
+			env:     "roadrunner",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tc.composerJSON != "" {
+				if err := os.WriteFile(filepath.Join(dir, composerJSON), []byte(tc.composerJSON), 0644); err != nil {
+					t.Fatalf("writing composer.json: %v", err)
+				}
+			}
+			if tc.env != "" {
+				t.Setenv(ServerEnv, tc.env)
+			}
+
+			got, gotExplicit, err := Server(gcp.NewContext(gcp.WithApplicationRoot(dir)))
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("Server() = %q, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Server() got error: %v", err)
+			}
+			if got != tc.want || gotExplicit != tc.wantExplicit {
+				t.Errorf("Server() = (%q, %t), want (%q, %t)", got, gotExplicit, tc.want, tc.wantExplicit)
+			}
+		})
+	}
+}
+
+func TestHasSwooleExtension(t *testing.T) {
+	testCases := []struct {
+		name    string
+		modules string
+		want    bool
+	}{
+		{
+			name:    "swoole",
+			modules: "[PHP Modules]\nCore\ncurl\nswoole\nzlib\n\n[Zend Modules]\n",
+			want:    true,
+		},
+		{
+			name:    "openswoole",
+			modules: "[PHP Modules]\nCore\nopenswoole\n",
+			want:    true,
+		},
+		{
+			name:    "no swoole",
+			modules: "[PHP Modules]\nCore\ncurl\nswoole_async_helper\n",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := hasSwooleExtension(tc.modules); got != tc.want {
+				t.Errorf("hasSwooleExtension(%q) = %t, want %t", tc.modules, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package projecttoml reads the build configuration of the project.toml file defined by the Cloud
+// Native Buildpacks project descriptor specification: build environment variables and the files
+// included in or excluded from the build.
+package projecttoml
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// FileName is the name of the project descriptor in the root of the source.
+const FileName = "project.toml"
+
+// EnvVar is a build environment variable declared in a [[build.env]] entry.
+type EnvVar struct {
+	Name  string `toml:"name"`
+	Value string `toml:"value"`
+}
+
+// Config is the build configuration of a project.toml file.
+type Config struct {
+	// Env are the build environment variables, in the order they are declared.
+	Env []EnvVar
+	// Include are the patterns of the files that are part of the build. All other files are removed.
+	Include []string
+	// Exclude are the patterns of the files that are removed before the build.
+	Exclude []string
+}
+
+type descriptor struct {
+	// Build is the [build] table of schema version 0.1.
+	Build struct {
+		Include []string `toml:"include"`
+		Exclude []string `toml:"exclude"`
+		Env     []EnvVar `toml:"env"`
+	} `toml:"build"`
+	IO struct {
+		// Buildpacks is the [io.buildpacks] table of schema version 0.2, which declares the
+		// environment variables in [[io.buildpacks.build.env]].
+		Buildpacks struct {
+			Include []string `toml:"include"`
+			Exclude []string `toml:"exclude"`
+			Build   struct {
+				Env []EnvVar `toml:"env"`
+			} `toml:"build"`
+		} `toml:"buildpacks"`
+	} `toml:"io"`
+}
+
+// Read parses the project.toml file in dir. It returns nil if the file does not exist.
+func Read(dir string) (*Config, error) {
+	data, err := os.ReadFile(filepath.Join(dir, FileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", FileName, err)
+	}
+	return Parse(data)
+}
+
+// Parse parses the content of a project.toml file. Both schema versions 0.1 and 0.2 are supported.
+func Parse(data []byte) (*Config, error) {
+	var d descriptor
+	if err := toml.Unmarshal(data, &d); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", FileName, err)
+	}
+	bp := d.IO.Buildpacks
+	c := &Config{
+		Env:     append(d.Build.Env, bp.Build.Env...),
+		Include: append(d.Build.Include, bp.Include...),
+		Exclude: append(d.Build.Exclude, bp.Exclude...),
+	}
+	for _, e := range c.Env {
+		if e.Name == "" {
+			return nil, fmt.Errorf("invalid %s: build.env entries must have a name", FileName)
+		}
+	}
+	if len(c.Include) > 0 && len(c.Exclude) > 0 {
+		return nil, fmt.Errorf("invalid %s: include and exclude cannot both be set", FileName)
+	}
+	for _, p := range append(c.Include, c.Exclude...) {
+		if err := validatePattern(p); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", FileName, err)
+		}
+	}
+	return c, nil
+}
+
+// ExcludedFiles returns the paths relative to root of the files and directories that are not part
+// of the build: those that match an exclude pattern, or that do not match an include pattern when
+// include is set. Directories are returned instead of their content when all of it is excluded.
+// The project.toml file itself is never excluded.
+func (c *Config) ExcludedFiles(root string) ([]string, error) {
+	if len(c.Include) == 0 && len(c.Exclude) == 0 {
+		return nil, nil
+	}
+	excluded, _, err := c.excludedFiles(root, "")
+	return excluded, err
+}
+
+// excludedFiles returns the excluded paths in the directory rel, and whether any of its files is
+// part of the build.
+func (c *Config) excludedFiles(root, rel string) ([]string, bool, error) {
+	entries, err := os.ReadDir(filepath.Join(root, filepath.FromSlash(rel)))
+	if err != nil {
+		return nil, false, err
+	}
+	var excluded []string
+	kept := false
+	for _, e := range entries {
+		p := path.Join(rel, e.Name())
+		if p == FileName {
+			kept = true
+			continue
+		}
+		if len(c.Exclude) > 0 {
+			if matchAny(c.Exclude, p, e.IsDir()) {
+				excluded = append(excluded, p)
+				continue
+			}
+			kept = true
+			if e.IsDir() {
+				sub, _, err := c.excludedFiles(root, p)
+				if err != nil {
+					return nil, false, err
+				}
+				excluded = append(excluded, sub...)
+			}
+			continue
+		}
+		if matchAny(c.Include, p, e.IsDir()) {
+			kept = true
+			continue
+		}
+		if !e.IsDir() {
+			excluded = append(excluded, p)
+			continue
+		}
+		sub, subKept, err := c.excludedFiles(root, p)
+		if err != nil {
+			return nil, false, err
+		}
+		if subKept {
+			kept = true
+			excluded = append(excluded, sub...)
+		} else {
+			excluded = append(excluded, p)
+		}
+	}
+	return excluded, kept, nil
+}
+
+// validatePattern returns an error if the given pattern is malformed.
+func validatePattern(pattern string) error {
+	for _, s := range strings.Split(strings.Trim(pattern, "/"), "/") {
+		if _, err := path.Match(s, ""); err != nil {
+			return fmt.Errorf("malformed pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+func matchAny(patterns []string, rel string, isDir bool) bool {
+	for _, p := range patterns {
+		if match(p, rel, isDir) {
+			return true
+		}
+	}
+	return false
+}
+
+// match reports whether the slash-separated path rel matches the pattern, which follows the
+// .gitignore syntax without negation: a pattern with a trailing slash only matches directories, a
+// pattern without a slash matches a file name at any depth, other patterns are relative to the
+// root, and "**" matches any number of directories.
+func match(pattern, rel string, isDir bool) bool {
+	if strings.HasSuffix(pattern, "/") {
+		if !isDir {
+			return false
+		}
+		pattern = strings.TrimSuffix(pattern, "/")
+	}
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(rel))
+		return ok
+	}
+	return matchSegments(strings.Split(strings.TrimPrefix(pattern, "/"), "/"), strings.Split(rel, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	if len(pattern) == 0 {
+		return len(name) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(name); i++ {
+			if matchSegments(pattern[1:], name[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(name) == 0 {
+		return false
+	}
+	ok, _ := path.Match(pattern[0], name[0])
+	return ok && matchSegments(pattern[1:], name[1:])
+}
@@ -0,0 +1,218 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package projecttoml
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+		want    *Config
+		wantErr bool
+	}{
+		{
+			name: "schema 0.1",
+			content: `
+[project]
+id = "io.buildpacks.my-app"
+
+[build]
+exclude = ["*.md", "docs/"]
+
+[[build.env]]
+name = "GOOGLE_RUNTIME_VERSION"
+value = "20"
+
+[[build.env]]
+name = "GOOGLE_BUILD_ARGS"
+value = "-Pprod"
+`,
+			want: &Config{
+				Env: []EnvVar{
+					{Name: "GOOGLE_RUNTIME_VERSION", Value: "20"},
+					{Name: "GOOGLE_BUILD_ARGS", Value: "-Pprod"},
+				},
+				Exclude: []string{"*.md", "docs/"},
+			},
+		},
+		{
+			name: "schema 0.2",
+			content: `
+[_]
+schema-version = "0.2"
+id = "io.buildpacks.my-app"
+
+[io.buildpacks]
+include = ["src/", "package.json"]
+
+[[io.buildpacks.build.env]]
+name = "GOOGLE_ENTRYPOINT"
+value = "node src/index.js"
+`,
+			want: &Config{
+				Env:     []EnvVar{{Name: "GOOGLE_ENTRYPOINT", Value: "node src/index.js"}},
+				Include: []string{"src/", "package.json"},
+			},
+		},
+		{
+			name:    "empty value",
+			content: "[[build.env]]\nname = \"GOOGLE_CLEAR_SOURCE\"\n",
+			want:    &Config{Env: []EnvVar{{Name: "GOOGLE_CLEAR_SOURCE"}}},
+		},
+		{
+			name:    "no build configuration",
+			content: "[project]\nid = \"my-app\"\n",
+			want:    &Config{},
+		},
+		{
+			name:    "missing name",
+			content: "[[build.env]]\nvalue = \"20\"\n",
+			wantErr: true,
+		},
+		{
+			name:    "include and exclude",
+			content: "[build]\ninclude = [\"src/\"]\nexclude = [\"*.md\"]\n",
+			wantErr: true,
+		},
+		{
+			name:    "malformed pattern",
+			content: "[build]\nexclude = [\"[a-\"]\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid toml",
+			content: "[build\n",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Parse([]byte(tc.content))
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("Parse() = %+v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse() got error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("Parse() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestReadMissingFile(t *testing.T) {
+	got, err := Read(t.TempDir())
+	if err != nil {
+		t.Fatalf("Read() got error: %v", err)
+	}
+	if got != nil {
+		t.Errorf("Read() = %+v, want nil", got)
+	}
+}
+
+func TestExcludedFiles(t *testing.T) {
+	files := []string{
+		"project.toml",
+		"README.md",
+		"package.json",
+		"src/index.js",
+		"src/README.md",
+		"src/lib/util.js",
+		"docs/guide.txt",
+		"test/index.test.js",
+		"test/fixtures/data.json",
+	}
+	testCases := []struct {
+		name   string
+		config Config
+		want   []string
+	}{
+		{
+			name:   "exclude file names at any depth",
+			config: Config{Exclude: []string{"*.md"}},
+			want:   []string{"README.md", "src/README.md"},
+		},
+		{
+			name:   "exclude directories",
+			config: Config{Exclude: []string{"docs/", "test"}},
+			want:   []string{"docs", "test"},
+		},
+		{
+			name:   "exclude relative to the root",
+			config: Config{Exclude: []string{"/README.md", "src/lib"}},
+			want:   []string{"README.md", "src/lib"},
+		},
+		{
+			name:   "exclude with double star",
+			config: Config{Exclude: []string{"**/*.json"}},
+			want:   []string{"package.json", "test/fixtures/data.json"},
+		},
+		{
+			name:   "directory pattern does not match files",
+			config: Config{Exclude: []string{"package.json/"}},
+		},
+		{
+			name:   "include",
+			config: Config{Include: []string{"src/", "package.json"}},
+			want:   []string{"README.md", "docs", "test"},
+		},
+		{
+			name:   "include nested files",
+			config: Config{Include: []string{"src/lib/*.js", "test/fixtures"}},
+			want:   []string{"README.md", "docs", "package.json", "src/README.md", "src/index.js", "test/index.test.js"},
+		},
+		{
+			name:   "project.toml is never excluded",
+			config: Config{Exclude: []string{"*.toml"}},
+		},
+		{
+			name:   "no patterns",
+			config: Config{},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			root := t.TempDir()
+			for _, f := range files {
+				path := filepath.Join(root, f)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatalf("creating dir: %v", err)
+				}
+				if err := os.WriteFile(path, nil, 0644); err != nil {
+					t.Fatalf("writing file: %v", err)
+				}
+			}
+
+			got, err := tc.config.ExcludedFiles(root)
+			if err != nil {
+				t.Fatalf("ExcludedFiles() got error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("ExcludedFiles() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,237 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package python
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+const (
+	// DjangoSkipCollectstaticEnv is an env var that skips `manage.py collectstatic` during the build
+	// of Django applications.
+	DjangoSkipCollectstaticEnv = "GOOGLE_DJANGO_SKIP_COLLECTSTATIC"
+	// DjangoStaticRootEnv is set to a directory in a layer during the build and at run time. Settings
+	// that read STATIC_ROOT from the environment collect the static files into that layer.
+	DjangoStaticRootEnv = "STATIC_ROOT"
+	// DjangoSettingsModuleEnv is the env var that tells Django which settings module to use.
+	DjangoSettingsModuleEnv = "DJANGO_SETTINGS_MODULE"
+
+	djangoManageScript = "manage.py"
+)
+
+var (
+	// DjangoMigrateCommand is the command of the release process of Django projects, which applies the
+	// database migrations.
+	DjangoMigrateCommand = []string{"python3", djangoManageScript, "migrate", "--noinput"}
+
+	// djangoRequirementRegexp matches a django requirement in requirements.txt, but not packages whose
+	// name starts with django, such as django-environ.
+	djangoRequirementRegexp = regexp.MustCompile(`(?im)^\s*django\s*(?:[<>=!~;\[@]|$)`)
+	// djangoSettingsRegexp matches the default settings module set by manage.py, for example
+	// os.environ.setdefault("DJANGO_SETTINGS_MODULE", "mysite.settings").
+	djangoSettingsRegexp = regexp.MustCompile(`DJANGO_SETTINGS_MODULE['"]\s*,\s*['"]([\w.]+)['"]`)
+	// djangoWSGIRegexp matches the WSGI_APPLICATION setting, for example
+	// WSGI_APPLICATION = "mysite.wsgi.application".
+	djangoWSGIRegexp = regexp.MustCompile(`(?m)^WSGI_APPLICATION\s*=\s*['"]([\w.]+)['"]`)
+	// djangoCheckIssueRegexp matches an issue reported by `manage.py check`, for example
+	// "?: (security.W004) You have not set a value for the SECURE_HSTS_SECONDS setting.".
+	djangoCheckIssueRegexp = regexp.MustCompile(`(?m)^\S*: \([\w.]+\.[EW]\d+\).*$`)
+	// djangoMissingEnvRegexp matches errors raised by settings that depend on the environment, such as
+	// a missing SECRET_KEY, a STATIC_ROOT read from an unset variable, or os.environ["DATABASE_URL"].
+	djangoMissingEnvRegexp = regexp.MustCompile(`ImproperlyConfigured|KeyError: '\w+'|[Ee]nvironment variable|must not be empty`)
+)
+
+// IsDjango returns true if the application is a Django project: it has a manage.py file and depends
+// on django in requirements.txt.
+func IsDjango(ctx *gcp.Context) (bool, error) {
+	manageExists, err := ctx.FileExists(ctx.ApplicationRoot(), djangoManageScript)
+	if err != nil || !manageExists {
+		return false, err
+	}
+	reqsExists, err := ctx.FileExists(ctx.ApplicationRoot(), "requirements.txt")
+	if err != nil || !reqsExists {
+		return false, err
+	}
+	reqs, err := ctx.ReadFile(filepath.Join(ctx.ApplicationRoot(), "requirements.txt"))
+	if err != nil {
+		return false, err
+	}
+	return djangoRequirementRegexp.Match(reqs), nil
+}
+
+// DjangoSettingsModule returns the settings module of a Django project: the value of
+// DJANGO_SETTINGS_MODULE if set, otherwise the default set by manage.py. It returns an empty string
+// if neither is available.
+func DjangoSettingsModule(ctx *gcp.Context) (string, error) {
+	if m := os.Getenv(DjangoSettingsModuleEnv); m != "" {
+		return m, nil
+	}
+	manage, err := ctx.ReadFile(filepath.Join(ctx.ApplicationRoot(), djangoManageScript))
+	if err != nil {
+		return "", err
+	}
+	if m := djangoSettingsRegexp.FindSubmatch(manage); m != nil {
+		return string(m[1]), nil
+	}
+	return "", nil
+}
+
+// DjangoWSGIApplication returns the WSGI application of a Django project in the module:attribute
+// form used by gunicorn, e.g. "mysite.wsgi:application". It reads WSGI_APPLICATION from the settings
+// module and falls back to the wsgi.py file created by startproject next to the settings. It returns
+// an empty string if the application cannot be found.
+func DjangoWSGIApplication(ctx *gcp.Context, settingsModule string) (string, error) {
+	if settingsModule == "" {
+		return "", nil
+	}
+	files, err := settingsFiles(ctx, settingsModule)
+	if err != nil {
+		return "", err
+	}
+	for _, f := range files {
+		content, err := ctx.ReadFile(f)
+		if err != nil {
+			return "", err
+		}
+		if m := djangoWSGIRegexp.FindSubmatch(content); m != nil {
+			app := string(m[1])
+			i := strings.LastIndex(app, ".")
+			if i < 0 {
+				continue
+			}
+			return app[:i] + ":" + app[i+1:], nil
+		}
+	}
+	project := strings.Split(settingsModule, ".")[0]
+	wsgiExists, err := ctx.FileExists(ctx.ApplicationRoot(), project, "wsgi.py")
+	if err != nil || !wsgiExists {
+		return "", err
+	}
+	return project + ".wsgi:application", nil
+}
+
+// settingsFiles returns the paths of the files that may define the settings of the given module:
+// the module itself, then the other files of its package since split settings commonly import a
+// shared base module, e.g. "from .base import *".
+func settingsFiles(ctx *gcp.Context, module string) ([]string, error) {
+	path := filepath.Join(ctx.ApplicationRoot(), filepath.FromSlash(strings.ReplaceAll(module, ".", "/")))
+	var first, dir string
+	fileExists, err := ctx.FileExists(path + ".py")
+	if err != nil {
+		return nil, err
+	}
+	initExists, err := ctx.FileExists(path, "__init__.py")
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case fileExists:
+		first, dir = path+".py", filepath.Dir(path)
+	case initExists:
+		first, dir = filepath.Join(path, "__init__.py"), path
+	default:
+		return nil, nil
+	}
+	files := []string{first}
+	// Top-level modules are not part of a package, so the other files are unrelated.
+	if dir == ctx.ApplicationRoot() {
+		return files, nil
+	}
+	siblings, err := ctx.Glob(filepath.Join(dir, "*.py"))
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range siblings {
+		if f != first {
+			files = append(files, f)
+		}
+	}
+	return files, nil
+}
+
+// CollectDjangoStatic runs `manage.py collectstatic` with STATIC_ROOT set to a directory in the
+// given layer, unless it is set by the user, and keeps it set at run time. execEnv makes the
+// dependencies of the application importable. Failures caused by settings that need environment
+// variables which are not available during the build are logged as warnings with guidance since
+// the application may still work if it serves its static files differently.
+func CollectDjangoStatic(ctx *gcp.Context, l *libcnb.Layer, execEnv []string) error {
+	skip, err := env.IsPresentAndTrue(DjangoSkipCollectstaticEnv)
+	if err != nil {
+		return err
+	}
+	if skip {
+		ctx.Logf("Skipping Django collectstatic because %s is set.", DjangoSkipCollectstaticEnv)
+		return nil
+	}
+	if os.Getenv(DjangoStaticRootEnv) == "" {
+		staticRoot := filepath.Join(l.Path, "static")
+		execEnv = append(execEnv, DjangoStaticRootEnv+"="+staticRoot)
+		l.LaunchEnvironment.Default(DjangoStaticRootEnv, staticRoot)
+	}
+
+	ctx.Logf("Collecting Django static files.")
+	result, err := ctx.Exec([]string{"python3", djangoManageScript, "collectstatic", "--noinput"}, gcp.WithEnv(execEnv...), gcp.WithUserAttribution)
+	if err == nil {
+		return nil
+	}
+	if result == nil {
+		return err
+	}
+	if strings.Contains(result.Combined, "Unknown command: 'collectstatic'") {
+		ctx.Logf("Skipping Django collectstatic because django.contrib.staticfiles is not in INSTALLED_APPS.")
+		return nil
+	}
+	if djangoMissingEnvRegexp.MatchString(result.Combined) {
+		ctx.Warnf("Django collectstatic failed because the settings could not be loaded during the build, static files were not collected. "+
+			"Read STATIC_ROOT from the %s environment variable in settings.py, give the settings that read environment variables a default value "+
+			"or provide them as build environment variables, or set %s=true to skip this step.", DjangoStaticRootEnv, DjangoSkipCollectstaticEnv)
+		return nil
+	}
+	return gcp.UserErrorf("running Django collectstatic, fix the error above or set %s=true to skip this step: %w", DjangoSkipCollectstaticEnv, err)
+}
+
+// CheckDjangoDeploy runs `manage.py check --deploy` and logs the issues it reports as warnings. It
+// never fails the build since the checks are only recommendations.
+func CheckDjangoDeploy(ctx *gcp.Context, execEnv []string) {
+	ctx.Logf("Checking the Django deployment settings.")
+	result, err := ctx.Exec([]string{"python3", djangoManageScript, "check", "--deploy"}, gcp.WithEnv(execEnv...), gcp.WithUserAttribution)
+	if result == nil {
+		ctx.Warnf("Failed to run Django deployment checks: %v", err)
+		return
+	}
+	issues := djangoCheckIssues(result.Combined)
+	for _, issue := range issues {
+		ctx.Warnf("Django deployment check: %s", issue)
+	}
+	if err != nil && len(issues) == 0 {
+		ctx.Warnf("Django deployment checks did not complete: %v", err)
+	}
+}
+
+// djangoCheckIssues returns the issues reported in the output of `manage.py check`.
+func djangoCheckIssues(output string) []string {
+	var issues []string
+	for _, issue := range djangoCheckIssueRegexp.FindAllString(output, -1) {
+		issues = append(issues, strings.TrimSpace(issue))
+	}
+	return issues
+}
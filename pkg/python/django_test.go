@@ -0,0 +1,246 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package python
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/google/go-cmp/cmp"
+)
+
+const manageScript = `#!/usr/bin/env python
+import os
+import sys
+
+if __name__ == "__main__":
+    os.environ.setdefault("DJANGO_SETTINGS_MODULE", "mysite.settings")
+    from django.core.management import execute_from_command_line
+    execute_from_command_line(sys.argv)
+`
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("creating directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("writing %s: %v", name, err)
+		}
+	}
+}
+
+func TestIsDjango(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		want  bool
+	}{
+		{
+			name:  "manage.py and django requirement",
+			files: map[string]string{"manage.py": manageScript, "requirements.txt": "gunicorn\nDjango==5.0.3\n"},
+			want:  true,
+		},
+		{
+			name:  "unpinned django requirement",
+			files: map[string]string{"manage.py": manageScript, "requirements.txt": "django"},
+			want:  true,
+		},
+		{
+			name:  "django with extras",
+			files: map[string]string{"manage.py": manageScript, "requirements.txt": "django[argon2]>=4.2"},
+			want:  true,
+		},
+		{
+			name:  "only django plugins",
+			files: map[string]string{"manage.py": manageScript, "requirements.txt": "django-environ==0.11.2\n"},
+		},
+		{
+			name:  "no manage.py",
+			files: map[string]string{"requirements.txt": "django==5.0.3"},
+		},
+		{
+			name:  "no requirements.txt",
+			files: map[string]string{"manage.py": manageScript},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tc.files)
+
+			got, err := IsDjango(gcp.NewContext(gcp.WithApplicationRoot(dir)))
+			if err != nil {
+				t.Fatalf("IsDjango() got error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("IsDjango() = %t, want %t", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDjangoSettingsModule(t *testing.T) {
+	testCases := []struct {
+		name   string
+		manage string
+		env    string
+		want   string
+	}{
+		{
+			name:   "default from manage.py",
+			manage: manageScript,
+			want:   "mysite.settings",
+		},
+		{
+			name:   "single quotes",
+			manage: `os.environ.setdefault('DJANGO_SETTINGS_MODULE', 'config.settings.production')`,
+			want:   "config.settings.production",
+		},
+		{
+			name:   "from environment",
+			manage: manageScript,
+			env:    "mysite.settings_prod",
+			want:   "mysite.settings_prod",
+		},
+		{
+			name:   "not set",
+			manage: "from django.core.management import execute_from_command_line\n",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, map[string]string{"manage.py": tc.manage})
+			if tc.env != "" {
+				t.Setenv(DjangoSettingsModuleEnv, tc.env)
+			}
+
+			got, err := DjangoSettingsModule(gcp.NewContext(gcp.WithApplicationRoot(dir)))
+			if err != nil {
+				t.Fatalf("DjangoSettingsModule() got error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("DjangoSettingsModule() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDjangoWSGIApplication(t *testing.T) {
+	testCases := []struct {
+		name     string
+		settings string
+		files    map[string]string
+		want     string
+	}{
+		{
+			name:     "settings module",
+			settings: "mysite.settings",
+			files: map[string]string{
+				"mysite/settings.py": "DEBUG = False\nWSGI_APPLICATION = 'mysite.wsgi.application'\n",
+				"mysite/wsgi.py":     "",
+			},
+			want: "mysite.wsgi:application",
+		},
+		{
+			name:     "custom wsgi module",
+			settings: "mysite.settings",
+			files: map[string]string{
+				"mysite/settings.py": `WSGI_APPLICATION = "config.server.app"`,
+			},
+			want: "config.server:app",
+		},
+		{
+			name:     "settings package",
+			settings: "config.settings.production",
+			files: map[string]string{
+				"config/settings/__init__.py":   "",
+				"config/settings/base.py":       `WSGI_APPLICATION = "config.wsgi.application"`,
+				"config/settings/production.py": "from .base import *\n",
+			},
+			want: "config.wsgi:application",
+		},
+		{
+			name:     "settings package module",
+			settings: "config.settings",
+			files: map[string]string{
+				"config/settings/__init__.py": "",
+				"config/settings/base.py":     `WSGI_APPLICATION = "config.wsgi.application"`,
+			},
+			want: "config.wsgi:application",
+		},
+		{
+			name:     "wsgi.py next to settings",
+			settings: "mysite.settings",
+			files: map[string]string{
+				"mysite/settings.py": "DEBUG = False\n",
+				"mysite/wsgi.py":     "",
+			},
+			want: "mysite.wsgi:application",
+		},
+		{
+			name:     "not found",
+			settings: "mysite.settings",
+			files: map[string]string{
+				"mysite/settings.py": "DEBUG = False\n",
+			},
+		},
+		{
+			name: "no settings module",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tc.files)
+
+			got, err := DjangoWSGIApplication(gcp.NewContext(gcp.WithApplicationRoot(dir)), tc.settings)
+			if err != nil {
+				t.Fatalf("DjangoWSGIApplication(%q) got error: %v", tc.settings, err)
+			}
+			if got != tc.want {
+				t.Errorf("DjangoWSGIApplication(%q) = %q, want %q", tc.settings, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDjangoCheckIssues(t *testing.T) {
+	output := `System check identified some issues:
+
+WARNINGS:
+?: (security.W004) You have not set a value for the SECURE_HSTS_SECONDS setting.
+?: (security.W008) Your SECURE_SSL_REDIRECT setting is not set to True.
+polls.Question: (models.W042) Auto-created primary key used when not defining a primary key type.
+
+System check identified 3 issues (0 silenced).
+`
+	want := []string{
+		"?: (security.W004) You have not set a value for the SECURE_HSTS_SECONDS setting.",
+		"?: (security.W008) Your SECURE_SSL_REDIRECT setting is not set to True.",
+		"polls.Question: (models.W042) Auto-created primary key used when not defining a primary key type.",
+	}
+	if diff := cmp.Diff(want, djangoCheckIssues(output)); diff != "" {
+		t.Errorf("djangoCheckIssues() mismatch (-want +got):\n%s", diff)
+	}
+	if got := djangoCheckIssues("System check identified no issues (0 silenced).\n"); len(got) != 0 {
+		t.Errorf("djangoCheckIssues() = %q, want no issues", got)
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package python
+
+import (
+	"path/filepath"
+	"regexp"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+var (
+	// flaskRequirementRegexp matches a flask requirement in requirements.txt, but not packages whose
+	// name starts with flask, such as flask-cors.
+	flaskRequirementRegexp = regexp.MustCompile(`(?im)^\s*flask\s*(?:[<>=!~;\[@]|$)`)
+	// flaskAppRegexp matches a module-level assignment of the application, for example
+	// app = Flask(__name__) or app: Flask = create_app().
+	flaskAppRegexp = regexp.MustCompile(`(?m)^app\s*(?::[^=\n]*)?=[^=]`)
+	// flaskFactoryRegexp matches a module-level application factory, for example def create_app():.
+	flaskFactoryRegexp = regexp.MustCompile(`(?m)^def\s+(create_app|make_app)\s*\(`)
+
+	// flaskModules are the modules searched for the application, in order.
+	flaskModules = []string{"wsgi", "app"}
+)
+
+// IsFlask returns true if the application depends on flask in requirements.txt.
+func IsFlask(ctx *gcp.Context) (bool, error) {
+	reqsExists, err := ctx.FileExists(ctx.ApplicationRoot(), "requirements.txt")
+	if err != nil || !reqsExists {
+		return false, err
+	}
+	reqs, err := ctx.ReadFile(filepath.Join(ctx.ApplicationRoot(), "requirements.txt"))
+	if err != nil {
+		return false, err
+	}
+	return flaskRequirementRegexp.Match(reqs), nil
+}
+
+// FlaskApplication returns the Flask application of wsgi.py or app.py in the form expected by
+// gunicorn: "app:app" if the module assigns an app variable, or "app:create_app()" if it defines an
+// application factory, following the discovery order of `flask run`. It returns an empty string if
+// neither module defines an application.
+func FlaskApplication(ctx *gcp.Context) (string, error) {
+	for _, module := range flaskModules {
+		path := filepath.Join(ctx.ApplicationRoot(), module+".py")
+		exists, err := ctx.FileExists(path)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			continue
+		}
+		content, err := ctx.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		if flaskAppRegexp.Match(content) {
+			return module + ":app", nil
+		}
+		if m := flaskFactoryRegexp.FindSubmatch(content); m != nil {
+			return module + ":" + string(m[1]) + "()", nil
+		}
+	}
+	return "", nil
+}
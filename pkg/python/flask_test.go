@@ -0,0 +1,138 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package python
+
+import (
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const flaskFactory = `from flask import Flask
+
+
+def create_app(config=None):
+    app = Flask(__name__)
+    return app
+`
+
+func TestIsFlask(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		want  bool
+	}{
+		{
+			name:  "pinned flask requirement",
+			files: map[string]string{"requirements.txt": "gunicorn\nFlask==3.0.2\n"},
+			want:  true,
+		},
+		{
+			name:  "flask with extras",
+			files: map[string]string{"requirements.txt": "flask[async]>=3.0"},
+			want:  true,
+		},
+		{
+			name:  "only flask plugins",
+			files: map[string]string{"requirements.txt": "flask-cors==4.0.0\n"},
+		},
+		{
+			name: "no requirements.txt",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tc.files)
+
+			got, err := IsFlask(gcp.NewContext(gcp.WithApplicationRoot(dir)))
+			if err != nil {
+				t.Fatalf("IsFlask() got error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("IsFlask() = %t, want %t", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFlaskApplication(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{
+			name:  "direct app",
+			files: map[string]string{"app.py": "from flask import Flask\n\napp = Flask(__name__)\n"},
+			want:  "app:app",
+		},
+		{
+			name:  "annotated app",
+			files: map[string]string{"app.py": "app: Flask = Flask(__name__)\n"},
+			want:  "app:app",
+		},
+		{
+			name:  "app factory",
+			files: map[string]string{"app.py": flaskFactory},
+			want:  "app:create_app()",
+		},
+		{
+			name:  "make_app factory",
+			files: map[string]string{"app.py": "def make_app():\n    return Flask(__name__)\n"},
+			want:  "app:make_app()",
+		},
+		{
+			name:  "app created by factory",
+			files: map[string]string{"app.py": flaskFactory + "\napp = create_app()\n"},
+			want:  "app:app",
+		},
+		{
+			name:  "wsgi module",
+			files: map[string]string{"wsgi.py": flaskFactory},
+			want:  "wsgi:create_app()",
+		},
+		{
+			name:  "wsgi module takes precedence",
+			files: map[string]string{"app.py": flaskFactory, "wsgi.py": "from app import create_app\n\napp = create_app()\n"},
+			want:  "wsgi:app",
+		},
+		{
+			name:  "comparison is not an assignment",
+			files: map[string]string{"app.py": "app == None\n"},
+		},
+		{
+			name:  "no application",
+			files: map[string]string{"app.py": "print('hello')\n"},
+		},
+		{
+			name: "no module",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tc.files)
+
+			got, err := FlaskApplication(gcp.NewContext(gcp.WithApplicationRoot(dir)))
+			if err != nil {
+				t.Fatalf("FlaskApplication() got error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("FlaskApplication() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package python
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// GunicornConfigFile is the name of the gunicorn config file that is used, if it exists at the
+	// application root, by the default gunicorn command.
+	GunicornConfigFile = "gunicorn.conf.py"
+
+	// GunicornTimeoutEnv is an env var used to set the number of seconds after which the default
+	// gunicorn command restarts a silent worker, e.g. one handling a long request. It overrides the
+	// timeout of the gunicorn config, which is disabled by the generated config.
+	GunicornTimeoutEnv = "GOOGLE_GUNICORN_TIMEOUT"
+
+	// GunicornGracefulTimeoutEnv is an env var used to set the number of seconds that the workers of
+	// the default gunicorn command have to finish their requests after a restart or SIGTERM. It
+	// overrides the graceful_timeout of the gunicorn config, which is 8 in the generated config.
+	GunicornGracefulTimeoutEnv = "GOOGLE_GUNICORN_GRACEFUL_TIMEOUT"
+
+	gunicornConfigLayer = "gunicorn-config"
+
+	// gunicornDefaultConfig is the config generated when the application does not have a
+	// gunicorn.conf.py. The number of workers is computed at startup from the CPU and memory limits
+	// of the container, unless WEB_CONCURRENCY is set. The settings of GUNICORN_CMD_ARGS take
+	// precedence over the config.
+	gunicornDefaultConfig = `# Generated by Google Cloud's buildpacks: gunicorn defaults for Cloud Run.
+# Add a gunicorn.conf.py file to the application root to replace these settings.
+import os
+
+# Memory reserved for each worker when capping the number of workers.
+_MEMORY_PER_WORKER = 256 * 1024 * 1024
+
+
+def _cpu_count():
+    try:
+        with open("/sys/fs/cgroup/cpu.max") as f:
+            quota, period = f.read().split()
+        if quota != "max":
+            return max(1, int(quota) // int(period))
+    except (OSError, ValueError):
+        pass
+    try:
+        return len(os.sched_getaffinity(0))
+    except (AttributeError, OSError):
+        return os.cpu_count() or 1
+
+
+def _memory_limit():
+    for path in ("/sys/fs/cgroup/memory.max", "/sys/fs/cgroup/memory/memory.limit_in_bytes"):
+        try:
+            with open(path) as f:
+                limit = f.read().strip()
+        except OSError:
+            continue
+        if limit.isdigit() and int(limit) < 1 << 60:
+            return int(limit)
+    return None
+
+
+def _workers():
+    if os.environ.get("WEB_CONCURRENCY", "").isdigit():
+        return max(1, int(os.environ["WEB_CONCURRENCY"]))
+    workers = _cpu_count()
+    memory = _memory_limit()
+    if memory:
+        workers = min(workers, memory // _MEMORY_PER_WORKER)
+    return max(1, workers)
+
+
+workers = _workers()
+threads = 8
+# Cloud Run enforces the request timeout.
+timeout = 0
+# Cloud Run stops the container 10 seconds after sending SIGTERM.
+graceful_timeout = 8
+accesslog = "-"
+errorlog = "-"
+`
+)
+
+// GunicornCommand returns the gunicorn command that serves the given WSGI application on port 8080.
+// It uses the gunicorn.conf.py of the application if there is one, or a config with defaults for
+// Cloud Run generated in a launch layer otherwise. The timeouts set with GOOGLE_GUNICORN_TIMEOUT and
+// GOOGLE_GUNICORN_GRACEFUL_TIMEOUT are passed as flags, which take precedence over the config.
+func GunicornCommand(ctx *gcp.Context, app string) ([]string, error) {
+	timeouts, err := GunicornTimeoutArgs()
+	if err != nil {
+		return nil, err
+	}
+	config, err := gunicornConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cmd := append([]string{"gunicorn", "-b", ":8080", "-c", config}, timeouts...)
+	return append(cmd, app), nil
+}
+
+// GunicornTimeoutArgs returns the gunicorn flags for the timeouts set with GOOGLE_GUNICORN_TIMEOUT
+// and GOOGLE_GUNICORN_GRACEFUL_TIMEOUT, or an error if they are not positive integers.
+func GunicornTimeoutArgs() ([]string, error) {
+	var args []string
+	for _, t := range []struct{ env, flag string }{
+		{GunicornTimeoutEnv, "--timeout"},
+		{GunicornGracefulTimeoutEnv, "--graceful-timeout"},
+	} {
+		v := os.Getenv(t.env)
+		if v == "" {
+			continue
+		}
+		seconds, err := strconv.Atoi(v)
+		if err != nil || seconds <= 0 {
+			return nil, gcp.UserErrorf("invalid %s %q: it must be a positive integer number of seconds", t.env, v)
+		}
+		args = append(args, t.flag, strconv.Itoa(seconds))
+	}
+	return args, nil
+}
+
+// gunicornConfig returns the path of the gunicorn config file to use, relative to the application
+// root if it is the one of the application.
+func gunicornConfig(ctx *gcp.Context) (string, error) {
+	exists, err := ctx.FileExists(ctx.ApplicationRoot(), GunicornConfigFile)
+	if err != nil {
+		return "", err
+	}
+	if exists {
+		ctx.Logf("Using gunicorn config file %s.", GunicornConfigFile)
+		return GunicornConfigFile, nil
+	}
+	l, err := ctx.Layer(gunicornConfigLayer, gcp.LaunchLayer)
+	if err != nil {
+		return "", fmt.Errorf("creating %v layer: %w", gunicornConfigLayer, err)
+	}
+	path := filepath.Join(l.Path, GunicornConfigFile)
+	if err := ctx.WriteFile(path, []byte(gunicornDefaultConfig), 0644); err != nil {
+		return "", err
+	}
+	ctx.Debugf("Generated gunicorn config %s:\n%s", path, gunicornDefaultConfig)
+	return path, nil
+}
@@ -0,0 +1,151 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package python
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGunicornCommand(t *testing.T) {
+	testCases := []struct {
+		name          string
+		files         map[string]string
+		wantConfig    string
+		wantGenerated bool
+	}{
+		{
+			name:       "app config file",
+			files:      map[string]string{"main.py": "", "gunicorn.conf.py": "workers = 4\n"},
+			wantConfig: "gunicorn.conf.py",
+		},
+		{
+			name:          "generated config",
+			files:         map[string]string{"main.py": ""},
+			wantGenerated: true,
+		},
+		{
+			name:          "config file in subdirectory",
+			files:         map[string]string{"main.py": "", "config/gunicorn.conf.py": "workers = 4\n"},
+			wantGenerated: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tc.files {
+				path := filepath.Join(dir, name)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			layers := t.TempDir()
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(dir), gcp.WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: layers}}))
+			wantConfig := tc.wantConfig
+			if tc.wantGenerated {
+				wantConfig = filepath.Join(layers, gunicornConfigLayer, GunicornConfigFile)
+			}
+
+			got, err := GunicornCommand(ctx, "main:app")
+			if err != nil {
+				t.Fatalf("GunicornCommand() got error: %v", err)
+			}
+
+			want := []string{"gunicorn", "-b", ":8080", "-c", wantConfig, "main:app"}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("GunicornCommand() mismatch (-want +got):\n%s", diff)
+			}
+			content, err := os.ReadFile(filepath.Join(layers, gunicornConfigLayer, GunicornConfigFile))
+			if gotGenerated := err == nil; gotGenerated != tc.wantGenerated {
+				t.Fatalf("generated config exists: %v, want %v", gotGenerated, tc.wantGenerated)
+			}
+			if tc.wantGenerated && string(content) != gunicornDefaultConfig {
+				t.Errorf("generated config = %q, want %q", content, gunicornDefaultConfig)
+			}
+		})
+	}
+}
+
+func TestGunicornCommandTimeouts(t *testing.T) {
+	testCases := []struct {
+		name    string
+		env     map[string]string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "unset",
+			want: []string{"gunicorn", "-b", ":8080", "-c", GunicornConfigFile, "main:app"},
+		},
+		{
+			name: "timeout",
+			env:  map[string]string{GunicornTimeoutEnv: "300"},
+			want: []string{"gunicorn", "-b", ":8080", "-c", GunicornConfigFile, "--timeout", "300", "main:app"},
+		},
+		{
+			name: "graceful timeout",
+			env:  map[string]string{GunicornGracefulTimeoutEnv: "20"},
+			want: []string{"gunicorn", "-b", ":8080", "-c", GunicornConfigFile, "--graceful-timeout", "20", "main:app"},
+		},
+		{
+			name: "both",
+			env:  map[string]string{GunicornTimeoutEnv: "120", GunicornGracefulTimeoutEnv: "5"},
+			want: []string{"gunicorn", "-b", ":8080", "-c", GunicornConfigFile, "--timeout", "120", "--graceful-timeout", "5", "main:app"},
+		},
+		{
+			name:    "zero",
+			env:     map[string]string{GunicornTimeoutEnv: "0"},
+			wantErr: true,
+		},
+		{
+			name:    "negative",
+			env:     map[string]string{GunicornGracefulTimeoutEnv: "-1"},
+			wantErr: true,
+		},
+		{
+			name:    "not an integer",
+			env:     map[string]string{GunicornTimeoutEnv: "30s"},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, GunicornConfigFile), nil, 0644); err != nil {
+				t.Fatal(err)
+			}
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(dir))
+
+			got, err := GunicornCommand(ctx, "main:app")
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("GunicornCommand() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("GunicornCommand() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
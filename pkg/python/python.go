@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"time"
 
@@ -27,6 +28,7 @@ import (
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/toolversions"
 	"github.com/buildpacks/libcnb"
 )
 
@@ -45,9 +47,31 @@ const (
 	// The requirements files are processed from left to right, with requirements from the next overriding any conflicts from the previous.
 	RequirementsFilesEnv = "GOOGLE_INTERNAL_REQUIREMENTS_FILES"
 
+	// FrameworkInjectedEnv is an environment variable set by the functions framework buildpack when
+	// it adds functions-framework to the requirements because the function does not depend on it.
+	FrameworkInjectedEnv = "GOOGLE_INTERNAL_FUNCTIONS_FRAMEWORK_INJECTED"
+
 	// VendorPipDepsEnv is the envar used to opt using vendored pip dependencies
 	VendorPipDepsEnv = "GOOGLE_VENDOR_PIP_DEPENDENCIES"
 
+	// TrustedHostsEnv is a space-separated list of hosts, such as private PyPI mirrors served over
+	// HTTP, that pip trusts even though they do not have a valid HTTPS certificate.
+	TrustedHostsEnv = "GOOGLE_PIP_TRUSTED_HOSTS"
+
+	// ImplementationEnv is the env var used to select the Python implementation to install, cpython
+	// (default) or pypy.
+	ImplementationEnv = "GOOGLE_PYTHON_IMPLEMENTATION"
+	// CPython is the reference implementation of Python.
+	CPython = "cpython"
+	// PyPy is the PyPy implementation of Python.
+	PyPy = "pypy"
+
+	// PyPyVersionEnv is the env var used to select the PyPy release installed when
+	// GOOGLE_PYTHON_IMPLEMENTATION is pypy, e.g. 3.10-v7.3.12 for PyPy 7.3.12 implementing Python 3.10.
+	PyPyVersionEnv = "GOOGLE_PYPY_VERSION"
+	// defaultPyPyVersion is the PyPy release installed when GOOGLE_PYPY_VERSION is not set.
+	defaultPyPyVersion = "3.10-v7.3.12"
+
 	versionFile = ".python-version"
 	versionKey  = "version"
 	versionEnv  = "GOOGLE_PYTHON_VERSION"
@@ -59,6 +83,9 @@ const (
 )
 
 var (
+	// trustedHostRegexp matches a hostname without a port or protocol.
+	trustedHostRegexp = regexp.MustCompile(`^[a-zA-Z0-9.-]+$`)
+
 	// RequirementsProvides denotes that the buildpack provides requirements.txt in the environment.
 	RequirementsProvides = []libcnb.BuildPlanProvide{{Name: "requirements.txt"}}
 	// RequirementsRequires denotes that the buildpack consumes requirements.txt from the environment.
@@ -78,8 +105,30 @@ func Version(ctx *gcp.Context) (string, error) {
 	return strings.TrimSpace(result.Stdout), nil
 }
 
+// Implementation returns the Python implementation selected with GOOGLE_PYTHON_IMPLEMENTATION,
+// CPython by default.
+func Implementation() (string, error) {
+	switch impl := strings.ToLower(strings.TrimSpace(os.Getenv(ImplementationEnv))); impl {
+	case "", CPython:
+		return CPython, nil
+	case PyPy:
+		return PyPy, nil
+	default:
+		return "", gcp.UserErrorf("invalid %s %q, must be %s or %s", ImplementationEnv, impl, CPython, PyPy)
+	}
+}
+
+// PyPyVersion returns the PyPy release to install, set by GOOGLE_PYPY_VERSION.
+func PyPyVersion(ctx *gcp.Context) string {
+	if v := os.Getenv(PyPyVersionEnv); v != "" {
+		ctx.Logf("Using PyPy version from %s: %s", PyPyVersionEnv, v)
+		return v
+	}
+	return defaultPyPyVersion
+}
+
 // RuntimeVersion validate and returns the customer requested Python version by inspecting the
-// environment variables and .python-version file.
+// environment variables, the .python-version file and the .tool-versions file.
 func RuntimeVersion(ctx *gcp.Context, dir string) (string, error) {
 	if v := os.Getenv(env.Runtime); v != "" && !strings.HasPrefix(v, "python") {
 		return "*", nil
@@ -100,6 +149,14 @@ func RuntimeVersion(ctx *gcp.Context, dir string) (string, error) {
 	if v != "" {
 		return v, nil
 	}
+	v, err = toolversions.Version(ctx, toolversions.Python)
+	if err != nil {
+		return "", err
+	}
+	if v != "" {
+		ctx.Logf("Using Python version from %s: %s", toolversions.File, v)
+		return v, nil
+	}
 
 	// This will use the highest listed at https://dl.google.com/runtimes/python/version.json.
 	ctx.Logf("Python version not specified, using the latest available version.")
@@ -108,6 +165,16 @@ func RuntimeVersion(ctx *gcp.Context, dir string) (string, error) {
 
 func versionFromFile(ctx *gcp.Context, dir string) (string, error) {
 	vf := filepath.Join(dir, versionFile)
+	if dir == ctx.ApplicationRoot() {
+		// A .python-version file at the root of a monorepo applies to all of its applications.
+		found, err := ctx.FindInWorkspace(versionFile)
+		if err != nil {
+			return "", err
+		}
+		if found != "" {
+			vf = found
+		}
+	}
 	versionFileExists, err := ctx.FileExists(vf)
 	if err != nil {
 		return "", err
@@ -146,13 +213,24 @@ func InstallRequirements(ctx *gcp.Context, l *libcnb.Layer, reqs ...string) erro
 		return nil
 	}
 
+	trustedHostFlags, err := trustedHostFlags(ctx)
+	if err != nil {
+		return err
+	}
+	indexes, err := pipIndexes(ctx)
+	if err != nil {
+		return err
+	}
+
 	currentPythonVersion, err := Version(ctx)
 	if err != nil {
 		return err
 	}
+	// The index hosts are part of the key so that switching to a private index reinstalls the
+	// dependencies, without invalidating the caches of the builds that do not set one.
 	hash, cached, err := cache.HashAndCheck(ctx, l, dependencyHashKey,
 		cache.WithFiles(reqs...),
-		cache.WithStrings(currentPythonVersion))
+		cache.WithStrings(append([]string{currentPythonVersion}, indexHosts(indexes)...)...))
 	if err != nil {
 		return err
 	}
@@ -181,6 +259,15 @@ func InstallRequirements(ctx *gcp.Context, l *libcnb.Layer, reqs ...string) erro
 	if err := ar.GeneratePythonConfig(ctx); err != nil {
 		return fmt.Errorf("generating Artifact Registry credentials: %w", err)
 	}
+	// The index credentials are passed to pip in a netrc file outside of the layers, so that they are
+	// neither logged nor part of the image.
+	netrcEnv, netrcDir, err := writeIndexNetrc(ctx, indexes)
+	if err != nil {
+		return fmt.Errorf("writing package index credentials: %w", err)
+	}
+	if netrcDir != "" {
+		defer ctx.RemoveAll(netrcDir)
+	}
 
 	// History of the logic below:
 	//
@@ -243,11 +330,15 @@ func InstallRequirements(ctx *gcp.Context, l *libcnb.Layer, reqs ...string) erro
 		if isVendored {
 			cmd = append(cmd, "--no-index", "--find-links", vendorDir)
 			buildermetrics.GlobalBuilderMetrics().GetCounter(buildermetrics.PipVendorDependenciesCounterID).Increment(1)
+		} else {
+			cmd = append(cmd, indexFlags(indexes)...)
 		}
+		cmd = append(cmd, trustedHostFlags...)
 		if !virtualEnv {
 			cmd = append(cmd, "--user") // Install into user site-packages directory.
 		}
 		if _, err := ctx.Exec(cmd,
+			gcp.WithEnv(netrcEnv...),
 			gcp.WithUserAttribution); err != nil {
 			return err
 		}
@@ -276,6 +367,23 @@ func InstallRequirements(ctx *gcp.Context, l *libcnb.Layer, reqs ...string) erro
 	return nil
 }
 
+// trustedHostFlags returns the pip flags that trust the hosts listed in GOOGLE_PIP_TRUSTED_HOSTS.
+func trustedHostFlags(ctx *gcp.Context) ([]string, error) {
+	hosts := strings.Fields(os.Getenv(TrustedHostsEnv))
+	if len(hosts) == 0 {
+		return nil, nil
+	}
+	var flags []string
+	for _, host := range hosts {
+		if !trustedHostRegexp.MatchString(host) {
+			return nil, gcp.UserErrorf("invalid host %q in %s: specify hostnames separated by spaces, without a protocol or port", host, TrustedHostsEnv)
+		}
+		flags = append(flags, "--trusted-host", host)
+	}
+	ctx.Warnf("pip does not verify the SSL certificates of the hosts in %s (%s), only use it with internal package mirrors.", TrustedHostsEnv, strings.Join(hosts, " "))
+	return flags, nil
+}
+
 // cacheExpired returns true when the cache is past expiration.
 func cacheExpired(ctx *gcp.Context, l *libcnb.Layer) bool {
 	t := time.Now()
@@ -290,6 +398,19 @@ func cacheExpired(ctx *gcp.Context, l *libcnb.Layer) bool {
 	return !t.After(time.Now())
 }
 
+// DependencyEnv returns the environment variables that make the dependencies installed in l by
+// InstallRequirements importable by commands run later in the same buildpack, including when the
+// layer was restored from the cache.
+func DependencyEnv(l *libcnb.Layer) []string {
+	if requiresVirtualEnv() {
+		return []string{
+			"VIRTUAL_ENV=" + l.Path,
+			"PATH=" + filepath.Join(l.Path, "bin") + string(os.PathListSeparator) + os.Getenv("PATH"),
+		}
+	}
+	return []string{"PYTHONUSERBASE=" + l.Path}
+}
+
 // requiresVirtualEnv returns true for runtimes that require a virtual environment to be created before pip install.
 // We cannot use Python per-user site-packages (https://www.python.org/dev/peps/pep-0370/),
 // because Python 3.7 and 3.8 on App Engine and Cloud Functions have a virtualenv set up
@@ -19,6 +19,7 @@ import (
 	"testing"
 
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/google/go-cmp/cmp"
 )
 
 func TestRuntimeVersion(t *testing.T) {
@@ -27,8 +28,11 @@ func TestRuntimeVersion(t *testing.T) {
 		version        string
 		runtimeVersion string
 		versionFile    string
-		want           string
-		wantErr        bool
+		// workspaceVersionFile is written to the workspace root, the parent of the application root.
+		workspaceVersionFile string
+		toolVersions         string
+		want                 string
+		wantErr              bool
 	}{
 		{
 			name: "default to *",
@@ -66,12 +70,38 @@ func TestRuntimeVersion(t *testing.T) {
 			versionFile:    "3.8.1",
 			want:           "3.8.0",
 		},
+		{
+			name:                 "version from workspace .python-version file",
+			workspaceVersionFile: "3.9.0",
+			want:                 "3.9.0",
+		},
+		{
+			name:                 "application .python-version take precedence over workspace",
+			versionFile:          "3.8.0",
+			workspaceVersionFile: "3.9.0",
+			want:                 "3.8.0",
+		},
+		{
+			name:         "version from .tool-versions",
+			toolVersions: "nodejs 20.11.0\npython 3.12.1 3.11.7\nruby 3.3.0\n",
+			want:         "3.12.1",
+		},
+		{
+			name:         ".python-version take precedence over .tool-versions",
+			versionFile:  "3.8.0",
+			toolVersions: "python 3.12.1\n",
+			want:         "3.8.0",
+		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			dir := t.TempDir()
-			ctx := gcp.NewContext(gcp.WithApplicationRoot(dir))
+			workspace := t.TempDir()
+			dir := filepath.Join(workspace, "app")
+			if err := os.Mkdir(dir, 0755); err != nil {
+				t.Fatalf("creating dir %q: %v", dir, err)
+			}
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(dir), gcp.WithWorkspaceRoot(workspace))
 
 			if tc.version != "" {
 				t.Setenv("GOOGLE_PYTHON_VERSION", tc.version)
@@ -85,6 +115,19 @@ func TestRuntimeVersion(t *testing.T) {
 					t.Fatalf("writing file %q: %v", versionFile, err)
 				}
 			}
+			if tc.workspaceVersionFile != "" {
+				versionFile := filepath.Join(workspace, ".python-version")
+				if err := os.WriteFile(versionFile, []byte(tc.workspaceVersionFile), os.FileMode(0744)); err != nil {
+					t.Fatalf("writing file %q: %v", versionFile, err)
+				}
+			}
+
+			if tc.toolVersions != "" {
+				toolVersions := filepath.Join(dir, ".tool-versions")
+				if err := os.WriteFile(toolVersions, []byte(tc.toolVersions), os.FileMode(0744)); err != nil {
+					t.Fatalf("writing file %q: %v", toolVersions, err)
+				}
+			}
 
 			got, err := RuntimeVersion(ctx, dir)
 			if tc.wantErr == (err == nil) {
@@ -96,3 +139,76 @@ func TestRuntimeVersion(t *testing.T) {
 		})
 	}
 }
+
+func TestImplementation(t *testing.T) {
+	testCases := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{name: "default", want: CPython},
+		{name: "cpython", value: "cpython", want: CPython},
+		{name: "pypy", value: "PyPy", want: PyPy},
+		{name: "unknown", value: "jython", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(ImplementationEnv, tc.value)
+
+			got, err := Implementation()
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("Implementation() got error: %v, want error: %v", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("Implementation() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestTrustedHostFlags(t *testing.T) {
+	testCases := []struct {
+		name    string
+		hosts   string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "not set",
+		},
+		{
+			name:  "single host",
+			hosts: "pypi.internal.example.com",
+			want:  []string{"--trusted-host", "pypi.internal.example.com"},
+		},
+		{
+			name:  "multiple hosts",
+			hosts: " mirror-1.corp  10.0.0.12 ",
+			want:  []string{"--trusted-host", "mirror-1.corp", "--trusted-host", "10.0.0.12"},
+		},
+		{
+			name:    "with port",
+			hosts:   "mirror.corp:8080",
+			wantErr: true,
+		},
+		{
+			name:    "with protocol",
+			hosts:   "mirror.corp http://other.corp",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(TrustedHostsEnv, tc.hosts)
+
+			got, err := trustedHostFlags(gcp.NewContext())
+			if tc.wantErr == (err == nil) {
+				t.Errorf("trustedHostFlags() got error: %v, want err? %t", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("trustedHostFlags() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -25,6 +25,7 @@ import (
 	"github.com/Masterminds/semver"
 
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/toolversions"
 )
 
 const defaultVersion = "3.2.*"
@@ -32,8 +33,8 @@ const defaultVersion = "3.2.*"
 // RubyVersionKey is the environment variable name used to store the Ruby version installed.
 const RubyVersionKey = "build_ruby_version"
 
-// DetectVersion detects ruby version from the environment, Gemfile.lock, gems.locked, or falls
-// back to a default version.
+// DetectVersion detects ruby version from the environment, .ruby-version, Gemfile.lock,
+// gems.locked, .tool-versions, or falls back to a default version.
 func DetectVersion(ctx *gcp.Context) (string, error) {
 	versionFromEnv := os.Getenv(env.RuntimeVersion)
 	// The two lock files have the same format for Ruby version
@@ -106,6 +107,14 @@ func DetectVersion(ctx *gcp.Context) (string, error) {
 			"Using runtime version from .ruby-version file: %s", versionFromRubyVersion)
 		return versionFromRubyVersion, nil
 	}
+	versionFromToolVersions, err := toolversions.Version(ctx, toolversions.Ruby)
+	if err != nil {
+		return "", err
+	}
+	if versionFromToolVersions != "" {
+		ctx.Logf("Using runtime version from %s file: %s", toolversions.File, versionFromToolVersions)
+		return versionFromToolVersions, nil
+	}
 
 	return defaultVersion, nil
 }
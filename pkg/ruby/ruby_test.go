@@ -168,6 +168,30 @@ RUBY VERSION
 			},
 			want: "3.2.2",
 		},
+		{
+			name: ".tool-versions is present",
+			lockFiles: []lockFile{
+				lockFile{
+					name:    ".tool-versions",
+					content: "nodejs 20.11.0\nruby 3.3.0 # preferred\npython 3.12.1\n",
+				},
+			},
+			want: "3.3.0",
+		},
+		{
+			name: "both .ruby-version and .tool-versions are present",
+			lockFiles: []lockFile{
+				lockFile{
+					name:    ".ruby-version",
+					content: `3.2.2`,
+				},
+				lockFile{
+					name:    ".tool-versions",
+					content: "ruby 3.3.0\n",
+				},
+			},
+			want: "3.2.2",
+		},
 	}
 
 	for _, tc := range testCases {
@@ -0,0 +1,257 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/Masterminds/semver"
+)
+
+const (
+	eolDateFormat = "2006-01-02"
+	// eolWarningPeriod is how long before the end of life date a warning is emitted.
+	eolWarningPeriod = 30 * 24 * time.Hour
+	// defaultMigrationURL documents the supported runtime versions and how to upgrade.
+	defaultMigrationURL = "https://cloud.google.com/run/docs/runtime-support"
+	// eolLabel is the image label set to the end of life date of the runtime version, so that images
+	// built on runtimes that are past or near their end of life can be found.
+	eolLabel = "runtime-eol"
+	// decommissionLabel is the image label set to the decommission date of the runtime version.
+	decommissionLabel = "runtime-decommission"
+)
+
+// eolSchedule is the support schedule of a runtime version.
+type eolSchedule struct {
+	// Deprecation is the end of life date, after which the version no longer receives security updates.
+	Deprecation string `json:"deprecation"`
+	// Decommission is the date from which builds that use the version fail. It is optional.
+	Decommission string `json:"decommission,omitempty"`
+	// Migration is a link to the upgrade instructions. It defaults to defaultMigrationURL.
+	Migration string `json:"migration,omitempty"`
+}
+
+var (
+	// eolDates contains the upstream end of life dates of runtime versions. Versions are keyed by
+	// major version for runtimes in majorVersionRuntimes and by major.minor version otherwise.
+	eolDates = map[InstallableRuntime]map[string]string{
+		Nodejs: {
+			"10": "2021-04-30",
+			"12": "2022-04-30",
+			"14": "2023-04-30",
+			"16": "2023-09-11",
+			"18": "2025-04-30",
+			"20": "2026-04-30",
+			"22": "2027-04-30",
+			"24": "2028-04-30",
+		},
+		Python: {
+			"3.7":  "2023-06-27",
+			"3.8":  "2024-10-07",
+			"3.9":  "2025-10-31",
+			"3.10": "2026-10-31",
+			"3.11": "2027-10-31",
+			"3.12": "2028-10-31",
+			"3.13": "2029-10-31",
+		},
+		Ruby: {
+			"2.6": "2022-04-12",
+			"2.7": "2023-03-31",
+			"3.0": "2024-04-23",
+			"3.1": "2025-03-26",
+			"3.2": "2026-03-31",
+			"3.3": "2027-03-31",
+			"3.4": "2028-03-31",
+		},
+		PHP: {
+			"7.4": "2022-11-28",
+			"8.0": "2023-11-26",
+			"8.1": "2025-12-31",
+			"8.2": "2026-12-31",
+			"8.3": "2027-12-31",
+			"8.4": "2028-12-31",
+		},
+		Go: {
+			"1.18": "2023-02-01",
+			"1.19": "2023-08-08",
+			"1.20": "2024-02-06",
+			"1.21": "2024-08-13",
+			"1.22": "2025-02-11",
+			"1.23": "2025-08-12",
+			"1.24": "2026-02-10",
+			"1.25": "2026-08-11",
+		},
+		OpenJDK:      javaEOLDates,
+		CanonicalJDK: javaEOLDates,
+		DotnetSDK: {
+			"3": "2022-12-13",
+			"5": "2022-05-10",
+			"6": "2024-11-12",
+			"7": "2024-05-14",
+			"8": "2026-11-10",
+			"9": "2026-11-10",
+		},
+		AspNetCore: {
+			"3": "2022-12-13",
+			"5": "2022-05-10",
+			"6": "2024-11-12",
+			"7": "2024-05-14",
+			"8": "2026-11-10",
+			"9": "2026-11-10",
+		},
+	}
+
+	// javaEOLDates are the end of life dates of Java versions. LTS versions are supported for years,
+	// while the other versions reach their end of life when the next version is released.
+	javaEOLDates = map[string]string{
+		"8":  "2030-12-31",
+		"11": "2027-10-31",
+		"12": "2019-09-17",
+		"13": "2020-03-17",
+		"14": "2020-09-15",
+		"15": "2021-03-16",
+		"16": "2021-09-14",
+		"17": "2027-10-31",
+		"18": "2022-09-20",
+		"19": "2023-03-21",
+		"20": "2023-09-19",
+		"21": "2029-12-31",
+		"22": "2024-09-17",
+		"23": "2025-03-18",
+		"24": "2025-09-16",
+		"25": "2031-09-30",
+	}
+
+	majorVersionRuntimes = map[InstallableRuntime]bool{
+		Nodejs:       true,
+		DotnetSDK:    true,
+		AspNetCore:   true,
+		OpenJDK:      true,
+		CanonicalJDK: true,
+	}
+
+	// now returns the current time. It can be overridden for testing.
+	now = time.Now
+)
+
+// CheckEOL warns if the given version of a runtime is past or within 30 days of its end of life, and
+// records the date in an image label. A version past its end of life fails the build when
+// GOOGLE_DISALLOW_EOL_RUNTIME is true, and a version past its decommission date always does. The
+// schedule of the builder manifest set by X_GOOGLE_RUNTIME_EOL_MANIFEST takes precedence over the
+// built-in end of life dates. Runtimes and versions without a known schedule are ignored.
+func CheckEOL(ctx *gcp.Context, runtime InstallableRuntime, version string) error {
+	v, err := semver.NewVersion(version)
+	if err != nil {
+		// Versions that are not semver, e.g. release candidates, are not covered by the table.
+		return nil
+	}
+	key := fmt.Sprintf("%d.%d", v.Major(), v.Minor())
+	if majorVersionRuntimes[runtime] {
+		key = fmt.Sprintf("%d", v.Major())
+	}
+	schedule, ok, err := eolScheduleFor(runtime, key)
+	if err != nil || !ok {
+		return err
+	}
+	eol, err := time.Parse(eolDateFormat, schedule.Deprecation)
+	if err != nil {
+		return gcp.InternalErrorf("parsing end of life date %q of %s %s: %v", schedule.Deprecation, runtime, key, err)
+	}
+	migration := schedule.Migration
+	if migration == "" {
+		migration = defaultMigrationURL
+	}
+
+	t := now()
+	if schedule.Decommission != "" {
+		decommission, err := time.Parse(eolDateFormat, schedule.Decommission)
+		if err != nil {
+			return gcp.InternalErrorf("parsing decommission date %q of %s %s: %v", schedule.Decommission, runtime, key, err)
+		}
+		if !t.Before(decommission) {
+			return gcp.UserErrorf("%s %s was decommissioned on %s and can no longer be used to build applications. Please upgrade to a newer version, see %s.", runtime, key, schedule.Decommission, migration)
+		}
+	}
+	if t.Before(eol.Add(-eolWarningPeriod)) {
+		return nil
+	}
+	if t.Before(eol) {
+		addEOLLabels(ctx, schedule)
+		ctx.Warnf("%s %s will reach end of life on %s and will no longer receive security updates. Please upgrade to a newer version, see %s.", runtime, key, schedule.Deprecation, migration)
+		return nil
+	}
+	disallow, err := env.IsPresentAndTrue(env.DisallowEOLRuntime)
+	if err != nil {
+		return err
+	}
+	if disallow {
+		return gcp.UserErrorf("%s %s reached end of life on %s and %s is set. Please upgrade to a newer version, see %s.", runtime, key, schedule.Deprecation, env.DisallowEOLRuntime, migration)
+	}
+	addEOLLabels(ctx, schedule)
+	decommissionNote := ""
+	if schedule.Decommission != "" {
+		decommissionNote = fmt.Sprintf(" Builds will fail from %s.", schedule.Decommission)
+	}
+	ctx.Warnf("%s %s reached end of life on %s and no longer receives security updates.%s Please upgrade to a newer version, see %s. Set %s=true to fail builds that use end of life runtimes.", runtime, key, schedule.Deprecation, decommissionNote, migration, env.DisallowEOLRuntime)
+	return nil
+}
+
+// addEOLLabels records the end of life and decommission dates of a runtime version in image labels.
+func addEOLLabels(ctx *gcp.Context, schedule eolSchedule) {
+	ctx.AddLabel(eolLabel, schedule.Deprecation)
+	if schedule.Decommission != "" {
+		ctx.AddLabel(decommissionLabel, schedule.Decommission)
+	}
+}
+
+// eolScheduleFor returns the support schedule of the given runtime version, keyed like eolDates,
+// from the builder manifest if it lists the version and from eolDates otherwise.
+func eolScheduleFor(runtime InstallableRuntime, key string) (eolSchedule, bool, error) {
+	manifest, err := readEOLManifest()
+	if err != nil {
+		return eolSchedule{}, false, err
+	}
+	if schedule, ok := manifest[string(runtime)][key]; ok {
+		return schedule, true, nil
+	}
+	if date, ok := eolDates[runtime][key]; ok {
+		return eolSchedule{Deprecation: date}, true, nil
+	}
+	return eolSchedule{}, false, nil
+}
+
+// readEOLManifest reads the builder manifest set by X_GOOGLE_RUNTIME_EOL_MANIFEST, which maps
+// runtime names, e.g. "nodejs", to the schedules of their versions, keyed like eolDates, e.g.
+// {"nodejs": {"16": {"deprecation": "2023-09-11", "decommission": "2025-01-30"}}}.
+func readEOLManifest() (map[string]map[string]eolSchedule, error) {
+	path := os.Getenv(env.XGoogleRuntimeEOLManifest)
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, gcp.InternalErrorf("reading runtime end of life manifest %s: %w", path, err)
+	}
+	var manifest map[string]map[string]eolSchedule
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, gcp.InternalErrorf("parsing runtime end of life manifest %s: %w", path, err)
+	}
+	return manifest, nil
+}
@@ -0,0 +1,231 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+func TestCheckEOL(t *testing.T) {
+	testCases := []struct {
+		name     string
+		runtime  InstallableRuntime
+		version  string
+		now      string
+		disallow bool
+		manifest string
+		wantLog  string
+		wantErr  bool
+	}{
+		{
+			name:    "before eol",
+			runtime: Nodejs,
+			version: "20.11.1",
+			now:     "2025-01-01",
+		},
+		{
+			name:    "near eol",
+			runtime: Nodejs,
+			version: "20.11.1",
+			now:     "2026-04-15",
+			wantLog: "nodejs 20 will reach end of life on 2026-04-30",
+		},
+		{
+			name:    "past eol",
+			runtime: Python,
+			version: "3.7.17",
+			now:     "2025-01-01",
+			wantLog: "python 3.7 reached end of life on 2023-06-27",
+		},
+		{
+			name:    "past eol label",
+			runtime: Python,
+			version: "3.7.17",
+			now:     "2025-01-01",
+			wantLog: "Adding image label google.runtime-eol: 2023-06-27",
+		},
+		{
+			name:    "past eol migration link",
+			runtime: Python,
+			version: "3.7.17",
+			now:     "2025-01-01",
+			wantLog: "see https://cloud.google.com/run/docs/runtime-support",
+		},
+		{
+			name:     "past eol disallowed",
+			runtime:  Python,
+			version:  "3.7.17",
+			now:      "2025-01-01",
+			disallow: true,
+			wantErr:  true,
+		},
+		{
+			name:     "near eol disallowed",
+			runtime:  PHP,
+			version:  "8.1.27",
+			now:      "2025-12-15",
+			disallow: true,
+			wantLog:  "php 8.1 will reach end of life on 2025-12-31",
+		},
+		{
+			name:    "major version runtime",
+			runtime: DotnetSDK,
+			version: "6.0.428",
+			now:     "2025-01-01",
+			wantLog: "dotnetsdk 6 reached end of life on 2024-11-12",
+		},
+		{
+			name:    "java lts before eol",
+			runtime: OpenJDK,
+			version: "21.0.2+13",
+			now:     "2025-01-01",
+		},
+		{
+			name:    "java lts near eol",
+			runtime: OpenJDK,
+			version: "11.0.22+7",
+			now:     "2027-10-15",
+			wantLog: "openjdk 11 will reach end of life on 2027-10-31",
+		},
+		{
+			name:    "java non-lts past eol",
+			runtime: CanonicalJDK,
+			version: "22.0.1",
+			now:     "2025-01-01",
+			wantLog: "canonicaljdk 22 reached end of life on 2024-09-17",
+		},
+		{
+			name:    "go before eol",
+			runtime: Go,
+			version: "1.25.1",
+			now:     "2025-10-01",
+		},
+		{
+			name:    "go past eol",
+			runtime: Go,
+			version: "1.21.13",
+			now:     "2025-01-01",
+			wantLog: "go 1.21 reached end of life on 2024-08-13",
+		},
+		{
+			name:    "unknown version",
+			runtime: Ruby,
+			version: "1.9.3",
+			now:     "2025-01-01",
+		},
+		{
+			name:    "unknown runtime",
+			runtime: Nginx,
+			version: "1.21.6",
+			now:     "2025-01-01",
+		},
+		{
+			name:     "manifest overrides eol date",
+			runtime:  Nodejs,
+			version:  "22.1.0",
+			now:      "2025-01-01",
+			manifest: `{"nodejs": {"22": {"deprecation": "2024-12-01"}}}`,
+			wantLog:  "nodejs 22 reached end of life on 2024-12-01",
+		},
+		{
+			name:     "manifest decommission in the future",
+			runtime:  Nodejs,
+			version:  "16.20.2",
+			now:      "2025-01-01",
+			manifest: `{"nodejs": {"16": {"deprecation": "2023-09-11", "decommission": "2025-06-30"}}}`,
+			wantLog:  "Builds will fail from 2025-06-30",
+		},
+		{
+			name:     "manifest decommission label",
+			runtime:  Nodejs,
+			version:  "16.20.2",
+			now:      "2025-01-01",
+			manifest: `{"nodejs": {"16": {"deprecation": "2023-09-11", "decommission": "2025-06-30"}}}`,
+			wantLog:  "Adding image label google.runtime-decommission: 2025-06-30",
+		},
+		{
+			name:     "manifest decommissioned",
+			runtime:  Nodejs,
+			version:  "16.20.2",
+			now:      "2025-07-01",
+			manifest: `{"nodejs": {"16": {"deprecation": "2023-09-11", "decommission": "2025-06-30"}}}`,
+			wantErr:  true,
+		},
+		{
+			name:     "manifest migration link",
+			runtime:  OpenJDK,
+			version:  "11.0.2",
+			now:      "2025-01-01",
+			manifest: `{"openjdk": {"11": {"deprecation": "2024-10-31", "migration": "https://example.com/java"}}}`,
+			wantLog:  "see https://example.com/java",
+		},
+		{
+			name:     "invalid manifest",
+			runtime:  Nodejs,
+			version:  "20.11.1",
+			now:      "2025-01-01",
+			manifest: `{"nodejs": `,
+			wantErr:  true,
+		},
+		{
+			name:    "not semver",
+			runtime: PHP,
+			version: "8.3.0RC4",
+			now:     "2030-01-01",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			defer func(fn func() time.Time) { now = fn }(now)
+			fakeNow, err := time.Parse(eolDateFormat, tc.now)
+			if err != nil {
+				t.Fatalf("parsing %q: %v", tc.now, err)
+			}
+			now = func() time.Time { return fakeNow }
+			if tc.disallow {
+				t.Setenv(env.DisallowEOLRuntime, "true")
+			}
+			if tc.manifest != "" {
+				path := filepath.Join(t.TempDir(), "eol.json")
+				if err := os.WriteFile(path, []byte(tc.manifest), 0644); err != nil {
+					t.Fatalf("writing manifest: %v", err)
+				}
+				t.Setenv(env.XGoogleRuntimeEOLManifest, path)
+			}
+			var buf bytes.Buffer
+			ctx := gcp.NewContext(gcp.WithLogger(log.New(&buf, "", 0)))
+
+			err = CheckEOL(ctx, tc.runtime, tc.version)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("CheckEOL(%q, %q) got error: %v, want error: %v", tc.runtime, tc.version, err, tc.wantErr)
+			}
+			if tc.wantLog == "" && buf.Len() != 0 {
+				t.Errorf("CheckEOL(%q, %q) logged %q, want no output", tc.runtime, tc.version, buf.String())
+			}
+			if !strings.Contains(buf.String(), tc.wantLog) {
+				t.Errorf("CheckEOL(%q, %q) logged %q, want output containing %q", tc.runtime, tc.version, buf.String(), tc.wantLog)
+			}
+		})
+	}
+}
@@ -15,11 +15,13 @@
 package runtime
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
+	"regexp"
 	"slices"
 	"strings"
 
@@ -38,10 +40,22 @@ var (
 	runtimeVersionsURL = "https://dl.google.com/runtimes/%s/%s/version.json"
 	// goTarballURL is the location from which we download Go. This is different from other runtimes
 	// because the Go team already provides re-built tarballs on the same CDN.
-	goTarballURL          = "https://dl.google.com/go/go%s.linux-amd64.tar.gz"
+	goTarballURL = "https://dl.google.com/go/go%s.linux-amd64.tar.gz"
+	// pypyTarballURL is the location from which we download PyPy, which is only published by the
+	// PyPy project. The version combines the Python and PyPy versions, e.g. 3.10-v7.3.12.
+	pypyTarballURL = "https://downloads.python.org/pypy/pypy%s-linux64.tar.bz2"
+	// goChecksumURL contains only the checksum of a single Go tarball. pypyChecksumURL is the page
+	// on which PyPy publishes the checksums of its releases in the format written by sha256sum. The
+	// tarballs on dl.google.com carry their checksum in the sha256 metadata of the object instead.
+	goChecksumURL   = "https://dl.google.com/go/go%s.linux-amd64.tar.gz.sha256"
+	pypyChecksumURL = "https://www.pypy.org/checksums.html"
+	// htmlTagRegexp matches the tags around the checksums on pypyChecksumURL.
+	htmlTagRegexp         = regexp.MustCompile(`<[^>]*>`)
 	runtimeImageARURL     = "%s-docker.pkg.dev/gae-runtimes/runtimes-%s/%s:%s"
 	runtimeImageARRepoURL = "%s-docker.pkg.dev/gae-runtimes/runtimes-%s/%s"
 	fallbackRegion        = "us"
+	// pypyVersionRegexp matches a PyPy release, e.g. 3.10-v7.3.12.
+	pypyVersionRegexp = regexp.MustCompile(`^[0-9]+\.[0-9]+-v[0-9]+\.[0-9]+\.[0-9]+$`)
 )
 
 // InstallableRuntime is used to hold runtimes information
@@ -60,16 +74,31 @@ const (
 	OpenJDK      InstallableRuntime = "openjdk"
 	CanonicalJDK InstallableRuntime = "canonicaljdk"
 	Go           InstallableRuntime = "go"
+	PyPy         InstallableRuntime = "pypy"
 
 	ubuntu1804 string = "ubuntu1804"
 	ubuntu2204 string = "ubuntu2204"
 )
 
+// runtimeLanguages maps language runtimes to the language reported in the build report.
+var runtimeLanguages = map[InstallableRuntime]string{
+	Nodejs:       "nodejs",
+	PHP:          "php",
+	Python:       "python",
+	PyPy:         "python",
+	Ruby:         "ruby",
+	OpenJDK:      "java",
+	CanonicalJDK: "java",
+	Go:           "go",
+	DotnetSDK:    "dotnet",
+}
+
 // User friendly display name of all runtime (e.g. for use in error message).
 var runtimeNames = map[InstallableRuntime]string{
 	Nodejs:    "Node.js",
 	PHP:       "PHP Runtime",
 	Python:    "Python",
+	PyPy:      "PyPy",
 	Ruby:      "Ruby Runtime",
 	Nginx:     "Nginx Web Server",
 	Pid1:      "Pid1",
@@ -172,6 +201,13 @@ func InstallTarballIfNotCached(ctx *gcp.Context, runtime InstallableRuntime, ver
 		return false, err
 	}
 
+	if err = CheckEOL(ctx, runtime, version); err != nil {
+		return false, err
+	}
+	if language, ok := runtimeLanguages[runtime]; ok {
+		ctx.ReportRuntime(language, version)
+	}
+
 	if layer.Cache {
 		if IsCached(ctx, layer, version) {
 			ctx.CacheHit(runtimeID)
@@ -188,11 +224,11 @@ func InstallTarballIfNotCached(ctx *gcp.Context, runtime InstallableRuntime, ver
 
 	runtimeURL := tarballDownloadURL(runtime, osName, version)
 	stripComponents := 0
-	if runtime == OpenJDK || runtime == Go {
+	if runtime == OpenJDK || runtime == Go || runtime == PyPy {
 		stripComponents = 1
 	}
 	region, present := os.LookupEnv(env.RuntimeImageRegion)
-	if present && runtime != Go {
+	if present && runtime != Go && runtime != PyPy {
 		url := runtimeImageURL(runtime, osName, version, region)
 		fallbackURL := runtimeImageURL(runtime, osName, version, fallbackRegion)
 		if err := fetch.ARImage(url, fallbackURL, layer.Path, stripComponents, ctx); err != nil {
@@ -200,7 +236,16 @@ func InstallTarballIfNotCached(ctx *gcp.Context, runtime InstallableRuntime, ver
 			return false, err
 		}
 	} else {
-		if err := fetch.Tarball(runtimeURL, layer.Path, stripComponents); err != nil {
+		checksum, err := tarballChecksum(ctx, runtime, runtimeURL, version)
+		if err != nil {
+			return false, err
+		}
+		if checksum == "" {
+			err = fetch.Tarball(runtimeURL, layer.Path, stripComponents)
+		} else {
+			err = fetch.TarballWithSHA256(runtimeURL, layer.Path, stripComponents, checksum)
+		}
+		if err != nil {
 			ctx.Warnf("Failed to download %s version %s osName %s from lorry. You can specify the version by setting the GOOGLE_RUNTIME_VERSION environment variable", runtimeName, version, osName)
 			return false, err
 		}
@@ -222,12 +267,85 @@ func runtimeImageURL(runtime InstallableRuntime, osName, version, region string)
 }
 
 func tarballDownloadURL(runtime InstallableRuntime, os, version string) string {
-	if runtime == Go {
+	switch runtime {
+	case Go:
 		return fmt.Sprintf(goTarballURL, version)
+	case PyPy:
+		return fmt.Sprintf(pypyTarballURL, version)
 	}
 	return fmt.Sprintf(googleTarballURL, os, runtime, strings.ReplaceAll(version, "+", "_"))
 }
 
+// tarballChecksum returns the published SHA256 checksum of the runtime tarball at url, or an
+// empty string if verification is disabled with GOOGLE_SKIP_RUNTIME_CHECKSUM. It fails if the
+// checksum cannot be fetched, so that an unverified tarball is never installed.
+func tarballChecksum(ctx *gcp.Context, runtime InstallableRuntime, url, version string) (string, error) {
+	skip, err := env.IsPresentAndTrue(env.SkipRuntimeChecksum)
+	if err != nil {
+		return "", err
+	}
+	if skip {
+		ctx.Warnf("Skipping checksum verification of the %s tarball because %s is set.", runtimeNames[runtime], env.SkipRuntimeChecksum)
+		return "", nil
+	}
+	checksum, err := publishedChecksum(runtime, url, version)
+	if err != nil {
+		return "", fmt.Errorf("getting the checksum of the %s tarball, set %s=true to install it without verification: %w", runtimeNames[runtime], env.SkipRuntimeChecksum, err)
+	}
+	return checksum, nil
+}
+
+// publishedChecksum returns the SHA256 checksum published for the runtime tarball at url.
+func publishedChecksum(runtime InstallableRuntime, url, version string) (string, error) {
+	switch runtime {
+	case Go:
+		sumsURL := fmt.Sprintf(goChecksumURL, version)
+		var sums bytes.Buffer
+		if err := fetch.GetURL(sumsURL, &sums); err != nil {
+			return "", err
+		}
+		checksum, ok := singleChecksum(sums.String())
+		if !ok {
+			return "", gcp.InternalErrorf("%s does not contain a single checksum", sumsURL)
+		}
+		return checksum, nil
+	case PyPy:
+		var page bytes.Buffer
+		if err := fetch.GetURL(pypyChecksumURL, &page); err != nil {
+			return "", err
+		}
+		name := fmt.Sprintf("pypy%s-linux64.tar.bz2", version)
+		checksum, ok := parseChecksum(htmlTagRegexp.ReplaceAllString(page.String(), ""), name)
+		if !ok {
+			return "", gcp.InternalErrorf("checksums from %s do not include %s", pypyChecksumURL, name)
+		}
+		return checksum, nil
+	}
+	return fetch.SHA256Metadata(url)
+}
+
+// parseChecksum returns the checksum of the named file from the output of sha256sum, where the
+// file name may be prefixed with "*" in binary mode.
+func parseChecksum(sums, name string) (string, bool) {
+	for _, line := range strings.Split(sums, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return fields[0], true
+		}
+	}
+	return "", false
+}
+
+// singleChecksum returns the checksum of a file published for a single tarball, which contains
+// only the checksum, e.g. goChecksumURL.
+func singleChecksum(sums string) (string, bool) {
+	fields := strings.Fields(sums)
+	if len(fields) != 1 {
+		return "", false
+	}
+	return fields[0], true
+}
+
 // PinGemAndBundlerVersion pins the RubyGems versions for GAE and GCF runtime versions to prevent
 // unexpected behaviors with new versions. This is only expected to be called if the target
 // platform is GAE or GCF.
@@ -295,6 +413,13 @@ func ResolveVersion(ctx *gcp.Context, runtime InstallableRuntime, verConstraint,
 		// Go provides its own version manifest so it has its own version resolution logic.
 		return golang.ResolveGoVersion(verConstraint)
 	}
+	if runtime == PyPy {
+		// PyPy does not publish a version manifest, so the version must be exact.
+		if !pypyVersionRegexp.MatchString(verConstraint) {
+			return "", gcp.UserErrorf("invalid PyPy version %q, it must include the Python and PyPy versions, e.g. 3.10-v7.3.12", verConstraint)
+		}
+		return verConstraint, nil
+	}
 	// Some release candidates do not follow the convention for semver
 	// Specifically php. example - 8.3.0RC4.
 	if IsReleaseCandidate(verConstraint) || version.IsExactSemver(verConstraint) {
@@ -162,6 +162,7 @@ func TestInstallRuby(t *testing.T) {
 				t,
 				testserver.WithStatus(tc.httpStatus),
 				testserver.WithFile(testdata.MustGetPath(tc.responseFile)),
+				testserver.WithHeader("x-goog-meta-sha256", dummyRuntimeSHA256),
 				testserver.WithMockURL(&googleTarballURL))
 
 			// stub the version manifest
@@ -292,6 +293,7 @@ func TestInstallSource(t *testing.T) {
 				t,
 				testserver.WithStatus(tc.httpStatus),
 				testserver.WithFile(testdata.MustGetPath(tc.responseFile)),
+				testserver.WithHeader("x-goog-meta-sha256", dummyRuntimeSHA256),
 				testserver.WithMockURL(&googleTarballURL))
 
 			// stub the version manifest
@@ -359,6 +361,112 @@ func TestInstallSource(t *testing.T) {
 	}
 }
 
+func TestInstallTarballChecksum(t *testing.T) {
+	testCases := []struct {
+		name      string
+		checksum  string
+		envs      map[string]string
+		wantError bool
+	}{
+		{
+			name:     "matching checksum",
+			checksum: dummyRuntimeSHA256,
+		},
+		{
+			name:      "mismatched checksum",
+			checksum:  strings.Repeat("0", 64),
+			wantError: true,
+		},
+		{
+			name:      "checksum not published",
+			wantError: true,
+		},
+		{
+			name: "checksum not published with verification skipped",
+			envs: map[string]string{env.SkipRuntimeChecksum: "true"},
+		},
+		{
+			name:     "mismatched checksum with verification skipped",
+			checksum: strings.Repeat("0", 64),
+			envs:     map[string]string{env.SkipRuntimeChecksum: "true"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := []testserver.Option{
+				testserver.WithFile(testdata.MustGetPath("testdata/dummy-ruby-runtime.tar.gz")),
+				testserver.WithMockURL(&googleTarballURL),
+			}
+			if tc.checksum != "" {
+				opts = append(opts, testserver.WithHeader("x-goog-meta-sha256", tc.checksum))
+			}
+			testserver.New(t, opts...)
+			for k, v := range tc.envs {
+				t.Setenv(k, v)
+			}
+			layer := &libcnb.Layer{
+				Path:     t.TempDir(),
+				Metadata: map[string]interface{}{},
+			}
+			ctx := gcp.NewContext(gcp.WithStackID("google.gae.18"))
+
+			_, err := InstallTarballIfNotCached(ctx, Ruby, "2.2.2", layer)
+			if tc.wantError == (err == nil) {
+				t.Fatalf("InstallTarballIfNotCached(ctx, %q, %q) got error: %v, want error? %v", Ruby, "2.2.2", err, tc.wantError)
+			}
+			fp := filepath.Join(layer.Path, "lib/foo.txt")
+			if _, err := os.Stat(fp); tc.wantError != os.IsNotExist(err) {
+				t.Errorf("os.Stat(%q) got error: %v, want extracted? %v", fp, err, !tc.wantError)
+			}
+		})
+	}
+}
+
+func TestParseChecksum(t *testing.T) {
+	name := "pypy3.10-v7.3.12-linux64.tar.bz2"
+	testCases := []struct {
+		sums   string
+		want   string
+		wantOK bool
+	}{
+		{sums: dummyRuntimeSHA256 + "  " + name + "\n", want: dummyRuntimeSHA256, wantOK: true},
+		{sums: strings.Repeat("0", 64) + "  other.tar.bz2\n" + dummyRuntimeSHA256 + " *" + name + "\n", want: dummyRuntimeSHA256, wantOK: true},
+		{sums: dummyRuntimeSHA256 + "  old/" + name + "\n"},
+		{sums: dummyRuntimeSHA256 + "\n"},
+	}
+	for _, tc := range testCases {
+		got, ok := parseChecksum(tc.sums, name)
+		if got != tc.want || ok != tc.wantOK {
+			t.Errorf("parseChecksum(%q, %q) = (%q, %t), want (%q, %t)", tc.sums, name, got, ok, tc.want, tc.wantOK)
+		}
+	}
+}
+
+func TestSingleChecksum(t *testing.T) {
+	testCases := []struct {
+		sums   string
+		want   string
+		wantOK bool
+	}{
+		{sums: dummyRuntimeSHA256 + "\n", want: dummyRuntimeSHA256, wantOK: true},
+		{sums: dummyRuntimeSHA256 + "  go1.21.5.linux-amd64.tar.gz\n"},
+		{sums: ""},
+	}
+	for _, tc := range testCases {
+		got, ok := singleChecksum(tc.sums)
+		if got != tc.want || ok != tc.wantOK {
+			t.Errorf("singleChecksum(%q) = (%q, %t), want (%q, %t)", tc.sums, got, ok, tc.want, tc.wantOK)
+		}
+	}
+}
+
+const (
+	// dummyRuntimeSHA256 is the SHA256 checksum of testdata/dummy-ruby-runtime.tar.gz.
+	dummyRuntimeSHA256 = "fd9c9c45077d43db68deeaf210401b427efe4634b7a176fa84e9414f3790fa29"
+	// dummyPyPySHA256 is the SHA256 checksum of testdata/dummy-pypy-runtime.tar.bz2.
+	dummyPyPySHA256 = "f799a410008e80317982e3d44643e3392a5e63829b0a1e6ad0eda01212695823"
+)
+
 func TestPinGemAndBundlerVersion(t *testing.T) {
 	testCases := []struct {
 		name         string
@@ -663,3 +771,53 @@ func TestRuntimeMatchesInstallableRuntime(t *testing.T) {
 		}
 	}
 }
+
+func TestInstallPyPy(t *testing.T) {
+	testCases := []struct {
+		name      string
+		version   string
+		wantError bool
+	}{
+		{
+			name:    "bzip2 tarball",
+			version: "3.10-v7.3.12",
+		},
+		{
+			name:      "version without pypy release",
+			version:   "3.10",
+			wantError: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			testserver.New(
+				t,
+				testserver.WithFile(testdata.MustGetPath("testdata/dummy-pypy-runtime.tar.bz2")),
+				testserver.WithMockURL(&pypyTarballURL))
+			testserver.New(
+				t,
+				testserver.WithJSON("<pre>"+dummyPyPySHA256+"  pypy"+tc.version+"-linux64.tar.bz2</pre>\n"),
+				testserver.WithMockURL(&pypyChecksumURL))
+			layer := &libcnb.Layer{
+				Path:     t.TempDir(),
+				Metadata: map[string]interface{}{},
+			}
+			ctx := gcp.NewContext(gcp.WithStackID("google.22"))
+
+			_, err := InstallTarballIfNotCached(ctx, PyPy, tc.version, layer)
+			if tc.wantError == (err == nil) {
+				t.Fatalf("InstallTarballIfNotCached(ctx, %q, %q) got error: %v, want error? %v", PyPy, tc.version, err, tc.wantError)
+			}
+			if tc.wantError {
+				return
+			}
+			fp := filepath.Join(layer.Path, "bin/pypy3")
+			if _, err := os.Stat(fp); err != nil {
+				t.Errorf("os.Stat(%q) got error: %v, want extracted PyPy binary", fp, err)
+			}
+			if got := ctx.GetMetadata(layer, versionKey); got != tc.version {
+				t.Errorf("layer version metadata = %q, want %q", got, tc.version)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/version"
+	"github.com/Masterminds/semver"
+)
+
+// versionCommand is the command that prints the version of a runtime on PATH.
+type versionCommand struct {
+	command []string
+	// versionRegexp captures the version of the runtime in the output of the command.
+	versionRegexp *regexp.Regexp
+	// list is true if the command lists all the installed versions of the runtime.
+	list bool
+	// normalize, if set, converts a captured version to the format of the version constraints.
+	normalize func(string) string
+}
+
+var (
+	// firstVersionRegexp captures the first version number in the output of a --version flag, e.g.
+	// 3.2.2 in "ruby 3.2.2p53 (2023-03-30 revision e51014f9c0)".
+	firstVersionRegexp = regexp.MustCompile(`\b([0-9]+(?:\.[0-9]+){0,2})`)
+	javaVersionRegexp  = regexp.MustCompile(`version "([0-9]+(?:\.[0-9]+){0,2})`)
+	// legacyJavaVersionRegexp matches the versions of Java 8 and earlier, e.g. 1.8.0, whose feature
+	// version is the second number.
+	legacyJavaVersionRegexp = regexp.MustCompile(`^1\.([0-9]+)`)
+
+	versionCommands = map[InstallableRuntime]versionCommand{
+		Nodejs:       {command: []string{"node", "--version"}, versionRegexp: regexp.MustCompile(`^v([0-9]+\.[0-9]+\.[0-9]+)`)},
+		PHP:          {command: []string{"php", "--version"}, versionRegexp: firstVersionRegexp},
+		Python:       {command: []string{"python3", "--version"}, versionRegexp: firstVersionRegexp},
+		Ruby:         {command: []string{"ruby", "--version"}, versionRegexp: firstVersionRegexp},
+		Go:           {command: []string{"go", "version"}, versionRegexp: regexp.MustCompile(`\bgo([0-9]+(?:\.[0-9]+){0,2})`)},
+		OpenJDK:      {command: []string{"java", "-version"}, versionRegexp: javaVersionRegexp, normalize: javaFeatureVersion},
+		CanonicalJDK: {command: []string{"java", "-version"}, versionRegexp: javaVersionRegexp, normalize: javaFeatureVersion},
+		// PyPy prints the version of the Python language it implements, e.g. "Python 3.10.12".
+		PyPy:       {command: []string{"pypy3", "--version"}, versionRegexp: firstVersionRegexp},
+		DotnetSDK:  {command: []string{"dotnet", "--list-sdks"}, versionRegexp: regexp.MustCompile(`(?m)^([0-9]+\.[0-9]+\.[0-9]+)`), list: true},
+		AspNetCore: {command: []string{"dotnet", "--list-runtimes"}, versionRegexp: regexp.MustCompile(`(?m)^Microsoft\.AspNetCore\.App ([0-9]+\.[0-9]+\.[0-9]+)`), list: true},
+	}
+)
+
+// javaFeatureVersion converts a Java 8 or earlier version, e.g. 1.8.0, to one that starts with the
+// feature version, e.g. 8.0, like the versions of Java 9 and later.
+func javaFeatureVersion(v string) string {
+	return legacyJavaVersionRegexp.ReplaceAllString(v, "$1")
+}
+
+// SkipInstall returns true if GOOGLE_SKIP_RUNTIME_INSTALL is set to true, in which case the runtime
+// buildpacks use the runtime of the base image instead of installing one.
+func SkipInstall() (bool, error) {
+	return env.IsPresentAndTrue(env.SkipRuntimeInstall)
+}
+
+// CheckPreinstalled returns the version of the runtime on PATH, or a user error if it is not on PATH
+// or if its version does not satisfy the version constraint that would have been installed.
+func CheckPreinstalled(ctx *gcp.Context, runtime InstallableRuntime, versionConstraint string) (string, error) {
+	runtimeName := runtimeNames[runtime]
+	vc, ok := versionCommands[runtime]
+	if !ok {
+		return "", gcp.InternalErrorf("%s does not support %s", env.SkipRuntimeInstall, runtime)
+	}
+	bin := vc.command[0]
+	result, err := ctx.Exec([]string{"bash", "-c", "command -v " + bin + " || true"})
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(result.Stdout) == "" {
+		return "", gcp.UserErrorf("%s is set but %s was not found on PATH: install %s in the base image or unset %s", env.SkipRuntimeInstall, bin, runtimeName, env.SkipRuntimeInstall)
+	}
+	result, err = ctx.Exec(vc.command, gcp.WithUserAttribution)
+	if err != nil {
+		return "", err
+	}
+	// Some runtimes, e.g. java, print their version to stderr.
+	output := strings.TrimSpace(result.Stdout + "\n" + result.Stderr)
+	n := 1
+	if vc.list {
+		n = -1
+	}
+	var versions []string
+	for _, m := range vc.versionRegexp.FindAllStringSubmatch(output, n) {
+		v := m[1]
+		if vc.normalize != nil {
+			v = vc.normalize(v)
+		}
+		if _, err := semver.NewVersion(v); err == nil {
+			versions = append(versions, v)
+		}
+	}
+	if len(versions) == 0 {
+		return "", gcp.UserErrorf("%s is set but the version of %s could not be determined from the output of %q: %s", env.SkipRuntimeInstall, runtimeName, strings.Join(vc.command, " "), result.Combined)
+	}
+	found, err := version.ResolveVersion(versionConstraint, versions, version.WithoutSanitization)
+	if err != nil {
+		return "", gcp.UserErrorf("%s is set but %s %s on PATH does not satisfy the requested version %q: install a matching version in the base image or change the requested version", env.SkipRuntimeInstall, runtimeName, strings.Join(versions, ", "), versionConstraint)
+	}
+	ctx.Logf("Using %s v%s from the base image because %s is set.", runtimeName, found, env.SkipRuntimeInstall)
+	if language, ok := runtimeLanguages[runtime]; ok {
+		ctx.ReportRuntime(language, found)
+	}
+	return found, nil
+}
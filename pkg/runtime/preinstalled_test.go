@@ -0,0 +1,160 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+func TestCheckPreinstalled(t *testing.T) {
+	testCases := []struct {
+		name       string
+		runtime    InstallableRuntime
+		constraint string
+		mocks      []*mockprocess.Mock
+		want       string
+		wantErr    bool
+	}{
+		{
+			name:       "node present and acceptable",
+			runtime:    Nodejs,
+			constraint: "20.x",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`command -v node`, mockprocess.WithStdout("/usr/local/bin/node")),
+				mockprocess.New(`^node --version`, mockprocess.WithStdout("v20.11.1\n")),
+			},
+			want: "20.11.1",
+		},
+		{
+			name:       "node present but wrong version",
+			runtime:    Nodejs,
+			constraint: "22.x",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`command -v node`, mockprocess.WithStdout("/usr/local/bin/node")),
+				mockprocess.New(`^node --version`, mockprocess.WithStdout("v20.11.1\n")),
+			},
+			wantErr: true,
+		},
+		{
+			name:       "node absent",
+			runtime:    Nodejs,
+			constraint: "*",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`command -v node`, mockprocess.WithStdout("")),
+			},
+			wantErr: true,
+		},
+		{
+			name:       "ruby ignores the release date",
+			runtime:    Ruby,
+			constraint: "*",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`command -v ruby`, mockprocess.WithStdout("/usr/bin/ruby")),
+				mockprocess.New(`^ruby --version`, mockprocess.WithStdout("ruby 3.2.2p53 (2023-03-30 revision e51014f9c0) [x86_64-linux]\n")),
+			},
+			want: "3.2.2",
+		},
+		{
+			name:       "java version on stderr",
+			runtime:    OpenJDK,
+			constraint: "17",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`command -v java`, mockprocess.WithStdout("/usr/bin/java")),
+				mockprocess.New(`^java -version`, mockprocess.WithStderr("openjdk version \"17.0.9\" 2023-10-17\nOpenJDK Runtime Environment (build 17.0.9+9)\n")),
+			},
+			want: "17.0.9",
+		},
+		{
+			name:       "java wrong feature version",
+			runtime:    OpenJDK,
+			constraint: "21",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`command -v java`, mockprocess.WithStdout("/usr/bin/java")),
+				mockprocess.New(`^java -version`, mockprocess.WithStderr("openjdk version \"17.0.9\" 2023-10-17\n")),
+			},
+			wantErr: true,
+		},
+		{
+			name:       "java 8 legacy version",
+			runtime:    OpenJDK,
+			constraint: "8",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`command -v java`, mockprocess.WithStdout("/usr/bin/java")),
+				mockprocess.New(`^java -version`, mockprocess.WithStderr("openjdk version \"1.8.0_392\"\nOpenJDK Runtime Environment (build 1.8.0_392-8u392-ga-1~22.04-b08)\n")),
+			},
+			want: "8.0",
+		},
+		{
+			name:       "pypy",
+			runtime:    PyPy,
+			constraint: "3.10.x",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`command -v pypy3`, mockprocess.WithStdout("/usr/bin/pypy3")),
+				mockprocess.New(`^pypy3 --version`, mockprocess.WithStdout("Python 3.10.12 (af44d0b8114cb82c40a07bb9ee9c1ca8a1b3688c, Jun 15 2023, 12:39:27)\n[PyPy 7.3.12 with GCC 10.2.1 20210130]\n")),
+			},
+			want: "3.10.12",
+		},
+		{
+			name:       "go",
+			runtime:    Go,
+			constraint: "1.21.x",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`command -v go`, mockprocess.WithStdout("/usr/local/go/bin/go")),
+				mockprocess.New(`^go version`, mockprocess.WithStdout("go version go1.21.5 linux/amd64\n")),
+			},
+			want: "1.21.5",
+		},
+		{
+			name:       "one of several aspnetcore runtimes",
+			runtime:    AspNetCore,
+			constraint: "6.0.x",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`command -v dotnet`, mockprocess.WithStdout("/usr/bin/dotnet")),
+				mockprocess.New(`^dotnet --list-runtimes`, mockprocess.WithStdout("Microsoft.AspNetCore.App 6.0.25 [/usr/share/dotnet/shared/Microsoft.AspNetCore.App]\nMicrosoft.AspNetCore.App 8.0.0 [/usr/share/dotnet/shared/Microsoft.AspNetCore.App]\nMicrosoft.NETCore.App 8.0.0 [/usr/share/dotnet/shared/Microsoft.NETCore.App]\n")),
+			},
+			want: "6.0.25",
+		},
+		{
+			name:       "unknown version",
+			runtime:    Python,
+			constraint: "*",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`command -v python3`, mockprocess.WithStdout("/usr/bin/python3")),
+				mockprocess.New(`^python3 --version`, mockprocess.WithStdout("Python\n")),
+			},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			eCmd, err := mockprocess.NewExecCmd(tc.mocks...)
+			if err != nil {
+				t.Fatalf("error creating mock exec command: %v", err)
+			}
+			ctx := gcp.NewContext(gcp.WithExecCmd(eCmd))
+
+			got, err := CheckPreinstalled(ctx, tc.runtime, tc.constraint)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("CheckPreinstalled(ctx, %q, %q) got error: %v, want error: %v", tc.runtime, tc.constraint, err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("CheckPreinstalled(ctx, %q, %q) = %q, want %q", tc.runtime, tc.constraint, got, tc.want)
+			}
+		})
+	}
+}
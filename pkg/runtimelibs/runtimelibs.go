@@ -0,0 +1,234 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package runtimelibs copies shared libraries needed by native modules at runtime from the build
+// image into a launch layer.
+package runtimelibs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	goruntime "runtime"
+	"sort"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/fileutil"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+const (
+	layerName = "runtime_libs"
+	// libraryPathEnv is the environment variable the dynamic linker searches for shared libraries.
+	libraryPathEnv = "LD_LIBRARY_PATH"
+)
+
+var (
+	// allowlist maps the library names accepted in GOOGLE_RUNTIME_LIBS to the shared library files
+	// they consist of. The libraries they depend on are found with ldd and copied with them.
+	allowlist = map[string][]string{
+		"libexif":  {"libexif.so.12*"},
+		"libgomp":  {"libgomp.so.1*"},
+		"libjpeg":  {"libjpeg.so.8*"},
+		"libmagic": {"libmagic.so.1*"},
+		"libpng":   {"libpng16.so.16*"},
+		"libpq":    {"libpq.so.5*"},
+		"libvips":  {"libvips.so.42*", "libvips-cpp.so.42*"},
+		"libwebp":  {"libwebp.so.7*", "libwebpmux.so.3*", "libwebpdemux.so.2*"},
+		"libxml2":  {"libxml2.so.2*"},
+		"libxslt":  {"libxslt.so.1*", "libexslt.so.0*"},
+	}
+
+	// libDirs are the directories of the build image searched for shared libraries, in order.
+	libDirs = searchDirs(goruntime.GOARCH)
+
+	// baseLibRegexp matches the libraries of the C and C++ runtimes, which the run image provides.
+	baseLibRegexp = regexp.MustCompile(`^(ld-linux.*|lib(c|m|dl|pthread|rt|resolv|gcc_s|stdc\+\+)\.so\..*)$`)
+	// lddPathRegexp matches the path a dependency resolves to in a line of ldd output.
+	lddPathRegexp = regexp.MustCompile(`=>\s+(/\S+)`)
+
+	// lddDeps returns the paths of the shared libraries the given library depends on, directly or
+	// indirectly. It is a variable so that tests can stub it.
+	lddDeps = func(ctx *gcp.Context, path string) ([]string, error) {
+		result, err := ctx.Exec([]string{"ldd", path})
+		if err != nil {
+			return nil, err
+		}
+		return parseLdd(result.Stdout)
+	}
+)
+
+// multiarchTriplets maps GOARCH values to the Debian multiarch triplets of library directories.
+var multiarchTriplets = map[string]string{
+	"amd64": "x86_64-linux-gnu",
+	"arm64": "aarch64-linux-gnu",
+}
+
+// searchDirs returns the directories searched for shared libraries on the given architecture.
+func searchDirs(goarch string) []string {
+	var dirs []string
+	if triplet, ok := multiarchTriplets[goarch]; ok {
+		dirs = append(dirs, filepath.Join("/usr/lib", triplet), filepath.Join("/lib", triplet))
+	}
+	return append(dirs, "/usr/lib", "/lib")
+}
+
+// parseLdd returns the library paths in the output of ldd. It returns a user error if a dependency
+// is not installed in the build image.
+func parseLdd(output string) ([]string, error) {
+	var paths []string
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasSuffix(line, "not found") {
+			return nil, gcp.UserErrorf("shared library %s needed by %s was not found in the build image", strings.Fields(line)[0], env.RuntimeLibs)
+		}
+		if m := lddPathRegexp.FindStringSubmatch(line); m != nil {
+			paths = append(paths, m[1])
+		}
+	}
+	return paths, nil
+}
+
+// Resolve returns the shared library file patterns for a comma-separated list of library names. It
+// returns a user error if any of the names is not in the allowlist.
+func Resolve(names string) ([]string, error) {
+	var patterns, unknown []string
+	seen := map[string]bool{}
+	for _, name := range strings.Split(names, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		files, ok := allowlist[name]
+		if !ok {
+			unknown = append(unknown, name)
+			continue
+		}
+		patterns = append(patterns, files...)
+	}
+	if len(unknown) > 0 {
+		return nil, gcp.UserErrorf("unsupported libraries in %s: %s, supported libraries are: %s", env.RuntimeLibs, strings.Join(unknown, ", "), strings.Join(supported(), ", "))
+	}
+	return patterns, nil
+}
+
+// supported returns the sorted names of the allowlisted libraries.
+func supported() []string {
+	var names []string
+	for name := range allowlist {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Install copies the shared libraries listed in GOOGLE_RUNTIME_LIBS from the build image into a
+// launch layer and prepends the layer to LD_LIBRARY_PATH. It does nothing if the env var is not set.
+func Install(ctx *gcp.Context) error {
+	names := os.Getenv(env.RuntimeLibs)
+	if strings.TrimSpace(names) == "" {
+		return nil
+	}
+	patterns, err := Resolve(names)
+	if err != nil {
+		return err
+	}
+	l, err := ctx.Layer(layerName, gcp.LaunchLayer)
+	if err != nil {
+		return fmt.Errorf("creating %v layer: %w", layerName, err)
+	}
+	return copyLibs(ctx, l, patterns)
+}
+
+// copyLibs copies the shared library files matching patterns into the lib directory of l, keeping
+// symlinks between versions of a library, along with the libraries they depend on that the run
+// image does not provide. It prepends that directory to LD_LIBRARY_PATH.
+func copyLibs(ctx *gcp.Context, l *libcnb.Layer, patterns []string) error {
+	libDir := filepath.Join(l.Path, "lib")
+	if err := ctx.MkdirAll(libDir, 0755); err != nil {
+		return err
+	}
+	copied := map[string]bool{}
+	var requested []string
+	for _, pattern := range patterns {
+		found := false
+		for _, dir := range libDirs {
+			matches, err := ctx.Glob(filepath.Join(dir, pattern))
+			if err != nil {
+				return err
+			}
+			for _, src := range matches {
+				found = true
+				name := filepath.Base(src)
+				if copied[name] {
+					continue
+				}
+				copied[name] = true
+				requested = append(requested, src)
+				if err := copyLib(ctx, filepath.Join(libDir, name), src, pattern); err != nil {
+					return err
+				}
+			}
+		}
+		if !found {
+			return gcp.UserErrorf("shared library %s requested in %s was not found in the build image", pattern, env.RuntimeLibs)
+		}
+	}
+	for _, src := range requested {
+		deps, err := lddDeps(ctx, src)
+		if err != nil {
+			return err
+		}
+		for _, dep := range deps {
+			name := filepath.Base(dep)
+			if copied[name] || baseLibRegexp.MatchString(name) {
+				continue
+			}
+			copied[name] = true
+			// The dependency is copied by content under the name the dynamic linker looks up.
+			if err := fileutil.CopyFile(filepath.Join(libDir, name), dep); err != nil {
+				return gcp.InternalErrorf("copying %s to %s: %v", dep, libDir, err)
+			}
+		}
+	}
+	ctx.Logf("Copied %d shared library files to %s.", len(copied), libDir)
+	l.LaunchEnvironment.Prepend(libraryPathEnv, string(os.PathListSeparator), libDir)
+	return nil
+}
+
+// copyLib copies a single shared library file. Symlinks to another file matching pattern in the
+// same directory are recreated so that they point to its copy; other files are copied by content.
+func copyLib(ctx *gcp.Context, dest, src, pattern string) error {
+	fi, err := os.Lstat(src)
+	if err != nil {
+		return gcp.InternalErrorf("reading %s: %v", src, err)
+	}
+	if fi.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(src)
+		if err != nil {
+			return gcp.InternalErrorf("reading link %s: %v", src, err)
+		}
+		if ok, _ := filepath.Match(pattern, target); ok {
+			return ctx.Symlink(target, dest)
+		}
+	}
+	if err := fileutil.CopyFile(dest, src); err != nil {
+		return gcp.InternalErrorf("copying %s to %s: %v", src, dest, err)
+	}
+	return nil
+}
@@ -0,0 +1,259 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtimelibs
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+func TestResolve(t *testing.T) {
+	testCases := []struct {
+		name    string
+		names   string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:  "single library",
+			names: "libpq",
+			want:  []string{"libpq.so.5*"},
+		},
+		{
+			name:  "multiple libraries with spaces and duplicates",
+			names: " libvips, LIBPQ,libvips ,",
+			want:  []string{"libvips.so.42*", "libvips-cpp.so.42*", "libpq.so.5*"},
+		},
+		{
+			name:    "library not in allowlist",
+			names:   "libpq,libssl",
+			wantErr: true,
+		},
+		{
+			name:    "path instead of name",
+			names:   "/usr/lib/libpq.so.5",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Resolve(tc.names)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("Resolve(%q) got error: %v, want error: %v", tc.names, err, tc.wantErr)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Resolve(%q) = %v, want %v", tc.names, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCopyLibs(t *testing.T) {
+	testCases := []struct {
+		name      string
+		patterns  []string
+		wantFiles []string
+		wantLinks map[string]string
+		// wantAbsent are the dependencies that must not be copied.
+		wantAbsent []string
+		lddErr     bool
+		wantErr    bool
+	}{
+		{
+			name:      "library with version symlink",
+			patterns:  []string{"libpq.so.5*"},
+			wantFiles: []string{"libpq.so.5.16"},
+			wantLinks: map[string]string{"libpq.so.5": "libpq.so.5.16"},
+		},
+		{
+			name:      "libraries from several directories",
+			patterns:  []string{"libpq.so.5*", "libvips.so.42*"},
+			wantFiles: []string{"libpq.so.5.16", "libvips.so.42.17.1"},
+		},
+		{
+			name:       "library with dependencies",
+			patterns:   []string{"libvips.so.42*"},
+			wantFiles:  []string{"libvips.so.42.17.1", "libglib-2.0.so.0", "libexpat.so.1"},
+			wantAbsent: []string{"libc.so.6", "libstdc++.so.6"},
+		},
+		{
+			name:     "library not installed",
+			patterns: []string{"libxml2.so.2*"},
+			wantErr:  true,
+		},
+		{
+			name:     "dependency not installed",
+			patterns: []string{"libpq.so.5*"},
+			lddErr:   true,
+			wantErr:  true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			usrLib, lib := t.TempDir(), t.TempDir()
+			writeLib(t, usrLib, "libpq.so.5.16")
+			if err := os.Symlink("libpq.so.5.16", filepath.Join(usrLib, "libpq.so.5")); err != nil {
+				t.Fatal(err)
+			}
+			writeLib(t, lib, "libvips.so.42.17.1")
+			writeLib(t, lib, "libglib-2.0.so.0.7200.4")
+			if err := os.Symlink("libglib-2.0.so.0.7200.4", filepath.Join(lib, "libglib-2.0.so.0")); err != nil {
+				t.Fatal(err)
+			}
+			writeLib(t, lib, "libexpat.so.1")
+			writeLib(t, lib, "libc.so.6")
+			writeLib(t, lib, "libstdc++.so.6")
+			defer func(dirs []string) { libDirs = dirs }(libDirs)
+			libDirs = []string{usrLib, lib}
+			defer func(f func(*gcp.Context, string) ([]string, error)) { lddDeps = f }(lddDeps)
+			lddDeps = func(_ *gcp.Context, path string) ([]string, error) {
+				if tc.lddErr {
+					return nil, gcp.UserErrorf("libkrb5.so.3 not found")
+				}
+				if filepath.Base(path) != "libvips.so.42.17.1" {
+					return nil, nil
+				}
+				var deps []string
+				for _, name := range []string{"libglib-2.0.so.0", "libexpat.so.1", "libc.so.6", "libstdc++.so.6"} {
+					deps = append(deps, filepath.Join(lib, name))
+				}
+				return deps, nil
+			}
+			l := &libcnb.Layer{Path: t.TempDir(), LaunchEnvironment: libcnb.Environment{}}
+
+			err := copyLibs(gcp.NewContext(), l, tc.patterns)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("copyLibs(%v) got error: %v, want error: %v", tc.patterns, err, tc.wantErr)
+			}
+			if tc.wantErr {
+				if _, ok := l.LaunchEnvironment[libraryPathEnv+".prepend"]; ok {
+					t.Errorf("copyLibs(%v) set %s, want it unset on error", tc.patterns, libraryPathEnv)
+				}
+				return
+			}
+			libDir := filepath.Join(l.Path, "lib")
+			if got := l.LaunchEnvironment[libraryPathEnv+".prepend"]; got != libDir {
+				t.Errorf("%s.prepend = %q, want %q", libraryPathEnv, got, libDir)
+			}
+			for _, f := range tc.wantFiles {
+				fi, err := os.Lstat(filepath.Join(libDir, f))
+				if err != nil {
+					t.Errorf("copyLibs(%v) did not copy %s: %v", tc.patterns, f, err)
+				} else if !fi.Mode().IsRegular() {
+					t.Errorf("copyLibs(%v) copied %s as mode %v, want a regular file", tc.patterns, f, fi.Mode())
+				}
+			}
+			for _, f := range tc.wantAbsent {
+				if _, err := os.Lstat(filepath.Join(libDir, f)); err == nil {
+					t.Errorf("copyLibs(%v) copied %s, want it provided by the run image", tc.patterns, f)
+				}
+			}
+			for link, want := range tc.wantLinks {
+				got, err := os.Readlink(filepath.Join(libDir, link))
+				if err != nil {
+					t.Errorf("copyLibs(%v) did not link %s: %v", tc.patterns, link, err)
+				} else if got != want {
+					t.Errorf("copyLibs(%v) linked %s to %q, want %q", tc.patterns, link, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseLdd(t *testing.T) {
+	testCases := []struct {
+		name    string
+		output  string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "dependencies",
+			output: `	linux-vdso.so.1 (0x00007ffd6c3f2000)
+	libglib-2.0.so.0 => /lib/x86_64-linux-gnu/libglib-2.0.so.0 (0x00007f3c1a9b0000)
+	libexpat.so.1 => /lib/x86_64-linux-gnu/libexpat.so.1 (0x00007f3c1a980000)
+	libc.so.6 => /lib/x86_64-linux-gnu/libc.so.6 (0x00007f3c1a600000)
+	/lib64/ld-linux-x86-64.so.2 (0x00007f3c1ab00000)
+`,
+			want: []string{"/lib/x86_64-linux-gnu/libglib-2.0.so.0", "/lib/x86_64-linux-gnu/libexpat.so.1", "/lib/x86_64-linux-gnu/libc.so.6"},
+		},
+		{
+			name:    "dependency not found",
+			output:  "\tlibkrb5.so.3 => not found\n",
+			wantErr: true,
+		},
+		{
+			name: "no dependencies",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseLdd(tc.output)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("parseLdd() got error: %v, want error: %v", err, tc.wantErr)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("parseLdd() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSearchDirs(t *testing.T) {
+	testCases := []struct {
+		goarch string
+		want   []string
+	}{
+		{
+			goarch: "amd64",
+			want:   []string{"/usr/lib/x86_64-linux-gnu", "/lib/x86_64-linux-gnu", "/usr/lib", "/lib"},
+		},
+		{
+			goarch: "arm64",
+			want:   []string{"/usr/lib/aarch64-linux-gnu", "/lib/aarch64-linux-gnu", "/usr/lib", "/lib"},
+		},
+		{
+			goarch: "riscv64",
+			want:   []string{"/usr/lib", "/lib"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.goarch, func(t *testing.T) {
+			if got := searchDirs(tc.goarch); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("searchDirs(%q) = %v, want %v", tc.goarch, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestInstallNotSet(t *testing.T) {
+	t.Setenv("GOOGLE_RUNTIME_LIBS", "")
+	// The context has no layers directory, so creating a layer would fail.
+	if err := Install(gcp.NewContext()); err != nil {
+		t.Errorf("Install() got error: %v", err)
+	}
+}
+
+func writeLib(t *testing.T, dir, name string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte("ELF"), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
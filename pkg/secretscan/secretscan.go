@@ -0,0 +1,286 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package secretscan detects secrets committed to the application source, e.g. a .env file with
+// credentials, so that users can be warned before they are shipped in the application image.
+package secretscan
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// EntropyThreshold is the minimum Shannon entropy, in bits per character, of a value assigned to
+	// a secret-like key for it to be reported. Random base64 strings are above it, while words and
+	// placeholders such as "development-secret-key-1234" are below it.
+	EntropyThreshold = 4.0
+
+	// HexEntropyThreshold is the minimum Shannon entropy of a hexadecimal value, which cannot exceed
+	// 4 bits per character, for it to be reported.
+	HexEntropyThreshold = 3.0
+
+	// minSecretLength is the minimum length of a value assigned to a secret-like key for it to be
+	// reported.
+	minSecretLength = 16
+	// minHexSecretLength is the minimum length of a hexadecimal value for it to be reported.
+	minHexSecretLength = 32
+	// maxFileSize is the size above which files are not scanned, since source files with secrets
+	// are small.
+	maxFileSize = 1 << 20
+	// maxReportedFiles is the maximum number of files listed in the warning.
+	maxReportedFiles = 20
+)
+
+var (
+	// skippedDirs are the directories of dependencies and tools, which are not scanned.
+	skippedDirs = map[string]bool{
+		".git":             true,
+		".hg":              true,
+		".svn":             true,
+		".venv":            true,
+		"venv":             true,
+		"__pycache__":      true,
+		"node_modules":     true,
+		"bower_components": true,
+		".yarn":            true,
+		".pnpm-store":      true,
+		"vendor":           true,
+		".bundle":          true,
+		".gradle":          true,
+		".m2":              true,
+	}
+
+	// patterns match the secrets that are recognizable by their format.
+	patterns = []struct {
+		kind string
+		re   *regexp.Regexp
+	}{
+		{"private key", regexp.MustCompile(`-----BEGIN (?:[A-Z]+ )?PRIVATE KEY(?: BLOCK)?-----`)},
+		{"AWS access key ID", regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+		{"Google API key", regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+		{"GitHub token", regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`)},
+		{"Slack token", regexp.MustCompile(`\bxox[abposr]-[0-9A-Za-z-]{10,}`)},
+		{"Stripe secret key", regexp.MustCompile(`\b[sr]k_live_[0-9A-Za-z]{24,}\b`)},
+	}
+
+	// secretAssignmentRegexp matches a value assigned to a secret-like key in code or config, e.g.
+	// `aws_secret_access_key = "..."` or `"apiKey": "..."`, capturing the key and the value.
+	secretAssignmentRegexp = regexp.MustCompile(`(?i)([a-z0-9_.-]*(?:secret|passw(?:or)?d|token|api[_-]?key|access[_-]?key|private[_-]?key|credential|auth)[a-z0-9_.-]*)["']?\s*[:=]\s*["']?([A-Za-z0-9+/=_.~-]+)`)
+	// hexRegexp matches a hexadecimal string.
+	hexRegexp = regexp.MustCompile(`^[0-9a-fA-F]+$`)
+	// dotenvRegexp matches a variable of a .env file, capturing its name and value.
+	dotenvRegexp = regexp.MustCompile(`^\s*(?:export\s+)?([A-Za-z_][A-Za-z0-9_]*)\s*=\s*["']?([A-Za-z0-9+/=_.~-]+)`)
+)
+
+// Finding is a secret found in a file. It never includes the secret value.
+type Finding struct {
+	// Path is the path of the file relative to the scanned directory.
+	Path string
+	// Line is the line number of the secret, starting at 1.
+	Line int
+	// Kind describes the secret, e.g. "private key".
+	Kind string
+}
+
+// Report scans the application source for secrets if GOOGLE_SCAN_FOR_SECRETS is true and warns
+// about the files that contain them. It never fails the build.
+func Report(ctx *gcp.Context) {
+	enabled, err := env.IsPresentAndTrue(env.ScanForSecrets)
+	if err != nil {
+		ctx.Warnf("Skipping the scan for secrets: %v", err)
+		return
+	}
+	if !enabled {
+		return
+	}
+	findings, err := Scan(ctx.ApplicationRoot())
+	if err != nil {
+		ctx.Warnf("Scanning the application source for secrets failed: %v", err)
+		return
+	}
+	if len(findings) == 0 {
+		ctx.Logf("No secrets found in the application source.")
+		return
+	}
+	ctx.Warnf("%s", warning(findings))
+}
+
+// warning returns the warning listing the files that contain the findings.
+func warning(findings []Finding) string {
+	var files []string
+	byFile := map[string][]string{}
+	for _, f := range findings {
+		if _, ok := byFile[f.Path]; !ok {
+			files = append(files, f.Path)
+		}
+		byFile[f.Path] = append(byFile[f.Path], fmt.Sprintf("line %d (%s)", f.Line, f.Kind))
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Found possible secrets in %d file(s) of the application source, which are included in the application image. Remove them from the source, e.g. with a .gcloudignore or .dockerignore file, and provide them at runtime instead, e.g. with Secret Manager:", len(files))
+	for i, path := range files {
+		if i == maxReportedFiles {
+			fmt.Fprintf(&b, "\n  ... and %d more file(s)", len(files)-maxReportedFiles)
+			break
+		}
+		fmt.Fprintf(&b, "\n  %s: %s", path, strings.Join(byFile[path], ", "))
+	}
+	return b.String()
+}
+
+// Scan returns the secrets found in the text files under root, sorted by path and line. The
+// directories of dependencies, e.g. node_modules, and symlinks are not scanned.
+func Scan(root string) ([]Finding, error) {
+	var findings []Finding
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != root && skippedDirs[d.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if info.Size() > maxFileSize {
+			return nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		findings = append(findings, scanFile(filepath.ToSlash(rel), content)...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Path != findings[j].Path {
+			return findings[i].Path < findings[j].Path
+		}
+		return findings[i].Line < findings[j].Line
+	})
+	return findings, nil
+}
+
+// scanFile returns the secrets found in the content of a file, at most one per line.
+func scanFile(path string, content []byte) []Finding {
+	if isBinary(content) {
+		return nil
+	}
+	dotenv := isDotenv(path)
+	var findings []Finding
+	s := bufio.NewScanner(bytes.NewReader(content))
+	s.Buffer(make([]byte, 0, 64*1024), maxFileSize)
+	for n := 1; s.Scan(); n++ {
+		if kind := scanLine(s.Text(), dotenv); kind != "" {
+			findings = append(findings, Finding{Path: path, Line: n, Kind: kind})
+		}
+	}
+	return findings
+}
+
+// scanLine returns the kind of secret found in a line, or an empty string if there is none.
+func scanLine(line string, dotenv bool) string {
+	for _, p := range patterns {
+		if p.re.MatchString(line) {
+			return p.kind
+		}
+	}
+	if dotenv {
+		if m := dotenvRegexp.FindStringSubmatch(line); m != nil && isHighEntropy(m[2]) {
+			return fmt.Sprintf("high-entropy value of %s", m[1])
+		}
+		return ""
+	}
+	for _, m := range secretAssignmentRegexp.FindAllStringSubmatch(line, -1) {
+		if isHighEntropy(m[2]) {
+			return fmt.Sprintf("high-entropy value of %s", m[1])
+		}
+	}
+	return ""
+}
+
+// isHighEntropy returns true if the value is long and random enough to be a secret.
+func isHighEntropy(value string) bool {
+	if hexRegexp.MatchString(value) {
+		return len(value) >= minHexSecretLength && ShannonEntropy(value) >= HexEntropyThreshold
+	}
+	return len(value) >= minSecretLength && ShannonEntropy(value) >= EntropyThreshold
+}
+
+// ShannonEntropy returns the Shannon entropy of s in bits per character.
+func ShannonEntropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+	counts := map[rune]int{}
+	n := 0
+	for _, c := range s {
+		counts[c]++
+		n++
+	}
+	var entropy float64
+	for _, count := range counts {
+		p := float64(count) / float64(n)
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// isDotenv returns true if the file is a .env file with real values, e.g. .env or
+// .env.production, as opposed to a template such as .env.example.
+func isDotenv(path string) bool {
+	name := filepath.Base(path)
+	if name != ".env" && !strings.HasPrefix(name, ".env.") {
+		return false
+	}
+	for _, suffix := range []string{".example", ".sample", ".template", ".dist"} {
+		if strings.HasSuffix(name, suffix) {
+			return false
+		}
+	}
+	return true
+}
+
+// isBinary returns true if the content has a NUL byte in its first 8000 bytes, like git does.
+func isBinary(content []byte) bool {
+	if len(content) > 8000 {
+		content = content[:8000]
+	}
+	return bytes.IndexByte(content, 0) != -1
+}
@@ -0,0 +1,83 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package toolversions reads the runtime versions declared in an asdf .tool-versions file.
+package toolversions
+
+import (
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// File is the name of the asdf file that declares the versions of the tools of a project.
+	File = ".tool-versions"
+
+	// The asdf plugin names of the runtimes installed by the buildpacks.
+	Nodejs = "nodejs"
+	Ruby   = "ruby"
+	Python = "python"
+	Golang = "golang"
+	PHP    = "php"
+	Java   = "java"
+	// Dotnet and DotnetCore are the names of the two asdf plugins that install the .NET SDK.
+	Dotnet     = "dotnet"
+	DotnetCore = "dotnet-core"
+)
+
+// Parse parses the content of a .tool-versions file and returns the version of each tool. Each
+// line holds a tool name followed by one or more versions, the first of which is the preferred
+// one. Comments start with # and blank lines are ignored. If a tool is listed more than once, the
+// first line wins.
+func Parse(content string) map[string]string {
+	versions := map[string]string{}
+	for _, line := range strings.Split(content, "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		if _, ok := versions[fields[0]]; !ok {
+			versions[fields[0]] = fields[1]
+		}
+	}
+	return versions
+}
+
+// Version returns the version of the given tool declared in the .tool-versions file closest to
+// the application root within the workspace, or an empty string if there is none.
+func Version(ctx *gcp.Context, tool string) (string, error) {
+	path, err := ctx.FindInWorkspace(File)
+	if err != nil || path == "" {
+		return "", err
+	}
+	content, err := ctx.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	v := Parse(string(content))[tool]
+	if v == "" {
+		return "", nil
+	}
+	// asdf uses "system" to defer to the version installed outside of asdf, and "ref:" or "path:"
+	// for versions built from source, none of which the buildpacks can install.
+	if v == "system" || strings.HasPrefix(v, "ref:") || strings.HasPrefix(v, "path:") {
+		ctx.Warnf("Ignoring unsupported %s version %q in %s.", tool, v, File)
+		return "", nil
+	}
+	return v, nil
+}
@@ -0,0 +1,183 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package toolversions
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/google/go-cmp/cmp"
+)
+
+const multiToolFile = `# Versions used by the project.
+nodejs 20.11.0
+ruby   3.3.0 3.2.2
+python 3.12.1  # the latest release
+golang 1.22.0
+
+php 8.3.2
+java temurin-21.0.2+13.0.LTS
+nodejs 18.19.0
+`
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+		want    map[string]string
+	}{
+		{
+			name:    "multiple tools",
+			content: multiToolFile,
+			want: map[string]string{
+				"nodejs": "20.11.0",
+				"ruby":   "3.3.0",
+				"python": "3.12.1",
+				"golang": "1.22.0",
+				"php":    "8.3.2",
+				"java":   "temurin-21.0.2+13.0.LTS",
+			},
+		},
+		{
+			name:    "comments only",
+			content: "# nodejs 20.11.0\n  # ruby 3.3.0\n",
+			want:    map[string]string{},
+		},
+		{
+			name:    "tool without version",
+			content: "nodejs\nruby 3.3.0",
+			want:    map[string]string{"ruby": "3.3.0"},
+		},
+		{
+			name:    "crlf line endings",
+			content: "nodejs 20.11.0\r\npython 3.12.1\r\n",
+			want:    map[string]string{"nodejs": "20.11.0", "python": "3.12.1"},
+		},
+		{
+			name: "empty",
+			want: map[string]string{},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, Parse(tc.content)); diff != "" {
+				t.Errorf("Parse() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestVersion(t *testing.T) {
+	testCases := []struct {
+		name    string
+		tool    string
+		content string
+		// inParent writes the file in the parent directory of the application root.
+		inParent bool
+		want     string
+	}{
+		{
+			name:    "nodejs",
+			tool:    Nodejs,
+			content: multiToolFile,
+			want:    "20.11.0",
+		},
+		{
+			name:    "ruby",
+			tool:    Ruby,
+			content: multiToolFile,
+			want:    "3.3.0",
+		},
+		{
+			name:    "python",
+			tool:    Python,
+			content: multiToolFile,
+			want:    "3.12.1",
+		},
+		{
+			name:    "golang",
+			tool:    Golang,
+			content: multiToolFile,
+			want:    "1.22.0",
+		},
+		{
+			name:    "php",
+			tool:    PHP,
+			content: multiToolFile,
+			want:    "8.3.2",
+		},
+		{
+			name:    "java",
+			tool:    Java,
+			content: multiToolFile,
+			want:    "temurin-21.0.2+13.0.LTS",
+		},
+		{
+			name:     "in workspace root",
+			tool:     Python,
+			content:  multiToolFile,
+			inParent: true,
+			want:     "3.12.1",
+		},
+		{
+			name:    "tool not listed",
+			tool:    "dotnet",
+			content: multiToolFile,
+		},
+		{
+			name:    "system version",
+			tool:    Nodejs,
+			content: "nodejs system",
+		},
+		{
+			name:    "ref version",
+			tool:    Ruby,
+			content: "ruby ref:v3_3_0",
+		},
+		{
+			name: "no file",
+			tool: Nodejs,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			workspace := t.TempDir()
+			appRoot := filepath.Join(workspace, "app")
+			if err := os.Mkdir(appRoot, 0755); err != nil {
+				t.Fatal(err)
+			}
+			if tc.content != "" {
+				dir := appRoot
+				if tc.inParent {
+					dir = workspace
+				}
+				if err := os.WriteFile(filepath.Join(dir, File), []byte(tc.content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(appRoot), gcp.WithWorkspaceRoot(workspace))
+
+			got, err := Version(ctx, tc.tool)
+			if err != nil {
+				t.Fatalf("Version(%q) got error: %v", tc.tool, err)
+			}
+			if got != tc.want {
+				t.Errorf("Version(%q) = %q, want %q", tc.tool, got, tc.want)
+			}
+		})
+	}
+}
@@ -54,6 +54,10 @@ type OverrideProperties struct {
 	PHPIniOverrideFileName string
 	// NginxServesStaticFiles whether Nginx also serves static files for matching URIs.
 	NginxServesStaticFiles bool
+	// NginxSecurityHeaders whether Nginx adds HTTP security headers to its responses.
+	NginxSecurityHeaders bool
+	// NginxStubStatus whether Nginx exposes its stub_status page on /_nginx/status.
+	NginxStubStatus bool
 }
 
 // OverriddenProperties returns whether the property has been overridden and the path to the file.